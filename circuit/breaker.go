@@ -4,7 +4,10 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
+
+	"github.com/sony/gobreaker"
 )
 
 // BreakerType defines the type of the used breaker: consecutive, rate or disabled.
@@ -48,11 +51,49 @@ type BreakerSettings struct {
 	Failures         int           `yaml:"failures"`
 	Timeout          time.Duration `yaml:"timeout"`
 	HalfOpenRequests int           `yaml:"half-open-requests"`
+	HalfOpenProbes   int           `yaml:"half-open-probes"`
 	IdleTTL          time.Duration `yaml:"idle-ttl"`
+	PerEndpoint      bool          `yaml:"per-endpoint"`
+
+	// Endpoint is set by the proxy to scope the breaker to a single
+	// endpoint of a load balanced backend.
+	Endpoint string `yaml:"-"`
+}
+
+// State represents the state of a circuit breaker.
+type State int
+
+const (
+	StateClosed State = iota
+	StateHalfOpen
+	StateOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case StateHalfOpen:
+		return "half-open"
+	case StateOpen:
+		return "open"
+	default:
+		return "closed"
+	}
+}
+
+func fromGobreakerState(s gobreaker.State) State {
+	switch s {
+	case gobreaker.StateHalfOpen:
+		return StateHalfOpen
+	case gobreaker.StateOpen:
+		return StateOpen
+	default:
+		return StateClosed
+	}
 }
 
 type breakerImplementation interface {
 	Allow() (func(bool), bool)
+	State() State
 }
 
 type voidBreaker struct{}
@@ -64,6 +105,7 @@ type Breaker struct {
 	settings BreakerSettings
 	ts       time.Time
 	impl     breakerImplementation
+	probes   atomic.Int64
 }
 
 func (to BreakerSettings) mergeSettings(from BreakerSettings) BreakerSettings {
@@ -88,6 +130,14 @@ func (to BreakerSettings) mergeSettings(from BreakerSettings) BreakerSettings {
 		to.HalfOpenRequests = from.HalfOpenRequests
 	}
 
+	if to.HalfOpenProbes == 0 {
+		to.HalfOpenProbes = from.HalfOpenProbes
+	}
+
+	if !to.PerEndpoint {
+		to.PerEndpoint = from.PerEndpoint
+	}
+
 	if to.IdleTTL == 0 {
 		to.IdleTTL = from.IdleTTL
 	}
@@ -116,6 +166,10 @@ func (s BreakerSettings) String() string {
 		ss = append(ss, "host="+s.Host)
 	}
 
+	if s.Endpoint != "" {
+		ss = append(ss, "endpoint="+s.Endpoint)
+	}
+
 	if s.Type == FailureRate && s.Window > 0 {
 		ss = append(ss, "window="+strconv.Itoa(s.Window))
 	}
@@ -132,10 +186,18 @@ func (s BreakerSettings) String() string {
 		ss = append(ss, "half-open-requests="+strconv.Itoa(s.HalfOpenRequests))
 	}
 
+	if s.HalfOpenProbes > 0 {
+		ss = append(ss, "half-open-probes="+strconv.Itoa(s.HalfOpenProbes))
+	}
+
 	if s.IdleTTL > 0 {
 		ss = append(ss, "idle-ttl="+s.IdleTTL.String())
 	}
 
+	if s.PerEndpoint {
+		ss = append(ss, "per-endpoint=true")
+	}
+
 	return strings.Join(ss, ",")
 }
 
@@ -143,13 +205,17 @@ func (b voidBreaker) Allow() (func(bool), bool) {
 	return func(bool) {}, true
 }
 
-func newBreaker(s BreakerSettings) *Breaker {
+func (b voidBreaker) State() State {
+	return StateClosed
+}
+
+func newBreaker(s BreakerSettings, onStateChange func(BreakerSettings, State, State)) *Breaker {
 	var impl breakerImplementation
 	switch s.Type {
 	case ConsecutiveFailures:
-		impl = newConsecutive(s)
+		impl = newConsecutive(s, onStateChange)
 	case FailureRate:
-		impl = newRate(s)
+		impl = newRate(s, onStateChange)
 	default:
 		impl = voidBreaker{}
 	}
@@ -162,9 +228,38 @@ func newBreaker(s BreakerSettings) *Breaker {
 
 // Allow returns true if the breaker is in the closed state and a callback function for reporting the outcome of
 // the operation. The callback expects true values if the outcome of the request was successful. Allow may not
-// return a callback function when the state is open.
+// return a callback function when the state is open. In the half-open state, it allows only HalfOpenProbes
+// number of concurrent requests, when set.
 func (b *Breaker) Allow() (func(bool), bool) {
-	return b.impl.Allow()
+	if b.settings.HalfOpenProbes <= 0 || b.impl.State() != StateHalfOpen {
+		return b.impl.Allow()
+	}
+
+	if b.probes.Add(1) > int64(b.settings.HalfOpenProbes) {
+		b.probes.Add(-1)
+		return nil, false
+	}
+
+	done, ok := b.impl.Allow()
+	if !ok {
+		b.probes.Add(-1)
+		return nil, false
+	}
+
+	return func(success bool) {
+		b.probes.Add(-1)
+		done(success)
+	}, true
+}
+
+// State returns the current state of the breaker.
+func (b *Breaker) State() State {
+	return b.impl.State()
+}
+
+// Settings returns the settings of the breaker.
+func (b *Breaker) Settings() BreakerSettings {
+	return b.settings
 }
 
 func (b *Breaker) idle(now time.Time) bool {
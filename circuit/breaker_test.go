@@ -58,24 +58,24 @@ func TestConsecutiveFailures(t *testing.T) {
 	}
 
 	t.Run("new breaker closed", func(t *testing.T) {
-		b := newBreaker(s)
+		b := newBreaker(s, nil)
 		checkClosed(t, b)
 	})
 
 	t.Run("does not open on not enough failures", func(t *testing.T) {
-		b := newBreaker(s)
+		b := newBreaker(s, nil)
 		times(s.Failures-1, fail(t, b))
 		checkClosed(t, b)
 	})
 
 	t.Run("open on failures", func(t *testing.T) {
-		b := newBreaker(s)
+		b := newBreaker(s, nil)
 		times(s.Failures, fail(t, b))
 		checkOpen(t, b)
 	})
 
 	t.Run("go half open, close after required successes", func(t *testing.T) {
-		b := newBreaker(s)
+		b := newBreaker(s, nil)
 		times(s.Failures, fail(t, b))
 		waitTimeout()
 		times(s.HalfOpenRequests, succeed(t, b))
@@ -83,7 +83,7 @@ func TestConsecutiveFailures(t *testing.T) {
 	})
 
 	t.Run("go half open, reopen after a fail within the required successes", func(t *testing.T) {
-		b := newBreaker(s)
+		b := newBreaker(s, nil)
 		times(s.Failures, fail(t, b))
 		waitTimeout()
 		times(s.HalfOpenRequests-1, succeed(t, b))
@@ -102,12 +102,12 @@ func TestRateBreaker(t *testing.T) {
 	}
 
 	t.Run("new breaker closed", func(t *testing.T) {
-		b := newBreaker(s)
+		b := newBreaker(s, nil)
 		checkClosed(t, b)
 	})
 
 	t.Run("doesn't open if failure count is not within a window", func(t *testing.T) {
-		b := newBreaker(s)
+		b := newBreaker(s, nil)
 		times(1, fail(t, b))
 		times(2, succeed(t, b))
 		checkClosed(t, b)
@@ -120,7 +120,7 @@ func TestRateBreaker(t *testing.T) {
 	})
 
 	t.Run("opens on reaching the rate", func(t *testing.T) {
-		b := newBreaker(s)
+		b := newBreaker(s, nil)
 		times(s.Window, succeed(t, b))
 		times(s.Failures, fail(t, b))
 		checkOpen(t, b)
@@ -148,7 +148,7 @@ func TestRateBreakerFuzzy(t *testing.T) {
 		Timeout:          3 * time.Millisecond,
 	}
 
-	b := newBreaker(s)
+	b := newBreaker(s, nil)
 
 	stop := make(chan struct{})
 
@@ -192,14 +192,73 @@ func TestSettingsString(t *testing.T) {
 		Window:           300,
 		Timeout:          time.Minute,
 		HalfOpenRequests: 15,
+		HalfOpenProbes:   5,
 		IdleTTL:          time.Hour,
+		PerEndpoint:      true,
 	}
 
 	ss := s.String()
-	expect := "type=rate,host=www.example.org,window=300,failures=30,timeout=1m0s,half-open-requests=15,idle-ttl=1h0m0s"
+	expect := "type=rate,host=www.example.org,window=300,failures=30,timeout=1m0s,half-open-requests=15,half-open-probes=5,idle-ttl=1h0m0s,per-endpoint=true"
 	if ss != expect {
 		t.Error("invalid breaker settings string")
 		t.Logf("got     : %s", ss)
 		t.Logf("expected: %s", expect)
 	}
 }
+
+func TestHalfOpenProbes(t *testing.T) {
+	s := BreakerSettings{
+		Type:             ConsecutiveFailures,
+		Failures:         1,
+		HalfOpenRequests: 3,
+		HalfOpenProbes:   1,
+		Timeout:          15 * time.Millisecond,
+	}
+
+	b := newBreaker(s, nil)
+	failOnce(t, b)
+	checkOpen(t, b)
+	if b.State() != StateOpen {
+		t.Fatalf("expected open state, got %v", b.State())
+	}
+
+	time.Sleep(s.Timeout)
+	if b.State() != StateHalfOpen {
+		t.Fatalf("expected half-open state, got %v", b.State())
+	}
+
+	done, ok := b.Allow()
+	if !ok {
+		t.Fatal("failed to allow the first probe")
+	}
+
+	if _, ok := b.Allow(); ok {
+		t.Error("unexpectedly allowed a concurrent probe")
+	}
+
+	done(true)
+	times(s.HalfOpenRequests-1, succeed(t, b))
+	if b.State() != StateClosed {
+		t.Errorf("expected closed state, got %v", b.State())
+	}
+}
+
+func TestStateChange(t *testing.T) {
+	s := BreakerSettings{
+		Type:     FailureRate,
+		Window:   2,
+		Failures: 1,
+		Timeout:  time.Minute,
+	}
+
+	var changes []State
+	b := newBreaker(s, func(_ BreakerSettings, _, to State) {
+		changes = append(changes, to)
+	})
+
+	failOnce(t, b)
+	checkOpen(t, b)
+	if len(changes) != 1 || changes[0] != StateOpen {
+		t.Errorf("expected a single change to open, got %v", changes)
+	}
+}
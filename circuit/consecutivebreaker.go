@@ -10,7 +10,7 @@ type consecutiveBreaker struct {
 	gb       *gobreaker.TwoStepCircuitBreaker
 }
 
-func newConsecutive(s BreakerSettings) *consecutiveBreaker {
+func newConsecutive(s BreakerSettings, onStateChange func(BreakerSettings, State, State)) *consecutiveBreaker {
 	b := &consecutiveBreaker{
 		settings: s,
 	}
@@ -22,6 +22,9 @@ func newConsecutive(s BreakerSettings) *consecutiveBreaker {
 		ReadyToTrip: b.readyToTrip,
 		OnStateChange: func(name string, from gobreaker.State, to gobreaker.State) {
 			log.Infof("circuit breaker %v went from %v to %v", name, from.String(), to.String())
+			if onStateChange != nil {
+				onStateChange(s, fromGobreakerState(from), fromGobreakerState(to))
+			}
 		},
 	})
 
@@ -43,3 +46,7 @@ func (b *consecutiveBreaker) Allow() (func(bool), bool) {
 	}
 	return done, true
 }
+
+func (b *consecutiveBreaker) State() State {
+	return fromGobreakerState(b.gb.State())
+}
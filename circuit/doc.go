@@ -103,6 +103,22 @@ Defines the number of requests expected to succeed while the circuit breaker is
 
 Command line name: half-open-requests. Possible command line values: any positive integer.
 
+# Settings - Half-Open Probes
+
+Defines the maximum number of concurrent requests accepted while the circuit breaker is in the half-open state.
+The requests exceeding it are rejected as if the breaker was open. When not set, all the half-open requests are
+accepted concurrently.
+
+Command line name: half-open-probes. Possible command line values: any positive integer.
+
+# Settings - Per Endpoint
+
+When set, the breakers of routes with load balanced backends are scoped to the individual endpoints instead of
+the whole backend, so that a single failing endpoint doesn't open the breaker for all of them. The endpoints
+with an open breaker are excluded from load balancing, unless all of the endpoints are open.
+
+Command line name: per-endpoint. Possible command line values: true, false.
+
 # Settings - Idle TTL
 
 Defines the idle timeout after which a circuit breaker gets recycled, if it hasn't been used.
@@ -139,6 +155,8 @@ breaker is open, the proxy doesn't try to make backend requests, and returns a r
 
 	X-Circuit-Open: true
 
+With per endpoint breakers, the proxy checks the breaker of the endpoint selected by the load balancer.
+
 # Registry
 
 The active circuit breakers are stored in a registry. They are created on-demand, for the requested settings.
@@ -148,5 +166,16 @@ go open after an unreasonably low number of recent failures. The registry also m
 circuit breakers that are not requested anymore by the proxy. This happens in a passive way, whenever a new
 circuit breaker is created. The cleanup prevents storing circuit breakers for inaccessible backend hosts
 infinitely in those scenarios where the route configuration is continuously changing.
+
+# Monitoring
+
+When the registry is created with metrics, it updates the gauge circuit.state.<host> with 0 for closed, 1 for
+half-open and 2 for open, and increments the counter circuit.open.<host> when a breaker opens. For per endpoint
+breakers, the endpoint is used instead of the host.
+
+The registry serves the open and half-open breakers as JSON, and skipper exposes them on the support listener:
+
+	curl localhost:9911/circuit-breakers
+	curl localhost:9911/circuit-breakers?all=true
 */
 package circuit
@@ -20,7 +20,7 @@ type rateBreaker struct {
 	gb       *gobreaker.TwoStepCircuitBreaker
 }
 
-func newRate(s BreakerSettings) *rateBreaker {
+func newRate(s BreakerSettings, onStateChange func(BreakerSettings, State, State)) *rateBreaker {
 	b := &rateBreaker{
 		settings: s,
 	}
@@ -32,6 +32,9 @@ func newRate(s BreakerSettings) *rateBreaker {
 		ReadyToTrip: func(gobreaker.Counts) bool { return b.readyToTrip() },
 		OnStateChange: func(name string, from gobreaker.State, to gobreaker.State) {
 			log.Infof("circuit breaker %v went from %v to %v", name, from.String(), to.String())
+			if onStateChange != nil {
+				onStateChange(s, fromGobreakerState(from), fromGobreakerState(to))
+			}
 		},
 	})
 
@@ -76,3 +79,7 @@ func (b *rateBreaker) Allow() (func(bool), bool) {
 		done(success)
 	}, true
 }
+
+func (b *rateBreaker) State() State {
+	return fromGobreakerState(b.gb.State())
+}
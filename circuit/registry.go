@@ -1,24 +1,76 @@
 package circuit
 
 import (
+	"encoding/json"
+	"net/http"
+	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
+
+	"github.com/zalando/skipper/metrics"
 )
 
 const DefaultIdleTTL = time.Hour
 
+// defaultTimeout is the timeout of the breakers, when not set.
+const defaultTimeout = time.Minute
+
+const (
+	stateMetricsPrefix = "circuit.state."
+	openMetricsPrefix  = "circuit.open."
+)
+
+// Options to create a registry.
+type Options struct {
+
+	// Settings contain the global and host specific settings of the breakers.
+	Settings []BreakerSettings
+
+	// Metrics, when set, receives the state of the breakers as gauges,
+	// 0 for closed, 1 for half-open and 2 for open, and counts the
+	// transitions into the open state.
+	Metrics metrics.Metrics
+}
+
 // Registry objects hold the active circuit breakers, ensure synchronized access to them, apply default settings
 // and recycle the idle breakers.
 type Registry struct {
 	defaults     BreakerSettings
 	hostSettings map[string]BreakerSettings
+	metrics      metrics.Metrics
 	mu           sync.Mutex
 	lookup       map[BreakerSettings]*Breaker
+
+	// openEndpoints holds the endpoints with an open breaker until their
+	// timeout, and it is replaced on every change, so that load
+	// balancing can check it without locking.
+	openMu        sync.Mutex
+	openEndpoints atomic.Pointer[map[endpointKey]time.Time]
+}
+
+type endpointKey struct {
+	host, endpoint string
 }
 
 // NewRegistry initializes a registry with the provided default settings. Settings with an empty Host field are
 // considered as defaults. Settings with the same Host field are merged together.
 func NewRegistry(settings ...BreakerSettings) *Registry {
+	return NewRegistryWithOptions(Options{Settings: settings})
+}
+
+// NewRegistryWithOptions initializes a registry with the provided options.
+func NewRegistryWithOptions(o Options) *Registry {
+	defaults, hostSettings := parseSettings(o.Settings)
+	return &Registry{
+		defaults:     defaults,
+		hostSettings: hostSettings,
+		metrics:      o.Metrics,
+		lookup:       make(map[BreakerSettings]*Breaker),
+	}
+}
+
+func parseSettings(settings []BreakerSettings) (BreakerSettings, map[string]BreakerSettings) {
 	var (
 		defaults     BreakerSettings
 		hostSettings []BreakerSettings
@@ -46,14 +98,93 @@ func NewRegistry(settings ...BreakerSettings) *Registry {
 		}
 	}
 
-	return &Registry{
-		defaults:     defaults,
-		hostSettings: hs,
-		lookup:       make(map[BreakerSettings]*Breaker),
+	return defaults, hs
+}
+
+// SetSettings replaces the default and host specific settings of the
+// registry, the same way as they are passed to NewRegistry. The
+// breakers created with the previous settings are not used for the
+// new requests, and they are recycled when they become idle.
+func (r *Registry) SetSettings(settings ...BreakerSettings) {
+	defaults, hostSettings := parseSettings(settings)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.defaults = defaults
+	r.hostSettings = hostSettings
+}
+
+func metricsKey(s BreakerSettings) string {
+	if s.Endpoint != "" {
+		return s.Endpoint
+	}
+	return s.Host
+}
+
+func (r *Registry) onStateChange(s BreakerSettings, _, to State) {
+	if s.Endpoint != "" {
+		r.setEndpointState(s, to)
+	}
+
+	if r.metrics == nil {
+		return
+	}
+
+	key := metricsKey(s)
+	r.metrics.UpdateGauge(stateMetricsPrefix+key, float64(to))
+	if to == StateOpen {
+		r.metrics.IncCounter(openMetricsPrefix + key)
 	}
 }
 
+func (r *Registry) setEndpointState(s BreakerSettings, to State) {
+	r.openMu.Lock()
+	defer r.openMu.Unlock()
+
+	now := time.Now()
+	open := make(map[endpointKey]time.Time)
+	if current := r.openEndpoints.Load(); current != nil {
+		for key, until := range *current {
+			if until.After(now) {
+				open[key] = until
+			}
+		}
+	}
+
+	key := endpointKey{host: s.Host, endpoint: s.Endpoint}
+	if to == StateOpen {
+		timeout := s.Timeout
+		if timeout <= 0 {
+			timeout = defaultTimeout
+		}
+
+		open[key] = now.Add(timeout)
+	} else {
+		delete(open, key)
+	}
+
+	r.openEndpoints.Store(&open)
+}
+
+// EndpointOpen tells whether the breaker of an endpoint of a load
+// balanced backend was opened, and its timeout has not expired yet. It
+// doesn't look up the breaker, and it is meant to exclude the open
+// endpoints from load balancing. After the timeout, the endpoint is
+// included again, and its breaker decides about the half-open requests.
+func (r *Registry) EndpointOpen(host, endpoint string) bool {
+	open := r.openEndpoints.Load()
+	if open == nil {
+		return false
+	}
+
+	until, ok := (*open)[endpointKey{host: host, endpoint: endpoint}]
+	return ok && time.Now().Before(until)
+}
+
 func (r *Registry) mergeDefaults(s BreakerSettings) BreakerSettings {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
 	defaults, ok := r.hostSettings[s.Host]
 	if !ok {
 		defaults = r.defaults
@@ -82,7 +213,7 @@ func (r *Registry) get(s BreakerSettings) *Breaker {
 		r.dropIdle(now)
 
 		// create a new one
-		b = newBreaker(s)
+		b = newBreaker(s, r.onStateChange)
 		r.lookup[s] = b
 	}
 
@@ -101,7 +232,7 @@ func (r *Registry) get(s BreakerSettings) *Breaker {
 // or a new one will be created if not.
 func (r *Registry) Get(s BreakerSettings) *Breaker {
 	// we check for host, because we don't want to use shared global breakers
-	if s.Type == BreakerDisabled || s.Host == "" {
+	if s.Type == BreakerDisabled || s.Host == "" && s.Endpoint == "" {
 		return nil
 	}
 
@@ -112,3 +243,75 @@ func (r *Registry) Get(s BreakerSettings) *Breaker {
 
 	return r.get(s)
 }
+
+// PerEndpoint tells whether the breakers for the provided settings are
+// scoped per endpoint of load balanced backends. In this case, the
+// breakers need to be requested with the Endpoint field set.
+func (r *Registry) PerEndpoint(s BreakerSettings) bool {
+	if s.Type == BreakerDisabled {
+		return false
+	}
+
+	s = r.mergeDefaults(s)
+	return s.Type != BreakerNone && s.Type != BreakerDisabled && s.PerEndpoint
+}
+
+type breakerInfo struct {
+	Host       string    `json:"host,omitempty"`
+	Endpoint   string    `json:"endpoint,omitempty"`
+	Settings   string    `json:"settings"`
+	State      string    `json:"state"`
+	LastAccess time.Time `json:"lastAccess"`
+}
+
+func (r *Registry) breakerInfos(all bool) []breakerInfo {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	infos := make([]breakerInfo, 0, len(r.lookup))
+	for s, b := range r.lookup {
+		state := b.State()
+		if state == StateClosed && !all {
+			continue
+		}
+
+		infos = append(infos, breakerInfo{
+			Host:       s.Host,
+			Endpoint:   s.Endpoint,
+			Settings:   s.String(),
+			State:      state.String(),
+			LastAccess: b.ts,
+		})
+	}
+
+	sort.Slice(infos, func(i, j int) bool {
+		if infos[i].Host != infos[j].Host {
+			return infos[i].Host < infos[j].Host
+		}
+		if infos[i].Endpoint != infos[j].Endpoint {
+			return infos[i].Endpoint < infos[j].Endpoint
+		}
+		return infos[i].Settings < infos[j].Settings
+	})
+
+	return infos
+}
+
+// ServeHTTP returns the open and half-open circuit breakers as JSON.
+// With the query parameter all=true, the closed breakers are returned,
+// too.
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != "GET" && req.Method != "HEAD" {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	infos := r.breakerInfos(req.URL.Query().Get("all") == "true")
+
+	w.Header().Set("Content-Type", "application/json")
+	if req.Method == "HEAD" {
+		return
+	}
+
+	json.NewEncoder(w).Encode(infos)
+}
@@ -1,8 +1,14 @@
 package circuit
 
 import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
 	"testing"
 	"time"
+
+	"github.com/zalando/skipper/metrics/metricstest"
 )
 
 // no checks, used for race detector
@@ -318,3 +324,161 @@ func TestIndividualIdle(t *testing.T) {
 		shouldBeClosed(t, "foo")
 	})
 }
+
+func TestRegistryPerEndpoint(t *testing.T) {
+	m := &metricstest.MockMetrics{}
+	defer m.Close()
+
+	r := NewRegistryWithOptions(Options{
+		Settings: []BreakerSettings{{
+			Type:        ConsecutiveFailures,
+			Failures:    1,
+			Timeout:     time.Minute,
+			PerEndpoint: true,
+		}},
+		Metrics: m,
+	})
+
+	if !r.PerEndpoint(BreakerSettings{}) {
+		t.Error("expected per endpoint breakers by default")
+	}
+
+	if r.PerEndpoint(BreakerSettings{Type: BreakerDisabled}) {
+		t.Error("expected no per endpoint breakers for disabled breakers")
+	}
+
+	if r.Get(BreakerSettings{}) != nil {
+		t.Error("unexpected breaker without host and endpoint")
+	}
+
+	b1 := r.Get(BreakerSettings{Endpoint: "10.0.0.1:80"})
+	b2 := r.Get(BreakerSettings{Endpoint: "10.0.0.2:80"})
+	if b1 == nil || b2 == nil || b1 == b2 {
+		t.Fatal("expected separate breakers per endpoint")
+	}
+
+	done, ok := b1.Allow()
+	if !ok {
+		t.Fatal("breaker unexpectedly open")
+	}
+	done(false)
+
+	if b1.State() != StateOpen || b2.State() != StateClosed {
+		t.Errorf("unexpected states: %v, %v", b1.State(), b2.State())
+	}
+
+	if !r.EndpointOpen("", "10.0.0.1:80") || r.EndpointOpen("", "10.0.0.2:80") {
+		t.Error("expected only the failing endpoint to be open")
+	}
+
+	if v, ok := m.Gauge("circuit.state.10.0.0.1:80"); !ok || v != float64(StateOpen) {
+		t.Errorf("unexpected state gauge: %v, %v", v, ok)
+	}
+
+	m.WithCounters(func(counters map[string]int64) {
+		if counters["circuit.open.10.0.0.1:80"] != 1 {
+			t.Errorf("unexpected open counters: %v", counters)
+		}
+	})
+
+	for _, tt := range []struct {
+		query  string
+		expect []string
+	}{
+		{query: "", expect: []string{"10.0.0.1:80"}},
+		{query: "?all=true", expect: []string{"10.0.0.1:80", "10.0.0.2:80"}},
+	} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("GET", "/circuit-breakers"+tt.query, nil))
+
+		var infos []breakerInfo
+		if err := json.Unmarshal(w.Body.Bytes(), &infos); err != nil {
+			t.Fatal(err)
+		}
+
+		var endpoints []string
+		for _, i := range infos {
+			endpoints = append(endpoints, i.Endpoint)
+		}
+
+		if !slices.Equal(endpoints, tt.expect) {
+			t.Errorf("query %q: expected breakers %v, got %v", tt.query, tt.expect, endpoints)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("POST", "/circuit-breakers", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("unexpected status code: %d", w.Code)
+	}
+}
+
+func TestRegistryEndpointOpenTimeout(t *testing.T) {
+	const timeout = 30 * time.Millisecond
+	r := NewRegistry(BreakerSettings{
+		Type:        ConsecutiveFailures,
+		Failures:    1,
+		Timeout:     timeout,
+		PerEndpoint: true,
+	})
+
+	b := r.Get(BreakerSettings{Host: "example.org", Endpoint: "10.0.0.1:80"})
+	done, ok := b.Allow()
+	if !ok {
+		t.Fatal("breaker unexpectedly open")
+	}
+	done(false)
+
+	if !r.EndpointOpen("example.org", "10.0.0.1:80") {
+		t.Fatal("expected the endpoint to be open")
+	}
+
+	if r.EndpointOpen("other.example.org", "10.0.0.1:80") {
+		t.Error("unexpected open endpoint for another host")
+	}
+
+	time.Sleep(2 * timeout)
+	if r.EndpointOpen("example.org", "10.0.0.1:80") {
+		t.Error("expected the endpoint to be included after the timeout")
+	}
+
+	// the breaker goes half-open and closes after a successful request
+	done, ok = b.Allow()
+	if !ok {
+		t.Fatal("expected the breaker to allow a half-open request")
+	}
+	done(true)
+
+	if b.State() != StateClosed || r.EndpointOpen("example.org", "10.0.0.1:80") {
+		t.Errorf("expected a closed endpoint, got: %v", b.State())
+	}
+}
+
+func TestRegistrySetSettings(t *testing.T) {
+	r := NewRegistry(BreakerSettings{Type: ConsecutiveFailures, Failures: 5})
+
+	b := r.Get(BreakerSettings{Host: "foo"})
+	if b == nil || b.settings.Failures != 5 {
+		t.Fatal("failed to receive a breaker with the initial settings")
+	}
+
+	r.SetSettings(
+		BreakerSettings{Type: ConsecutiveFailures, Failures: 3},
+		BreakerSettings{Host: "bar", Type: ConsecutiveFailures, Failures: 7},
+	)
+
+	b = r.Get(BreakerSettings{Host: "foo"})
+	if b == nil || b.settings.Failures != 3 || b.settings.IdleTTL != DefaultIdleTTL {
+		t.Errorf("failed to receive a breaker with the new settings: %v", b)
+	}
+
+	b = r.Get(BreakerSettings{Host: "bar"})
+	if b == nil || b.settings.Failures != 7 {
+		t.Errorf("failed to receive a breaker with the host settings: %v", b)
+	}
+
+	r.SetSettings()
+	if r.Get(BreakerSettings{Host: "foo"}) != nil {
+		t.Error("unexpected breaker without settings")
+	}
+}
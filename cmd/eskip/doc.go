@@ -54,6 +54,10 @@ Copy all routes in etcd under a different prefix:
 
 	eskip print | eskip upsert -etcd-prefix /skipper-backup
 
+Explain which route a running skipper selects for a request:
+
+	eskip explain -address http://127.0.0.1:9911 -method POST https://www.example.org/items/42
+
 (Where -etcd-urls is not set for write operations like upsert, reset and
 delete, the default etcd cluster urls are used:
 http://127.0.0.1:2379,http://127.0.0.1:4001)
@@ -85,10 +89,15 @@ const (
 	prettyUsage         = "prints routes in a more readable format"
 	indentStrUsage      = "indent string used in pretty printing. Must match regexp \\s"
 	jsonUsage           = "prints routes as JSON"
+	addressUsage        = "address of the support listener of skipper"
+	methodUsage         = "method of the explained request"
+	headerUsage         = "header of the explained request as 'Name: value', can be repeated"
+	bodyUsage           = "body of the explained request, used by the body predicates"
+	clientIPUsage       = "client IP of the explained request"
 
 	// command line help (1):
 	help1 = `Usage: eskip <command> [media flags] [--] [file]
-Commands: check|print|upsert|reset|delete|patch
+Commands: check|print|upsert|reset|delete|patch|explain
 Verify, print, update or delete Skipper routes.
 See more: https://github.com/zalando/skipper
 
@@ -147,6 +156,14 @@ patch    takes a list of routes as input from any media except of inline
 		 route. Example:
 		 eskip patch -append 'filter1() -> filter2()'
 
+explain  asks a running skipper instance on its support listener, which
+         route it selects for a request, and which condition rejected
+         the other candidate routes. It accepts the flags -address,
+         -method, -header, -body, -client-ip and -json, and the request
+         url.
+         Example:
+         eskip explain -header 'X-Tenant: foo' https://www.example.org/items/42
+
 version  print eskip version
 `
 )
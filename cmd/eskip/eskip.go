@@ -27,13 +27,14 @@ type (
 )
 
 const (
-	check  command = "check"
-	print  command = "print"
-	upsert command = "upsert"
-	reset  command = "reset"
-	delete command = "delete"
-	patch  command = "patch"
-	ver    command = "version"
+	check   command = "check"
+	print   command = "print"
+	upsert  command = "upsert"
+	reset   command = "reset"
+	delete  command = "delete"
+	patch   command = "patch"
+	explain command = "explain"
+	ver     command = "version"
 )
 
 var (
@@ -43,13 +44,14 @@ var (
 
 // map command string to command function
 var commands = map[command]commandFunc{
-	check:  checkCmd,
-	print:  printCmd,
-	upsert: upsertCmd,
-	reset:  resetCmd,
-	delete: deleteCmd,
-	patch:  patchCmd,
-	ver:    versionCmd}
+	check:   checkCmd,
+	print:   printCmd,
+	upsert:  upsertCmd,
+	reset:   resetCmd,
+	delete:  deleteCmd,
+	patch:   patchCmd,
+	explain: explainCmd,
+	ver:     versionCmd}
 
 var (
 	errMissingCommand = errors.New("missing command")
@@ -131,6 +133,11 @@ func main() {
 		exit(nil)
 	}
 
+	// explain doesn't use media, and has its own flags
+	if cmd == explain {
+		exitHint(commands[cmd](cmdArgs{}))
+	}
+
 	// process arguments, not checking if they make any sense:
 	media, err := processArgs()
 	if err != nil {
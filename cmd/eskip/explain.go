@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/zalando/skipper/eskip"
+	"github.com/zalando/skipper/routing"
+)
+
+const (
+	defaultSupportListener = "http://127.0.0.1:9911"
+	explainPath            = "/routes/explain"
+)
+
+var errMissingRequestURL = errors.New("missing request url")
+
+type headerFlags http.Header
+
+func (h headerFlags) String() string {
+	var s []string
+	for k, vs := range h {
+		for _, v := range vs {
+			s = append(s, k+": "+v)
+		}
+	}
+
+	return strings.Join(s, ", ")
+}
+
+func (h headerFlags) Set(value string) error {
+	k, v, found := strings.Cut(value, ":")
+	if !found {
+		return fmt.Errorf("invalid header: %q", value)
+	}
+
+	http.Header(h).Add(strings.TrimSpace(k), strings.TrimSpace(v))
+	return nil
+}
+
+func explainCmd(cmdArgs) error {
+	return explainRequest(os.Args[2:], stdout)
+}
+
+// explainRequest posts the request described by the args to the
+// explain endpoint of the support listener of a skipper instance, and
+// prints the selected and the candidate routes.
+func explainRequest(args []string, out io.Writer) error {
+	var (
+		address string
+		printJS bool
+		er      routing.ExplainRequest
+		header  = make(headerFlags)
+	)
+
+	fs := flag.NewFlagSet("explain", flag.ContinueOnError)
+	fs.SetOutput(nowrite)
+	fs.StringVar(&address, "address", defaultSupportListener, addressUsage)
+	fs.StringVar(&er.Method, "method", "GET", methodUsage)
+	fs.Var(header, "header", headerUsage)
+	fs.StringVar(&er.Body, "body", "", bodyUsage)
+	fs.StringVar(&er.ClientIP, "client-ip", "", clientIPUsage)
+	fs.BoolVar(&printJS, jsonFlag, false, jsonUsage)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 {
+		return errMissingRequestURL
+	}
+
+	er.URL = fs.Arg(0)
+	er.Header = http.Header(header)
+
+	body, err := json.Marshal(er)
+	if err != nil {
+		return err
+	}
+
+	rsp, err := http.Post(strings.TrimSuffix(address, "/")+explainPath, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer rsp.Body.Close()
+
+	if rsp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(rsp.Body)
+		return fmt.Errorf("failed to explain request: %s %s", rsp.Status, strings.TrimSpace(string(msg)))
+	}
+
+	if printJS {
+		_, err := io.Copy(out, rsp.Body)
+		return err
+	}
+
+	var e routing.Explanation
+	if err := json.NewDecoder(rsp.Body).Decode(&e); err != nil {
+		return err
+	}
+
+	printExplanation(out, &e)
+	return nil
+}
+
+// printExplanation prints the explanation, and during a staged rollout,
+// also the explanation by the canary table, labeled by their table.
+func printExplanation(out io.Writer, e *routing.Explanation) {
+	if e.Canary == nil {
+		printTableExplanation(out, e)
+		return
+	}
+
+	fmt.Fprintln(out, "table:", e.Table)
+	printTableExplanation(out, e)
+	fmt.Fprintln(out)
+	fmt.Fprintln(out, "table:", e.Canary.Table)
+	printTableExplanation(out, e.Canary)
+}
+
+func printTableExplanation(out io.Writer, e *routing.Explanation) {
+	if e.RouteId == "" {
+		fmt.Fprintln(out, "route: none")
+	} else {
+		fmt.Fprintln(out, "route:", e.RouteId)
+	}
+
+	if len(e.Params) > 0 {
+		names := make([]string, 0, len(e.Params))
+		for name := range e.Params {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		params := make([]string, len(names))
+		for i, name := range names {
+			params[i] = name + "=" + e.Params[name]
+		}
+
+		fmt.Fprintln(out, "params:", strings.Join(params, ", "))
+	}
+
+	fmt.Fprintln(out, "candidates:")
+	for _, c := range e.Candidates {
+		switch {
+		case c.Matched:
+			fmt.Fprintf(out, "  %s: matched\n", c.RouteId)
+		case c.Reason != "":
+			fmt.Fprintf(out, "  %s: rejected by %s: %s\n", c.RouteId, c.RejectedBy, c.Reason)
+		default:
+			fmt.Fprintf(out, "  %s: rejected by %s\n", c.RouteId, c.RejectedBy)
+		}
+	}
+
+	if e.Route != nil {
+		fmt.Fprintln(out)
+		eskip.Fprint(out, eskip.PrettyPrintInfo{Pretty: true, IndentStr: "  "}, e.Route)
+		fmt.Fprintln(out)
+	}
+}
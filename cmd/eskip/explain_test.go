@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/zalando/skipper/eskip"
+	"github.com/zalando/skipper/routing"
+)
+
+func TestExplain(t *testing.T) {
+	var received routing.ExplainRequest
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/routes/explain" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		if err := json.NewDecoder(r.Body).Decode(&received); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		json.NewEncoder(w).Encode(routing.Explanation{
+			RouteId: "items",
+			Route:   &eskip.Route{Id: "items", Path: "/items/:id", Backend: "https://items.example.org"},
+			Params:  map[string]string{"id": "42"},
+			Candidates: []routing.ExplainCandidate{
+				{RouteId: "tenant", RejectedBy: routing.RejectedByHeader, Reason: "X-Tenant: bar"},
+				{RouteId: "items", Matched: true},
+			},
+		})
+	}))
+	defer s.Close()
+
+	var out bytes.Buffer
+	err := explainRequest([]string{
+		"-address", s.URL,
+		"-method", "POST",
+		"-header", "X-Tenant: foo",
+		"-body", `{"name": "foo"}`,
+		"-client-ip", "10.0.0.1",
+		"https://www.example.org/items/42",
+	}, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if received.Method != "POST" ||
+		received.URL != "https://www.example.org/items/42" ||
+		received.Header.Get("X-Tenant") != "foo" ||
+		received.Body != `{"name": "foo"}` ||
+		received.ClientIP != "10.0.0.1" {
+		t.Errorf("unexpected explain request: %+v", received)
+	}
+
+	expect := `route: items
+params: id=42
+candidates:
+  tenant: rejected by header: X-Tenant: bar
+  items: matched
+
+items: Path("/items/:id")
+  -> "https://items.example.org";
+`
+	if out.String() != expect {
+		t.Errorf("unexpected output, got:\n%q\nexpected:\n%q", out.String(), expect)
+	}
+}
+
+func TestExplainCanary(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(routing.Explanation{
+			Table:      routing.ExplainStableTable,
+			Candidates: []routing.ExplainCandidate{{RouteId: "items", RejectedBy: routing.RejectedByPath, Reason: `Path("/items")`}},
+			Canary: &routing.Explanation{
+				Table:      routing.ExplainCanaryTable,
+				RouteId:    "items",
+				Candidates: []routing.ExplainCandidate{{RouteId: "items", Matched: true}},
+			},
+		})
+	}))
+	defer s.Close()
+
+	var out bytes.Buffer
+	if err := explainRequest([]string{"-address", s.URL, "https://www.example.org/items/42"}, &out); err != nil {
+		t.Fatal(err)
+	}
+
+	expect := `table: stable
+route: none
+candidates:
+  items: rejected by path: Path("/items")
+
+table: canary
+route: items
+candidates:
+  items: matched
+`
+	if out.String() != expect {
+		t.Errorf("unexpected output, got:\n%q\nexpected:\n%q", out.String(), expect)
+	}
+}
+
+func TestExplainErrors(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "invalid client IP", http.StatusBadRequest)
+	}))
+	defer s.Close()
+
+	for _, args := range [][]string{
+		{"-address", s.URL},
+		{"-address", s.URL, "-header", "invalid", "https://www.example.org"},
+		{"-address", s.URL, "https://www.example.org"},
+	} {
+		if err := explainRequest(args, &bytes.Buffer{}); err == nil {
+			t.Errorf("expected error for args: %v", args)
+		}
+	}
+}
@@ -61,7 +61,20 @@ func main() {
 	}
 
 	log.SetLevel(cfg.ApplicationLogLevel)
-	if err := skipper.Run(cfg.ToOptions()); err != nil {
+
+	reloader := config.NewReloader(cfg)
+	defer reloader.Close()
+
+	o := cfg.ToOptions()
+	if cfg.EnableConfigEndpoint {
+		o.ConfigHandler = reloader
+	}
+
+	if cfg.EnableConfigReload {
+		o.RuntimeSettings = reloader.Settings()
+	}
+
+	if err := skipper.Run(o); err != nil {
 		log.Fatal(err)
 	}
 }
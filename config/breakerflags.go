@@ -17,6 +17,8 @@ const breakerUsage = `set global or host specific circuit breakers, e.g. -breake
 	window: the size of the sliding window for the rate breaker
 	timeout: duration string or milliseconds while the breaker stays open
 	half-open-requests: the number of requests in half-open state to succeed before getting closed again
+	half-open-probes: the maximum number of concurrent requests in half-open state (unlimited when not set)
+	per-endpoint: true to scope the breakers to the endpoints of load balanced backends and exclude open endpoints
 	idle-ttl: duration string or milliseconds after the breaker is considered idle and reset
 	(see also: https://godoc.org/github.com/zalando/skipper/circuit)`
 
@@ -87,6 +89,20 @@ func (b *breakerFlags) Set(value string) error {
 			}
 
 			s.HalfOpenRequests = i
+		case "half-open-probes":
+			i, err := strconv.Atoi(v)
+			if err != nil {
+				return err
+			}
+
+			s.HalfOpenProbes = i
+		case "per-endpoint":
+			pe, err := strconv.ParseBool(v)
+			if err != nil {
+				return err
+			}
+
+			s.PerEndpoint = pe
 		case "idle-ttl":
 			d, err := time.ParseDuration(v)
 			if err != nil {
@@ -87,6 +87,25 @@ func Test_breakerFlags_Set(t *testing.T) {
 				Failures:         2,
 			},
 		},
+		{
+			name:    "test breaker settings per endpoint with half-open probes",
+			args:    "type=consecutive,failures=5,timeout=3s,half-open-requests=3,half-open-probes=1,per-endpoint=true",
+			wantErr: false,
+			want: circuit.BreakerSettings{
+				Type:             circuit.ConsecutiveFailures,
+				Timeout:          3 * time.Second,
+				HalfOpenRequests: 3,
+				HalfOpenProbes:   1,
+				Failures:         5,
+				PerEndpoint:      true,
+			},
+		},
+		{
+			name:      "test breaker settings invalid per endpoint",
+			args:      "type=consecutive,per-endpoint=yes",
+			wantErr:   true,
+			errString: `strconv.ParseBool: parsing "yes": invalid syntax`,
+		},
 		{
 			name:      "test breaker settings with wrong window",
 			args:      "type=consecutive,window=4s,host=example.com,timeout=3s,half-open-requests=3,idle-ttl=5s",
@@ -21,17 +21,27 @@ import (
 	"github.com/zalando/skipper/eskip"
 	"github.com/zalando/skipper/filters/openpolicyagent"
 	"github.com/zalando/skipper/net"
+	"github.com/zalando/skipper/predicates/content"
 	"github.com/zalando/skipper/proxy"
 	"github.com/zalando/skipper/swarm"
 )
 
 type Config struct {
-	ConfigFile string
-	Flags      *flag.FlagSet
+	ConfigFile           string
+	Flags                *flag.FlagSet
+	EnableConfigReload   bool          `yaml:"enable-config-reload"`
+	ConfigReloadInterval time.Duration `yaml:"config-reload-interval"`
+	EnableConfigEndpoint bool          `yaml:"enable-config-endpoint"`
+
+	// command line, used to parse the config again on reload:
+	progname string
+	args     []string
 
 	// generic:
 	Address                         string         `yaml:"address"`
 	InsecureAddress                 string         `yaml:"insecure-address"`
+	HTTP3Address                    string         `yaml:"http3-address"`
+	ProxyProtocolTrustedNetworks    *listFlag      `yaml:"proxy-protocol-trusted-networks"`
 	EnableTCPQueue                  bool           `yaml:"enable-tcp-queue"`
 	ExpectedBytesPerRequest         int            `yaml:"expected-bytes-per-request"`
 	MaxTCPListenerConcurrency       int            `yaml:"max-tcp-listener-concurrency"`
@@ -61,6 +71,8 @@ type Config struct {
 	Ratelimits                      ratelimitFlags `yaml:"ratelimits"`
 	EnableRouteFIFOMetrics          bool           `yaml:"enable-route-fifo-metrics"`
 	EnableRouteLIFOMetrics          bool           `yaml:"enable-route-lifo-metrics"`
+	SchedulerFeedbackHeader         string         `yaml:"scheduler-feedback-header"`
+	EnableSchedulerLiveConfig       bool           `yaml:"enable-scheduler-live-config"`
 	MetricsFlavour                  *listFlag      `yaml:"metrics-flavour"`
 	FilterPlugins                   *pluginFlag    `yaml:"filter-plugin"`
 	PredicatePlugins                *pluginFlag    `yaml:"predicate-plugin"`
@@ -78,6 +90,7 @@ type Config struct {
 	OpentracingLogFilterLifecycleEvents bool      `yaml:"opentracing-log-filter-lifecycle-events"`
 	OpentracingLogStreamEvents          bool      `yaml:"opentracing-log-stream-events"`
 	OpentracingBackendNameTag           bool      `yaml:"opentracing-backend-name-tag"`
+	OpenTracingSamplingRate             float64   `yaml:"opentracing-sampling-rate"`
 	MetricsListener                     string    `yaml:"metrics-listener"`
 	MetricsPrefix                       string    `yaml:"metrics-prefix"`
 	EnableProfile                       bool      `yaml:"enable-profile"`
@@ -116,6 +129,13 @@ type Config struct {
 	AccessLogJSONEnabled                bool      `yaml:"access-log-json-enabled"`
 	AccessLogStripQuery                 bool      `yaml:"access-log-strip-query"`
 	SuppressRouteUpdateLogs             bool      `yaml:"suppress-route-update-logs"`
+	RouteHistorySize                    int       `yaml:"route-history-size"`
+
+	// staged rollout of the route table generations:
+	RouteRolloutFraction       float64       `yaml:"route-rollout-fraction"`
+	RouteRolloutDuration       time.Duration `yaml:"route-rollout-duration"`
+	RouteRolloutMinRequests    int64         `yaml:"route-rollout-min-requests"`
+	RouteRolloutErrorThreshold float64       `yaml:"route-rollout-error-threshold"`
 
 	// route sources:
 	EtcdUrls           string               `yaml:"etcd-urls"`
@@ -235,9 +255,23 @@ type Config struct {
 	// Exclude insecure cipher suites
 	ExcludeInsecureCipherSuites bool `yaml:"exclude-insecure-cipher-suites"`
 
+	// Per-host TLS policies and OCSP stapling
+	TLSPoliciesFile    string `yaml:"tls-policies-file"`
+	EnableOCSPStapling bool   `yaml:"enable-ocsp-stapling"`
+
 	// TLS Config
 	KubernetesEnableTLS bool `yaml:"kubernetes-enable-tls"`
 
+	// ACME
+	ACMEDirectoryURL               string        `yaml:"acme-directory-url"`
+	ACMEEmail                      string        `yaml:"acme-email"`
+	ACMEChallenges                 *listFlag     `yaml:"acme-challenges"`
+	ACMEDomains                    *listFlag     `yaml:"acme-domains"`
+	ACMEStorageDir                 string        `yaml:"acme-storage-dir"`
+	ACMEStorageKubernetesNamespace string        `yaml:"acme-storage-kubernetes-namespace"`
+	ACMERenewBefore                time.Duration `yaml:"acme-renew-before"`
+	ACMECAFile                     string        `yaml:"acme-ca-file"`
+
 	// API Monitoring
 	ApiUsageMonitoringEnable                       bool   `yaml:"enable-api-usage-monitoring"`
 	ApiUsageMonitoringRealmKeys                    string `yaml:"api-usage-monitoring-realm-keys"`
@@ -247,11 +281,19 @@ type Config struct {
 
 	// connections, timeouts:
 	WaitForHealthcheckInterval   time.Duration `yaml:"wait-for-healthcheck-interval"`
+	ShutdownDrainInterval        time.Duration `yaml:"shutdown-drain-interval"`
+	ShutdownTimeout              time.Duration `yaml:"shutdown-timeout"`
 	IdleConnsPerHost             int           `yaml:"idle-conns-num"`
 	CloseIdleConnsPeriod         time.Duration `yaml:"close-idle-conns-period"`
 	BackendFlushInterval         time.Duration `yaml:"backend-flush-interval"`
 	ExperimentalUpgrade          bool          `yaml:"experimental-upgrade"`
 	ExperimentalUpgradeAudit     bool          `yaml:"experimental-upgrade-audit"`
+	StreamingIdleTimeout         time.Duration `yaml:"streaming-idle-timeout"`
+	WebSocketFrameParser         bool          `yaml:"websocket-frame-parser"`
+	WebSocketMaxMessageSize      int64         `yaml:"websocket-max-message-size"`
+	WebSocketIdleTimeout         time.Duration `yaml:"websocket-idle-timeout"`
+	WebSocketPingInterval        time.Duration `yaml:"websocket-ping-interval"`
+	WebSocketPingTimeout         time.Duration `yaml:"websocket-ping-timeout"`
 	ReadTimeoutServer            time.Duration `yaml:"read-timeout-server"`
 	ReadHeaderTimeoutServer      time.Duration `yaml:"read-header-timeout-server"`
 	WriteTimeoutServer           time.Duration `yaml:"write-timeout-server"`
@@ -297,6 +339,16 @@ type Config struct {
 	LuaModules *listFlag `yaml:"lua-modules"`
 	LuaSources *listFlag `yaml:"lua-sources"`
 
+	GeoIPDatabases      *listFlag     `yaml:"geoip-databases"`
+	GeoIPClientIPSource string        `yaml:"geoip-client-ip-source"`
+	GeoIPReloadInterval time.Duration `yaml:"geoip-reload-interval"`
+
+	IPLists               *listFlag     `yaml:"ip-lists"`
+	IPListsReloadInterval time.Duration `yaml:"ip-lists-reload-interval"`
+	IPListsClientIPSource string        `yaml:"ip-lists-client-ip-source"`
+
+	BodyPredicatesMaxSize int64 `yaml:"body-predicates-max-size"`
+
 	EnableOpenPolicyAgent                              bool          `yaml:"enable-open-policy-agent"`
 	EnableOpenPolicyAgentCustomControlLoop             bool          `yaml:"enable-open-policy-agent-custom-control-loop"`
 	OpenPolicyAgentControlLoopInterval                 time.Duration `yaml:"open-policy-agent-control-loop-interval"`
@@ -343,14 +395,24 @@ func NewConfig() *Config {
 	cfg.CompressEncodings = commaListFlag("gzip", "deflate", "br")
 	cfg.LuaModules = commaListFlag()
 	cfg.LuaSources = commaListFlag()
+	cfg.GeoIPDatabases = commaListFlag()
+	cfg.IPLists = commaListFlag()
+	cfg.ProxyProtocolTrustedNetworks = commaListFlag()
+	cfg.ACMEChallenges = commaListFlag()
+	cfg.ACMEDomains = commaListFlag()
 	cfg.Oauth2GrantTokeninfoKeys = commaListFlag()
 
 	flag := flag.NewFlagSet("", flag.ExitOnError)
 	flag.StringVar(&cfg.ConfigFile, "config-file", "", "if provided the flags will be loaded/overwritten by the values on the file (yaml)")
+	flag.BoolVar(&cfg.EnableConfigReload, "enable-config-reload", false, "enables reloading the config file when it changes or on SIGHUP, and applies the settings that can change at runtime")
+	flag.DurationVar(&cfg.ConfigReloadInterval, "config-reload-interval", 10*time.Second, "interval of checking the config file for changes, when config reload is enabled")
+	flag.BoolVar(&cfg.EnableConfigEndpoint, "enable-config-endpoint", false, "enables the /config endpoint on the support listener, that shows the effective config, with the values of the settings that may contain credentials redacted")
 
 	// generic:
 	flag.StringVar(&cfg.Address, "address", ":9090", "network address that skipper should listen on")
 	flag.StringVar(&cfg.InsecureAddress, "insecure-address", "", "insecure network address that skipper should listen on when TLS is enabled")
+	flag.StringVar(&cfg.HTTP3Address, "http3-address", "", "UDP network address of the HTTP/3 (QUIC) listener, used only when TLS is enabled")
+	flag.Var(cfg.ProxyProtocolTrustedNetworks, "proxy-protocol-trusted-networks", "comma separated list of IPs and CIDRs of the load balancers sending the PROXY protocol header, enables the PROXY protocol on the main listener")
 	flag.BoolVar(&cfg.EnableTCPQueue, "enable-tcp-queue", false, "enable the TCP listener queue")
 	flag.IntVar(&cfg.ExpectedBytesPerRequest, "expected-bytes-per-request", 50*1024, "bytes per request, that is used to calculate concurrency limits to buffer connection spikes")
 	flag.IntVar(&cfg.MaxTCPListenerConcurrency, "max-tcp-listener-concurrency", 0, "sets hardcoded max for TCP listener concurrency, normally calculated based on available memory cgroups with max TODO")
@@ -380,6 +442,8 @@ func NewConfig() *Config {
 	flag.Var(&cfg.Ratelimits, "ratelimits", ratelimitsUsage)
 	flag.BoolVar(&cfg.EnableRouteFIFOMetrics, "enable-route-fifo-metrics", false, "enable metrics for the individual route FIFO queues")
 	flag.BoolVar(&cfg.EnableRouteLIFOMetrics, "enable-route-lifo-metrics", false, "enable metrics for the individual route LIFO queues")
+	flag.StringVar(&cfg.SchedulerFeedbackHeader, "scheduler-feedback-header", "", "enables backends to lower or raise the concurrency of the route FIFO and LIFO queues by responding with this header")
+	flag.BoolVar(&cfg.EnableSchedulerLiveConfig, "enable-scheduler-live-config", false, "enables changing the live settings of the route FIFO and LIFO queues via the /queues endpoint of the support listener")
 	flag.Var(cfg.MetricsFlavour, "metrics-flavour", "Metrics flavour is used to change the exposed metrics format. Supported metric formats: 'codahale' and 'prometheus', you can select both of them by using one option with ',' separated values")
 	flag.Var(cfg.FilterPlugins, "filter-plugin", "set a custom filter plugins to load, a comma separated list of name and arguments")
 	flag.Var(cfg.PredicatePlugins, "predicate-plugin", "set a custom predicate plugins to load, a comma separated list of name and arguments")
@@ -396,6 +460,7 @@ func NewConfig() *Config {
 	flag.BoolVar(&cfg.OpentracingLogFilterLifecycleEvents, "opentracing-log-filter-lifecycle-events", true, "enables the logs for request & response filters' lifecycle events that are marking start & end times.")
 	flag.BoolVar(&cfg.OpentracingLogStreamEvents, "opentracing-log-stream-events", true, "enables the logs for events marking the times response headers & payload are streamed to the client")
 	flag.BoolVar(&cfg.OpentracingBackendNameTag, "opentracing-backend-name-tag", false, "enables an additional tracing tag that contains a backend name for a route when it's available  (e.g. for RouteGroups) (default false)")
+	flag.Float64Var(&cfg.OpenTracingSamplingRate, "opentracing-sampling-rate", 1, "ratio of the traces started by skipper that are passed to the tracer, between 0 and 1, can be changed with the config reload")
 	flag.StringVar(&cfg.MetricsListener, "metrics-listener", ":9911", "network address used for exposing the /metrics endpoint. An empty value disables metrics iff support listener is also empty.")
 	flag.StringVar(&cfg.MetricsPrefix, "metrics-prefix", "skipper.", "allows setting a custom path prefix for metrics export")
 	flag.BoolVar(&cfg.EnableProfile, "enable-profile", false, "enable profile information on the metrics endpoint with path /pprof")
@@ -433,6 +498,11 @@ func NewConfig() *Config {
 	flag.BoolVar(&cfg.AccessLogJSONEnabled, "access-log-json-enabled", false, "when this flag is set, log in JSON format is used")
 	flag.BoolVar(&cfg.AccessLogStripQuery, "access-log-strip-query", false, "when this flag is set, the access log strips the query strings from the access log")
 	flag.BoolVar(&cfg.SuppressRouteUpdateLogs, "suppress-route-update-logs", false, "print only summaries on route updates/deletes")
+	flag.IntVar(&cfg.RouteHistorySize, "route-history-size", 16, "number of route table generations kept in memory and served on /routes/history and /routes/diff of the support listener, 0 disables the history")
+	flag.Float64Var(&cfg.RouteRolloutFraction, "route-rollout-fraction", 0, "fraction of the requests routed with a new route table generation during its staged rollout, 0 disables the staged rollout")
+	flag.DurationVar(&cfg.RouteRolloutDuration, "route-rollout-duration", time.Minute, "duration of the staged rollout of a new route table generation before it is promoted")
+	flag.Int64Var(&cfg.RouteRolloutMinRequests, "route-rollout-min-requests", 100, "minimum number of requests routed with a new route table generation before it can be rolled back")
+	flag.Float64Var(&cfg.RouteRolloutErrorThreshold, "route-rollout-error-threshold", 0.05, "maximum allowed increase of the 5xx rate of a new route table generation during the staged rollout, compared to the current generation")
 
 	// route sources:
 	flag.StringVar(&cfg.EtcdUrls, "etcd-urls", "", "urls of nodes in an etcd cluster, storing route definitions")
@@ -559,6 +629,10 @@ func NewConfig() *Config {
 	// Exclude insecure cipher suites
 	flag.BoolVar(&cfg.ExcludeInsecureCipherSuites, "exclude-insecure-cipher-suites", false, "excludes insecure cipher suites")
 
+	// Per-host TLS policies and OCSP stapling
+	flag.StringVar(&cfg.TLSPoliciesFile, "tls-policies-file", "", "YAML file with the per-host TLS policies of the server: minimum TLS version, cipher suites, ALPN protocols and client authentication")
+	flag.BoolVar(&cfg.EnableOCSPStapling, "enable-ocsp-stapling", false, "enables stapling the OCSP responses to the server certificates, refreshed in the background")
+
 	// API Monitoring:
 	flag.BoolVar(&cfg.ApiUsageMonitoringEnable, "enable-api-usage-monitoring", false, "enables the apiUsageMonitoring filter")
 	flag.StringVar(&cfg.ApiUsageMonitoringRealmKeys, "api-usage-monitoring-realm-keys", "", "name of the property in the JWT payload that contains the authority realm")
@@ -571,11 +645,19 @@ func NewConfig() *Config {
 
 	// Connections, timeouts:
 	flag.DurationVar(&cfg.WaitForHealthcheckInterval, "wait-for-healthcheck-interval", (10+5)*3*time.Second, "period waiting to become unhealthy in the loadbalancer pool in front of this instance, before shutdown triggered by SIGINT or SIGTERM") // kube-ingress-aws-controller default
+	flag.DurationVar(&cfg.ShutdownDrainInterval, "shutdown-drain-interval", 0, "period waiting for the Server-Sent Events streams and the upgraded connections to finish on shutdown, after the health check interval, before terminating them")
+	flag.DurationVar(&cfg.ShutdownTimeout, "shutdown-timeout", 0, "period after the health check interval, after which the connections still open on shutdown are closed forcefully. When 0, the shutdown waits for all the requests to finish")
 	flag.IntVar(&cfg.IdleConnsPerHost, "idle-conns-num", proxy.DefaultIdleConnsPerHost, "maximum idle connections per backend host")
 	flag.DurationVar(&cfg.CloseIdleConnsPeriod, "close-idle-conns-period", proxy.DefaultCloseIdleConnsPeriod, "sets the time interval of closing all idle connections. Not closing when 0")
 	flag.DurationVar(&cfg.BackendFlushInterval, "backend-flush-interval", 20*time.Millisecond, "flush interval for upgraded proxy connections")
 	flag.BoolVar(&cfg.ExperimentalUpgrade, "experimental-upgrade", false, "enable experimental feature to handle upgrade protocol requests")
 	flag.BoolVar(&cfg.ExperimentalUpgradeAudit, "experimental-upgrade-audit", false, "enable audit logging of the request line and the messages during the experimental web socket upgrades")
+	flag.DurationVar(&cfg.StreamingIdleTimeout, "streaming-idle-timeout", time.Minute, "idle timeout between the events of the responses served in streaming mode, the Server-Sent Events and the routes with the streaming() filter, disabled when zero")
+	flag.BoolVar(&cfg.WebSocketFrameParser, "websocket-frame-parser", false, "parse the frames of all the upgraded WebSocket connections to collect message metrics and enforce the WebSocket limits, requires -experimental-upgrade")
+	flag.Int64Var(&cfg.WebSocketMaxMessageSize, "websocket-max-message-size", 1<<20, "maximum size of the WebSocket messages of the parsed connections, larger messages close the connection")
+	flag.DurationVar(&cfg.WebSocketIdleTimeout, "websocket-idle-timeout", 0, "close the parsed WebSocket connections without messages for the given duration, disabled when zero")
+	flag.DurationVar(&cfg.WebSocketPingInterval, "websocket-ping-interval", 0, "send pings to the clients of the parsed WebSocket connections that didn't send any frame for the given duration, disabled when zero")
+	flag.DurationVar(&cfg.WebSocketPingTimeout, "websocket-ping-timeout", 0, "close the parsed WebSocket connections not answering the pings within the given duration, defaults to the ping interval")
 	flag.DurationVar(&cfg.ReadTimeoutServer, "read-timeout-server", 5*time.Minute, "set ReadTimeout for http server connections")
 	flag.DurationVar(&cfg.ReadHeaderTimeoutServer, "read-header-timeout-server", 60*time.Second, "set ReadHeaderTimeout for http server connections")
 	flag.DurationVar(&cfg.WriteTimeoutServer, "write-timeout-server", 60*time.Second, "set WriteTimeout for http server connections")
@@ -593,6 +675,14 @@ func NewConfig() *Config {
 	flag.IntVar(&cfg.MaxIdleConnsBackend, "max-idle-connection-backend", 0, "sets the maximum idle connections for all backend connections")
 	flag.BoolVar(&cfg.DisableHTTPKeepalives, "disable-http-keepalives", false, "forces backend to always create a new connection")
 	flag.BoolVar(&cfg.KubernetesEnableTLS, "kubernetes-enable-tls", false, "enable using kubnernetes resources to terminate tls")
+	flag.StringVar(&cfg.ACMEDirectoryURL, "acme-directory-url", "", "directory URL of the ACME server, e.g. https://acme-v02.api.letsencrypt.org/directory, enables obtaining TLS certificates for the hosts of the routes")
+	flag.StringVar(&cfg.ACMEEmail, "acme-email", "", "contact email of the ACME account")
+	flag.Var(cfg.ACMEChallenges, "acme-challenges", "comma separated list of the enabled ACME challenges in the order of preference, http-01 and tls-alpn-01, defaults to both")
+	flag.Var(cfg.ACMEDomains, "acme-domains", "comma separated list of domains, limits the ACME certificates to these domains and their subdomains")
+	flag.StringVar(&cfg.ACMEStorageDir, "acme-storage-dir", "", "directory storing the ACME account key, certificates and pending challenges")
+	flag.StringVar(&cfg.ACMEStorageKubernetesNamespace, "acme-storage-kubernetes-namespace", "", "namespace of the Kubernetes secrets storing the ACME account key, certificates and pending challenges, shared by the instances")
+	flag.DurationVar(&cfg.ACMERenewBefore, "acme-renew-before", 30*24*time.Hour, "time before the expiry when the ACME certificates are renewed")
+	flag.StringVar(&cfg.ACMECAFile, "acme-ca-file", "", "PEM file of the CA certificates trusted when accessing the ACME server, e.g. a local test server")
 
 	// Swarm:
 	flag.BoolVar(&cfg.EnableSwarm, "enable-swarm", false, "enable swarm communication between nodes in a skipper fleet")
@@ -619,6 +709,14 @@ func NewConfig() *Config {
 	flag.Var(cfg.LuaModules, "lua-modules", "comma separated list of lua filter modules. Use <module>.<symbol> to selectively enable module symbols, for example: package,base._G,base.print,json")
 	flag.Var(cfg.LuaSources, "lua-sources", `comma separated list of lua input types for the lua() filter. Valid sources "", "file", "inline", "file,inline" and "none". Use "file" to only allow lua file references in lua filter. Default "" is the same as "file","inline". Use "none" to disable lua filters.`)
 
+	flag.Var(cfg.GeoIPDatabases, "geoip-databases", "comma separated list of MaxMind DB files, e.g. GeoLite2-Country.mmdb and GeoLite2-ASN.mmdb, enables the GeoCountry and GeoASN predicates and the geoHeaders filter")
+	flag.StringVar(&cfg.GeoIPClientIPSource, "geoip-client-ip-source", "Source", `client IP used for the geoip lookups, with the same logic as the predicates of the same name: "Source", "SourceFromLast" or "ClientIP"`)
+	flag.DurationVar(&cfg.GeoIPReloadInterval, "geoip-reload-interval", time.Minute, "interval of checking the geoip databases for changes")
+	flag.Var(cfg.IPLists, "ip-lists", "comma separated list of named IP lists in the format of <name>=<file path or URL>, enables the ipDenyList and ipAllowList filters")
+	flag.DurationVar(&cfg.IPListsReloadInterval, "ip-lists-reload-interval", time.Minute, "interval of reloading the IP lists")
+	flag.StringVar(&cfg.IPListsClientIPSource, "ip-lists-client-ip-source", "Source", `client IP checked against the IP lists, with the same logic as the predicates of the same name: "Source", "SourceFromLast" or "ClientIP"`)
+	flag.Int64Var(&cfg.BodyPredicatesMaxSize, "body-predicates-max-size", content.DefaultMaxBodySize, "maximum size of the request bodies inspected by the JSONBodyField, JSONBodyFieldRegexp, FormValue and GraphQL predicates, and by the GraphQL filters")
+
 	// Passive Health Checks
 	flag.Var(&cfg.PassiveHealthCheck, "passive-health-check", "sets the parameters for passive health check feature")
 
@@ -676,7 +774,14 @@ func (c *Config) Parse() error {
 }
 
 func (c *Config) ParseArgs(progname string, args []string) error {
-	c.Flags.Init(progname, flag.ExitOnError)
+	return c.parseArgs(progname, args, flag.ExitOnError)
+}
+
+func (c *Config) parseArgs(progname string, args []string, errorHandling flag.ErrorHandling) error {
+	c.progname = progname
+	c.args = args
+
+	c.Flags.Init(progname, errorHandling)
 	err := c.Flags.Parse(args)
 	if err != nil {
 		return err
@@ -773,6 +878,7 @@ func (c *Config) ToOptions() skipper.Options {
 		// generic:
 		Address:                   c.Address,
 		InsecureAddress:           c.InsecureAddress,
+		HTTP3Address:              c.HTTP3Address,
 		StatusChecks:              c.StatusChecks.values,
 		EnableTCPQueue:            c.EnableTCPQueue,
 		ExpectedBytesPerRequest:   c.ExpectedBytesPerRequest,
@@ -787,6 +893,8 @@ func (c *Config) ToOptions() skipper.Options {
 		TLSClientAuth:             c.TLSClientAuth,
 		TLSMinVersion:             c.getMinTLSVersion(),
 		CipherSuites:              c.filterCipherSuites(),
+		TLSPoliciesFile:           c.TLSPoliciesFile,
+		EnableOCSPStapling:        c.EnableOCSPStapling,
 		MaxLoopbacks:              c.MaxLoopbacks,
 		DefaultHTTPStatus:         c.DefaultHTTPStatus,
 		ReverseSourcePredicate:    c.ReverseSourcePredicate,
@@ -798,6 +906,8 @@ func (c *Config) ToOptions() skipper.Options {
 		RatelimitSettings:         c.Ratelimits,
 		EnableRouteFIFOMetrics:    c.EnableRouteFIFOMetrics,
 		EnableRouteLIFOMetrics:    c.EnableRouteLIFOMetrics,
+		SchedulerFeedbackHeader:   c.SchedulerFeedbackHeader,
+		EnableSchedulerLiveConfig: c.EnableSchedulerLiveConfig,
 		MetricsFlavours:           c.MetricsFlavour.values,
 		FilterPlugins:             c.FilterPlugins.values,
 		PredicatePlugins:          c.PredicatePlugins.values,
@@ -815,6 +925,7 @@ func (c *Config) ToOptions() skipper.Options {
 		OpenTracingDisableFilterSpans:       c.OpenTracingDisableFilterSpans,
 		OpenTracingLogStreamEvents:          c.OpentracingLogStreamEvents,
 		OpenTracingLogFilterLifecycleEvents: c.OpentracingLogFilterLifecycleEvents,
+		OpenTracingSamplingRate:             c.OpenTracingSamplingRate,
 		MetricsListener:                     c.MetricsListener,
 		MetricsPrefix:                       c.MetricsPrefix,
 		EnableProfile:                       c.EnableProfile,
@@ -849,6 +960,11 @@ func (c *Config) ToOptions() skipper.Options {
 		AccessLogJSONEnabled:                c.AccessLogJSONEnabled,
 		AccessLogStripQuery:                 c.AccessLogStripQuery,
 		SuppressRouteUpdateLogs:             c.SuppressRouteUpdateLogs,
+		RouteHistorySize:                    c.RouteHistorySize,
+		RouteRolloutFraction:                c.RouteRolloutFraction,
+		RouteRolloutDuration:                c.RouteRolloutDuration,
+		RouteRolloutMinRequests:             c.RouteRolloutMinRequests,
+		RouteRolloutErrorThreshold:          c.RouteRolloutErrorThreshold,
 
 		// route sources:
 		EtcdUrls:        eus,
@@ -943,11 +1059,19 @@ func (c *Config) ToOptions() skipper.Options {
 
 		// connections, timeouts:
 		WaitForHealthcheckInterval:   c.WaitForHealthcheckInterval,
+		ShutdownDrainInterval:        c.ShutdownDrainInterval,
+		ShutdownTimeout:              c.ShutdownTimeout,
 		IdleConnectionsPerHost:       c.IdleConnsPerHost,
 		CloseIdleConnsPeriod:         c.CloseIdleConnsPeriod,
 		BackendFlushInterval:         c.BackendFlushInterval,
 		ExperimentalUpgrade:          c.ExperimentalUpgrade,
 		ExperimentalUpgradeAudit:     c.ExperimentalUpgradeAudit,
+		StreamingIdleTimeout:         c.StreamingIdleTimeout,
+		WebSocketFrameParser:         c.WebSocketFrameParser,
+		WebSocketMaxMessageSize:      c.WebSocketMaxMessageSize,
+		WebSocketIdleTimeout:         c.WebSocketIdleTimeout,
+		WebSocketPingInterval:        c.WebSocketPingInterval,
+		WebSocketPingTimeout:         c.WebSocketPingTimeout,
 		ReadTimeoutServer:            c.ReadTimeoutServer,
 		ReadHeaderTimeoutServer:      c.ReadHeaderTimeoutServer,
 		WriteTimeoutServer:           c.WriteTimeoutServer,
@@ -966,6 +1090,15 @@ func (c *Config) ToOptions() skipper.Options {
 		DisableHTTPKeepalives:        c.DisableHTTPKeepalives,
 		KubernetesEnableTLS:          c.KubernetesEnableTLS,
 
+		ACMEDirectoryURL:               c.ACMEDirectoryURL,
+		ACMEEmail:                      c.ACMEEmail,
+		ACMEChallenges:                 c.ACMEChallenges.values,
+		ACMEDomains:                    c.ACMEDomains.values,
+		ACMEStorageDir:                 c.ACMEStorageDir,
+		ACMEStorageKubernetesNamespace: c.ACMEStorageKubernetesNamespace,
+		ACMERenewBefore:                c.ACMERenewBefore,
+		ACMECAFile:                     c.ACMECAFile,
+
 		// swarm:
 		EnableSwarm: c.EnableSwarm,
 		// redis based
@@ -995,6 +1128,18 @@ func (c *Config) ToOptions() skipper.Options {
 		LuaModules: c.LuaModules.values,
 		LuaSources: c.LuaSources.values,
 
+		GeoIPDatabases:      c.GeoIPDatabases.values,
+		GeoIPClientIPSource: c.GeoIPClientIPSource,
+		GeoIPReloadInterval: c.GeoIPReloadInterval,
+
+		IPLists:               c.IPLists.values,
+		IPListsReloadInterval: c.IPListsReloadInterval,
+		IPListsClientIPSource: c.IPListsClientIPSource,
+
+		BodyPredicatesMaxSize: c.BodyPredicatesMaxSize,
+
+		ProxyProtocolTrustedNetworks: c.ProxyProtocolTrustedNetworks.values,
+
 		EnableOpenPolicyAgent:                              c.EnableOpenPolicyAgent,
 		EnableOpenPolicyAgentCustomControlLoop:             c.EnableOpenPolicyAgentCustomControlLoop,
 		OpenPolicyAgentControlLoopInterval:                 c.OpenPolicyAgentControlLoopInterval,
@@ -17,6 +17,7 @@ import (
 	"github.com/zalando/skipper/eskip"
 	"github.com/zalando/skipper/filters/openpolicyagent"
 	"github.com/zalando/skipper/net"
+	"github.com/zalando/skipper/predicates/content"
 	"github.com/zalando/skipper/proxy"
 	"gopkg.in/yaml.v2"
 
@@ -70,6 +71,7 @@ func TestEnvOverrides_SwarmRedisPassword(t *testing.T) {
 func defaultConfig(with func(*Config)) *Config {
 	cfg := &Config{
 		Flags:                                   nil,
+		ConfigReloadInterval:                    10 * time.Second,
 		Address:                                 ":9090",
 		StatusChecks:                            commaListFlag(),
 		ExpectedBytesPerRequest:                 50 * 1024,
@@ -88,6 +90,7 @@ func defaultConfig(with func(*Config)) *Config {
 		OpenTracingInitialSpan:                  "ingress",
 		OpentracingLogFilterLifecycleEvents:     true,
 		OpentracingLogStreamEvents:              true,
+		OpenTracingSamplingRate:                 1,
 		MetricsListener:                         ":9911",
 		MetricsPrefix:                           "skipper.",
 		RuntimeMetrics:                          true,
@@ -103,6 +106,10 @@ func defaultConfig(with func(*Config)) *Config {
 		CloneRoute:                              routeChangerConfig{},
 		EditRoute:                               routeChangerConfig{},
 		SourcePollTimeout:                       3000,
+		RouteHistorySize:                        16,
+		RouteRolloutDuration:                    time.Minute,
+		RouteRolloutMinRequests:                 100,
+		RouteRolloutErrorThreshold:              0.05,
 		KubernetesEastWestRangeDomains:          commaListFlag(),
 		KubernetesHealthcheck:                   true,
 		KubernetesHTTPSRedirect:                 true,
@@ -130,6 +137,8 @@ func defaultConfig(with func(*Config)) *Config {
 		CloseIdleConnsPeriod:                    20 * time.Second,
 		BackendFlushInterval:                    20 * time.Millisecond,
 		ReadTimeoutServer:                       5 * time.Minute,
+		StreamingIdleTimeout:                    time.Minute,
+		WebSocketMaxMessageSize:                 1 << 20,
 		ReadHeaderTimeoutServer:                 1 * time.Minute,
 		WriteTimeoutServer:                      1 * time.Minute,
 		IdleTimeoutServer:                       1 * time.Minute,
@@ -164,6 +173,17 @@ func defaultConfig(with func(*Config)) *Config {
 		ValidateQueryLog:                        true,
 		LuaModules:                              commaListFlag(),
 		LuaSources:                              commaListFlag(),
+		GeoIPDatabases:                          commaListFlag(),
+		GeoIPClientIPSource:                     "Source",
+		GeoIPReloadInterval:                     time.Minute,
+		IPLists:                                 commaListFlag(),
+		ProxyProtocolTrustedNetworks:            commaListFlag(),
+		ACMEChallenges:                          commaListFlag(),
+		ACMEDomains:                             commaListFlag(),
+		ACMERenewBefore:                         30 * 24 * time.Hour,
+		IPListsReloadInterval:                   time.Minute,
+		IPListsClientIPSource:                   "Source",
+		BodyPredicatesMaxSize:                   content.DefaultMaxBodySize,
 		OpenPolicyAgentCleanerInterval:          openpolicyagent.DefaultCleanIdlePeriod,
 		OpenPolicyAgentStartupTimeout:           openpolicyagent.DefaultOpaStartupTimeout,
 		OpenPolicyAgentControlLoopInterval:      openpolicyagent.DefaultControlLoopInterval,
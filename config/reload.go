@@ -0,0 +1,347 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/zalando/skipper"
+	"github.com/zalando/skipper/eskip"
+)
+
+const redactedValue = "[redacted]"
+
+// visibleFlags lists the settings shown by the config endpoint. The
+// values of all the other settings are redacted, because they may
+// contain credentials, e.g. the tracer arguments, the URLs or the
+// arguments of the default filters.
+var visibleFlags = map[string]bool{
+	"config-file":                     true,
+	"enable-config-reload":            true,
+	"config-reload-interval":          true,
+	"enable-config-endpoint":          true,
+	"address":                         true,
+	"insecure-address":                true,
+	"http3-address":                   true,
+	"support-listener":                true,
+	"metrics-listener":                true,
+	"debug-listener":                  true,
+	"dev-mode":                        true,
+	"max-loopbacks":                   true,
+	"default-http-status":             true,
+	"enable-breakers":                 true,
+	"breaker":                         true,
+	"enable-ratelimits":               true,
+	"ratelimits":                      true,
+	"metrics-flavour":                 true,
+	"metrics-prefix":                  true,
+	"enable-prometheus-metrics":       true,
+	"application-log-level":           true,
+	"application-log-json-enabled":    true,
+	"access-log-disabled":             true,
+	"access-log-json-enabled":         true,
+	"wait-first-route-load":           true,
+	"route-rollout-duration":          true,
+	"shutdown-drain-interval":         true,
+	"shutdown-timeout":                true,
+	"streaming-idle-timeout":          true,
+	"read-timeout-server":             true,
+	"read-header-timeout-server":      true,
+	"write-timeout-server":            true,
+	"idle-timeout-server":             true,
+	"max-header-bytes":                true,
+	"timeout-backend":                 true,
+	"keepalive-backend":               true,
+	"tls-timeout-backend":             true,
+	"response-header-timeout-backend": true,
+	"expect-continue-timeout-backend": true,
+	"max-idle-connection-backend":     true,
+	"disable-http-keepalives":         true,
+	"idle-conns-num":                  true,
+	"close-idle-conns-period":         true,
+	"tls-min-version":                 true,
+	"enable-connection-metrics":       true,
+	"opentracing-sampling-rate":       true,
+}
+
+// Reloader parses the configuration again when the config file changes,
+// or on SIGHUP, and applies the settings that can change at runtime:
+// the application log level, the default filters, the breakers, the
+// ratelimits and the tracing sampling rate. The changes of the other
+// settings are reported as requiring a restart.
+//
+// The Reloader serves the effective configuration as JSON, and it is
+// meant to be mounted on the support listener, when the config endpoint
+// is enabled. Only the values of the settings in visibleFlags are
+// shown.
+type Reloader struct {
+	config   *Config
+	settings chan skipper.RuntimeSettings
+	quit     chan struct{}
+	once     sync.Once
+
+	mu              sync.Mutex
+	fileContent     []byte
+	effective       map[string]string
+	restartRequired []string
+	lastReload      time.Time
+	lastError       error
+	closed          bool
+}
+
+type configInfo struct {
+	Config          map[string]string `json:"config"`
+	RestartRequired []string          `json:"restartRequired"`
+	LastReload      *time.Time        `json:"lastReload,omitempty"`
+	LastError       string            `json:"lastError,omitempty"`
+}
+
+// NewReloader creates a Reloader for the parsed configuration. When
+// config reload is enabled, it starts watching the config file and
+// SIGHUP.
+func NewReloader(c *Config) *Reloader {
+	r := &Reloader{
+		config:    c,
+		settings:  make(chan skipper.RuntimeSettings, 1),
+		quit:      make(chan struct{}),
+		effective: flagValues(c.Flags),
+	}
+
+	if c.EnableConfigReload {
+		if c.ConfigFile != "" {
+			r.fileContent, _ = os.ReadFile(c.ConfigFile)
+		}
+
+		go r.watch()
+	}
+
+	return r
+}
+
+// Settings returns the channel that receives the runtime settings
+// after a reload, to be passed to skipper.Options.RuntimeSettings.
+func (r *Reloader) Settings() <-chan skipper.RuntimeSettings {
+	return r.settings
+}
+
+func (r *Reloader) watch() {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	var check <-chan time.Time
+	if r.config.ConfigFile != "" && r.config.ConfigReloadInterval > 0 {
+		t := time.NewTicker(r.config.ConfigReloadInterval)
+		defer t.Stop()
+		check = t.C
+	}
+
+	for {
+		select {
+		case <-hup:
+			log.Info("Received SIGHUP, reloading the config")
+			r.Reload()
+		case <-check:
+			if r.fileChanged() {
+				log.Infof("Config file %s changed, reloading the config", r.config.ConfigFile)
+				r.Reload()
+			}
+		case <-r.quit:
+			return
+		}
+	}
+}
+
+func (r *Reloader) fileChanged() bool {
+	content, err := os.ReadFile(r.config.ConfigFile)
+	if err != nil {
+		log.Errorf("Failed to read the config file: %v", err)
+		return false
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if bytes.Equal(content, r.fileContent) {
+		return false
+	}
+
+	r.fileContent = content
+	return true
+}
+
+// reloadable tells whether the setting can be applied at runtime. The
+// breaker and the ratelimit settings can be applied only when the
+// breakers, respectively the ratelimiters, were enabled on startup.
+func (r *Reloader) reloadable(name string) bool {
+	switch name {
+	case "application-log-level", "default-filters-prepend", "default-filters-append", "opentracing-sampling-rate":
+		return true
+	case "breaker":
+		return r.config.EnableBreakers || len(r.config.Breakers) > 0
+	case "ratelimits":
+		return r.config.EnableRatelimiters || len(r.config.Ratelimits) > 0
+	default:
+		return false
+	}
+}
+
+// Reload parses the command line arguments and the config file again,
+// and applies the changed settings that can change at runtime. The
+// changed settings that require a restart are logged and reported by
+// the config endpoint.
+func (r *Reloader) Reload() error {
+	c := NewConfig()
+	c.Flags.SetOutput(io.Discard)
+	err := c.parseArgs(r.config.progname, r.config.args, flag.ContinueOnError)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.lastReload = time.Now()
+	r.lastError = err
+	if err != nil {
+		log.Errorf("Failed to reload the config: %v", err)
+		return err
+	}
+
+	var applied, restartRequired []string
+	for name, value := range flagValues(c.Flags) {
+		if r.effective[name] == value {
+			continue
+		}
+
+		if !r.reloadable(name) {
+			restartRequired = append(restartRequired, name)
+			continue
+		}
+
+		r.effective[name] = value
+		applied = append(applied, name)
+	}
+
+	slices.Sort(restartRequired)
+	r.restartRequired = restartRequired
+	if len(restartRequired) > 0 {
+		log.Warnf("Config changes require a restart: %s", strings.Join(restartRequired, ", "))
+	}
+
+	if len(applied) == 0 {
+		return nil
+	}
+
+	slices.Sort(applied)
+	log.Infof("Config changes applied: %s", strings.Join(applied, ", "))
+
+	if slices.Contains(applied, "application-log-level") {
+		log.SetLevel(c.ApplicationLogLevel)
+	}
+
+	if slices.ContainsFunc(applied, func(name string) bool { return name != "application-log-level" }) {
+		r.sendSettings(skipper.RuntimeSettings{
+			DefaultFilters: &eskip.DefaultFilters{
+				Prepend: c.PrependFilters.filters,
+				Append:  c.AppendFilters.filters,
+			},
+			BreakerSettings:     c.Breakers,
+			RatelimitSettings:   c.Ratelimits,
+			TracingSamplingRate: c.OpenTracingSamplingRate,
+		})
+	}
+
+	return nil
+}
+
+// sendSettings replaces the settings not received yet, if any.
+func (r *Reloader) sendSettings(s skipper.RuntimeSettings) {
+	if r.closed {
+		return
+	}
+
+	select {
+	case <-r.settings:
+	default:
+	}
+
+	r.settings <- s
+}
+
+// ServeHTTP serves the effective configuration, the changed settings
+// that require a restart, and the result of the last reload.
+func (r *Reloader) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != "GET" {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	r.mu.Lock()
+	info := configInfo{
+		Config:          make(map[string]string, len(r.effective)),
+		RestartRequired: slices.Clone(r.restartRequired),
+	}
+
+	for name, value := range r.effective {
+		info.Config[name] = redact(name, value)
+	}
+
+	if !r.lastReload.IsZero() {
+		t := r.lastReload
+		info.LastReload = &t
+	}
+
+	if r.lastError != nil {
+		info.LastError = r.lastError.Error()
+	}
+
+	r.mu.Unlock()
+
+	if info.RestartRequired == nil {
+		info.RestartRequired = []string{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(info); err != nil {
+		log.Errorf("Failed to encode the config: %v", err)
+	}
+}
+
+// Close stops watching the config file and SIGHUP, and closes the
+// settings channel.
+func (r *Reloader) Close() {
+	r.once.Do(func() {
+		close(r.quit)
+
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.closed = true
+		close(r.settings)
+	})
+}
+
+func flagValues(fs *flag.FlagSet) map[string]string {
+	values := make(map[string]string)
+	fs.VisitAll(func(f *flag.Flag) {
+		values[f.Name] = f.Value.String()
+	})
+
+	return values
+}
+
+// redact hides the values of the settings not listed as visible.
+func redact(name, value string) string {
+	if value == "" || visibleFlags[name] {
+		return value
+	}
+
+	return redactedValue
+}
@@ -2,16 +2,12 @@ package kubernetes
 
 import (
 	"bytes"
-	"crypto/tls"
-	"crypto/x509"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
-	"net"
 	"net/http"
 	"net/url"
-	"os"
 	"regexp"
 	"sort"
 	"strings"
@@ -20,6 +16,7 @@ import (
 	log "github.com/sirupsen/logrus"
 
 	"github.com/zalando/skipper/dataclients/kubernetes/definitions"
+	"github.com/zalando/skipper/dataclients/kubernetes/incluster"
 	"github.com/zalando/skipper/secrets"
 	"github.com/zalando/skipper/secrets/certregistry"
 )
@@ -42,9 +39,6 @@ const (
 	EndpointsNamespaceFmt      = "/api/v1/namespaces/%s/endpoints"
 	EndpointSlicesNamespaceFmt = "/apis/discovery.k8s.io/v1/namespaces/%s/endpointslices"
 	SecretsNamespaceFmt        = "/api/v1/namespaces/%s/secrets"
-	serviceAccountDir          = "/var/run/secrets/kubernetes.io/serviceaccount/"
-	serviceAccountTokenKey     = "token"
-	serviceAccountRootCAKey    = "ca.crt"
 	labelSelectorFmt           = "%s=%s"
 	labelSelectorQueryFmt      = "?labelSelector=%s"
 )
@@ -85,8 +79,8 @@ type clusterClient struct {
 var (
 	errResourceNotFound     = errors.New("resource not found")
 	errServiceNotFound      = errors.New("service not found")
-	errAPIServerURLNotFound = errors.New("kubernetes API server URL could not be constructed from env vars")
-	errInvalidCertificate   = errors.New("invalid CA")
+	errAPIServerURLNotFound = incluster.ErrAPIServerURLNotFound
+	errInvalidCertificate   = incluster.ErrInvalidCertificate
 )
 
 func buildHTTPClient(certFilePath string, inCluster bool, quit <-chan struct{}) (*http.Client, error) {
@@ -94,53 +88,11 @@ func buildHTTPClient(certFilePath string, inCluster bool, quit <-chan struct{})
 		return http.DefaultClient, nil
 	}
 
-	rootCA, err := os.ReadFile(certFilePath)
-	if err != nil {
-		return nil, err
-	}
-	certPool := x509.NewCertPool()
-	if !certPool.AppendCertsFromPEM(rootCA) {
-		return nil, errInvalidCertificate
-	}
-
-	transport := &http.Transport{
-		DialContext: (&net.Dialer{
-			Timeout:   10 * time.Second,
-			KeepAlive: 30 * time.Second,
-			DualStack: true,
-		}).DialContext,
-		TLSHandshakeTimeout:   10 * time.Second,
-		ResponseHeaderTimeout: 10 * time.Second,
-		ExpectContinueTimeout: 30 * time.Second,
-		MaxIdleConns:          5,
-		MaxIdleConnsPerHost:   5,
-		TLSClientConfig: &tls.Config{
-			MinVersion: tls.VersionTLS12,
-			RootCAs:    certPool,
-		},
-	}
-
-	// regularly force closing idle connections
-	go func() {
-		ticker := time.NewTicker(10 * time.Second)
-		defer ticker.Stop()
-		for {
-			select {
-			case <-ticker.C:
-				transport.CloseIdleConnections()
-			case <-quit:
-				return
-			}
-		}
-	}()
-
-	return &http.Client{
-		Transport: transport,
-	}, nil
+	return incluster.NewHTTPClient(certFilePath, quit)
 }
 
 func newClusterClient(o Options, apiURL, ingCls, rgCls string, quit <-chan struct{}) (*clusterClient, error) {
-	httpClient, err := buildHTTPClient(serviceAccountDir+serviceAccountRootCAKey, o.KubernetesInCluster, quit)
+	httpClient, err := buildHTTPClient(incluster.RootCAFile, o.KubernetesInCluster, quit)
 	if err != nil {
 		return nil, err
 	}
@@ -180,7 +132,7 @@ func newClusterClient(o Options, apiURL, ingCls, rgCls string, quit <-chan struc
 
 	if o.KubernetesInCluster {
 		c.tokenProvider = secrets.NewSecretPaths(time.Minute)
-		c.tokenFile = serviceAccountDir + serviceAccountTokenKey
+		c.tokenFile = incluster.TokenFile
 	} else if o.TokenFile != "" {
 		c.tokenProvider = secrets.NewSecretPaths(time.Minute)
 		c.tokenFile = o.TokenFile
@@ -167,6 +167,37 @@ type RouteTLSSpec struct {
 	// SecretName specifies the Kubernetes TLS secret to be
 	// used to terminate the TLS SNI connection
 	SecretName string `json:"secretName,omitempty"`
+
+	// MinVersion specifies the minimum TLS version of the hosts,
+	// one of 1.0, 1.1, 1.2 or 1.3
+	MinVersion string `json:"minVersion,omitempty"`
+
+	// CipherSuites specifies the TLS 1.0-1.2 cipher suites of the
+	// hosts, e.g. TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256
+	CipherSuites []string `json:"cipherSuites,omitempty"`
+
+	// ALPNProtocols specifies the application protocols of the
+	// hosts in the order of preference, e.g. h2 and http/1.1
+	ALPNProtocols []string `json:"alpnProtocols,omitempty"`
+
+	// ClientAuth specifies the client certificate policy of the
+	// hosts, one of none, optional or require
+	ClientAuth string `json:"clientAuth,omitempty"`
+
+	// ClientCASecretName specifies the Kubernetes secret with the
+	// ca.crt key, containing the CA certificates used to verify the
+	// client certificates
+	ClientCASecretName string `json:"clientCASecretName,omitempty"`
+}
+
+// HasPolicy returns true when the TLS spec overrides the TLS
+// configuration of the hosts.
+func (s *RouteTLSSpec) HasPolicy() bool {
+	return s.MinVersion != "" ||
+		len(s.CipherSuites) > 0 ||
+		len(s.ALPNProtocols) > 0 ||
+		s.ClientAuth != "" ||
+		s.ClientCASecretName != ""
 }
 
 func backendsWithDuplicateName(name string) error {
@@ -88,6 +88,8 @@ func (rgv *RouteGroupValidator) validatePredicates(item *RouteGroupItem) error {
 				errs = append(errs, err)
 			} else if len(predicates) != 1 {
 				errs = append(errs, fmt.Errorf("%w at %q", errSinglePredicateExpected, p))
+			} else if _, err := eskip.HeaderPredicateArgs(predicates[0]); err != nil {
+				errs = append(errs, fmt.Errorf("%w at %q", err, p))
 			}
 		}
 	}
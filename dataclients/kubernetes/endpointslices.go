@@ -4,7 +4,10 @@ import (
 	"github.com/zalando/skipper/dataclients/kubernetes/definitions"
 )
 
-const endpointSliceServiceNameLabel = "kubernetes.io/service-name"
+const (
+	endpointSliceServiceNameLabel = "kubernetes.io/service-name"
+	h2cAppProtocol                = "kubernetes.io/h2c"
+)
 
 // There are [1..N] Kubernetes endpointslices created for a single Kubernetes service.
 // Kubernetes endpointslices of a given service can have duplicates with different states.
@@ -43,6 +46,23 @@ func (eps *skipperEndpointSlice) getPort(protocol, pName string, pValue int) int
 
 	return port
 }
+
+// backendScheme returns h2c instead of http for the ports with the
+// kubernetes.io/h2c application protocol.
+func (eps *skipperEndpointSlice) backendScheme(protocol, scheme string, port int) string {
+	if scheme != "http" {
+		return scheme
+	}
+
+	for _, p := range eps.Ports {
+		if p.Port == port && (protocol == "" || p.Protocol == protocol) && p.AppProtocol == h2cAppProtocol {
+			return "h2c"
+		}
+	}
+
+	return scheme
+}
+
 func (eps *skipperEndpointSlice) targetsByServicePort(protocol, scheme string, servicePort *servicePort) []string {
 	var port int
 	if servicePort.Name != "" {
@@ -57,6 +77,7 @@ func (eps *skipperEndpointSlice) targetsByServicePort(protocol, scheme string, s
 		port = eps.getPort(protocol, servicePort.Name, servicePort.Port)
 	}
 
+	scheme = eps.backendScheme(protocol, scheme, port)
 	result := make([]string, 0, len(eps.Endpoints))
 	for _, ep := range eps.Endpoints {
 		result = append(result, formatEndpointString(ep.Address, scheme, port))
@@ -69,6 +90,7 @@ func (eps *skipperEndpointSlice) targetsByServiceTarget(protocol, scheme string,
 	pValue, _ := serviceTarget.Value.(int)
 	port := eps.getPort(protocol, pName, pValue)
 
+	scheme = eps.backendScheme(protocol, scheme, port)
 	result := make([]string, 0, len(eps.Endpoints))
 	for _, ep := range eps.Endpoints {
 		result = append(result, formatEndpointString(ep.Address, scheme, port))
@@ -130,7 +152,8 @@ type endpointSlicePort struct {
 	Name     string `json:"name"`     // "http"
 	Port     int    `json:"port"`     // 8080
 	Protocol string `json:"protocol"` // "TCP"
-	// AppProtocol is not used, but would make it possible to optimize H2C and websocket connections
+	// AppProtocol kubernetes.io/h2c selects the h2c backend scheme for the http ports,
+	// the others are not used, but would make it possible to optimize websocket connections
 	AppProtocol string `json:"appProtocol"` // "kubernetes.io/h2c", "kubernetes.io/ws", "kubernetes.io/wss"
 }
 
@@ -0,0 +1,101 @@
+/*
+Package incluster implements the access to the Kubernetes API from a pod
+running in the cluster, with the service account of the pod. It is shared
+by the Kubernetes clients of Skipper: the data client, the swarm and the
+ACME storage.
+*/
+package incluster
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"time"
+)
+
+const (
+	serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount/"
+
+	// TokenFile is the path of the service account token. The token
+	// is rotated, it needs to be read regularly.
+	TokenFile = serviceAccountDir + "token"
+
+	// RootCAFile is the path of the CA certificates of the API
+	// server.
+	RootCAFile = serviceAccountDir + "ca.crt"
+
+	serviceHostEnvVar = "KUBERNETES_SERVICE_HOST"
+	servicePortEnvVar = "KUBERNETES_SERVICE_PORT"
+)
+
+var (
+	// ErrAPIServerURLNotFound is returned by APIURL, when the
+	// environment of the pod doesn't contain the API server address.
+	ErrAPIServerURLNotFound = errors.New("kubernetes API server URL could not be constructed from env vars")
+
+	// ErrInvalidCertificate is returned by NewHTTPClient, when the CA
+	// file doesn't contain any certificates.
+	ErrInvalidCertificate = errors.New("invalid CA")
+)
+
+// APIURL returns the URL of the API server, from the environment
+// variables of the pod.
+func APIURL() (string, error) {
+	host, port := os.Getenv(serviceHostEnvVar), os.Getenv(servicePortEnvVar)
+	if host == "" || port == "" {
+		return "", ErrAPIServerURLNotFound
+	}
+
+	return "https://" + net.JoinHostPort(host, port), nil
+}
+
+// NewHTTPClient creates a client for the API server, trusting the CA
+// certificates of the PEM file, typically RootCAFile. The client
+// regularly closes its idle connections, until quit is closed.
+func NewHTTPClient(rootCAFile string, quit <-chan struct{}) (*http.Client, error) {
+	rootCA, err := os.ReadFile(rootCAFile)
+	if err != nil {
+		return nil, err
+	}
+	certPool := x509.NewCertPool()
+	if !certPool.AppendCertsFromPEM(rootCA) {
+		return nil, ErrInvalidCertificate
+	}
+
+	transport := &http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout:   10 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ResponseHeaderTimeout: 10 * time.Second,
+		ExpectContinueTimeout: 30 * time.Second,
+		MaxIdleConns:          5,
+		MaxIdleConnsPerHost:   5,
+		TLSClientConfig: &tls.Config{
+			MinVersion: tls.VersionTLS12,
+			RootCAs:    certPool,
+		},
+	}
+
+	// regularly force closing idle connections
+	go func() {
+		ticker := time.NewTicker(10 * time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				transport.CloseIdleConnections()
+			case <-quit:
+				return
+			}
+		}
+	}()
+
+	return &http.Client{
+		Transport: transport,
+	}, nil
+}
@@ -27,6 +27,8 @@ const (
 	tlsSecretType                       = "kubernetes.io/tls"
 	tlsSecretDataCrt                    = "tls.crt"
 	tlsSecretDataKey                    = "tls.key"
+	tlsSecretDataCA                     = "ca.crt"
+	routeGroupTLSPolicySource           = "routegroups"
 )
 
 type ingressContext struct {
@@ -2,9 +2,7 @@ package kubernetes
 
 import (
 	"fmt"
-	"net"
 	"net/http"
-	"os"
 	"regexp"
 	"strings"
 	"sync"
@@ -12,6 +10,7 @@ import (
 	"time"
 
 	log "github.com/sirupsen/logrus"
+	"github.com/zalando/skipper/dataclients/kubernetes/incluster"
 	"github.com/zalando/skipper/eskip"
 	"github.com/zalando/skipper/filters"
 	"github.com/zalando/skipper/loadbalancer"
@@ -23,8 +22,6 @@ const DefaultLoadBalancerAlgorithm = "roundRobin"
 const (
 	defaultIngressClass    = "skipper"
 	defaultRouteGroupClass = "skipper"
-	serviceHostEnvVar      = "KUBERNETES_SERVICE_HOST"
-	servicePortEnvVar      = "KUBERNETES_SERVICE_PORT"
 	httpRedirectRouteID    = "kube__redirect"
 	defaultEastWestDomain  = "skipper.cluster.local"
 )
@@ -270,6 +267,7 @@ type Client struct {
 	quit                   chan struct{}
 	defaultFiltersDir      string
 	state                  *clusterState
+	origins                routeOrigins
 	loggingInterval        time.Duration
 	loggingLastEnabled     time.Time
 }
@@ -361,12 +359,7 @@ func buildAPIURL(o Options) (string, error) {
 		return o.KubernetesURL, nil
 	}
 
-	host, port := os.Getenv(serviceHostEnvVar), os.Getenv(servicePortEnvVar)
-	if host == "" || port == "" {
-		return "", errAPIServerURLNotFound
-	}
-
-	return "https://" + net.JoinHostPort(host, port), nil
+	return incluster.APIURL()
 }
 
 // String returns the string representation of the path mode, the same
@@ -422,6 +415,7 @@ func (c *Client) loadAndConvert() ([]*eskip.Route, error) {
 		return nil, err
 	}
 	c.state = state
+	c.origins = newRouteOrigins(state)
 
 	loggingEnabled := log.GetLevel() >= log.DebugLevel || time.Since(c.loggingLastEnabled) >= c.loggingInterval
 	if loggingEnabled {
@@ -4,8 +4,10 @@ import (
 	"bytes"
 	"crypto/rand"
 	"crypto/rsa"
+	"crypto/tls"
 	"crypto/x509"
 	"crypto/x509/pkix"
+	"encoding/base64"
 	"encoding/json"
 	"encoding/pem"
 	"fmt"
@@ -1896,23 +1898,23 @@ func TestBuildAPIURL(t *testing.T) {
 	dummyPort := "8080"
 
 	// There is t.Unsetenv so set to properly restore/unset
-	t.Setenv(serviceHostEnvVar, "")
-	t.Setenv(servicePortEnvVar, "")
-	os.Unsetenv(serviceHostEnvVar)
-	os.Unsetenv(servicePortEnvVar)
+	t.Setenv("KUBERNETES_SERVICE_HOST", "")
+	t.Setenv("KUBERNETES_SERVICE_PORT", "")
+	os.Unsetenv("KUBERNETES_SERVICE_HOST")
+	os.Unsetenv("KUBERNETES_SERVICE_PORT")
 
 	apiURL, err = buildAPIURL(o)
 	if apiURL != "" || err != errAPIServerURLNotFound {
 		t.Error("build API url should fail if env var is missing")
 	}
 
-	t.Setenv(serviceHostEnvVar, dummyHost)
+	t.Setenv("KUBERNETES_SERVICE_HOST", dummyHost)
 	apiURL, err = buildAPIURL(o)
 	if apiURL != "" || err != errAPIServerURLNotFound {
 		t.Error("build API url should fail if env var is missing")
 	}
 
-	t.Setenv(servicePortEnvVar, dummyPort)
+	t.Setenv("KUBERNETES_SERVICE_PORT", dummyPort)
 	apiURL, err = buildAPIURL(o)
 	if apiURL != "https://10.0.0.2:8080" || err != nil {
 		t.Error("incorrect result of build api url")
@@ -2958,6 +2960,45 @@ func TestCertificateRegistry(t *testing.T) {
 	})
 }
 
+func TestRouteGroupTLSPolicy(t *testing.T) {
+	ca := generateSSCert()
+	state := &clusterState{secrets: map[definitions.ResourceID]*secret{
+		{Namespace: "default", Name: "client-ca"}: {Data: map[string]string{"ca.crt": base64.StdEncoding.EncodeToString(ca)}},
+	}}
+
+	rg := &definitions.RouteGroupItem{
+		Metadata: &definitions.Metadata{Namespace: "default", Name: "myapp"},
+		Spec:     &definitions.RouteGroupSpec{Hosts: []string{"example.org", "www.example.org"}},
+	}
+
+	policies := make(map[string]*certregistry.TLSPolicy)
+	addRouteGroupTLSPolicy(state, rg, nil, &definitions.RouteTLSSpec{Hosts: []string{"example.org"}, SecretName: "myapp-secret"}, policies)
+	assert.Empty(t, policies)
+
+	addRouteGroupTLSPolicy(state, rg, nil, &definitions.RouteTLSSpec{
+		Hosts:              []string{"example.org", "other.example.org"},
+		MinVersion:         "1.3",
+		ALPNProtocols:      []string{"h2"},
+		ClientAuth:         "require",
+		ClientCASecretName: "client-ca",
+	}, policies)
+
+	require.Len(t, policies, 1)
+	p := policies["example.org"]
+	require.NotNil(t, p)
+	assert.Equal(t, uint16(tls.VersionTLS13), p.MinVersion)
+	assert.Equal(t, []string{"h2"}, p.NextProtos)
+	assert.Equal(t, tls.RequireAndVerifyClientCert, *p.ClientAuth)
+	assert.NotNil(t, p.ClientCAs)
+
+	addRouteGroupTLSPolicy(state, rg, nil, &definitions.RouteTLSSpec{
+		Hosts:              []string{"www.example.org"},
+		ClientAuth:         "require",
+		ClientCASecretName: "missing",
+	}, policies)
+	assert.NotContains(t, policies, "www.example.org")
+}
+
 type mockSecretProvider string
 
 func (sp mockSecretProvider) GetSecret(string) ([]byte, bool) {
@@ -0,0 +1,86 @@
+package kubernetes
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/zalando/skipper/routing"
+)
+
+const (
+	ingressKind    = "Ingress"
+	routeGroupKind = "RouteGroup"
+)
+
+// routeOrigins maps the route id prefixes generated for the ingresses
+// and the route groups to their object references.
+type routeOrigins map[string]*routing.ObjectReference
+
+func newRouteOrigins(state *clusterState) routeOrigins {
+	o := make(routeOrigins)
+	for _, i := range state.ingressesV1 {
+		if i.Metadata == nil {
+			continue
+		}
+
+		ns, name := i.Metadata.Namespace, i.Metadata.Name
+		prefix := fmt.Sprintf(
+			"%s_%s__%s__",
+			ingressRouteIDPrefix,
+			nonWord.ReplaceAllString(ns, "_"),
+			nonWord.ReplaceAllString(name, "_"),
+		)
+
+		o[prefix] = &routing.ObjectReference{Kind: ingressKind, Namespace: ns, Name: name}
+	}
+
+	for _, rg := range state.routeGroups {
+		if rg.Metadata == nil {
+			continue
+		}
+
+		ref := &routing.ObjectReference{
+			Kind:      routeGroupKind,
+			Namespace: namespaceString(rg.Metadata.Namespace),
+			Name:      rg.Metadata.Name,
+		}
+
+		ns, name := toSymbol(ref.Namespace), toSymbol(ref.Name)
+		o[fmt.Sprintf("kube_rg__%s__%s__", ns, name)] = ref
+		o[fmt.Sprintf("kube_rg__internal_%s__%s__", ns, name)] = ref
+	}
+
+	return o
+}
+
+// get returns the object reference with the longest prefix of the route
+// id, ending with a double underscore.
+func (o routeOrigins) get(id string) *routing.ObjectReference {
+	if rest, ok := strings.CutPrefix(id, "kubeew"); ok {
+		id = ingressRouteIDPrefix + rest
+	}
+
+	var ref *routing.ObjectReference
+	for i := 0; i < len(id); {
+		j := strings.Index(id[i:], "__")
+		if j < 0 {
+			break
+		}
+
+		i += j + 2
+		if r, ok := o[id[:i]]; ok {
+			ref = r
+		}
+	}
+
+	return ref
+}
+
+// RouteOrigin returns the Ingress or the RouteGroup that the route with
+// the given id was generated from, based on the last loaded cluster
+// state. It implements the routing.RouteOrigins interface.
+func (c *Client) RouteOrigin(id string) *routing.ObjectReference {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.origins.get(id)
+}
@@ -0,0 +1,46 @@
+package kubernetes
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/zalando/skipper/dataclients/kubernetes/definitions"
+	"github.com/zalando/skipper/routing"
+)
+
+func TestRouteOrigins(t *testing.T) {
+	state := &clusterState{
+		ingressesV1: []*definitions.IngressV1Item{
+			{Metadata: &definitions.Metadata{Namespace: "default", Name: "my-app"}},
+			{Metadata: &definitions.Metadata{Namespace: "default", Name: "my-app-v2"}},
+		},
+		routeGroups: []*definitions.RouteGroupItem{
+			{Metadata: &definitions.Metadata{Namespace: "team-a", Name: "api.v1"}},
+		},
+	}
+
+	o := newRouteOrigins(state)
+	ingress := &routing.ObjectReference{Kind: "Ingress", Namespace: "default", Name: "my-app"}
+	ingressV2 := &routing.ObjectReference{Kind: "Ingress", Namespace: "default", Name: "my-app-v2"}
+	routeGroup := &routing.ObjectReference{Kind: "RouteGroup", Namespace: "team-a", Name: "api.v1"}
+
+	rgMeta := &definitions.Metadata{Namespace: "team-a", Name: "api.v1"}
+	ingressRouteID := routeID("default", "my-app", "www.example.org", "/foo", "my-svc")
+	for _, tt := range []struct {
+		id     string
+		expect *routing.ObjectReference
+	}{
+		{ingressRouteID, ingress},
+		{routeID("default", "my-app-v2", "www.example.org", "", ""), ingressV2},
+		{routeIDForRedirectRoute(ingressRouteID, true), ingress},
+		{eastWestRouteID(ingressRouteID), ingress},
+		{crdRouteID(rgMeta, "GET", 1, 0, false), routeGroup},
+		{crdRouteID(rgMeta, "", 0, 0, true), routeGroup},
+		{routeID("", "catchall", "www.example.org", "", ""), nil},
+		{"kube__healthz_up", nil},
+		{"custom", nil},
+	} {
+		assert.Equal(t, tt.expect, o.get(tt.id), tt.id)
+	}
+}
@@ -1,6 +1,7 @@
 package kubernetes
 
 import (
+	b64 "encoding/base64"
 	"fmt"
 	"regexp"
 	"strings"
@@ -516,9 +517,62 @@ func (r *routeGroups) addRouteGroupTLS(ctx *routeGroupContext, tls *definitions.
 
 }
 
+// addRouteGroupTLSPolicy creates the TLS policy of the hosts from the
+// TLS spec, when it defines any of the policy fields.
+func addRouteGroupTLSPolicy(s *clusterState, rg *definitions.RouteGroupItem, logger *logger, tls *definitions.RouteTLSSpec, policies map[string]*certregistry.TLSPolicy) {
+	if !tls.HasPolicy() {
+		return
+	}
+
+	hosts := compareStringList(tls.Hosts, rg.Spec.UniqueHosts())
+	if len(hosts) == 0 {
+		return
+	}
+
+	var clientCAs []byte
+	if tls.ClientCASecretName != "" {
+		secretID := definitions.ResourceID{Name: tls.ClientCASecretName, Namespace: rg.Metadata.Namespace}
+		secret, ok := s.secrets[secretID]
+		if !ok {
+			logger.Errorf("Failed to find client CA secret %s in namespace %s", secretID.Name, secretID.Namespace)
+			return
+		}
+
+		var err error
+		clientCAs, err = b64.StdEncoding.DecodeString(secret.Data[tlsSecretDataCA])
+		if err != nil || len(clientCAs) == 0 {
+			logger.Errorf("Failed to decode %s from secret %s", tlsSecretDataCA, secretID.Name)
+			return
+		}
+	}
+
+	spec := certregistry.PolicySpec{
+		MinVersion:    tls.MinVersion,
+		CipherSuites:  tls.CipherSuites,
+		ALPNProtocols: tls.ALPNProtocols,
+		ClientAuth:    tls.ClientAuth,
+	}
+
+	p, err := spec.Policy(clientCAs)
+	if err != nil {
+		logger.Errorf("Invalid TLS policy for hosts %s: %v", hosts, err)
+		return
+	}
+
+	for _, h := range hosts {
+		if _, ok := policies[h]; ok {
+			logger.Infof("Ignoring duplicate TLS policy for host %s", h)
+			continue
+		}
+
+		policies[h] = p
+	}
+}
+
 func (r *routeGroups) convert(s *clusterState, df defaultFilters, loggingEnabled bool, cr *certregistry.CertRegistry) ([]*eskip.Route, error) {
 	var rs []*eskip.Route
 	redirect := createRedirectInfo(r.options.ProvideHTTPSRedirect, r.options.HTTPSRedirectCode)
+	policies := make(map[string]*certregistry.TLSPolicy)
 
 	for _, rg := range s.routeGroups {
 		logger := newLogger("RouteGroup", rg.Metadata.Namespace, rg.Metadata.Name, loggingEnabled)
@@ -647,6 +701,16 @@ func (r *routeGroups) convert(s *clusterState, df defaultFilters, loggingEnabled
 
 			rs = append(rs, internalRi...)
 		}
+
+		if cr != nil {
+			for _, rgTLS := range rg.Spec.TLS {
+				addRouteGroupTLSPolicy(s, rg, logger, rgTLS, policies)
+			}
+		}
+	}
+
+	if cr != nil {
+		cr.SetPolicies(routeGroupTLSPolicySource, policies)
 	}
 
 	return rs, nil
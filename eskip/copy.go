@@ -1,9 +1,17 @@
 package eskip
 
 func copyArgs(a []interface{}) []interface{} {
-	// we don't need deep copy of the items for the supported values
+	// we don't need deep copy of the items for the supported values, except
+	// for the predicates nested in the Or, And and Not compositions
 	c := make([]interface{}, len(a))
-	copy(c, a)
+	for i, ai := range a {
+		if p, ok := ai.(*Predicate); ok {
+			c[i] = CopyPredicate(p)
+		} else {
+			c[i] = ai
+		}
+	}
+
 	return c
 }
 
@@ -108,6 +108,19 @@ func TestCopy(t *testing.T) {
 				checkPredicate(t, c[i], p[i])
 			}
 		})
+
+		t.Run("nested", func(t *testing.T) {
+			p := &Predicate{Name: "Not", Args: []interface{}{&Predicate{Name: "foo", Args: []interface{}{"hello"}}}}
+			c := CopyPredicate(p)
+			if !reflect.DeepEqual(c, p) {
+				t.Error("failed to copy predicate")
+			}
+
+			p.Args[0].(*Predicate).Args[0] = "test-slice-identity"
+			if c.Args[0].(*Predicate).Args[0] == "test-slice-identity" {
+				t.Error("failed to copy nested predicate")
+			}
+		})
 	})
 
 	t.Run("routes", func(t *testing.T) {
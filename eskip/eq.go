@@ -32,6 +32,16 @@ func eqArgs(left, right []interface{}) bool {
 	}
 
 	for i := range left {
+		lp, lok := left[i].(*Predicate)
+		rp, rok := right[i].(*Predicate)
+		if lok || rok {
+			if !lok || !rok || !eqPredicate(lp, rp) {
+				return false
+			}
+
+			continue
+		}
+
 		if left[i] != right[i] {
 			return false
 		}
@@ -40,6 +50,16 @@ func eqArgs(left, right []interface{}) bool {
 	return true
 }
 
+// compares predicates, including the predicates nested in the arguments of
+// the Or, And and Not compositions
+func eqPredicate(left, right *Predicate) bool {
+	if left == nil || right == nil {
+		return left == right
+	}
+
+	return left.Name == right.Name && eqArgs(left.Args, right.Args)
+}
+
 func eqStrings(left, right []string) bool {
 	if len(left) != len(right) {
 		return false
@@ -74,8 +94,7 @@ func eq2(left, right *Route) bool {
 	}
 
 	for i := range lc.Predicates {
-		lp, rp := lc.Predicates[i], rc.Predicates[i]
-		if lp.Name != rp.Name || !eqArgs(lp.Args, rp.Args) {
+		if !eqPredicate(lc.Predicates[i], rc.Predicates[i]) {
 			return false
 		}
 	}
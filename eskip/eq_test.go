@@ -126,6 +126,21 @@ func TestEq(t *testing.T) {
 			LBEndpoints: []string{"https://one.example.org", "https://two.example.org"},
 		}},
 		expect: true,
+	}, {
+		title: "eq composed predicates",
+		routes: []*Route{{
+			Predicates: []*Predicate{{Name: "Not", Args: []interface{}{&Predicate{Name: "Method", Args: []interface{}{"GET"}}}}},
+		}, {
+			Predicates: []*Predicate{{Name: "Not", Args: []interface{}{&Predicate{Name: "Method", Args: []interface{}{"GET"}}}}},
+		}},
+		expect: true,
+	}, {
+		title: "non-eq composed predicates",
+		routes: []*Route{{
+			Predicates: []*Predicate{{Name: "Not", Args: []interface{}{&Predicate{Name: "Method", Args: []interface{}{"GET"}}}}},
+		}, {
+			Predicates: []*Predicate{{Name: "Not", Args: []interface{}{&Predicate{Name: "Method", Args: []interface{}{"POST"}}}}},
+		}},
 	}, {
 		title:  "one out of 3 non-eq",
 		routes: []*Route{{Id: "foo"}, {Id: "foo"}, {Id: "bar"}},
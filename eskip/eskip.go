@@ -238,7 +238,8 @@ type Predicate struct {
 	// The arguments of the predicate as defined in the
 	// route definition. The arguments can be of type
 	// float64 or string (string for both strings and
-	// regular expressions).
+	// regular expressions), or *Predicate for the predicates
+	// nested in the Or, And and Not compositions.
 	Args []interface{} `json:"args"`
 }
 
@@ -348,11 +349,19 @@ func (f *Filter) Copy() *Filter {
 	return &c
 }
 
-// Copy copies a predicate to a new filter instance. The argument values are copied in a shallow way.
+// Copy copies a predicate to a new filter instance. The argument values are copied in a shallow way,
+// except for the nested predicates of the Or, And and Not compositions.
 func (p *Predicate) Copy() *Predicate {
 	c := *p
 	c.Args = make([]interface{}, len(p.Args))
-	copy(c.Args, p.Args)
+	for i, a := range p.Args {
+		if np, ok := a.(*Predicate); ok {
+			c.Args[i] = np.Copy()
+		} else {
+			c.Args[i] = a
+		}
+	}
+
 	return &c
 }
 
@@ -469,6 +478,27 @@ func getStringArgs(p *Predicate, n int) ([]string, error) {
 	return sargs, nil
 }
 
+// HeaderPredicateArgs validates the arguments of the header predicates
+// HeaderPrefix, HeaderSuffix, HeaderContains, HeaderExists, HeaderAbsent
+// and HeaderIn, and returns them as strings. It returns nil for other
+// predicates.
+func HeaderPredicateArgs(p *Predicate) ([]string, error) {
+	switch p.Name {
+	case "HeaderPrefix", "HeaderSuffix", "HeaderContains":
+		return getStringArgs(p, 2)
+	case "HeaderExists", "HeaderAbsent":
+		return getStringArgs(p, 1)
+	case "HeaderIn":
+		if len(p.Args) < 2 {
+			return nil, fmt.Errorf("%s predicate expects at least 2 string arguments", p.Name)
+		}
+
+		return getStringArgs(p, len(p.Args))
+	default:
+		return nil, nil
+	}
+}
+
 // Checks and sets the different predicates taken from the yacc result.
 // As the syntax is getting stabilized, this logic soon should be defined as
 // yacc rules. (https://github.com/zalando/skipper/issues/89)
@@ -528,6 +558,10 @@ func applyPredicates(route *Route, proute *parsedRoute) error {
 
 				route.Headers[args[0]] = args[1]
 			}
+		case "HeaderPrefix", "HeaderSuffix", "HeaderContains", "HeaderExists", "HeaderAbsent", "HeaderIn":
+			if _, err = HeaderPredicateArgs(p); err == nil {
+				route.Predicates = append(route.Predicates, p)
+			}
 		case "*", "Any":
 			// void
 		default:
@@ -105,6 +105,46 @@ func TestParse(t *testing.T) {
 				"Header-1": {"value-2", "value-3"}},
 			Backend: "https://www.example.org"}},
 		"",
+	}, {
+		"header value predicates",
+		`HeaderPrefix("Accept", "application/") &&
+		HeaderExists("Authorization") &&
+		HeaderIn("X-Tenant", "foo", "bar") ->
+		"https://www.example.org"`,
+		[]*Route{{
+			Predicates: []*Predicate{
+				{"HeaderPrefix", []interface{}{"Accept", "application/"}},
+				{"HeaderExists", []interface{}{"Authorization"}},
+				{"HeaderIn", []interface{}{"X-Tenant", "foo", "bar"}},
+			},
+			Backend: "https://www.example.org"}},
+		"",
+	}, {
+		"invalid header value predicate",
+		`foo: HeaderContains("Accept") -> "https://www.example.org";`,
+		nil,
+		`invalid route "foo": HeaderContains predicate expects 2 string arguments`,
+	}, {
+		"invalid header in predicate",
+		`foo: HeaderIn("X-Tenant") -> "https://www.example.org";`,
+		nil,
+		`invalid route "foo": HeaderIn predicate expects at least 2 string arguments`,
+	}, {
+		"composed predicates",
+		`Or(Method("GET"), And(Header("X-Foo", "bar"), Not(Host(/^www[.]/)))) ->
+		"https://www.example.org"`,
+		[]*Route{{
+			Predicates: []*Predicate{{"Or", []interface{}{
+				&Predicate{"Method", []interface{}{"GET"}},
+				&Predicate{"And", []interface{}{
+					&Predicate{"Header", []interface{}{"X-Foo", "bar"}},
+					&Predicate{"Not", []interface{}{
+						&Predicate{"Host", []interface{}{"^www[.]"}},
+					}},
+				}},
+			}}},
+			Backend: "https://www.example.org"}},
+		"",
 	}, {
 		"comment as last token",
 		"route: Any() -> <shunt>; // some comment",
@@ -739,6 +779,19 @@ func TestPredicateString(t *testing.T) {
 				},
 			},
 			want: `ClientIP("1.2.3.4/26", "10.2.3.4/22")`,
+		},
+		{
+			name: "test nested predicates",
+			predicate: &Predicate{
+				Name: "Or",
+				Args: []interface{}{
+					&Predicate{Name: "Method", Args: []interface{}{"GET"}},
+					&Predicate{Name: "Not", Args: []interface{}{
+						&Predicate{Name: "Header", Args: []interface{}{"X-Foo", "bar"}},
+					}},
+				},
+			},
+			want: `Or(Method("GET"), Not(Header("X-Foo", "bar")))`,
 		}} {
 		t.Run(tt.name, func(t *testing.T) {
 			got := tt.predicate.String()
@@ -886,3 +939,19 @@ func BenchmarkRouteStringNoRepeatedPredicates(b *testing.B) {
 	}
 	stringSink = s
 }
+
+func TestHeaderPredicateArgs(t *testing.T) {
+	args, err := HeaderPredicateArgs(&Predicate{Name: "HeaderIn", Args: []interface{}{"X-Tenant", "foo", "bar"}})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"X-Tenant", "foo", "bar"}, args)
+
+	_, err = HeaderPredicateArgs(&Predicate{Name: "HeaderExists", Args: []interface{}{"X-Tenant", "foo"}})
+	assert.EqualError(t, err, "HeaderExists predicate expects 1 string argument")
+
+	_, err = HeaderPredicateArgs(&Predicate{Name: "HeaderPrefix", Args: []interface{}{"Accept", 42}})
+	assert.EqualError(t, err, "HeaderPrefix predicate expects 2 string arguments")
+
+	args, err = HeaderPredicateArgs(&Predicate{Name: "Method", Args: []interface{}{42}})
+	assert.NoError(t, err)
+	assert.Nil(t, args)
+}
@@ -3,6 +3,8 @@ package eskip
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
+	"fmt"
 )
 
 type jsonNameArgs struct {
@@ -64,6 +66,54 @@ func (p *Predicate) MarshalJSON() ([]byte, error) {
 	return marshalJSONNoEscape(&jsonNameArgs{Name: p.Name, Args: p.Args})
 }
 
+// UnmarshalJSON decodes a predicate. Object arguments are decoded as the
+// nested predicates of the Or, And and Not compositions.
+func (p *Predicate) UnmarshalJSON(b []byte) error {
+	var jp jsonNameArgs
+	if err := json.Unmarshal(b, &jp); err != nil {
+		return err
+	}
+
+	args, err := nestedPredicateArgs(jp.Args)
+	if err != nil {
+		return err
+	}
+
+	p.Name = jp.Name
+	p.Args = args
+	return nil
+}
+
+func nestedPredicateArgs(args []interface{}) ([]interface{}, error) {
+	for i, a := range args {
+		m, ok := a.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		name, ok := m["name"].(string)
+		if !ok {
+			return nil, errors.New("invalid nested predicate: missing name")
+		}
+
+		var nestedArgs []interface{}
+		if a, ok := m["args"]; ok && a != nil {
+			if nestedArgs, ok = a.([]interface{}); !ok {
+				return nil, fmt.Errorf("invalid args of nested predicate: %s", name)
+			}
+		}
+
+		nestedArgs, err := nestedPredicateArgs(nestedArgs)
+		if err != nil {
+			return nil, err
+		}
+
+		args[i] = &Predicate{Name: name, Args: nestedArgs}
+	}
+
+	return args, nil
+}
+
 func (r *Route) MarshalJSON() ([]byte, error) {
 	return marshalJSONNoEscape(newJSONRoute(r))
 }
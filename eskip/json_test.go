@@ -119,6 +119,31 @@ func TestMarshalUnmarshalJSON(t *testing.T) {
 				}
 			]`,
 		},
+		{
+			"composed predicates",
+			[]*Route{
+				{
+					Id: "composed",
+					Predicates: []*Predicate{{Name: "Or", Args: []interface{}{
+						&Predicate{Name: "Method", Args: []interface{}{"GET"}},
+						&Predicate{Name: "Not", Args: []interface{}{
+							&Predicate{Name: "Traffic", Args: []interface{}{0.1}},
+						}},
+					}}},
+					BackendType: ShuntBackend,
+				},
+			},
+			`[
+				{
+					"id":"composed",
+					"backend":{"type":"shunt"},
+					"predicates":[{"name":"Or","args":[
+						{"name":"Method","args":["GET"]},
+						{"name":"Not","args":[{"name":"Traffic","args":[0.1]}]}
+					]}]
+				}
+			]`,
+		},
 		{
 			"shunt, field",
 			[]*Route{{Id: "sh", Shunt: true}},
@@ -308,8 +333,9 @@ func TestMarshalUnmarshalJSON(t *testing.T) {
 
 func TestInvalidJSON(t *testing.T) {
 	for name, input := range map[string]string{
-		"invalid":              "{\\",
-		"invalid backend type": `{"backend": {"type": "foo"}}`,
+		"invalid":                       "{\\",
+		"invalid backend type":          `{"backend": {"type": "foo"}}`,
+		"nested predicate without name": `{"predicates": [{"name": "Not", "args": [{"args": ["GET"]}]}]}`,
 	} {
 		t.Run(name, func(t *testing.T) {
 			var r *Route
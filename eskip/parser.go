@@ -94,65 +94,68 @@ var eskipExca = [...]int8{
 
 const eskipPrivate = 57344
 
-const eskipLast = 68
+const eskipLast = 78
 
 var eskipAct = [...]int8{
-	48, 39, 17, 29, 38, 2, 3, 4, 32, 33,
-	34, 31, 18, 36, 11, 55, 16, 43, 8, 42,
-	50, 49, 13, 18, 41, 50, 13, 28, 44, 59,
-	23, 45, 19, 24, 26, 15, 37, 30, 27, 12,
-	24, 7, 53, 51, 52, 52, 56, 57, 23, 44,
-	54, 21, 22, 20, 58, 46, 25, 21, 60, 9,
-	35, 47, 40, 14, 10, 6, 5, 1,
+	51, 40, 11, 17, 39, 29, 2, 3, 4, 32,
+	33, 34, 31, 18, 36, 16, 59, 8, 13, 45,
+	18, 44, 13, 45, 37, 44, 43, 41, 48, 46,
+	43, 15, 53, 52, 13, 15, 30, 27, 28, 53,
+	64, 19, 23, 26, 24, 24, 7, 12, 56, 54,
+	57, 55, 60, 46, 61, 58, 23, 22, 41, 63,
+	62, 21, 65, 20, 49, 25, 21, 9, 35, 50,
+	42, 14, 47, 38, 10, 6, 5, 1,
 }
 
 var eskipPact = [...]int16{
-	-16, -1000, 21, 17, 5, -1000, 19, -1000, -1000, 47,
-	17, -1000, 22, -1000, 53, 29, 50, -1000, 23, 9,
-	-6, 17, -1000, -1000, 7, 5, 7, -1000, 40, -1000,
-	49, -1000, -1000, -1000, -1000, -1000, 3, -1000, 36, -1000,
-	-1000, -1000, -1000, -1000, -1000, 35, -6, -5, 37, 38,
-	-1000, -1000, 7, -1000, -1000, -1000, 12, 8, -1000, -1000,
-	37,
+	-15, -1000, 29, 17, 2, -1000, 28, -1000, -1000, 57,
+	17, -1000, 34, -1000, 62, 33, 59, -1000, 32, 20,
+	-5, 17, -1000, -1000, 13, 2, 9, -1000, 48, -1000,
+	58, -1000, -1000, -1000, -1000, -1000, 15, -1000, 42, -1000,
+	-1000, -1000, -1000, -1000, -1000, -1000, -1000, 41, -1000, -5,
+	-4, 43, 45, -1000, -1000, 13, -1000, 9, -1000, -1000,
+	23, 22, -1000, -1000, -1000, 43,
 }
 
 var eskipPgo = [...]int8{
-	0, 67, 66, 59, 16, 65, 41, 18, 64, 3,
-	14, 4, 2, 1, 62, 0, 61, 60,
+	0, 77, 76, 67, 15, 75, 46, 17, 74, 5,
+	2, 73, 4, 1, 3, 72, 70, 0, 69, 68,
 }
 
 var eskipR1 = [...]int8{
 	0, 1, 1, 1, 1, 1, 2, 2, 5, 5,
 	5, 5, 7, 8, 6, 6, 3, 3, 10, 10,
-	4, 4, 12, 11, 11, 11, 13, 13, 13, 15,
-	15, 16, 16, 17, 9, 9, 9, 9, 9, 14,
+	11, 11, 11, 12, 12, 4, 4, 14, 15, 15,
+	15, 13, 13, 13, 17, 17, 18, 18, 19, 9,
+	9, 9, 9, 9, 16,
 }
 
 var eskipR2 = [...]int8{
 	0, 2, 1, 2, 1, 2, 1, 1, 0, 1,
 	3, 2, 2, 2, 3, 5, 1, 3, 1, 4,
-	1, 3, 4, 0, 1, 3, 1, 1, 1, 1,
-	3, 1, 3, 3, 1, 1, 1, 1, 1, 1,
+	0, 1, 3, 1, 1, 1, 3, 4, 0, 1,
+	3, 1, 1, 1, 1, 3, 1, 3, 3, 1,
+	1, 1, 1, 1, 1,
 }
 
 var eskipChk = [...]int16{
 	-1000, -1, 21, 22, 23, -2, -5, -6, -7, -3,
-	-8, -10, 18, 5, -3, 18, -4, -12, 18, 13,
+	-8, -10, 18, 5, -3, 18, -4, -14, 18, 13,
 	6, 4, -6, 8, 11, 6, 11, -7, 18, -9,
-	-4, 17, 14, 15, 16, -17, 19, -10, -11, -13,
-	-14, 17, 12, 10, -12, -11, 6, -16, -15, 18,
-	17, 7, 9, 7, -9, 20, 9, 9, -13, 17,
-	-15,
+	-4, 17, 14, 15, 16, -19, 19, -10, -11, -12,
+	-13, -10, -16, 17, 12, 10, -14, -15, -13, 6,
+	-18, -17, 18, 17, 7, 9, 7, 9, -9, 20,
+	9, 9, -12, -13, 17, -17,
 }
 
 var eskipDef = [...]int8{
 	0, -2, 8, 2, 4, 1, 6, 7, 9, 0,
-	0, 16, 0, 18, 3, 0, 5, 20, 0, 11,
-	0, 0, 12, 13, 23, 0, 23, 10, 0, 14,
-	0, 34, 35, 36, 37, 38, 0, 17, 0, 24,
-	26, 27, 28, 39, 21, 0, 0, 0, 31, 0,
-	29, 19, 0, 22, 15, 33, 0, 0, 25, 30,
-	32,
+	0, 16, 0, 18, 3, 0, 5, 25, 0, 11,
+	0, 0, 12, 13, 20, 0, 28, 10, 0, 14,
+	0, 39, 40, 41, 42, 43, 0, 17, 0, 21,
+	23, 24, 31, 32, 33, 44, 26, 0, 29, 0,
+	0, 36, 0, 34, 19, 0, 27, 0, 15, 38,
+	0, 0, 22, 30, 35, 37,
 }
 
 var eskipTok1 = [...]int8{
@@ -626,18 +629,40 @@ eskipdefault:
 			eskipVAL.predicate = &Predicate{eskipDollar[1].token, eskipDollar[3].args}
 			eskipDollar[3].args = nil
 		}
-	case 20:
+	case 21:
+		eskipDollar = eskipS[eskippt-1 : eskippt+1]
+		{
+			eskipVAL.args = []interface{}{eskipDollar[1].arg}
+		}
+	case 22:
+		eskipDollar = eskipS[eskippt-3 : eskippt+1]
+		{
+			eskipVAL.args = eskipDollar[1].args
+			eskipVAL.args = append(eskipVAL.args, eskipDollar[3].arg)
+		}
+	case 23:
+		eskipDollar = eskipS[eskippt-1 : eskippt+1]
+		{
+			eskipVAL.arg = eskipDollar[1].arg
+		}
+	case 24:
+		eskipDollar = eskipS[eskippt-1 : eskippt+1]
+		{
+			// nested predicates, used by the Or, And and Not compositions
+			eskipVAL.arg = eskipDollar[1].predicate
+		}
+	case 25:
 		eskipDollar = eskipS[eskippt-1 : eskippt+1]
 		{
 			eskipVAL.filters = []*Filter{eskipDollar[1].filter}
 		}
-	case 21:
+	case 26:
 		eskipDollar = eskipS[eskippt-3 : eskippt+1]
 		{
 			eskipVAL.filters = eskipDollar[1].filters
 			eskipVAL.filters = append(eskipVAL.filters, eskipDollar[3].filter)
 		}
-	case 22:
+	case 27:
 		eskipDollar = eskipS[eskippt-4 : eskippt+1]
 		{
 			eskipVAL.filter = &Filter{
@@ -645,61 +670,61 @@ eskipdefault:
 				Args: eskipDollar[3].args}
 			eskipDollar[3].args = nil
 		}
-	case 24:
+	case 29:
 		eskipDollar = eskipS[eskippt-1 : eskippt+1]
 		{
 			eskipVAL.args = []interface{}{eskipDollar[1].arg}
 		}
-	case 25:
+	case 30:
 		eskipDollar = eskipS[eskippt-3 : eskippt+1]
 		{
 			eskipVAL.args = eskipDollar[1].args
 			eskipVAL.args = append(eskipVAL.args, eskipDollar[3].arg)
 		}
-	case 26:
+	case 31:
 		eskipDollar = eskipS[eskippt-1 : eskippt+1]
 		{
 			eskipVAL.arg = eskipDollar[1].numval
 		}
-	case 27:
+	case 32:
 		eskipDollar = eskipS[eskippt-1 : eskippt+1]
 		{
 			eskipVAL.arg = eskipDollar[1].token
 		}
-	case 28:
+	case 33:
 		eskipDollar = eskipS[eskippt-1 : eskippt+1]
 		{
 			eskipVAL.arg = eskipDollar[1].token
 		}
-	case 29:
+	case 34:
 		eskipDollar = eskipS[eskippt-1 : eskippt+1]
 		{
 			eskipVAL.stringvals = []string{eskipDollar[1].token}
 		}
-	case 30:
+	case 35:
 		eskipDollar = eskipS[eskippt-3 : eskippt+1]
 		{
 			eskipVAL.stringvals = eskipDollar[1].stringvals
 			eskipVAL.stringvals = append(eskipVAL.stringvals, eskipDollar[3].token)
 		}
-	case 31:
+	case 36:
 		eskipDollar = eskipS[eskippt-1 : eskippt+1]
 		{
 			eskipVAL.lbEndpoints = eskipDollar[1].stringvals
 		}
-	case 32:
+	case 37:
 		eskipDollar = eskipS[eskippt-3 : eskippt+1]
 		{
 			eskipVAL.lbAlgorithm = eskipDollar[1].token
 			eskipVAL.lbEndpoints = eskipDollar[3].stringvals
 		}
-	case 33:
+	case 38:
 		eskipDollar = eskipS[eskippt-3 : eskippt+1]
 		{
 			eskipVAL.lbAlgorithm = eskipDollar[2].lbAlgorithm
 			eskipVAL.lbEndpoints = eskipDollar[2].lbEndpoints
 		}
-	case 34:
+	case 39:
 		eskipDollar = eskipS[eskippt-1 : eskippt+1]
 		{
 			eskipVAL.backend = eskipDollar[1].token
@@ -708,7 +733,7 @@ eskipdefault:
 			eskipVAL.dynamic = false
 			eskipVAL.lbBackend = false
 		}
-	case 35:
+	case 40:
 		eskipDollar = eskipS[eskippt-1 : eskippt+1]
 		{
 			eskipVAL.shunt = true
@@ -716,7 +741,7 @@ eskipdefault:
 			eskipVAL.dynamic = false
 			eskipVAL.lbBackend = false
 		}
-	case 36:
+	case 41:
 		eskipDollar = eskipS[eskippt-1 : eskippt+1]
 		{
 			eskipVAL.shunt = false
@@ -724,7 +749,7 @@ eskipdefault:
 			eskipVAL.dynamic = false
 			eskipVAL.lbBackend = false
 		}
-	case 37:
+	case 42:
 		eskipDollar = eskipS[eskippt-1 : eskippt+1]
 		{
 			eskipVAL.shunt = false
@@ -732,7 +757,7 @@ eskipdefault:
 			eskipVAL.dynamic = true
 			eskipVAL.lbBackend = false
 		}
-	case 38:
+	case 43:
 		eskipDollar = eskipS[eskippt-1 : eskippt+1]
 		{
 			eskipVAL.shunt = false
@@ -742,7 +767,7 @@ eskipdefault:
 			eskipVAL.lbAlgorithm = eskipDollar[1].lbAlgorithm
 			eskipVAL.lbEndpoints = eskipDollar[1].lbEndpoints
 		}
-	case 39:
+	case 44:
 		eskipDollar = eskipS[eskippt-1 : eskippt+1]
 		{
 			eskipVAL.numval = convertNumber(eskipDollar[1].token)
@@ -178,11 +178,32 @@ predicate:
 		$$.predicate = &Predicate{"*", nil}
 	}
 	|
-	symbol openparen args closeparen {
+	symbol openparen predicateargs closeparen {
 		$$.predicate = &Predicate{$1.token, $3.args}
 		$3.args = nil
 	}
 
+predicateargs:
+	|
+	predicatearg {
+		$$.args = []interface{}{$1.arg}
+	}
+	|
+	predicateargs comma predicatearg {
+		$$.args = $1.args
+		$$.args = append($$.args, $3.arg)
+	}
+
+predicatearg:
+	arg {
+		$$.arg = $1.arg
+	}
+	|
+	predicate {
+		// nested predicates, used by the Or, And and Not compositions
+		$$.arg = $1.predicate
+	}
+
 filters:
 	filter {
 		$$.filters = []*Filter{$1.filter}
@@ -62,6 +62,8 @@ func argsString(args []interface{}) string {
 			sargs = appendFmt(sargs, f, a)
 		case string:
 			sargs = appendFmtEscape(sargs, `"%s"`, `"`, a)
+		case *Predicate:
+			sargs = append(sargs, v.String())
 		default:
 			if m, ok := a.(interface{ MarshalText() ([]byte, error) }); ok {
 				t, err := m.MarshalText()
@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/zalando/skipper/filters"
+	graphqlfilters "github.com/zalando/skipper/filters/graphql"
 	"github.com/zalando/skipper/jwt"
 )
 
@@ -63,7 +64,15 @@ func (f *apiUsageMonitoringFilter) Response(c filters.FilterContext) {
 	request, response, metrics := c.Request(), c.Response(), c.Metrics()
 	stateBag, stateBagPresent := c.StateBag()[stateBagKey].(apiUsageMonitoringStateBag)
 	path := f.UnknownPath
-	if stateBagPresent && stateBag.url != nil {
+	if op := graphqlfilters.GetRequest(c); op != nil && op.OperationName != "" {
+		if stateBagPresent {
+			path = f.resolveGraphQLPath(stateBag.url, op.OperationName)
+		}
+		if path == f.UnknownPath {
+			path = f.resolveGraphQLPath(request.URL, op.OperationName)
+		}
+	}
+	if path == f.UnknownPath && stateBagPresent && stateBag.url != nil {
 		path = f.resolveMatchedPath(stateBag.url)
 	}
 	if path == f.UnknownPath {
@@ -166,7 +175,21 @@ func (f *apiUsageMonitoringFilter) getRealmClientKey(r *http.Request, path *path
 func (f *apiUsageMonitoringFilter) resolveMatchedPath(u *url.URL) *pathInfo {
 	if u != nil {
 		for _, p := range f.Paths {
-			if p.Matcher.MatchString(u.Path) {
+			if p.Operation == "" && p.Matcher.MatchString(u.Path) {
+				return p
+			}
+		}
+	}
+	return f.UnknownPath
+}
+
+// resolveGraphQLPath tries to match the request's path and the name of
+// the GraphQL operation with one of the configured operation path
+// templates.
+func (f *apiUsageMonitoringFilter) resolveGraphQLPath(u *url.URL, operationName string) *pathInfo {
+	if u != nil {
+		for _, p := range f.Paths {
+			if p.Operation == operationName && p.Matcher.MatchString(u.Path) {
 				return p
 			}
 		}
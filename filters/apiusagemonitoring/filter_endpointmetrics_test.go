@@ -3,6 +3,7 @@ package apiusagemonitoring
 import (
 	"fmt"
 	"net/http"
+	"net/url"
 	"strings"
 	"testing"
 	"time"
@@ -10,6 +11,8 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/zalando/skipper/filters"
 	"github.com/zalando/skipper/filters/filtertest"
+	graphqlfilters "github.com/zalando/skipper/filters/graphql"
+	"github.com/zalando/skipper/graphql"
 	"github.com/zalando/skipper/metrics/metricstest"
 )
 
@@ -408,3 +411,59 @@ func Test_Filter_PathTemplateMatchesPathFromRequestChain(t *testing.T) {
 		})
 	}
 }
+
+func Test_Filter_GraphQLOperation(t *testing.T) {
+	graphqlFilter, err := graphqlfilters.NewMetrics(graphql.Options{}).CreateFilter(nil)
+	assert.NoError(t, err)
+
+	createFilter := func() (filters.Filter, error) {
+		spec := NewApiUsageMonitoring(true, "", "", "")
+		return spec.CreateFilter([]interface{}{`{
+			"application_id": "my_app",
+			"tag": "my_tag",
+			"api_id": "my_api",
+			"path_templates": [
+				"foo/orders",
+				"foo/orders/:order-id",
+				"foo/orders#GetOrder",
+				"foo/orders#invalid-name"
+			]
+		}`})
+	}
+
+	for _, tt := range []struct {
+		query  string
+		expect string
+	}{{
+		query:  "query GetOrder { order { id } }",
+		expect: "foo/orders#GetOrder",
+	}, {
+		// the operation name is not resolved as a path segment
+		query:  "query GetOrders { orders { id } }",
+		expect: "foo/orders",
+	}} {
+		t.Run(tt.expect, func(t *testing.T) {
+			testWithFilterModifyContext(
+				t,
+				createFilter,
+				http.MethodGet,
+				"https://www.example.org/foo/orders?query="+url.QueryEscape(tt.query),
+				200,
+				func(ctx *filtertest.Context) {
+					graphqlFilter.Request(ctx)
+				},
+				func(pass int, m *metricstest.MockMetrics) {
+					pre := "apiUsageMonitoring.custom.my_app.my_tag.my_api.GET." + tt.expect + ".*.*."
+					m.WithCounters(func(counters map[string]int64) {
+						assert.Equal(t,
+							map[string]int64{
+								pre + "http_count":    int64(pass),
+								pre + "http2xx_count": int64(pass),
+							},
+							counters,
+						)
+					})
+				})
+		})
+	}
+}
@@ -13,6 +13,7 @@ type pathInfo struct {
 	Tag            string
 	ApiId          string
 	PathTemplate   string
+	Operation      string
 	Matcher        *regexp.Regexp
 	ClientTracking *clientTrackingInfo
 	CommonPrefix   string
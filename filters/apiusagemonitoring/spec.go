@@ -22,11 +22,16 @@ const (
 	unknownPlaceholder = "{unknown}"
 	noMatchPlaceholder = "{no-match}"
 	noTagPlaceholder   = "{no-tag}"
+
+	// separates the path and the GraphQL operation name in the path
+	// templates, e.g. graphql#GetOrders
+	operationSeparator = "#"
 )
 
 var (
-	log      = logrus.WithField("filter", filters.ApiUsageMonitoringName)
-	regCache = sync.Map{}
+	log           = logrus.WithField("filter", filters.ApiUsageMonitoringName)
+	regCache      = sync.Map{}
+	graphqlNameRx = regexp.MustCompile("^[_A-Za-z][_0-9A-Za-z]*$")
 )
 
 func loadOrCompileRegex(pattern string) (*regexp.Regexp, error) {
@@ -288,12 +293,25 @@ func (s *apiUsageMonitoringSpec) buildPathInfoListFromConfiguration(apis []*apiC
 				continue
 			}
 
+			// Split the GraphQL operation name, if any
+			template, operation, isOperation := strings.Cut(template, operationSeparator)
+			if isOperation && !graphqlNameRx.MatchString(operation) {
+				s.warnf(
+					"args[%d].path_templates[%d] ignored: invalid GraphQL operation name %q",
+					apiIndex, templateIndex, operation)
+				continue
+			}
+
 			// Normalize path template and get regular expression path pattern
 			pathTemplate := s.pathHandler.normalizePathTemplate(template)
 			pathPattern := s.pathHandler.createPathPattern(template)
+			if isOperation {
+				pathTemplate += operationSeparator + operation
+			}
 
 			// Create new `pathInfo` with normalized PathTemplate
 			info := newPathInfo(applicationId, api.Tag, apiId, pathTemplate, clientTrackingInfo)
+			info.Operation = operation
 
 			// Detect path template duplicates
 			if _, ok := existingPathTemplates[info.PathTemplate]; ok {
@@ -305,13 +323,14 @@ func (s *apiUsageMonitoringSpec) buildPathInfoListFromConfiguration(apis []*apiC
 			existingPathTemplates[info.PathTemplate] = info
 
 			// Detect regular expression duplicates
-			if existingMatcher, ok := existingPathPattern[pathPattern]; ok {
+			patternKey := pathPattern + operationSeparator + operation
+			if existingMatcher, ok := existingPathPattern[patternKey]; ok {
 				s.warnf(
 					"args[%d].path_templates[%d] ignored: two path templates yielded the same regular expression %q (%q and %q)",
 					apiIndex, templateIndex, pathPattern, info.PathTemplate, existingMatcher.PathTemplate)
 				continue
 			}
-			existingPathPattern[pathPattern] = info
+			existingPathPattern[patternKey] = info
 
 			pathPatternMatcher, err := loadOrCompileRegex(pathPattern)
 			if err != nil {
@@ -66,6 +66,13 @@ func (m *RouteCreationMetrics) Do(routes []*routing.Route) []*routing.Route {
 	return m.reportRouteCreationTimes(routes)
 }
 
+// DoRollout implements routing.RolloutPostProcessor. The creation time
+// is recorded only when the routes under staged rollout are promoted.
+func (m *RouteCreationMetrics) DoRollout(routes []*routing.Route) []*routing.Route {
+	removeOriginMarkers(routes)
+	return routes
+}
+
 func (m *RouteCreationMetrics) reportRouteCreationTimes(routes []*routing.Route) []*routing.Route {
 	for _, r := range routes {
 		for origin, start := range m.startTimes(r) {
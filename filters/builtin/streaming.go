@@ -0,0 +1,55 @@
+package builtin
+
+import (
+	"time"
+
+	"github.com/zalando/skipper/filters"
+)
+
+type streaming struct {
+	idleTimeout time.Duration
+}
+
+// NewStreaming creates a filter spec for the streaming() filter. The
+// filter makes the proxy serve the response in streaming mode, see
+// filters.Streaming. The optional parameter sets the idle timeout
+// between the events, as a duration string or a number of seconds.
+func NewStreaming() filters.Spec { return &streaming{} }
+
+func (*streaming) Name() string { return filters.StreamingName }
+
+func (*streaming) CreateFilter(args []interface{}) (filters.Filter, error) {
+	switch len(args) {
+	case 0:
+		return &streaming{}, nil
+	case 1:
+	default:
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	var d time.Duration
+	switch v := args[0].(type) {
+	case string:
+		var err error
+		d, err = time.ParseDuration(v)
+		if err != nil {
+			return nil, err
+		}
+	case float64:
+		d = time.Duration(v * float64(time.Second))
+	default:
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	if d <= 0 {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	return &streaming{idleTimeout: d}, nil
+}
+
+func (s *streaming) Request(ctx filters.FilterContext) {
+	ctx.StateBag()[filters.Streaming] = s.idleTimeout
+}
+
+func (*streaming) Response(filters.FilterContext) {}
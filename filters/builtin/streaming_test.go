@@ -0,0 +1,46 @@
+package builtin
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/filters/filtertest"
+)
+
+func TestStreamingFilter(t *testing.T) {
+	spec := NewStreaming()
+	assert.Equal(t, filters.StreamingName, spec.Name())
+
+	for _, tc := range []struct {
+		args     []interface{}
+		expected time.Duration
+	}{
+		{nil, 0},
+		{[]interface{}{"30s"}, 30 * time.Second},
+		{[]interface{}{60.0}, time.Minute},
+		{[]interface{}{0.5}, 500 * time.Millisecond},
+	} {
+		f, err := spec.CreateFilter(tc.args)
+		require.NoError(t, err)
+
+		ctx := &filtertest.Context{FStateBag: map[string]interface{}{}}
+		f.Request(ctx)
+		assert.Equal(t, tc.expected, ctx.FStateBag[filters.Streaming])
+	}
+
+	for _, args := range [][]interface{}{
+		{"foo"},
+		{"0s"},
+		{0.0},
+		{-1.0},
+		{time.Minute},
+		{"1s", "2s"},
+	} {
+		_, err := spec.CreateFilter(args)
+		assert.Error(t, err, "%v", args)
+	}
+}
@@ -168,6 +168,25 @@ func NewPostProcessor(options PostProcessorOptions) routing.PostProcessor {
 	}
 }
 
+// setFadeIn sets the fade-in settings of the route from its filters,
+// and returns the creation time of the endpoints.
+func setFadeIn(ri *routing.Route) map[string]time.Time {
+	ri.LBFadeInDuration = 0
+	ri.LBFadeInExponent = 1
+	endpointsCreated := make(map[string]time.Time)
+	for _, f := range ri.Filters {
+		switch fi := f.Filter.(type) {
+		case fadeIn:
+			ri.LBFadeInDuration = fi.duration
+			ri.LBFadeInExponent = fi.exponent
+		case endpointCreated:
+			endpointsCreated[fi.which] = fi.when
+		}
+	}
+
+	return endpointsCreated
+}
+
 func (p *postProcessor) Do(r []*routing.Route) []*routing.Route {
 	now := time.Now()
 
@@ -176,19 +195,7 @@ func (p *postProcessor) Do(r []*routing.Route) []*routing.Route {
 			continue
 		}
 
-		ri.LBFadeInDuration = 0
-		ri.LBFadeInExponent = 1
-		endpointsCreated := make(map[string]time.Time)
-		for _, f := range ri.Filters {
-			switch fi := f.Filter.(type) {
-			case fadeIn:
-				ri.LBFadeInDuration = fi.duration
-				ri.LBFadeInExponent = fi.exponent
-			case endpointCreated:
-				endpointsCreated[fi.which] = fi.when
-			}
-		}
-
+		endpointsCreated := setFadeIn(ri)
 		if ri.LBFadeInDuration <= 0 {
 			continue
 		}
@@ -226,3 +233,16 @@ func (p *postProcessor) Do(r []*routing.Route) []*routing.Route {
 
 	return r
 }
+
+// DoRollout implements routing.RolloutPostProcessor. It sets the fade-in
+// settings of the routes under staged rollout, without detecting their
+// endpoints.
+func (p *postProcessor) DoRollout(r []*routing.Route) []*routing.Route {
+	for _, ri := range r {
+		if ri.Route.BackendType == eskip.LBBackend {
+			setFadeIn(ri)
+		}
+	}
+
+	return r
+}
@@ -33,8 +33,16 @@ const (
 	// WriteTimeout is the key used in the state bag to configure write response body timeout in proxy
 	WriteTimeout = "write:timeout"
 
+	// Streaming is the key used in the state bag to serve the response in streaming mode, with
+	// the time.Duration idle timeout between the events, zero for the default of the proxy
+	Streaming = "streaming"
+
 	// BackendRatelimit is the key used in the state bag to configure backend ratelimit in proxy
 	BackendRatelimit = "backend:ratelimit"
+
+	// WebSocketMessageHandlers is the key used in the state bag to pass the
+	// []websocket.MessageHandler of the upgraded WebSocket connections to the proxy
+	WebSocketMessageHandlers = "websocket:messagehandlers"
 )
 
 // FilterContext object providing state and information that is unique to a request.
@@ -255,6 +263,7 @@ const (
 	BackendTimeoutName                         = "backendTimeout"
 	ReadTimeoutName                            = "readTimeout"
 	WriteTimeoutName                           = "writeTimeout"
+	StreamingName                              = "streaming"
 	BlockName                                  = "blockContent"
 	BlockHexName                               = "blockContentHex"
 	LatencyName                                = "latency"
@@ -317,6 +326,7 @@ const (
 	RateBreakerName                            = "rateBreaker"
 	DisableBreakerName                         = "disableBreaker"
 	AdmissionControlName                       = "admissionControl"
+	AdaptiveConcurrencyName                    = "adaptiveConcurrency"
 	ClientRatelimitName                        = "clientRatelimit"
 	RatelimitName                              = "ratelimit"
 	ClusterClientRatelimitName                 = "clusterClientRatelimit"
@@ -341,6 +351,8 @@ const (
 	ApiUsageMonitoringName                     = "apiUsageMonitoring"
 	FifoName                                   = "fifo"
 	FifoWithBodyName                           = "fifoWithBody"
+	FairFifoName                               = "fairFifo"
+	PriorityName                               = "priority"
 	LifoName                                   = "lifo"
 	LifoGroupName                              = "lifoGroup"
 	RfcPathName                                = "rfcPath"
@@ -364,6 +376,13 @@ const (
 	OpaServeResponseWithReqBodyName            = "opaServeResponseWithReqBody"
 	TLSName                                    = "tlsPassClientCertificates"
 	AWSSigV4Name                               = "awsSigv4"
+	GeoHeadersName                             = "geoHeaders"
+	IPDenyListName                             = "ipDenyList"
+	IPAllowListName                            = "ipAllowList"
+	GraphQLLimitsName                          = "graphqlLimits"
+	GraphQLPersistedQueriesName                = "graphqlPersistedQueries"
+	GraphQLMetricsName                         = "graphqlMetrics"
+	GRPCWebName                                = "grpcWeb"
 
 	// Undocumented filters
 	HealthCheckName        = "healthcheck"
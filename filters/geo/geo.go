@@ -0,0 +1,70 @@
+/*
+Package geo implements the geoHeaders filter, that passes the country and
+the autonomous system of the client IP to the backend in request headers.
+*/
+package geo
+
+import (
+	"strconv"
+
+	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/net/geoip"
+)
+
+const (
+	CountryHeader        = "X-Geo-Country"
+	ASNHeader            = "X-Geo-Asn"
+	ASOrganizationHeader = "X-Geo-As-Organization"
+)
+
+type (
+	spec struct {
+		resolver *geoip.Resolver
+	}
+
+	filter struct {
+		resolver *geoip.Resolver
+	}
+)
+
+// NewHeaders creates the geoHeaders filter specification. The filter
+// sets the X-Geo-Country, X-Geo-Asn and X-Geo-As-Organization request
+// headers to the values found for the client IP. The headers sent by the
+// client are removed, also when no value is found.
+//
+// Eskip example:
+//
+//   - -> geoHeaders() -> "https://www.example.org";
+func NewHeaders(r *geoip.Resolver) filters.Spec { return &spec{resolver: r} }
+
+func (*spec) Name() string { return filters.GeoHeadersName }
+
+func (s *spec) CreateFilter(args []interface{}) (filters.Filter, error) {
+	if len(args) != 0 {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	return &filter{resolver: s.resolver}, nil
+}
+
+func (f *filter) Request(ctx filters.FilterContext) {
+	req := ctx.Request()
+	req.Header.Del(CountryHeader)
+	req.Header.Del(ASNHeader)
+	req.Header.Del(ASOrganizationHeader)
+
+	rec := f.resolver.LookupRequest(req)
+	if rec.Country != "" {
+		req.Header.Set(CountryHeader, rec.Country)
+	}
+
+	if rec.ASN != 0 {
+		req.Header.Set(ASNHeader, strconv.FormatUint(uint64(rec.ASN), 10))
+	}
+
+	if rec.ASOrganization != "" {
+		req.Header.Set(ASOrganizationHeader, rec.ASOrganization)
+	}
+}
+
+func (*filter) Response(filters.FilterContext) {}
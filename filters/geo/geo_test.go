@@ -0,0 +1,55 @@
+package geo
+
+import (
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zalando/skipper/filters/filtertest"
+	"github.com/zalando/skipper/net/geoip"
+	"github.com/zalando/skipper/net/geoip/geoiptest"
+)
+
+func TestGeoHeaders(t *testing.T) {
+	db := filepath.Join(t.TempDir(), "test.mmdb")
+	require.NoError(t, geoiptest.Write(db, map[string]map[string]interface{}{
+		"1.2.3.0/24": {
+			"country":                        map[string]interface{}{"iso_code": "DE"},
+			"autonomous_system_number":       uint(3320),
+			"autonomous_system_organization": "Deutsche Telekom AG",
+		},
+	}))
+
+	r, err := geoip.New(geoip.Options{Databases: []string{db}, ClientIPSource: "ClientIP"})
+	require.NoError(t, err)
+	defer r.Close()
+
+	spec := NewHeaders(r)
+	_, err = spec.CreateFilter([]interface{}{"foo"})
+	assert.Error(t, err)
+
+	f, err := spec.CreateFilter(nil)
+	require.NoError(t, err)
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "1.2.3.4:1234"
+	req.Header.Set(CountryHeader, "XX")
+	f.Request(&filtertest.Context{FRequest: req})
+
+	assert.Equal(t, "DE", req.Header.Get(CountryHeader))
+	assert.Equal(t, "3320", req.Header.Get(ASNHeader))
+	assert.Equal(t, "Deutsche Telekom AG", req.Header.Get(ASOrganizationHeader))
+
+	req = httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "5.6.7.8:1234"
+	req.Header.Set(CountryHeader, "XX")
+	req.Header.Set(ASNHeader, "1")
+	f.Request(&filtertest.Context{FRequest: req})
+
+	assert.Empty(t, req.Header.Values(CountryHeader))
+	assert.Empty(t, req.Header.Values(ASNHeader))
+	assert.Empty(t, req.Header.Values(ASOrganizationHeader))
+}
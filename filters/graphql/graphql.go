@@ -0,0 +1,370 @@
+/*
+Package graphql implements filters to limit and measure GraphQL requests
+by their operations.
+
+The graphqlLimits filter rejects the operations exceeding the configured
+depth, complexity or alias count. The graphqlPersistedQueries filter
+accepts only the operations whose hashes are allow-listed. The
+graphqlMetrics filter measures the requests per operation.
+
+All the filters store the parsed GraphQL request in the state bag, and the
+apiUsageMonitoring filter uses its operation name to resolve the path
+templates, see GetRequest.
+*/
+package graphql
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/graphql"
+)
+
+const (
+	stateBagKey          = "filter.graphql"
+	startTimeStateBagKey = "filter.graphql.start"
+
+	unknownPlaceholder   = "{unknown}"
+	anonymousPlaceholder = "{anonymous}"
+	otherPlaceholder     = "{other}"
+
+	// maxOperationNames limits the number of the operation names measured
+	// by the graphqlMetrics filters without arguments
+	maxOperationNames = 100
+)
+
+type (
+	limitsSpec struct {
+		options graphql.Options
+	}
+
+	persistedQueriesSpec struct {
+		options graphql.Options
+	}
+
+	metricsSpec struct {
+		options graphql.Options
+		names   *operationNames
+	}
+
+	// operationNames collects the operation names sent by the clients,
+	// shared by the graphqlMetrics filters without arguments
+	operationNames struct {
+		mu    sync.Mutex
+		max   int
+		names atomic.Pointer[map[string]struct{}]
+	}
+
+	limitsFilter struct {
+		options       graphql.Options
+		maxDepth      int
+		maxComplexity int
+		maxAliases    int
+	}
+
+	persistedQueriesFilter struct {
+		options graphql.Options
+		hashes  map[string]struct{}
+	}
+
+	metricsFilter struct {
+		options    graphql.Options
+		operations map[string]struct{}
+		names      *operationNames
+	}
+)
+
+// NewLimits creates the graphqlLimits filter specification. The filter
+// rejects the operations whose depth, complexity or alias count exceeds
+// the limits, see graphql.Stats. Zero disables a limit. The requests that
+// don't contain a GraphQL operation, and the persisted queries sent
+// without the query, are not checked.
+//
+// Example:
+//
+//	Path("/graphql") -> graphqlLimits(10, 500, 20) -> "https://graphql.example.org";
+func NewLimits(o graphql.Options) filters.Spec { return &limitsSpec{options: o} }
+
+// NewPersistedQueries creates the graphqlPersistedQueries filter
+// specification. The filter accepts only the GraphQL requests whose
+// SHA-256 query hash, either sent as the persisted query hash or
+// calculated from the query, is one of the arguments.
+//
+// Example:
+//
+//	Path("/graphql") -> graphqlPersistedQueries("ecf4edb46db40b5132295c0291d62fb65d6759a9eedfa4d5d612dd5ec54a6b38") -> "https://graphql.example.org";
+func NewPersistedQueries(o graphql.Options) filters.Spec { return &persistedQueriesSpec{options: o} }
+
+// NewMetrics creates the graphqlMetrics filter specification. The filter
+// measures the count and the latency of the requests per operation type
+// and name. Without arguments, it measures the first 100 operation names
+// sent by the clients, otherwise only the listed ones, and the rest as
+// {other}.
+//
+// Example:
+//
+//	Path("/graphql") -> graphqlMetrics("GetUser", "GetOrders") -> "https://graphql.example.org";
+func NewMetrics(o graphql.Options) filters.Spec {
+	return &metricsSpec{options: o, names: newOperationNames(maxOperationNames)}
+}
+
+func newOperationNames(max int) *operationNames {
+	n := &operationNames{max: max}
+	n.names.Store(&map[string]struct{}{})
+	return n
+}
+
+// allow tells whether the operation name is measured by name. The names
+// are copied on write, so the known names are checked without locking.
+func (n *operationNames) allow(name string) bool {
+	if _, ok := (*n.names.Load())[name]; ok {
+		return true
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	names := *n.names.Load()
+	if _, ok := names[name]; ok {
+		return true
+	}
+
+	if len(names) >= n.max {
+		return false
+	}
+
+	updated := make(map[string]struct{}, len(names)+1)
+	for k := range names {
+		updated[k] = struct{}{}
+	}
+
+	updated[name] = struct{}{}
+	n.names.Store(&updated)
+	return true
+}
+
+// GetRequest returns the GraphQL request parsed by the graphql filters of
+// the route, or nil, when the request doesn't contain a GraphQL
+// operation.
+func GetRequest(ctx filters.FilterContext) *graphql.Request {
+	r, _ := ctx.StateBag()[stateBagKey].(*graphql.Request)
+	return r
+}
+
+func parse(ctx filters.FilterContext, o graphql.Options) (*graphql.Request, error) {
+	r, err := graphql.FromHTTP(ctx.Request(), o)
+	if err == nil {
+		ctx.StateBag()[stateBagKey] = r
+	}
+
+	return r, err
+}
+
+func serveError(ctx filters.FilterContext, status int, message string) {
+	b, _ := json.Marshal(map[string]interface{}{
+		"errors": []map[string]string{{"message": message}},
+	})
+
+	ctx.Serve(&http.Response{
+		StatusCode: status,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(bytes.NewReader(b)),
+	})
+}
+
+// serveParseError responds to the requests that cannot be parsed, and
+// returns false when the request doesn't contain a GraphQL operation.
+func serveParseError(ctx filters.FilterContext, err error) bool {
+	switch {
+	case errors.Is(err, graphql.ErrNotGraphQL):
+		return false
+	case errors.Is(err, graphql.ErrBodyTooLarge):
+		serveError(ctx, http.StatusRequestEntityTooLarge, err.Error())
+	default:
+		serveError(ctx, http.StatusBadRequest, err.Error())
+	}
+
+	return true
+}
+
+func intArg(a interface{}) (int, bool) {
+	f, ok := a.(float64)
+	if !ok || f < 0 || f != float64(int(f)) {
+		return 0, false
+	}
+
+	return int(f), true
+}
+
+func (*limitsSpec) Name() string { return filters.GraphQLLimitsName }
+
+func (s *limitsSpec) CreateFilter(args []interface{}) (filters.Filter, error) {
+	if len(args) == 0 || len(args) > 3 {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	limits := make([]int, 3)
+	for i, a := range args {
+		l, ok := intArg(a)
+		if !ok {
+			return nil, filters.ErrInvalidFilterParameters
+		}
+
+		limits[i] = l
+	}
+
+	return &limitsFilter{
+		options:       s.options,
+		maxDepth:      limits[0],
+		maxComplexity: limits[1],
+		maxAliases:    limits[2],
+	}, nil
+}
+
+func (f *limitsFilter) Request(ctx filters.FilterContext) {
+	r, err := parse(ctx, f.options)
+	if err != nil {
+		serveParseError(ctx, err)
+		return
+	}
+
+	stats, err := r.Stats()
+	if err != nil {
+		serveError(ctx, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var exceeded string
+	switch {
+	case f.maxDepth > 0 && stats.Depth > f.maxDepth:
+		exceeded = fmt.Sprintf("query depth %d exceeds the maximum of %d", stats.Depth, f.maxDepth)
+	case f.maxComplexity > 0 && stats.Complexity > f.maxComplexity:
+		exceeded = fmt.Sprintf("query complexity %d exceeds the maximum of %d", stats.Complexity, f.maxComplexity)
+	case f.maxAliases > 0 && stats.Aliases > f.maxAliases:
+		exceeded = fmt.Sprintf("query alias count %d exceeds the maximum of %d", stats.Aliases, f.maxAliases)
+	default:
+		return
+	}
+
+	serveError(ctx, http.StatusBadRequest, exceeded)
+}
+
+func (*limitsFilter) Response(filters.FilterContext) {}
+
+func (*persistedQueriesSpec) Name() string { return filters.GraphQLPersistedQueriesName }
+
+func (s *persistedQueriesSpec) CreateFilter(args []interface{}) (filters.Filter, error) {
+	if len(args) == 0 {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	hashes := make(map[string]struct{}, len(args))
+	for _, a := range args {
+		h, ok := a.(string)
+		if !ok || h == "" {
+			return nil, filters.ErrInvalidFilterParameters
+		}
+
+		hashes[strings.ToLower(h)] = struct{}{}
+	}
+
+	return &persistedQueriesFilter{options: s.options, hashes: hashes}, nil
+}
+
+func (f *persistedQueriesFilter) Request(ctx filters.FilterContext) {
+	r, err := parse(ctx, f.options)
+	if err != nil {
+		if !serveParseError(ctx, err) {
+			serveError(ctx, http.StatusForbidden, "persisted query required")
+		}
+
+		return
+	}
+
+	if r.Query != "" && r.PersistedQueryHash != "" && !strings.EqualFold(r.Hash(), r.PersistedQueryHash) {
+		serveError(ctx, http.StatusBadRequest, "persisted query hash mismatch")
+		return
+	}
+
+	if _, ok := f.hashes[strings.ToLower(r.Hash())]; !ok {
+		serveError(ctx, http.StatusForbidden, "persisted query not allowed")
+	}
+}
+
+func (*persistedQueriesFilter) Response(filters.FilterContext) {}
+
+func (*metricsSpec) Name() string { return filters.GraphQLMetricsName }
+
+func (s *metricsSpec) CreateFilter(args []interface{}) (filters.Filter, error) {
+	f := &metricsFilter{options: s.options}
+	if len(args) > 0 {
+		f.operations = make(map[string]struct{}, len(args))
+	} else {
+		f.names = s.names
+	}
+
+	for _, a := range args {
+		name, ok := a.(string)
+		if !ok || name == "" {
+			return nil, filters.ErrInvalidFilterParameters
+		}
+
+		f.operations[name] = struct{}{}
+	}
+
+	return f, nil
+}
+
+// HandleErrorResponse opts in to measure the failed requests, too.
+func (*metricsFilter) HandleErrorResponse() bool { return true }
+
+func (f *metricsFilter) Request(ctx filters.FilterContext) {
+	if _, err := parse(ctx, f.options); err == nil {
+		ctx.StateBag()[startTimeStateBagKey] = time.Now()
+	}
+}
+
+func (f *metricsFilter) metricsPrefix(r *graphql.Request) string {
+	operationType := string(r.OperationType())
+	if operationType == "" {
+		operationType = unknownPlaceholder
+	}
+
+	name := r.OperationName
+	switch {
+	case name == "":
+		name = anonymousPlaceholder
+	case f.operations != nil:
+		if _, ok := f.operations[name]; !ok {
+			name = otherPlaceholder
+		}
+	case !f.names.allow(name):
+		name = otherPlaceholder
+	}
+
+	return "graphql." + operationType + "." + name + "."
+}
+
+func (f *metricsFilter) Response(ctx filters.FilterContext) {
+	start, ok := ctx.StateBag()[startTimeStateBagKey].(time.Time)
+	r := GetRequest(ctx)
+	if !ok || r == nil {
+		return
+	}
+
+	prefix := f.metricsPrefix(r)
+	ctx.Metrics().IncCounter(prefix + "requests")
+	ctx.Metrics().MeasureSince(prefix+"latency", start)
+	if rsp := ctx.Response(); rsp != nil && rsp.StatusCode >= 500 {
+		ctx.Metrics().IncCounter(prefix + "errors")
+	}
+}
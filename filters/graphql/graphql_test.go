@@ -0,0 +1,240 @@
+package graphql
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/filters/filtertest"
+	"github.com/zalando/skipper/graphql"
+	"github.com/zalando/skipper/metrics/metricstest"
+)
+
+func newContext(contentType, body string) *filtertest.Context {
+	req := httptest.NewRequest("POST", "/graphql", strings.NewReader(body))
+	req.Header.Set("Content-Type", contentType)
+	return &filtertest.Context{
+		FRequest:  req,
+		FStateBag: make(map[string]interface{}),
+		FMetrics:  &metricstest.MockMetrics{},
+	}
+}
+
+func errorMessage(t *testing.T, rsp *http.Response) string {
+	var body struct {
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+
+	require.Equal(t, "application/json", rsp.Header.Get("Content-Type"))
+	b, err := io.ReadAll(rsp.Body)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(b, &body))
+	require.Len(t, body.Errors, 1)
+	return body.Errors[0].Message
+}
+
+func TestCreateFilter(t *testing.T) {
+	for _, tt := range []struct {
+		spec filters.Spec
+		args []interface{}
+	}{
+		{NewLimits(graphql.Options{}), nil},
+		{NewLimits(graphql.Options{}), []interface{}{1.0, 2.0, 3.0, 4.0}},
+		{NewLimits(graphql.Options{}), []interface{}{-1.0}},
+		{NewLimits(graphql.Options{}), []interface{}{1.5}},
+		{NewLimits(graphql.Options{}), []interface{}{"10"}},
+		{NewPersistedQueries(graphql.Options{}), nil},
+		{NewPersistedQueries(graphql.Options{}), []interface{}{""}},
+		{NewPersistedQueries(graphql.Options{}), []interface{}{42.0}},
+		{NewMetrics(graphql.Options{}), []interface{}{""}},
+		{NewMetrics(graphql.Options{}), []interface{}{42.0}},
+	} {
+		_, err := tt.spec.CreateFilter(tt.args)
+		assert.ErrorIs(t, err, filters.ErrInvalidFilterParameters, "%s%v", tt.spec.Name(), tt.args)
+	}
+}
+
+func TestLimits(t *testing.T) {
+	for _, tt := range []struct {
+		name        string
+		args        []interface{}
+		contentType string
+		body        string
+		status      int
+		message     string
+	}{
+		{"within limits", []interface{}{3.0, 5.0, 1.0}, "application/graphql", `{ a { b { c } } x: d }`, 0, ""},
+		{"depth", []interface{}{2.0}, "application/graphql", `{ a { b { c } } }`, 400, "query depth 3 exceeds the maximum of 2"},
+		{"complexity", []interface{}{0.0, 3.0}, "application/graphql", `{ a { b { c } } d }`, 400, "query complexity 4 exceeds the maximum of 3"},
+		{"aliases", []interface{}{0.0, 0.0, 1.0}, "application/graphql", `{ a: x b: x }`, 400, "query alias count 2 exceeds the maximum of 1"},
+		{"fragments", []interface{}{2.0}, "application/graphql", `{ a { ...f } } fragment f on A { b { c } }`, 400, "query depth 3 exceeds the maximum of 2"},
+		{"undefined fragment", []interface{}{2.0}, "application/graphql", `{ a { ...f } }`, 400, `invalid graphql document: undefined fragment "f"`},
+		{"invalid", []interface{}{2.0}, "application/graphql", `{ a `, 400, "invalid graphql document at 4: unexpected end of document"},
+		{"not graphql", []interface{}{2.0}, "text/plain", `{ a { b { c } } }`, 0, ""},
+		{"persisted query", []interface{}{1.0}, "application/json", `{"extensions": {"persistedQuery": {"sha256Hash": "abc"}}}`, 0, ""},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := NewLimits(graphql.Options{}).CreateFilter(tt.args)
+			require.NoError(t, err)
+
+			ctx := newContext(tt.contentType, tt.body)
+			f.Request(ctx)
+			if tt.status == 0 {
+				assert.False(t, ctx.FServed)
+				return
+			}
+
+			require.True(t, ctx.FServed)
+			assert.Equal(t, tt.status, ctx.FResponse.StatusCode)
+			assert.Equal(t, tt.message, errorMessage(t, ctx.FResponse))
+		})
+	}
+}
+
+func TestLimitsBodyTooLarge(t *testing.T) {
+	f, err := NewLimits(graphql.Options{MaxBodySize: 4}).CreateFilter([]interface{}{1.0})
+	require.NoError(t, err)
+
+	ctx := newContext("application/graphql", `{ a b c }`)
+	f.Request(ctx)
+	require.True(t, ctx.FServed)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, ctx.FResponse.StatusCode)
+}
+
+func TestPersistedQueries(t *testing.T) {
+	const (
+		// echo -n '{ hello }' | sha256sum
+		hello      = "001c3174e099bd72b729d0c0a529ba9f5a740c446e2a6e1d71b283cb84ec3065"
+		helloUpper = "001C3174E099BD72B729D0C0A529BA9F5A740C446E2A6E1D71B283CB84EC3065"
+	)
+
+	f, err := NewPersistedQueries(graphql.Options{}).CreateFilter([]interface{}{helloUpper})
+	require.NoError(t, err)
+
+	for _, tt := range []struct {
+		name        string
+		contentType string
+		body        string
+		status      int
+	}{
+		{"hash", "application/json", `{"extensions": {"persistedQuery": {"version": 1, "sha256Hash": "` + hello + `"}}}`, 0},
+		{"query", "application/graphql", `{ hello }`, 0},
+		{"query and hash", "application/json", `{"query": "{ hello }", "extensions": {"persistedQuery": {"version": 1, "sha256Hash": "` + hello + `"}}}`, 0},
+		{"hash mismatch", "application/json", `{"query": "{ goodbye }", "extensions": {"persistedQuery": {"version": 1, "sha256Hash": "` + hello + `"}}}`, 400},
+		{"unknown hash", "application/json", `{"extensions": {"persistedQuery": {"version": 1, "sha256Hash": "abc"}}}`, 403},
+		{"unknown query", "application/graphql", `{ goodbye }`, 403},
+		{"not graphql", "text/plain", `{ hello }`, 403},
+		{"invalid", "application/graphql", `{ hello `, 400},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := newContext(tt.contentType, tt.body)
+			f.Request(ctx)
+			if tt.status == 0 {
+				assert.False(t, ctx.FServed)
+				return
+			}
+
+			require.True(t, ctx.FServed)
+			assert.Equal(t, tt.status, ctx.FResponse.StatusCode)
+		})
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	for _, tt := range []struct {
+		name        string
+		args        []interface{}
+		contentType string
+		body        string
+		status      int
+		prefix      string
+	}{
+		{"named", nil, "application/graphql", `query GetUser { user { id } }`, 200, "graphql.query.GetUser."},
+		{"anonymous", nil, "application/graphql", `mutation { deleteUser }`, 200, "graphql.mutation.{anonymous}."},
+		{"persisted", nil, "application/json", `{"operationName": "GetUser", "extensions": {"persistedQuery": {"sha256Hash": "abc"}}}`, 200, "graphql.{unknown}.GetUser."},
+		{"tracked", []interface{}{"GetUser"}, "application/graphql", `query GetUser { user { id } }`, 200, "graphql.query.GetUser."},
+		{"other", []interface{}{"GetUser"}, "application/graphql", `query GetOrder { order { id } }`, 200, "graphql.query.{other}."},
+		{"error", nil, "application/graphql", `query GetUser { user { id } }`, 502, "graphql.query.GetUser."},
+		{"not graphql", nil, "text/plain", `query GetUser { user { id } }`, 200, ""},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := NewMetrics(graphql.Options{}).CreateFilter(tt.args)
+			require.NoError(t, err)
+
+			ctx := newContext(tt.contentType, tt.body)
+			f.Request(ctx)
+			assert.False(t, ctx.FServed)
+
+			ctx.FResponse = &http.Response{StatusCode: tt.status}
+			f.Response(ctx)
+
+			m := ctx.FMetrics.(*metricstest.MockMetrics)
+			m.WithCounters(func(c map[string]int64) {
+				if tt.prefix == "" {
+					assert.Empty(t, c)
+					return
+				}
+
+				expected := map[string]int64{tt.prefix + "requests": 1}
+				if tt.status >= 500 {
+					expected[tt.prefix+"errors"] = 1
+				}
+
+				assert.Equal(t, expected, c)
+			})
+
+			m.WithMeasures(func(measures map[string][]time.Duration) {
+				if tt.prefix == "" {
+					assert.Empty(t, measures)
+					return
+				}
+
+				assert.Contains(t, measures, tt.prefix+"latency")
+			})
+
+			if tt.prefix != "" {
+				assert.NotNil(t, GetRequest(ctx))
+			}
+		})
+	}
+}
+
+func TestMetricsOperationNamesLimit(t *testing.T) {
+	spec := &metricsSpec{names: newOperationNames(2)}
+	f, err := spec.CreateFilter(nil)
+	require.NoError(t, err)
+
+	for _, tt := range []struct {
+		operation string
+		prefix    string
+	}{
+		{"GetUser", "graphql.query.GetUser."},
+		{"GetOrder", "graphql.query.GetOrder."},
+		{"GetCart", "graphql.query.{other}."},
+		{"GetUser", "graphql.query.GetUser."},
+	} {
+		ctx := newContext("application/graphql", "query "+tt.operation+" { id }")
+		f.Request(ctx)
+		ctx.FResponse = &http.Response{StatusCode: 200}
+		f.Response(ctx)
+
+		ctx.FMetrics.(*metricstest.MockMetrics).WithCounters(func(c map[string]int64) {
+			assert.Equal(t, map[string]int64{tt.prefix + "requests": 1}, c, tt.operation)
+		})
+	}
+
+	// the names are shared by the filters
+	f, err = spec.CreateFilter(nil)
+	require.NoError(t, err)
+	assert.Equal(t, "graphql.query.{other}.", f.(*metricsFilter).metricsPrefix(&graphql.Request{OperationName: "GetCart", Operation: &graphql.Operation{Type: "query"}}))
+}
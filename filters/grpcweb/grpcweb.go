@@ -0,0 +1,343 @@
+/*
+Package grpcweb implements the grpcWeb filter, that translates gRPC-Web
+requests to gRPC requests for the backends, and the gRPC responses back
+to gRPC-Web responses.
+
+Both the binary (application/grpc-web) and the base64 text
+(application/grpc-web-text) modes are supported. The trailers of the gRPC
+responses are sent to the clients as the last, trailer frame of the
+response body, as specified by the gRPC-Web protocol:
+
+https://github.com/grpc/grpc/blob/master/doc/PROTOCOL-WEB.md
+
+The filter responds to the CORS preflight requests, and it exposes the
+grpc-status and grpc-message headers to the browsers. The allowed origin
+is set by the corsOrigin filter, when it is placed before the grpcWeb
+filter:
+
+	corsOrigin("https://app.example.org") -> grpcWeb() -> "h2c://grpc.example.org:50051"
+*/
+package grpcweb
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"mime"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/grpc"
+)
+
+const (
+	contentType     = "application/grpc-web"
+	textContentType = "application/grpc-web-text"
+
+	stateBagKey = "filter.grpcweb"
+
+	// trailerFrameFlag marks the last frame of a gRPC-Web response,
+	// containing the trailers.
+	trailerFrameFlag = 0x80
+
+	defaultAllowHeaders = "content-type, x-grpc-web, x-user-agent, grpc-timeout"
+	exposeHeaders       = "grpc-status, grpc-message"
+
+	bufferSize = 3 * 1024
+)
+
+type (
+	spec   struct{}
+	filter struct{}
+
+	// translation stores the mode and the content type suffix of a
+	// gRPC-Web request, e.g. +proto, to translate the response back.
+	translation struct {
+		text   bool
+		suffix string
+	}
+)
+
+// New creates the grpcWeb filter specification.
+//
+// Example:
+//
+//	PathSubtree("/") -> grpcWeb() -> "h2c://grpc.example.org:50051";
+func New() filters.Spec { return spec{} }
+
+func (spec) Name() string { return filters.GRPCWebName }
+
+func (spec) CreateFilter(args []interface{}) (filters.Filter, error) {
+	if len(args) != 0 {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	return filter{}, nil
+}
+
+// HandleErrorResponse opts in to translate the proxy errors, too.
+func (filter) HandleErrorResponse() bool { return true }
+
+func parseContentType(h http.Header) (*translation, bool) {
+	mt, _, err := mime.ParseMediaType(h.Get("Content-Type"))
+	if err != nil {
+		return nil, false
+	}
+
+	switch {
+	case mt == textContentType || strings.HasPrefix(mt, textContentType+"+"):
+		return &translation{text: true, suffix: mt[len(textContentType):]}, true
+	case mt == contentType || strings.HasPrefix(mt, contentType+"+"):
+		return &translation{suffix: mt[len(contentType):]}, true
+	default:
+		return nil, false
+	}
+}
+
+func servePreflight(ctx filters.FilterContext) {
+	allowHeaders := ctx.Request().Header.Get("Access-Control-Request-Headers")
+	if allowHeaders == "" {
+		allowHeaders = defaultAllowHeaders
+	}
+
+	ctx.Serve(&http.Response{
+		StatusCode: http.StatusNoContent,
+		Header: http.Header{
+			"Access-Control-Allow-Methods":  []string{"POST, OPTIONS"},
+			"Access-Control-Allow-Headers":  []string{allowHeaders},
+			"Access-Control-Expose-Headers": []string{exposeHeaders},
+		},
+		Body: http.NoBody,
+	})
+}
+
+func (filter) Request(ctx filters.FilterContext) {
+	req := ctx.Request()
+	if req.Method == http.MethodOptions && req.Header.Get("Access-Control-Request-Method") != "" {
+		servePreflight(ctx)
+		return
+	}
+
+	t, ok := parseContentType(req.Header)
+	if !ok {
+		return
+	}
+
+	req.Header.Set("Content-Type", grpc.ContentType+t.suffix)
+	req.Header.Set("Te", "trailers")
+	if t.text {
+		req.Header.Del("Content-Length")
+		req.ContentLength = -1
+		req.Body = &base64Decoder{body: req.Body, buf: make([]byte, bufferSize)}
+	}
+
+	ctx.StateBag()[stateBagKey] = t
+}
+
+func (filter) Response(ctx filters.FilterContext) {
+	t, ok := ctx.StateBag()[stateBagKey].(*translation)
+	if !ok {
+		return
+	}
+
+	rsp := ctx.Response()
+	if ctx.Request().Header.Get("Origin") != "" && rsp.Header.Get("Access-Control-Expose-Headers") == "" {
+		rsp.Header.Set("Access-Control-Expose-Headers", exposeHeaders)
+	}
+
+	mediaType := contentType
+	if t.text {
+		mediaType = textContentType
+	}
+
+	if !grpc.IsGRPC(rsp.Header) {
+		if rsp.StatusCode < http.StatusBadRequest {
+			return
+		}
+
+		// trailers-only response, with the status in the headers
+		for k, v := range grpc.ErrorHeader(rsp.StatusCode) {
+			rsp.Header[k] = v
+		}
+
+		rsp.Header.Set("Content-Type", mediaType+t.suffix)
+		rsp.Header.Del("Content-Length")
+		rsp.StatusCode = http.StatusOK
+		rsp.Body.Close()
+		rsp.Body = http.NoBody
+		return
+	}
+
+	rsp.Header.Set("Content-Type", mediaType+strings.TrimPrefix(rsp.Header.Get("Content-Type"), grpc.ContentType))
+	rsp.Header.Del("Content-Length")
+	rsp.ContentLength = -1
+
+	var body io.ReadCloser = &responseBody{response: rsp, body: rsp.Body}
+	if t.text {
+		body = &base64Encoder{body: body, buf: make([]byte, bufferSize)}
+	}
+
+	rsp.Body = body
+}
+
+// responseBody appends the trailer frame to the response body. After
+// the trailers were read, it moves them to the response headers, which
+// were already sent, to prevent sending them as HTTP trailers, while
+// keeping the status for the metrics.
+type responseBody struct {
+	response *http.Response
+	body     io.ReadCloser
+	trailer  *bytes.Reader
+}
+
+func trailerFrame(trailer http.Header) []byte {
+	keys := make([]string, 0, len(trailer))
+	for k := range trailer {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	var b bytes.Buffer
+	for _, k := range keys {
+		for _, v := range trailer[k] {
+			b.WriteString(strings.ToLower(k))
+			b.WriteString(": ")
+			b.WriteString(v)
+			b.WriteString("\r\n")
+		}
+	}
+
+	if b.Len() == 0 {
+		return nil
+	}
+
+	frame := make([]byte, 5, 5+b.Len())
+	frame[0] = trailerFrameFlag
+	binary.BigEndian.PutUint32(frame[1:], uint32(b.Len()))
+	return append(frame, b.Bytes()...)
+}
+
+func (b *responseBody) Read(p []byte) (int, error) {
+	if b.trailer != nil {
+		return b.trailer.Read(p)
+	}
+
+	n, err := b.body.Read(p)
+	if err != io.EOF {
+		return n, err
+	}
+
+	b.trailer = bytes.NewReader(trailerFrame(b.response.Trailer))
+	for k, v := range b.response.Trailer {
+		b.response.Header[k] = v
+	}
+
+	b.response.Trailer = nil
+	if n > 0 {
+		return n, nil
+	}
+
+	return b.trailer.Read(p)
+}
+
+func (b *responseBody) Close() error { return b.body.Close() }
+
+// base64Encoder encodes the response body in text mode. Every chunk is
+// encoded separately, with padding, which the clients accept.
+type base64Encoder struct {
+	body    io.ReadCloser
+	buf     []byte
+	encoded []byte
+	err     error
+}
+
+func (e *base64Encoder) Read(p []byte) (int, error) {
+	if len(e.encoded) == 0 {
+		if e.err != nil {
+			return 0, e.err
+		}
+
+		var n int
+		n, e.err = e.body.Read(e.buf)
+		e.encoded = base64.StdEncoding.AppendEncode(e.encoded[:0], e.buf[:n])
+	}
+
+	n := copy(p, e.encoded)
+	e.encoded = e.encoded[n:]
+	if len(e.encoded) == 0 && e.err != nil {
+		return n, e.err
+	}
+
+	return n, nil
+}
+
+func (e *base64Encoder) Close() error { return e.body.Close() }
+
+// base64Decoder decodes the request body in text mode. It accepts the
+// concatenation of separately padded base64 chunks.
+type base64Decoder struct {
+	body    io.ReadCloser
+	buf     []byte
+	pending []byte
+	decoded []byte
+	err     error
+}
+
+// decodeGroups decodes base64 input whose length is a multiple of four,
+// and may contain padding at the end of any of the four byte groups.
+func decodeGroups(dst, src []byte) (int, error) {
+	var written int
+	for len(src) > 0 {
+		end := len(src)
+		if i := bytes.IndexByte(src, '='); i >= 0 {
+			end = (i/4 + 1) * 4
+		}
+
+		n, err := base64.StdEncoding.Decode(dst[written:], src[:end])
+		if err != nil {
+			return 0, err
+		}
+
+		written += n
+		src = src[end:]
+	}
+
+	return written, nil
+}
+
+func (d *base64Decoder) Read(p []byte) (int, error) {
+	for len(d.decoded) == 0 {
+		if d.err != nil {
+			if d.err == io.EOF && len(d.pending) > 0 {
+				return 0, io.ErrUnexpectedEOF
+			}
+
+			return 0, d.err
+		}
+
+		var n int
+		n, d.err = d.body.Read(d.buf)
+		d.pending = append(d.pending, d.buf[:n]...)
+
+		groups := len(d.pending) / 4 * 4
+		decoded := make([]byte, groups/4*3)
+		k, err := decodeGroups(decoded, d.pending[:groups])
+		if err != nil {
+			d.err = err
+			return 0, err
+		}
+
+		d.decoded = decoded[:k]
+		d.pending = append(d.pending[:0], d.pending[groups:]...)
+	}
+
+	n := copy(p, d.decoded)
+	d.decoded = d.decoded[n:]
+	return n, nil
+}
+
+func (d *base64Decoder) Close() error { return d.body.Close() }
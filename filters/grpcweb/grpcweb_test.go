@@ -0,0 +1,197 @@
+package grpcweb
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/filters/filtertest"
+)
+
+// a length-prefixed message with flag 0, length 3 and payload "abc"
+const message = "\x00\x00\x00\x00\x03abc"
+
+func trailer(s string) string {
+	return "\x80\x00\x00\x00" + string(rune(len(s))) + s
+}
+
+func newContext(method, contentType, body string) *filtertest.Context {
+	req := httptest.NewRequest(method, "/users.v1.Users/GetUser", strings.NewReader(body))
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	return &filtertest.Context{
+		FRequest:  req,
+		FStateBag: make(map[string]interface{}),
+	}
+}
+
+func createFilter(t *testing.T) filters.Filter {
+	f, err := New().CreateFilter(nil)
+	require.NoError(t, err)
+	return f
+}
+
+func TestCreateFilter(t *testing.T) {
+	_, err := New().CreateFilter([]interface{}{"foo"})
+	assert.ErrorIs(t, err, filters.ErrInvalidFilterParameters)
+}
+
+func TestRequest(t *testing.T) {
+	for _, tt := range []struct {
+		name        string
+		contentType string
+		body        string
+		expectType  string
+		expectBody  string
+	}{
+		{"binary", "application/grpc-web", message, "application/grpc", message},
+		{"binary proto", "application/grpc-web+proto", message, "application/grpc+proto", message},
+		{"text", "application/grpc-web-text", base64.StdEncoding.EncodeToString([]byte(message)), "application/grpc", message},
+		{"text proto", "application/grpc-web-text+proto", base64.StdEncoding.EncodeToString([]byte(message)), "application/grpc+proto", message},
+		{"grpc", "application/grpc", message, "application/grpc", message},
+		{"json", "application/json", "{}", "application/json", "{}"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := newContext("POST", tt.contentType, tt.body)
+			createFilter(t).Request(ctx)
+			require.False(t, ctx.FServed)
+
+			req := ctx.Request()
+			assert.Equal(t, tt.expectType, req.Header.Get("Content-Type"))
+			b, err := io.ReadAll(req.Body)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectBody, string(b))
+		})
+	}
+}
+
+func TestBase64Decoder(t *testing.T) {
+	chunks := []string{"a", "bc", "defg", "h", ""}
+	var encoded strings.Builder
+	for _, c := range chunks {
+		encoded.WriteString(base64.StdEncoding.EncodeToString([]byte(c)))
+	}
+
+	d := &base64Decoder{
+		body: io.NopCloser(iotest.OneByteReader(strings.NewReader(encoded.String()))),
+		buf:  make([]byte, 16),
+	}
+
+	b, err := io.ReadAll(d)
+	require.NoError(t, err)
+	assert.Equal(t, strings.Join(chunks, ""), string(b))
+
+	for _, invalid := range []string{"YWJj$", "YW"} {
+		d := &base64Decoder{body: io.NopCloser(strings.NewReader(invalid)), buf: make([]byte, 16)}
+		_, err = io.ReadAll(d)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestResponse(t *testing.T) {
+	for _, tt := range []struct {
+		name        string
+		contentType string
+		backendType string
+		rspType     string
+		text        bool
+	}{
+		{"binary", "application/grpc-web", "application/grpc", "application/grpc-web", false},
+		{"binary proto", "application/grpc-web", "application/grpc+proto", "application/grpc-web+proto", false},
+		{"text", "application/grpc-web-text", "application/grpc", "application/grpc-web-text", true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := newContext("POST", tt.contentType, "")
+			ctx.FRequest.Header.Set("Origin", "https://app.example.org")
+			f := createFilter(t)
+			f.Request(ctx)
+
+			rsp := &http.Response{
+				StatusCode: http.StatusOK,
+				Header: http.Header{
+					"Content-Type":   []string{tt.backendType},
+					"Content-Length": []string{"8"},
+				},
+				Trailer: http.Header{"Grpc-Status": nil, "Grpc-Message": nil},
+			}
+
+			// the trailers are received after the body
+			rsp.Body = io.NopCloser(io.MultiReader(strings.NewReader(message), readerFunc(func([]byte) (int, error) {
+				rsp.Trailer.Set("Grpc-Status", "0")
+				rsp.Trailer.Set("Grpc-Message", "OK")
+				return 0, io.EOF
+			})))
+
+			ctx.FResponse = rsp
+			f.Response(ctx)
+
+			assert.Equal(t, tt.rspType, rsp.Header.Get("Content-Type"))
+			assert.Empty(t, rsp.Header.Get("Content-Length"))
+			assert.Equal(t, "grpc-status, grpc-message", rsp.Header.Get("Access-Control-Expose-Headers"))
+
+			b, err := io.ReadAll(rsp.Body)
+			require.NoError(t, err)
+			if tt.text {
+				var decoded bytes.Buffer
+				_, err := io.Copy(&decoded, &base64Decoder{body: io.NopCloser(bytes.NewReader(b)), buf: make([]byte, 16)})
+				require.NoError(t, err)
+				b = decoded.Bytes()
+			}
+
+			assert.Equal(t, message+trailer("grpc-message: OK\r\ngrpc-status: 0\r\n"), string(b))
+			assert.Empty(t, rsp.Trailer)
+			assert.Equal(t, "0", rsp.Header.Get("Grpc-Status"))
+		})
+	}
+}
+
+type readerFunc func([]byte) (int, error)
+
+func (f readerFunc) Read(p []byte) (int, error) { return f(p) }
+
+func TestErrorResponse(t *testing.T) {
+	ctx := newContext("POST", "application/grpc-web-text+proto", "")
+	f := createFilter(t)
+	f.Request(ctx)
+
+	ctx.FResponse = &http.Response{
+		StatusCode: http.StatusGatewayTimeout,
+		Header:     http.Header{"Content-Type": []string{"text/plain"}},
+		Body:       io.NopCloser(strings.NewReader("Gateway Timeout")),
+	}
+
+	f.Response(ctx)
+
+	rsp := ctx.FResponse
+	assert.Equal(t, http.StatusOK, rsp.StatusCode)
+	assert.Equal(t, "application/grpc-web-text+proto", rsp.Header.Get("Content-Type"))
+	assert.Equal(t, "4", rsp.Header.Get("Grpc-Status"))
+	assert.Equal(t, "Gateway Timeout", rsp.Header.Get("Grpc-Message"))
+
+	b, err := io.ReadAll(rsp.Body)
+	require.NoError(t, err)
+	assert.Empty(t, b)
+}
+
+func TestPreflight(t *testing.T) {
+	ctx := newContext("OPTIONS", "", "")
+	ctx.FRequest.Header.Set("Access-Control-Request-Method", "POST")
+	ctx.FRequest.Header.Set("Access-Control-Request-Headers", "content-type, x-grpc-web")
+	createFilter(t).Request(ctx)
+
+	require.True(t, ctx.FServed)
+	assert.Equal(t, http.StatusNoContent, ctx.FResponse.StatusCode)
+	assert.Equal(t, "POST, OPTIONS", ctx.FResponse.Header.Get("Access-Control-Allow-Methods"))
+	assert.Equal(t, "content-type, x-grpc-web", ctx.FResponse.Header.Get("Access-Control-Allow-Headers"))
+}
@@ -0,0 +1,100 @@
+package grpcweb_test
+
+import (
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zalando/skipper/eskip"
+	"github.com/zalando/skipper/filters/builtin"
+	"github.com/zalando/skipper/proxy/proxytest"
+)
+
+const message = "\x00\x00\x00\x00\x03abc"
+
+// decodeChunks decodes the concatenation of separately padded base64
+// chunks.
+func decodeChunks(t *testing.T, b []byte) []byte {
+	var decoded []byte
+	s := string(b)
+	for s != "" {
+		end := len(s)
+		if i := strings.IndexByte(s, '='); i >= 0 {
+			end = (i/4 + 1) * 4
+		}
+
+		d, err := base64.StdEncoding.DecodeString(s[:end])
+		require.NoError(t, err)
+		decoded = append(decoded, d...)
+		s = s[end:]
+	}
+
+	return decoded
+}
+
+func TestProxy(t *testing.T) {
+	backend := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		if r.ProtoMajor != 2 || r.Header.Get("Content-Type") != "application/grpc+proto" || string(b) != message {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/grpc+proto")
+		w.Header().Set("Trailer", "Grpc-Status")
+		_, _ = w.Write([]byte(message))
+		w.Header().Set("Grpc-Status", "0")
+	}))
+	backend.Config.Protocols = new(http.Protocols)
+	backend.Config.Protocols.SetUnencryptedHTTP2(true)
+	backend.Start()
+	defer backend.Close()
+
+	p := proxytest.New(builtin.MakeRegistry(), eskip.MustParse(`*
+		-> corsOrigin("https://app.example.org")
+		-> grpcWeb()
+		-> "h2c://`+backend.Listener.Addr().String()+`"`)...)
+	defer p.Close()
+
+	t.Run("text", func(t *testing.T) {
+		req, err := http.NewRequest("POST", p.URL+"/users.v1.Users/GetUser", strings.NewReader(base64.StdEncoding.EncodeToString([]byte(message))))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/grpc-web-text+proto")
+		req.Header.Set("Origin", "https://app.example.org")
+
+		rsp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer rsp.Body.Close()
+
+		assert.Equal(t, http.StatusOK, rsp.StatusCode)
+		assert.Equal(t, "application/grpc-web-text+proto", rsp.Header.Get("Content-Type"))
+		assert.Equal(t, "https://app.example.org", rsp.Header.Get("Access-Control-Allow-Origin"))
+
+		b, err := io.ReadAll(rsp.Body)
+		require.NoError(t, err)
+		b = decodeChunks(t, b)
+		assert.Equal(t, message+"\x80\x00\x00\x00\x10grpc-status: 0\r\n", string(b))
+		assert.Empty(t, rsp.Trailer)
+	})
+
+	t.Run("preflight", func(t *testing.T) {
+		req, err := http.NewRequest("OPTIONS", p.URL+"/users.v1.Users/GetUser", nil)
+		require.NoError(t, err)
+		req.Header.Set("Origin", "https://app.example.org")
+		req.Header.Set("Access-Control-Request-Method", "POST")
+
+		rsp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer rsp.Body.Close()
+
+		assert.Equal(t, http.StatusNoContent, rsp.StatusCode)
+		assert.Equal(t, "https://app.example.org", rsp.Header.Get("Access-Control-Allow-Origin"))
+		assert.Equal(t, "content-type, x-grpc-web, x-user-agent, grpc-timeout", rsp.Header.Get("Access-Control-Allow-Headers"))
+	})
+}
@@ -0,0 +1,101 @@
+/*
+Package ipfilter implements the ipDenyList and ipAllowList filters, that
+block requests based on the client IP, using the named lists of IP
+networks of an iplist.Registry.
+*/
+package ipfilter
+
+import (
+	"net/http"
+
+	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/net/iplist"
+)
+
+type (
+	spec struct {
+		registry *iplist.Registry
+		allow    bool
+	}
+
+	filter struct {
+		registry *iplist.Registry
+		list     *iplist.List
+		allow    bool
+		status   int
+	}
+)
+
+// NewDenyList creates the ipDenyList filter specification. The filter
+// blocks the requests whose client IP is contained by the list.
+//
+// The first argument is the name of the list, the optional second
+// argument is the status code of the response, defaults to 403.
+//
+// Eskip example:
+//
+//	PathSubtree("/") -> ipDenyList("threat-intel") -> "https://www.example.org";
+func NewDenyList(r *iplist.Registry) filters.Spec { return &spec{registry: r} }
+
+// NewAllowList creates the ipAllowList filter specification. The filter
+// blocks the requests whose client IP is not contained by the list.
+//
+// The first argument is the name of the list, the optional second
+// argument is the status code of the response, defaults to 403.
+//
+// Eskip example:
+//
+//	PathSubtree("/") -> ipAllowList("office", 404) -> "https://www.example.org";
+func NewAllowList(r *iplist.Registry) filters.Spec { return &spec{registry: r, allow: true} }
+
+func (s *spec) Name() string {
+	if s.allow {
+		return filters.IPAllowListName
+	}
+
+	return filters.IPDenyListName
+}
+
+func (s *spec) CreateFilter(args []interface{}) (filters.Filter, error) {
+	if len(args) == 0 || len(args) > 2 {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	name, ok := args[0].(string)
+	if !ok {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	list := s.registry.Get(name)
+	if list == nil {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	status := http.StatusForbidden
+	if len(args) == 2 {
+		f, ok := args[1].(float64)
+		if !ok || f < 100 || f > 599 || f != float64(int(f)) {
+			return nil, filters.ErrInvalidFilterParameters
+		}
+
+		status = int(f)
+	}
+
+	return &filter{registry: s.registry, list: list, allow: s.allow, status: status}, nil
+}
+
+func (f *filter) Request(ctx filters.FilterContext) {
+	hit := f.list.Contains(f.registry.ClientIP(ctx.Request()))
+	if hit {
+		ctx.Metrics().IncCounter("iplist." + f.list.Name() + ".hits")
+	}
+
+	if hit == f.allow {
+		return
+	}
+
+	ctx.Metrics().IncCounter("iplist." + f.list.Name() + ".blocked")
+	ctx.Serve(&http.Response{StatusCode: f.status})
+}
+
+func (*filter) Response(filters.FilterContext) {}
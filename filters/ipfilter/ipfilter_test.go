@@ -0,0 +1,94 @@
+package ipfilter
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/filters/filtertest"
+	"github.com/zalando/skipper/metrics/metricstest"
+	"github.com/zalando/skipper/net/iplist"
+)
+
+func newRegistry(t *testing.T) *iplist.Registry {
+	file := filepath.Join(t.TempDir(), "list.txt")
+	require.NoError(t, os.WriteFile(file, []byte("1.2.3.0/24\n2001:db8::/32\n"), 0o644))
+
+	r, err := iplist.NewRegistry(iplist.Options{Lists: map[string]string{"foo": file}, ClientIPSource: "ClientIP"})
+	require.NoError(t, err)
+	t.Cleanup(r.Close)
+	return r
+}
+
+func TestCreateFilter(t *testing.T) {
+	r := newRegistry(t)
+	for _, spec := range []filters.Spec{NewDenyList(r), NewAllowList(r)} {
+		for _, args := range [][]interface{}{
+			nil,
+			{42.0},
+			{"bar"},
+			{"foo", "403"},
+			{"foo", 42.0},
+			{"foo", 403.5},
+			{"foo", 403.0, "bar"},
+		} {
+			_, err := spec.CreateFilter(args)
+			assert.ErrorIs(t, err, filters.ErrInvalidFilterParameters, "%s%v", spec.Name(), args)
+		}
+
+		_, err := spec.CreateFilter([]interface{}{"foo", 404.0})
+		assert.NoError(t, err)
+	}
+}
+
+func TestFilter(t *testing.T) {
+	r := newRegistry(t)
+	for _, tt := range []struct {
+		name    string
+		spec    filters.Spec
+		args    []interface{}
+		addr    string
+		blocked bool
+		status  int
+		hits    int64
+	}{
+		{"deny hit", NewDenyList(r), []interface{}{"foo"}, "1.2.3.4:1234", true, http.StatusForbidden, 1},
+		{"deny hit ipv6", NewDenyList(r), []interface{}{"foo"}, "[2001:db8::1]:1234", true, http.StatusForbidden, 1},
+		{"deny miss", NewDenyList(r), []interface{}{"foo"}, "5.6.7.8:1234", false, 0, 0},
+		{"deny custom status", NewDenyList(r), []interface{}{"foo", 429.0}, "1.2.3.4:1234", true, http.StatusTooManyRequests, 1},
+		{"allow hit", NewAllowList(r), []interface{}{"foo"}, "1.2.3.4:1234", false, 0, 1},
+		{"allow miss", NewAllowList(r), []interface{}{"foo", 404.0}, "5.6.7.8:1234", true, http.StatusNotFound, 0},
+		{"allow invalid address", NewAllowList(r), []interface{}{"foo"}, "invalid", true, http.StatusForbidden, 0},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := tt.spec.CreateFilter(tt.args)
+			require.NoError(t, err)
+
+			req := httptest.NewRequest("GET", "/", nil)
+			req.RemoteAddr = tt.addr
+			m := &metricstest.MockMetrics{}
+			ctx := &filtertest.Context{FRequest: req, FMetrics: m}
+			f.Request(ctx)
+
+			assert.Equal(t, tt.blocked, ctx.FServed)
+			m.WithCounters(func(c map[string]int64) {
+				assert.Equal(t, tt.hits, c["iplist.foo.hits"])
+				if tt.blocked {
+					assert.Equal(t, int64(1), c["iplist.foo.blocked"])
+				} else {
+					assert.Zero(t, c["iplist.foo.blocked"])
+				}
+			})
+
+			if tt.blocked {
+				assert.Equal(t, tt.status, ctx.FResponse.StatusCode)
+			}
+		})
+	}
+}
@@ -391,6 +391,12 @@ func (registry *OpenPolicyAgentRegistry) Do(routes []*routing.Route) []*routing.
 	return routes
 }
 
+// DoRollout implements routing.RolloutPostProcessor. The instances not
+// used by the routes under staged rollout are not marked as unused.
+func (registry *OpenPolicyAgentRegistry) DoRollout(routes []*routing.Route) []*routing.Route {
+	return routes
+}
+
 func (registry *OpenPolicyAgentRegistry) NewOpenPolicyAgentInstance(bundleName string, config OpenPolicyAgentInstanceConfig, filterName string) (*OpenPolicyAgentInstance, error) {
 	registry.mu.Lock()
 	defer registry.mu.Unlock()
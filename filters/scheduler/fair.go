@@ -0,0 +1,246 @@
+package scheduler
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/ratelimit"
+	"github.com/zalando/skipper/scheduler"
+)
+
+const stateBagKeyPrefix = "state:"
+
+type (
+	fairFifoSpec struct{}
+
+	fairFifoFilter struct {
+		config   scheduler.Config
+		weights  map[string]int
+		lookuper ratelimit.Lookuper
+		stateKey string
+		queue    *scheduler.FairQueue
+	}
+)
+
+func NewFairFifo() filters.Spec {
+	return &fairFifoSpec{}
+}
+
+func (*fairFifoSpec) Name() string { return filters.FairFifoName }
+
+// CreateFilter creates a fairFifoFilter, that will use a queue, which
+// groups the waiting requests by a key and serves them deficit round
+// robin by weight. The first parameter is maxConcurrency, the second
+// maxQueueSize, the third timeout and the fourth the key. The key is
+// either a comma separated list of headers, like the lookuper of the
+// clusterClientRatelimit filter, or a state bag value prefixed by
+// "state:". The optional fifth parameter sets the weights of the keys
+// as comma separated list of key=weight pairs. Keys without weight
+// have the weight 1.
+//
+//	fairFifo(100, 150, "10s", "X-Tenant-Id", "checkout=3,search=2")
+//	fairFifo(100, 150, "10s", "state:tenant")
+func (*fairFifoSpec) CreateFilter(args []interface{}) (filters.Filter, error) {
+	if len(args) < 4 || len(args) > 5 {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	cc, err := intArg(args[0])
+	if err != nil {
+		return nil, err
+	}
+	if cc < 1 {
+		return nil, fmt.Errorf("maxconcurrency requires value >0, %w", filters.ErrInvalidFilterParameters)
+	}
+
+	qs, err := intArg(args[1])
+	if err != nil {
+		return nil, err
+	}
+	if qs < 0 {
+		return nil, fmt.Errorf("maxqueuesize requires value >=0, %w", filters.ErrInvalidFilterParameters)
+	}
+
+	d, err := durationArg(args[2])
+	if err != nil {
+		return nil, err
+	}
+	if d < 1*time.Millisecond {
+		return nil, fmt.Errorf("timeout requires value >=1ms, %w", filters.ErrInvalidFilterParameters)
+	}
+
+	key, ok := args[3].(string)
+	if !ok || key == "" {
+		return nil, fmt.Errorf("key requires a non empty string, %w", filters.ErrInvalidFilterParameters)
+	}
+
+	f := &fairFifoFilter{
+		config: scheduler.Config{
+			MaxConcurrency: cc,
+			MaxQueueSize:   qs,
+			Timeout:        d,
+		},
+	}
+
+	if stateKey, ok := strings.CutPrefix(key, stateBagKeyPrefix); ok {
+		f.stateKey = stateKey
+	} else if strings.Contains(key, ",") {
+		var lookupers []ratelimit.Lookuper
+		for _, h := range strings.Split(key, ",") {
+			lookupers = append(lookupers, headerLookuper(h))
+		}
+		f.lookuper = ratelimit.NewTupleLookuper(lookupers...)
+	} else {
+		f.lookuper = headerLookuper(key)
+	}
+
+	if len(args) > 4 {
+		s, ok := args[4].(string)
+		if !ok {
+			return nil, filters.ErrInvalidFilterParameters
+		}
+
+		f.weights, err = parseWeights(s)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return f, nil
+}
+
+func headerLookuper(s string) ratelimit.Lookuper {
+	headerName := http.CanonicalHeaderKey(strings.TrimSpace(s))
+	if headerName == "X-Forwarded-For" {
+		return ratelimit.NewXForwardedForLookuper()
+	}
+	return ratelimit.NewHeaderLookuper(headerName)
+}
+
+func parseWeights(s string) (map[string]int, error) {
+	weights := make(map[string]int)
+	for _, kv := range strings.Split(s, ",") {
+		k, v, ok := strings.Cut(strings.TrimSpace(kv), "=")
+		if !ok || k == "" {
+			return nil, fmt.Errorf("weight requires key=weight, got %q, %w", kv, filters.ErrInvalidFilterParameters)
+		}
+
+		w, err := strconv.Atoi(v)
+		if err != nil || w < 1 {
+			return nil, fmt.Errorf("weight requires value >0, got %q, %w", kv, filters.ErrInvalidFilterParameters)
+		}
+
+		weights[k] = w
+	}
+
+	return weights, nil
+}
+
+func (f *fairFifoFilter) Config() scheduler.Config {
+	return f.config
+}
+
+func (f *fairFifoFilter) Weights() map[string]int {
+	return f.weights
+}
+
+func (f *fairFifoFilter) GetQueue() *scheduler.FairQueue {
+	return f.queue
+}
+
+func (f *fairFifoFilter) SetQueue(q *scheduler.FairQueue) {
+	f.queue = q
+}
+
+func (f *fairFifoFilter) key(ctx filters.FilterContext) string {
+	if f.stateKey != "" {
+		if v, ok := ctx.StateBag()[f.stateKey]; ok {
+			return fmt.Sprint(v)
+		}
+		return ""
+	}
+
+	return f.lookuper.Lookup(ctx.Request())
+}
+
+// Request is the filter.Filter interface implementation. Request will
+// increase the number of inflight requests of the key and respond to
+// the caller, if the bounded queue returns an error. Status code by
+// Error:
+//
+// - 503 if queue full or closed
+// - 502 if queue timeout
+// - 500 if error unknown
+func (f *fairFifoFilter) Request(ctx filters.FilterContext) {
+	q := f.GetQueue()
+	if q == nil {
+		ctx.Logger().Warnf("Unexpected scheduler.FairQueue is nil for key %s", filters.FairFifoName)
+		return
+	}
+
+	c := ctx.Request().Context()
+	done, err := q.WaitWithPriority(c, f.key(ctx), scheduler.GetPriority(ctx.StateBag()))
+	if err != nil {
+		if span := opentracing.SpanFromContext(c); span != nil {
+			ext.Error.Set(span, true)
+			span.LogKV("fairFifo error", fmt.Sprintf("Failed to wait for fair fifo queue: %v", err))
+		}
+		ctx.Logger().Debugf("Failed to wait for fair fifo queue: %v", err)
+
+		switch err {
+		case scheduler.ErrQueueFull:
+			ctx.Serve(&http.Response{
+				StatusCode: http.StatusServiceUnavailable,
+				Status:     "Queue Full - https://opensource.zalando.com/skipper/operation/operation/#scheduler",
+			})
+		case scheduler.ErrQueueTimeout:
+			ctx.Serve(&http.Response{
+				StatusCode: http.StatusBadGateway,
+				Status:     "Queue Timeout - https://opensource.zalando.com/skipper/operation/operation/#scheduler",
+			})
+		case scheduler.ErrQueueClosed:
+			ctx.Serve(&http.Response{
+				StatusCode: http.StatusServiceUnavailable,
+				Status:     "Queue Closed - https://opensource.zalando.com/skipper/operation/operation/#scheduler",
+			})
+		case scheduler.ErrClientCanceled:
+			// This case is handled in the proxy with status code 499
+		default:
+			ctx.Logger().Errorf("Unknown error in fairFifo() please create an issue https://github.com/zalando/skipper/issues/new/choose: %v", err)
+			ctx.Serve(&http.Response{StatusCode: http.StatusInternalServerError})
+		}
+		return
+	}
+
+	pending, _ := ctx.StateBag()[filters.FairFifoName].([]func())
+	ctx.StateBag()[filters.FairFifoName] = append(pending, done)
+}
+
+// Response will decrease the number of inflight requests to release
+// the concurrency reservation for the request.
+func (f *fairFifoFilter) Response(ctx filters.FilterContext) {
+	if q := f.GetQueue(); q != nil {
+		q.Feedback(ctx.Response())
+	}
+
+	pending, _ := ctx.StateBag()[filters.FairFifoName].([]func())
+	last := len(pending) - 1
+	if last < 0 {
+		return
+	}
+
+	pending[last]()
+	ctx.StateBag()[filters.FairFifoName] = pending[:last]
+}
+
+// HandleErrorResponse is to opt-in for filters to get called
+// Response(ctx) in case of errors via proxy. It has to return true to opt-in.
+func (f *fairFifoFilter) HandleErrorResponse() bool {
+	return true
+}
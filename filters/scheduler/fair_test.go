@@ -0,0 +1,179 @@
+package scheduler
+
+import (
+	"net/http"
+	stdlibhttptest "net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zalando/skipper/eskip"
+	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/filters/filtertest"
+	"github.com/zalando/skipper/proxy/proxytest"
+	"github.com/zalando/skipper/routing"
+	"github.com/zalando/skipper/scheduler"
+)
+
+func TestCreateFairFifoFilter(t *testing.T) {
+	spec := NewFairFifo()
+	assert.Equal(t, filters.FairFifoName, spec.Name())
+
+	for _, tt := range []struct {
+		name        string
+		args        []interface{}
+		wantErr     bool
+		wantConfig  scheduler.Config
+		wantWeights map[string]int
+	}{
+		{
+			name:    "too few args",
+			args:    []interface{}{3, 5, "1s"},
+			wantErr: true,
+		},
+		{
+			name:       "header key",
+			args:       []interface{}{3, 5, "1s", "X-Tenant"},
+			wantConfig: scheduler.Config{MaxConcurrency: 3, MaxQueueSize: 5, Timeout: time.Second},
+		},
+		{
+			name:        "state bag key with weights",
+			args:        []interface{}{3.0, 5.0, "1s", "state:tenant", "a=3, b=2"},
+			wantConfig:  scheduler.Config{MaxConcurrency: 3, MaxQueueSize: 5, Timeout: time.Second},
+			wantWeights: map[string]int{"a": 3, "b": 2},
+		},
+		{
+			name:    "empty key",
+			args:    []interface{}{3, 5, "1s", ""},
+			wantErr: true,
+		},
+		{
+			name:    "invalid concurrency",
+			args:    []interface{}{0, 5, "1s", "X-Tenant"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid timeout",
+			args:    []interface{}{3, 5, "1us", "X-Tenant"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid weight",
+			args:    []interface{}{3, 5, "1s", "X-Tenant", "a=0"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid weights",
+			args:    []interface{}{3, 5, "1s", "X-Tenant", "a"},
+			wantErr: true,
+		},
+		{
+			name:    "too many args",
+			args:    []interface{}{3, 5, "1s", "X-Tenant", "a=1", "b"},
+			wantErr: true,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := spec.CreateFilter(tt.args)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, filters.ErrInvalidFilterParameters)
+				return
+			}
+			require.NoError(t, err)
+
+			ff := f.(scheduler.FairFilter)
+			assert.Equal(t, tt.wantConfig, ff.Config())
+			assert.Equal(t, tt.wantWeights, ff.Weights())
+		})
+	}
+}
+
+func TestFairFifoKey(t *testing.T) {
+	for _, tt := range []struct {
+		key      string
+		header   http.Header
+		stateBag map[string]interface{}
+		want     string
+	}{
+		{
+			key:    "X-Tenant",
+			header: http.Header{"X-Tenant": []string{"a"}},
+			want:   "a",
+		},
+		{
+			key:      "state:tenant",
+			stateBag: map[string]interface{}{"tenant": "b"},
+			want:     "b",
+		},
+		{
+			key:  "state:tenant",
+			want: "",
+		},
+	} {
+		t.Run(tt.key, func(t *testing.T) {
+			f, err := NewFairFifo().CreateFilter([]interface{}{1, 1, "1s", tt.key})
+			require.NoError(t, err)
+
+			req := &http.Request{Header: tt.header}
+			if req.Header == nil {
+				req.Header = make(http.Header)
+			}
+			ctx := &filtertest.Context{FRequest: req, FStateBag: tt.stateBag}
+
+			assert.Equal(t, tt.want, f.(*fairFifoFilter).key(ctx))
+		})
+	}
+}
+
+func TestFairFifo(t *testing.T) {
+	started, block := make(chan struct{}), make(chan struct{})
+	backend := stdlibhttptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Tenant") == "slow" {
+			close(started)
+			<-block
+		}
+	}))
+	defer backend.Close()
+
+	reg := scheduler.RegistryWith(scheduler.Options{})
+	defer reg.Close()
+
+	fr := make(filters.Registry)
+	fr.Register(NewFairFifo())
+
+	r := &eskip.Route{
+		Id:      "fair",
+		Filters: []*eskip.Filter{{Name: filters.FairFifoName, Args: []interface{}{1, 0, "1s", "X-Tenant"}}},
+		Backend: backend.URL,
+	}
+	p := proxytest.WithRoutingOptions(fr, routing.Options{
+		PostProcessors: []routing.PostProcessor{reg},
+	}, r)
+	defer p.Close()
+
+	get := func(tenant string) int {
+		req, err := http.NewRequest("GET", p.URL, nil)
+		require.NoError(t, err)
+		req.Header.Set("X-Tenant", tenant)
+
+		rsp, err := p.Client().Do(req)
+		require.NoError(t, err)
+		rsp.Body.Close()
+		return rsp.StatusCode
+	}
+
+	assert.Equal(t, http.StatusOK, get("a"))
+
+	slow := make(chan int)
+	go func() { slow <- get("slow") }()
+
+	<-started
+
+	assert.Equal(t, http.StatusServiceUnavailable, get("b"))
+
+	close(block)
+	assert.Equal(t, http.StatusOK, <-slow)
+	assert.Equal(t, http.StatusOK, get("b"))
+}
@@ -103,7 +103,7 @@ func (f *fifoFilter) SetQueue(fq *scheduler.FifoQueue) {
 func (f *fifoFilter) Request(ctx filters.FilterContext) {
 	q := f.GetQueue()
 	c := ctx.Request().Context()
-	done, err := q.Wait(c)
+	done, err := q.WaitWithPriority(c, scheduler.GetPriority(ctx.StateBag()))
 	if err != nil {
 		if span := opentracing.SpanFromContext(c); span != nil {
 			ext.Error.Set(span, true)
@@ -145,8 +145,13 @@ func (f *fifoFilter) Request(ctx filters.FilterContext) {
 }
 
 // Response will decrease the number of inflight requests to release
-// the concurrency reservation for the request.
+// the concurrency reservation for the request. If the registry has the
+// feedback mode enabled, the queue is tuned by the backend response.
 func (f *fifoFilter) Response(ctx filters.FilterContext) {
+	if q := f.GetQueue(); q != nil {
+		q.Feedback(ctx.Response())
+	}
+
 	switch f.typ {
 	case filters.FifoName:
 		pending, ok := ctx.StateBag()[f.typ].([]func())
@@ -252,7 +252,7 @@ func (l *lifoFilter) Request(ctx filters.FilterContext) {
 // Response is the filter.Filter interface implementation. Response
 // will decrease the number of inflight requests.
 func (l *lifoFilter) Response(ctx filters.FilterContext) {
-	response(scheduler.LIFOKey, ctx)
+	response(l.GetQueue(), scheduler.LIFOKey, ctx)
 }
 
 // HandleErrorResponse is to opt-in for filters to get called
@@ -303,7 +303,7 @@ func (l *lifoGroupFilter) Request(ctx filters.FilterContext) {
 // Response is the filter.Filter interface implementation. Response
 // will decrease the number of inflight requests.
 func (l *lifoGroupFilter) Response(ctx filters.FilterContext) {
-	response(scheduler.LIFOKey, ctx)
+	response(l.GetQueue(), scheduler.LIFOKey, ctx)
 }
 
 // HandleErrorResponse is to opt-in for filters to get called
@@ -318,7 +318,7 @@ func request(q *scheduler.Queue, key string, ctx filters.FilterContext) {
 		return
 	}
 
-	done, err := q.Wait()
+	done, err := q.WaitWithPriority(scheduler.GetPriority(ctx.StateBag()))
 	if err != nil {
 		if span := opentracing.SpanFromContext(ctx.Request().Context()); span != nil {
 			ext.Error.Set(span, true)
@@ -348,7 +348,11 @@ func request(q *scheduler.Queue, key string, ctx filters.FilterContext) {
 
 }
 
-func response(key string, ctx filters.FilterContext) {
+func response(q *scheduler.Queue, key string, ctx filters.FilterContext) {
+	if q != nil {
+		q.Feedback(ctx.Response())
+	}
+
 	pending, _ := ctx.StateBag()[key].([]func())
 	last := len(pending) - 1
 	if last < 0 {
@@ -0,0 +1,101 @@
+package scheduler
+
+import (
+	"net/http"
+
+	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/scheduler"
+)
+
+const defaultSheddableQueueUtilization = 0.5
+
+// ListenerLoad reports the utilization of the listener queue, e.g. the
+// queuelistener.Load of the TCP LIFO listener.
+type ListenerLoad interface {
+	QueueUtilization() float64
+}
+
+// PriorityOptions provides options for the priority filter.
+type PriorityOptions struct {
+
+	// ListenerLoad is the load of the TCP LIFO listener. When set,
+	// sheddable requests are rejected while the utilization of the
+	// listener queue is above SheddableQueueUtilization.
+	ListenerLoad ListenerLoad
+
+	// SheddableQueueUtilization sets the utilization of the listener
+	// queue within (0, 1], above which sheddable requests are
+	// rejected. Defaults to 0.5.
+	SheddableQueueUtilization float64
+}
+
+type (
+	prioritySpec struct {
+		options PriorityOptions
+	}
+
+	priorityFilter struct {
+		priority scheduler.Priority
+		options  PriorityOptions
+	}
+)
+
+// NewPriority creates the priority filter spec without shedding on
+// the listener load.
+func NewPriority() filters.Spec {
+	return NewPriorityWithOptions(PriorityOptions{})
+}
+
+// NewPriorityWithOptions creates the priority filter spec with the
+// provided options.
+func NewPriorityWithOptions(o PriorityOptions) filters.Spec {
+	if o.SheddableQueueUtilization <= 0 || o.SheddableQueueUtilization > 1 {
+		o.SheddableQueueUtilization = defaultSheddableQueueUtilization
+	}
+	return &prioritySpec{options: o}
+}
+
+func (*prioritySpec) Name() string { return filters.PriorityName }
+
+// CreateFilter creates a priority filter, that sets the priority class
+// of the request for the scheduler queues and load shedders. The only
+// parameter is the class, one of "critical", "default" and "sheddable".
+//
+//	priority("critical")
+func (s *prioritySpec) CreateFilter(args []interface{}) (filters.Filter, error) {
+	if len(args) != 1 {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	class, ok := args[0].(string)
+	if !ok {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	p, err := scheduler.ParsePriority(class)
+	if err != nil {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	return &priorityFilter{priority: p, options: s.options}, nil
+}
+
+// Request sets the priority of the request. Sheddable requests are
+// rejected with 503, while the listener queue is utilized above the
+// configured threshold.
+func (f *priorityFilter) Request(ctx filters.FilterContext) {
+	ctx.StateBag()[scheduler.PriorityKey] = f.priority
+
+	if f.priority == scheduler.PrioritySheddable &&
+		f.options.ListenerLoad != nil &&
+		f.options.ListenerLoad.QueueUtilization() > f.options.SheddableQueueUtilization {
+
+		ctx.Logger().Debugf("Shedding sheddable request, listener queue utilization above %0.2f", f.options.SheddableQueueUtilization)
+		ctx.Serve(&http.Response{
+			StatusCode: http.StatusServiceUnavailable,
+			Status:     "Listener Queue Full - https://opensource.zalando.com/skipper/operation/operation/#scheduler",
+		})
+	}
+}
+
+func (*priorityFilter) Response(filters.FilterContext) {}
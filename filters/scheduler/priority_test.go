@@ -0,0 +1,97 @@
+package scheduler
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/filters/filtertest"
+	"github.com/zalando/skipper/scheduler"
+)
+
+type testListenerLoad float64
+
+func (l testListenerLoad) QueueUtilization() float64 { return float64(l) }
+
+func TestCreatePriorityFilter(t *testing.T) {
+	spec := NewPriority()
+	assert.Equal(t, filters.PriorityName, spec.Name())
+
+	for _, tt := range []struct {
+		args    []interface{}
+		want    scheduler.Priority
+		wantErr bool
+	}{
+		{args: []interface{}{"critical"}, want: scheduler.PriorityCritical},
+		{args: []interface{}{"default"}, want: scheduler.PriorityDefault},
+		{args: []interface{}{"sheddable"}, want: scheduler.PrioritySheddable},
+		{args: []interface{}{}, wantErr: true},
+		{args: []interface{}{"high"}, wantErr: true},
+		{args: []interface{}{1}, wantErr: true},
+		{args: []interface{}{"critical", "sheddable"}, wantErr: true},
+	} {
+		f, err := spec.CreateFilter(tt.args)
+		if tt.wantErr {
+			assert.Error(t, err, "args: %v", tt.args)
+			continue
+		}
+
+		require.NoError(t, err, "args: %v", tt.args)
+		assert.Equal(t, tt.want, f.(*priorityFilter).priority)
+	}
+}
+
+func TestPriorityFilter(t *testing.T) {
+	for _, tt := range []struct {
+		name        string
+		class       string
+		load        ListenerLoad
+		wantShedded bool
+	}{
+		{
+			name:  "no listener load",
+			class: "sheddable",
+		},
+		{
+			name:  "sheddable below threshold",
+			class: "sheddable",
+			load:  testListenerLoad(0.5),
+		},
+		{
+			name:        "sheddable above threshold",
+			class:       "sheddable",
+			load:        testListenerLoad(0.6),
+			wantShedded: true,
+		},
+		{
+			name:  "critical above threshold",
+			class: "critical",
+			load:  testListenerLoad(1),
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			spec := NewPriorityWithOptions(PriorityOptions{ListenerLoad: tt.load})
+			f, err := spec.CreateFilter([]interface{}{tt.class})
+			require.NoError(t, err)
+
+			req, err := http.NewRequest("GET", "http://example.org", nil)
+			require.NoError(t, err)
+
+			ctx := &filtertest.Context{
+				FRequest:  req,
+				FStateBag: make(map[string]interface{}),
+			}
+			f.Request(ctx)
+
+			want, _ := scheduler.ParsePriority(tt.class)
+			assert.Equal(t, want, scheduler.GetPriority(ctx.StateBag()))
+			assert.Equal(t, tt.wantShedded, ctx.FServed)
+			if tt.wantShedded {
+				assert.Equal(t, http.StatusServiceUnavailable, ctx.FResponse.StatusCode)
+			}
+		})
+	}
+}
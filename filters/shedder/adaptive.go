@@ -0,0 +1,392 @@
+package shedder
+
+import (
+	"math"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	log "github.com/sirupsen/logrus"
+	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/metrics"
+	"github.com/zalando/skipper/scheduler"
+)
+
+const (
+	adaptivePrefix                 = "shedder.adaptive_concurrency."
+	adaptiveConcurrencySpanName    = "adaptive_concurrency"
+	adaptiveConcurrencyKey         = "shedder:adaptive_concurrency"
+	adaptiveConcurrencyStartKey    = "shedder:adaptive_concurrency_start"
+	adaptiveConcurrencyValue       = "reject"
+	defaultAIMDLatencyThreshold    = 5 * time.Second
+	aimdBackoffRatio               = 0.9
+	gradientSmoothing              = 0.2
+	gradientTolerance              = 1.5
+	gradientLongWindowSamples      = 600
+	gradientMinGradient            = 0.5
+	gradientMaxGradient            = 1.0
+	adaptiveConcurrencyMinArgs     = 6
+	adaptiveConcurrencyMaxArgs     = 7
+	adaptiveConcurrencyMinimumSize = 1
+)
+
+type algorithm int
+
+const (
+	gradient algorithm = iota + 1
+	aimd
+)
+
+func (a algorithm) String() string {
+	switch a {
+	case gradient:
+		return "gradient"
+	case aimd:
+		return "aimd"
+	}
+	return "unknown"
+}
+
+func getAlgorithmArg(a interface{}) (algorithm, error) {
+	s, ok := a.(string)
+	if !ok {
+		return 0, filters.ErrInvalidFilterParameters
+	}
+	switch s {
+	case "gradient":
+		return gradient, nil
+	case "aimd":
+		return aimd, nil
+	}
+
+	return 0, filters.ErrInvalidFilterParameters
+}
+
+// limiter estimates the concurrency limit from observed round trip
+// times. Implementations are not safe for concurrent use, the caller
+// has to synchronize.
+type limiter interface {
+	// update is called for every finished request with the measured
+	// round trip time, the number of requests in flight when the
+	// request was started and whether the request was dropped by the
+	// backend. It returns the new estimated limit.
+	update(rtt time.Duration, inflight int, dropped bool) float64
+}
+
+// gradientLimiter is a simplified version of the Netflix
+// concurrency-limits Gradient2 algorithm. It compares the short term
+// RTT with a long term exponential moving average and grows or
+// shrinks the limit by the gradient between both.
+type gradientLimiter struct {
+	limit    float64
+	min, max float64
+	longRtt  float64
+	samples  int
+}
+
+func (l *gradientLimiter) update(rtt time.Duration, inflight int, dropped bool) float64 {
+	shortRtt := float64(rtt)
+	if shortRtt <= 0 {
+		return l.limit
+	}
+
+	if l.samples < gradientLongWindowSamples {
+		l.samples++
+	}
+	if l.longRtt == 0 {
+		l.longRtt = shortRtt
+	} else {
+		l.longRtt = l.longRtt + (shortRtt-l.longRtt)/float64(l.samples)
+	}
+
+	// the long term RTT recovers faster, if the backend got faster
+	if l.longRtt/shortRtt > 2 {
+		l.longRtt *= 0.95
+	}
+
+	// app limited, no need to grow the limit
+	if float64(inflight) < l.limit/2 {
+		return l.limit
+	}
+
+	g := math.Max(gradientMinGradient, math.Min(gradientMaxGradient, gradientTolerance*l.longRtt/shortRtt))
+	newLimit := l.limit*g + math.Sqrt(l.limit)
+	newLimit = l.limit*(1-gradientSmoothing) + newLimit*gradientSmoothing
+
+	l.limit = math.Max(l.min, math.Min(l.max, newLimit))
+	return l.limit
+}
+
+// aimdLimiter implements additive increase multiplicative decrease. The
+// limit is reduced if a request was dropped or took longer than the
+// latency threshold and increased by one otherwise.
+type aimdLimiter struct {
+	limit            float64
+	min, max         float64
+	latencyThreshold time.Duration
+}
+
+func (l *aimdLimiter) update(rtt time.Duration, inflight int, dropped bool) float64 {
+	if dropped || rtt > l.latencyThreshold {
+		l.limit = math.Floor(l.limit * aimdBackoffRatio)
+	} else if float64(inflight)*2 >= l.limit {
+		l.limit++
+	}
+
+	l.limit = math.Max(l.min, math.Min(l.max, l.limit))
+	return l.limit
+}
+
+type AdaptiveConcurrencySpec struct {
+	tracer opentracing.Tracer
+}
+
+type adaptiveConcurrency struct {
+	metrics      metrics.Metrics
+	metricSuffix string
+	tracer       opentracing.Tracer
+	now          func() time.Time
+
+	mode      mode
+	algorithm algorithm
+
+	mu       sync.Mutex
+	limiter  limiter
+	limit    atomic.Int64
+	inflight atomic.Int64
+}
+
+// NewAdaptiveConcurrency creates a filter spec for latency based
+// adaptive concurrency limiting.
+func NewAdaptiveConcurrency(o Options) filters.Spec {
+	tracer := o.Tracer
+	if tracer == nil {
+		tracer = &opentracing.NoopTracer{}
+	}
+	return &AdaptiveConcurrencySpec{
+		tracer: tracer,
+	}
+}
+
+func (*AdaptiveConcurrencySpec) Name() string { return filters.AdaptiveConcurrencyName }
+
+// CreateFilter creates a new adaptiveConcurrency filter with passed configuration:
+//
+//	adaptiveConcurrency(metricSuffix, mode, algorithm, minLimit, maxLimit, initialLimit[, latencyThreshold])
+//	adaptiveConcurrency("$app", "active", "gradient", 10, 1000, 20)
+//	adaptiveConcurrency("$app", "active", "aimd", 10, 1000, 20, "500ms")
+//
+// metricSuffix is the suffix key to expose the limit gauge and reject counter, should be unique by filter instance
+// mode is one of "active", "inactive", "logInactive"
+//
+//	active will reject traffic
+//	inactive will never reject traffic
+//	logInactive will not reject traffic, but log to debug filter settings
+//
+// algorithm is one of "gradient", "aimd"
+// minLimit, maxLimit and initialLimit bound and initialize the concurrency limit
+// latencyThreshold is only used by "aimd", requests slower than it decrease the limit
+//
+// see also https://github.com/Netflix/concurrency-limits
+func (spec *AdaptiveConcurrencySpec) CreateFilter(args []interface{}) (filters.Filter, error) {
+	if len(args) < adaptiveConcurrencyMinArgs || len(args) > adaptiveConcurrencyMaxArgs {
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	metricSuffix, ok := args[0].(string)
+	if !ok {
+		log.Warn("metricsuffix required as string")
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	mode, err := getModeArg(args[1])
+	if err != nil {
+		log.Warnf("mode failed: %v", err)
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	alg, err := getAlgorithmArg(args[2])
+	if err != nil {
+		log.Warnf("algorithm failed: %v", err)
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	minLimit, err := getIntArg(args[3])
+	if err != nil {
+		log.Warnf("minLimit failed: %v", err)
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	maxLimit, err := getIntArg(args[4])
+	if err != nil {
+		log.Warnf("maxLimit failed: %v", err)
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	initialLimit, err := getIntArg(args[5])
+	if err != nil {
+		log.Warnf("initialLimit failed: %v", err)
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	if minLimit < adaptiveConcurrencyMinimumSize || minLimit > maxLimit || initialLimit < minLimit || initialLimit > maxLimit {
+		log.Warnf("limits should satisfy %d <= minLimit <= initialLimit <= maxLimit, got: %d, %d, %d", adaptiveConcurrencyMinimumSize, minLimit, initialLimit, maxLimit)
+		return nil, filters.ErrInvalidFilterParameters
+	}
+
+	latencyThreshold := defaultAIMDLatencyThreshold
+	if len(args) == adaptiveConcurrencyMaxArgs {
+		latencyThreshold, err = getDurationArg(args[6])
+		if err != nil || latencyThreshold <= 0 {
+			log.Warnf("latencyThreshold failed: %v", err)
+			return nil, filters.ErrInvalidFilterParameters
+		}
+	}
+
+	var l limiter
+	switch alg {
+	case gradient:
+		l = &gradientLimiter{
+			limit: float64(initialLimit),
+			min:   float64(minLimit),
+			max:   float64(maxLimit),
+		}
+	case aimd:
+		l = &aimdLimiter{
+			limit:            float64(initialLimit),
+			min:              float64(minLimit),
+			max:              float64(maxLimit),
+			latencyThreshold: latencyThreshold,
+		}
+	}
+
+	ac := &adaptiveConcurrency{
+		metrics:      metrics.Default,
+		metricSuffix: metricSuffix,
+		tracer:       spec.tracer,
+		now:          time.Now,
+		mode:         mode,
+		algorithm:    alg,
+		limiter:      l,
+	}
+	ac.limit.Store(int64(initialLimit))
+	ac.metrics.UpdateGauge(adaptivePrefix+"limit."+metricSuffix, float64(initialLimit))
+
+	return ac, nil
+}
+
+// Limit returns the current estimated concurrency limit.
+func (ac *adaptiveConcurrency) Limit() int {
+	return int(ac.limit.Load())
+}
+
+// priorityLimit returns the concurrency limit for the priority of the
+// request. Critical requests are never rejected and sheddable requests
+// are rejected above half of the limit.
+func priorityLimit(limit int64, p scheduler.Priority) int64 {
+	switch p {
+	case scheduler.PriorityCritical:
+		return math.MaxInt64
+	case scheduler.PrioritySheddable:
+		if limit < 2 {
+			return 1
+		}
+		return limit / 2
+	}
+	return limit
+}
+
+func (ac *adaptiveConcurrency) Request(ctx filters.FilterContext) {
+	span := ac.startSpan(ctx)
+	defer span.Finish()
+
+	span.SetTag("adaptiveConcurrency.group", ac.metricSuffix)
+	span.SetTag("adaptiveConcurrency.mode", ac.mode.String())
+	span.SetTag("adaptiveConcurrency.algorithm", ac.algorithm.String())
+
+	ac.metrics.IncCounter(adaptivePrefix + "total." + ac.metricSuffix)
+
+	inflight := ac.inflight.Add(1)
+	limit := ac.limit.Load()
+	span.SetTag("adaptiveConcurrency.limit", limit)
+
+	if ac.mode == logInactive {
+		log.Infof("%s: inflight: %d, limit: %d", filters.AdaptiveConcurrencyName, inflight, limit)
+	}
+
+	if inflight > priorityLimit(limit, scheduler.GetPriority(ctx.StateBag())) && ac.mode != inactive {
+		ac.metrics.IncCounter(adaptivePrefix + "reject." + ac.metricSuffix)
+		ext.Error.Set(span, true)
+
+		// shadow mode to measure data
+		if ac.mode == active {
+			ac.inflight.Add(-1)
+			ctx.StateBag()[adaptiveConcurrencyKey] = adaptiveConcurrencyValue
+
+			header := make(http.Header)
+			header.Set(admissionSignalHeaderKey, admissionSignalHeaderValue)
+			ctx.Serve(&http.Response{
+				Header:     header,
+				StatusCode: http.StatusServiceUnavailable,
+			})
+			return
+		}
+	}
+
+	ctx.StateBag()[adaptiveConcurrencyStartKey] = adaptiveStart{
+		t:        ac.now(),
+		inflight: int(inflight),
+	}
+}
+
+type adaptiveStart struct {
+	t        time.Time
+	inflight int
+}
+
+func (ac *adaptiveConcurrency) Response(ctx filters.FilterContext) {
+	// we don't want to count our short cutted responses
+	if ctx.StateBag()[adaptiveConcurrencyKey] == adaptiveConcurrencyValue {
+		return
+	}
+
+	start, ok := ctx.StateBag()[adaptiveConcurrencyStartKey].(adaptiveStart)
+	if !ok {
+		return
+	}
+	delete(ctx.StateBag(), adaptiveConcurrencyStartKey)
+	ac.inflight.Add(-1)
+
+	rsp := ctx.Response()
+
+	// we don't want to count other shedders in the call path as drops
+	dropped := rsp == nil ||
+		(rsp.StatusCode >= 500 && rsp.Header.Get(admissionSignalHeaderKey) != admissionSignalHeaderValue)
+
+	rtt := ac.now().Sub(start.t)
+
+	ac.mu.Lock()
+	limit := ac.limiter.update(rtt, start.inflight, dropped)
+	ac.mu.Unlock()
+
+	ac.limit.Store(int64(limit))
+	ac.metrics.UpdateGauge(adaptivePrefix+"limit."+ac.metricSuffix, limit)
+
+	if ac.mode == logInactive {
+		log.Infof("%s: rtt: %v, dropped: %v, limit: %0.2f", filters.AdaptiveConcurrencyName, rtt, dropped, limit)
+	}
+}
+
+func (ac *adaptiveConcurrency) startSpan(ctx filters.FilterContext) opentracing.Span {
+	span := ac.tracer.StartSpan(adaptiveConcurrencySpanName, opentracing.ChildOf(ctx.ParentSpan().Context()))
+	ext.Component.Set(span, "skipper")
+	return span
+}
+
+// HandleErrorResponse is to opt-in for filters to get called
+// Response(ctx) in case of errors via proxy. It has to return true to
+// opt-in.
+func (ac *adaptiveConcurrency) HandleErrorResponse() bool { return true }
@@ -0,0 +1,221 @@
+package shedder
+
+import (
+	"math"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/filters/filtertest"
+	"github.com/zalando/skipper/metrics/metricstest"
+	"github.com/zalando/skipper/scheduler"
+)
+
+func TestAdaptiveConcurrencyCreateFilter(t *testing.T) {
+	spec := NewAdaptiveConcurrency(Options{})
+	assert.Equal(t, filters.AdaptiveConcurrencyName, spec.Name())
+
+	for _, ti := range []struct {
+		msg  string
+		args []interface{}
+		err  bool
+	}{{
+		msg:  "gradient",
+		args: []interface{}{"app", "active", "gradient", 10, 100, 20},
+	}, {
+		msg:  "aimd with latency threshold",
+		args: []interface{}{"app", "logInactive", "aimd", 10.0, 100.0, 20.0, "100ms"},
+	}, {
+		msg:  "too few args",
+		args: []interface{}{"app", "active", "gradient", 10, 100},
+		err:  true,
+	}, {
+		msg:  "too many args",
+		args: []interface{}{"app", "active", "aimd", 10, 100, 20, "1s", 1},
+		err:  true,
+	}, {
+		msg:  "unknown mode",
+		args: []interface{}{"app", "foo", "gradient", 10, 100, 20},
+		err:  true,
+	}, {
+		msg:  "unknown algorithm",
+		args: []interface{}{"app", "active", "vegas", 10, 100, 20},
+		err:  true,
+	}, {
+		msg:  "min limit larger than max limit",
+		args: []interface{}{"app", "active", "gradient", 100, 10, 20},
+		err:  true,
+	}, {
+		msg:  "initial limit out of bounds",
+		args: []interface{}{"app", "active", "gradient", 10, 100, 200},
+		err:  true,
+	}, {
+		msg:  "zero min limit",
+		args: []interface{}{"app", "active", "gradient", 0, 100, 20},
+		err:  true,
+	}, {
+		msg:  "invalid latency threshold",
+		args: []interface{}{"app", "active", "aimd", 10, 100, 20, "-1s"},
+		err:  true,
+	}} {
+		t.Run(ti.msg, func(t *testing.T) {
+			_, err := spec.CreateFilter(ti.args)
+			if ti.err {
+				assert.ErrorIs(t, err, filters.ErrInvalidFilterParameters)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestAIMDLimiter(t *testing.T) {
+	l := &aimdLimiter{limit: 10, min: 5, max: 12, latencyThreshold: time.Second}
+
+	assert.Equal(t, 10.0, l.update(10*time.Millisecond, 2, false), "app limited should not grow")
+	assert.Equal(t, 11.0, l.update(10*time.Millisecond, 5, false))
+	assert.Equal(t, 12.0, l.update(10*time.Millisecond, 6, false))
+	assert.Equal(t, 12.0, l.update(10*time.Millisecond, 6, false), "should not exceed max")
+	assert.Equal(t, 10.0, l.update(10*time.Millisecond, 6, true), "drop should back off")
+	assert.Equal(t, 9.0, l.update(2*time.Second, 6, false), "slow request should back off")
+
+	for i := 0; i < 10; i++ {
+		l.update(0, 6, true)
+	}
+	assert.Equal(t, 5.0, l.limit, "should not go below min")
+}
+
+func TestGradientLimiter(t *testing.T) {
+	l := &gradientLimiter{limit: 20, min: 5, max: 100}
+
+	for i := 0; i < 100; i++ {
+		l.update(10*time.Millisecond, int(l.limit), false)
+	}
+	grown := l.limit
+	assert.Greater(t, grown, 20.0, "stable latency should grow the limit")
+
+	for i := 0; i < 20; i++ {
+		l.update(100*time.Millisecond, int(l.limit), false)
+	}
+	assert.Less(t, l.limit, grown, "increasing latency should shrink the limit")
+	assert.GreaterOrEqual(t, l.limit, 5.0)
+
+	limit := l.limit
+	l.update(10*time.Millisecond, 0, false)
+	assert.Equal(t, limit, l.limit, "app limited should not change the limit")
+}
+
+func TestAdaptiveConcurrencyRejects(t *testing.T) {
+	for _, ti := range []struct {
+		mode          string
+		expectReject  bool
+		expectCounter bool
+	}{{
+		mode:          "active",
+		expectReject:  true,
+		expectCounter: true,
+	}, {
+		mode:          "logInactive",
+		expectReject:  false,
+		expectCounter: true,
+	}, {
+		mode:          "inactive",
+		expectReject:  false,
+		expectCounter: false,
+	}} {
+		t.Run(ti.mode, func(t *testing.T) {
+			m := &metricstest.MockMetrics{}
+			defer m.Close()
+
+			spec := NewAdaptiveConcurrency(Options{})
+			f, err := spec.CreateFilter([]interface{}{"test", ti.mode, "aimd", 1, 10, 2, "1s"})
+			require.NoError(t, err)
+
+			ac := f.(*adaptiveConcurrency)
+			ac.metrics = m
+			now := time.Now()
+			ac.now = func() time.Time { return now }
+
+			newCtx := func() *filtertest.Context {
+				req, _ := http.NewRequest("GET", "http://example.org", nil)
+				return &filtertest.Context{
+					FRequest:  req,
+					FStateBag: make(map[string]interface{}),
+				}
+			}
+
+			ctx1, ctx2, ctx3 := newCtx(), newCtx(), newCtx()
+			f.Request(ctx1)
+			f.Request(ctx2)
+			f.Request(ctx3)
+
+			assert.False(t, ctx1.FServed)
+			assert.False(t, ctx2.FServed)
+			assert.Equal(t, ti.expectReject, ctx3.FServed)
+			if ti.expectReject {
+				assert.Equal(t, http.StatusServiceUnavailable, ctx3.FResponse.StatusCode)
+				assert.Equal(t, admissionSignalHeaderValue, ctx3.FResponse.Header.Get(admissionSignalHeaderKey))
+			}
+
+			m.WithCounters(func(counters map[string]int64) {
+				assert.Equal(t, int64(3), counters["shedder.adaptive_concurrency.total.test"])
+				if ti.expectCounter {
+					assert.Equal(t, int64(1), counters["shedder.adaptive_concurrency.reject.test"])
+				} else {
+					assert.Equal(t, int64(0), counters["shedder.adaptive_concurrency.reject.test"])
+				}
+			})
+
+			for _, ctx := range []*filtertest.Context{ctx1, ctx2, ctx3} {
+				if !ctx.FServed {
+					ctx.FResponse = &http.Response{StatusCode: http.StatusOK, Header: make(http.Header)}
+				}
+				f.Response(ctx)
+			}
+			assert.Equal(t, int64(0), ac.inflight.Load())
+
+			limit, ok := m.Gauge("shedder.adaptive_concurrency.limit.test")
+			assert.True(t, ok)
+			assert.Equal(t, float64(ac.Limit()), limit)
+			assert.Greater(t, ac.Limit(), 2, "successful saturated requests should grow the limit")
+		})
+	}
+}
+
+func TestAdaptiveConcurrencyPriority(t *testing.T) {
+	assert.Equal(t, int64(10), priorityLimit(10, scheduler.PriorityDefault))
+	assert.Equal(t, int64(5), priorityLimit(10, scheduler.PrioritySheddable))
+	assert.Equal(t, int64(1), priorityLimit(1, scheduler.PrioritySheddable))
+	assert.Equal(t, int64(math.MaxInt64), priorityLimit(10, scheduler.PriorityCritical))
+
+	spec := NewAdaptiveConcurrency(Options{})
+	f, err := spec.CreateFilter([]interface{}{"test", "active", "aimd", 1, 10, 2})
+	require.NoError(t, err)
+
+	newCtx := func(p scheduler.Priority) *filtertest.Context {
+		req, _ := http.NewRequest("GET", "http://example.org", nil)
+		return &filtertest.Context{
+			FRequest:  req,
+			FStateBag: map[string]interface{}{scheduler.PriorityKey: p},
+		}
+	}
+
+	ctx1 := newCtx(scheduler.PriorityDefault)
+	f.Request(ctx1)
+	assert.False(t, ctx1.FServed)
+
+	ctx2 := newCtx(scheduler.PrioritySheddable)
+	f.Request(ctx2)
+	assert.True(t, ctx2.FServed, "sheddable request should be rejected above half of the limit")
+
+	ctx3 := newCtx(scheduler.PriorityDefault)
+	f.Request(ctx3)
+	assert.False(t, ctx3.FServed)
+
+	ctx4 := newCtx(scheduler.PriorityCritical)
+	f.Request(ctx4)
+	assert.False(t, ctx4.FServed, "critical request should not be rejected")
+}
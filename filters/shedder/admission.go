@@ -16,6 +16,7 @@ import (
 	"github.com/zalando/skipper/filters"
 	"github.com/zalando/skipper/metrics"
 	"github.com/zalando/skipper/routing"
+	"github.com/zalando/skipper/scheduler"
 )
 
 func getIntArg(a interface{}) (int, error) {
@@ -163,6 +164,13 @@ func (spec *admissionControlPost) Do(routes []*routing.Route) []*routing.Route {
 	return routes
 }
 
+// DoRollout implements routing.RolloutPostProcessor. The filters of the
+// routes under staged rollout are closed together with the routes, and
+// they don't replace the filters of the current routes.
+func (spec *admissionControlPost) DoRollout(routes []*routing.Route) []*routing.Route {
+	return routes
+}
+
 type AdmissionControlSpec struct {
 	tracer opentracing.Tracer
 }
@@ -404,8 +412,17 @@ func (ac *admissionControl) pReject() float64 {
 	return math.Max(rejectP, 0.0)
 }
 
-func (ac *admissionControl) shouldReject() bool {
+// shouldReject never rejects critical requests and rejects sheddable
+// requests with twice the probability.
+func (ac *admissionControl) shouldReject(priority scheduler.Priority) bool {
+	if priority == scheduler.PriorityCritical {
+		return false
+	}
+
 	p := ac.pReject() // [0, ac.maxRejectProbability] and -1 to disable
+	if priority == scheduler.PrioritySheddable && p > 0 {
+		p = math.Min(2*p, 1)
+	}
 	/* #nosec */
 	r := rand.Float64() // [0,1)
 
@@ -423,7 +440,7 @@ func (ac *admissionControl) Request(ctx filters.FilterContext) {
 	ac.setCommonTags(span)
 	ac.metrics.IncCounter(counterPrefix + "total." + ac.metricSuffix)
 
-	if ac.shouldReject() {
+	if ac.shouldReject(scheduler.GetPriority(ctx.StateBag())) {
 		ac.metrics.IncCounter(counterPrefix + "reject." + ac.metricSuffix)
 		ext.Error.Set(span, true)
 
@@ -0,0 +1,118 @@
+package graphql
+
+import (
+	"fmt"
+	"math"
+)
+
+// Stats contains the measures of an operation, used to limit the cost of
+// the requests. The fragment spreads are expanded.
+type Stats struct {
+
+	// Depth is the maximum nesting of the fields. The top level fields
+	// have depth 1.
+	Depth int
+
+	// Complexity is the number of fields that the operation selects.
+	Complexity int
+
+	// Aliases is the number of aliased fields.
+	Aliases int
+}
+
+type analysis struct {
+	fragments map[string][]*Selection
+	visiting  map[string]bool
+	done      map[string]Stats
+}
+
+func saturatingAdd(a, b int) int {
+	if a > math.MaxInt32-b {
+		return math.MaxInt32
+	}
+
+	return a + b
+}
+
+func (s Stats) add(other Stats) Stats {
+	return Stats{
+		Depth:      max(s.Depth, other.Depth),
+		Complexity: saturatingAdd(s.Complexity, other.Complexity),
+		Aliases:    saturatingAdd(s.Aliases, other.Aliases),
+	}
+}
+
+// Stats returns the measures of an operation of the document. It fails
+// when the operation uses undefined or cyclic fragments.
+func (d *Document) Stats(o *Operation) (Stats, error) {
+	a := &analysis{
+		fragments: d.Fragments,
+		visiting:  make(map[string]bool),
+		done:      make(map[string]Stats),
+	}
+
+	return a.selectionSet(o.SelectionSet)
+}
+
+func (a *analysis) selectionSet(selections []*Selection) (Stats, error) {
+	var stats Stats
+	for _, s := range selections {
+		ss, err := a.selection(s)
+		if err != nil {
+			return Stats{}, err
+		}
+
+		stats = stats.add(ss)
+	}
+
+	return stats, nil
+}
+
+func (a *analysis) selection(s *Selection) (Stats, error) {
+	switch {
+	case s.FragmentSpread:
+		return a.fragment(s.Name)
+	case s.InlineFragment:
+		return a.selectionSet(s.SelectionSet)
+	}
+
+	stats, err := a.selectionSet(s.SelectionSet)
+	if err != nil {
+		return Stats{}, err
+	}
+
+	stats.Depth++
+	stats.Complexity = saturatingAdd(stats.Complexity, 1)
+	if s.Alias != "" {
+		stats.Aliases = saturatingAdd(stats.Aliases, 1)
+	}
+
+	return stats, nil
+}
+
+// fragment returns the measures of a fragment. They don't depend on where
+// the fragment is spread, so they are calculated once.
+func (a *analysis) fragment(name string) (Stats, error) {
+	if stats, ok := a.done[name]; ok {
+		return stats, nil
+	}
+
+	selections, ok := a.fragments[name]
+	if !ok {
+		return Stats{}, fmt.Errorf("%w: undefined fragment %q", ErrInvalidDocument, name)
+	}
+
+	if a.visiting[name] {
+		return Stats{}, fmt.Errorf("%w: fragment cycle at %q", ErrInvalidDocument, name)
+	}
+
+	a.visiting[name] = true
+	stats, err := a.selectionSet(selections)
+	if err != nil {
+		return Stats{}, err
+	}
+
+	delete(a.visiting, name)
+	a.done[name] = stats
+	return stats, nil
+}
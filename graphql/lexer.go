@@ -0,0 +1,140 @@
+package graphql
+
+import (
+	"fmt"
+	"strings"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenPunctuator
+	tokenName
+	tokenNumber
+	tokenString
+)
+
+type token struct {
+	kind  tokenKind
+	value string
+	pos   int
+}
+
+// lexer splits a GraphQL document into tokens. It skips the ignored
+// tokens: whitespace, commas, comments and the unicode BOM. The values of
+// the string tokens are not unescaped, because only the structure of the
+// documents is analysed.
+type lexer struct {
+	input string
+	pos   int
+}
+
+func (t token) String() string {
+	if t.kind == tokenEOF {
+		return "end of document"
+	}
+
+	return fmt.Sprintf("%q", t.value)
+}
+
+func isNameStart(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+func isNameContinue(c byte) bool {
+	return isNameStart(c) || c >= '0' && c <= '9'
+}
+
+func isNumberContinue(c byte) bool {
+	return c >= '0' && c <= '9' || c == '.' || c == 'e' || c == 'E' || c == '+' || c == '-'
+}
+
+func (l *lexer) errorf(pos int, format string, args ...interface{}) error {
+	return fmt.Errorf("%w at %d: %s", ErrInvalidDocument, pos, fmt.Sprintf(format, args...))
+}
+
+func (l *lexer) skipIgnored() {
+	for l.pos < len(l.input) {
+		switch c := l.input[l.pos]; {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == ',':
+			l.pos++
+		case c == '#':
+			for l.pos < len(l.input) && l.input[l.pos] != '\n' && l.input[l.pos] != '\r' {
+				l.pos++
+			}
+		case strings.HasPrefix(l.input[l.pos:], "\uFEFF"):
+			l.pos += len("\uFEFF")
+		default:
+			return
+		}
+	}
+}
+
+func (l *lexer) next() (token, error) {
+	l.skipIgnored()
+	start := l.pos
+	if l.pos >= len(l.input) {
+		return token{kind: tokenEOF, pos: start}, nil
+	}
+
+	c := l.input[l.pos]
+	switch {
+	case strings.HasPrefix(l.input[l.pos:], "..."):
+		l.pos += 3
+		return token{kind: tokenPunctuator, value: "...", pos: start}, nil
+	case strings.IndexByte("!$&():=@[]{|}", c) >= 0:
+		l.pos++
+		return token{kind: tokenPunctuator, value: l.input[start:l.pos], pos: start}, nil
+	case isNameStart(c):
+		for l.pos < len(l.input) && isNameContinue(l.input[l.pos]) {
+			l.pos++
+		}
+
+		return token{kind: tokenName, value: l.input[start:l.pos], pos: start}, nil
+	case c == '-' || c >= '0' && c <= '9':
+		l.pos++
+		for l.pos < len(l.input) && isNumberContinue(l.input[l.pos]) {
+			l.pos++
+		}
+
+		return token{kind: tokenNumber, value: l.input[start:l.pos], pos: start}, nil
+	case strings.HasPrefix(l.input[l.pos:], `"""`):
+		end := l.pos + 3
+		for {
+			i := strings.Index(l.input[end:], `"""`)
+			if i < 0 {
+				return token{}, l.errorf(start, "unterminated block string")
+			}
+
+			end += i
+			if l.input[end-1] != '\\' {
+				break
+			}
+
+			end += 3
+		}
+
+		l.pos = end + 3
+		return token{kind: tokenString, value: l.input[start:l.pos], pos: start}, nil
+	case c == '"':
+		l.pos++
+		for l.pos < len(l.input) {
+			switch l.input[l.pos] {
+			case '\\':
+				l.pos += 2
+			case '"':
+				l.pos++
+				return token{kind: tokenString, value: l.input[start:l.pos], pos: start}, nil
+			case '\n', '\r':
+				return token{}, l.errorf(start, "unterminated string")
+			default:
+				l.pos++
+			}
+		}
+
+		return token{}, l.errorf(start, "unterminated string")
+	default:
+		return token{}, l.errorf(start, "unexpected character %q", c)
+	}
+}
@@ -0,0 +1,535 @@
+package graphql
+
+import (
+	"errors"
+)
+
+// maxNesting limits the nesting of the selection sets, values and types
+// during parsing.
+const maxNesting = 512
+
+// ErrInvalidDocument is returned when a GraphQL document cannot be parsed.
+var ErrInvalidDocument = errors.New("invalid graphql document")
+
+// OperationType is the type of a GraphQL operation.
+type OperationType string
+
+const (
+	Query        OperationType = "query"
+	Mutation     OperationType = "mutation"
+	Subscription OperationType = "subscription"
+)
+
+// Selection is a field, a fragment spread or an inline fragment.
+type Selection struct {
+
+	// Name is the name of the field, or the name of the fragment of a
+	// fragment spread.
+	Name string
+
+	// Alias is the alias of the field, when set.
+	Alias string
+
+	// FragmentSpread is true for fragment spreads.
+	FragmentSpread bool
+
+	// InlineFragment is true for inline fragments.
+	InlineFragment bool
+
+	// SelectionSet contains the selections of the field or the inline
+	// fragment.
+	SelectionSet []*Selection
+}
+
+// Operation is an operation definition of a GraphQL document.
+type Operation struct {
+	Type         OperationType
+	Name         string
+	SelectionSet []*Selection
+}
+
+// Document is a parsed GraphQL executable document. It contains only the
+// parts needed to route and limit the requests: the types and names of
+// the operations, and the structure of their selections.
+type Document struct {
+	Operations []*Operation
+	Fragments  map[string][]*Selection
+}
+
+type parser struct {
+	lexer   lexer
+	current token
+	nesting int
+}
+
+// Parse parses a GraphQL executable document.
+func Parse(s string) (*Document, error) {
+	p := &parser{lexer: lexer{input: s}}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+
+	d := &Document{Fragments: make(map[string][]*Selection)}
+	for p.current.kind != tokenEOF {
+		if err := p.parseDefinition(d); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(d.Operations) == 0 {
+		return nil, p.errorf("no operation")
+	}
+
+	return d, nil
+}
+
+func (p *parser) advance() error {
+	t, err := p.lexer.next()
+	if err != nil {
+		return err
+	}
+
+	p.current = t
+	return nil
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	return p.lexer.errorf(p.current.pos, format, args...)
+}
+
+func (p *parser) unexpected() error {
+	return p.errorf("unexpected %v", p.current)
+}
+
+func (p *parser) is(kind tokenKind, value string) bool {
+	return p.current.kind == kind && p.current.value == value
+}
+
+func (p *parser) isPunctuator(value string) bool {
+	return p.is(tokenPunctuator, value)
+}
+
+func (p *parser) expectPunctuator(value string) error {
+	if !p.isPunctuator(value) {
+		return p.unexpected()
+	}
+
+	return p.advance()
+}
+
+func (p *parser) expectName() (string, error) {
+	if p.current.kind != tokenName {
+		return "", p.unexpected()
+	}
+
+	name := p.current.value
+	return name, p.advance()
+}
+
+func (p *parser) enter() error {
+	p.nesting++
+	if p.nesting > maxNesting {
+		return p.errorf("maximum nesting exceeded")
+	}
+
+	return nil
+}
+
+func (p *parser) leave() {
+	p.nesting--
+}
+
+func (p *parser) parseDefinition(d *Document) error {
+	if p.isPunctuator("{") {
+		s, err := p.parseSelectionSet()
+		if err != nil {
+			return err
+		}
+
+		d.Operations = append(d.Operations, &Operation{Type: Query, SelectionSet: s})
+		return nil
+	}
+
+	if p.current.kind != tokenName {
+		return p.unexpected()
+	}
+
+	switch p.current.value {
+	case string(Query), string(Mutation), string(Subscription):
+		o, err := p.parseOperation()
+		if err != nil {
+			return err
+		}
+
+		d.Operations = append(d.Operations, o)
+		return nil
+	case "fragment":
+		name, s, err := p.parseFragment()
+		if err != nil {
+			return err
+		}
+
+		if _, exists := d.Fragments[name]; exists {
+			return p.errorf("duplicate fragment %q", name)
+		}
+
+		d.Fragments[name] = s
+		return nil
+	default:
+		return p.unexpected()
+	}
+}
+
+func (p *parser) parseOperation() (*Operation, error) {
+	o := &Operation{Type: OperationType(p.current.value)}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+
+	if p.current.kind == tokenName {
+		o.Name = p.current.value
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	}
+
+	if p.isPunctuator("(") {
+		if err := p.parseVariableDefinitions(); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := p.parseDirectives(); err != nil {
+		return nil, err
+	}
+
+	s, err := p.parseSelectionSet()
+	if err != nil {
+		return nil, err
+	}
+
+	o.SelectionSet = s
+	return o, nil
+}
+
+func (p *parser) parseFragment() (string, []*Selection, error) {
+	if err := p.advance(); err != nil {
+		return "", nil, err
+	}
+
+	name, err := p.expectName()
+	if err != nil {
+		return "", nil, err
+	}
+
+	if name == "on" {
+		return "", nil, p.errorf("invalid fragment name")
+	}
+
+	if !p.is(tokenName, "on") {
+		return "", nil, p.unexpected()
+	}
+
+	if err := p.advance(); err != nil {
+		return "", nil, err
+	}
+
+	if _, err := p.expectName(); err != nil {
+		return "", nil, err
+	}
+
+	if err := p.parseDirectives(); err != nil {
+		return "", nil, err
+	}
+
+	s, err := p.parseSelectionSet()
+	return name, s, err
+}
+
+func (p *parser) parseVariableDefinitions() error {
+	if err := p.advance(); err != nil {
+		return err
+	}
+
+	for !p.isPunctuator(")") {
+		if err := p.expectPunctuator("$"); err != nil {
+			return err
+		}
+
+		if _, err := p.expectName(); err != nil {
+			return err
+		}
+
+		if err := p.expectPunctuator(":"); err != nil {
+			return err
+		}
+
+		if err := p.parseType(); err != nil {
+			return err
+		}
+
+		if p.isPunctuator("=") {
+			if err := p.advance(); err != nil {
+				return err
+			}
+
+			if err := p.parseValue(); err != nil {
+				return err
+			}
+		}
+
+		if err := p.parseDirectives(); err != nil {
+			return err
+		}
+	}
+
+	return p.advance()
+}
+
+func (p *parser) parseType() error {
+	if err := p.enter(); err != nil {
+		return err
+	}
+
+	defer p.leave()
+	if p.isPunctuator("[") {
+		if err := p.advance(); err != nil {
+			return err
+		}
+
+		if err := p.parseType(); err != nil {
+			return err
+		}
+
+		if err := p.expectPunctuator("]"); err != nil {
+			return err
+		}
+	} else if _, err := p.expectName(); err != nil {
+		return err
+	}
+
+	if p.isPunctuator("!") {
+		return p.advance()
+	}
+
+	return nil
+}
+
+func (p *parser) parseValue() error {
+	if err := p.enter(); err != nil {
+		return err
+	}
+
+	defer p.leave()
+	switch {
+	case p.isPunctuator("$"):
+		if err := p.advance(); err != nil {
+			return err
+		}
+
+		_, err := p.expectName()
+		return err
+	case p.isPunctuator("["):
+		if err := p.advance(); err != nil {
+			return err
+		}
+
+		for !p.isPunctuator("]") {
+			if err := p.parseValue(); err != nil {
+				return err
+			}
+		}
+
+		return p.advance()
+	case p.isPunctuator("{"):
+		if err := p.advance(); err != nil {
+			return err
+		}
+
+		for !p.isPunctuator("}") {
+			if _, err := p.expectName(); err != nil {
+				return err
+			}
+
+			if err := p.expectPunctuator(":"); err != nil {
+				return err
+			}
+
+			if err := p.parseValue(); err != nil {
+				return err
+			}
+		}
+
+		return p.advance()
+	case p.current.kind == tokenName, p.current.kind == tokenNumber, p.current.kind == tokenString:
+		return p.advance()
+	default:
+		return p.unexpected()
+	}
+}
+
+func (p *parser) parseArguments() error {
+	if !p.isPunctuator("(") {
+		return nil
+	}
+
+	if err := p.advance(); err != nil {
+		return err
+	}
+
+	for !p.isPunctuator(")") {
+		if _, err := p.expectName(); err != nil {
+			return err
+		}
+
+		if err := p.expectPunctuator(":"); err != nil {
+			return err
+		}
+
+		if err := p.parseValue(); err != nil {
+			return err
+		}
+	}
+
+	return p.advance()
+}
+
+func (p *parser) parseDirectives() error {
+	for p.isPunctuator("@") {
+		if err := p.advance(); err != nil {
+			return err
+		}
+
+		if _, err := p.expectName(); err != nil {
+			return err
+		}
+
+		if err := p.parseArguments(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (p *parser) parseSelectionSet() ([]*Selection, error) {
+	if err := p.enter(); err != nil {
+		return nil, err
+	}
+
+	defer p.leave()
+	if err := p.expectPunctuator("{"); err != nil {
+		return nil, err
+	}
+
+	var selections []*Selection
+	for !p.isPunctuator("}") {
+		s, err := p.parseSelection()
+		if err != nil {
+			return nil, err
+		}
+
+		selections = append(selections, s)
+	}
+
+	if len(selections) == 0 {
+		return nil, p.errorf("empty selection set")
+	}
+
+	return selections, p.advance()
+}
+
+func (p *parser) parseSelection() (*Selection, error) {
+	if p.isPunctuator("...") {
+		return p.parseFragmentSelection()
+	}
+
+	name, err := p.expectName()
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Selection{Name: name}
+	if p.isPunctuator(":") {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+
+		s.Alias = name
+		if s.Name, err = p.expectName(); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := p.parseArguments(); err != nil {
+		return nil, err
+	}
+
+	if err := p.parseDirectives(); err != nil {
+		return nil, err
+	}
+
+	if p.isPunctuator("{") {
+		if s.SelectionSet, err = p.parseSelectionSet(); err != nil {
+			return nil, err
+		}
+	}
+
+	return s, nil
+}
+
+func (p *parser) parseFragmentSelection() (*Selection, error) {
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+
+	if p.current.kind == tokenName && p.current.value != "on" {
+		s := &Selection{Name: p.current.value, FragmentSpread: true}
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+
+		return s, p.parseDirectives()
+	}
+
+	if p.is(tokenName, "on") {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+
+		if _, err := p.expectName(); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := p.parseDirectives(); err != nil {
+		return nil, err
+	}
+
+	s, err := p.parseSelectionSet()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Selection{InlineFragment: true, SelectionSet: s}, nil
+}
+
+// Operation returns the operation to execute. When the name is empty,
+// the document must contain a single operation.
+func (d *Document) Operation(name string) (*Operation, bool) {
+	if name == "" {
+		if len(d.Operations) != 1 {
+			return nil, false
+		}
+
+		return d.Operations[0], true
+	}
+
+	for _, o := range d.Operations {
+		if o.Name == name {
+			return o, true
+		}
+	}
+
+	return nil, false
+}
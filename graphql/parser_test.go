@@ -0,0 +1,145 @@
+package graphql
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	d, err := Parse(`
+		# comment
+		query GetUser($id: ID! = "1", $tags: [String!]! @deprecated) @cached(ttl: 60) {
+			user(id: $id, filter: {tags: $tags, active: true, score: -1.5e3, kind: ADMIN, list: [1, 2, null]}) {
+			  name
+			  friends: contacts(first: 10) @include(if: true) { ...userFields }
+			  ... on Admin { permissions }
+			  ... @skip(if: false) { email }
+			  bio(format: """block "string" \""" """)
+			}
+		}
+
+		mutation { deleteUser(id: "2\"") }
+
+		fragment userFields on User { id name }
+	`)
+	require.NoError(t, err)
+
+	require.Len(t, d.Operations, 2)
+	assert.Equal(t, Query, d.Operations[0].Type)
+	assert.Equal(t, "GetUser", d.Operations[0].Name)
+	assert.Equal(t, Mutation, d.Operations[1].Type)
+	assert.Equal(t, "", d.Operations[1].Name)
+	assert.Contains(t, d.Fragments, "userFields")
+
+	user := d.Operations[0].SelectionSet[0]
+	assert.Equal(t, "user", user.Name)
+	require.Len(t, user.SelectionSet, 5)
+	assert.Equal(t, &Selection{Name: "contacts", Alias: "friends", SelectionSet: []*Selection{{Name: "userFields", FragmentSpread: true}}}, user.SelectionSet[1])
+	assert.True(t, user.SelectionSet[2].InlineFragment)
+	assert.True(t, user.SelectionSet[3].InlineFragment)
+	assert.Equal(t, "bio", user.SelectionSet[4].Name)
+
+	o, ok := d.Operation("GetUser")
+	assert.True(t, ok)
+	assert.Equal(t, d.Operations[0], o)
+
+	_, ok = d.Operation("")
+	assert.False(t, ok)
+
+	_, ok = d.Operation("foo")
+	assert.False(t, ok)
+}
+
+func TestParseShorthand(t *testing.T) {
+	d, err := Parse(`{ users { id } }`)
+	require.NoError(t, err)
+
+	o, ok := d.Operation("")
+	require.True(t, ok)
+	assert.Equal(t, Query, o.Type)
+	assert.Equal(t, "", o.Name)
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, doc := range []string{
+		``,
+		`# only a comment`,
+		`fragment f on User { id }`,
+		`query {}`,
+		`query { user(id: ) { id } }`,
+		`query { user { id }`,
+		`query { user(id: "1) { id } }`,
+		`query { user(id: """1) { id } }`,
+		`query ($id) { user { id } }`,
+		`subscription { foo } extra`,
+		`type User { id: ID }`,
+		`query { foo } fragment on on User { id }`,
+		`query { foo } fragment f on User { id } fragment f on User { name }`,
+		`query { foo % }`,
+		strings.Repeat("{ a ", 1000) + strings.Repeat("}", 1000),
+	} {
+		_, err := Parse(doc)
+		assert.True(t, errors.Is(err, ErrInvalidDocument), "%s: %v", doc, err)
+	}
+}
+
+func TestStats(t *testing.T) {
+	for _, tt := range []struct {
+		doc    string
+		expect Stats
+		err    bool
+	}{{
+		doc:    `{ a }`,
+		expect: Stats{Depth: 1, Complexity: 1},
+	}, {
+		doc:    `{ a { b { c } d } e: f }`,
+		expect: Stats{Depth: 3, Complexity: 5, Aliases: 1},
+	}, {
+		doc:    `{ a { ...f ... on T { x: b { c } } } } fragment f on T { y: b { c { d } } }`,
+		expect: Stats{Depth: 4, Complexity: 6, Aliases: 2},
+	}, {
+		doc:    `{ ...f1 } fragment f1 on Q { ...f2 ...f2 } fragment f2 on Q { a b }`,
+		expect: Stats{Depth: 1, Complexity: 4},
+	}, {
+		doc: `{ ...f1 } fragment f1 on Q { a ...f2 } fragment f2 on Q { ...f1 }`,
+		err: true,
+	}, {
+		doc: `{ ...missing }`,
+		err: true,
+	}} {
+		d, err := Parse(tt.doc)
+		require.NoError(t, err, tt.doc)
+
+		stats, err := d.Stats(d.Operations[0])
+		if tt.err {
+			assert.ErrorIs(t, err, ErrInvalidDocument, tt.doc)
+			continue
+		}
+
+		require.NoError(t, err, tt.doc)
+		assert.Equal(t, tt.expect, stats, tt.doc)
+	}
+}
+
+func TestStatsFragmentBomb(t *testing.T) {
+	var doc strings.Builder
+	doc.WriteString("{ ...f0 }")
+	for i := 0; i < 32; i++ {
+		fmt.Fprintf(&doc, " fragment f%d on Q { ...f%d ...f%d }", i, i+1, i+1)
+	}
+
+	doc.WriteString(" fragment f32 on Q { a }")
+	d, err := Parse(doc.String())
+	require.NoError(t, err)
+
+	stats, err := d.Stats(d.Operations[0])
+	require.NoError(t, err)
+	assert.Equal(t, 1, stats.Depth)
+	assert.Equal(t, math.MaxInt32, stats.Complexity)
+}
@@ -0,0 +1,227 @@
+/*
+Package graphql parses the GraphQL requests sent over HTTP, to route,
+limit and measure them by their operations.
+
+The requests are accepted in the formats of the GraphQL over HTTP
+specification: GET requests with the query, operationName and extensions
+URL parameters, POST requests with a JSON body containing the same fields,
+and POST requests with an application/graphql body. Automatic persisted
+queries are supported by reading the sha256Hash of the persistedQuery
+extension. Batched requests are not supported.
+*/
+package graphql
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"mime"
+	"net/http"
+
+	skpio "github.com/zalando/skipper/io"
+)
+
+// DefaultMaxBodySize is the default maximum size of the request bodies
+// that are parsed.
+const DefaultMaxBodySize = 64 * 1024
+
+var (
+	// ErrNotGraphQL is returned for requests that don't contain a
+	// GraphQL operation.
+	ErrNotGraphQL = errors.New("not a graphql request")
+
+	// ErrBodyTooLarge is returned when the request body exceeds the
+	// maximum size.
+	ErrBodyTooLarge = errors.New("graphql request body too large")
+
+	// ErrUnknownOperation is returned when the document doesn't contain
+	// the requested operation.
+	ErrUnknownOperation = errors.New("unknown graphql operation")
+)
+
+// Request is a parsed GraphQL request.
+type Request struct {
+
+	// Query is the GraphQL document sent by the client. It is empty
+	// when the client sent only the hash of a persisted query.
+	Query string
+
+	// PersistedQueryHash is the SHA-256 hash of the query sent in the
+	// persistedQuery extension, in hex format.
+	PersistedQueryHash string
+
+	// Document is the parsed query. It is nil when the client sent only
+	// the hash of a persisted query.
+	Document *Document
+
+	// Operation is the operation to execute. It is nil when the client
+	// sent only the hash of a persisted query.
+	Operation *Operation
+
+	// OperationName is the name of the executed operation, either
+	// sent by the client, or found in the document, when it contains a
+	// single, named operation.
+	OperationName string
+}
+
+// Options to parse the requests.
+type Options struct {
+
+	// MaxBodySize is the maximum size of the request bodies that are
+	// parsed. Defaults to DefaultMaxBodySize.
+	MaxBodySize int64
+}
+
+type params struct {
+	Query         string `json:"query"`
+	OperationName string `json:"operationName"`
+	Extensions    struct {
+		PersistedQuery struct {
+			SHA256Hash string `json:"sha256Hash"`
+		} `json:"persistedQuery"`
+	} `json:"extensions"`
+}
+
+type requestKey struct{}
+
+// parsed is the GraphQL request parsed from a body, cached by the skipper
+// io.Body for the predicates of several routes and the filters.
+type parsed struct {
+	request *Request
+	err     error
+}
+
+// OperationType returns the type of the executed operation, or an empty
+// string, when it is not known.
+func (r *Request) OperationType() OperationType {
+	if r.Operation == nil {
+		return ""
+	}
+
+	return r.Operation.Type
+}
+
+// Hash returns the SHA-256 hash of the query in hex format, used to
+// identify persisted queries. When the query was not sent, it returns
+// the hash sent by the client.
+func (r *Request) Hash() string {
+	if r.Query == "" {
+		return r.PersistedQueryHash
+	}
+
+	h := sha256.Sum256([]byte(r.Query))
+	return hex.EncodeToString(h[:])
+}
+
+// Stats returns the measures of the executed operation. See Stats.
+func (r *Request) Stats() (Stats, error) {
+	if r.Operation == nil {
+		return Stats{}, nil
+	}
+
+	return r.Document.Stats(r.Operation)
+}
+
+func (o Options) maxBodySize() int64 {
+	if o.MaxBodySize <= 0 {
+		return DefaultMaxBodySize
+	}
+
+	return o.MaxBodySize
+}
+
+func newRequest(p params) (*Request, error) {
+	r := &Request{
+		Query:              p.Query,
+		PersistedQueryHash: p.Extensions.PersistedQuery.SHA256Hash,
+		OperationName:      p.OperationName,
+	}
+
+	if r.Query == "" {
+		if r.PersistedQueryHash == "" {
+			return nil, ErrNotGraphQL
+		}
+
+		return r, nil
+	}
+
+	d, err := Parse(r.Query)
+	if err != nil {
+		return nil, err
+	}
+
+	o, ok := d.Operation(r.OperationName)
+	if !ok {
+		return nil, ErrUnknownOperation
+	}
+
+	r.Document = d
+	r.Operation = o
+	r.OperationName = o.Name
+	return r, nil
+}
+
+func parseGet(req *http.Request) (*Request, error) {
+	q := req.URL.Query()
+	p := params{Query: q.Get("query"), OperationName: q.Get("operationName")}
+	if ext := q.Get("extensions"); ext != "" {
+		if err := json.Unmarshal([]byte(ext), &p.Extensions); err != nil {
+			return nil, ErrNotGraphQL
+		}
+	}
+
+	return newRequest(p)
+}
+
+func parseBody(contentType string, b []byte) (*Request, error) {
+	switch contentType {
+	case "application/graphql":
+		return newRequest(params{Query: string(b)})
+	case "application/json", "application/graphql+json":
+		var p params
+		if err := json.Unmarshal(b, &p); err != nil {
+			return nil, ErrNotGraphQL
+		}
+
+		return newRequest(p)
+	default:
+		return nil, ErrNotGraphQL
+	}
+}
+
+// FromHTTP returns the GraphQL request contained by an HTTP request. The
+// body of the HTTP request is read up to the maximum size, and it is
+// restored for the backend. The result of parsing the body is cached for
+// the subsequent calls.
+func FromHTTP(req *http.Request, o Options) (*Request, error) {
+	if req.Method == "GET" {
+		return parseGet(req)
+	}
+
+	if req.Method != "POST" || req.Body == nil || req.Body == http.NoBody || req.ContentLength == 0 {
+		return nil, ErrNotGraphQL
+	}
+
+	contentType, _, err := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	if err != nil {
+		return nil, ErrNotGraphQL
+	}
+
+	b, err := skpio.ReadRequestBody(req, o.maxBodySize())
+	switch {
+	case errors.Is(err, skpio.ErrBodyTooLarge):
+		return nil, ErrBodyTooLarge
+	case err != nil:
+		return nil, err
+	case b == nil:
+		return nil, ErrNotGraphQL
+	}
+
+	p := b.Value(requestKey{}, func(content []byte) any {
+		r, err := parseBody(contentType, content)
+		return parsed{request: r, err: err}
+	}).(parsed)
+
+	return p.request, p.err
+}
@@ -0,0 +1,194 @@
+package graphql
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	skpio "github.com/zalando/skipper/io"
+)
+
+func TestFromHTTP(t *testing.T) {
+	const (
+		getUser  = `query GetUser { user { name } }`
+		twoOps   = `query A { a } mutation B { b }`
+		hash     = "ecf4edb46db40b5132295c0291d62fb65d6759a9eedfa4d5d612dd5ec54a6b38"
+		apqQuery = `{"extensions": {"persistedQuery": {"version": 1, "sha256Hash": "` + hash + `"}}}`
+	)
+
+	for _, tt := range []struct {
+		name          string
+		method        string
+		url           string
+		contentType   string
+		body          string
+		maxBodySize   int64
+		err           error
+		operationType OperationType
+		operationName string
+		hash          string
+	}{{
+		name:          "json",
+		method:        "POST",
+		contentType:   "application/json; charset=utf-8",
+		body:          `{"query": "` + getUser + `", "variables": {"id": 1}}`,
+		operationType: Query,
+		operationName: "GetUser",
+	}, {
+		name:          "graphql body",
+		method:        "POST",
+		contentType:   "application/graphql",
+		body:          `mutation { deleteUser }`,
+		operationType: Mutation,
+	}, {
+		name:          "operation name",
+		method:        "POST",
+		contentType:   "application/json",
+		body:          `{"query": "` + twoOps + `", "operationName": "B"}`,
+		operationType: Mutation,
+		operationName: "B",
+	}, {
+		name:        "missing operation name",
+		method:      "POST",
+		contentType: "application/json",
+		body:        `{"query": "` + twoOps + `"}`,
+		err:         ErrUnknownOperation,
+	}, {
+		name:          "get",
+		method:        "GET",
+		url:           "/graphql?query=" + url.QueryEscape(getUser),
+		operationType: Query,
+		operationName: "GetUser",
+	}, {
+		name:          "persisted query",
+		method:        "POST",
+		contentType:   "application/json",
+		body:          `{"operationName": "GetUser", ` + apqQuery[1:],
+		operationName: "GetUser",
+		hash:          hash,
+	}, {
+		name:   "persisted query get",
+		method: "GET",
+		url:    "/graphql?extensions=" + url.QueryEscape(apqQuery[len(`{"extensions": `):len(apqQuery)-1]),
+		hash:   hash,
+	}, {
+		name:   "not graphql",
+		method: "GET",
+		url:    "/graphql",
+		err:    ErrNotGraphQL,
+	}, {
+		name:        "not json",
+		method:      "POST",
+		contentType: "application/json",
+		body:        `[{"query": "{ a }"}]`,
+		err:         ErrNotGraphQL,
+	}, {
+		name:        "content type",
+		method:      "POST",
+		contentType: "text/plain",
+		body:        `{ a }`,
+		err:         ErrNotGraphQL,
+	}, {
+		name:        "invalid document",
+		method:      "POST",
+		contentType: "application/graphql",
+		body:        `{ a `,
+		err:         ErrInvalidDocument,
+	}, {
+		name:        "too large",
+		method:      "POST",
+		contentType: "application/graphql",
+		body:        `{ a b c d e f }`,
+		maxBodySize: 8,
+		err:         ErrBodyTooLarge,
+	}} {
+		t.Run(tt.name, func(t *testing.T) {
+			u := tt.url
+			if u == "" {
+				u = "/graphql"
+			}
+
+			var body io.Reader
+			if tt.body != "" {
+				body = strings.NewReader(tt.body)
+			}
+
+			req := httptest.NewRequest(tt.method, u, body)
+			req.Header.Set("Content-Type", tt.contentType)
+			if tt.maxBodySize == 0 {
+				// unknown content length
+				req.ContentLength = -1
+			}
+
+			r, err := FromHTTP(req, Options{MaxBodySize: tt.maxBodySize})
+			if tt.err != nil {
+				assert.ErrorIs(t, err, tt.err)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, tt.operationType, r.OperationType())
+				assert.Equal(t, tt.operationName, r.OperationName)
+				if tt.hash != "" {
+					assert.Equal(t, tt.hash, r.Hash())
+				}
+			}
+
+			// cached
+			r2, err2 := FromHTTP(req, Options{MaxBodySize: tt.maxBodySize})
+			assert.Equal(t, err, err2)
+			assert.Equal(t, r, r2)
+
+			if tt.body != "" {
+				b, err := io.ReadAll(req.Body)
+				require.NoError(t, err)
+				assert.Equal(t, tt.body, string(b))
+			}
+		})
+	}
+}
+
+func TestRequestHash(t *testing.T) {
+	req := httptest.NewRequest("POST", "/graphql", strings.NewReader(`{ hello }`))
+	req.Header.Set("Content-Type", "application/graphql")
+	r, err := FromHTTP(req, Options{})
+	require.NoError(t, err)
+
+	// echo -n '{ hello }' | sha256sum
+	assert.Equal(t, "001c3174e099bd72b729d0c0a529ba9f5a740c446e2a6e1d71b283cb84ec3065", r.Hash())
+}
+
+func TestFromHTTPUnsupportedMethod(t *testing.T) {
+	_, err := FromHTTP(httptest.NewRequest(http.MethodPut, "/graphql", strings.NewReader(`{ a }`)), Options{})
+	assert.ErrorIs(t, err, ErrNotGraphQL)
+}
+
+func TestFromHTTPSharedBody(t *testing.T) {
+	body := `{"query": "query GetUser { user { id } }"}`
+	req := httptest.NewRequest("POST", "/graphql", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+
+	// read before, e.g. by the body predicates
+	b, err := skpio.ReadRequestBody(req, 1024)
+	require.NoError(t, err)
+	require.NotNil(t, b)
+
+	r, err := FromHTTP(req, Options{})
+	require.NoError(t, err)
+	assert.Equal(t, "GetUser", r.OperationName)
+
+	r2, err := FromHTTP(req, Options{})
+	require.NoError(t, err)
+	assert.Same(t, r, r2)
+
+	_, err = FromHTTP(req, Options{MaxBodySize: 8})
+	assert.ErrorIs(t, err, ErrBodyTooLarge)
+
+	restored, err := io.ReadAll(req.Body)
+	require.NoError(t, err)
+	assert.Equal(t, body, string(restored))
+}
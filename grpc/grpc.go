@@ -0,0 +1,179 @@
+/*
+Package grpc contains the helpers to proxy gRPC requests: it detects the
+gRPC content types, maps HTTP errors to gRPC status codes, and reads the
+service and the method from the request paths.
+
+See https://github.com/grpc/grpc/blob/master/doc/PROTOCOL-HTTP2.md
+*/
+package grpc
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	// ContentType is the content type of the gRPC requests and
+	// responses. It can have a suffix, e.g. application/grpc+proto.
+	ContentType = "application/grpc"
+
+	// StatusHeader is the trailer, or, in case of trailers-only
+	// responses, the header containing the gRPC status code.
+	StatusHeader = "Grpc-Status"
+
+	// MessageHeader is the trailer, or, in case of trailers-only
+	// responses, the header containing the percent-encoded status
+	// message.
+	MessageHeader = "Grpc-Message"
+
+	// clientClosedRequest is the HTTP status used by the proxy, when
+	// the client canceled the request.
+	clientClosedRequest = 499
+)
+
+// Status is a gRPC status code, see
+// https://github.com/grpc/grpc/blob/master/doc/statuscodes.md
+type Status int
+
+const (
+	OK Status = iota
+	Canceled
+	Unknown
+	InvalidArgument
+	DeadlineExceeded
+	NotFound
+	AlreadyExists
+	PermissionDenied
+	ResourceExhausted
+	FailedPrecondition
+	Aborted
+	OutOfRange
+	Unimplemented
+	Internal
+	Unavailable
+	DataLoss
+	Unauthenticated
+)
+
+var statusNames = [...]string{
+	"OK",
+	"CANCELLED",
+	"UNKNOWN",
+	"INVALID_ARGUMENT",
+	"DEADLINE_EXCEEDED",
+	"NOT_FOUND",
+	"ALREADY_EXISTS",
+	"PERMISSION_DENIED",
+	"RESOURCE_EXHAUSTED",
+	"FAILED_PRECONDITION",
+	"ABORTED",
+	"OUT_OF_RANGE",
+	"UNIMPLEMENTED",
+	"INTERNAL",
+	"UNAVAILABLE",
+	"DATA_LOSS",
+	"UNAUTHENTICATED",
+}
+
+// String returns the name of the status code, e.g. DEADLINE_EXCEEDED.
+func (s Status) String() string {
+	if s < OK || s > Unauthenticated {
+		return fmt.Sprintf("STATUS_%d", int(s))
+	}
+
+	return statusNames[s]
+}
+
+// IsGRPC tells whether the content type header contains one of the gRPC
+// content types, e.g. application/grpc or application/grpc+proto. The
+// gRPC-Web content types are not included.
+func IsGRPC(h http.Header) bool {
+	ct := h.Get("Content-Type")
+	if !strings.HasPrefix(ct, ContentType) {
+		return false
+	}
+
+	mt, _, err := mime.ParseMediaType(ct)
+	return err == nil && (mt == ContentType || strings.HasPrefix(mt, ContentType+"+"))
+}
+
+// StatusFromHTTP maps an HTTP status code, e.g. of a proxy error or a
+// non-gRPC response, to a gRPC status code.
+func StatusFromHTTP(code int) Status {
+	switch code {
+	case clientClosedRequest:
+		return Canceled
+	case http.StatusBadRequest:
+		return Internal
+	case http.StatusUnauthorized:
+		return Unauthenticated
+	case http.StatusForbidden:
+		return PermissionDenied
+	case http.StatusNotFound:
+		return Unimplemented
+	case http.StatusTooManyRequests, http.StatusRequestEntityTooLarge:
+		return ResourceExhausted
+	case http.StatusGatewayTimeout:
+		return DeadlineExceeded
+	case http.StatusBadGateway, http.StatusServiceUnavailable:
+		return Unavailable
+	default:
+		return Unknown
+	}
+}
+
+// EncodeMessage percent-encodes a status message for the grpc-message
+// header.
+func EncodeMessage(msg string) string {
+	var b strings.Builder
+	for i := 0; i < len(msg); i++ {
+		c := msg[i]
+		if c >= ' ' && c <= '~' && c != '%' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+
+	return b.String()
+}
+
+// ErrorHeader returns the grpc-status and grpc-message fields
+// representing an HTTP error status code.
+func ErrorHeader(code int) http.Header {
+	return http.Header{
+		StatusHeader:  []string{strconv.Itoa(int(StatusFromHTTP(code)))},
+		MessageHeader: []string{EncodeMessage(http.StatusText(code))},
+	}
+}
+
+// Method returns the service and the method from the path of a gRPC
+// request, in the form of /package.Service/Method.
+func Method(path string) (service, method string, ok bool) {
+	service, method, ok = strings.Cut(strings.TrimPrefix(path, "/"), "/")
+	if !ok || service == "" || method == "" || strings.Contains(method, "/") {
+		return "", "", false
+	}
+
+	return service, method, true
+}
+
+// ResponseStatus returns the status of a gRPC response, sent either in
+// the trailers or, in case of trailers-only responses, in the headers.
+// The trailers are available only after the response body was read.
+func ResponseStatus(rsp *http.Response) (Status, bool) {
+	s := rsp.Trailer.Get(StatusHeader)
+	if s == "" {
+		s = rsp.Header.Get(StatusHeader)
+	}
+
+	code, err := strconv.Atoi(s)
+	if err != nil || code < int(OK) || code > int(Unauthenticated) {
+		return 0, false
+	}
+
+	return Status(code), true
+}
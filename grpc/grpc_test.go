@@ -0,0 +1,104 @@
+package grpc
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsGRPC(t *testing.T) {
+	for ct, expected := range map[string]bool{
+		"application/grpc":             true,
+		"application/grpc+proto":       true,
+		"application/grpc; charset=x":  true,
+		"application/grpc-web":         false,
+		"application/grpc-web-text":    false,
+		"application/grpcfoo":          false,
+		"application/json":             false,
+		"":                             false,
+		"application/grpc; invalid=\"": false,
+	} {
+		assert.Equal(t, expected, IsGRPC(http.Header{"Content-Type": []string{ct}}), ct)
+	}
+}
+
+func TestStatusFromHTTP(t *testing.T) {
+	for code, expected := range map[int]Status{
+		400: Internal,
+		401: Unauthenticated,
+		403: PermissionDenied,
+		404: Unimplemented,
+		413: ResourceExhausted,
+		429: ResourceExhausted,
+		499: Canceled,
+		500: Unknown,
+		502: Unavailable,
+		503: Unavailable,
+		504: DeadlineExceeded,
+	} {
+		assert.Equal(t, expected, StatusFromHTTP(code), code)
+	}
+}
+
+func TestStatusString(t *testing.T) {
+	assert.Equal(t, "OK", OK.String())
+	assert.Equal(t, "DEADLINE_EXCEEDED", DeadlineExceeded.String())
+	assert.Equal(t, "UNAUTHENTICATED", Unauthenticated.String())
+	assert.Equal(t, "STATUS_42", Status(42).String())
+}
+
+func TestEncodeMessage(t *testing.T) {
+	assert.Equal(t, "Gateway Timeout", EncodeMessage("Gateway Timeout"))
+	assert.Equal(t, "100%25 f%C3%A4iled%0A", EncodeMessage("100% fäiled\n"))
+}
+
+func TestErrorHeader(t *testing.T) {
+	assert.Equal(t, http.Header{
+		"Grpc-Status":  []string{"4"},
+		"Grpc-Message": []string{"Gateway Timeout"},
+	}, ErrorHeader(http.StatusGatewayTimeout))
+}
+
+func TestMethod(t *testing.T) {
+	for _, tt := range []struct {
+		path, service, method string
+		ok                    bool
+	}{
+		{"/users.v1.Users/GetUser", "users.v1.Users", "GetUser", true},
+		{"/Users/GetUser", "Users", "GetUser", true},
+		{"/users.v1.Users/", "", "", false},
+		{"/users.v1.Users", "", "", false},
+		{"//GetUser", "", "", false},
+		{"/a/b/c", "", "", false},
+		{"/", "", "", false},
+	} {
+		service, method, ok := Method(tt.path)
+		assert.Equal(t, tt.service, service, tt.path)
+		assert.Equal(t, tt.method, method, tt.path)
+		assert.Equal(t, tt.ok, ok, tt.path)
+	}
+}
+
+func TestResponseStatus(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		header  http.Header
+		trailer http.Header
+		status  Status
+		ok      bool
+	}{
+		{"trailer", http.Header{}, http.Header{"Grpc-Status": []string{"5"}}, NotFound, true},
+		{"trailers-only", http.Header{"Grpc-Status": []string{"12"}}, nil, Unimplemented, true},
+		{"trailer first", http.Header{"Grpc-Status": []string{"12"}}, http.Header{"Grpc-Status": []string{"0"}}, OK, true},
+		{"missing", http.Header{}, nil, 0, false},
+		{"invalid", http.Header{"Grpc-Status": []string{"foo"}}, nil, 0, false},
+		{"out of range", http.Header{"Grpc-Status": []string{"17"}}, nil, 0, false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			status, ok := ResponseStatus(&http.Response{Header: tt.header, Trailer: tt.trailer})
+			assert.Equal(t, tt.status, status)
+			assert.Equal(t, tt.ok, ok)
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package io
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+)
+
+// ErrBodyTooLarge is returned by ReadRequestBody when the request body
+// exceeds the maximum size.
+var ErrBodyTooLarge = errors.New("request body too large")
+
+// Body replaces the request body, after its content was read to inspect
+// it before proxying. It restores the content for the backend, and
+// caches the values parsed from the content, so that the predicates of
+// several routes and the filters can share them.
+type Body struct {
+	content  []byte
+	complete bool
+	err      error
+	reading  bool
+	reader   io.Reader
+	original io.ReadCloser
+	values   map[any]any
+}
+
+// ReadRequestBody reads the body of the request up to maxSize bytes, and
+// replaces it with a Body, that restores the content for the backend.
+// It returns nil, when the request has no body, and ErrBodyTooLarge,
+// when the body is larger than maxSize. The subsequent calls, also with a
+// different maxSize, share the content read by the previous ones.
+func ReadRequestBody(r *http.Request, maxSize int64) (*Body, error) {
+	b, ok := r.Body.(*Body)
+	if !ok {
+		if r.Body == nil || r.Body == http.NoBody || r.ContentLength == 0 {
+			return nil, nil
+		}
+
+		if r.ContentLength > maxSize {
+			return nil, ErrBodyTooLarge
+		}
+
+		b = &Body{original: r.Body}
+		r.Body = b
+	}
+
+	if !b.complete && b.err == nil && !b.reading && int64(len(b.content)) <= maxSize {
+		b.readMore(maxSize + 1 - int64(len(b.content)))
+	}
+
+	switch {
+	case int64(len(b.content)) > maxSize:
+		return nil, ErrBodyTooLarge
+	case b.err != nil:
+		return nil, b.err
+	case !b.complete:
+		return nil, ErrBodyTooLarge
+	default:
+		return b, nil
+	}
+}
+
+func (b *Body) readMore(n int64) {
+	more, err := io.ReadAll(io.LimitReader(b.original, n))
+	b.content = append(b.content, more...)
+	b.err = err
+	b.complete = err == nil && int64(len(more)) < n
+}
+
+// Bytes returns the content of the body. It must not be modified.
+func (b *Body) Bytes() []byte { return b.content }
+
+// Value returns the value parsed from the content for the key. The
+// content is parsed only on the first call for the key.
+func (b *Body) Value(key any, parse func(content []byte) any) any {
+	if v, ok := b.values[key]; ok {
+		return v
+	}
+
+	if b.values == nil {
+		b.values = make(map[any]any)
+	}
+
+	v := parse(b.content)
+	b.values[key] = v
+	return v
+}
+
+func (b *Body) Read(p []byte) (int, error) {
+	if !b.reading {
+		b.reading = true
+		b.reader = io.MultiReader(bytes.NewReader(b.content), b.original)
+	}
+
+	return b.reader.Read(p)
+}
+
+func (b *Body) Close() error { return b.original.Close() }
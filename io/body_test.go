@@ -0,0 +1,97 @@
+package io
+
+import (
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestReadRequestBody(t *testing.T) {
+	t.Run("no body", func(t *testing.T) {
+		r := httptest.NewRequest("POST", "/", nil)
+		if b, err := ReadRequestBody(r, 8); b != nil || err != nil {
+			t.Fatalf("unexpected result: %v, %v", b, err)
+		}
+	})
+
+	t.Run("too large content length", func(t *testing.T) {
+		r := httptest.NewRequest("POST", "/", strings.NewReader("0123456789"))
+		if _, err := ReadRequestBody(r, 8); err != ErrBodyTooLarge {
+			t.Fatalf("expected too large, got: %v", err)
+		}
+
+		if _, ok := r.Body.(*Body); ok {
+			t.Fatal("the body was replaced")
+		}
+	})
+
+	t.Run("shared content", func(t *testing.T) {
+		r := httptest.NewRequest("POST", "/", strings.NewReader("0123456789"))
+		r.ContentLength = -1
+
+		if _, err := ReadRequestBody(r, 4); err != ErrBodyTooLarge {
+			t.Fatalf("expected too large, got: %v", err)
+		}
+
+		b, err := ReadRequestBody(r, 16)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(b.Bytes()) != "0123456789" {
+			t.Fatalf("unexpected content: %q", b.Bytes())
+		}
+
+		parsed := 0
+		parse := func(content []byte) any {
+			parsed++
+			return len(content)
+		}
+
+		for range 2 {
+			b, err = ReadRequestBody(r, 10)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if v := b.Value("length", parse); v != 10 {
+				t.Fatalf("unexpected value: %v", v)
+			}
+		}
+
+		if parsed != 1 {
+			t.Fatalf("the content was parsed %d times", parsed)
+		}
+
+		if _, err := ReadRequestBody(r, 8); err != ErrBodyTooLarge {
+			t.Fatalf("expected too large, got: %v", err)
+		}
+
+		restored, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(restored) != "0123456789" {
+			t.Fatalf("failed to restore the body: %q", restored)
+		}
+	})
+
+	t.Run("reading stops extending the content", func(t *testing.T) {
+		r := httptest.NewRequest("POST", "/", strings.NewReader("0123456789"))
+		r.ContentLength = -1
+		if _, err := ReadRequestBody(r, 4); err != ErrBodyTooLarge {
+			t.Fatalf("expected too large, got: %v", err)
+		}
+
+		p := make([]byte, 2)
+		if _, err := r.Body.Read(p); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := ReadRequestBody(r, 16); err != ErrBodyTooLarge {
+			t.Fatalf("expected too large, got: %v", err)
+		}
+	})
+}
@@ -11,6 +11,7 @@ import (
 
 	flowidFilter "github.com/zalando/skipper/filters/flowid"
 	logFilter "github.com/zalando/skipper/filters/log"
+	"github.com/zalando/skipper/net/proxyprotocol"
 )
 
 const (
@@ -166,6 +167,14 @@ func LogAccess(entry *AccessEntry, additional map[string]interface{}) {
 		"auth-user":      authUser,
 	}
 
+	if entry.Request != nil {
+		if h, ok := proxyprotocol.FromContext(entry.Request.Context()); ok {
+			if id := h.AWSVPCEndpointID(); id != "" {
+				logData["aws-vpce-id"] = id
+			}
+		}
+	}
+
 	for k, v := range additional {
 		logData[k] = v
 	}
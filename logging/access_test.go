@@ -11,6 +11,7 @@ import (
 	"github.com/sirupsen/logrus"
 
 	logFilter "github.com/zalando/skipper/filters/log"
+	"github.com/zalando/skipper/net/proxyprotocol"
 )
 
 const logOutput = `127.0.0.1 - - [10/Oct/2000:13:55:36 -0700] "GET /apache_pb.gif HTTP/1.1" 418 2326 "-" "-" 42 example.com - -`
@@ -293,3 +294,18 @@ func TestAccessLogStripQuery(t *testing.T) {
 	entry.Request.RequestURI += "?foo=bar"
 	testAccessLog(t, entry, logOutput, Options{AccessLogStripQuery: true})
 }
+
+func TestAccessLogAWSVPCEndpointIDJSON(t *testing.T) {
+	entry := testAccessEntry()
+	entry.Request = entry.Request.WithContext(proxyprotocol.NewContext(entry.Request.Context(), &proxyprotocol.Header{
+		Version: 2,
+		TLVs:    []proxyprotocol.TLV{{Type: proxyprotocol.TypeAWS, Value: []byte("\x01vpce-0123")}},
+	}))
+
+	testAccessLog(
+		t,
+		entry,
+		`{"audit":"","auth-user":"","aws-vpce-id":"vpce-0123","duration":42,"flow-id":"","host":"127.0.0.1","level":"info","method":"GET","msg":"","proto":"HTTP/1.1","referer":"","requested-host":"example.com","response-size":2326,"status":418,"timestamp":"10/Oct/2000:13:55:36 -0700","uri":"/apache_pb.gif","user-agent":""}`,
+		Options{AccessLogJSONEnabled: true},
+	)
+}
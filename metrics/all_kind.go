@@ -136,6 +136,11 @@ func (a *All) UpdateInvalidRoute(reasonCounts map[string]int) {
 	a.codaHale.UpdateInvalidRoute(reasonCounts)
 }
 
+func (a *All) UpdateInvalidRouteByDataClient(dataClient string, reasonCounts map[string]int) {
+	a.prometheus.UpdateInvalidRouteByDataClient(dataClient, reasonCounts)
+	a.codaHale.UpdateInvalidRouteByDataClient(dataClient, reasonCounts)
+}
+
 func (a *All) Close() {
 	a.codaHale.Close()
 	a.prometheus.Close()
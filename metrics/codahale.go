@@ -35,6 +35,8 @@ const (
 	KeyErrorsStreaming = "errors.streaming.%s"
 	KeyInvalidRoutes   = "route.invalid.%s"
 
+	KeyInvalidRoutesByDataClient = "route.invalid.dataclient.%s.%s"
+
 	statsRefreshDuration = time.Duration(5 * time.Second)
 
 	defaultUniformReservoirSize  = 1024
@@ -271,6 +273,12 @@ func (c *CodaHale) UpdateInvalidRoute(reasonCounts map[string]int) {
 	}
 }
 
+func (c *CodaHale) UpdateInvalidRouteByDataClient(dataClient string, reasonCounts map[string]int) {
+	for reason, count := range reasonCounts {
+		c.UpdateGauge(fmt.Sprintf(KeyInvalidRoutesByDataClient, dataClient, reason), float64(count))
+	}
+}
+
 func (c *CodaHale) Close() {
 	close(c.quit)
 }
@@ -81,6 +81,7 @@ type Metrics interface {
 	RegisterHandler(path string, handler *http.ServeMux)
 	UpdateGauge(key string, value float64)
 	UpdateInvalidRoute(reasonCounts map[string]int)
+	UpdateInvalidRouteByDataClient(dataClient string, reasonCounts map[string]int)
 	Close()
 }
 
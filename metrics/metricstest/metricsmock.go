@@ -231,4 +231,10 @@ func (m *MockMetrics) UpdateInvalidRoute(reasonCounts map[string]int) {
 	}
 }
 
+func (m *MockMetrics) UpdateInvalidRouteByDataClient(dataClient string, reasonCounts map[string]int) {
+	for reason, count := range reasonCounts {
+		m.UpdateGauge("route.invalid.dataclient."+dataClient+"."+reason, float64(count))
+	}
+}
+
 func (m *MockMetrics) Close() {}
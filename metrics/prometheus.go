@@ -72,6 +72,7 @@ type Prometheus struct {
 	customCounterM             *prometheus.CounterVec
 	customGaugeM               *prometheus.GaugeVec
 	invalidRouteM              *prometheus.GaugeVec
+	invalidRouteDataClientM    *prometheus.GaugeVec
 
 	opts     Options
 	registry *prometheus.Registry
@@ -314,6 +315,13 @@ func NewPrometheus(opts Options) *Prometheus {
 		Help:      "Number of invalid routes by reason.",
 	}, []string{"reason"}))
 
+	p.invalidRouteDataClientM = register(p, prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: promRouteSubsystem,
+		Name:      "invalid_by_dataclient",
+		Help:      "Number of invalid routes by data client and reason.",
+	}, []string{"dataclient", "reason"}))
+
 	// Register prometheus runtime collectors if required.
 	if opts.EnableRuntimeMetrics {
 		register(p, collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}))
@@ -530,6 +538,13 @@ func (p *Prometheus) UpdateInvalidRoute(reasonCounts map[string]int) {
 	}
 }
 
+// UpdateInvalidRouteByDataClient satisfies Metrics interface.
+func (p *Prometheus) UpdateInvalidRouteByDataClient(dataClient string, reasonCounts map[string]int) {
+	for reason, count := range reasonCounts {
+		p.invalidRouteDataClientM.WithLabelValues(dataClient, reason).Set(float64(count))
+	}
+}
+
 func (p *Prometheus) Close() {}
 
 // withStartLabelGatherer adds a "start" label to all counters with
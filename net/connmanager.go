@@ -5,14 +5,23 @@ import (
 	"fmt"
 	"net"
 	"net/http"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/quic-go/quic-go"
+	"github.com/quic-go/quic-go/http3"
 	"github.com/zalando/skipper/metrics"
 )
 
 // ConnManager tracks creation of HTTP server connections and
 // closes connections when their age or number of requests served reaches configured limits.
-// Use [ConnManager.Configure] method to setup ConnManager for an [http.Server].
+// Use [ConnManager.Configure] method to setup ConnManager for an [http.Server],
+// and [ConnManager.ConfigureHTTP3] for an [http3.Server].
+//
+// It counts the open connections of an [http.Server] by their state,
+// see [ConnManager.ConnStates], and after [ConnManager.Drain] it closes
+// the connections after their current request.
 type ConnManager struct {
 	// Metrics is an optional metrics registry to count connection events.
 	Metrics metrics.Metrics
@@ -23,7 +32,13 @@ type ConnManager struct {
 	// KeepaliveRequests is the number of requests after which server connection is closed.
 	KeepaliveRequests int
 
-	handler http.Handler
+	handler  http.Handler
+	draining atomic.Bool
+
+	// conns holds the state of the connections, when the metrics are
+	// set, or after Drain
+	conns  sync.Map
+	counts [http.StateClosed + 1]atomic.Int64
 }
 
 type connState struct {
@@ -31,6 +46,18 @@ type connState struct {
 	requests  int
 }
 
+// the metric keys of the connection states, indexed by the state
+var connStateCounters, connStateGauges = connStateKeys()
+
+func connStateKeys() (counters, gauges [http.StateClosed + 1]string) {
+	for state := http.StateNew; state <= http.StateClosed; state++ {
+		counters[state] = fmt.Sprintf("lb-conn-%s", state)
+		gauges[state] = fmt.Sprintf("lb-conns.%s", state)
+	}
+
+	return
+}
+
 type contextKey struct{}
 
 var connection contextKey
@@ -58,10 +85,39 @@ func (cm *ConnManager) Configure(server *http.Server) {
 	}
 }
 
+// ConfigureHTTP3 sets up the ConnManager for an HTTP/3 server. It counts the new and
+// the closed QUIC connections. The keepalive limits are not applied to the HTTP/3
+// connections, because HTTP/3 has no per response signal to close the connection.
+func (cm *ConnManager) ConfigureHTTP3(server *http3.Server) {
+	cm.handler = server.Handler
+	server.Handler = http.HandlerFunc(cm.serveHTTP)
+
+	if cc := server.ConnContext; cc != nil {
+		server.ConnContext = func(ctx context.Context, c *quic.Conn) context.Context {
+			ctx = cc(ctx, c)
+			return cm.quicConnContext(ctx, c)
+		}
+	} else {
+		server.ConnContext = cm.quicConnContext
+	}
+}
+
 func (cm *ConnManager) serveHTTP(w http.ResponseWriter, r *http.Request) {
 	state, _ := r.Context().Value(connection).(*connState)
 	state.requests++
 
+	if r.ProtoMajor == 3 {
+		cm.handler.ServeHTTP(w, r)
+		return
+	}
+
+	if cm.draining.Load() {
+		// on HTTP/2, it sends GOAWAY
+		w.Header().Set("Connection", "close")
+
+		cm.count("lb-conn-closed.drain")
+	}
+
 	if cm.KeepaliveRequests > 0 && state.requests >= cm.KeepaliveRequests {
 		w.Header().Set("Connection", "close")
 
@@ -84,8 +140,78 @@ func (cm *ConnManager) connContext(ctx context.Context, _ net.Conn) context.Cont
 	return context.WithValue(ctx, connection, state)
 }
 
-func (cm *ConnManager) connState(_ net.Conn, state http.ConnState) {
-	cm.count(fmt.Sprintf("lb-conn-%s", state))
+func (cm *ConnManager) quicConnContext(ctx context.Context, c *quic.Conn) context.Context {
+	cm.count("lb-conn-new")
+	go func() {
+		<-c.Context().Done()
+		cm.count("lb-conn-closed")
+	}()
+
+	return cm.connContext(ctx, nil)
+}
+
+func (cm *ConnManager) connState(c net.Conn, state http.ConnState) {
+	if state > http.StateClosed {
+		return
+	}
+
+	cm.count(connStateCounters[state])
+	if cm.Metrics == nil && !cm.draining.Load() {
+		return
+	}
+
+	var (
+		previous any
+		tracked  bool
+	)
+
+	// the hijacked connections are not tracked by the server anymore
+	if state == http.StateHijacked || state == http.StateClosed {
+		previous, tracked = cm.conns.LoadAndDelete(c)
+	} else {
+		previous, tracked = cm.conns.Swap(c, state)
+		cm.updateCount(state, 1)
+	}
+
+	if tracked {
+		cm.updateCount(previous.(http.ConnState), -1)
+	}
+}
+
+// updateCount changes the number of the open connections in the state,
+// and reports it, when the metrics are set.
+func (cm *ConnManager) updateCount(state http.ConnState, delta int64) {
+	n := cm.counts[state].Add(delta)
+	if cm.Metrics != nil {
+		cm.Metrics.UpdateGauge(connStateGauges[state], float64(n))
+	}
+}
+
+// ConnStates returns the number of the open connections of the
+// configured [http.Server] by their state: new, active or idle. The
+// hijacked connections, e.g. the upgraded ones, are not counted. The
+// states are tracked only when the metrics are set, or after Drain,
+// without metrics, the connections opened before Drain are counted only
+// from their next state change.
+func (cm *ConnManager) ConnStates() map[http.ConnState]int {
+	counts := make(map[http.ConnState]int)
+	for state := range cm.counts {
+		if n := cm.counts[state].Load(); n > 0 {
+			counts[http.ConnState(state)] = int(n)
+		}
+	}
+
+	return counts
+}
+
+// Drain makes the connections close after their current request: the
+// HTTP/1 responses get the Connection: close header, and the HTTP/2
+// connections receive GOAWAY. It doesn't apply to the HTTP/3
+// connections, that are drained by shutting down the HTTP/3 server.
+// Without metrics, it starts tracking the connection states, see
+// [ConnManager.ConnStates].
+func (cm *ConnManager) Drain() {
+	cm.draining.Store(true)
 }
 
 func (cm *ConnManager) count(name string) {
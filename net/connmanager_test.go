@@ -125,4 +125,92 @@ func TestConnManager(t *testing.T) {
 			assert.Equal(t, int64(1), counters["lb-conn-closed.keepalive"])
 		})
 	})
+	t.Run("counts connection states and closes connections when draining", func(t *testing.T) {
+		ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}))
+		m := &metricstest.MockMetrics{}
+		cm := &snet.ConnManager{
+			Metrics: m,
+		}
+		cm.Configure(ts.Config)
+
+		ts.Start()
+		defer ts.Close()
+
+		resp, err := ts.Client().Get(ts.URL)
+		require.NoError(t, err)
+		assert.False(t, resp.Close)
+
+		time.Sleep(100 * time.Millisecond) // wait for connection state update
+
+		assert.Equal(t, map[http.ConnState]int{http.StateIdle: 1}, cm.ConnStates())
+		if v, ok := m.Gauge("lb-conns.idle"); assert.True(t, ok) {
+			assert.Equal(t, float64(1), v)
+		}
+
+		cm.Drain()
+
+		resp, err = ts.Client().Get(ts.URL)
+		require.NoError(t, err)
+		assert.True(t, resp.Close)
+
+		time.Sleep(100 * time.Millisecond) // wait for connection state update
+
+		assert.Empty(t, cm.ConnStates())
+		if v, ok := m.Gauge("lb-conns.idle"); assert.True(t, ok) {
+			assert.Equal(t, float64(0), v)
+		}
+
+		m.WithCounters(func(counters map[string]int64) {
+			assert.Equal(t, int64(1), counters["lb-conn-closed"])
+			assert.Equal(t, int64(1), counters["lb-conn-closed.drain"])
+		})
+	})
+	t.Run("tracks connection states without metrics only when draining", func(t *testing.T) {
+		release := make(chan struct{})
+		ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/block" {
+				<-release
+			}
+
+			w.WriteHeader(http.StatusOK)
+		}))
+		cm := &snet.ConnManager{}
+		cm.Configure(ts.Config)
+
+		ts.Start()
+		defer ts.Close()
+
+		resp, err := ts.Client().Get(ts.URL)
+		require.NoError(t, err)
+		resp.Body.Close()
+
+		time.Sleep(100 * time.Millisecond) // wait for connection state update
+
+		assert.Empty(t, cm.ConnStates())
+
+		cm.Drain()
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			resp, err := ts.Client().Get(ts.URL + "/block")
+			if assert.NoError(t, err) {
+				resp.Body.Close()
+				assert.True(t, resp.Close)
+			}
+		}()
+
+		time.Sleep(100 * time.Millisecond) // wait for connection state update
+
+		assert.Equal(t, map[http.ConnState]int{http.StateActive: 1}, cm.ConnStates())
+
+		close(release)
+		<-done
+
+		time.Sleep(100 * time.Millisecond) // wait for connection state update
+
+		assert.Empty(t, cm.ConnStates())
+	})
 }
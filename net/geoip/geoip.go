@@ -0,0 +1,240 @@
+/*
+Package geoip resolves the country and the autonomous system of the client
+IP addresses from local MaxMind DB files, e.g. GeoLite2-Country.mmdb and
+GeoLite2-ASN.mmdb.
+
+The database files are read into memory, and reloaded in the background
+when their modification time or size changes.
+*/
+package geoip
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	maxminddb "github.com/oschwald/maxminddb-golang"
+	log "github.com/sirupsen/logrus"
+
+	snet "github.com/zalando/skipper/net"
+	"github.com/zalando/skipper/predicates"
+	"github.com/zalando/skipper/routing"
+)
+
+const defaultReloadInterval = time.Minute
+
+// Options to create a Resolver.
+type Options struct {
+
+	// Databases contains the paths of the MaxMind DB files. The
+	// fields of the records found in the different databases are
+	// merged.
+	Databases []string
+
+	// ClientIPSource defines how the client IP of the requests is
+	// resolved, with the same logic as the predicates of the same
+	// name: "Source" uses the first address of the X-Forwarded-For
+	// header, "SourceFromLast" the last one, and "ClientIP" the
+	// remote address of the connection. Defaults to "Source".
+	ClientIPSource string
+
+	// ReloadInterval is the interval of checking the database files
+	// for changes. Defaults to one minute.
+	ReloadInterval time.Duration
+}
+
+// Record contains the data found for an IP address. The fields are empty
+// when the address is not found in the databases.
+type Record struct {
+
+	// Country is the ISO 3166-1 code of the country, e.g. "DE".
+	Country string
+
+	// ASN is the number of the autonomous system.
+	ASN uint
+
+	// ASOrganization is the organization of the autonomous system.
+	ASOrganization string
+}
+
+type lookupKey struct {
+	resolver *Resolver
+}
+
+type database struct {
+	path    string
+	modTime time.Time
+	size    int64
+	db      atomic.Pointer[maxminddb.Reader]
+}
+
+// Resolver looks up the client IP addresses in the configured databases.
+type Resolver struct {
+	source    string
+	databases []*database
+	quit      chan struct{}
+	once      sync.Once
+}
+
+var errInvalidClientIPSource = errors.New("invalid client IP source")
+
+// New creates a Resolver, loading the configured databases. It starts
+// the background reloading of the databases, make sure to Close() it.
+func New(o Options) (*Resolver, error) {
+	if len(o.Databases) == 0 {
+		return nil, errors.New("no geoip database configured")
+	}
+
+	if o.ClientIPSource == "" {
+		o.ClientIPSource = predicates.SourceName
+	} else if !snet.ValidRemoteAddrSource(o.ClientIPSource) {
+		return nil, fmt.Errorf("%w: %s", errInvalidClientIPSource, o.ClientIPSource)
+	}
+
+	if o.ReloadInterval <= 0 {
+		o.ReloadInterval = defaultReloadInterval
+	}
+
+	r := &Resolver{source: o.ClientIPSource, quit: make(chan struct{})}
+	for _, p := range o.Databases {
+		d := &database{path: p}
+		if err := d.load(); err != nil {
+			return nil, err
+		}
+
+		r.databases = append(r.databases, d)
+	}
+
+	go r.runReloader(o.ReloadInterval)
+	return r, nil
+}
+
+// load reads the database file, when it changed since the last load.
+func (d *database) load() error {
+	fi, err := os.Stat(d.path)
+	if err != nil {
+		return err
+	}
+
+	if d.db.Load() != nil && fi.ModTime().Equal(d.modTime) && fi.Size() == d.size {
+		return nil
+	}
+
+	b, err := os.ReadFile(d.path)
+	if err != nil {
+		return err
+	}
+
+	db, err := maxminddb.FromBytes(b)
+	if err != nil {
+		return fmt.Errorf("failed to open geoip database %s: %w", d.path, err)
+	}
+
+	d.db.Store(db)
+	d.modTime = fi.ModTime()
+	d.size = fi.Size()
+	return nil
+}
+
+func (r *Resolver) runReloader(d time.Duration) {
+	ticker := time.NewTicker(d)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			for _, db := range r.databases {
+				modTime := db.modTime
+				if err := db.load(); err != nil {
+					log.Errorf("Failed to reload geoip database: %v", err)
+				} else if !db.modTime.Equal(modTime) {
+					log.Infof("Reloaded geoip database: %s", db.path)
+				}
+			}
+		case <-r.quit:
+			return
+		}
+	}
+}
+
+type country struct {
+	ISOCode string `maxminddb:"iso_code"`
+}
+
+// fields contains the data of a record used by the resolver. The other
+// fields of the records are skipped when decoding them.
+type fields struct {
+	Country           country `maxminddb:"country"`
+	RegisteredCountry country `maxminddb:"registered_country"`
+	ASN               uint    `maxminddb:"autonomous_system_number"`
+	ASOrganization    string  `maxminddb:"autonomous_system_organization"`
+}
+
+func (r *Record) merge(f fields) {
+	if f.Country.ISOCode != "" {
+		r.Country = f.Country.ISOCode
+	} else if f.RegisteredCountry.ISOCode != "" && r.Country == "" {
+		r.Country = f.RegisteredCountry.ISOCode
+	}
+
+	if f.ASN != 0 {
+		r.ASN = f.ASN
+	}
+
+	if f.ASOrganization != "" {
+		r.ASOrganization = f.ASOrganization
+	}
+}
+
+// Lookup returns the record of an IP address.
+func (r *Resolver) Lookup(addr netip.Addr) Record {
+	var rec Record
+	if !addr.IsValid() {
+		return rec
+	}
+
+	ip := net.IP(addr.Unmap().AsSlice())
+	for _, d := range r.databases {
+		var f fields
+		if err := d.db.Load().Lookup(ip, &f); err != nil {
+			log.Debugf("Failed to look up %s in geoip database %s: %v", addr, d.path, err)
+			continue
+		}
+
+		rec.merge(f)
+	}
+
+	return rec
+}
+
+// ClientIP returns the client IP address of a request, according to the
+// configured client IP source.
+func (r *Resolver) ClientIP(req *http.Request) netip.Addr {
+	return snet.RemoteAddrBySource(req, r.source)
+}
+
+// LookupRequest returns the record of the client IP address of a request.
+// The record is stored in the routing context of the request, so that
+// the predicates and the filters share a single lookup.
+func (r *Resolver) LookupRequest(req *http.Request) Record {
+	return routing.FromContext(req.Context(), lookupKey{r}, func() Record {
+		return r.Lookup(r.ClientIP(req))
+	})
+}
+
+// Close stops the background reloading of the databases.
+func (r *Resolver) Close() {
+	r.once.Do(func() { close(r.quit) })
+}
+
+// NormalizeCountry returns the country code in the format of the records.
+func NormalizeCountry(c string) string {
+	return strings.ToUpper(strings.TrimSpace(c))
+}
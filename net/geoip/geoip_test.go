@@ -0,0 +1,167 @@
+package geoip_test
+
+import (
+	"net/http/httptest"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zalando/skipper/net/geoip"
+	"github.com/zalando/skipper/net/geoip/geoiptest"
+	"github.com/zalando/skipper/routing"
+)
+
+func country(code string) map[string]interface{} {
+	return map[string]interface{}{"country": map[string]interface{}{"iso_code": code}}
+}
+
+func asn(n uint, org string) map[string]interface{} {
+	return map[string]interface{}{"autonomous_system_number": n, "autonomous_system_organization": org}
+}
+
+func writeDatabases(t *testing.T) (string, string) {
+	dir := t.TempDir()
+	countries := filepath.Join(dir, "country.mmdb")
+	require.NoError(t, geoiptest.Write(countries, map[string]map[string]interface{}{
+		"1.2.0.0/16":    country("DE"),
+		"1.2.3.0/24":    country("AT"),
+		"2001:db8::/32": country("FR"),
+	}))
+
+	asns := filepath.Join(dir, "asn.mmdb")
+	require.NoError(t, geoiptest.Write(asns, map[string]map[string]interface{}{
+		"1.2.3.0/24": asn(3320, "Deutsche Telekom AG"),
+	}))
+
+	return countries, asns
+}
+
+func TestLookup(t *testing.T) {
+	countries, asns := writeDatabases(t)
+	r, err := geoip.New(geoip.Options{Databases: []string{countries, asns}})
+	require.NoError(t, err)
+	defer r.Close()
+
+	for _, tt := range []struct {
+		addr   string
+		expect geoip.Record
+	}{
+		{"1.2.3.4", geoip.Record{Country: "AT", ASN: 3320, ASOrganization: "Deutsche Telekom AG"}},
+		{"1.2.4.5", geoip.Record{Country: "DE"}},
+		{"::ffff:1.2.4.5", geoip.Record{Country: "DE"}},
+		{"1.3.0.1", geoip.Record{}},
+		{"2001:db8::1", geoip.Record{Country: "FR"}},
+		{"2001:db9::1", geoip.Record{}},
+	} {
+		assert.Equal(t, tt.expect, r.Lookup(netip.MustParseAddr(tt.addr)), tt.addr)
+	}
+
+	assert.Equal(t, geoip.Record{}, r.Lookup(netip.Addr{}))
+}
+
+func TestLookupSkipsUnusedFields(t *testing.T) {
+	db := filepath.Join(t.TempDir(), "city.mmdb")
+	require.NoError(t, geoiptest.Write(db, map[string]map[string]interface{}{
+		"1.2.3.0/24": {
+			"city":      map[string]interface{}{"geoname_id": uint32(2950159), "names": map[string]interface{}{"en": "Berlin"}},
+			"continent": map[string]interface{}{"code": "EU"},
+			"country": map[string]interface{}{
+				"is_in_european_union": true,
+				"names":                map[string]interface{}{"de": "Deutschland", "en": "Germany"},
+				"iso_code":             "DE",
+			},
+			"subdivisions": []interface{}{map[string]interface{}{"iso_code": "BE"}},
+			"traits":       map[string]interface{}{"is_anycast": false},
+		},
+		"1.2.4.0/24": {
+			"registered_country": map[string]interface{}{"iso_code": "AT", "geoname_id": uint64(2782113)},
+		},
+	}))
+
+	r, err := geoip.New(geoip.Options{Databases: []string{db}})
+	require.NoError(t, err)
+	defer r.Close()
+
+	assert.Equal(t, geoip.Record{Country: "DE"}, r.Lookup(netip.MustParseAddr("1.2.3.4")))
+	assert.Equal(t, geoip.Record{Country: "AT"}, r.Lookup(netip.MustParseAddr("1.2.4.5")))
+}
+
+func TestLookupRequestCached(t *testing.T) {
+	countries, _ := writeDatabases(t)
+	r, err := geoip.New(geoip.Options{Databases: []string{countries}})
+	require.NoError(t, err)
+	defer r.Close()
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req = req.WithContext(routing.NewContext(req.Context()))
+	req.Header.Set("X-Forwarded-For", "1.2.3.4")
+	require.Equal(t, "AT", r.LookupRequest(req).Country)
+
+	// the record of the request is looked up only once
+	req.Header.Set("X-Forwarded-For", "1.2.4.5")
+	assert.Equal(t, "AT", r.LookupRequest(req).Country)
+}
+
+func TestClientIPSource(t *testing.T) {
+	countries, _ := writeDatabases(t)
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "[2001:db8::1]:8080"
+	req.Header.Set("X-Forwarded-For", "1.2.3.4, 1.2.4.5")
+
+	for _, tt := range []struct {
+		source string
+		expect string
+	}{
+		{"", "AT"},
+		{"Source", "AT"},
+		{"SourceFromLast", "DE"},
+		{"ClientIP", "FR"},
+	} {
+		r, err := geoip.New(geoip.Options{Databases: []string{countries}, ClientIPSource: tt.source})
+		require.NoError(t, err)
+		defer r.Close()
+
+		assert.Equal(t, tt.expect, r.LookupRequest(req).Country, tt.source)
+	}
+
+	_, err := geoip.New(geoip.Options{Databases: []string{countries}, ClientIPSource: "foo"})
+	assert.Error(t, err)
+}
+
+func TestInvalidDatabase(t *testing.T) {
+	dir := t.TempDir()
+	invalid := filepath.Join(dir, "invalid.mmdb")
+	require.NoError(t, os.WriteFile(invalid, []byte("not a database"), 0o644))
+
+	_, err := geoip.New(geoip.Options{Databases: []string{invalid}})
+	assert.Error(t, err)
+
+	_, err = geoip.New(geoip.Options{Databases: []string{filepath.Join(dir, "missing.mmdb")}})
+	assert.Error(t, err)
+
+	_, err = geoip.New(geoip.Options{})
+	assert.Error(t, err)
+}
+
+func TestReload(t *testing.T) {
+	countries, _ := writeDatabases(t)
+	r, err := geoip.New(geoip.Options{Databases: []string{countries}, ReloadInterval: 10 * time.Millisecond})
+	require.NoError(t, err)
+	defer r.Close()
+
+	addr := netip.MustParseAddr("1.2.3.4")
+	require.Equal(t, "AT", r.Lookup(addr).Country)
+
+	// keeps the last valid database
+	require.NoError(t, os.WriteFile(countries, []byte("not a database"), 0o644))
+	time.Sleep(50 * time.Millisecond)
+	require.Equal(t, "AT", r.Lookup(addr).Country)
+
+	require.NoError(t, geoiptest.Write(countries, map[string]map[string]interface{}{"1.2.3.0/24": country("CH")}))
+	assert.Eventually(t, func() bool { return r.Lookup(addr).Country == "CH" }, time.Second, 10*time.Millisecond)
+}
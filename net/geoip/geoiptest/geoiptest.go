@@ -0,0 +1,233 @@
+/*
+Package geoiptest writes MaxMind DB files for testing.
+*/
+package geoiptest
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net/netip"
+	"os"
+	"sort"
+)
+
+const recordSize = 32
+
+type trieNode struct {
+	children [2]*trieNode
+	data     int
+}
+
+// search tree node, the records are node indexes or data offsets
+type node struct {
+	records [2]uint32
+	data    [2]int
+}
+
+// Write writes an IPv6 MaxMind DB file, containing the records mapped to
+// the networks in CIDR format, e.g. "1.2.3.0/24". The supported record
+// values are maps with string keys, arrays, strings, bools and unsigned
+// integers.
+func Write(path string, records map[string]map[string]interface{}) error {
+	b, err := Encode(records)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, b, 0o644)
+}
+
+// Encode returns the content of an IPv6 MaxMind DB file. See Write.
+func Encode(records map[string]map[string]interface{}) ([]byte, error) {
+	root := &trieNode{data: -1}
+	var data bytes.Buffer
+	for cidr, r := range records {
+		p, err := netip.ParsePrefix(cidr)
+		if err != nil {
+			return nil, err
+		}
+
+		offset := data.Len()
+		if err := encodeValue(&data, r); err != nil {
+			return nil, err
+		}
+
+		root.insert(p, offset)
+	}
+
+	var nodes []*node
+	root.emit(&nodes, -1)
+
+	var buf bytes.Buffer
+	nodeCount := uint32(len(nodes))
+	for _, n := range nodes {
+		for i := range n.records {
+			r := n.records[i]
+			switch {
+			case n.data[i] >= 0:
+				r = nodeCount + 16 + uint32(n.data[i])
+			case r == 0:
+				r = nodeCount
+			}
+
+			binary.Write(&buf, binary.BigEndian, r)
+		}
+	}
+
+	buf.Write(make([]byte, 16))
+	buf.Write(data.Bytes())
+	buf.WriteString("\xAB\xCD\xEFMaxMind.com")
+	if err := encodeValue(&buf, map[string]interface{}{
+		"binary_format_major_version": uint16(2),
+		"binary_format_minor_version": uint16(0),
+		"database_type":               "Skipper-Test",
+		"ip_version":                  uint16(6),
+		"node_count":                  nodeCount,
+		"record_size":                 uint16(recordSize),
+	}); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func (t *trieNode) insert(p netip.Prefix, offset int) {
+	addr := p.Addr().As16()
+	bits := p.Bits()
+	if p.Addr().Is4() {
+		// IPv4 networks are stored in the ::/96 subtree
+		a4 := p.Addr().As4()
+		addr = [16]byte{12: a4[0], 13: a4[1], 14: a4[2], 15: a4[3]}
+		bits += 96
+	}
+
+	for i := 0; i < bits; i++ {
+		bit := (addr[i/8] >> (7 - i%8)) & 1
+		if t.children[bit] == nil {
+			t.children[bit] = &trieNode{data: -1}
+		}
+
+		t = t.children[bit]
+	}
+
+	t.data = offset
+}
+
+// emit appends the search tree nodes of the subtree, pushing the data of
+// the networks down to the records of the nodes of the more specific
+// networks
+func (t *trieNode) emit(nodes *[]*node, inherited int) uint32 {
+	n := &node{data: [2]int{-1, -1}}
+	index := uint32(len(*nodes))
+	*nodes = append(*nodes, n)
+	for bit, c := range t.children {
+		d := inherited
+		if c != nil && c.data >= 0 {
+			d = c.data
+		}
+
+		if c == nil || c.children[0] == nil && c.children[1] == nil {
+			n.data[bit] = d
+			continue
+		}
+
+		n.records[bit] = c.emit(nodes, d)
+	}
+
+	return index
+}
+
+func encodeControl(buf *bytes.Buffer, typ int, size int) {
+	var ctrl byte
+	var ext []byte
+	if typ > 7 {
+		ext = append(ext, byte(typ-7))
+	} else {
+		ctrl = byte(typ << 5)
+	}
+
+	var sizeBytes []byte
+	switch {
+	case size < 29:
+		ctrl |= byte(size)
+	case size < 285:
+		ctrl |= 29
+		sizeBytes = []byte{byte(size - 29)}
+	case size < 65821:
+		ctrl |= 30
+		s := size - 285
+		sizeBytes = []byte{byte(s >> 8), byte(s)}
+	default:
+		ctrl |= 31
+		s := size - 65821
+		sizeBytes = []byte{byte(s >> 16), byte(s >> 8), byte(s)}
+	}
+
+	buf.WriteByte(ctrl)
+	buf.Write(ext)
+	buf.Write(sizeBytes)
+}
+
+func encodeUint(buf *bytes.Buffer, typ int, v uint64) {
+	var b []byte
+	for ; v > 0; v >>= 8 {
+		b = append([]byte{byte(v)}, b...)
+	}
+
+	encodeControl(buf, typ, len(b))
+	buf.Write(b)
+}
+
+func encodeValue(buf *bytes.Buffer, v interface{}) error {
+	switch vv := v.(type) {
+	case string:
+		encodeControl(buf, 2, len(vv))
+		buf.WriteString(vv)
+	case uint16:
+		encodeUint(buf, 5, uint64(vv))
+	case uint32:
+		encodeUint(buf, 6, uint64(vv))
+	case uint:
+		encodeUint(buf, 6, uint64(vv))
+	case int:
+		encodeUint(buf, 6, uint64(vv))
+	case uint64:
+		encodeUint(buf, 9, vv)
+	case bool:
+		size := 0
+		if vv {
+			size = 1
+		}
+
+		encodeControl(buf, 14, size)
+	case map[string]interface{}:
+		keys := make([]string, 0, len(vv))
+		for k := range vv {
+			keys = append(keys, k)
+		}
+
+		sort.Strings(keys)
+		encodeControl(buf, 7, len(vv))
+		for _, k := range keys {
+			if err := encodeValue(buf, k); err != nil {
+				return err
+			}
+
+			if err := encodeValue(buf, vv[k]); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		encodeControl(buf, 11, len(vv))
+		for _, vi := range vv {
+			if err := encodeValue(buf, vi); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("unsupported value type: %T", v)
+	}
+
+	return nil
+}
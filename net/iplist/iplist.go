@@ -0,0 +1,319 @@
+/*
+Package iplist implements named lists of IP networks, loaded from files or
+URLs, and reloaded in the background. The lists are stored as
+netipx.IPSet, and can contain hundreds of thousands of networks.
+
+The list sources contain one IP address or network in CIDR format per
+line. Empty lines and comments starting with '#' or ';' are ignored, and
+so is the text after the first whitespace of a line, to support the
+common formats of the threat intelligence feeds.
+*/
+package iplist
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/netip"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"go4.org/netipx"
+
+	"github.com/zalando/skipper/metrics"
+	snet "github.com/zalando/skipper/net"
+	"github.com/zalando/skipper/predicates"
+)
+
+const (
+	defaultReloadInterval = time.Minute
+	defaultFetchTimeout   = 30 * time.Second
+)
+
+var (
+	errInvalidDefinition     = errors.New("invalid ip list definition")
+	errInvalidClientIPSource = errors.New("invalid client IP source")
+)
+
+// Options to create a Registry.
+type Options struct {
+
+	// Lists maps the names of the lists to their sources, a file path
+	// or an http or https URL.
+	Lists map[string]string
+
+	// ReloadInterval is the interval of reloading the lists. Defaults
+	// to one minute.
+	ReloadInterval time.Duration
+
+	// ClientIPSource defines how the client IP of the requests is
+	// resolved, with the same logic as the predicates of the same
+	// name: "Source", "SourceFromLast" or "ClientIP". Defaults to
+	// "Source".
+	ClientIPSource string
+
+	// Client is used to load the lists from URLs. Defaults to an HTTP
+	// client with 30 seconds timeout.
+	Client *http.Client
+
+	// Metrics receives the size of the lists, as the
+	// iplist.<name>.networks gauges. Optional.
+	Metrics metrics.Metrics
+}
+
+// List is a named list of IP networks.
+type List struct {
+	name    string
+	source  string
+	set     atomic.Pointer[netipx.IPSet]
+	modTime time.Time
+	etag    string
+}
+
+// Registry contains the configured lists.
+type Registry struct {
+	options Options
+	lists   map[string]*List
+	quit    chan struct{}
+	once    sync.Once
+}
+
+// ParseDefinitions parses list definitions in the format of
+// <name>=<file path or URL>.
+func ParseDefinitions(defs []string) (map[string]string, error) {
+	lists := make(map[string]string, len(defs))
+	for _, d := range defs {
+		name, source, ok := strings.Cut(d, "=")
+		name, source = strings.TrimSpace(name), strings.TrimSpace(source)
+		if !ok || name == "" || source == "" {
+			return nil, fmt.Errorf("%w: %s", errInvalidDefinition, d)
+		}
+
+		if _, exists := lists[name]; exists {
+			return nil, fmt.Errorf("%w: duplicate name: %s", errInvalidDefinition, name)
+		}
+
+		lists[name] = source
+	}
+
+	return lists, nil
+}
+
+// NewRegistry creates a Registry, loading all the configured lists. It
+// fails when any of the lists cannot be loaded. It starts the background
+// reloading of the lists, make sure to Close() it.
+func NewRegistry(o Options) (*Registry, error) {
+	if o.ClientIPSource == "" {
+		o.ClientIPSource = predicates.SourceName
+	} else if !snet.ValidRemoteAddrSource(o.ClientIPSource) {
+		return nil, fmt.Errorf("%w: %s", errInvalidClientIPSource, o.ClientIPSource)
+	}
+
+	if o.ReloadInterval <= 0 {
+		o.ReloadInterval = defaultReloadInterval
+	}
+
+	if o.Client == nil {
+		o.Client = &http.Client{Timeout: defaultFetchTimeout}
+	}
+
+	r := &Registry{
+		options: o,
+		lists:   make(map[string]*List, len(o.Lists)),
+		quit:    make(chan struct{}),
+	}
+
+	for name, source := range o.Lists {
+		l := &List{name: name, source: source}
+		if _, err := r.load(l); err != nil {
+			return nil, err
+		}
+
+		r.lists[name] = l
+	}
+
+	go r.runReloader()
+	return r, nil
+}
+
+// Parse reads a list of IP addresses and networks. It returns the number
+// of the invalid lines that were skipped.
+func Parse(r io.Reader) (*netipx.IPSet, int, error) {
+	var b netipx.IPSetBuilder
+	var invalid int
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+
+		if i := strings.IndexAny(line, " \t;#"); i >= 0 {
+			line = line[:i]
+		}
+
+		if strings.Contains(line, "/") {
+			p, err := netip.ParsePrefix(line)
+			if err != nil {
+				invalid++
+				continue
+			}
+
+			b.AddPrefix(p.Masked())
+			continue
+		}
+
+		addr, err := netip.ParseAddr(line)
+		if err != nil {
+			invalid++
+			continue
+		}
+
+		b.Add(addr.Unmap())
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, invalid, err
+	}
+
+	s, err := b.IPSet()
+	return s, invalid, err
+}
+
+func isURL(source string) bool {
+	return strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://")
+}
+
+// open returns the content of the list source, or nil, when it didn't
+// change since the last load.
+func (r *Registry) open(l *List) (io.ReadCloser, error) {
+	if !isURL(l.source) {
+		fi, err := os.Stat(l.source)
+		if err != nil {
+			return nil, err
+		}
+
+		if fi.ModTime().Equal(l.modTime) {
+			return nil, nil
+		}
+
+		f, err := os.Open(l.source)
+		if err != nil {
+			return nil, err
+		}
+
+		l.modTime = fi.ModTime()
+		return f, nil
+	}
+
+	req, err := http.NewRequest("GET", l.source, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if l.etag != "" {
+		req.Header.Set("If-None-Match", l.etag)
+	}
+
+	rsp, err := r.options.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	switch rsp.StatusCode {
+	case http.StatusOK:
+		l.etag = rsp.Header.Get("ETag")
+		return rsp.Body, nil
+	case http.StatusNotModified:
+		rsp.Body.Close()
+		return nil, nil
+	default:
+		rsp.Body.Close()
+		return nil, fmt.Errorf("failed to load ip list %s, status: %d", l.name, rsp.StatusCode)
+	}
+}
+
+// load loads a list, and returns true if it was updated.
+func (r *Registry) load(l *List) (bool, error) {
+	modTime, etag := l.modTime, l.etag
+	rc, err := r.open(l)
+	if err != nil {
+		return false, fmt.Errorf("failed to load ip list %s: %w", l.name, err)
+	}
+
+	if rc == nil {
+		return false, nil
+	}
+
+	defer rc.Close()
+	s, invalid, err := Parse(rc)
+	if err != nil {
+		// retry on the next reload
+		l.modTime, l.etag = modTime, etag
+		return false, fmt.Errorf("failed to load ip list %s: %w", l.name, err)
+	}
+
+	if invalid > 0 {
+		log.Warnf("Skipped %d invalid lines of ip list %s", invalid, l.name)
+	}
+
+	l.set.Store(s)
+	if r.options.Metrics != nil {
+		r.options.Metrics.UpdateGauge(fmt.Sprintf("iplist.%s.networks", l.name), float64(len(s.Prefixes())))
+	}
+
+	return true, nil
+}
+
+func (r *Registry) runReloader() {
+	ticker := time.NewTicker(r.options.ReloadInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			for _, l := range r.lists {
+				if updated, err := r.load(l); err != nil {
+					log.Errorf("Failed to reload ip list: %v", err)
+				} else if updated {
+					log.Infof("Reloaded ip list %s, networks: %d", l.name, len(l.set.Load().Prefixes()))
+				}
+			}
+		case <-r.quit:
+			return
+		}
+	}
+}
+
+// Get returns a list by name, or nil, when it doesn't exist.
+func (r *Registry) Get(name string) *List {
+	return r.lists[name]
+}
+
+// ClientIP returns the client IP address of a request, according to the
+// configured client IP source.
+func (r *Registry) ClientIP(req *http.Request) netip.Addr {
+	return snet.RemoteAddrBySource(req, r.options.ClientIPSource)
+}
+
+// Close stops the background reloading of the lists.
+func (r *Registry) Close() {
+	r.once.Do(func() { close(r.quit) })
+}
+
+// Name returns the name of the list.
+func (l *List) Name() string {
+	return l.name
+}
+
+// Contains checks if the address is contained by any of the networks of
+// the list.
+func (l *List) Contains(addr netip.Addr) bool {
+	return l.set.Load().Contains(addr.Unmap())
+}
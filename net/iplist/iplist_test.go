@@ -0,0 +1,129 @@
+package iplist_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zalando/skipper/metrics/metricstest"
+	"github.com/zalando/skipper/net/iplist"
+)
+
+func TestParse(t *testing.T) {
+	s, invalid, err := iplist.Parse(strings.NewReader(`
+		# threat intel feed
+		1.2.3.0/24 ; SBL123
+		5.6.7.8
+		2001:db8::/32	# comment
+		not-an-ip
+		1.2.3.4/33
+	`))
+	require.NoError(t, err)
+
+	assert.Equal(t, 2, invalid)
+	assert.Len(t, s.Prefixes(), 3)
+	assert.True(t, s.Contains(netip.MustParseAddr("1.2.3.4")))
+	assert.True(t, s.Contains(netip.MustParseAddr("5.6.7.8")))
+	assert.False(t, s.Contains(netip.MustParseAddr("5.6.7.9")))
+	assert.True(t, s.Contains(netip.MustParseAddr("2001:db8::1")))
+}
+
+func TestParseDefinitions(t *testing.T) {
+	lists, err := iplist.ParseDefinitions([]string{"foo=/etc/foo.txt", "bar = https://www.example.org/bar.txt"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"foo": "/etc/foo.txt", "bar": "https://www.example.org/bar.txt"}, lists)
+
+	for _, defs := range [][]string{{"foo"}, {"=/etc/foo.txt"}, {"foo="}, {"foo=a", "foo=b"}} {
+		_, err := iplist.ParseDefinitions(defs)
+		assert.Error(t, err, "%v", defs)
+	}
+}
+
+func TestRegistryFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "list.txt")
+	require.NoError(t, os.WriteFile(file, []byte("1.2.3.0/24\n"), 0o644))
+
+	m := &metricstest.MockMetrics{}
+	r, err := iplist.NewRegistry(iplist.Options{
+		Lists:          map[string]string{"foo": file},
+		ReloadInterval: 10 * time.Millisecond,
+		Metrics:        m,
+	})
+	require.NoError(t, err)
+	defer r.Close()
+
+	assert.Nil(t, r.Get("bar"))
+
+	l := r.Get("foo")
+	require.NotNil(t, l)
+	assert.True(t, l.Contains(netip.MustParseAddr("1.2.3.4")))
+	assert.False(t, l.Contains(netip.MustParseAddr("5.6.7.8")))
+	m.WithGauges(func(g map[string]float64) { assert.Equal(t, 1.0, g["iplist.foo.networks"]) })
+
+	require.NoError(t, os.WriteFile(file, []byte("1.2.3.0/24\n5.6.7.0/24\n"), 0o644))
+	require.NoError(t, os.Chtimes(file, time.Now(), time.Now().Add(time.Second)))
+	assert.Eventually(t, func() bool { return l.Contains(netip.MustParseAddr("5.6.7.8")) }, time.Second, 10*time.Millisecond)
+}
+
+func TestRegistryURL(t *testing.T) {
+	var requests, notModified atomic.Int64
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			notModified.Add(1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		w.Header().Set("ETag", `"v1"`)
+		w.Write([]byte("1.2.3.0/24\n"))
+	}))
+	defer s.Close()
+
+	r, err := iplist.NewRegistry(iplist.Options{
+		Lists:          map[string]string{"foo": s.URL},
+		ReloadInterval: 10 * time.Millisecond,
+	})
+	require.NoError(t, err)
+	defer r.Close()
+
+	assert.True(t, r.Get("foo").Contains(netip.MustParseAddr("1.2.3.4")))
+	assert.Eventually(t, func() bool { return notModified.Load() > 0 }, time.Second, 10*time.Millisecond)
+	assert.True(t, r.Get("foo").Contains(netip.MustParseAddr("1.2.3.4")))
+}
+
+func TestRegistryInvalid(t *testing.T) {
+	_, err := iplist.NewRegistry(iplist.Options{Lists: map[string]string{"foo": filepath.Join(t.TempDir(), "missing.txt")}})
+	assert.Error(t, err)
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer s.Close()
+
+	_, err = iplist.NewRegistry(iplist.Options{Lists: map[string]string{"foo": s.URL}})
+	assert.Error(t, err)
+
+	_, err = iplist.NewRegistry(iplist.Options{ClientIPSource: "foo"})
+	assert.Error(t, err)
+}
+
+func TestClientIP(t *testing.T) {
+	r, err := iplist.NewRegistry(iplist.Options{ClientIPSource: "ClientIP"})
+	require.NoError(t, err)
+	defer r.Close()
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "1.2.3.4:8080"
+	req.Header.Set("X-Forwarded-For", "5.6.7.8")
+	assert.Equal(t, netip.MustParseAddr("1.2.3.4"), r.ClientIP(req))
+}
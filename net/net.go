@@ -9,6 +9,8 @@ import (
 	"strings"
 
 	"go4.org/netipx"
+
+	"github.com/zalando/skipper/predicates"
 )
 
 // strip port from addresses with hostname, ipv4 or ipv6
@@ -103,6 +105,33 @@ func RemoteHostFromLast(r *http.Request) net.IP {
 	return parse(r.RemoteAddr)
 }
 
+// RemoteAddrBySource returns the address of the client, resolved the
+// same way as by the predicate of the given name: "Source" uses
+// RemoteAddr, "SourceFromLast" uses RemoteAddrFromLast and "ClientIP"
+// uses the remote address of the connection. It defaults to RemoteAddr.
+func RemoteAddrBySource(r *http.Request, source string) netip.Addr {
+	switch source {
+	case predicates.SourceFromLastName:
+		return RemoteAddrFromLast(r)
+	case predicates.ClientIPName:
+		addr, _ := netip.ParseAddr(stripPort(r.RemoteAddr))
+		return addr
+	default:
+		return RemoteAddr(r)
+	}
+}
+
+// ValidRemoteAddrSource checks if the source is supported by
+// RemoteAddrBySource.
+func ValidRemoteAddrSource(source string) bool {
+	switch source {
+	case predicates.SourceName, predicates.SourceFromLastName, predicates.ClientIPName:
+		return true
+	default:
+		return false
+	}
+}
+
 // IPNets is *deprecated* use netipx.IPSet instead
 type IPNets []*net.IPNet
 
@@ -186,7 +215,7 @@ func SchemeHost(input string) (string, string, error) {
 			// so we need to remove them to avoid duplicate pairs of brackets.
 			h = strings.Trim(h, "[]")
 			switch s {
-			case "http":
+			case "http", "h2c":
 				p = "80"
 			case "https":
 				p = "443"
@@ -327,6 +327,12 @@ func TestSchemeHost(t *testing.T) {
 			host:   "example.com:8080",
 			err:    "",
 		}),
+		testCase(TestSchemeHostItem{
+			input:  "h2c://example.com",
+			scheme: "h2c",
+			host:   "example.com:80",
+			err:    "",
+		}),
 
 		testCase(TestSchemeHostItem{
 			input:  "https://example.com",
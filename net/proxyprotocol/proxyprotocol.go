@@ -0,0 +1,449 @@
+/*
+Package proxyprotocol implements a listener that accepts the PROXY protocol
+version 1 and 2 headers, sent by TCP load balancers like AWS NLB or HAProxy
+before the client data:
+
+https://www.haproxy.org/download/2.8/doc/proxy-protocol.txt
+
+The header is accepted only from the trusted source networks. Connections
+from the trusted networks must start with a header, while the connections
+from other sources are used as they are. The source address of the header
+is returned as the remote address of the connection, which makes it the
+remote address of the HTTP requests, too.
+
+The header, including the TLVs, like the AWS VPC endpoint id, can be
+accessed by the request handlers, when the server was configured with
+ConnContext:
+
+	server.ConnContext = proxyprotocol.ConnContext
+	...
+	h, ok := proxyprotocol.FromContext(r.Context())
+*/
+package proxyprotocol
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/zalando/skipper/metrics"
+)
+
+const (
+	// TypeAWS is the TLV type of the AWS specific information.
+	TypeAWS = 0xEA
+
+	// SubtypeAWSVPCEndpointID is the subtype of the AWS TLV containing
+	// the id of the VPC endpoint the connection was received through.
+	SubtypeAWSVPCEndpointID = 0x01
+
+	defaultReadHeaderTimeout = 5 * time.Second
+
+	v1Prefix       = "PROXY "
+	v1MaxLength    = 107
+	v2HeaderLength = 16
+
+	v2CommandLocal = 0x0
+	v2CommandProxy = 0x1
+
+	v2FamilyInet  = 0x1
+	v2FamilyInet6 = 0x2
+	v2FamilyUnix  = 0x3
+
+	v2UnixAddressLength = 216
+)
+
+var v2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+var (
+	ErrInvalidHeader = errors.New("invalid PROXY protocol header")
+	ErrMissingHeader = errors.New("missing PROXY protocol header")
+)
+
+// TLV is a type-length-value field of a version 2 header.
+type TLV struct {
+	Type  byte
+	Value []byte
+}
+
+// Header contains the information received in a PROXY protocol header.
+type Header struct {
+	// Version is either 1 or 2.
+	Version int
+
+	// Source and Destination are the addresses of the original
+	// connection. They are nil when the load balancer didn't proxy a
+	// TCP connection, e.g. for the health checks.
+	Source, Destination net.Addr
+
+	// TLVs contains the type-length-value fields of version 2 headers.
+	TLVs []TLV
+}
+
+// Options for the PROXY protocol listener.
+type Options struct {
+
+	// TrustedNetworks contains the source networks of the load
+	// balancers that send the PROXY protocol header.
+	TrustedNetworks []netip.Prefix
+
+	// ReadHeaderTimeout is the maximum time to receive the header.
+	// Defaults to 5 seconds.
+	ReadHeaderTimeout time.Duration
+
+	// Metrics is an optional metrics registry to count the invalid
+	// and the missing headers.
+	Metrics metrics.Metrics
+}
+
+// Listener wraps a net.Listener, and accepts the PROXY protocol header
+// on the connections from the trusted networks.
+type Listener struct {
+	net.Listener
+	options Options
+}
+
+type conn struct {
+	net.Conn
+	options Options
+	once    sync.Once
+	reader  *bufio.Reader
+	header  *Header
+	err     error
+}
+
+type contextKey struct{}
+
+// NewListener wraps a listener to accept the PROXY protocol headers.
+func NewListener(l net.Listener, o Options) *Listener {
+	if o.ReadHeaderTimeout <= 0 {
+		o.ReadHeaderTimeout = defaultReadHeaderTimeout
+	}
+
+	return &Listener{Listener: l, options: o}
+}
+
+// ParseTrustedNetworks parses a list of IP addresses and networks in CIDR
+// format.
+func ParseTrustedNetworks(s []string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, si := range s {
+		if strings.Contains(si, "/") {
+			p, err := netip.ParsePrefix(si)
+			if err != nil {
+				return nil, err
+			}
+
+			prefixes = append(prefixes, p.Masked())
+			continue
+		}
+
+		a, err := netip.ParseAddr(si)
+		if err != nil {
+			return nil, err
+		}
+
+		prefixes = append(prefixes, netip.PrefixFrom(a, a.BitLen()))
+	}
+
+	return prefixes, nil
+}
+
+func (l *Listener) trusted(a net.Addr) bool {
+	ta, ok := a.(*net.TCPAddr)
+	if !ok {
+		return false
+	}
+
+	ip, ok := netip.AddrFromSlice(ta.IP)
+	if !ok {
+		return false
+	}
+
+	ip = ip.Unmap()
+	for _, p := range l.options.TrustedNetworks {
+		if p.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Accept returns the next connection. The header is read only when the
+// connection is first used, to not block accepting the other
+// connections.
+func (l *Listener) Accept() (net.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+
+	if !l.trusted(c.RemoteAddr()) {
+		return c, nil
+	}
+
+	return &conn{Conn: c, options: l.options}, nil
+}
+
+// ConnContext stores the connection in the context, to make the PROXY
+// protocol header available for FromContext. It can be used as the
+// ConnContext of an http.Server.
+func ConnContext(ctx context.Context, c net.Conn) context.Context {
+	if tc, ok := c.(*tls.Conn); ok {
+		c = tc.NetConn()
+	}
+
+	if pc, ok := c.(*conn); ok {
+		return context.WithValue(ctx, contextKey{}, pc)
+	}
+
+	return ctx
+}
+
+// NewContext returns a context that carries a PROXY protocol header.
+func NewContext(ctx context.Context, h *Header) context.Context {
+	return context.WithValue(ctx, contextKey{}, h)
+}
+
+// FromContext returns the PROXY protocol header of the connection of a
+// request, when the connection was received from a trusted network.
+func FromContext(ctx context.Context) (*Header, bool) {
+	switch v := ctx.Value(contextKey{}).(type) {
+	case *conn:
+		v.init()
+		return v.header, v.header != nil
+	case *Header:
+		return v, v != nil
+	default:
+		return nil, false
+	}
+}
+
+// TLV returns the value of the first TLV with the given type.
+func (h *Header) TLV(typ byte) ([]byte, bool) {
+	for _, t := range h.TLVs {
+		if t.Type == typ {
+			return t.Value, true
+		}
+	}
+
+	return nil, false
+}
+
+// AWSVPCEndpointID returns the id of the AWS VPC endpoint that the
+// connection was received through, or empty string.
+func (h *Header) AWSVPCEndpointID() string {
+	v, ok := h.TLV(TypeAWS)
+	if !ok || len(v) < 1 || v[0] != SubtypeAWSVPCEndpointID {
+		return ""
+	}
+
+	return string(v[1:])
+}
+
+func (c *conn) init() {
+	c.once.Do(func() {
+		c.reader = bufio.NewReaderSize(c.Conn, 256)
+		if err := c.SetReadDeadline(time.Now().Add(c.options.ReadHeaderTimeout)); err != nil {
+			c.err = err
+			return
+		}
+
+		c.header, c.err = readHeader(c.reader)
+		if c.err != nil {
+			if m := c.options.Metrics; m != nil {
+				m.IncCounter("proxy-protocol.errors")
+			}
+
+			log.Debugf("Failed to read PROXY protocol header from %v: %v", c.Conn.RemoteAddr(), c.err)
+			return
+		}
+
+		c.err = c.SetReadDeadline(time.Time{})
+	})
+}
+
+func (c *conn) Read(p []byte) (int, error) {
+	c.init()
+	if c.err != nil {
+		return 0, c.err
+	}
+
+	if c.reader.Buffered() > 0 {
+		return c.reader.Read(p)
+	}
+
+	return c.Conn.Read(p)
+}
+
+// RemoteAddr returns the source address received in the header, or the
+// address of the load balancer when the header has no address.
+func (c *conn) RemoteAddr() net.Addr {
+	c.init()
+	if c.header != nil && c.header.Source != nil {
+		return c.header.Source
+	}
+
+	return c.Conn.RemoteAddr()
+}
+
+func readHeader(r *bufio.Reader) (*Header, error) {
+	sig, err := r.Peek(len(v2Signature))
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case bytes.Equal(sig, v2Signature):
+		return readV2(r)
+	case bytes.HasPrefix(sig, []byte(v1Prefix)):
+		return readV1(r)
+	default:
+		return nil, ErrMissingHeader
+	}
+}
+
+func readV1(r *bufio.Reader) (*Header, error) {
+	var line []byte
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidHeader, err)
+		}
+
+		line = append(line, b)
+		if b == '\n' {
+			break
+		}
+
+		if len(line) >= v1MaxLength {
+			return nil, ErrInvalidHeader
+		}
+	}
+
+	if !bytes.HasSuffix(line, []byte("\r\n")) {
+		return nil, ErrInvalidHeader
+	}
+
+	fields := strings.Split(string(line[len(v1Prefix):len(line)-2]), " ")
+	h := &Header{Version: 1}
+	switch fields[0] {
+	case "UNKNOWN":
+		return h, nil
+	case "TCP4", "TCP6":
+	default:
+		return nil, ErrInvalidHeader
+	}
+
+	if len(fields) != 5 {
+		return nil, ErrInvalidHeader
+	}
+
+	src, err := parseV1Addr(fields[0], fields[1], fields[3])
+	if err != nil {
+		return nil, err
+	}
+
+	dst, err := parseV1Addr(fields[0], fields[2], fields[4])
+	if err != nil {
+		return nil, err
+	}
+
+	h.Source, h.Destination = src, dst
+	return h, nil
+}
+
+func parseV1Addr(protocol, address, port string) (net.Addr, error) {
+	ip, err := netip.ParseAddr(address)
+	if err != nil || ip.Is4() != (protocol == "TCP4") {
+		return nil, ErrInvalidHeader
+	}
+
+	p, err := strconv.ParseUint(port, 10, 16)
+	if err != nil || (len(port) > 1 && port[0] == '0') {
+		return nil, ErrInvalidHeader
+	}
+
+	return net.TCPAddrFromAddrPort(netip.AddrPortFrom(ip, uint16(p))), nil
+}
+
+func readV2(r *bufio.Reader) (*Header, error) {
+	fixed := make([]byte, v2HeaderLength)
+	if _, err := io.ReadFull(r, fixed); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidHeader, err)
+	}
+
+	if fixed[12]>>4 != 2 {
+		return nil, ErrInvalidHeader
+	}
+
+	payload := make([]byte, binary.BigEndian.Uint16(fixed[14:]))
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidHeader, err)
+	}
+
+	h := &Header{Version: 2}
+	switch fixed[12] & 0xf {
+	case v2CommandLocal:
+		return h, nil
+	case v2CommandProxy:
+	default:
+		return nil, ErrInvalidHeader
+	}
+
+	var ipLength, addressLength int
+	switch fixed[13] >> 4 {
+	case v2FamilyInet:
+		ipLength = net.IPv4len
+		addressLength = 2*ipLength + 4
+	case v2FamilyInet6:
+		ipLength = net.IPv6len
+		addressLength = 2*ipLength + 4
+	case v2FamilyUnix:
+		// the unix socket addresses are skipped, but the TLVs are kept
+		addressLength = v2UnixAddressLength
+	}
+
+	if len(payload) < addressLength {
+		return nil, ErrInvalidHeader
+	}
+
+	if ipLength > 0 {
+		src, _ := netip.AddrFromSlice(payload[:ipLength])
+		dst, _ := netip.AddrFromSlice(payload[ipLength : 2*ipLength])
+		ports := payload[2*ipLength:]
+		h.Source = net.TCPAddrFromAddrPort(netip.AddrPortFrom(src, binary.BigEndian.Uint16(ports)))
+		h.Destination = net.TCPAddrFromAddrPort(netip.AddrPortFrom(dst, binary.BigEndian.Uint16(ports[2:])))
+	}
+
+	tlvs := payload[addressLength:]
+	for len(tlvs) > 0 {
+		if len(tlvs) < 3 {
+			return nil, ErrInvalidHeader
+		}
+
+		l := int(binary.BigEndian.Uint16(tlvs[1:]))
+		if len(tlvs) < 3+l {
+			return nil, ErrInvalidHeader
+		}
+
+		h.TLVs = append(h.TLVs, TLV{Type: tlvs[0], Value: tlvs[3 : 3+l]})
+		tlvs = tlvs[3+l:]
+	}
+
+	return h, nil
+}
@@ -0,0 +1,231 @@
+package proxyprotocol
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zalando/skipper/metrics/metricstest"
+)
+
+func v2Header(command, family byte, addresses []byte, tlvs ...TLV) string {
+	payload := append([]byte(nil), addresses...)
+	for _, t := range tlvs {
+		payload = append(payload, t.Type, 0, 0)
+		binary.BigEndian.PutUint16(payload[len(payload)-2:], uint16(len(t.Value)))
+		payload = append(payload, t.Value...)
+	}
+
+	h := append([]byte(nil), v2Signature...)
+	h = append(h, 0x20|command, family<<4|0x1, 0, 0)
+	binary.BigEndian.PutUint16(h[14:], uint16(len(payload)))
+	return string(append(h, payload...))
+}
+
+func ipv4Addresses() []byte {
+	return []byte{
+		192, 0, 2, 1,
+		198, 51, 100, 1,
+		0x30, 0x39,
+		0x01, 0xbb,
+	}
+}
+
+func TestReadHeader(t *testing.T) {
+	aws := TLV{Type: TypeAWS, Value: []byte("\x01vpce-0123456789abcdef0")}
+	ipv6 := append(append(netip.MustParseAddr("2001:db8::1").AsSlice(), netip.MustParseAddr("2001:db8::2").AsSlice()...), 0, 80, 0, 81)
+
+	for _, tt := range []struct {
+		name        string
+		input       string
+		source      string
+		destination string
+		tlvs        []TLV
+		err         error
+	}{
+		{name: "v1 tcp4", input: "PROXY TCP4 192.0.2.1 198.51.100.1 12345 443\r\n", source: "192.0.2.1:12345", destination: "198.51.100.1:443"},
+		{name: "v1 tcp6", input: "PROXY TCP6 2001:db8::1 2001:db8::2 80 81\r\n", source: "[2001:db8::1]:80", destination: "[2001:db8::2]:81"},
+		{name: "v1 unknown", input: "PROXY UNKNOWN\r\n"},
+		{name: "v1 unknown with addresses", input: "PROXY UNKNOWN 192.0.2.1 198.51.100.1 12345 443\r\n"},
+		{name: "v1 family mismatch", input: "PROXY TCP4 2001:db8::1 198.51.100.1 12345 443\r\n", err: ErrInvalidHeader},
+		{name: "v1 invalid port", input: "PROXY TCP4 192.0.2.1 198.51.100.1 012345 443\r\n", err: ErrInvalidHeader},
+		{name: "v1 missing fields", input: "PROXY TCP4 192.0.2.1 198.51.100.1 12345\r\n", err: ErrInvalidHeader},
+		{name: "v1 missing CR", input: "PROXY TCP4 192.0.2.1 198.51.100.1 12345 443\n", err: ErrInvalidHeader},
+		{name: "v1 too long", input: "PROXY " + strings.Repeat("X", 120) + "\r\n", err: ErrInvalidHeader},
+		{name: "v2 ipv4", input: v2Header(v2CommandProxy, v2FamilyInet, ipv4Addresses()), source: "192.0.2.1:12345", destination: "198.51.100.1:443"},
+		{name: "v2 ipv6", input: v2Header(v2CommandProxy, v2FamilyInet6, ipv6), source: "[2001:db8::1]:80", destination: "[2001:db8::2]:81"},
+		{name: "v2 tlvs", input: v2Header(v2CommandProxy, v2FamilyInet, ipv4Addresses(), aws), source: "192.0.2.1:12345", destination: "198.51.100.1:443", tlvs: []TLV{aws}},
+		{name: "v2 local", input: v2Header(v2CommandLocal, 0, nil)},
+		{name: "v2 unspecified family", input: v2Header(v2CommandProxy, 0, nil, aws), tlvs: []TLV{aws}},
+		{name: "v2 short addresses", input: v2Header(v2CommandProxy, v2FamilyInet6, ipv4Addresses()), err: ErrInvalidHeader},
+		{name: "v2 invalid tlv", input: v2Header(v2CommandProxy, v2FamilyInet, append(ipv4Addresses(), TypeAWS, 0, 42)), err: ErrInvalidHeader},
+		{name: "v2 invalid command", input: v2Header(0x2, v2FamilyInet, ipv4Addresses()), err: ErrInvalidHeader},
+		{name: "v2 truncated", input: v2Header(v2CommandProxy, v2FamilyInet, ipv4Addresses())[:20], err: ErrInvalidHeader},
+		{name: "missing", input: "GET / HTTP/1.1\r\nHost: example.org\r\n\r\n", err: ErrMissingHeader},
+		{name: "short", input: "GET", err: io.EOF},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			h, err := readHeader(bufio.NewReader(strings.NewReader(tt.input)))
+			if tt.err != nil {
+				assert.ErrorIs(t, err, tt.err)
+				return
+			}
+
+			require.NoError(t, err)
+			if tt.source == "" {
+				assert.Nil(t, h.Source)
+				assert.Nil(t, h.Destination)
+			} else {
+				assert.Equal(t, tt.source, h.Source.String())
+				assert.Equal(t, tt.destination, h.Destination.String())
+			}
+
+			assert.Equal(t, tt.tlvs, h.TLVs)
+		})
+	}
+}
+
+func TestAWSVPCEndpointID(t *testing.T) {
+	for _, tt := range []struct {
+		tlvs     []TLV
+		expected string
+	}{
+		{nil, ""},
+		{[]TLV{{Type: 0x01, Value: []byte("h2")}}, ""},
+		{[]TLV{{Type: TypeAWS, Value: []byte("\x02foo")}}, ""},
+		{[]TLV{{Type: TypeAWS, Value: nil}}, ""},
+		{[]TLV{{Type: 0x01, Value: []byte("h2")}, {Type: TypeAWS, Value: []byte("\x01vpce-1")}}, "vpce-1"},
+	} {
+		assert.Equal(t, tt.expected, (&Header{TLVs: tt.tlvs}).AWSVPCEndpointID())
+	}
+}
+
+func TestParseTrustedNetworks(t *testing.T) {
+	prefixes, err := ParseTrustedNetworks([]string{"10.0.0.0/8", "192.0.2.1", "2001:db8::1/32"})
+	require.NoError(t, err)
+	assert.Equal(t, []netip.Prefix{
+		netip.MustParsePrefix("10.0.0.0/8"),
+		netip.MustParsePrefix("192.0.2.1/32"),
+		netip.MustParsePrefix("2001:db8::/32"),
+	}, prefixes)
+
+	for _, invalid := range []string{"10.0.0.0/33", "foo", ""} {
+		_, err := ParseTrustedNetworks([]string{invalid})
+		assert.Error(t, err, invalid)
+	}
+}
+
+func startServer(t *testing.T, o Options) (string, *metricstest.MockMetrics) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	m := &metricstest.MockMetrics{}
+	o.Metrics = m
+	s := &httptest.Server{
+		Listener: NewListener(l, o),
+		Config: &http.Server{
+			ConnContext: ConnContext,
+			Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				vpce := "-"
+				if h, ok := FromContext(r.Context()); ok && h.AWSVPCEndpointID() != "" {
+					vpce = h.AWSVPCEndpointID()
+				}
+
+				io.WriteString(w, r.RemoteAddr+" "+vpce)
+			}),
+		},
+	}
+
+	s.Start()
+	t.Cleanup(s.Close)
+	return l.Addr().String(), m
+}
+
+func roundTrip(t *testing.T, address, header string) (int, string) {
+	c, err := net.Dial("tcp", address)
+	require.NoError(t, err)
+	defer c.Close()
+
+	require.NoError(t, c.SetDeadline(time.Now().Add(3*time.Second)))
+	_, err = io.WriteString(c, header+"GET / HTTP/1.1\r\nHost: example.org\r\nConnection: close\r\n\r\n")
+	require.NoError(t, err)
+
+	rsp, err := http.ReadResponse(bufio.NewReader(c), nil)
+	require.NoError(t, err)
+	defer rsp.Body.Close()
+
+	b, err := io.ReadAll(rsp.Body)
+	require.NoError(t, err)
+	return rsp.StatusCode, string(b)
+}
+
+func TestListener(t *testing.T) {
+	aws := TLV{Type: TypeAWS, Value: []byte("\x01vpce-0123456789abcdef0")}
+
+	t.Run("trusted", func(t *testing.T) {
+		address, m := startServer(t, Options{TrustedNetworks: []netip.Prefix{netip.MustParsePrefix("127.0.0.0/8")}})
+
+		_, body := roundTrip(t, address, "PROXY TCP4 192.0.2.1 198.51.100.1 12345 443\r\n")
+		assert.Equal(t, "192.0.2.1:12345 -", body)
+
+		_, body = roundTrip(t, address, v2Header(v2CommandProxy, v2FamilyInet, ipv4Addresses(), aws))
+		assert.Equal(t, "192.0.2.1:12345 vpce-0123456789abcdef0", body)
+
+		_, body = roundTrip(t, address, v2Header(v2CommandLocal, 0, nil))
+		assert.True(t, strings.HasPrefix(body, "127.0.0.1:"), body)
+
+		status, _ := roundTrip(t, address, "")
+		assert.Equal(t, http.StatusBadRequest, status)
+		m.WithCounters(func(counters map[string]int64) {
+			assert.Equal(t, int64(1), counters["proxy-protocol.errors"])
+		})
+	})
+
+	t.Run("untrusted", func(t *testing.T) {
+		address, _ := startServer(t, Options{TrustedNetworks: []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}})
+
+		_, body := roundTrip(t, address, "")
+		assert.True(t, strings.HasPrefix(body, "127.0.0.1:"), body)
+		assert.True(t, strings.HasSuffix(body, " -"), body)
+
+		// the header is not accepted from untrusted sources
+		status, _ := roundTrip(t, address, "PROXY TCP4 192.0.2.1 198.51.100.1 12345 443\r\n")
+		assert.Equal(t, http.StatusBadRequest, status)
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		address, _ := startServer(t, Options{
+			TrustedNetworks:   []netip.Prefix{netip.MustParsePrefix("127.0.0.1/32")},
+			ReadHeaderTimeout: 50 * time.Millisecond,
+		})
+
+		c, err := net.Dial("tcp", address)
+		require.NoError(t, err)
+		defer c.Close()
+
+		require.NoError(t, c.SetReadDeadline(time.Now().Add(3*time.Second)))
+		_, err = c.Read(make([]byte, 1))
+		assert.ErrorIs(t, err, io.EOF)
+	})
+}
+
+func TestFromContext(t *testing.T) {
+	_, ok := FromContext(context.Background())
+	assert.False(t, ok)
+
+	h := &Header{Version: 2}
+	got, ok := FromContext(NewContext(context.Background(), h))
+	assert.True(t, ok)
+	assert.Same(t, h, got)
+}
@@ -0,0 +1,214 @@
+package websocket
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Opcode is the type of a WebSocket frame.
+type Opcode byte
+
+const (
+	OpContinuation Opcode = 0x0
+	OpText         Opcode = 0x1
+	OpBinary       Opcode = 0x2
+	OpClose        Opcode = 0x8
+	OpPing         Opcode = 0x9
+	OpPong         Opcode = 0xa
+)
+
+// Close status codes used by the proxy, see
+// https://www.rfc-editor.org/rfc/rfc6455#section-7.4.1.
+const (
+	CloseNormal        = 1000
+	CloseGoingAway     = 1001
+	CloseProtocolError = 1002
+	CloseMessageTooBig = 1009
+)
+
+const (
+	finBit  = 0x80
+	rsv1Bit = 0x40
+	rsvBits = 0x70
+	maskBit = 0x80
+
+	maxControlPayload = 125
+)
+
+var (
+	// ErrMessageTooBig is returned when a frame or a message exceeds
+	// the maximum size.
+	ErrMessageTooBig = errors.New("websocket: message too big")
+
+	// ErrProtocol is returned for the frames violating the protocol.
+	ErrProtocol = errors.New("websocket: protocol error")
+)
+
+// Frame is a single WebSocket frame. The payload of a received frame
+// is unmasked.
+type Frame struct {
+	Fin     bool
+	RSV     byte
+	Opcode  Opcode
+	Masked  bool
+	Payload []byte
+}
+
+// IsControl returns true for the close, ping and pong frames.
+func (o Opcode) IsControl() bool {
+	return o&0x8 != 0
+}
+
+func (o Opcode) String() string {
+	switch o {
+	case OpContinuation:
+		return "continuation"
+	case OpText:
+		return "text"
+	case OpBinary:
+		return "binary"
+	case OpClose:
+		return "close"
+	case OpPing:
+		return "ping"
+	case OpPong:
+		return "pong"
+	default:
+		return fmt.Sprintf("opcode(%d)", byte(o))
+	}
+}
+
+// ReadFrame reads a frame from r. When maxPayload is greater than zero,
+// the frames with larger payload are rejected with ErrMessageTooBig,
+// before reading the payload.
+func ReadFrame(r io.Reader, maxPayload int64) (*Frame, error) {
+	var h [14]byte
+	if _, err := io.ReadFull(r, h[:2]); err != nil {
+		return nil, err
+	}
+
+	f := &Frame{
+		Fin:    h[0]&finBit != 0,
+		RSV:    h[0] & rsvBits,
+		Opcode: Opcode(h[0] & 0x0f),
+		Masked: h[1]&maskBit != 0,
+	}
+
+	switch f.Opcode {
+	case OpContinuation, OpText, OpBinary, OpClose, OpPing, OpPong:
+	default:
+		return nil, ErrProtocol
+	}
+
+	length := uint64(h[1] & 0x7f)
+	if f.Opcode.IsControl() && (!f.Fin || length > maxControlPayload) {
+		return nil, ErrProtocol
+	}
+
+	switch length {
+	case 126:
+		if _, err := io.ReadFull(r, h[2:4]); err != nil {
+			return nil, err
+		}
+
+		length = uint64(binary.BigEndian.Uint16(h[2:4]))
+	case 127:
+		if _, err := io.ReadFull(r, h[2:10]); err != nil {
+			return nil, err
+		}
+
+		length = binary.BigEndian.Uint64(h[2:10])
+		if length > 1<<63-1 {
+			return nil, ErrProtocol
+		}
+	}
+
+	if maxPayload > 0 && length > uint64(maxPayload) {
+		return nil, ErrMessageTooBig
+	}
+
+	var mask [4]byte
+	if f.Masked {
+		if _, err := io.ReadFull(r, mask[:]); err != nil {
+			return nil, err
+		}
+	}
+
+	f.Payload = make([]byte, length)
+	if _, err := io.ReadFull(r, f.Payload); err != nil {
+		return nil, err
+	}
+
+	if f.Masked {
+		maskBytes(mask, f.Payload)
+	}
+
+	return f, nil
+}
+
+// WriteFrame writes the frame to w. When mask is true, the payload is
+// masked with a random key, as required for the frames sent by
+// clients. The payload of f is not modified.
+func WriteFrame(w io.Writer, f *Frame, mask bool) error {
+	var h [14]byte
+	h[0] = byte(f.Opcode) | f.RSV&rsvBits
+	if f.Fin {
+		h[0] |= finBit
+	}
+
+	n := 2
+	length := len(f.Payload)
+	switch {
+	case length <= 125:
+		h[1] = byte(length)
+	case length <= 0xffff:
+		h[1] = 126
+		binary.BigEndian.PutUint16(h[2:4], uint16(length))
+		n = 4
+	default:
+		h[1] = 127
+		binary.BigEndian.PutUint64(h[2:10], uint64(length))
+		n = 10
+	}
+
+	payload := f.Payload
+	if mask {
+		var key [4]byte
+		if _, err := rand.Read(key[:]); err != nil {
+			return err
+		}
+
+		h[1] |= maskBit
+		copy(h[n:], key[:])
+		n += 4
+
+		payload = append([]byte(nil), f.Payload...)
+		maskBytes(key, payload)
+	}
+
+	// a single write, to not interleave with the concurrent writers
+	_, err := w.Write(append(h[:n:n], payload...))
+	return err
+}
+
+// ClosePayload returns the payload of a close frame with the status
+// code and the reason.
+func ClosePayload(code int, reason string) []byte {
+	if len(reason) > maxControlPayload-2 {
+		reason = reason[:maxControlPayload-2]
+	}
+
+	p := make([]byte, 2+len(reason))
+	binary.BigEndian.PutUint16(p, uint16(code))
+	copy(p[2:], reason)
+	return p
+}
+
+func maskBytes(key [4]byte, b []byte) {
+	for i := range b {
+		b[i] ^= key[i%4]
+	}
+}
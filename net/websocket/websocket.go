@@ -0,0 +1,588 @@
+/*
+Package websocket implements a relay of the upgraded WebSocket
+connections that parses the frames instead of copying the bytes:
+
+https://www.rfc-editor.org/rfc/rfc6455
+
+The relay counts the messages and the bytes per direction, enforces the
+maximum message size and the idle and ping timeouts, and passes the
+messages to the optional message handlers, that can inspect, modify or
+drop them. The fragmented messages are reassembled only for the
+handlers, without handlers the frames are forwarded as they are. The
+control frames are forwarded as they are, except for the pongs answering
+the pings of the relay.
+
+The relay closes the connections with a close frame when they exceed the
+limits, when their route is removed from the routing table, or when the
+relay is closed on shutdown. To track the route removals, the relay needs
+to be registered as a routing.PostProcessor.
+*/
+package websocket
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/zalando/skipper/metrics"
+	"github.com/zalando/skipper/routing"
+)
+
+const (
+	defaultMaxMessageSize = 1 << 20
+	defaultCloseTimeout   = 5 * time.Second
+	minMonitorInterval    = 10 * time.Millisecond
+)
+
+var pingPayload = []byte("skipper")
+
+// Direction tells which side of the connection sent a message.
+type Direction int
+
+const (
+	FromClient Direction = iota
+	FromBackend
+)
+
+func (d Direction) String() string {
+	if d == FromClient {
+		return "client"
+	}
+
+	return "backend"
+}
+
+// Message is a reassembled text or binary WebSocket message.
+type Message struct {
+	Direction Direction
+	Opcode    Opcode
+
+	// Compressed is true when the message was compressed by a
+	// negotiated extension, e.g. permessage-deflate, and the Data
+	// contains the compressed bytes. The relay doesn't decompress the
+	// messages, and the replaced Data of a compressed message needs to
+	// be compressed, too.
+	Compressed bool
+
+	// Data is the unmasked payload of the message. The handlers can
+	// replace it.
+	Data []byte
+
+	rsv byte
+}
+
+// MessageHandler inspects the messages of a WebSocket connection.
+type MessageHandler interface {
+
+	// HandleMessage is called for every text and binary message, in
+	// the order they were received in a direction. When it returns
+	// false, the message is dropped. The handlers of the two
+	// directions may be called concurrently.
+	HandleMessage(*Message) bool
+}
+
+// MessageHandlerFunc implements MessageHandler with a function.
+type MessageHandlerFunc func(*Message) bool
+
+func (f MessageHandlerFunc) HandleMessage(m *Message) bool { return f(m) }
+
+// Options configures the WebSocket relay.
+type Options struct {
+
+	// MaxMessageSize limits the size of the reassembled messages.
+	// The connections sending larger messages are closed with the
+	// 1009 status. Defaults to 1MiB.
+	MaxMessageSize int64
+
+	// IdleTimeout closes the connections without text or binary
+	// messages in either direction for the given duration. Disabled
+	// when zero.
+	IdleTimeout time.Duration
+
+	// PingInterval enables sending pings to the clients that didn't
+	// send any frame for the given duration.
+	PingInterval time.Duration
+
+	// PingTimeout closes the connections of the clients that didn't
+	// send any frame for the given duration after a ping. Defaults
+	// to the PingInterval.
+	PingTimeout time.Duration
+
+	// CloseTimeout is the time to wait for the close frames of the
+	// peers, when the relay closes a connection. Defaults to 5s.
+	CloseTimeout time.Duration
+
+	// Metrics collects the message, byte and close counters. Defaults
+	// to metrics.Default.
+	Metrics metrics.Metrics
+}
+
+// Endpoint is one side of a relayed connection.
+type Endpoint struct {
+	Conn net.Conn
+
+	// Reader is used to read the frames when set, e.g. to consume the
+	// data buffered during the upgrade. Defaults to Conn.
+	Reader io.Reader
+
+	// Writer is used to write the frames when set, e.g. to copy them
+	// to an audit log. Defaults to Conn.
+	Writer io.Writer
+}
+
+// Relay relays the WebSocket connections and tracks the open ones to
+// close them on route removal and on shutdown.
+type Relay struct {
+	options Options
+	mu      sync.Mutex
+	conns   map[*relayConn]struct{}
+	closed  bool
+}
+
+type peer struct {
+	conn net.Conn
+	r    io.Reader
+	w    io.Writer
+	mask bool
+
+	// sem serializes the writes, allowing to wait for a write with
+	// a timeout
+	sem chan struct{}
+
+	// deadlineMu guards the write deadline and closing, it is not
+	// held during the writes
+	deadlineMu sync.Mutex
+	closing    bool
+}
+
+type relayConn struct {
+	relay    *Relay
+	routeID  string
+	handlers []MessageHandler
+	client   *peer
+	backend  *peer
+
+	closeOnce      sync.Once
+	closing        atomic.Bool
+	lastMessage    atomic.Int64
+	lastFromClient atomic.Int64
+	pingSent       atomic.Int64
+	done           chan struct{}
+}
+
+// NewRelay creates a WebSocket relay.
+func NewRelay(o Options) *Relay {
+	if o.MaxMessageSize <= 0 {
+		o.MaxMessageSize = defaultMaxMessageSize
+	}
+
+	if o.PingTimeout <= 0 {
+		o.PingTimeout = o.PingInterval
+	}
+
+	if o.CloseTimeout <= 0 {
+		o.CloseTimeout = defaultCloseTimeout
+	}
+
+	if o.Metrics == nil {
+		o.Metrics = metrics.Default
+	}
+
+	return &Relay{options: o, conns: make(map[*relayConn]struct{})}
+}
+
+// Do implements routing.PostProcessor. It closes the connections of the
+// routes that were removed from the routing table.
+func (r *Relay) Do(routes []*routing.Route) []*routing.Route {
+	ids := make(map[string]struct{}, len(routes))
+	for _, rt := range routes {
+		ids[rt.Id] = struct{}{}
+	}
+
+	var removed []*relayConn
+	r.mu.Lock()
+	for c := range r.conns {
+		if _, ok := ids[c.routeID]; !ok && c.routeID != "" {
+			removed = append(removed, c)
+		}
+	}
+	r.mu.Unlock()
+
+	for _, c := range removed {
+		c.close(CloseGoingAway, "route removed", "route")
+	}
+
+	return routes
+}
+
+// DoRollout implements routing.RolloutPostProcessor. The connections
+// are closed only when the routes are removed by a promoted generation.
+func (r *Relay) DoRollout(routes []*routing.Route) []*routing.Route {
+	return routes
+}
+
+// Len returns the number of the open connections.
+func (r *Relay) Len() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.conns)
+}
+
+// Close closes the open connections with the going away status and
+// waits for them to finish, at most for the CloseTimeout. The
+// connections served after Close are closed immediately.
+func (r *Relay) Close() {
+	r.mu.Lock()
+	r.closed = true
+	conns := make([]*relayConn, 0, len(r.conns))
+	for c := range r.conns {
+		conns = append(conns, c)
+	}
+	r.mu.Unlock()
+
+	for _, c := range conns {
+		c.close(CloseGoingAway, "shutdown", "shutdown")
+	}
+
+	timeout := time.NewTimer(r.options.CloseTimeout)
+	defer timeout.Stop()
+	for _, c := range conns {
+		select {
+		case <-c.done:
+		case <-timeout.C:
+			return
+		}
+	}
+}
+
+// Serve relays the messages between the client and the backend of an
+// upgraded WebSocket connection, until one of the sides closes the
+// connection, or the relay closes it. The routeID identifies the route
+// of the connection. The caller is responsible for closing the
+// connections after Serve returned.
+func (r *Relay) Serve(routeID string, client, backend Endpoint, handlers []MessageHandler) {
+	c := &relayConn{
+		relay:    r,
+		routeID:  routeID,
+		handlers: handlers,
+		client:   newPeer(client, false),
+		backend:  newPeer(backend, true),
+		done:     make(chan struct{}),
+	}
+
+	now := time.Now().UnixNano()
+	c.lastMessage.Store(now)
+	c.lastFromClient.Store(now)
+
+	defer close(c.done)
+	if !r.add(c) {
+		c.close(CloseGoingAway, "shutdown", "shutdown")
+	}
+
+	defer r.remove(c)
+	r.options.Metrics.IncCounter("websocket.connections")
+
+	errs := make(chan error, 2)
+	go func() { errs <- c.pump(FromClient, c.client, c.backend) }()
+	go func() { errs <- c.pump(FromBackend, c.backend, c.client) }()
+	go c.monitor()
+
+	err := <-errs
+	switch {
+	case errors.Is(err, ErrMessageTooBig):
+		c.close(CloseMessageTooBig, "message too big", "message_size")
+	case errors.Is(err, ErrProtocol):
+		c.close(CloseProtocolError, "protocol error", "protocol")
+	case err != nil && !c.closing.Load():
+		log.Debugf("WebSocket connection of route %s finished: %v", routeID, err)
+		return
+	}
+
+	if !c.closing.Load() {
+		// the close frame was forwarded, waiting for the answer
+		deadline := time.Now().Add(r.options.CloseTimeout)
+		c.client.conn.SetReadDeadline(deadline)
+		c.backend.conn.SetReadDeadline(deadline)
+	}
+
+	<-errs
+}
+
+func (r *Relay) add(c *relayConn) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return false
+	}
+
+	r.conns[c] = struct{}{}
+	return true
+}
+
+func (r *Relay) remove(c *relayConn) {
+	r.mu.Lock()
+	delete(r.conns, c)
+	r.mu.Unlock()
+}
+
+func newPeer(e Endpoint, mask bool) *peer {
+	p := &peer{conn: e.Conn, r: e.Reader, w: e.Writer, mask: mask, sem: make(chan struct{}, 1)}
+	if p.r == nil {
+		p.r = e.Conn
+	}
+
+	if p.w == nil {
+		p.w = e.Conn
+	}
+
+	return p
+}
+
+func (p *peer) write(f *Frame) error {
+	p.sem <- struct{}{}
+	defer func() { <-p.sem }()
+	return WriteFrame(p.w, f, p.mask)
+}
+
+// tryWrite writes the frame unless another write is in progress, e.g.
+// blocked by a peer not reading.
+func (p *peer) tryWrite(f *Frame, deadline time.Time) {
+	select {
+	case p.sem <- struct{}{}:
+	default:
+		return
+	}
+
+	defer func() { <-p.sem }()
+	p.setWriteDeadline(deadline)
+	if err := WriteFrame(p.w, f, p.mask); err != nil {
+		log.Debugf("Failed to write WebSocket %s frame: %v", f.Opcode, err)
+	}
+
+	p.setWriteDeadline(time.Time{})
+}
+
+func (p *peer) setWriteDeadline(t time.Time) {
+	p.deadlineMu.Lock()
+	defer p.deadlineMu.Unlock()
+	if !p.closing {
+		p.conn.SetWriteDeadline(t)
+	}
+}
+
+// close sets the deadline of the connection, unblocking the pending
+// reads and writes at the latest then, and sends the close frame in the
+// background, after the pending write.
+func (p *peer) close(f *Frame, deadline time.Time) {
+	p.deadlineMu.Lock()
+	p.closing = true
+	p.conn.SetDeadline(deadline)
+	p.deadlineMu.Unlock()
+
+	go func() {
+		timeout := time.NewTimer(time.Until(deadline))
+		defer timeout.Stop()
+		select {
+		case p.sem <- struct{}{}:
+		case <-timeout.C:
+			return
+		}
+
+		defer func() { <-p.sem }()
+		if err := WriteFrame(p.w, f, p.mask); err != nil {
+			log.Debugf("Failed to write WebSocket %s frame: %v", f.Opcode, err)
+		}
+	}()
+}
+
+func (c *relayConn) pump(dir Direction, src, dst *peer) error {
+	var (
+		msg        *Message
+		fragmented bool
+		size       int64
+	)
+
+	for {
+		f, err := ReadFrame(src.r, c.relay.options.MaxMessageSize)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now().UnixNano()
+		if dir == FromClient {
+			c.lastFromClient.Store(now)
+		}
+
+		if c.closing.Load() {
+			if f.Opcode == OpClose {
+				return nil
+			}
+
+			continue
+		}
+
+		switch {
+		case f.Opcode == OpClose:
+			return dst.write(f)
+		case f.Opcode == OpPong && dir == FromClient && bytes.Equal(f.Payload, pingPayload):
+			c.pingSent.Store(0)
+			continue
+		case f.Opcode.IsControl():
+			if err := dst.write(f); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		c.lastMessage.Store(now)
+
+		// without handlers, the frames are forwarded unchanged
+		if len(c.handlers) == 0 {
+			if fragmented != (f.Opcode == OpContinuation) {
+				return ErrProtocol
+			}
+
+			size += int64(len(f.Payload))
+			if size > c.relay.options.MaxMessageSize {
+				return ErrMessageTooBig
+			}
+
+			if err := dst.write(f); err != nil {
+				return err
+			}
+
+			fragmented = !f.Fin
+			if f.Fin {
+				c.countMessage(dir, size)
+				size = 0
+			}
+
+			continue
+		}
+
+		if f.Opcode == OpContinuation {
+			if msg == nil {
+				return ErrProtocol
+			}
+
+			msg.Data = append(msg.Data, f.Payload...)
+		} else {
+			if msg != nil {
+				return ErrProtocol
+			}
+
+			msg = &Message{
+				Direction:  dir,
+				Opcode:     f.Opcode,
+				Compressed: f.RSV&rsv1Bit != 0,
+				Data:       f.Payload,
+				rsv:        f.RSV,
+			}
+		}
+
+		if int64(len(msg.Data)) > c.relay.options.MaxMessageSize {
+			return ErrMessageTooBig
+		}
+
+		if !f.Fin {
+			continue
+		}
+
+		m := msg
+		msg = nil
+
+		c.countMessage(dir, int64(len(m.Data)))
+		if !c.handle(m) {
+			c.relay.options.Metrics.IncCounter("websocket.messages.dropped")
+			continue
+		}
+
+		if err := dst.write(&Frame{Fin: true, RSV: m.rsv, Opcode: m.Opcode, Payload: m.Data}); err != nil {
+			return err
+		}
+	}
+}
+
+func (c *relayConn) countMessage(dir Direction, size int64) {
+	mtr := c.relay.options.Metrics
+	mtr.IncCounter("websocket.messages." + dir.String())
+	mtr.IncCounterBy("websocket.bytes."+dir.String(), size)
+}
+
+func (c *relayConn) handle(m *Message) bool {
+	for _, h := range c.handlers {
+		if !h.HandleMessage(m) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (c *relayConn) monitor() {
+	o := c.relay.options
+	interval := time.Duration(0)
+	for _, d := range []time.Duration{o.IdleTimeout, o.PingInterval, o.PingTimeout} {
+		if d > 0 && (interval == 0 || d < interval) {
+			interval = d
+		}
+	}
+
+	if interval == 0 {
+		return
+	}
+
+	ticker := time.NewTicker(max(interval/4, minMonitorInterval))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.done:
+			return
+		case now := <-ticker.C:
+			if o.IdleTimeout > 0 && now.Sub(time.Unix(0, c.lastMessage.Load())) >= o.IdleTimeout {
+				c.close(CloseGoingAway, "idle timeout", "idle")
+				return
+			}
+
+			if o.PingInterval <= 0 {
+				continue
+			}
+
+			lastFromClient := time.Unix(0, c.lastFromClient.Load())
+			if sent := c.pingSent.Load(); sent != 0 {
+				if lastFromClient.UnixNano() >= sent {
+					c.pingSent.Store(0)
+				} else if now.Sub(time.Unix(0, sent)) >= o.PingTimeout {
+					c.close(CloseGoingAway, "ping timeout", "ping")
+					return
+				}
+			} else if now.Sub(lastFromClient) >= o.PingInterval {
+				c.pingSent.Store(now.UnixNano())
+				c.client.tryWrite(&Frame{Fin: true, Opcode: OpPing, Payload: pingPayload}, now.Add(o.CloseTimeout))
+			}
+		}
+	}
+}
+
+// close sends a close frame to both sides, and lets the pumps wait for
+// the answers until the close timeout.
+func (c *relayConn) close(code int, reason, metric string) {
+	c.closeOnce.Do(func() {
+		c.closing.Store(true)
+		c.relay.options.Metrics.IncCounter("websocket.close." + metric)
+		log.Debugf("Closing WebSocket connection of route %s: %s", c.routeID, reason)
+
+		deadline := time.Now().Add(c.relay.options.CloseTimeout)
+		f := &Frame{Fin: true, Opcode: OpClose, Payload: ClosePayload(code, reason)}
+		c.client.close(f, deadline)
+		c.backend.close(f, deadline)
+	})
+}
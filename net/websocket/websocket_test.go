@@ -0,0 +1,316 @@
+package websocket
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zalando/skipper/eskip"
+	"github.com/zalando/skipper/metrics/metricstest"
+	"github.com/zalando/skipper/routing"
+)
+
+type testConn struct {
+	t      *testing.T
+	client net.Conn
+	mask   bool
+	frames chan *Frame
+}
+
+func newTestConn(t *testing.T, conn net.Conn, mask bool) *testConn {
+	c := &testConn{t: t, client: conn, mask: mask, frames: make(chan *Frame, 16)}
+	go func() {
+		defer close(c.frames)
+		for {
+			f, err := ReadFrame(conn, 0)
+			if err != nil {
+				return
+			}
+
+			c.frames <- f
+		}
+	}()
+
+	t.Cleanup(func() { conn.Close() })
+	return c
+}
+
+func (c *testConn) send(f *Frame) {
+	require.NoError(c.t, WriteFrame(c.client, f, c.mask))
+}
+
+func (c *testConn) receive() *Frame {
+	select {
+	case f := <-c.frames:
+		require.NotNil(c.t, f, "connection closed")
+		return f
+	case <-time.After(time.Second):
+		c.t.Fatal("timeout waiting for frame")
+		return nil
+	}
+}
+
+func (c *testConn) receiveClose() int {
+	for {
+		f := c.receive()
+		if f.Opcode == OpClose {
+			return int(binary.BigEndian.Uint16(f.Payload))
+		}
+	}
+}
+
+type testRelay struct {
+	relay   *Relay
+	metrics *metricstest.MockMetrics
+	client  *testConn
+	backend *testConn
+	done    chan struct{}
+}
+
+func newTestRelay(t *testing.T, o Options, routeID string, handlers ...MessageHandler) *testRelay {
+	m := &metricstest.MockMetrics{}
+	o.Metrics = m
+	o.CloseTimeout = 100 * time.Millisecond
+	tr := newTestRelayWith(t, NewRelay(o), routeID, handlers...)
+	tr.metrics = m
+	return tr
+}
+
+func newTestRelayWith(t *testing.T, r *Relay, routeID string, handlers ...MessageHandler) *testRelay {
+	clientConn, clientRelay := net.Pipe()
+	backendConn, backendRelay := net.Pipe()
+
+	tr := &testRelay{
+		relay:   r,
+		client:  newTestConn(t, clientConn, true),
+		backend: newTestConn(t, backendConn, false),
+		done:    make(chan struct{}),
+	}
+
+	go func() {
+		r.Serve(routeID, Endpoint{Conn: clientRelay}, Endpoint{Conn: backendRelay}, handlers)
+		clientRelay.Close()
+		backendRelay.Close()
+		close(tr.done)
+	}()
+
+	return tr
+}
+
+func (tr *testRelay) wait(t *testing.T) {
+	select {
+	case <-tr.done:
+	case <-time.After(time.Second):
+		t.Fatal("relay did not finish")
+	}
+}
+
+func (tr *testRelay) counter(key string) int64 {
+	var v int64
+	tr.metrics.WithCounters(func(c map[string]int64) { v = c[key] })
+	return v
+}
+
+func TestFrameRoundTrip(t *testing.T) {
+	for _, size := range []int{0, 125, 126, 0xffff, 0x10000} {
+		for _, mask := range []bool{false, true} {
+			payload := bytes.Repeat([]byte("x"), size)
+			var buf bytes.Buffer
+			require.NoError(t, WriteFrame(&buf, &Frame{Fin: true, RSV: rsv1Bit, Opcode: OpBinary, Payload: payload}, mask))
+
+			f, err := ReadFrame(&buf, 0)
+			require.NoError(t, err)
+			assert.True(t, f.Fin)
+			assert.Equal(t, byte(rsv1Bit), f.RSV)
+			assert.Equal(t, OpBinary, f.Opcode)
+			assert.Equal(t, mask, f.Masked)
+			assert.Equal(t, payload, f.Payload)
+		}
+	}
+}
+
+func TestReadFrameErrors(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, WriteFrame(&buf, &Frame{Fin: true, Opcode: OpText, Payload: []byte("hello")}, false))
+	_, err := ReadFrame(&buf, 4)
+	assert.ErrorIs(t, err, ErrMessageTooBig)
+
+	for _, header := range [][]byte{
+		{byte(OpPing), 0},            // fragmented control frame
+		{finBit | byte(OpPing), 126}, // control frame too long
+		{finBit | 0x3, 0},            // reserved opcode
+		{finBit | byte(OpText), 127, 0x80, 0, 0, 0, 0, 0, 0, 0}, // invalid length
+	} {
+		_, err := ReadFrame(bytes.NewReader(header), 0)
+		assert.ErrorIs(t, err, ErrProtocol, "%x", header)
+	}
+}
+
+func TestRelayMessages(t *testing.T) {
+	tr := newTestRelay(t, Options{}, "route", MessageHandlerFunc(func(m *Message) bool {
+		switch string(m.Data) {
+		case "drop":
+			return false
+		case "modify":
+			m.Data = []byte("modified")
+		}
+
+		return true
+	}))
+
+	tr.client.send(&Frame{Fin: true, Opcode: OpText, Payload: []byte("hello")})
+	f := tr.backend.receive()
+	assert.Equal(t, "hello", string(f.Payload))
+	assert.True(t, f.Masked)
+
+	tr.client.send(&Frame{Opcode: OpBinary, Payload: []byte("frag")})
+	tr.client.send(&Frame{Fin: true, Opcode: OpPing, Payload: []byte("p")})
+	tr.client.send(&Frame{Fin: true, Opcode: OpContinuation, Payload: []byte("mented")})
+
+	f = tr.backend.receive()
+	assert.Equal(t, OpPing, f.Opcode)
+	f = tr.backend.receive()
+	assert.Equal(t, OpBinary, f.Opcode)
+	assert.True(t, f.Fin)
+	assert.Equal(t, "fragmented", string(f.Payload))
+
+	tr.backend.send(&Frame{Fin: true, Opcode: OpText, Payload: []byte("drop")})
+	tr.backend.send(&Frame{Fin: true, Opcode: OpText, Payload: []byte("modify")})
+	f = tr.client.receive()
+	assert.Equal(t, "modified", string(f.Payload))
+	assert.False(t, f.Masked)
+
+	tr.client.send(&Frame{Fin: true, Opcode: OpClose, Payload: ClosePayload(CloseNormal, "bye")})
+	assert.Equal(t, CloseNormal, tr.backend.receiveClose())
+	tr.backend.send(&Frame{Fin: true, Opcode: OpClose, Payload: ClosePayload(CloseNormal, "")})
+	assert.Equal(t, CloseNormal, tr.client.receiveClose())
+	tr.wait(t)
+
+	assert.Equal(t, int64(1), tr.counter("websocket.connections"))
+	assert.Equal(t, int64(2), tr.counter("websocket.messages.client"))
+	assert.Equal(t, int64(len("hello")+len("fragmented")), tr.counter("websocket.bytes.client"))
+	assert.Equal(t, int64(2), tr.counter("websocket.messages.backend"))
+	assert.Equal(t, int64(1), tr.counter("websocket.messages.dropped"))
+}
+
+func TestRelayFramesWithoutHandlers(t *testing.T) {
+	tr := newTestRelay(t, Options{}, "route")
+
+	tr.client.send(&Frame{RSV: rsv1Bit, Opcode: OpBinary, Payload: []byte("frag")})
+	tr.client.send(&Frame{Fin: true, Opcode: OpContinuation, Payload: []byte("mented")})
+
+	f := tr.backend.receive()
+	assert.Equal(t, OpBinary, f.Opcode)
+	assert.False(t, f.Fin)
+	assert.Equal(t, byte(rsv1Bit), f.RSV)
+	assert.Equal(t, "frag", string(f.Payload))
+
+	f = tr.backend.receive()
+	assert.Equal(t, OpContinuation, f.Opcode)
+	assert.True(t, f.Fin)
+	assert.Equal(t, "mented", string(f.Payload))
+
+	// a continuation without a started message
+	tr.client.send(&Frame{Fin: true, Opcode: OpContinuation, Payload: []byte("foo")})
+	assert.Equal(t, CloseProtocolError, tr.client.receiveClose())
+	tr.wait(t)
+
+	assert.Equal(t, int64(1), tr.counter("websocket.messages.client"))
+	assert.Equal(t, int64(len("fragmented")), tr.counter("websocket.bytes.client"))
+}
+
+func TestRelayMaxMessageSize(t *testing.T) {
+	tr := newTestRelay(t, Options{MaxMessageSize: 8}, "route")
+
+	tr.client.send(&Frame{Opcode: OpText, Payload: []byte("12345")})
+	tr.client.send(&Frame{Fin: true, Opcode: OpContinuation, Payload: []byte("67890")})
+
+	assert.Equal(t, CloseMessageTooBig, tr.client.receiveClose())
+	assert.Equal(t, CloseMessageTooBig, tr.backend.receiveClose())
+	tr.wait(t)
+	assert.Equal(t, int64(1), tr.counter("websocket.close.message_size"))
+}
+
+func TestRelayIdleTimeout(t *testing.T) {
+	tr := newTestRelay(t, Options{IdleTimeout: 50 * time.Millisecond}, "route")
+
+	tr.client.send(&Frame{Fin: true, Opcode: OpText, Payload: []byte("hello")})
+	tr.backend.receive()
+
+	assert.Equal(t, CloseGoingAway, tr.client.receiveClose())
+	assert.Equal(t, CloseGoingAway, tr.backend.receiveClose())
+	tr.wait(t)
+	assert.Equal(t, int64(1), tr.counter("websocket.close.idle"))
+}
+
+func TestRelayPing(t *testing.T) {
+	tr := newTestRelay(t, Options{PingInterval: 40 * time.Millisecond}, "route")
+
+	f := tr.client.receive()
+	require.Equal(t, OpPing, f.Opcode)
+	tr.client.send(&Frame{Fin: true, Opcode: OpPong, Payload: f.Payload})
+
+	// the answer is not forwarded, the next ping is not answered
+	f = tr.client.receive()
+	require.Equal(t, OpPing, f.Opcode)
+
+	assert.Equal(t, CloseGoingAway, tr.client.receiveClose())
+	assert.Equal(t, CloseGoingAway, tr.backend.receiveClose())
+	tr.wait(t)
+	assert.Equal(t, int64(1), tr.counter("websocket.close.ping"))
+}
+
+func TestRelayRouteRemoved(t *testing.T) {
+	tr := newTestRelay(t, Options{}, "route")
+
+	tr.relay.Do([]*routing.Route{{Route: eskip.Route{Id: "route"}}})
+	tr.client.send(&Frame{Fin: true, Opcode: OpText, Payload: []byte("hello")})
+	tr.backend.receive()
+
+	tr.relay.Do([]*routing.Route{{Route: eskip.Route{Id: "other"}}})
+	assert.Equal(t, CloseGoingAway, tr.client.receiveClose())
+	assert.Equal(t, CloseGoingAway, tr.backend.receiveClose())
+
+	// the peers answer the close
+	tr.client.send(&Frame{Fin: true, Opcode: OpClose, Payload: ClosePayload(CloseGoingAway, "")})
+	tr.backend.send(&Frame{Fin: true, Opcode: OpClose, Payload: ClosePayload(CloseGoingAway, "")})
+	tr.wait(t)
+	assert.Equal(t, int64(1), tr.counter("websocket.close.route"))
+}
+
+func TestRelayClose(t *testing.T) {
+	tr := newTestRelay(t, Options{}, "route")
+	tr.client.send(&Frame{Fin: true, Opcode: OpText, Payload: []byte("hello")})
+	tr.backend.receive()
+
+	closed := make(chan struct{})
+	go func() {
+		tr.relay.Close()
+		close(closed)
+	}()
+
+	assert.Equal(t, CloseGoingAway, tr.client.receiveClose())
+	assert.Equal(t, CloseGoingAway, tr.backend.receiveClose())
+	tr.wait(t)
+	<-closed
+	assert.Equal(t, int64(1), tr.counter("websocket.close.shutdown"))
+
+	// closed immediately after shutdown
+	tr = newTestRelayWith(t, tr.relay, "route")
+	assert.Equal(t, CloseGoingAway, tr.client.receiveClose())
+	tr.wait(t)
+}
+
+func TestRelayBackendClosed(t *testing.T) {
+	tr := newTestRelay(t, Options{}, "route")
+	tr.backend.client.Close()
+	tr.wait(t)
+
+	_, ok := <-tr.client.frames
+	assert.False(t, ok, "no frames expected")
+}
@@ -0,0 +1,228 @@
+package content
+
+import (
+	"mime"
+	"net/http"
+	"net/url"
+	"regexp"
+
+	"github.com/tidwall/gjson"
+
+	skpio "github.com/zalando/skipper/io"
+	"github.com/zalando/skipper/predicates"
+	"github.com/zalando/skipper/routing"
+)
+
+// DefaultMaxBodySize is the default maximum size of the request bodies
+// inspected by the body predicates.
+const DefaultMaxBodySize = 64 * 1024
+
+// BodyOptions configures the body predicates.
+type BodyOptions struct {
+
+	// MaxBodySize is the maximum size of the request bodies inspected
+	// by the predicates. Requests with larger bodies don't match.
+	// Defaults to DefaultMaxBodySize.
+	MaxBodySize int64
+}
+
+type (
+	jsonBodyFieldSpec struct {
+		maxBodySize int64
+		regexp      bool
+	}
+
+	formValueSpec struct {
+		maxBodySize int64
+	}
+
+	jsonBodyFieldPredicate struct {
+		maxBodySize int64
+		path        string
+		value       string
+		regexp      *regexp.Regexp
+	}
+
+	formValuePredicate struct {
+		maxBodySize int64
+		name        string
+		value       string
+	}
+)
+
+type (
+	jsonKey struct{}
+	formKey struct{}
+)
+
+// parsedJSON is the JSON content of a body, cached by the skipper io.Body
+// for the predicates of several routes.
+type parsedJSON struct {
+	json  gjson.Result
+	valid bool
+}
+
+func (o BodyOptions) maxBodySize() int64 {
+	if o.MaxBodySize <= 0 {
+		return DefaultMaxBodySize
+	}
+
+	return o.MaxBodySize
+}
+
+// NewJSONBodyField creates a predicate specification, whose instances
+// match the requests with a JSON body, in which the value at the path,
+// in gjson syntax, equals the argument.
+//
+// Example:
+//
+//	JSONBodyField("operationName", "GetUser")
+func NewJSONBodyField(o BodyOptions) routing.PredicateSpec {
+	return &jsonBodyFieldSpec{maxBodySize: o.maxBodySize()}
+}
+
+// NewJSONBodyFieldRegexp creates a predicate specification, whose
+// instances match the requests with a JSON body, in which the value at the
+// path, in gjson syntax, matches the regular expression.
+//
+// Example:
+//
+//	JSONBodyFieldRegexp("operationName", /^Get/)
+func NewJSONBodyFieldRegexp(o BodyOptions) routing.PredicateSpec {
+	return &jsonBodyFieldSpec{maxBodySize: o.maxBodySize(), regexp: true}
+}
+
+// NewFormValue creates a predicate specification, whose instances match
+// the requests with a URL encoded form body, containing the value for the
+// name.
+//
+// Example:
+//
+//	FormValue("action", "login")
+func NewFormValue(o BodyOptions) routing.PredicateSpec {
+	return &formValueSpec{maxBodySize: o.maxBodySize()}
+}
+
+func stringArgs(args []interface{}) (string, string, bool) {
+	if len(args) != 2 {
+		return "", "", false
+	}
+
+	name, ok := args[0].(string)
+	if !ok || name == "" {
+		return "", "", false
+	}
+
+	value, ok := args[1].(string)
+	return name, value, ok
+}
+
+func (s *jsonBodyFieldSpec) Name() string {
+	if s.regexp {
+		return predicates.JSONBodyFieldRegexpName
+	}
+
+	return predicates.JSONBodyFieldName
+}
+
+func (s *jsonBodyFieldSpec) Create(args []interface{}) (routing.Predicate, error) {
+	path, value, ok := stringArgs(args)
+	if !ok {
+		return nil, predicates.ErrInvalidPredicateParameters
+	}
+
+	p := &jsonBodyFieldPredicate{maxBodySize: s.maxBodySize, path: path, value: value}
+	if s.regexp {
+		re, err := regexp.Compile(value)
+		if err != nil {
+			return nil, err
+		}
+
+		p.regexp = re
+	}
+
+	return p, nil
+}
+
+func (*formValueSpec) Name() string { return predicates.FormValueName }
+
+func (s *formValueSpec) Create(args []interface{}) (routing.Predicate, error) {
+	name, value, ok := stringArgs(args)
+	if !ok {
+		return nil, predicates.ErrInvalidPredicateParameters
+	}
+
+	return &formValuePredicate{maxBodySize: s.maxBodySize, name: name, value: value}, nil
+}
+
+// readBody returns the body of the request, reading at most maxBodySize
+// bytes of it, or nil when the request has no body or it is too large.
+func readBody(r *http.Request, maxBodySize int64) *skpio.Body {
+	b, err := skpio.ReadRequestBody(r, maxBodySize)
+	if err != nil {
+		return nil
+	}
+
+	return b
+}
+
+func jsonField(b *skpio.Body, path string) (gjson.Result, bool) {
+	j := b.Value(jsonKey{}, func(content []byte) any {
+		if !gjson.ValidBytes(content) {
+			return parsedJSON{}
+		}
+
+		return parsedJSON{json: gjson.ParseBytes(content), valid: true}
+	}).(parsedJSON)
+
+	if !j.valid {
+		return gjson.Result{}, false
+	}
+
+	v := j.json.Get(path)
+	return v, v.Exists()
+}
+
+func formValues(b *skpio.Body, r *http.Request) url.Values {
+	return b.Value(formKey{}, func(content []byte) any {
+		var form url.Values
+		if mt, _, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err == nil && mt == "application/x-www-form-urlencoded" {
+			form, _ = url.ParseQuery(string(content))
+		}
+
+		return form
+	}).(url.Values)
+}
+
+func (p *jsonBodyFieldPredicate) Match(r *http.Request) bool {
+	b := readBody(r, p.maxBodySize)
+	if b == nil {
+		return false
+	}
+
+	v, ok := jsonField(b, p.path)
+	if !ok {
+		return false
+	}
+
+	if p.regexp != nil {
+		return p.regexp.MatchString(v.String())
+	}
+
+	return v.String() == p.value
+}
+
+func (p *formValuePredicate) Match(r *http.Request) bool {
+	b := readBody(r, p.maxBodySize)
+	if b == nil {
+		return false
+	}
+
+	for _, v := range formValues(b, r)[p.name] {
+		if v == p.value {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,129 @@
+package content
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zalando/skipper/routing"
+)
+
+func TestBodyPredicatesCreate(t *testing.T) {
+	for _, spec := range []routing.PredicateSpec{
+		NewJSONBodyField(BodyOptions{}),
+		NewJSONBodyFieldRegexp(BodyOptions{}),
+		NewFormValue(BodyOptions{}),
+	} {
+		for _, args := range [][]interface{}{
+			nil,
+			{"foo"},
+			{"", "bar"},
+			{"foo", 42.0},
+			{"foo", "bar", "baz"},
+		} {
+			_, err := spec.Create(args)
+			assert.Error(t, err, "%s%v", spec.Name(), args)
+		}
+	}
+
+	_, err := NewJSONBodyFieldRegexp(BodyOptions{}).Create([]interface{}{"foo", "["})
+	assert.Error(t, err)
+}
+
+func TestBodyPredicatesMatch(t *testing.T) {
+	const graphQL = `{"operationName": "GetUser", "variables": {"id": 42}, "query": "query GetUser($id: ID!) { user(id: $id) { name } }"}`
+
+	for _, tc := range []struct {
+		name        string
+		spec        routing.PredicateSpec
+		args        []interface{}
+		contentType string
+		body        string
+		match       bool
+	}{
+		{"json field", NewJSONBodyField(BodyOptions{}), []interface{}{"operationName", "GetUser"}, "application/json", graphQL, true},
+		{"json field mismatch", NewJSONBodyField(BodyOptions{}), []interface{}{"operationName", "GetOrder"}, "application/json", graphQL, false},
+		{"json nested field", NewJSONBodyField(BodyOptions{}), []interface{}{"variables.id", "42"}, "application/json", graphQL, true},
+		{"json missing field", NewJSONBodyField(BodyOptions{}), []interface{}{"method", ""}, "application/json", graphQL, false},
+		{"json invalid", NewJSONBodyField(BodyOptions{}), []interface{}{"operationName", "GetUser"}, "application/json", `{"operationName": "GetUser"`, false},
+		{"json too large", NewJSONBodyField(BodyOptions{MaxBodySize: 16}), []interface{}{"operationName", "GetUser"}, "application/json", graphQL, false},
+		{"json empty", NewJSONBodyField(BodyOptions{}), []interface{}{"operationName", ""}, "application/json", "", false},
+		{"json regexp", NewJSONBodyFieldRegexp(BodyOptions{}), []interface{}{"operationName", "^Get"}, "application/json", graphQL, true},
+		{"json regexp mismatch", NewJSONBodyFieldRegexp(BodyOptions{}), []interface{}{"operationName", "^Set"}, "application/json", graphQL, false},
+		{"form value", NewFormValue(BodyOptions{}), []interface{}{"action", "login"}, "application/x-www-form-urlencoded", "user=foo&action=login", true},
+		{"form value charset", NewFormValue(BodyOptions{}), []interface{}{"action", "login"}, "application/x-www-form-urlencoded; charset=utf-8", "action=login", true},
+		{"form value multiple", NewFormValue(BodyOptions{}), []interface{}{"action", "logout"}, "application/x-www-form-urlencoded", "action=login&action=logout", true},
+		{"form value mismatch", NewFormValue(BodyOptions{}), []interface{}{"action", "logout"}, "application/x-www-form-urlencoded", "action=login", false},
+		{"form value content type", NewFormValue(BodyOptions{}), []interface{}{"action", "login"}, "text/plain", "action=login", false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			p, err := tc.spec.Create(tc.args)
+			require.NoError(t, err)
+
+			r := httptest.NewRequest("POST", "/", strings.NewReader(tc.body))
+			r.Header.Set("Content-Type", tc.contentType)
+			assert.Equal(t, tc.match, p.Match(r))
+
+			// the body is restored for the backend
+			b, err := io.ReadAll(r.Body)
+			require.NoError(t, err)
+			assert.Equal(t, tc.body, string(b))
+		})
+	}
+}
+
+type countingReader struct {
+	io.Reader
+	reads int
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	r.reads++
+	return r.Reader.Read(p)
+}
+
+func TestBodyPredicatesShareBody(t *testing.T) {
+	const body = `{"method": "user.get", "params": {"id": 42}}`
+
+	p1, err := NewJSONBodyField(BodyOptions{}).Create([]interface{}{"method", "user.set"})
+	require.NoError(t, err)
+
+	p2, err := NewJSONBodyFieldRegexp(BodyOptions{}).Create([]interface{}{"method", "^user[.]"})
+	require.NoError(t, err)
+
+	cr := &countingReader{Reader: strings.NewReader(body)}
+	r, err := http.NewRequest("POST", "/", io.NopCloser(cr))
+	require.NoError(t, err)
+	r.ContentLength = -1
+
+	assert.False(t, p1.Match(r))
+	reads := cr.reads
+	assert.True(t, p2.Match(r))
+	assert.Equal(t, reads, cr.reads)
+
+	b, err := io.ReadAll(r.Body)
+	require.NoError(t, err)
+	assert.Equal(t, body, string(b))
+}
+
+func TestBodyPredicatesLargeBodyRestored(t *testing.T) {
+	body := `{"method": "` + strings.Repeat("x", 100) + `"}`
+	p, err := NewJSONBodyFieldRegexp(BodyOptions{MaxBodySize: 32}).Create([]interface{}{"method", "x"})
+	require.NoError(t, err)
+
+	r, err := http.NewRequest("POST", "/", io.NopCloser(strings.NewReader(body)))
+	require.NoError(t, err)
+	r.ContentLength = -1
+
+	assert.False(t, p.Match(r))
+	assert.False(t, p.Match(r))
+
+	b, err := io.ReadAll(r.Body)
+	require.NoError(t, err)
+	assert.Equal(t, body, string(b))
+}
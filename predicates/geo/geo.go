@@ -0,0 +1,128 @@
+/*
+Package geo implements predicates to match routes based on the country and
+the autonomous system of the client IP, resolved from local MaxMind DB
+files.
+
+The client IP is resolved the same way as by the Source, SourceFromLast or
+ClientIP predicates, depending on the configuration of the geoip.Resolver.
+
+Examples:
+
+	// match requests from Germany and Austria
+	dach: GeoCountry("DE", "AT") -> "https://dach.example.org";
+
+	// block requests from an autonomous system
+	blocked: GeoASN(64496) -> status(403) -> <shunt>;
+*/
+package geo
+
+import (
+	"net/http"
+
+	"github.com/zalando/skipper/net/geoip"
+	"github.com/zalando/skipper/predicates"
+	"github.com/zalando/skipper/routing"
+)
+
+type (
+	countrySpec struct {
+		resolver *geoip.Resolver
+	}
+
+	asnSpec struct {
+		resolver *geoip.Resolver
+	}
+
+	countryPredicate struct {
+		resolver  *geoip.Resolver
+		countries map[string]struct{}
+	}
+
+	asnPredicate struct {
+		resolver *geoip.Resolver
+		asns     map[uint]struct{}
+	}
+)
+
+// NewCountry creates the GeoCountry predicate specification. The
+// predicate accepts one or more ISO 3166-1 country codes, and matches
+// when the client IP is located in one of the countries.
+func NewCountry(r *geoip.Resolver) routing.PredicateSpec { return &countrySpec{resolver: r} }
+
+// NewASN creates the GeoASN predicate specification. The predicate
+// accepts one or more autonomous system numbers, and matches when the
+// client IP belongs to one of them.
+func NewASN(r *geoip.Resolver) routing.PredicateSpec { return &asnSpec{resolver: r} }
+
+func (*countrySpec) Name() string { return predicates.GeoCountryName }
+
+func (s *countrySpec) Create(args []interface{}) (routing.Predicate, error) {
+	if len(args) == 0 {
+		return nil, predicates.ErrInvalidPredicateParameters
+	}
+
+	countries := make(map[string]struct{}, len(args))
+	for _, a := range args {
+		c, ok := a.(string)
+		if !ok || c == "" {
+			return nil, predicates.ErrInvalidPredicateParameters
+		}
+
+		countries[geoip.NormalizeCountry(c)] = struct{}{}
+	}
+
+	return &countryPredicate{resolver: s.resolver, countries: countries}, nil
+}
+
+func (p *countryPredicate) Match(r *http.Request) bool {
+	c := p.resolver.LookupRequest(r).Country
+	if c == "" {
+		return false
+	}
+
+	_, ok := p.countries[c]
+	return ok
+}
+
+func (*asnSpec) Name() string { return predicates.GeoASNName }
+
+func (s *asnSpec) Create(args []interface{}) (routing.Predicate, error) {
+	if len(args) == 0 {
+		return nil, predicates.ErrInvalidPredicateParameters
+	}
+
+	asns := make(map[uint]struct{}, len(args))
+	for _, a := range args {
+		var asn uint
+		switch v := a.(type) {
+		case float64:
+			if v <= 0 || v != float64(uint32(v)) {
+				return nil, predicates.ErrInvalidPredicateParameters
+			}
+
+			asn = uint(v)
+		case int:
+			if v <= 0 {
+				return nil, predicates.ErrInvalidPredicateParameters
+			}
+
+			asn = uint(v)
+		default:
+			return nil, predicates.ErrInvalidPredicateParameters
+		}
+
+		asns[asn] = struct{}{}
+	}
+
+	return &asnPredicate{resolver: s.resolver, asns: asns}, nil
+}
+
+func (p *asnPredicate) Match(r *http.Request) bool {
+	asn := p.resolver.LookupRequest(r).ASN
+	if asn == 0 {
+		return false
+	}
+
+	_, ok := p.asns[asn]
+	return ok
+}
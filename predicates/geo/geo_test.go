@@ -0,0 +1,82 @@
+package geo
+
+import (
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zalando/skipper/net/geoip"
+	"github.com/zalando/skipper/net/geoip/geoiptest"
+)
+
+func newTestResolver(t *testing.T) *geoip.Resolver {
+	db := filepath.Join(t.TempDir(), "test.mmdb")
+	require.NoError(t, geoiptest.Write(db, map[string]map[string]interface{}{
+		"1.2.3.0/24": {
+			"country":                  map[string]interface{}{"iso_code": "DE"},
+			"autonomous_system_number": uint(3320),
+		},
+		"5.6.7.0/24": {
+			"country": map[string]interface{}{"iso_code": "AT"},
+		},
+	}))
+
+	r, err := geoip.New(geoip.Options{Databases: []string{db}})
+	require.NoError(t, err)
+	t.Cleanup(r.Close)
+	return r
+}
+
+func TestGeoCountry(t *testing.T) {
+	r := newTestResolver(t)
+	spec := NewCountry(r)
+
+	for _, args := range [][]interface{}{nil, {42.0}, {""}} {
+		_, err := spec.Create(args)
+		assert.Error(t, err, "%v", args)
+	}
+
+	p, err := spec.Create([]interface{}{"de", "CH"})
+	require.NoError(t, err)
+
+	for _, tt := range []struct {
+		xff    string
+		expect bool
+	}{
+		{"1.2.3.4", true},
+		{"5.6.7.8", false},
+		{"9.9.9.9", false},
+		{"", false},
+	} {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = "192.0.2.1:1234"
+		if tt.xff != "" {
+			req.Header.Set("X-Forwarded-For", tt.xff)
+		}
+
+		assert.Equal(t, tt.expect, p.Match(req), tt.xff)
+	}
+}
+
+func TestGeoASN(t *testing.T) {
+	r := newTestResolver(t)
+	spec := NewASN(r)
+
+	for _, args := range [][]interface{}{nil, {"3320"}, {1.5}, {-1.0}} {
+		_, err := spec.Create(args)
+		assert.Error(t, err, "%v", args)
+	}
+
+	p, err := spec.Create([]interface{}{3320.0, 64496})
+	require.NoError(t, err)
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-Forwarded-For", "1.2.3.4")
+	assert.True(t, p.Match(req))
+
+	req.Header.Set("X-Forwarded-For", "5.6.7.8")
+	assert.False(t, p.Match(req))
+}
@@ -0,0 +1,136 @@
+/*
+Package graphql implements predicates to match routes based on the
+operations of GraphQL requests.
+
+The operation is parsed from the URL parameters of GET requests, or from
+the body of POST requests. The parsed request is cached, so the predicates
+of several routes and the graphql filters parse it only once.
+
+Examples:
+
+	// route mutations to the primary backend
+	mutations: Path("/graphql") && GraphQLOperationType("mutation") -> "https://primary.example.org";
+
+	// route expensive operations to a dedicated backend
+	reports: Path("/graphql") && GraphQLOperationName("MonthlyReport", "YearlyReport") -> "https://reports.example.org";
+*/
+package graphql
+
+import (
+	"net/http"
+
+	"github.com/zalando/skipper/graphql"
+	"github.com/zalando/skipper/predicates"
+	"github.com/zalando/skipper/routing"
+)
+
+type (
+	operationTypeSpec struct {
+		options graphql.Options
+	}
+
+	operationNameSpec struct {
+		options graphql.Options
+	}
+
+	operationTypePredicate struct {
+		options graphql.Options
+		types   map[graphql.OperationType]struct{}
+	}
+
+	operationNamePredicate struct {
+		options graphql.Options
+		names   map[string]struct{}
+	}
+)
+
+// NewOperationType creates the GraphQLOperationType predicate
+// specification. The predicate accepts one or more operation types, query,
+// mutation or subscription, and matches the GraphQL requests executing an
+// operation of one of the types.
+func NewOperationType(o graphql.Options) routing.PredicateSpec {
+	return &operationTypeSpec{options: o}
+}
+
+// NewOperationName creates the GraphQLOperationName predicate
+// specification. The predicate accepts one or more operation names, and
+// matches the GraphQL requests executing an operation with one of the
+// names.
+func NewOperationName(o graphql.Options) routing.PredicateSpec {
+	return &operationNameSpec{options: o}
+}
+
+func stringArgs(args []interface{}) ([]string, error) {
+	if len(args) == 0 {
+		return nil, predicates.ErrInvalidPredicateParameters
+	}
+
+	s := make([]string, len(args))
+	for i, a := range args {
+		v, ok := a.(string)
+		if !ok || v == "" {
+			return nil, predicates.ErrInvalidPredicateParameters
+		}
+
+		s[i] = v
+	}
+
+	return s, nil
+}
+
+func (*operationTypeSpec) Name() string { return predicates.GraphQLOperationTypeName }
+
+func (s *operationTypeSpec) Create(args []interface{}) (routing.Predicate, error) {
+	sargs, err := stringArgs(args)
+	if err != nil {
+		return nil, err
+	}
+
+	types := make(map[graphql.OperationType]struct{}, len(sargs))
+	for _, a := range sargs {
+		switch t := graphql.OperationType(a); t {
+		case graphql.Query, graphql.Mutation, graphql.Subscription:
+			types[t] = struct{}{}
+		default:
+			return nil, predicates.ErrInvalidPredicateParameters
+		}
+	}
+
+	return &operationTypePredicate{options: s.options, types: types}, nil
+}
+
+func (p *operationTypePredicate) Match(r *http.Request) bool {
+	req, err := graphql.FromHTTP(r, p.options)
+	if err != nil {
+		return false
+	}
+
+	_, ok := p.types[req.OperationType()]
+	return ok
+}
+
+func (*operationNameSpec) Name() string { return predicates.GraphQLOperationNameName }
+
+func (s *operationNameSpec) Create(args []interface{}) (routing.Predicate, error) {
+	sargs, err := stringArgs(args)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make(map[string]struct{}, len(sargs))
+	for _, a := range sargs {
+		names[a] = struct{}{}
+	}
+
+	return &operationNamePredicate{options: s.options, names: names}, nil
+}
+
+func (p *operationNamePredicate) Match(r *http.Request) bool {
+	req, err := graphql.FromHTTP(r, p.options)
+	if err != nil {
+		return false
+	}
+
+	_, ok := p.names[req.OperationName]
+	return ok
+}
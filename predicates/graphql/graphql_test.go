@@ -0,0 +1,60 @@
+package graphql
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zalando/skipper/graphql"
+	"github.com/zalando/skipper/routing"
+)
+
+func TestCreate(t *testing.T) {
+	for _, spec := range []routing.PredicateSpec{NewOperationType(graphql.Options{}), NewOperationName(graphql.Options{})} {
+		for _, args := range [][]interface{}{nil, {""}, {42.0}, {"query", 42.0}} {
+			_, err := spec.Create(args)
+			assert.Error(t, err, "%s%v", spec.Name(), args)
+		}
+	}
+
+	_, err := NewOperationType(graphql.Options{}).Create([]interface{}{"fragment"})
+	assert.Error(t, err)
+}
+
+func TestMatch(t *testing.T) {
+	for _, tt := range []struct {
+		name  string
+		spec  routing.PredicateSpec
+		args  []interface{}
+		query string
+		get   bool
+		match bool
+	}{
+		{"type", NewOperationType(graphql.Options{}), []interface{}{"mutation"}, `mutation DeleteUser { deleteUser }`, false, true},
+		{"type multiple", NewOperationType(graphql.Options{}), []interface{}{"query", "subscription"}, `subscription { events }`, false, true},
+		{"type mismatch", NewOperationType(graphql.Options{}), []interface{}{"mutation"}, `{ users }`, false, false},
+		{"type get", NewOperationType(graphql.Options{}), []interface{}{"query"}, `{ users }`, true, true},
+		{"name", NewOperationName(graphql.Options{}), []interface{}{"GetUser", "GetUsers"}, `query GetUsers { users }`, false, true},
+		{"name mismatch", NewOperationName(graphql.Options{}), []interface{}{"GetUser"}, `query GetUsers { users }`, false, false},
+		{"name anonymous", NewOperationName(graphql.Options{}), []interface{}{"GetUser"}, `{ users }`, false, false},
+		{"invalid", NewOperationType(graphql.Options{}), []interface{}{"query"}, `{ users `, false, false},
+		{"too large", NewOperationType(graphql.Options{MaxBodySize: 8}), []interface{}{"query"}, `query GetUsers { users }`, false, false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := tt.spec.Create(tt.args)
+			require.NoError(t, err)
+
+			req := httptest.NewRequest("POST", "/graphql", strings.NewReader(tt.query))
+			req.Header.Set("Content-Type", "application/graphql")
+			if tt.get {
+				req = httptest.NewRequest("GET", "/graphql?query="+url.QueryEscape(tt.query), nil)
+			}
+
+			assert.Equal(t, tt.match, p.Match(req))
+		})
+	}
+}
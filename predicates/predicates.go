@@ -28,6 +28,12 @@ const (
 	MethodsName               = "Methods"
 	HeaderName                = "Header"
 	HeaderRegexpName          = "HeaderRegexp"
+	HeaderPrefixName          = "HeaderPrefix"
+	HeaderSuffixName          = "HeaderSuffix"
+	HeaderContainsName        = "HeaderContains"
+	HeaderExistsName          = "HeaderExists"
+	HeaderAbsentName          = "HeaderAbsent"
+	HeaderInName              = "HeaderIn"
 	CookieName                = "Cookie"
 	JWTPayloadAnyKVName       = "JWTPayloadAnyKV"
 	JWTPayloadAllKVName       = "JWTPayloadAllKV"
@@ -46,4 +52,16 @@ const (
 	TrafficName               = "Traffic"
 	TrafficSegmentName        = "TrafficSegment"
 	ContentLengthBetweenName  = "ContentLengthBetween"
+	JSONBodyFieldName         = "JSONBodyField"
+	JSONBodyFieldRegexpName   = "JSONBodyFieldRegexp"
+	FormValueName             = "FormValue"
+	GraphQLOperationTypeName  = "GraphQLOperationType"
+	GraphQLOperationNameName  = "GraphQLOperationName"
+	GeoCountryName            = "GeoCountry"
+	GeoASNName                = "GeoASN"
+	ProxyProtocolTLVName      = "ProxyProtocolTLV"
+	AWSVPCEndpointIDName      = "AWSVPCEndpointID"
+	OrName                    = "Or"
+	AndName                   = "And"
+	NotName                   = "Not"
 )
@@ -24,6 +24,19 @@ func NewShutdown() routing.PredicateSpec {
 	return s
 }
 
+// NewShutdownNotify provides a predicate spec to create predicates
+// that evaluate to true after the done channel was closed, instead of
+// after SIGTERM.
+func NewShutdownNotify(done <-chan struct{}) routing.PredicateSpec {
+	s := &shutdown{}
+	go func() {
+		<-done
+		log.Infof("Shutdown started for %s predicates", s.Name())
+		atomic.StoreInt32(&s.inShutdown, 1)
+	}()
+	return s
+}
+
 func newShutdown() (routing.PredicateSpec, chan os.Signal) {
 	sigs := make(chan os.Signal, 1)
 	signal.Notify(sigs, syscall.SIGTERM)
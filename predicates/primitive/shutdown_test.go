@@ -26,3 +26,23 @@ func TestShutdown(t *testing.T) {
 		t.Error("expected shutdown")
 	}
 }
+
+func TestShutdownNotify(t *testing.T) {
+	done := make(chan struct{})
+	p, err := NewShutdownNotify(done).Create([]interface{}{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, _ := http.NewRequest("GET", "https://www.example.org", nil)
+
+	if p.Match(req) {
+		t.Error("unexpected shutdown")
+	}
+
+	close(done)
+	time.Sleep(100 * time.Millisecond)
+
+	if !p.Match(req) {
+		t.Error("expected shutdown")
+	}
+}
@@ -0,0 +1,134 @@
+/*
+Package proxyprotocol implements predicates to match routes based on the
+PROXY protocol header, received from a trusted load balancer on the
+connection of the request.
+
+The predicates don't match the requests received on connections without
+a PROXY protocol header.
+
+Examples:
+
+	// match requests received through an AWS VPC endpoint
+	vpce: AWSVPCEndpointID("vpce-0123456789abcdef0") -> "https://internal.example.org";
+
+	// match requests with a custom TLV set by the load balancer
+	tlv: ProxyProtocolTLV(224, "^tenant-a$") -> "https://tenant-a.example.org";
+*/
+package proxyprotocol
+
+import (
+	"net/http"
+	"regexp"
+
+	"github.com/zalando/skipper/net/proxyprotocol"
+	"github.com/zalando/skipper/predicates"
+	"github.com/zalando/skipper/routing"
+)
+
+type (
+	tlvSpec         struct{}
+	vpcEndpointSpec struct{}
+
+	tlvPredicate struct {
+		typ   byte
+		value *regexp.Regexp
+	}
+
+	vpcEndpointPredicate struct {
+		ids map[string]struct{}
+	}
+)
+
+// NewTLV creates the ProxyProtocolTLV predicate specification. The
+// predicate accepts the TLV type, from 0 to 255, and a regular
+// expression, and matches when the value of a TLV of that type matches
+// the expression.
+func NewTLV() routing.PredicateSpec { return tlvSpec{} }
+
+// NewAWSVPCEndpointID creates the AWSVPCEndpointID predicate
+// specification. The predicate accepts one or more VPC endpoint ids, and
+// matches when the connection was received through one of them.
+func NewAWSVPCEndpointID() routing.PredicateSpec { return vpcEndpointSpec{} }
+
+func (tlvSpec) Name() string { return predicates.ProxyProtocolTLVName }
+
+func (tlvSpec) Create(args []interface{}) (routing.Predicate, error) {
+	if len(args) != 2 {
+		return nil, predicates.ErrInvalidPredicateParameters
+	}
+
+	var typ byte
+	switch v := args[0].(type) {
+	case float64:
+		if v < 0 || v > 255 || v != float64(byte(v)) {
+			return nil, predicates.ErrInvalidPredicateParameters
+		}
+
+		typ = byte(v)
+	case int:
+		if v < 0 || v > 255 {
+			return nil, predicates.ErrInvalidPredicateParameters
+		}
+
+		typ = byte(v)
+	default:
+		return nil, predicates.ErrInvalidPredicateParameters
+	}
+
+	expr, ok := args[1].(string)
+	if !ok {
+		return nil, predicates.ErrInvalidPredicateParameters
+	}
+
+	rx, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	return &tlvPredicate{typ: typ, value: rx}, nil
+}
+
+func (p *tlvPredicate) Match(r *http.Request) bool {
+	h, ok := proxyprotocol.FromContext(r.Context())
+	if !ok {
+		return false
+	}
+
+	for _, t := range h.TLVs {
+		if t.Type == p.typ && p.value.Match(t.Value) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (vpcEndpointSpec) Name() string { return predicates.AWSVPCEndpointIDName }
+
+func (vpcEndpointSpec) Create(args []interface{}) (routing.Predicate, error) {
+	if len(args) == 0 {
+		return nil, predicates.ErrInvalidPredicateParameters
+	}
+
+	ids := make(map[string]struct{}, len(args))
+	for _, a := range args {
+		id, ok := a.(string)
+		if !ok || id == "" {
+			return nil, predicates.ErrInvalidPredicateParameters
+		}
+
+		ids[id] = struct{}{}
+	}
+
+	return &vpcEndpointPredicate{ids: ids}, nil
+}
+
+func (p *vpcEndpointPredicate) Match(r *http.Request) bool {
+	h, ok := proxyprotocol.FromContext(r.Context())
+	if !ok {
+		return false
+	}
+
+	_, ok = p.ids[h.AWSVPCEndpointID()]
+	return ok
+}
@@ -0,0 +1,80 @@
+package proxyprotocol
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zalando/skipper/net/proxyprotocol"
+	"github.com/zalando/skipper/predicates"
+	"github.com/zalando/skipper/routing"
+)
+
+func match(t *testing.T, spec routing.PredicateSpec, args []interface{}, h *proxyprotocol.Header) bool {
+	p, err := spec.Create(args)
+	require.NoError(t, err)
+
+	r := httptest.NewRequest("GET", "/", nil)
+	if h != nil {
+		r = r.WithContext(proxyprotocol.NewContext(r.Context(), h))
+	}
+
+	return p.Match(r)
+}
+
+func TestTLV(t *testing.T) {
+	spec := NewTLV()
+	assert.Equal(t, predicates.ProxyProtocolTLVName, spec.Name())
+
+	for _, args := range [][]interface{}{
+		nil,
+		{224.0},
+		{224.0, "foo", "bar"},
+		{256.0, "foo"},
+		{-1.0, "foo"},
+		{1.5, "foo"},
+		{"224", "foo"},
+		{224.0, 42.0},
+		{224.0, "("},
+	} {
+		_, err := spec.Create(args)
+		assert.Error(t, err, args)
+	}
+
+	h := &proxyprotocol.Header{
+		Version: 2,
+		TLVs: []proxyprotocol.TLV{
+			{Type: 0xE0, Value: []byte("tenant-a")},
+			{Type: 0xE0, Value: []byte("tenant-b")},
+		},
+	}
+
+	assert.True(t, match(t, spec, []interface{}{224.0, "^tenant-a$"}, h))
+	assert.True(t, match(t, spec, []interface{}{224, "^tenant-b$"}, h))
+	assert.False(t, match(t, spec, []interface{}{224.0, "^tenant-c$"}, h))
+	assert.False(t, match(t, spec, []interface{}{225.0, "tenant"}, h))
+	assert.False(t, match(t, spec, []interface{}{224.0, ""}, nil))
+}
+
+func TestAWSVPCEndpointID(t *testing.T) {
+	spec := NewAWSVPCEndpointID()
+	assert.Equal(t, predicates.AWSVPCEndpointIDName, spec.Name())
+
+	for _, args := range [][]interface{}{nil, {""}, {42.0}} {
+		_, err := spec.Create(args)
+		assert.Error(t, err, args)
+	}
+
+	h := &proxyprotocol.Header{
+		Version: 2,
+		TLVs:    []proxyprotocol.TLV{{Type: proxyprotocol.TypeAWS, Value: []byte("\x01vpce-1")}},
+	}
+
+	assert.True(t, match(t, spec, []interface{}{"vpce-1"}, h))
+	assert.True(t, match(t, spec, []interface{}{"vpce-2", "vpce-1"}, h))
+	assert.False(t, match(t, spec, []interface{}{"vpce-2"}, h))
+	assert.False(t, match(t, spec, []interface{}{"vpce-1"}, &proxyprotocol.Header{Version: 1}))
+	assert.False(t, match(t, spec, []interface{}{"vpce-1"}, nil))
+}
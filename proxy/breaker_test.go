@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"net/http"
 	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -11,6 +12,7 @@ import (
 	"github.com/zalando/skipper/eskip"
 	"github.com/zalando/skipper/filters"
 	"github.com/zalando/skipper/filters/builtin"
+	"github.com/zalando/skipper/metrics/metricstest"
 	"github.com/zalando/skipper/proxy"
 	"github.com/zalando/skipper/proxy/proxytest"
 )
@@ -615,3 +617,109 @@ func TestBreakerMultipleHostsAndRouteBasedSettings(t *testing.T) {
 		},
 	})
 }
+
+func TestBreakerPerEndpoint(t *testing.T) {
+	failing := newFailingBackend()
+	defer failing.close()
+	failing.fail()
+
+	healthy := newFailingBackend()
+	defer healthy.close()
+
+	registry := circuit.NewRegistry(circuit.BreakerSettings{
+		Type:        circuit.ConsecutiveFailures,
+		Failures:    testConsecutiveFailureCount,
+		Timeout:     time.Minute,
+		PerEndpoint: true,
+	})
+
+	p := proxytest.WithParams(builtin.MakeRegistry(), proxy.Params{
+		CloseIdleConnsPeriod: -1,
+		CircuitBreakers:      registry,
+	}, &eskip.Route{
+		Id:          "lb",
+		BackendType: eskip.LBBackend,
+		LBAlgorithm: "roundRobin",
+		LBEndpoints: []string{failing.url, healthy.url},
+	})
+	defer p.Close()
+
+	c := &breakerTestContext{
+		t:     t,
+		proxy: p,
+		backends: map[string]*failingBackend{
+			"failing": failing,
+			"healthy": healthy,
+		},
+	}
+
+	// the failing endpoint gets every other request until its breaker opens
+	for i := 0; i < 2*testConsecutiveFailureCount; i++ {
+		if _, err := proxyRequestHost(c, defaultHost); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	checkBackendHostCounter("failing", testConsecutiveFailureCount)(c)
+	checkBackendHostCounter("healthy", testConsecutiveFailureCount)(c)
+
+	// the open endpoint is excluded from load balancing
+	times(2*testConsecutiveFailureCount, request(200))(c)
+	checkBackendHostCounter("failing", 0)(c)
+	checkBackendHostCounter("healthy", 2*testConsecutiveFailureCount)(c)
+}
+
+type backendErrorMetrics struct {
+	*metricstest.MockMetrics
+	errors atomic.Int64
+}
+
+func (m *backendErrorMetrics) IncErrorsBackend(string) {
+	m.errors.Add(1)
+}
+
+func TestBreakerPerEndpointAllOpen(t *testing.T) {
+	failing := newFailingBackend()
+	defer failing.close()
+	failing.fail()
+
+	registry := circuit.NewRegistry(circuit.BreakerSettings{
+		Type:        circuit.ConsecutiveFailures,
+		Failures:    testConsecutiveFailureCount,
+		Timeout:     time.Minute,
+		PerEndpoint: true,
+	})
+
+	m := &backendErrorMetrics{MockMetrics: &metricstest.MockMetrics{}}
+	defer m.Close()
+
+	p := proxytest.WithParams(builtin.MakeRegistry(), proxy.Params{
+		CloseIdleConnsPeriod: -1,
+		CircuitBreakers:      registry,
+		Metrics:              m,
+	}, &eskip.Route{
+		Id:          "lb",
+		BackendType: eskip.LBBackend,
+		LBAlgorithm: "roundRobin",
+		LBEndpoints: []string{failing.url},
+	})
+	defer p.Close()
+
+	c := &breakerTestContext{
+		t:        t,
+		proxy:    p,
+		backends: map[string]*failingBackend{defaultHost: failing},
+	}
+
+	times(testConsecutiveFailureCount, request(500))(c)
+	checkBackendCounter(testConsecutiveFailureCount)(c)
+
+	// the rejections of the endpoint breaker are not backend errors
+	errorsBefore := m.errors.Load()
+	times(testConsecutiveFailureCount, requestOpen)(c)
+	checkBackendCounter(0)(c)
+
+	if errs := m.errors.Load(); errs != errorsBefore {
+		t.Errorf("unexpected backend errors for open breakers: %d", errs-errorsBefore)
+	}
+}
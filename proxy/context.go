@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"github.com/opentracing/opentracing-go"
+	"github.com/zalando/skipper/circuit"
 	"github.com/zalando/skipper/filters"
 	"github.com/zalando/skipper/metrics"
 	"github.com/zalando/skipper/routing"
@@ -34,6 +35,7 @@ type context struct {
 	deprecatedServed     bool
 	servedWithResponse   bool // to support the deprecated way independently
 	successfulUpgrade    bool
+	grpcBackendResponse  bool // the response was created by a gRPC backend
 	pathParams           map[string]string
 	stateBag             map[string]interface{}
 	originalRequest      *http.Request
@@ -50,9 +52,15 @@ type context struct {
 	proxy                *Proxy
 	routeLookup          *routing.RouteLookup
 	cancelBackendContext stdlibcontext.CancelFunc
+	stopBackendTimeout   func() bool
 	logger               filters.FilterContextLogger
 	proxyWatch           stopWatch
 	proxyRequestLatency  time.Duration
+
+	// set when the circuit breakers are scoped per endpoint of the
+	// load balanced backend
+	endpointBreakerSettings *circuit.BreakerSettings
+	endpointBreakerDone     func(bool)
 }
 
 type filterMetrics struct {
@@ -356,3 +364,12 @@ func (w noopFlushedResponseWriter) Write([]byte) (int, error) {
 func (w noopFlushedResponseWriter) WriteHeader(_ int)           {}
 func (w noopFlushedResponseWriter) Flush()                      {}
 func (w noopFlushedResponseWriter) Unwrap() http.ResponseWriter { return nil }
+
+// reportEndpointBreaker reports the outcome of the backend request to
+// the circuit breaker of the selected endpoint, if any.
+func (c *context) reportEndpointBreaker(success bool) {
+	if c.endpointBreakerDone != nil {
+		c.endpointBreakerDone(success)
+		c.endpointBreakerDone = nil
+	}
+}
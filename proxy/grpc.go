@@ -0,0 +1,70 @@
+package proxy
+
+import (
+	"net/http"
+
+	"github.com/zalando/skipper/grpc"
+	"github.com/zalando/skipper/metrics"
+)
+
+const grpcUnknownPlaceholder = "{unknown}"
+
+// grpcResponse returns the response to send to a gRPC client. When the
+// response is an HTTP error that was not created by a gRPC backend, it
+// returns a trailers-only gRPC response with the mapped grpc-status and
+// the grpc-message, otherwise the original response.
+func grpcResponse(req *http.Request, rsp *http.Response) *http.Response {
+	if !grpc.IsGRPC(req.Header) || rsp.StatusCode < http.StatusBadRequest || grpc.IsGRPC(rsp.Header) {
+		return rsp
+	}
+
+	h := http.Header{}
+	if server, ok := rsp.Header["Server"]; ok {
+		h["Server"] = server
+	}
+
+	h.Set("Content-Type", grpc.ContentType)
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     h,
+		Trailer:    grpc.ErrorHeader(rsp.StatusCode),
+		Body:       http.NoBody,
+	}
+}
+
+// measureGRPC measures the gRPC requests by service, method and status.
+// The service and the method are taken from the request path only when
+// the response was created by a gRPC backend, and the method is known by
+// it. The responses created by the proxy or the filters, and the unknown
+// methods, reported by the backends with the UNIMPLEMENTED status, are
+// measured with the {unknown} service and method, so that the clients
+// cannot raise the cardinality of the metrics.
+func measureGRPC(m metrics.Metrics, ctx *context, rsp *http.Response) {
+	req := ctx.request
+	if !grpc.IsGRPC(req.Header) {
+		return
+	}
+
+	status, ok := grpc.ResponseStatus(rsp)
+	if !ok {
+		status = grpc.Unknown
+	}
+
+	service, method, ok := grpc.Method(req.URL.Path)
+	if !ok || !ctx.grpcBackendResponse || status == grpc.Unimplemented {
+		service, method = grpcUnknownPlaceholder, grpcUnknownPlaceholder
+	}
+
+	prefix := "grpc." + service + "." + method + "."
+	m.IncCounter(prefix + "status." + status.String())
+	m.MeasureSince(prefix+"latency", ctx.startServe)
+}
+
+// copyTrailer sends the response trailers to the client, after the body
+// was copied.
+func copyTrailer(w http.ResponseWriter, trailer http.Header) {
+	h := w.Header()
+	for k, v := range trailer {
+		h[http.TrailerPrefix+k] = v
+	}
+}
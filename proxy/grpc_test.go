@@ -0,0 +1,163 @@
+package proxy_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zalando/skipper/eskip"
+	"github.com/zalando/skipper/filters/builtin"
+	"github.com/zalando/skipper/metrics/metricstest"
+	"github.com/zalando/skipper/proxy"
+	"github.com/zalando/skipper/proxy/proxytest"
+	"github.com/zalando/skipper/routing"
+)
+
+func newH2CBackend(t *testing.T, h http.HandlerFunc) *httptest.Server {
+	backend := httptest.NewUnstartedServer(h)
+	backend.Config.Protocols = new(http.Protocols)
+	backend.Config.Protocols.SetUnencryptedHTTP2(true)
+	backend.Start()
+	t.Cleanup(backend.Close)
+	return backend
+}
+
+func grpcRequest(t *testing.T, url string) *http.Response {
+	req, err := http.NewRequest("POST", url, strings.NewReader("\x00\x00\x00\x00\x00"))
+	require.NoError(t, err)
+
+	req.Header.Set("Content-Type", "application/grpc+proto")
+	req.Header.Set("Te", "trailers")
+	rsp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	t.Cleanup(func() { rsp.Body.Close() })
+	return rsp
+}
+
+func TestGRPCBackend(t *testing.T) {
+	backend := newH2CBackend(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.ProtoMajor != 2 || r.Header.Get("Te") != "trailers" {
+			w.WriteHeader(http.StatusHTTPVersionNotSupported)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/grpc")
+		w.Header().Set("Trailer", "Grpc-Status, Grpc-Message")
+		_, _ = w.Write([]byte("\x00\x00\x00\x00\x00"))
+		w.Header().Set("Grpc-Status", "5")
+		w.Header().Set("Grpc-Message", "user not found")
+	})
+
+	m := &metricstest.MockMetrics{}
+	p := proxytest.Config{
+		RoutingOptions: routing.Options{FilterRegistry: builtin.MakeRegistry()},
+		ProxyParams:    proxy.Params{Metrics: m},
+		Routes:         eskip.MustParse(`* -> "h2c://` + backend.Listener.Addr().String() + `"`),
+	}.Create()
+	defer p.Close()
+
+	rsp := grpcRequest(t, p.URL+"/users.v1.Users/GetUser")
+	assert.Equal(t, http.StatusOK, rsp.StatusCode)
+	assert.Equal(t, "application/grpc", rsp.Header.Get("Content-Type"))
+
+	_, err := io.ReadAll(rsp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "5", rsp.Trailer.Get("Grpc-Status"))
+	assert.Equal(t, "user not found", rsp.Trailer.Get("Grpc-Message"))
+
+	m.WithCounters(func(counters map[string]int64) {
+		assert.Equal(t, int64(1), counters["grpc.users.v1.Users.GetUser.status.NOT_FOUND"])
+	})
+	m.WithMeasures(func(measures map[string][]time.Duration) {
+		assert.Contains(t, measures, "grpc.users.v1.Users.GetUser.latency")
+	})
+}
+
+func TestGRPCErrors(t *testing.T) {
+	unimplemented := newH2CBackend(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/grpc")
+		w.Header().Set("Grpc-Status", "12")
+	})
+
+	for _, tt := range []struct {
+		name    string
+		route   string
+		status  string
+		message string
+		metric  string
+	}{{
+		name:    "connection refused",
+		route:   `* -> "h2c://127.0.0.1:1"`,
+		status:  "14",
+		message: "Bad Gateway",
+		metric:  "grpc.{unknown}.{unknown}.status.UNAVAILABLE",
+	}, {
+		name:    "filter",
+		route:   `* -> status(429) -> inlineContent("Too many requests") -> <shunt>`,
+		status:  "8",
+		message: "Too Many Requests",
+		metric:  "grpc.{unknown}.{unknown}.status.RESOURCE_EXHAUSTED",
+	}, {
+		name:    "route not found",
+		route:   `Path("/foo") -> <shunt>`,
+		status:  "12",
+		message: "Not Found",
+		metric:  "grpc.{unknown}.{unknown}.status.UNIMPLEMENTED",
+	}, {
+		name:   "unknown method",
+		route:  `* -> "h2c://` + unimplemented.Listener.Addr().String() + `"`,
+		status: "12",
+		metric: "grpc.{unknown}.{unknown}.status.UNIMPLEMENTED",
+	}} {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &metricstest.MockMetrics{}
+			p := proxytest.Config{
+				RoutingOptions: routing.Options{FilterRegistry: builtin.MakeRegistry()},
+				ProxyParams:    proxy.Params{Metrics: m},
+				Routes:         eskip.MustParse(tt.route),
+			}.Create()
+			defer p.Close()
+
+			rsp := grpcRequest(t, p.URL+"/users.v1.Users/GetUser")
+			assert.Equal(t, http.StatusOK, rsp.StatusCode)
+			assert.Equal(t, "application/grpc", rsp.Header.Get("Content-Type"))
+
+			b, err := io.ReadAll(rsp.Body)
+			require.NoError(t, err)
+			assert.Empty(t, b)
+
+			status := rsp.Trailer.Get("Grpc-Status")
+			if status == "" {
+				// trailers-only response
+				status = rsp.Header.Get("Grpc-Status")
+			}
+
+			assert.Equal(t, tt.status, status)
+			if tt.message != "" {
+				assert.Equal(t, tt.message, rsp.Trailer.Get("Grpc-Message"))
+			}
+
+			m.WithCounters(func(counters map[string]int64) {
+				assert.Equal(t, int64(1), counters[tt.metric])
+			})
+		})
+	}
+}
+
+func TestNonGRPCErrors(t *testing.T) {
+	p := proxytest.New(builtin.MakeRegistry(), eskip.MustParse(`* -> status(429) -> <shunt>`)...)
+	defer p.Close()
+
+	rsp, err := http.Post(p.URL, "application/grpc-web", nil)
+	require.NoError(t, err)
+	defer rsp.Body.Close()
+
+	assert.Equal(t, http.StatusTooManyRequests, rsp.StatusCode)
+	assert.Empty(t, rsp.Trailer.Get("Grpc-Status"))
+}
@@ -35,11 +35,13 @@ import (
 	filterslog "github.com/zalando/skipper/filters/log"
 	ratelimitfilters "github.com/zalando/skipper/filters/ratelimit"
 	tracingfilter "github.com/zalando/skipper/filters/tracing"
+	"github.com/zalando/skipper/grpc"
 	skpio "github.com/zalando/skipper/io"
 	"github.com/zalando/skipper/loadbalancer"
 	"github.com/zalando/skipper/logging"
 	"github.com/zalando/skipper/metrics"
 	snet "github.com/zalando/skipper/net"
+	"github.com/zalando/skipper/net/websocket"
 	"github.com/zalando/skipper/proxy/fastcgi"
 	"github.com/zalando/skipper/ratelimit"
 	"github.com/zalando/skipper/rfc"
@@ -273,6 +275,17 @@ type Params struct {
 	// and the response messages during web socket upgrades.
 	ExperimentalUpgradeAudit bool
 
+	// WebSocket relays the upgraded WebSocket connections whose frames
+	// are parsed, enforcing its limits. Defaults to a relay with the
+	// default options. The proxy closes the relay on Close.
+	WebSocket *websocket.Relay
+
+	// WebSocketFrameParser enables parsing the frames of all the
+	// upgraded WebSocket connections. When false, the frames are parsed
+	// only for the requests with message handlers, see
+	// filters.WebSocketMessageHandlers.
+	WebSocketFrameParser bool
+
 	// When set, no access log is printed.
 	AccessLogDisabled bool
 
@@ -320,6 +333,14 @@ type Params struct {
 	// The Flush interval for copying upgraded connections
 	FlushInterval time.Duration
 
+	// StreamingIdleTimeout is the default idle timeout between the
+	// events of the responses served in streaming mode, i.e. the
+	// Server-Sent Events and the responses of the routes with the
+	// streaming() filter. It replaces the backend and write timeouts of
+	// the streams. Disabled when zero, the streams keep the request
+	// timeouts then.
+	StreamingIdleTimeout time.Duration
+
 	// Timeout sets the TCP client connection timeout for proxy http connections to the backend
 	Timeout time.Duration
 
@@ -440,11 +461,15 @@ type Proxy struct {
 	fadein                   *fadeIn
 	heathlyEndpoints         *healthyEndpoints
 	roundTripper             http.RoundTripper
+	h2cRoundTripper          http.RoundTripper
 	priorityRoutes           []PriorityRoute
 	flags                    Flags
 	metrics                  metrics.Metrics
 	quit                     chan struct{}
 	flushInterval            time.Duration
+	streamingIdleTimeout     time.Duration
+	streams                  streams
+	upgrades                 upgrades
 	breakers                 *circuit.Registry
 	limiters                 *ratelimit.Registry
 	log                      logging.Logger
@@ -452,6 +477,8 @@ type Proxy struct {
 	upgradeAuditLogOut       io.Writer
 	upgradeAuditLogErr       io.Writer
 	auditLogHook             chan struct{}
+	webSocket                *websocket.Relay
+	parseWebSocket           bool
 	clientTLS                *tls.Config
 	hostname                 string
 	onPanicSometimes         rate.Sometimes
@@ -522,6 +549,20 @@ func cloneHeaderExcluding(h http.Header, excludeList map[string]bool) http.Heade
 	return hh
 }
 
+// teTrailers tells whether the TE header of the request contains the
+// trailers token.
+func teTrailers(h http.Header) bool {
+	for _, v := range h.Values("Te") {
+		for _, t := range strings.Split(v, ",") {
+			if t, _, _ := strings.Cut(t, ";"); strings.EqualFold(strings.TrimSpace(t), "trailers") {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
 type flusher struct {
 	w flushedResponseWriter
 }
@@ -582,6 +623,7 @@ func (p *Proxy) selectEndpoint(ctx *context) *routing.LBEndpoint {
 	endpoints := rt.LBEndpoints
 	endpoints = p.fadein.filterFadeIn(endpoints, rt)
 	endpoints = p.heathlyEndpoints.filterHealthyEndpoints(ctx, endpoints, p.metrics)
+	endpoints = p.filterOpenEndpoints(ctx, endpoints)
 
 	lbctx := &routing.LBContext{
 		Request:     ctx.request,
@@ -611,6 +653,9 @@ func (p *Proxy) mapRequest(ctx *context, requestContext stdlibcontext.Context) (
 		setRequestURLForDynamicBackend(u, stateBag)
 	case eskip.LBBackend:
 		endpoint := p.selectEndpoint(ctx)
+		if !p.checkEndpointBreaker(ctx, endpoint.Host) {
+			return nil, nil, errCircuitBreakerOpen
+		}
 		endpointMetrics = endpoint.Metrics
 		u.Scheme = endpoint.Scheme
 		u.Host = endpoint.Host
@@ -635,9 +680,18 @@ func (p *Proxy) mapRequest(ctx *context, requestContext stdlibcontext.Context) (
 	rr.ContentLength = r.ContentLength
 	if p.flags.HopHeadersRemoval() {
 		rr.Header = cloneHeaderExcluding(r.Header, hopHeaders)
+
+		// gRPC requires the TE header to signal the support of trailers
+		if teTrailers(r.Header) {
+			rr.Header.Set("Te", "trailers")
+		}
 	} else {
 		rr.Header = cloneHeader(r.Header)
 	}
+
+	// the request trailers are set by the server when the body was
+	// read, before the transport sends them
+	rr.Trailer = r.Trailer
 	// Disable default net/http user agent when user agent is not specified
 	if _, ok := rr.Header["User-Agent"]; !ok {
 		rr.Header["User-Agent"] = []string{""}
@@ -778,6 +832,12 @@ func WithParams(p Params) *Proxy {
 		Proxy:                 proxyFromContext,
 	}
 
+	// h2c backends are called with HTTP/2 over cleartext TCP, using
+	// prior knowledge
+	h2cTr := tr.Clone()
+	h2cTr.Protocols = new(http.Protocols)
+	h2cTr.Protocols.SetUnencryptedHTTP2(true)
+
 	quit := make(chan struct{})
 	// We need this to reliably fade on DNS change, which is right
 	// now not fixed with IdleConnTimeout in the http.Transport.
@@ -790,6 +850,7 @@ func WithParams(p Params) *Proxy {
 				select {
 				case <-ticker.C:
 					tr.CloseIdleConnections()
+					h2cTr.CloseIdleConnections()
 				case <-quit:
 					return
 				}
@@ -845,6 +906,11 @@ func WithParams(p Params) *Proxy {
 			maxUnhealthyEndpointsRatio: p.PassiveHealthCheck.MaxUnhealthyEndpointsRatio,
 		}
 	}
+	webSocket := p.WebSocket
+	if webSocket == nil {
+		webSocket = websocket.NewRelay(websocket.Options{Metrics: m})
+	}
+
 	return &Proxy{
 		routing:  p.Routing,
 		registry: p.EndpointRegistry,
@@ -853,11 +919,13 @@ func WithParams(p Params) *Proxy {
 		},
 		heathlyEndpoints:         healthyEndpointsChooser,
 		roundTripper:             p.CustomHttpRoundTripperWrap(tr),
+		h2cRoundTripper:          p.CustomHttpRoundTripperWrap(h2cTr),
 		priorityRoutes:           p.PriorityRoutes,
 		flags:                    p.Flags,
 		metrics:                  m,
 		quit:                     quit,
 		flushInterval:            p.FlushInterval,
+		streamingIdleTimeout:     p.StreamingIdleTimeout,
 		experimentalUpgrade:      p.ExperimentalUpgrade,
 		experimentalUpgradeAudit: p.ExperimentalUpgradeAudit,
 		maxLoops:                 p.MaxLoopbacks,
@@ -869,6 +937,8 @@ func WithParams(p Params) *Proxy {
 		accessLogDisabled:        p.AccessLogDisabled,
 		upgradeAuditLogOut:       os.Stdout,
 		upgradeAuditLogErr:       os.Stderr,
+		webSocket:                webSocket,
+		parseWebSocket:           p.WebSocketFrameParser,
 		clientTLS:                tr.TLSClientConfig,
 		hostname:                 hostname,
 		onPanicSometimes:         rate.Sometimes{First: 3, Interval: 1 * time.Minute},
@@ -959,6 +1029,16 @@ func (p *Proxy) makeUpgradeRequest(ctx *context, req *http.Request) {
 		auditLogOut:     p.upgradeAuditLogOut,
 		auditLogErr:     p.upgradeAuditLogErr,
 		auditLogHook:    p.auditLogHook,
+		routeID:         ctx.route.Id,
+		upgrades:        &p.upgrades,
+	}
+
+	if handlers, ok := ctx.StateBag()[filters.WebSocketMessageHandlers].([]websocket.MessageHandler); ok {
+		upgradeProxy.webSocketHandlers = handlers
+	}
+
+	if p.parseWebSocket || len(upgradeProxy.webSocketHandlers) > 0 {
+		upgradeProxy.webSocket = p.webSocket
 	}
 
 	upgradeProxy.serveHTTP(ctx.responseWriter, req)
@@ -970,7 +1050,9 @@ func (p *Proxy) makeBackendRequest(ctx *context, requestContext stdlibcontext.Co
 	payloadProtocol := getUpgradeRequest(ctx.Request())
 
 	req, endpointMetrics, err := p.mapRequest(ctx, requestContext)
-	if err != nil {
+	if err == errCircuitBreakerOpen {
+		return nil, errCircuitBreakerOpen
+	} else if err != nil {
 		return nil, &proxyError{err: fmt.Errorf("could not map backend request: %w", err)}
 	}
 
@@ -1053,7 +1135,9 @@ func (p *Proxy) makeBackendRequest(ctx *context, requestContext stdlibcontext.Co
 		// The roundtrip error `err` may be different:
 		// - for `Canceled` it could be either the same `context canceled` or `unexpected EOF` (net.OpError)
 		// - for `DeadlineExceeded` it is net.Error(timeout=true, temporary=true) wrapping this `context deadline exceeded`
-		if cerr := req.Context().Err(); cerr != nil {
+		if req.Context().Err() != nil {
+			// the backend timeout is stoppable, see withStoppableTimeout
+			cerr := stdlibcontext.Cause(req.Context())
 			ctx.proxySpan.LogKV("event", "error", "message", ensureUTF8(cerr.Error()))
 			if cerr == stdlibcontext.Canceled {
 				return nil, &proxyError{err: cerr, code: 499}
@@ -1124,6 +1208,9 @@ func (p *Proxy) getRoundTripper(ctx *context, req *http.Request) (http.RoundTrip
 		req.RemoteAddr = ctx.request.RemoteAddr
 
 		return rt, nil
+	case "h2c":
+		req.URL.Scheme = "http"
+		return p.h2cRoundTripper, nil
 	default:
 		return p.roundTripper, nil
 	}
@@ -1153,6 +1240,12 @@ func (p *Proxy) checkBreaker(c *context) (func(bool), bool) {
 	settings, _ := c.stateBag[circuitfilters.RouteSettingsKey].(circuit.BreakerSettings)
 	settings.Host = c.outgoingHost
 
+	if c.route.BackendType == eskip.LBBackend && p.breakers.PerEndpoint(settings) {
+		// checked for the selected endpoint during load balancing
+		c.endpointBreakerSettings = &settings
+		return nil, true
+	}
+
 	b := p.breakers.Get(settings)
 	if b == nil {
 		return nil, true
@@ -1166,6 +1259,58 @@ func (p *Proxy) checkBreaker(c *context) (func(bool), bool) {
 	return done, ok
 }
 
+// filterOpenEndpoints excludes the endpoints with open circuit breakers
+// from load balancing. When all endpoints are open, it returns all of
+// them, and the breaker of the selected one rejects the request.
+func (p *Proxy) filterOpenEndpoints(ctx *context, endpoints []routing.LBEndpoint) []routing.LBEndpoint {
+	if ctx.endpointBreakerSettings == nil {
+		return endpoints
+	}
+
+	host := ctx.endpointBreakerSettings.Host
+	filtered := make([]routing.LBEndpoint, 0, len(endpoints))
+	for _, e := range endpoints {
+		if p.breakers.EndpointOpen(host, e.Host) {
+			ctx.Logger().Debugf("Dropping endpoint %q due to open circuit breaker", e.Host)
+			continue
+		}
+
+		filtered = append(filtered, e)
+	}
+
+	if len(filtered) == 0 {
+		return endpoints
+	}
+
+	return filtered
+}
+
+func (p *Proxy) checkEndpointBreaker(ctx *context, endpoint string) bool {
+	if ctx.endpointBreakerSettings == nil {
+		return true
+	}
+
+	settings := *ctx.endpointBreakerSettings
+	settings.Endpoint = endpoint
+
+	b := p.breakers.Get(settings)
+	if b == nil {
+		return true
+	}
+
+	done, ok := b.Allow()
+	if !ok {
+		if ctx.request.Body != nil {
+			// consume the body to prevent goroutine leaks
+			io.Copy(io.Discard, ctx.request.Body)
+		}
+		return false
+	}
+
+	ctx.endpointBreakerDone = done
+	return true
+}
+
 func newRatelimitError(settings ratelimit.Settings, retryAfter int) *proxyError {
 	return &proxyError{
 		err:              errRatelimit,
@@ -1301,7 +1446,9 @@ func (p *Proxy) do(ctx *context, parentSpan ot.Span) (err error) {
 
 		backendContext := ctx.request.Context()
 		if timeout, ok := ctx.StateBag()[filters.BackendTimeout]; ok {
-			backendContext, ctx.cancelBackendContext = stdlibcontext.WithTimeout(backendContext, timeout.(time.Duration))
+			backendContext, ctx.cancelBackendContext, ctx.stopBackendTimeout = withStoppableTimeout(backendContext, timeout.(time.Duration))
+		} else {
+			backendContext, ctx.cancelBackendContext = stdlibcontext.WithCancel(backendContext)
 		}
 
 		backendStart := time.Now()
@@ -1312,10 +1459,19 @@ func (p *Proxy) do(ctx *context, parentSpan ot.Span) (err error) {
 			}
 		}
 		rsp, perr := p.makeBackendRequest(ctx, backendContext)
+		if perr == errCircuitBreakerOpen {
+			// rejected by the breaker of the selected endpoint
+			tracing.LogKV("circuit_breaker", "open", ctx.request.Context())
+			p.makeErrorResponse(ctx, perr)
+			p.applyFiltersOnError(ctx, processedFilters)
+			return perr
+		}
+
 		if perr != nil {
 			if done != nil {
 				done(false)
 			}
+			ctx.reportEndpointBreaker(false)
 
 			p.metrics.IncErrorsBackend(ctx.route.Id)
 
@@ -1330,11 +1486,17 @@ func (p *Proxy) do(ctx *context, parentSpan ot.Span) (err error) {
 				perr = nil
 				var perr2 *proxyError
 				rsp, perr2 = p.makeBackendRequest(ctx, backendContext)
-				if perr2 != nil {
+				if perr2 == errCircuitBreakerOpen {
+					tracing.LogKV("circuit_breaker", "open", ctx.request.Context())
+				} else if perr2 != nil {
+					ctx.reportEndpointBreaker(false)
 					ctx.Logger().Errorf("Failed to retry backend request: %v", perr2)
 					if perr2.code >= http.StatusInternalServerError {
 						p.metrics.MeasureBackend5xx(backendStart)
 					}
+				}
+
+				if perr2 != nil {
 					p.makeErrorResponse(ctx, perr2)
 					p.applyFiltersOnError(ctx, processedFilters)
 					return perr2
@@ -1353,8 +1515,10 @@ func (p *Proxy) do(ctx *context, parentSpan ot.Span) (err error) {
 		if done != nil {
 			done(rsp.StatusCode < http.StatusInternalServerError)
 		}
+		ctx.reportEndpointBreaker(rsp.StatusCode < http.StatusInternalServerError)
 
 		ctx.setResponse(rsp, p.flags.PreserveOriginal())
+		ctx.grpcBackendResponse = grpc.IsGRPC(rsp.Header)
 		p.metrics.MeasureBackend(ctx.route.Id, backendStart)
 		p.metrics.MeasureBackendHost(ctx.route.Host, backendStart)
 	}
@@ -1387,7 +1551,6 @@ func (p *Proxy) serveResponse(ctx *context) {
 
 	start := time.Now()
 	p.tracing.logStreamEvent(ctx.proxySpan, StreamHeadersEvent, StartEvent)
-	copyHeader(ctx.responseWriter.Header(), ctx.response.Header)
 
 	if err := ctx.Request().Context().Err(); err != nil {
 		// deadline exceeded or canceled in stdlib, client closed request
@@ -1399,13 +1562,34 @@ func (p *Proxy) serveResponse(ctx *context) {
 
 	p.tracing.setTag(ctx.initialSpan, HTTPStatusCodeTag, uint16(ctx.response.StatusCode))
 
-	ctx.responseWriter.WriteHeader(ctx.response.StatusCode)
+	rsp := grpcResponse(ctx.request, ctx.response)
+	copyHeader(ctx.responseWriter.Header(), rsp.Header)
+	if len(rsp.Trailer) > 0 {
+		// HTTP/1.1 clients receive the trailers only with chunked encoding
+		ctx.responseWriter.Header().Del("Content-Length")
+	}
+
+	idleTimeout, streaming := p.streamingMode(ctx)
+	if streaming && idleTimeout > 0 {
+		prepareStreaming(ctx)
+	}
+
+	ctx.responseWriter.WriteHeader(rsp.StatusCode)
 	ctx.responseWriter.Flush()
 	p.tracing.logStreamEvent(ctx.proxySpan, StreamHeadersEvent, EndEvent)
 
 	ctx.proxyWatch.Stop()
-	n, err := copyStream(ctx.responseWriter, ctx.response.Body)
+	var (
+		n   int64
+		err error
+	)
+	if streaming {
+		n, err = p.copyStreaming(ctx, rsp.Body, idleTimeout, isEventStream(ctx.responseWriter.Header().Get("Content-Type")))
+	} else {
+		n, err = copyStream(ctx.responseWriter, rsp.Body)
+	}
 	ctx.proxyWatch.Start()
+	copyTrailer(ctx.responseWriter, rsp.Trailer)
 
 	p.tracing.logStreamEvent(ctx.proxySpan, StreamBodyEvent, strconv.FormatInt(n, 10))
 	if err != nil {
@@ -1419,6 +1603,7 @@ func (p *Proxy) serveResponse(ctx *context) {
 		p.metrics.MeasureResponseSize(ctx.metricsHost(), n)
 	}
 	p.metrics.MeasureServe(ctx.route.Id, ctx.metricsHost(), ctx.request.Method, ctx.response.StatusCode, ctx.startServe)
+	measureGRPC(p.metrics, ctx, rsp)
 }
 
 func (p *Proxy) errorResponse(ctx *context, err error) {
@@ -1499,13 +1684,15 @@ func (p *Proxy) errorResponse(ctx *context, err error) {
 		)
 	}
 
-	copyHeader(ctx.responseWriter.Header(), ctx.response.Header)
-	ctx.responseWriter.WriteHeader(ctx.response.StatusCode)
+	rsp := grpcResponse(ctx.request, ctx.response)
+	copyHeader(ctx.responseWriter.Header(), rsp.Header)
+	ctx.responseWriter.WriteHeader(rsp.StatusCode)
 	ctx.responseWriter.Flush()
 
 	ctx.proxyWatch.Stop()
-	_, _ = copyStream(ctx.responseWriter, ctx.response.Body)
+	_, _ = copyStream(ctx.responseWriter, rsp.Body)
 	ctx.proxyWatch.Start()
+	copyTrailer(ctx.responseWriter, rsp.Trailer)
 
 	p.metrics.MeasureServe(
 		id,
@@ -1514,6 +1701,7 @@ func (p *Proxy) errorResponse(ctx *context, err error) {
 		ctx.response.StatusCode,
 		ctx.startServe,
 	)
+	measureGRPC(p.metrics, ctx, rsp)
 }
 
 // strip port from addresses with hostname, ipv4 or ipv6
@@ -1602,6 +1790,7 @@ func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		}
 
 		statusCode := lw.GetCode()
+		ctx.routeLookup.Report(statusCode)
 
 		if shouldLog(statusCode, accessLogEnabled) {
 			authUser, _ := ctx.stateBag[filterslog.AuthUserKey].(string)
@@ -1680,12 +1869,40 @@ func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// CloseStreams terminates the responses served in streaming mode, the
+// Server-Sent Events streams with a final shutdown event. The streams
+// started afterwards are terminated right after the response headers.
+// It is meant to be called on shutdown, before waiting for the active
+// requests.
+func (p *Proxy) CloseStreams() {
+	p.streams.close()
+}
+
+// UpgradedConns returns the number of the open upgraded connections
+// whose bytes are copied without parsing. The WebSocket connections
+// relayed with the frame parser are counted by the relay.
+func (p *Proxy) UpgradedConns() int {
+	return p.upgrades.len()
+}
+
+// CloseUpgrades closes the upgraded connections whose bytes are copied
+// without parsing. The connections upgraded afterwards are closed right
+// after the switching protocols response. It is meant to be called on
+// shutdown, like CloseStreams.
+func (p *Proxy) CloseUpgrades() {
+	p.upgrades.close()
+}
+
 // Close causes the proxy to stop closing idle
 // connections and, currently, has no other effect.
 // It's primary purpose is to support testing.
 func (p *Proxy) Close() error {
 	close(p.quit)
 	p.registry.Close()
+	if p.webSocket != nil {
+		p.webSocket.Close()
+	}
+
 	return nil
 }
 
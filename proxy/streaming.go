@@ -0,0 +1,218 @@
+package proxy
+
+import (
+	"bytes"
+	stdlibcontext "context"
+	"errors"
+	"io"
+	"mime"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/zalando/skipper/filters"
+)
+
+// sseShutdownEvent is sent to the clients of the Server-Sent Events
+// streams terminated on shutdown.
+const sseShutdownEvent = "event: shutdown\ndata: shutdown\n\n"
+
+var errStreamIdleTimeout = errors.New("stream idle timeout")
+
+// stream is a response served in streaming mode.
+type stream struct {
+	cancel      func()
+	idle        *time.Timer
+	idleExpired atomic.Bool
+	shutdown    atomic.Bool
+}
+
+// streams tracks the open streams, to terminate them on shutdown.
+type streams struct {
+	mu     sync.Mutex
+	open   map[*stream]struct{}
+	closed bool
+}
+
+// withStoppableTimeout is like context.WithTimeout, except that the
+// timeout can be stopped, e.g. when the response turns out to be a
+// stream. The cause of the timeout is context.DeadlineExceeded.
+func withStoppableTimeout(parent stdlibcontext.Context, d time.Duration) (stdlibcontext.Context, stdlibcontext.CancelFunc, func() bool) {
+	ctx, cancel := stdlibcontext.WithCancelCause(parent)
+	t := time.AfterFunc(d, func() { cancel(stdlibcontext.DeadlineExceeded) })
+	return ctx, func() {
+		t.Stop()
+		cancel(stdlibcontext.Canceled)
+	}, t.Stop
+}
+
+// isEventStream tells whether the content type is text/event-stream.
+func isEventStream(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err == nil && mediaType == "text/event-stream"
+}
+
+// streamingMode tells whether the response is served in streaming
+// mode, and returns its idle timeout. The streaming mode is set by the
+// streaming() filter, or detected from the Server-Sent Events content
+// type.
+func (p *Proxy) streamingMode(ctx *context) (time.Duration, bool) {
+	if d, ok := ctx.StateBag()[filters.Streaming].(time.Duration); ok {
+		if d <= 0 {
+			d = p.streamingIdleTimeout
+		}
+
+		return d, true
+	}
+
+	if isEventStream(ctx.response.Header.Get("Content-Type")) {
+		return p.streamingIdleTimeout, true
+	}
+
+	return 0, false
+}
+
+// prepareStreaming replaces the total duration timeouts of the request
+// with the idle timeout of the stream: it stops the backend timeout and
+// clears the read and write deadlines of the client connection. It is
+// called only when the idle timeout is set, otherwise the stream keeps
+// the timeouts of the request.
+func prepareStreaming(ctx *context) {
+	if ctx.stopBackendTimeout != nil {
+		ctx.stopBackendTimeout()
+	}
+
+	rc := ctx.ResponseController()
+	if err := rc.SetReadDeadline(time.Time{}); err != nil {
+		ctx.Logger().Debugf("Failed to clear the read deadline of the stream: %v", err)
+	}
+
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+		ctx.Logger().Debugf("Failed to clear the write deadline of the stream: %v", err)
+	}
+}
+
+// copyStreaming copies the body of a streaming response, flushing every
+// read immediately. When the idle timeout is set, the stream is
+// terminated when the backend doesn't send data, or the client doesn't
+// accept it, for the idle timeout. On shutdown, the Server-Sent Events
+// streams are terminated with a final event, when they are between two
+// events.
+func (p *Proxy) copyStreaming(ctx *context, body io.ReadCloser, idleTimeout time.Duration, sse bool) (int64, error) {
+	// canceling the backend request unblocks the reads of the body
+	s := &stream{cancel: ctx.cancelBackendContext}
+	if s.cancel == nil {
+		s.cancel = func() { body.Close() }
+	}
+
+	if idleTimeout > 0 {
+		s.idle = time.AfterFunc(idleTimeout, func() {
+			s.idleExpired.Store(true)
+			s.cancel()
+		})
+
+		defer s.idle.Stop()
+	}
+
+	if !p.streams.add(s) {
+		s.shutdown.Store(true)
+	}
+
+	defer p.streams.remove(s)
+
+	rc := ctx.ResponseController()
+	buf := make([]byte, proxyBufferSize)
+
+	var (
+		written int64
+		tail    []byte
+	)
+
+	for !s.shutdown.Load() {
+		n, rerr := body.Read(buf)
+		if n > 0 {
+			if s.idle != nil {
+				s.idle.Reset(idleTimeout)
+				if err := rc.SetWriteDeadline(time.Now().Add(idleTimeout)); err != nil {
+					ctx.Logger().Debugf("Failed to set the write deadline of the stream: %v", err)
+				}
+			}
+
+			w, err := ctx.responseWriter.Write(buf[:n])
+			written += int64(w)
+			if err != nil {
+				return written, err
+			}
+
+			ctx.responseWriter.Flush()
+			tail = append(tail, buf[:n]...)
+			if len(tail) > 4 {
+				tail = tail[len(tail)-4:]
+			}
+		}
+
+		if rerr != nil {
+			switch {
+			case s.shutdown.Load():
+			case s.idleExpired.Load():
+				return written, errStreamIdleTimeout
+			case rerr == io.EOF:
+				return written, nil
+			default:
+				return written, rerr
+			}
+		}
+	}
+
+	if sse && betweenEvents(tail) {
+		w, err := io.WriteString(ctx.responseWriter, sseShutdownEvent)
+		written += int64(w)
+		if err != nil {
+			return written, err
+		}
+
+		ctx.responseWriter.Flush()
+	}
+
+	return written, nil
+}
+
+// betweenEvents tells whether the tail of the written data ends with an
+// empty line, that terminates the Server-Sent Events.
+func betweenEvents(tail []byte) bool {
+	return len(tail) == 0 ||
+		bytes.HasSuffix(tail, []byte("\n\n")) ||
+		bytes.HasSuffix(tail, []byte("\n\r\n")) ||
+		bytes.HasSuffix(tail, []byte("\r\r"))
+}
+
+func (s *streams) add(st *stream) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return false
+	}
+
+	if s.open == nil {
+		s.open = make(map[*stream]struct{})
+	}
+
+	s.open[st] = struct{}{}
+	return true
+}
+
+func (s *streams) remove(st *stream) {
+	s.mu.Lock()
+	delete(s.open, st)
+	s.mu.Unlock()
+}
+
+func (s *streams) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	for st := range s.open {
+		st.shutdown.Store(true)
+		st.cancel()
+	}
+}
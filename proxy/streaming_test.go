@@ -0,0 +1,173 @@
+package proxy_test
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zalando/skipper/eskip"
+	"github.com/zalando/skipper/filters/builtin"
+	"github.com/zalando/skipper/proxy"
+	"github.com/zalando/skipper/routing"
+	"github.com/zalando/skipper/routing/testdataclient"
+)
+
+// newEventBackend sends the events with the delay between them, and
+// blocks after the last event until the client disconnects.
+func newEventBackend(t *testing.T, contentType string, events int, delay time.Duration) *httptest.Server {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", contentType)
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+
+		for i := range events {
+			if i > 0 {
+				time.Sleep(delay)
+			}
+
+			fmt.Fprintf(w, "data: %d\n\n", i)
+			w.(http.Flusher).Flush()
+		}
+
+		<-r.Context().Done()
+	}))
+
+	t.Cleanup(backend.Close)
+	return backend
+}
+
+func newStreamingProxy(t *testing.T, params proxy.Params, route string) (*proxy.Proxy, string) {
+	dc := testdataclient.New(eskip.MustParse(route))
+	rt := routing.New(routing.Options{
+		DataClients:     []routing.DataClient{dc},
+		FilterRegistry:  builtin.MakeRegistry(),
+		SignalFirstLoad: true,
+	})
+
+	<-rt.FirstLoad()
+	params.Routing = rt
+	p := proxy.WithParams(params)
+	ps := httptest.NewServer(p)
+
+	t.Cleanup(func() {
+		ps.Close()
+		p.Close()
+		rt.Close()
+		dc.Close()
+	})
+
+	return p, ps.URL
+}
+
+func readEvents(t *testing.T, body *bufio.Reader, n int) []string {
+	var events []string
+	for len(events) < n {
+		line, err := body.ReadString('\n')
+		require.NoError(t, err)
+		if line = strings.TrimSpace(line); line != "" {
+			events = append(events, line)
+		}
+	}
+
+	return events
+}
+
+func TestStreamingEventStreamDetected(t *testing.T) {
+	backend := newEventBackend(t, "text/event-stream; charset=utf-8", 4, 60*time.Millisecond)
+	_, url := newStreamingProxy(t, proxy.Params{StreamingIdleTimeout: time.Second}, fmt.Sprintf(`* -> backendTimeout("100ms") -> writeTimeout("100ms") -> %q`, backend.URL))
+
+	rsp, err := http.Get(url)
+	require.NoError(t, err)
+	defer rsp.Body.Close()
+
+	assert.Equal(t, http.StatusOK, rsp.StatusCode)
+	assert.Equal(t, []string{"data: 0", "data: 1", "data: 2", "data: 3"}, readEvents(t, bufio.NewReader(rsp.Body), 4))
+}
+
+func TestStreamingWithoutIdleTimeout(t *testing.T) {
+	backend := newEventBackend(t, "text/event-stream", 2, 150*time.Millisecond)
+	_, url := newStreamingProxy(t, proxy.Params{}, fmt.Sprintf(`* -> backendTimeout("100ms") -> %q`, backend.URL))
+
+	rsp, err := http.Get(url)
+	require.NoError(t, err)
+	defer rsp.Body.Close()
+
+	// the stream keeps the backend timeout
+	b, _ := io.ReadAll(rsp.Body)
+	assert.Equal(t, "data: 0\n\n", string(b))
+}
+
+func TestStreamingFilterIdleTimeout(t *testing.T) {
+	backend := newEventBackend(t, "application/octet-stream", 2, 30*time.Millisecond)
+	_, url := newStreamingProxy(t, proxy.Params{StreamingIdleTimeout: time.Hour}, fmt.Sprintf(`* -> streaming("100ms") -> %q`, backend.URL))
+
+	start := time.Now()
+	rsp, err := http.Get(url)
+	require.NoError(t, err)
+	defer rsp.Body.Close()
+
+	// the backend doesn't send anything after the second event
+	b, _ := io.ReadAll(rsp.Body)
+	assert.Equal(t, "data: 0\n\ndata: 1\n\n", string(b))
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+func TestStreamingDefaultIdleTimeout(t *testing.T) {
+	backend := newEventBackend(t, "text/event-stream", 1, 0)
+	_, url := newStreamingProxy(t, proxy.Params{StreamingIdleTimeout: 50 * time.Millisecond}, fmt.Sprintf(`* -> %q`, backend.URL))
+
+	rsp, err := http.Get(url)
+	require.NoError(t, err)
+	defer rsp.Body.Close()
+
+	b, _ := io.ReadAll(rsp.Body)
+	assert.Equal(t, "data: 0\n\n", string(b))
+}
+
+func TestStreamingShutdown(t *testing.T) {
+	backend := newEventBackend(t, "text/event-stream", 1, 0)
+	p, url := newStreamingProxy(t, proxy.Params{}, fmt.Sprintf(`* -> %q`, backend.URL))
+
+	rsp, err := http.Get(url)
+	require.NoError(t, err)
+	defer rsp.Body.Close()
+
+	body := bufio.NewReader(rsp.Body)
+	assert.Equal(t, []string{"data: 0"}, readEvents(t, body, 1))
+
+	p.CloseStreams()
+	rest, err := io.ReadAll(body)
+	require.NoError(t, err)
+	assert.Equal(t, "\nevent: shutdown\ndata: shutdown\n\n", string(rest))
+
+	// terminated after the headers
+	rsp, err = http.Get(url)
+	require.NoError(t, err)
+	defer rsp.Body.Close()
+
+	b, err := io.ReadAll(rsp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, rsp.StatusCode)
+	assert.Equal(t, "event: shutdown\ndata: shutdown\n\n", string(b))
+}
+
+func TestStreamingNotDetected(t *testing.T) {
+	backend := newEventBackend(t, "text/plain", 2, 150*time.Millisecond)
+	_, url := newStreamingProxy(t, proxy.Params{}, fmt.Sprintf(`* -> backendTimeout("100ms") -> %q`, backend.URL))
+
+	rsp, err := http.Get(url)
+	require.NoError(t, err)
+	defer rsp.Body.Close()
+
+	// the backend timeout terminates the response
+	b, _ := io.ReadAll(rsp.Body)
+	assert.Equal(t, "data: 0\n\n", string(b))
+}
@@ -13,8 +13,11 @@ import (
 	"net/http/httputil"
 	"net/url"
 	"strings"
+	"sync"
 
 	log "github.com/sirupsen/logrus"
+
+	"github.com/zalando/skipper/net/websocket"
 )
 
 // isUpgradeRequest returns true if and only if there is a "Connection"
@@ -48,6 +51,24 @@ type upgradeProxy struct {
 	auditLogOut     io.Writer
 	auditLogErr     io.Writer
 	auditLogHook    chan struct{}
+
+	// webSocket parses the frames of the upgraded WebSocket
+	// connections when set, instead of copying the bytes.
+	webSocket         *websocket.Relay
+	webSocketHandlers []websocket.MessageHandler
+	routeID           string
+
+	// upgrades tracks the connections copied without parsing, to close
+	// them on shutdown.
+	upgrades *upgrades
+}
+
+// upgrades tracks the open upgraded connections whose bytes are copied
+// between the client and the backend.
+type upgrades struct {
+	mu     sync.Mutex
+	open   map[net.Conn]net.Conn
+	closed bool
 }
 
 // TODO: add user here
@@ -107,7 +128,8 @@ func (p *upgradeProxy) serveHTTP(w http.ResponseWriter, req *http.Request) {
 		}
 	}
 
-	resp, err := http.ReadResponse(bufio.NewReader(backendConn), req)
+	backendReader := bufio.NewReader(backendConn)
+	resp, err := http.ReadResponse(backendReader, req)
 	if err != nil {
 		log.Errorf("Error reading response from backend: %s", err)
 		w.WriteHeader(http.StatusInternalServerError)
@@ -142,7 +164,7 @@ func (p *upgradeProxy) serveHTTP(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	requestHijackedConn, _, err := w.(http.Hijacker).Hijack()
+	requestHijackedConn, requestBuffer, err := w.(http.Hijacker).Hijack()
 	if err != nil {
 		log.Errorf("Error hijacking request connection: %s", err)
 		w.WriteHeader(http.StatusInternalServerError)
@@ -160,6 +182,27 @@ func (p *upgradeProxy) serveHTTP(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
+	if p.webSocket != nil && strings.EqualFold(resp.Header.Get("Upgrade"), "websocket") {
+		client := websocket.Endpoint{Conn: requestHijackedConn, Reader: requestBuffer.Reader}
+		if p.useAuditLog {
+			client.Writer = io.MultiWriter(p.auditLogOut, requestHijackedConn)
+		}
+
+		backend := websocket.Endpoint{Conn: backendConn, Reader: backendReader}
+
+		log.Debugf("Successfully upgraded to protocol %s by user request, parsing frames", getUpgradeRequest(req))
+		p.webSocket.Serve(p.routeID, client, backend, p.webSocketHandlers)
+		p.notifyAuditLog()
+		return
+	}
+
+	if !p.upgrades.add(requestHijackedConn, backendConn) {
+		log.Debugf("Closing upgraded connection to protocol %s on shutdown", getUpgradeRequest(req))
+		return
+	}
+
+	defer p.upgrades.remove(requestHijackedConn)
+
 	done := make(chan struct{}, 2)
 
 	if p.useAuditLog {
@@ -176,7 +219,50 @@ func (p *upgradeProxy) serveHTTP(w http.ResponseWriter, req *http.Request) {
 	// Return from this method closes both request and backend connections via defer
 	// and thus unblocks the second copyAsync.
 	<-done
+	p.notifyAuditLog()
+}
+
+func (u *upgrades) add(client, backend net.Conn) bool {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.closed {
+		return false
+	}
+
+	if u.open == nil {
+		u.open = make(map[net.Conn]net.Conn)
+	}
+
+	u.open[client] = backend
+	return true
+}
+
+func (u *upgrades) remove(client net.Conn) {
+	u.mu.Lock()
+	delete(u.open, client)
+	u.mu.Unlock()
+}
+
+func (u *upgrades) len() int {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return len(u.open)
+}
+
+// close closes both sides of the open connections. It doesn't wait for
+// the copying to finish, that returns right after the connections are
+// closed.
+func (u *upgrades) close() {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.closed = true
+	for client, backend := range u.open {
+		client.Close()
+		backend.Close()
+	}
+}
 
+func (p *upgradeProxy) notifyAuditLog() {
 	if p.useAuditLog {
 		select {
 		case p.auditLogHook <- struct{}{}:
@@ -327,6 +327,73 @@ func TestServeHTTP(t *testing.T) {
 	}
 }
 
+func TestCloseUpgrades(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Connection", "Upgrade")
+		w.Header().Set("Upgrade", "custom")
+		w.WriteHeader(http.StatusSwitchingProtocols)
+
+		conn, bufrw, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		defer conn.Close()
+		io.Copy(conn, bufrw)
+	}))
+	defer backend.Close()
+
+	tp, err := newTestProxyWithParams(fmt.Sprintf(`* -> %q`, backend.URL), Params{ExperimentalUpgrade: true})
+	require.NoError(t, err)
+	defer tp.close()
+
+	skipper := httptest.NewServer(tp.proxy)
+	defer skipper.Close()
+
+	upgrade := func() (net.Conn, *bufio.Reader) {
+		conn, err := net.Dial("tcp", skipper.Listener.Addr().String())
+		require.NoError(t, err)
+
+		req, err := http.NewRequest(http.MethodGet, skipper.URL, nil)
+		require.NoError(t, err)
+
+		req.Header.Set("Connection", "Upgrade")
+		req.Header.Set("Upgrade", "custom")
+		require.NoError(t, req.Write(conn))
+
+		reader := bufio.NewReader(conn)
+		rsp, err := http.ReadResponse(reader, req)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusSwitchingProtocols, rsp.StatusCode)
+		return conn, reader
+	}
+
+	conn, reader := upgrade()
+	defer conn.Close()
+
+	_, err = conn.Write([]byte("ping\n"))
+	require.NoError(t, err)
+
+	echo, err := reader.ReadString('\n')
+	require.NoError(t, err)
+	assert.Equal(t, "ping\n", echo)
+	assert.Equal(t, 1, tp.proxy.UpgradedConns())
+
+	tp.proxy.CloseUpgrades()
+	_, err = reader.ReadString('\n')
+	assert.ErrorIs(t, err, io.EOF)
+	assert.Eventually(t, func() bool { return tp.proxy.UpgradedConns() == 0 }, time.Second, 10*time.Millisecond)
+
+	// closed after the switching protocols response
+	conn, reader = upgrade()
+	defer conn.Close()
+
+	_, err = reader.ReadString('\n')
+	assert.ErrorIs(t, err, io.EOF)
+	assert.Equal(t, 0, tp.proxy.UpgradedConns())
+}
+
 func getReverseProxy(backendURL *url.URL) *httputil.ReverseProxy {
 	reverseProxy := httputil.NewSingleHostReverseProxy(backendURL)
 	reverseProxy.FlushInterval = 20 * time.Millisecond
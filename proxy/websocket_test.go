@@ -0,0 +1,124 @@
+package proxy_test
+
+import (
+	"bytes"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	xwebsocket "golang.org/x/net/websocket"
+
+	"github.com/zalando/skipper/eskip"
+	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/filters/builtin"
+	"github.com/zalando/skipper/metrics/metricstest"
+	"github.com/zalando/skipper/net/websocket"
+	"github.com/zalando/skipper/proxy"
+	"github.com/zalando/skipper/proxy/proxytest"
+	"github.com/zalando/skipper/routing"
+)
+
+type webSocketHandlerSpec struct{}
+
+type webSocketHandlerFilter struct{}
+
+func (webSocketHandlerSpec) Name() string { return "testWebSocketHandler" }
+
+func (webSocketHandlerSpec) CreateFilter([]any) (filters.Filter, error) {
+	return webSocketHandlerFilter{}, nil
+}
+
+func (webSocketHandlerFilter) Request(ctx filters.FilterContext) {
+	ctx.StateBag()[filters.WebSocketMessageHandlers] = []websocket.MessageHandler{
+		websocket.MessageHandlerFunc(func(m *websocket.Message) bool {
+			if string(m.Data) == "drop" {
+				return false
+			}
+
+			m.Data = bytes.ToUpper(m.Data)
+			return true
+		}),
+	}
+}
+
+func (webSocketHandlerFilter) Response(filters.FilterContext) {}
+
+func newWebSocketEchoProxy(t *testing.T, params proxy.Params, route string) *proxytest.TestProxy {
+	backend := httptest.NewServer(xwebsocket.Handler(func(ws *xwebsocket.Conn) {
+		io.Copy(ws, ws)
+	}))
+	t.Cleanup(backend.Close)
+
+	fr := builtin.MakeRegistry()
+	fr.Register(webSocketHandlerSpec{})
+
+	params.ExperimentalUpgrade = true
+	p := proxytest.Config{
+		RoutingOptions: routing.Options{FilterRegistry: fr},
+		ProxyParams:    params,
+		Routes:         eskip.MustParse(strings.ReplaceAll(route, "$backend", backend.URL)),
+	}.Create()
+	t.Cleanup(func() { p.Close() })
+
+	return p
+}
+
+func dialWebSocket(t *testing.T, p *proxytest.TestProxy) *xwebsocket.Conn {
+	ws, err := xwebsocket.Dial(strings.Replace(p.URL, "http:", "ws:", 1), "", "http://[::1]")
+	require.NoError(t, err)
+	t.Cleanup(func() { ws.Close() })
+
+	return ws
+}
+
+func receiveWebSocket(t *testing.T, ws *xwebsocket.Conn) string {
+	require.NoError(t, ws.SetReadDeadline(time.Now().Add(time.Second)))
+
+	var message string
+	require.NoError(t, xwebsocket.Message.Receive(ws, &message))
+	return message
+}
+
+func TestWebSocketRelay(t *testing.T) {
+	m := &metricstest.MockMetrics{}
+	relay := websocket.NewRelay(websocket.Options{Metrics: m})
+	p := newWebSocketEchoProxy(t, proxy.Params{WebSocket: relay, WebSocketFrameParser: true}, `* -> "$backend"`)
+
+	ws := dialWebSocket(t, p)
+	require.NoError(t, xwebsocket.Message.Send(ws, "hello"))
+	assert.Equal(t, "hello", receiveWebSocket(t, ws))
+
+	m.WithCounters(func(counters map[string]int64) {
+		assert.Equal(t, int64(1), counters["websocket.connections"])
+		assert.Equal(t, int64(1), counters["websocket.messages.client"])
+		assert.Equal(t, int64(len("hello")), counters["websocket.bytes.client"])
+	})
+
+	relay.Close()
+	var message string
+	assert.Error(t, xwebsocket.Message.Receive(ws, &message))
+
+	m.WithCounters(func(counters map[string]int64) {
+		assert.Equal(t, int64(1), counters["websocket.close.shutdown"])
+	})
+}
+
+func TestWebSocketMessageHandlers(t *testing.T) {
+	m := &metricstest.MockMetrics{}
+	p := newWebSocketEchoProxy(t, proxy.Params{Metrics: m}, `* -> testWebSocketHandler() -> "$backend"`)
+
+	ws := dialWebSocket(t, p)
+	require.NoError(t, xwebsocket.Message.Send(ws, "drop"))
+	require.NoError(t, xwebsocket.Message.Send(ws, "hello"))
+
+	// uppercased on the way to the backend and on the way back
+	assert.Equal(t, "HELLO", receiveWebSocket(t, ws))
+
+	m.WithCounters(func(counters map[string]int64) {
+		assert.Equal(t, int64(1), counters["websocket.messages.dropped"])
+	})
+}
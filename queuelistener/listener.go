@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"net"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/zalando/skipper/logging"
@@ -75,6 +76,11 @@ type Options struct {
 	// Log is used to log unexpected, non-fatal errors. It defaults to logging.DefaultLog.
 	Log logging.Logger
 
+	// Load, when set, is updated with the current queue size of the listener. It can
+	// be shared with request level components, e.g. to shed low priority requests
+	// first while the listener is queueing connections.
+	Load *Load
+
 	testQueueChangeHook chan struct{}
 }
 
@@ -93,6 +99,36 @@ type listener struct {
 	closedHook        chan struct{} // for testing
 }
 
+// Load reports the utilization of the connection queue of a listener.
+type Load struct {
+	queued       atomic.Int64
+	maxQueueSize atomic.Int64
+}
+
+// QueueUtilization returns the ratio of the queued connections to the
+// maximum queue size, within [0, 1]. It returns 0 for a nil Load.
+func (l *Load) QueueUtilization() float64 {
+	if l == nil {
+		return 0
+	}
+
+	max := l.maxQueueSize.Load()
+	if max <= 0 {
+		return 0
+	}
+
+	return float64(l.queued.Load()) / float64(max)
+}
+
+func (l *Load) update(queued, maxQueueSize int64) {
+	if l == nil {
+		return
+	}
+
+	l.queued.Store(queued)
+	l.maxQueueSize.Store(maxQueueSize)
+}
+
 var (
 	token             struct{}
 	errListenerClosed = errors.New("listener closed")
@@ -314,6 +350,8 @@ func (l *listener) listenInternal() {
 			l.options.Metrics.UpdateGauge(queuedConnectionsKey, float64(queue.size))
 		}
 
+		l.options.Load.update(int64(queue.size), l.maxQueueSize)
+
 		select {
 		case conn := <-l.acceptExternal:
 			cc := &connection{
@@ -1330,3 +1330,20 @@ func TestConnectionClose(t *testing.T) {
 		})
 	})
 }
+
+func TestLoad(t *testing.T) {
+	var nilLoad *Load
+	if u := nilLoad.QueueUtilization(); u != 0 {
+		t.Fatalf("expected zero utilization for nil load, got %f", u)
+	}
+
+	load := &Load{}
+	if u := load.QueueUtilization(); u != 0 {
+		t.Fatalf("expected zero utilization without queue size, got %f", u)
+	}
+
+	load.update(3, 4)
+	if u := load.QueueUtilization(); u != 0.75 {
+		t.Fatalf("expected utilization 0.75, got %f", u)
+	}
+}
@@ -66,6 +66,26 @@ func NewSwarmRegistry(swarm Swarmer, ro *net.RedisOptions, settings ...Settings)
 	return r
 }
 
+// SetSettings replaces the global settings of the registry, the same
+// way as they are passed to NewRegistry. Without settings, the global
+// ratelimit is disabled.
+func (r *Registry) SetSettings(settings ...Settings) {
+	global := Settings{
+		Type:          DisableRatelimit,
+		MaxHits:       DefaultMaxhits,
+		TimeWindow:    DefaultTimeWindow,
+		CleanInterval: DefaultCleanInterval,
+	}
+
+	if len(settings) > 0 {
+		global = settings[0]
+	}
+
+	r.Lock()
+	defer r.Unlock()
+	r.global = global
+}
+
 // Close teardown Registry and dependent resources
 func (r *Registry) Close() {
 	r.once.Do(func() {
@@ -105,7 +125,9 @@ func (r *Registry) Check(req *http.Request) (Settings, int) {
 		return Settings{}, 0
 	}
 
+	r.Lock()
 	s := r.global
+	r.Unlock()
 
 	rlimit := r.Get(s)
 
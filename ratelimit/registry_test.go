@@ -1,6 +1,7 @@
 package ratelimit
 
 import (
+	"net/http/httptest"
 	"testing"
 	"time"
 )
@@ -44,3 +45,24 @@ func TestRegistry(t *testing.T) {
 		checkNotNil(t, rl)
 	})
 }
+
+func TestRegistrySetSettings(t *testing.T) {
+	r := NewRegistry()
+	defer r.Close()
+
+	req := httptest.NewRequest("GET", "/", nil)
+	if _, retry := r.Check(req); retry != 0 {
+		t.Fatal("unexpected ratelimit without settings")
+	}
+
+	r.SetSettings(Settings{Type: ServiceRatelimit, MaxHits: 1, TimeWindow: time.Minute})
+	r.Check(req)
+	if _, retry := r.Check(req); retry == 0 {
+		t.Error("failed to ratelimit with the new settings")
+	}
+
+	r.SetSettings()
+	if _, retry := r.Check(req); retry != 0 {
+		t.Error("unexpected ratelimit after disabling")
+	}
+}
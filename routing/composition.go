@@ -0,0 +1,170 @@
+package routing
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+
+	"github.com/dimfeld/httppath"
+
+	"github.com/zalando/skipper/eskip"
+	"github.com/zalando/skipper/predicates"
+)
+
+// predicateFunc implements Predicate for the predicates evaluated inside
+// the Or, And and Not compositions.
+type predicateFunc func(*http.Request) bool
+
+type (
+	orPredicate  []Predicate
+	andPredicate []Predicate
+	notPredicate struct{ predicate Predicate }
+)
+
+func (f predicateFunc) Match(r *http.Request) bool { return f(r) }
+
+func (p orPredicate) Match(r *http.Request) bool {
+	for _, pi := range p {
+		if pi.Match(r) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (p andPredicate) Match(r *http.Request) bool {
+	return matchPredicates(p, r)
+}
+
+func (p notPredicate) Match(r *http.Request) bool {
+	return !p.predicate.Match(r)
+}
+
+// check if a predicate combines other predicates
+func isComposedPredicate(name string) bool {
+	switch name {
+	case predicates.OrName, predicates.AndName, predicates.NotName:
+		return true
+	default:
+		return false
+	}
+}
+
+// creates the nested predicates of a composition. The predicates built
+// into the lookup tree are evaluated per request, except for the Path and
+// PathSubtree predicates, that define the position of the route in the
+// tree, and Weight, that only affects the priority of the route. These are
+// rejected.
+func newNestedPredicate(cpm map[string]PredicateSpec, p *eskip.Predicate) (Predicate, error) {
+	switch p.Name {
+	case predicates.PathName, predicates.PathSubtreeName, predicates.WeightName:
+		return nil, fmt.Errorf("%w: %s predicate cannot be used inside a composition", errInvalidPredicateParams, p.Name)
+	case predicates.OrName, predicates.AndName, predicates.NotName:
+		return newComposedPredicate(cpm, p)
+	case predicates.HostName, predicates.PathRegexpName:
+		a, err := getFreeStringArgs(1, p)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", errInvalidPredicateParams, err)
+		}
+
+		rx, err := regexp.Compile(a[0])
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", errInvalidPredicateParams, err)
+		}
+
+		if p.Name == predicates.HostName {
+			return predicateFunc(func(r *http.Request) bool { return rx.MatchString(r.Host) }), nil
+		}
+
+		return predicateFunc(func(r *http.Request) bool { return rx.MatchString(httppath.Clean(r.URL.Path)) }), nil
+	case predicates.MethodName:
+		a, err := getFreeStringArgs(1, p)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", errInvalidPredicateParams, err)
+		}
+
+		return predicateFunc(func(r *http.Request) bool { return r.Method == a[0] }), nil
+	case predicates.HeaderName:
+		a, err := getFreeStringArgs(2, p)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", errInvalidPredicateParams, err)
+		}
+
+		key := http.CanonicalHeaderKey(a[0])
+		return predicateFunc(func(r *http.Request) bool {
+			return matchHeader(r.Header, key, func(v string) bool { return v == a[1] })
+		}), nil
+	case predicates.HeaderRegexpName:
+		a, err := getFreeStringArgs(2, p)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", errInvalidPredicateParams, err)
+		}
+
+		rx, err := regexp.Compile(a[1])
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", errInvalidPredicateParams, err)
+		}
+
+		key := http.CanonicalHeaderKey(a[0])
+		return predicateFunc(func(r *http.Request) bool {
+			return matchHeader(r.Header, key, rx.MatchString)
+		}), nil
+	}
+
+	if isHeaderPredicate(p.Name) {
+		c, err := newHeaderCondition(p)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", errInvalidPredicateParams, err)
+		}
+
+		return predicateFunc(func(r *http.Request) bool { return c.match(r.Header) }), nil
+	}
+
+	spec, ok := cpm[p.Name]
+	if !ok {
+		return nil, fmt.Errorf("%w: predicate %q not found", errUnknownPredicate, p.Name)
+	}
+
+	np, err := spec.Create(p.Args)
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to create predicate %q: %w", errInvalidPredicateParams, spec.Name(), err)
+	}
+
+	return np, nil
+}
+
+// creates a predicate from an Or, And or Not composition. The arguments of
+// the compositions are predicates, e.g. Or(Method("GET"), Not(Header("X-Foo", "bar"))).
+func newComposedPredicate(cpm map[string]PredicateSpec, p *eskip.Predicate) (Predicate, error) {
+	if len(p.Args) == 0 || p.Name == predicates.NotName && len(p.Args) != 1 {
+		expect := "at least 1"
+		if p.Name == predicates.NotName {
+			expect = "1"
+		}
+
+		return nil, fmt.Errorf("%w: invalid length of predicate args in %s, %d instead of %s", errInvalidPredicateParams, p.Name, len(p.Args), expect)
+	}
+
+	nested := make([]Predicate, len(p.Args))
+	for i, a := range p.Args {
+		np, ok := a.(*eskip.Predicate)
+		if !ok {
+			return nil, fmt.Errorf("%w: %s predicate expects predicate arguments, got: %v", errInvalidPredicateParams, p.Name, a)
+		}
+
+		var err error
+		if nested[i], err = newNestedPredicate(cpm, np); err != nil {
+			return nil, err
+		}
+	}
+
+	switch p.Name {
+	case predicates.OrName:
+		return orPredicate(nested), nil
+	case predicates.AndName:
+		return andPredicate(nested), nil
+	default:
+		return notPredicate{nested[0]}, nil
+	}
+}
@@ -0,0 +1,92 @@
+package routing_test
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zalando/skipper/predicates/query"
+	"github.com/zalando/skipper/routing"
+	"github.com/zalando/skipper/routing/testdataclient"
+)
+
+func TestComposedPredicates(t *testing.T) {
+	dc, err := testdataclient.NewDoc(`
+		or: Path("/or") && Or(Method("POST"), Header("X-Foo", "bar"), QueryParam("debug")) -> <shunt>;
+		not: Path("/not") && Not(HeaderRegexp("User-Agent", /bot/)) -> <shunt>;
+		and: Path("/and") && Or(And(Host(/^api[.]/), Method("PUT")), HeaderIn("X-Tenant", "foo", "bar")) -> <shunt>;
+		pathRegexp: PathSubtree("/rx") && Not(PathRegexp(/[.]json$/)) -> <shunt>;
+		catchAll: * -> <shunt>;
+	`)
+	require.NoError(t, err)
+	defer dc.Close()
+
+	tr, err := newTestRoutingWithPredicates([]routing.PredicateSpec{query.New()}, dc)
+	require.NoError(t, err)
+	defer tr.close()
+
+	for _, tt := range []struct {
+		method  string
+		url     string
+		headers map[string]string
+		expect  string
+	}{
+		{method: "POST", url: "/or", expect: "or"},
+		{method: "GET", url: "/or", headers: map[string]string{"X-Foo": "bar"}, expect: "or"},
+		{method: "GET", url: "/or?debug=1", expect: "or"},
+		{method: "GET", url: "/or", expect: "catchAll"},
+		{method: "GET", url: "/not", expect: "not"},
+		{method: "GET", url: "/not", headers: map[string]string{"User-Agent": "some-bot/1.0"}, expect: "catchAll"},
+		{method: "PUT", url: "https://api.example.org/and", expect: "and"},
+		{method: "GET", url: "https://api.example.org/and", expect: "catchAll"},
+		{method: "PUT", url: "https://www.example.org/and", expect: "catchAll"},
+		{method: "GET", url: "https://www.example.org/and", headers: map[string]string{"X-Tenant": "bar"}, expect: "and"},
+		{method: "GET", url: "/rx/foo", expect: "pathRegexp"},
+		{method: "GET", url: "/rx/foo.json", expect: "catchAll"},
+	} {
+		req := httptest.NewRequest(tt.method, tt.url, nil)
+		for k, v := range tt.headers {
+			req.Header.Set(k, v)
+		}
+
+		r, err := tr.checkRequest(req)
+		require.NoError(t, err)
+		assert.Equal(t, tt.expect, r.Id, "%s %s %v", tt.method, tt.url, tt.headers)
+	}
+}
+
+func TestComposedPredicatesInvalid(t *testing.T) {
+	dc, err := testdataclient.NewDoc(`
+		tree: Or(Path("/foo"), Method("GET")) -> <shunt>;
+		weight: Not(Weight(10)) -> <shunt>;
+		notArgs: Not(Method("GET"), Method("POST")) -> <shunt>;
+		noArgs: Or() -> <shunt>;
+		stringArgs: Or("foo") -> <shunt>;
+		unknown: Or(Unknown()) -> <shunt>;
+	`)
+	require.NoError(t, err)
+	defer dc.Close()
+
+	tr, err := newTestRouting(dc)
+	require.NoError(t, err)
+	defer tr.close()
+
+	invalid := getInvalidRoutes(t, tr, "")
+	require.Len(t, invalid, 6)
+
+	reasons := make(map[string]string)
+	for _, r := range invalid {
+		reasons[r.Route.Id] = r.Reason
+	}
+
+	assert.Equal(t, map[string]string{
+		"tree":       "invalid_predicate_params",
+		"weight":     "invalid_predicate_params",
+		"notArgs":    "invalid_predicate_params",
+		"noArgs":     "invalid_predicate_params",
+		"stringArgs": "invalid_predicate_params",
+		"unknown":    "unknown_predicate",
+	}, reasons)
+}
@@ -21,8 +21,12 @@ func NewContext(ctx context.Context) context.Context {
 // FromContext returns value from the routing context stored in ctx.
 // It returns value associated with the key or stores result of the defaultValue call.
 // defaultValue may be called multiple times but only one result will be used as a default value.
+// Without routing context, it returns the result of the defaultValue call.
 func FromContext[K comparable, V any](ctx context.Context, key K, defaultValue func() V) V {
-	m, _ := ctx.Value(routingContextKey).(*sync.Map)
+	m, ok := ctx.Value(routingContextKey).(*sync.Map)
+	if !ok {
+		return defaultValue()
+	}
 
 	// https://github.com/golang/go/issues/44159#issuecomment-780774977
 	val, ok := m.Load(key)
@@ -155,18 +155,22 @@ func applyIncoming(defs routeDefs, d *incomingData) routeDefs {
 }
 
 type mergedDefs struct {
-	routes  []*eskip.Route
-	clients map[DataClient]struct{}
+	routes      []*eskip.Route
+	clients     map[DataClient]struct{}
+	clientsById map[string]DataClient
+	source      DataClient
 }
 
 // merges the route definitions from multiple data clients by route id
 func mergeDefs(defsByClient map[DataClient]routeDefs) mergedDefs {
 	clients := make(map[DataClient]struct{}, len(defsByClient))
+	clientsById := make(map[string]DataClient)
 	mergeByID := make(routeDefs)
 	for c, defs := range defsByClient {
 		clients[c] = struct{}{}
 		for id, def := range defs {
 			mergeByID[id] = def
+			clientsById[id] = c
 		}
 	}
 
@@ -174,7 +178,7 @@ func mergeDefs(defsByClient map[DataClient]routeDefs) mergedDefs {
 	for _, def := range mergeByID {
 		all = append(all, def)
 	}
-	return mergedDefs{routes: all, clients: clients}
+	return mergedDefs{routes: all, clients: clients, clientsById: clientsById}
 }
 
 // receives the initial set of the route definitiosn and their
@@ -182,8 +186,9 @@ func mergeDefs(defsByClient map[DataClient]routeDefs) mergedDefs {
 // and sends the merged route definitions to the output channel.
 //
 // The active set of routes from last successful update are used until the
-// next successful update.
-func receiveRouteDefs(o Options, quit <-chan struct{}) <-chan mergedDefs {
+// next successful update. A signal on the reprocess channel sends the
+// current route definitions again.
+func receiveRouteDefs(o Options, reprocess <-chan struct{}, quit <-chan struct{}) <-chan mergedDefs {
 	in := make(chan *incomingData)
 	out := make(chan mergedDefs)
 	defsByClient := make(map[DataClient]routeDefs)
@@ -194,19 +199,31 @@ func receiveRouteDefs(o Options, quit <-chan struct{}) <-chan mergedDefs {
 
 	go func() {
 		for {
-			var incoming *incomingData
+			var (
+				incoming *incomingData
+				c        DataClient
+			)
+
 			select {
 			case incoming = <-in:
+			case <-reprocess:
 			case <-quit:
 				return
 			}
 
-			incoming.log(o.Log, o.SuppressLogs)
-			c := incoming.client
-			defsByClient[c] = applyIncoming(defsByClient[c], incoming)
+			if incoming != nil {
+				incoming.log(o.Log, o.SuppressLogs)
+				c = incoming.client
+				defsByClient[c] = applyIncoming(defsByClient[c], incoming)
+			} else if len(defsByClient) == 0 {
+				continue
+			}
+
+			mdefs := mergeDefs(defsByClient)
+			mdefs.source = c
 
 			select {
-			case out <- mergeDefs(defsByClient):
+			case out <- mdefs:
 			case <-quit:
 				return
 			}
@@ -232,7 +249,7 @@ func splitBackend(r *eskip.Route) (string, string, error) {
 func createFilter(o *Options, def *eskip.Filter, cpm map[string]PredicateSpec) (filters.Filter, error) {
 	spec, ok := o.FilterRegistry[def.Name]
 	if !ok {
-		if isTreePredicate(def.Name) || def.Name == predicates.HostName || def.Name == predicates.PathRegexpName || def.Name == predicates.MethodName || def.Name == predicates.HeaderName || def.Name == predicates.HeaderRegexpName {
+		if isTreePredicate(def.Name) || def.Name == predicates.HostName || def.Name == predicates.PathRegexpName || def.Name == predicates.MethodName || def.Name == predicates.HeaderName || def.Name == predicates.HeaderRegexpName || isHeaderPredicate(def.Name) {
 			return nil, fmt.Errorf("%w: trying to use %q as filter, but it is only available as predicate", errUnknownFilter, def.Name)
 		}
 
@@ -401,7 +418,17 @@ func processPredicates(o *Options, cpm map[string]PredicateSpec, defs []*eskip.P
 			continue
 		}
 
-		if isTreePredicate(def.Name) {
+		if isTreePredicate(def.Name) || isHeaderPredicate(def.Name) {
+			continue
+		}
+
+		if isComposedPredicate(def.Name) {
+			cp, err := newComposedPredicate(cpm, def)
+			if err != nil {
+				return nil, 0, err
+			}
+
+			cps = append(cps, cp)
 			continue
 		}
 
@@ -505,7 +532,12 @@ func processRouteDef(o *Options, cpm map[string]PredicateSpec, def *eskip.Route)
 		return nil, err
 	}
 
-	r := &Route{Route: *def, Scheme: scheme, Host: host, Predicates: cps, Filters: fs, weight: weight}
+	hcs, err := processHeaderPredicates(def.Predicates)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Route{Route: *def, Scheme: scheme, Host: host, Predicates: cps, Filters: fs, weight: weight, headerConditions: hcs}
 	if err := processTreePredicates(r, def.Predicates); err != nil {
 		return nil, err
 	}
@@ -524,7 +556,7 @@ func mapPredicates(cps []PredicateSpec) map[string]PredicateSpec {
 }
 
 // processes a set of route definitions for the routing table
-func processRouteDefs(o *Options, defs []*eskip.Route) (routes []*Route, invalidDefs []*eskip.Route) {
+func processRouteDefs(o *Options, defs []*eskip.Route) (routes []*Route, invalidDefs []*InvalidRoute) {
 	cpm := mapPredicates(o.Predicates)
 	reasonCounts := make(map[string]int)
 
@@ -533,16 +565,10 @@ func processRouteDefs(o *Options, defs []*eskip.Route) (routes []*Route, invalid
 		if err == nil {
 			routes = append(routes, route)
 		} else {
-			invalidDefs = append(invalidDefs, def)
 			o.Log.Errorf("failed to process route %s: %v", def.Id, err)
-
-			var defErr invalidDefinitionError
-			reason := "other"
-			if errors.As(err, &defErr) {
-				reason = defErr.Code()
-			}
-
-			reasonCounts[reason]++
+			invalid := newInvalidRoute(def, err)
+			invalidDefs = append(invalidDefs, invalid)
+			reasonCounts[invalid.Reason]++
 		}
 	}
 
@@ -559,9 +585,16 @@ type routeTable struct {
 	once          sync.Once
 	routes        []*Route // only used for closing
 	validRoutes   []*eskip.Route
-	invalidRoutes []*eskip.Route
+	invalidRoutes []*InvalidRoute
 	clients       map[DataClient]struct{}
+	source        DataClient
 	created       time.Time
+
+	// the preprocessed definitions, used to create the generation again
+	// when it is promoted from a staged rollout
+	defs    []*eskip.Route
+	merged  mergedDefs
+	rollout bool
 }
 
 // close routeTable will cleanup all underlying resources, that could
@@ -578,15 +611,111 @@ func (rt *routeTable) close() {
 	})
 }
 
+// tableBuilder creates the route tables from the preprocessed route
+// definitions. Besides the goroutine receiving the updates, it is used
+// to create the promoted generations of the staged rollout, and so it
+// serializes the calls to the post-processors.
+type tableBuilder struct {
+	o            *Options
+	mu           sync.Mutex
+	invalidStats invalidRouteMetrics
+	rolledBack   rolledBackRoutes
+}
+
+func newTableBuilder(o *Options) *tableBuilder {
+	return &tableBuilder{
+		o:            o,
+		invalidStats: make(invalidRouteMetrics),
+		rolledBack:   make(rolledBackRoutes),
+	}
+}
+
+// build creates a route table. For a staged rollout, the routes are
+// processed with DoRollout of the post-processors implementing it.
+func (b *tableBuilder) build(id int, defs []*eskip.Route, mdefs mergedDefs, start time.Time, rollout bool) *routeTable {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	o := b.o
+	routes, invalidRoutes := processRouteDefs(o, defs)
+
+	for _, pp := range o.PostProcessors {
+		if rpp, ok := pp.(RolloutPostProcessor); ok && rollout {
+			routes = rpp.DoRollout(routes)
+		} else {
+			routes = pp.Do(routes)
+		}
+	}
+
+	m, errs := newMatcher(routes, o.MatchingOptions)
+
+	invalidRouteErrs := make(map[string]error)
+	validRoutes := []*eskip.Route{}
+
+	for _, err := range errs {
+		o.Log.Error(err)
+		if _, found := invalidRouteErrs[err.ID]; !found {
+			invalidRouteErrs[err.ID] = err
+		}
+	}
+
+	if o.Metrics != nil {
+		o.Metrics.UpdateInvalidRoute(map[string]int{errInvalidMatcher.Code(): len(errs)})
+	}
+
+	for i := range routes {
+		r := routes[i]
+		if err, found := invalidRouteErrs[r.Id]; found {
+			invalidRoutes = append(invalidRoutes, &InvalidRoute{
+				Route:  &r.Route,
+				Reason: errInvalidMatcher.Code(),
+				Error:  err.Error(),
+			})
+		} else {
+			validRoutes = append(validRoutes, &r.Route)
+		}
+	}
+
+	sort.SliceStable(validRoutes, func(i, j int) bool {
+		return validRoutes[i].Id < validRoutes[j].Id
+	})
+
+	setInvalidRouteSources(invalidRoutes, mdefs.clientsById)
+	b.invalidStats.update(o, invalidRoutes)
+
+	return &routeTable{
+		id:            id,
+		m:             m,
+		routes:        routes,
+		validRoutes:   validRoutes,
+		invalidRoutes: invalidRoutes,
+		clients:       mdefs.clients,
+		source:        mdefs.source,
+		created:       start,
+		defs:          defs,
+		merged:        mdefs,
+		rollout:       rollout,
+	}
+}
+
+// promote creates the route table of a generation under rollout again,
+// processed with all the post-processors.
+func (b *tableBuilder) promote(canary *routeTable) *routeTable {
+	return b.build(canary.id, canary.defs, canary.merged, canary.created, false)
+}
+
 // receives the next version of the routing table on the output channel,
-// when an update is received on one of the data clients.
-func receiveRouteMatcher(o Options, out chan<- *routeTable, quit <-chan struct{}) {
-	updates := receiveRouteDefs(o, quit)
+// when an update is received on one of the data clients. When the staged
+// rollout is enabled, the tables following the initial load of all the
+// data clients are created for the rollout.
+func receiveRouteMatcher(o Options, b *tableBuilder, out chan<- *routeTable, reprocess <-chan struct{}, quit <-chan struct{}) {
+	updates := receiveRouteDefs(o, reprocess, quit)
 	var (
 		rt           *routeTable
 		outRelay     chan<- *routeTable
 		updatesRelay <-chan mergedDefs
 		updateId     int
+		loaded       bool
 	)
 	updatesRelay = updates
 	for {
@@ -602,48 +731,12 @@ func receiveRouteMatcher(o Options, out chan<- *routeTable, quit <-chan struct{}
 				defs = o.PreProcessors[i].Do(defs)
 			}
 
-			routes, invalidRoutes := processRouteDefs(&o, defs)
-
-			for i := range o.PostProcessors {
-				routes = o.PostProcessors[i].Do(routes)
-			}
-
-			m, errs := newMatcher(routes, o.MatchingOptions)
-
-			invalidRouteIds := make(map[string]struct{})
-			validRoutes := []*eskip.Route{}
+			defs = b.skipRolledBack(defs)
 
-			for _, err := range errs {
-				o.Log.Error(err)
-				invalidRouteIds[err.ID] = struct{}{}
-			}
+			rollout := o.Rollout.enabled() && loaded
+			loaded = loaded || len(mdefs.clients) == len(o.DataClients)
 
-			if o.Metrics != nil {
-				o.Metrics.UpdateInvalidRoute(map[string]int{errInvalidMatcher.Code(): len(errs)})
-			}
-
-			for i := range routes {
-				r := routes[i]
-				if _, found := invalidRouteIds[r.Id]; found {
-					invalidRoutes = append(invalidRoutes, &r.Route)
-				} else {
-					validRoutes = append(validRoutes, &r.Route)
-				}
-			}
-
-			sort.SliceStable(validRoutes, func(i, j int) bool {
-				return validRoutes[i].Id < validRoutes[j].Id
-			})
-
-			rt = &routeTable{
-				id:            updateId,
-				m:             m,
-				routes:        routes,
-				validRoutes:   validRoutes,
-				invalidRoutes: invalidRoutes,
-				clients:       mdefs.clients,
-				created:       start,
-			}
+			rt = b.build(updateId, defs, mdefs, start, rollout)
 			updatesRelay = nil
 			outRelay = out
 		case outRelay <- rt:
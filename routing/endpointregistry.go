@@ -107,7 +107,7 @@ type EndpointRegistry struct {
 	data sync.Map // map[string]*entry
 }
 
-var _ PostProcessor = &EndpointRegistry{}
+var _ RolloutPostProcessor = &EndpointRegistry{}
 
 type RegistryOptions struct {
 	LastSeenTimeout               time.Duration
@@ -154,6 +154,21 @@ func (r *EndpointRegistry) Do(routes []*Route) []*Route {
 	return routes
 }
 
+// DoRollout implements RolloutPostProcessor. It sets the metrics of the
+// endpoints of the routes under staged rollout, without marking them as
+// detected or seen.
+func (r *EndpointRegistry) DoRollout(routes []*Route) []*Route {
+	for _, route := range routes {
+		if route.BackendType == eskip.LBBackend {
+			for i := range route.LBEndpoints {
+				route.LBEndpoints[i].Metrics = r.GetMetrics(route.LBEndpoints[i].Host)
+			}
+		}
+	}
+
+	return routes
+}
+
 func (r *EndpointRegistry) updateStats() {
 	ticker := time.NewTicker(r.statsResetPeriod)
 
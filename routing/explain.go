@@ -0,0 +1,337 @@
+package routing
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/dimfeld/httppath"
+	"github.com/zalando/skipper/eskip"
+	"github.com/zalando/skipper/predicates"
+)
+
+const (
+	explainPath           = "/routes/explain"
+	maxExplainRequestSize = 1 << 20
+)
+
+// The route tables that explain a request.
+const (
+	ExplainStableTable = "stable"
+	ExplainCanaryTable = "canary"
+)
+
+// The conditions that can reject a candidate route during explaining a
+// request.
+const (
+	RejectedByPath         = "path"
+	RejectedByMethod       = "method"
+	RejectedByHost         = "host"
+	RejectedByPathRegexp   = "pathRegexp"
+	RejectedByHeader       = "header"
+	RejectedByHeaderRegexp = "headerRegexp"
+	RejectedByPredicate    = "predicate"
+)
+
+// ExplainRequest describes a synthetic request to be explained by the
+// routing. The body is used by the predicates matching the request
+// body.
+type ExplainRequest struct {
+	Method   string      `json:"method,omitempty"`
+	URL      string      `json:"url"`
+	Header   http.Header `json:"header,omitempty"`
+	Body     string      `json:"body,omitempty"`
+	ClientIP string      `json:"clientIP,omitempty"`
+}
+
+// ExplainCandidate is a route considered during the lookup. When it
+// was not matched, RejectedBy tells which condition rejected it, and
+// Reason shows the condition.
+type ExplainCandidate struct {
+	RouteId    string `json:"routeId"`
+	Matched    bool   `json:"matched"`
+	RejectedBy string `json:"rejectedBy,omitempty"`
+	Reason     string `json:"reason,omitempty"`
+}
+
+// Explanation is the result of explaining a request. Candidates contain
+// the routes rejected by the path tree lookup, followed by the routes in
+// the order as they were considered. Table tells which route table
+// explained the request. During a staged rollout, the request is
+// explained by the stable table, and Canary contains the explanation by
+// the new generation under rollout.
+type Explanation struct {
+	Table      string             `json:"table,omitempty"`
+	RouteId    string             `json:"routeId,omitempty"`
+	Route      *eskip.Route       `json:"route,omitempty"`
+	Params     map[string]string  `json:"params,omitempty"`
+	Candidates []ExplainCandidate `json:"candidates"`
+	Canary     *Explanation       `json:"canary,omitempty"`
+}
+
+// Request creates the HTTP request described by the explain request. The
+// Host header overrides the host of the URL.
+func (er *ExplainRequest) Request() (*http.Request, error) {
+	method := er.Method
+	if method == "" {
+		method = "GET"
+	}
+
+	var body io.Reader
+	if er.Body != "" {
+		body = strings.NewReader(er.Body)
+	}
+
+	req, err := http.NewRequest(method, er.URL, body)
+	if err != nil {
+		return nil, err
+	}
+
+	for k, vs := range er.Header {
+		for _, v := range vs {
+			req.Header.Add(k, v)
+		}
+	}
+
+	if h := req.Header.Get("Host"); h != "" {
+		req.Host = h
+		req.Header.Del("Host")
+	}
+
+	if er.ClientIP != "" {
+		if net.ParseIP(er.ClientIP) == nil {
+			return nil, fmt.Errorf("invalid client IP: %q", er.ClientIP)
+		}
+
+		req.RemoteAddr = net.JoinHostPort(er.ClientIP, "0")
+	}
+
+	return req, nil
+}
+
+// explainRecorder records the candidate routes, and the conditions
+// that rejected them, while matching a request. Its methods are no-op
+// on a nil recorder, which is used when not explaining.
+type explainRecorder struct {
+	candidates []ExplainCandidate
+}
+
+func (x *explainRecorder) match(l *leafMatcher) {
+	if x == nil {
+		return
+	}
+
+	x.candidates = append(x.candidates, ExplainCandidate{RouteId: l.route.Id, Matched: true})
+}
+
+func (x *explainRecorder) reject(l *leafMatcher, rejectedBy, reason string) {
+	if x == nil {
+		return
+	}
+
+	x.candidates = append(x.candidates, ExplainCandidate{
+		RouteId:    l.route.Id,
+		RejectedBy: rejectedBy,
+		Reason:     reason,
+	})
+}
+
+func (x *explainRecorder) rejectRegexps(l *leafMatcher, rejectedBy string, rxs []*regexp.Regexp, s string) {
+	if x == nil {
+		return
+	}
+
+	for _, rx := range rxs {
+		if !rx.MatchString(s) {
+			x.reject(l, rejectedBy, rx.String())
+			return
+		}
+	}
+}
+
+func (x *explainRecorder) rejectHeaders(l *leafMatcher, h http.Header) {
+	if x == nil {
+		return
+	}
+
+	for k, v := range l.headersExact {
+		if !matchHeader(h, k, func(val string) bool { return val == v }) {
+			x.reject(l, RejectedByHeader, k+": "+v)
+			return
+		}
+	}
+
+	for k, rxs := range l.headersRegexp {
+		for _, rx := range rxs {
+			if !matchHeader(h, k, rx.MatchString) {
+				x.reject(l, RejectedByHeaderRegexp, k+": "+rx.String())
+				return
+			}
+		}
+	}
+}
+
+func (x *explainRecorder) rejectHeaderConditions(l *leafMatcher, h http.Header) {
+	if x == nil {
+		return
+	}
+
+	for _, c := range l.headerConditions {
+		if !c.match(h) {
+			x.reject(l, RejectedByHeader, c.String())
+			return
+		}
+	}
+}
+
+func (x *explainRecorder) rejectPredicate(l *leafMatcher, index int) {
+	if x == nil {
+		return
+	}
+
+	var name string
+	if names := customPredicateNames(l.route); index < len(names) {
+		name = names[index]
+	}
+
+	x.reject(l, RejectedByPredicate, name)
+}
+
+// rejectPaths records the routes with a path condition, that were not
+// considered, because the lookup in the path tree didn't reach them.
+func (x *explainRecorder) rejectPaths(routes []*Route) {
+	considered := make(map[string]bool)
+	for _, c := range x.candidates {
+		considered[c.RouteId] = true
+	}
+
+	var rejected []ExplainCandidate
+	for _, r := range routes {
+		if considered[r.Id] {
+			continue
+		}
+
+		var p *eskip.Predicate
+		switch {
+		case r.path != "":
+			p = &eskip.Predicate{Name: predicates.PathName, Args: []interface{}{r.path}}
+		case r.pathSubtree != "":
+			p = &eskip.Predicate{Name: predicates.PathSubtreeName, Args: []interface{}{r.pathSubtree}}
+		default:
+			continue
+		}
+
+		rejected = append(rejected, ExplainCandidate{
+			RouteId:    r.Id,
+			RejectedBy: RejectedByPath,
+			Reason:     p.String(),
+		})
+	}
+
+	x.candidates = append(rejected, x.candidates...)
+}
+
+// customPredicateNames returns the names of the custom predicates of a
+// route, in the same order as the processed predicates.
+func customPredicateNames(r *Route) []string {
+	var names []string
+	for _, p := range r.Route.Predicates {
+		if p.Name == predicates.WeightName || isTreePredicate(p.Name) || isHeaderPredicate(p.Name) {
+			continue
+		}
+
+		if isComposedPredicate(p.Name) {
+			names = append(names, p.String())
+			continue
+		}
+
+		names = append(names, p.Name)
+	}
+
+	return names
+}
+
+// explain is the equivalent of match, recording the candidate routes.
+// The routes are all the routes of the table, and those with a path
+// condition not reached in the path tree are reported as rejected by
+// the path.
+func (m *matcher) explain(r *http.Request, routes []*Route) *Explanation {
+	path := httppath.Clean(r.URL.Path)
+	exact := path
+	if m.matchingOptions.ignoreTrailingSlash() {
+		path = trimTrailingSlash(path)
+	}
+
+	x := &explainRecorder{}
+	lrm := &leafRequestMatcher{r: r, path: path, exactPath: exact, explain: x}
+	params, l := matchPathTree(m.paths, path, lrm)
+	if l == nil {
+		params = nil
+		l = matchLeaves(m.rootLeaves, r, path, exact, x)
+	}
+
+	x.rejectPaths(routes)
+
+	e := &Explanation{Params: params, Candidates: x.candidates}
+	if e.Candidates == nil {
+		e.Candidates = []ExplainCandidate{}
+	}
+
+	if l != nil {
+		e.RouteId = l.route.Id
+		e.Route = &l.route.Route
+	}
+
+	return e
+}
+
+// Explain looks up the route for the request, like Do, and returns the
+// selected route together with all the candidate routes that were
+// considered, and the conditions that rejected them.
+func (rl *RouteLookup) Explain(req *http.Request) *Explanation {
+	return rl.rt.m.explain(req, rl.rt.routes)
+}
+
+// serveExplain explains the synthetic request posted as JSON.
+func (r *Routing) serveExplain(w http.ResponseWriter, req *http.Request) {
+	if req.Method != "POST" {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	var er ExplainRequest
+	if err := json.NewDecoder(io.LimitReader(req.Body, maxExplainRequestSize)).Decode(&er); err != nil {
+		http.Error(w, "invalid explain request", http.StatusBadRequest)
+		return
+	}
+
+	hr, err := er.Request()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// not using Get, that returns the canary for a fraction of the calls
+	rt := r.routeTable.Load().(*routeTable)
+	e := rt.m.explain(hr, rt.routes)
+	e.Table = ExplainStableTable
+	if ro := r.rollout.Load(); ro != nil {
+		if hr, err = er.Request(); err == nil {
+			e.Canary = ro.canary.m.explain(hr, ro.canary.routes)
+			e.Canary.Table = ExplainCanaryTable
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(e); err != nil {
+		http.Error(
+			w,
+			http.StatusText(http.StatusInternalServerError),
+			http.StatusInternalServerError,
+		)
+	}
+}
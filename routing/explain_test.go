@@ -0,0 +1,180 @@
+package routing_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zalando/skipper/predicates/content"
+	"github.com/zalando/skipper/routing"
+	"github.com/zalando/skipper/routing/testdataclient"
+)
+
+const explainRoutes = `
+	byMethod: Path("/items/:id") && Method("POST") -> "https://post.example.org";
+	byHost: Path("/items/:id") && Host("^www[.]example[.]org$") -> "https://host.example.org";
+	byHeader: Path("/items/:id") && Header("X-Tenant", "foo") -> "https://header.example.org";
+	byHeaderIn: Path("/items/:id") && HeaderIn("X-Region", "eu", "us") -> "https://headerin.example.org";
+	byPredicate: Path("/items/:id") && CustomPredicate("bar") -> "https://predicate.example.org";
+	catchAll: Path("/items/:id") -> "https://catchall.example.org";
+	other: Path("/other") -> "https://other.example.org";
+	byBody: Path("/graphql") && JSONBodyField("operationName", "GetUser") -> "https://body.example.org";
+`
+
+func TestExplain(t *testing.T) {
+	dc, err := testdataclient.NewDoc(explainRoutes)
+	require.NoError(t, err)
+	defer dc.Close()
+
+	tr, err := newTestRoutingWithPredicates([]routing.PredicateSpec{
+		&predicate{},
+		content.NewJSONBodyField(content.BodyOptions{}),
+	}, dc)
+	require.NoError(t, err)
+	defer tr.close()
+
+	for _, tt := range []struct {
+		name       string
+		request    routing.ExplainRequest
+		expect     string
+		params     map[string]string
+		candidates []routing.ExplainCandidate
+	}{
+		{
+			name: "rejected by method, host, header and predicate",
+			request: routing.ExplainRequest{
+				URL: "https://api.example.org/items/42",
+			},
+			expect: "catchAll",
+			params: map[string]string{"id": "42"},
+			candidates: []routing.ExplainCandidate{
+				{RouteId: "other", RejectedBy: routing.RejectedByPath, Reason: `Path("/other")`},
+				{RouteId: "byMethod", RejectedBy: routing.RejectedByMethod, Reason: "POST"},
+				{RouteId: "byHost", RejectedBy: routing.RejectedByHost, Reason: "^www[.]example[.]org$"},
+				{RouteId: "byHeader", RejectedBy: routing.RejectedByHeader, Reason: "X-Tenant: foo"},
+				{RouteId: "byHeaderIn", RejectedBy: routing.RejectedByHeader, Reason: `HeaderIn("X-Region", "eu", "us")`},
+				{RouteId: "byPredicate", RejectedBy: routing.RejectedByPredicate, Reason: "CustomPredicate"},
+				{RouteId: "catchAll", Matched: true},
+			},
+		},
+		{
+			name: "host header",
+			request: routing.ExplainRequest{
+				URL:    "https://api.example.org/items/42",
+				Header: http.Header{"Host": []string{"www.example.org"}},
+			},
+			expect: "byHost",
+			params: map[string]string{"id": "42"},
+			candidates: []routing.ExplainCandidate{
+				{RouteId: "byHost", Matched: true},
+			},
+		},
+		{
+			name: "custom predicate",
+			request: routing.ExplainRequest{
+				URL:    "https://api.example.org/items/42",
+				Header: http.Header{"X-Custom-Predicate": []string{"bar"}},
+			},
+			expect: "byPredicate",
+			params: map[string]string{"id": "42"},
+		},
+		{
+			name: "body",
+			request: routing.ExplainRequest{
+				Method: "POST",
+				URL:    "https://api.example.org/graphql",
+				Header: http.Header{"Content-Type": []string{"application/json"}},
+				Body:   `{"operationName": "GetUser"}`,
+			},
+			expect: "byBody",
+		},
+		{
+			name: "body not matching",
+			request: routing.ExplainRequest{
+				Method: "POST",
+				URL:    "https://api.example.org/graphql",
+				Header: http.Header{"Content-Type": []string{"application/json"}},
+				Body:   `{"operationName": "GetOrder"}`,
+			},
+			candidates: []routing.ExplainCandidate{
+				{RouteId: "byBody", RejectedBy: routing.RejectedByPredicate, Reason: "JSONBodyField"},
+			},
+		},
+		{
+			name: "no match",
+			request: routing.ExplainRequest{
+				Method: "GET",
+				URL:    "https://api.example.org/none",
+			},
+			candidates: []routing.ExplainCandidate{
+				{RouteId: "catchAll", RejectedBy: routing.RejectedByPath, Reason: `Path("/items/:id")`},
+				{RouteId: "other", RejectedBy: routing.RejectedByPath, Reason: `Path("/other")`},
+			},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			body, err := json.Marshal(tt.request)
+			require.NoError(t, err)
+
+			w := httptest.NewRecorder()
+			tr.routing.ServeHTTP(w, httptest.NewRequest("POST", "/routes/explain", bytes.NewReader(body)))
+			require.Equal(t, http.StatusOK, w.Code)
+
+			var e routing.Explanation
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &e))
+
+			assert.Equal(t, routing.ExplainStableTable, e.Table)
+			assert.Nil(t, e.Canary)
+			assert.Equal(t, tt.expect, e.RouteId)
+			if tt.expect != "" {
+				require.NotNil(t, e.Route)
+				assert.Equal(t, tt.expect, e.Route.Id)
+				assert.Equal(t, tt.params, e.Params)
+			}
+
+			// the order of the routes with the same weight is not defined
+			for _, c := range tt.candidates {
+				assert.Contains(t, e.Candidates, c)
+			}
+
+			// the routes rejected in the path tree come first
+			require.NotEmpty(t, e.Candidates)
+			if tt.expect != "" {
+				assert.Equal(t, routing.ExplainCandidate{RouteId: tt.expect, Matched: true}, e.Candidates[len(e.Candidates)-1])
+			} else {
+				for _, c := range e.Candidates {
+					assert.False(t, c.Matched)
+				}
+			}
+		})
+	}
+}
+
+func TestExplainInvalidRequest(t *testing.T) {
+	dc, err := testdataclient.NewDoc(explainRoutes)
+	require.NoError(t, err)
+	defer dc.Close()
+
+	tr, err := newTestRoutingWithPredicates([]routing.PredicateSpec{&predicate{}}, dc)
+	require.NoError(t, err)
+	defer tr.close()
+
+	for _, tt := range []struct {
+		method string
+		body   string
+		status int
+	}{
+		{method: "GET", status: http.StatusMethodNotAllowed},
+		{method: "POST", body: "{", status: http.StatusBadRequest},
+		{method: "POST", body: `{"url": "https://www.example.org", "clientIP": "not-an-ip"}`, status: http.StatusBadRequest},
+	} {
+		w := httptest.NewRecorder()
+		tr.routing.ServeHTTP(w, httptest.NewRequest(tt.method, "/routes/explain", bytes.NewBufferString(tt.body)))
+		assert.Equal(t, tt.status, w.Code, "body: %s", tt.body)
+	}
+}
@@ -0,0 +1,237 @@
+package routing
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/zalando/skipper/eskip"
+	"github.com/zalando/skipper/predicates"
+)
+
+// header conditions compiled into the leaf matchers, in addition to
+// Header and HeaderRegexp
+type headerCondition struct {
+	name   string
+	key    string
+	value  string
+	values map[string]struct{}
+	args   []string
+}
+
+// check if a predicate is a header predicate compiled into the leaf
+// matchers
+func isHeaderPredicate(name string) bool {
+	switch name {
+	case predicates.HeaderPrefixName,
+		predicates.HeaderSuffixName,
+		predicates.HeaderContainsName,
+		predicates.HeaderExistsName,
+		predicates.HeaderAbsentName,
+		predicates.HeaderInName:
+		return true
+	default:
+		return false
+	}
+}
+
+func newHeaderCondition(p *eskip.Predicate) (*headerCondition, error) {
+	args, err := eskip.HeaderPredicateArgs(p)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &headerCondition{
+		name: p.Name,
+		key:  http.CanonicalHeaderKey(args[0]),
+		args: args,
+	}
+
+	switch p.Name {
+	case predicates.HeaderInName:
+		c.values = make(map[string]struct{}, len(args)-1)
+		for _, v := range args[1:] {
+			c.values[v] = struct{}{}
+		}
+	case predicates.HeaderPrefixName, predicates.HeaderSuffixName, predicates.HeaderContainsName:
+		c.value = args[1]
+	}
+
+	return c, nil
+}
+
+// processes the header predicates compiled into the leaf matchers
+func processHeaderPredicates(defs []*eskip.Predicate) ([]*headerCondition, error) {
+	var conditions []*headerCondition
+	for _, def := range defs {
+		if !isHeaderPredicate(def.Name) {
+			continue
+		}
+
+		c, err := newHeaderCondition(def)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", errInvalidPredicateParams, err)
+		}
+
+		conditions = append(conditions, c)
+	}
+
+	return conditions, nil
+}
+
+func (c *headerCondition) matchValue(v string) bool {
+	switch c.name {
+	case predicates.HeaderPrefixName:
+		return strings.HasPrefix(v, c.value)
+	case predicates.HeaderSuffixName:
+		return strings.HasSuffix(v, c.value)
+	case predicates.HeaderContainsName:
+		return strings.Contains(v, c.value)
+	case predicates.HeaderInName:
+		_, ok := c.values[v]
+		return ok
+	default:
+		return true
+	}
+}
+
+func (c *headerCondition) match(h http.Header) bool {
+	_, has := h[c.key]
+	switch c.name {
+	case predicates.HeaderExistsName:
+		return has
+	case predicates.HeaderAbsentName:
+		return !has
+	default:
+		return matchHeader(h, c.key, c.matchValue)
+	}
+}
+
+func (c *headerCondition) String() string {
+	args := make([]string, len(c.args))
+	for i, a := range c.args {
+		args[i] = fmt.Sprintf("%q", a)
+	}
+
+	return fmt.Sprintf("%s(%s)", c.name, strings.Join(args, ", "))
+}
+
+// matches a set of request headers to the header conditions
+func matchHeaderConditions(conditions []*headerCondition, h http.Header) bool {
+	for _, c := range conditions {
+		if !c.match(h) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// headerIndex selects the candidate leaves of a path by the value of
+// the header that most of them require with Header or HeaderIn, so that
+// the leaves requiring a different value are not evaluated. The leaves
+// are referenced by their position, to keep the priority order.
+type headerIndex struct {
+	key    string
+	values map[string][]int
+	rest   []int
+}
+
+// the minimum number of leaves requiring the same header, to index them
+const minIndexedLeaves = 2
+
+// returns the values that the leaf requires for the header, if any
+func indexedHeaderValues(l *leafMatcher, key string) []string {
+	if v, ok := l.headersExact[key]; ok {
+		return []string{v}
+	}
+
+	for _, c := range l.headerConditions {
+		if c.name == predicates.HeaderInName && c.key == key {
+			return c.args[1:]
+		}
+	}
+
+	return nil
+}
+
+// creates a header index for the sorted leaves, or returns nil, when
+// not enough leaves require the same header
+func newHeaderIndex(leaves leafMatchers) *headerIndex {
+	counts := make(map[string]int)
+	for _, l := range leaves {
+		keys := make(map[string]struct{})
+		for k := range l.headersExact {
+			keys[k] = struct{}{}
+		}
+
+		for _, c := range l.headerConditions {
+			if c.name == predicates.HeaderInName {
+				keys[c.key] = struct{}{}
+			}
+		}
+
+		for k := range keys {
+			counts[k]++
+		}
+	}
+
+	var key string
+	for k, n := range counts {
+		if n > counts[key] || n == counts[key] && k < key {
+			key = k
+		}
+	}
+
+	if counts[key] < minIndexedLeaves {
+		return nil
+	}
+
+	ix := &headerIndex{key: key, values: make(map[string][]int)}
+	for i, l := range leaves {
+		values := indexedHeaderValues(l, key)
+		if len(values) == 0 {
+			ix.rest = append(ix.rest, i)
+			continue
+		}
+
+		for _, v := range values {
+			if positions := ix.values[v]; len(positions) == 0 || positions[len(positions)-1] != i {
+				ix.values[v] = append(positions, i)
+			}
+		}
+	}
+
+	return ix
+}
+
+// matches a request to the candidate leaves, in the order of their
+// priority. With multiple values of the indexed header, it evaluates
+// all the leaves.
+func (ix *headerIndex) match(leaves leafMatchers, req *http.Request, path, exactPath string) *leafMatcher {
+	vals := req.Header[ix.key]
+	if len(vals) > 1 {
+		return matchLeaves(leaves, req, path, exactPath, nil)
+	}
+
+	var indexed []int
+	if len(vals) == 1 {
+		indexed = ix.values[vals[0]]
+	}
+
+	rest := ix.rest
+	for len(indexed) > 0 || len(rest) > 0 {
+		var next int
+		if len(rest) == 0 || len(indexed) > 0 && indexed[0] < rest[0] {
+			next, indexed = indexed[0], indexed[1:]
+		} else {
+			next, rest = rest[0], rest[1:]
+		}
+
+		if l := leaves[next]; matchLeaf(l, req, path, exactPath, nil) {
+			return l
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,89 @@
+package routing_test
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zalando/skipper/eskip"
+	"github.com/zalando/skipper/routing/testdataclient"
+)
+
+func TestHeaderPredicates(t *testing.T) {
+	dc, err := testdataclient.NewDoc(`
+		prefix: Path("/") && HeaderPrefix("Accept", "application/") -> <shunt>;
+		suffix: Path("/") && HeaderSuffix("X-Host", ".example.org") -> <shunt>;
+		contains: Path("/") && HeaderContains("User-Agent", "bot") -> <shunt>;
+		exists: Path("/") && HeaderExists("authorization") -> <shunt>;
+		absent: Path("/absent") && HeaderAbsent("Cookie") -> <shunt>;
+		in: Path("/") && HeaderIn("X-Tenant", "foo", "bar") -> <shunt>;
+		all: Path("/all") && HeaderPrefix("Accept", "text/") && HeaderIn("X-Tenant", "baz") -> <shunt>;
+		catchAll: * -> <shunt>;
+	`)
+	require.NoError(t, err)
+	defer dc.Close()
+
+	tr, err := newTestRouting(dc)
+	require.NoError(t, err)
+	defer tr.close()
+
+	for _, tt := range []struct {
+		path    string
+		headers map[string][]string
+		expect  string
+	}{
+		{path: "/", headers: map[string][]string{"Accept": {"text/html", "application/json"}}, expect: "prefix"},
+		{path: "/", headers: map[string][]string{"Accept": {"text/html"}}, expect: "catchAll"},
+		{path: "/", headers: map[string][]string{"X-Host": {"www.example.org"}}, expect: "suffix"},
+		{path: "/", headers: map[string][]string{"User-Agent": {"some-bot/1.0"}}, expect: "contains"},
+		{path: "/", headers: map[string][]string{"Authorization": {""}}, expect: "exists"},
+		{path: "/absent", expect: "absent"},
+		{path: "/absent", headers: map[string][]string{"Cookie": {"foo=bar"}}, expect: "catchAll"},
+		{path: "/", headers: map[string][]string{"X-Tenant": {"qux", "bar"}}, expect: "in"},
+		{path: "/", headers: map[string][]string{"X-Tenant": {"qux"}}, expect: "catchAll"},
+		{path: "/all", headers: map[string][]string{"Accept": {"text/html"}, "X-Tenant": {"baz"}}, expect: "all"},
+		{path: "/all", headers: map[string][]string{"Accept": {"text/html"}, "X-Tenant": {"foo"}}, expect: "catchAll"},
+	} {
+		req := httptest.NewRequest("GET", tt.path, nil)
+		for k, vs := range tt.headers {
+			for _, v := range vs {
+				req.Header.Add(k, v)
+			}
+		}
+
+		r, err := tr.checkRequest(req)
+		require.NoError(t, err)
+		assert.Equal(t, tt.expect, r.Id, "%s %v", tt.path, tt.headers)
+	}
+}
+
+func TestHeaderPredicatesInvalidArgs(t *testing.T) {
+	dc := testdataclient.New([]*eskip.Route{{
+		Id:          "invalid",
+		Path:        "/invalid",
+		Predicates:  []*eskip.Predicate{{Name: "HeaderIn", Args: []interface{}{"X-Tenant"}}},
+		BackendType: eskip.ShuntBackend,
+	}, {
+		Id:          "valid",
+		Path:        "/valid",
+		Predicates:  []*eskip.Predicate{{Name: "HeaderIn", Args: []interface{}{"X-Tenant", "foo"}}},
+		BackendType: eskip.ShuntBackend,
+	}})
+	defer dc.Close()
+
+	tr, err := newTestRouting(dc)
+	require.NoError(t, err)
+	defer tr.close()
+
+	req := httptest.NewRequest("GET", "/invalid", nil)
+	req.Header.Set("X-Tenant", "foo")
+	_, err = tr.checkRequest(req)
+	assert.Error(t, err)
+
+	req = httptest.NewRequest("GET", "/valid", nil)
+	req.Header.Set("X-Tenant", "foo")
+	_, err = tr.checkRequest(req)
+	assert.NoError(t, err)
+}
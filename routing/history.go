@@ -0,0 +1,255 @@
+package routing
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/zalando/skipper/eskip"
+)
+
+const (
+	historyPath = "/routes/history"
+	diffPath    = "/routes/diff"
+)
+
+// Generation describes an applied route table and its changes compared
+// to the previous generation.
+type Generation struct {
+	Id      int       `json:"id"`
+	Created time.Time `json:"created"`
+
+	// Source is the name of the data client whose update triggered
+	// the generation, e.g. kubernetes, the same as of the invalid
+	// routes.
+	Source  string   `json:"source,omitempty"`
+	Routes  int      `json:"routes"`
+	Added   []string `json:"added"`
+	Deleted []string `json:"deleted"`
+	Changed []string `json:"changed"`
+}
+
+// RouteChange is a route that differs between two generations.
+type RouteChange struct {
+	Id   string       `json:"id"`
+	From *eskip.Route `json:"from"`
+	To   *eskip.Route `json:"to"`
+}
+
+// RouteDiff is the structured difference of the valid routes between two
+// generations.
+type RouteDiff struct {
+	From    int            `json:"from"`
+	To      int            `json:"to"`
+	Added   []*eskip.Route `json:"added"`
+	Deleted []*eskip.Route `json:"deleted"`
+	Changed []RouteChange  `json:"changed"`
+}
+
+type generation struct {
+	Generation
+
+	// sorted by id, as the valid routes of the route table
+	routes []*eskip.Route
+}
+
+// routeHistory keeps a bounded number of the last route table
+// generations.
+type routeHistory struct {
+	mu          sync.Mutex
+	size        int
+	generations []*generation
+}
+
+func newRouteHistory(size int) *routeHistory {
+	return &routeHistory{size: size}
+}
+
+// diffRoutes compares two lists of routes sorted by id.
+func diffRoutes(from, to []*eskip.Route) (added, deleted []*eskip.Route, changed []RouteChange) {
+	added, deleted, changed = []*eskip.Route{}, []*eskip.Route{}, []RouteChange{}
+	for len(from) > 0 || len(to) > 0 {
+		switch {
+		case len(to) == 0 || len(from) > 0 && from[0].Id < to[0].Id:
+			deleted = append(deleted, from[0])
+			from = from[1:]
+		case len(from) == 0 || to[0].Id < from[0].Id:
+			added = append(added, to[0])
+			to = to[1:]
+		default:
+			if !eskip.Eq(from[0], to[0]) {
+				changed = append(changed, RouteChange{Id: to[0].Id, From: from[0], To: to[0]})
+			}
+
+			from, to = from[1:], to[1:]
+		}
+	}
+
+	return
+}
+
+func routeIds(routes []*eskip.Route) []string {
+	ids := make([]string, len(routes))
+	for i, r := range routes {
+		ids[i] = r.Id
+	}
+
+	return ids
+}
+
+func (h *routeHistory) add(rt *routeTable) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var previous []*eskip.Route
+	if len(h.generations) > 0 {
+		previous = h.generations[len(h.generations)-1].routes
+	}
+
+	added, deleted, changed := diffRoutes(previous, rt.validRoutes)
+	changedIds := make([]string, len(changed))
+	for i, c := range changed {
+		changedIds[i] = c.Id
+	}
+
+	g := &generation{
+		Generation: Generation{
+			Id:      rt.id,
+			Created: rt.created,
+			Source:  dataClientName(rt.source),
+			Routes:  len(rt.validRoutes),
+			Added:   routeIds(added),
+			Deleted: routeIds(deleted),
+			Changed: changedIds,
+		},
+		routes: rt.validRoutes,
+	}
+
+	if len(h.generations) >= h.size {
+		n := copy(h.generations, h.generations[len(h.generations)-h.size+1:])
+		clear(h.generations[n:])
+		h.generations = h.generations[:n]
+	}
+
+	h.generations = append(h.generations, g)
+}
+
+func (h *routeHistory) list() []Generation {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	gs := make([]Generation, len(h.generations))
+	for i, g := range h.generations {
+		gs[i] = g.Generation
+	}
+
+	return gs
+}
+
+func (h *routeHistory) get(id int) *generation {
+	for _, g := range h.generations {
+		if g.Id == id {
+			return g
+		}
+	}
+
+	return nil
+}
+
+// diff returns the difference between two generations. When from is
+// zero, the generation preceding to is used, and when to is zero, the
+// last generation is used. It returns nil if a generation is not in the
+// history anymore.
+func (h *routeHistory) diff(from, to int) *RouteDiff {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if len(h.generations) == 0 {
+		return nil
+	}
+
+	if to == 0 {
+		to = h.generations[len(h.generations)-1].Id
+	}
+
+	if from == 0 {
+		from = to - 1
+	}
+
+	fg, tg := h.get(from), h.get(to)
+	if fg == nil || tg == nil {
+		return nil
+	}
+
+	added, deleted, changed := diffRoutes(fg.routes, tg.routes)
+	return &RouteDiff{
+		From:    from,
+		To:      to,
+		Added:   added,
+		Deleted: deleted,
+		Changed: changed,
+	}
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		http.Error(
+			w,
+			http.StatusText(http.StatusInternalServerError),
+			http.StatusInternalServerError,
+		)
+	}
+}
+
+// serveHistory renders the route table generations kept in the history,
+// the oldest first.
+func (r *Routing) serveHistory(w http.ResponseWriter, req *http.Request) {
+	if req.Method != "GET" {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	if r.history == nil {
+		http.Error(w, "route history disabled", http.StatusNotFound)
+		return
+	}
+
+	writeJSON(w, r.history.list())
+}
+
+// serveDiff renders the difference of the routes between two
+// generations, defined by the from and to query parameters.
+func (r *Routing) serveDiff(w http.ResponseWriter, req *http.Request) {
+	if req.Method != "GET" {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	if r.history == nil {
+		http.Error(w, "route history disabled", http.StatusNotFound)
+		return
+	}
+
+	req.ParseForm()
+	from, err := extractParam(req, "from", 0)
+	if err != nil {
+		http.Error(w, "invalid from", http.StatusBadRequest)
+		return
+	}
+
+	to, err := extractParam(req, "to", 0)
+	if err != nil {
+		http.Error(w, "invalid to", http.StatusBadRequest)
+		return
+	}
+
+	d := r.history.diff(from, to)
+	if d == nil {
+		http.Error(w, "generation not found", http.StatusNotFound)
+		return
+	}
+
+	writeJSON(w, d)
+}
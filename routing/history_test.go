@@ -0,0 +1,144 @@
+package routing_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zalando/skipper/filters/builtin"
+	"github.com/zalando/skipper/logging/loggingtest"
+	"github.com/zalando/skipper/routing"
+	"github.com/zalando/skipper/routing/testdataclient"
+)
+
+func newTestRoutingWithHistory(t *testing.T, size int, dc routing.DataClient) *testRouting {
+	tl := loggingtest.New()
+	rt := routing.New(routing.Options{
+		FilterRegistry:   builtin.MakeRegistry(),
+		DataClients:      []routing.DataClient{dc},
+		PollTimeout:      pollTimeout,
+		Log:              tl,
+		RouteHistorySize: size,
+	})
+
+	tr := &testRouting{tl, rt}
+	require.NoError(t, tr.waitForRouteSetting())
+	return tr
+}
+
+func getHistory(t *testing.T, tr *testRouting) []routing.Generation {
+	w := httptest.NewRecorder()
+	tr.routing.ServeHTTP(w, httptest.NewRequest("GET", "/routes/history", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var gs []routing.Generation
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &gs))
+	return gs
+}
+
+func getDiff(t *testing.T, tr *testRouting, query string) (int, *routing.RouteDiff) {
+	w := httptest.NewRecorder()
+	tr.routing.ServeHTTP(w, httptest.NewRequest("GET", "/routes/diff"+query, nil))
+	if w.Code != http.StatusOK {
+		return w.Code, nil
+	}
+
+	var d routing.RouteDiff
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &d))
+	return w.Code, &d
+}
+
+func TestRouteHistory(t *testing.T) {
+	dc, err := testdataclient.NewDoc(`
+		foo: Path("/foo") -> "https://foo.example.org";
+		bar: Path("/bar") -> "https://bar.example.org";
+	`)
+	require.NoError(t, err)
+	defer dc.Close()
+
+	tr := newTestRoutingWithHistory(t, 2, dc)
+	defer tr.close()
+
+	gs := getHistory(t, tr)
+	require.Len(t, gs, 1)
+	assert.Equal(t, "testdataclient", gs[0].Source)
+	assert.Equal(t, 2, gs[0].Routes)
+	assert.Equal(t, []string{"bar", "foo"}, gs[0].Added)
+	assert.Empty(t, gs[0].Deleted)
+	assert.Empty(t, gs[0].Changed)
+
+	tr.log.Reset()
+	require.NoError(t, dc.UpdateDoc(`
+		foo: Path("/foo") -> "https://foo2.example.org";
+		baz: Path("/baz") -> "https://baz.example.org";
+	`, []string{"bar"}))
+	require.NoError(t, tr.waitForRouteSetting())
+
+	gs = getHistory(t, tr)
+	require.Len(t, gs, 2)
+	first, last := gs[0].Id, gs[1].Id
+	assert.Equal(t, []string{"baz"}, gs[1].Added)
+	assert.Equal(t, []string{"bar"}, gs[1].Deleted)
+	assert.Equal(t, []string{"foo"}, gs[1].Changed)
+
+	status, d := getDiff(t, tr, "")
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, first, d.From)
+	assert.Equal(t, last, d.To)
+	require.Len(t, d.Added, 1)
+	assert.Equal(t, "baz", d.Added[0].Id)
+	require.Len(t, d.Deleted, 1)
+	assert.Equal(t, "bar", d.Deleted[0].Id)
+	require.Len(t, d.Changed, 1)
+	assert.Equal(t, "foo", d.Changed[0].Id)
+	assert.Equal(t, "https://foo.example.org", d.Changed[0].From.Backend)
+	assert.Equal(t, "https://foo2.example.org", d.Changed[0].To.Backend)
+
+	status, d = getDiff(t, tr, "?from=2&to=1")
+	require.Equal(t, http.StatusOK, status)
+	require.Len(t, d.Added, 1)
+	assert.Equal(t, "bar", d.Added[0].Id)
+
+	tr.log.Reset()
+	require.NoError(t, dc.UpdateDoc(`qux: Path("/qux") -> "https://qux.example.org";`, nil))
+	require.NoError(t, tr.waitForRouteSetting())
+
+	gs = getHistory(t, tr)
+	require.Len(t, gs, 2)
+	assert.Equal(t, last, gs[0].Id)
+	assert.Equal(t, []string{"qux"}, gs[1].Added)
+
+	status, _ = getDiff(t, tr, "?from=1")
+	assert.Equal(t, http.StatusNotFound, status)
+}
+
+func TestRouteHistoryInvalidRequest(t *testing.T) {
+	dc, err := testdataclient.NewDoc(`foo: * -> <shunt>;`)
+	require.NoError(t, err)
+	defer dc.Close()
+
+	tr := newTestRoutingWithHistory(t, 2, dc)
+	defer tr.close()
+
+	status, _ := getDiff(t, tr, "?from=foo")
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	status, _ = getDiff(t, tr, "?to=-1")
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	w := httptest.NewRecorder()
+	tr.routing.ServeHTTP(w, httptest.NewRequest("POST", "/routes/history", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+
+	noHistory, err := newTestRouting(dc)
+	require.NoError(t, err)
+	defer noHistory.close()
+
+	w = httptest.NewRecorder()
+	noHistory.routing.ServeHTTP(w, httptest.NewRequest("GET", "/routes/history", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
@@ -0,0 +1,165 @@
+package routing
+
+import (
+	"errors"
+	"net/http"
+	"path"
+	"reflect"
+	"sort"
+
+	"github.com/zalando/skipper/eskip"
+)
+
+const invalidPath = "/routes/invalid"
+
+// ObjectReference refers to the object that a route was generated from,
+// e.g. a Kubernetes Ingress or RouteGroup.
+type ObjectReference struct {
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name"`
+}
+
+// RouteOrigins can be optionally implemented by the data clients that
+// generate routes from other objects. It is used to refer to these
+// objects when reporting invalid routes.
+type RouteOrigins interface {
+
+	// RouteOrigin returns the object that the route with the given
+	// id was generated from, or nil if it is not known.
+	RouteOrigin(routeId string) *ObjectReference
+}
+
+// InvalidRoute is a route definition that was rejected during
+// processing the routing table.
+type InvalidRoute struct {
+	Route *eskip.Route `json:"route"`
+
+	// Reason is the code of the rejection, e.g. unknown_filter.
+	Reason string `json:"reason"`
+	Error  string `json:"error"`
+
+	// DataClient is the name of the data client that provided the
+	// route definition.
+	DataClient string           `json:"dataClient,omitempty"`
+	Origin     *ObjectReference `json:"origin,omitempty"`
+}
+
+func newInvalidRoute(def *eskip.Route, err error) *InvalidRoute {
+	reason := "other"
+	var defErr invalidDefinitionError
+	if errors.As(err, &defErr) {
+		reason = defErr.Code()
+	}
+
+	return &InvalidRoute{Route: def, Reason: reason, Error: err.Error()}
+}
+
+// dataClientName returns the package name of a data client, e.g.
+// kubernetes or eskipfile. It names the data clients on all the
+// endpoints and in the metrics.
+func dataClientName(c DataClient) string {
+	t := reflect.TypeOf(c)
+	if t == nil {
+		return ""
+	}
+
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	if t.PkgPath() == "" {
+		return t.String()
+	}
+
+	return path.Base(t.PkgPath())
+}
+
+// setInvalidRouteSources sets the data client and the origin of the
+// invalid routes.
+func setInvalidRouteSources(invalid []*InvalidRoute, clientsById map[string]DataClient) {
+	for _, ir := range invalid {
+		c, ok := clientsById[ir.Route.Id]
+		if !ok {
+			continue
+		}
+
+		ir.DataClient = dataClientName(c)
+		if ro, ok := c.(RouteOrigins); ok {
+			ir.Origin = ro.RouteOrigin(ir.Route.Id)
+		}
+	}
+}
+
+// invalidRouteMetrics reports the number of the invalid routes per data
+// client and reason. It remembers the reported counts, in order to reset
+// them when there are no more invalid routes for a data client and
+// reason.
+type invalidRouteMetrics map[string]map[string]int
+
+func (m invalidRouteMetrics) update(o *Options, invalid []*InvalidRoute) {
+	counts := make(invalidRouteMetrics)
+	for client, reasons := range m {
+		counts[client] = make(map[string]int)
+		for reason := range reasons {
+			counts[client][reason] = 0
+		}
+	}
+
+	for _, ir := range invalid {
+		client := ir.DataClient
+		if client == "" {
+			client = "unknown"
+		}
+
+		if counts[client] == nil {
+			counts[client] = make(map[string]int)
+		}
+
+		counts[client][ir.Reason]++
+	}
+
+	clear(m)
+	for client, reasons := range counts {
+		if o.Metrics != nil {
+			o.Metrics.UpdateInvalidRouteByDataClient(client, reasons)
+		}
+
+		for reason, count := range reasons {
+			if count > 0 {
+				if m[client] == nil {
+					m[client] = make(map[string]int)
+				}
+
+				m[client][reason] = count
+			}
+		}
+	}
+}
+
+// serveInvalid renders the invalid routes of the current routing table,
+// together with the reason of the rejection.
+func (r *Routing) serveInvalid(w http.ResponseWriter, req *http.Request) {
+	if req.Method != "GET" {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	rt := r.routeTable.Load().(*routeTable)
+	invalid := make([]*InvalidRoute, 0, len(rt.invalidRoutes))
+	req.ParseForm()
+	reason, dataClient := req.Form.Get("reason"), req.Form.Get("dataclient")
+	for _, ir := range rt.invalidRoutes {
+		if reason != "" && ir.Reason != reason || dataClient != "" && ir.DataClient != dataClient {
+			continue
+		}
+
+		invalid = append(invalid, ir)
+	}
+
+	sort.SliceStable(invalid, func(i, j int) bool {
+		return invalid[i].Route.Id < invalid[j].Route.Id
+	})
+
+	writeJSON(w, invalid)
+}
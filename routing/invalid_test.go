@@ -0,0 +1,100 @@
+package routing_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zalando/skipper/filters/builtin"
+	"github.com/zalando/skipper/logging/loggingtest"
+	"github.com/zalando/skipper/metrics/metricstest"
+	"github.com/zalando/skipper/routing"
+	"github.com/zalando/skipper/routing/testdataclient"
+)
+
+type originDataClient struct {
+	*testdataclient.Client
+}
+
+func (originDataClient) RouteOrigin(id string) *routing.ObjectReference {
+	if id == "fooInvalid" {
+		return &routing.ObjectReference{Kind: "RouteGroup", Namespace: "default", Name: "foo"}
+	}
+
+	return nil
+}
+
+func getInvalidRoutes(t *testing.T, tr *testRouting, query string) []routing.InvalidRoute {
+	w := httptest.NewRecorder()
+	tr.routing.ServeHTTP(w, httptest.NewRequest("GET", "/routes/invalid"+query, nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var invalid []routing.InvalidRoute
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &invalid))
+	return invalid
+}
+
+func TestInvalidRoutes(t *testing.T) {
+	dc, err := testdataclient.NewDoc(`
+		valid: Path("/valid") -> <shunt>;
+		fooInvalid: Path("/foo") -> unknownFilter() -> <shunt>;
+		barInvalid: Path("/bar") && UnknownPredicate() -> <shunt>;
+	`)
+	require.NoError(t, err)
+	defer dc.Close()
+
+	m := &metricstest.MockMetrics{}
+	tl := loggingtest.New()
+	rt := routing.New(routing.Options{
+		FilterRegistry: builtin.MakeRegistry(),
+		DataClients:    []routing.DataClient{originDataClient{dc}},
+		PollTimeout:    pollTimeout,
+		Log:            tl,
+		Metrics:        m,
+	})
+
+	tr := &testRouting{tl, rt}
+	defer tr.close()
+	require.NoError(t, tr.waitForRouteSetting())
+
+	invalid := getInvalidRoutes(t, tr, "")
+	require.Len(t, invalid, 2)
+
+	assert.Equal(t, "barInvalid", invalid[0].Route.Id)
+	assert.Equal(t, "unknown_predicate", invalid[0].Reason)
+	assert.Contains(t, invalid[0].Error, "UnknownPredicate")
+	assert.Equal(t, "routing_test", invalid[0].DataClient)
+	assert.Nil(t, invalid[0].Origin)
+
+	assert.Equal(t, "fooInvalid", invalid[1].Route.Id)
+	assert.Equal(t, "unknown_filter", invalid[1].Reason)
+	assert.Equal(t, &routing.ObjectReference{Kind: "RouteGroup", Namespace: "default", Name: "foo"}, invalid[1].Origin)
+
+	invalid = getInvalidRoutes(t, tr, "?reason=unknown_filter")
+	require.Len(t, invalid, 1)
+	assert.Equal(t, "fooInvalid", invalid[0].Route.Id)
+
+	assert.Empty(t, getInvalidRoutes(t, tr, "?dataclient=kubernetes"))
+
+	m.WithGauges(func(g map[string]float64) {
+		assert.Equal(t, 1.0, g["route.invalid.dataclient.routing_test.unknown_filter"])
+		assert.Equal(t, 1.0, g["route.invalid.dataclient.routing_test.unknown_predicate"])
+	})
+
+	tr.log.Reset()
+	dc.Update(nil, []string{"fooInvalid"})
+	require.NoError(t, tr.waitForRouteSetting())
+
+	invalid = getInvalidRoutes(t, tr, "")
+	require.Len(t, invalid, 1)
+	assert.Equal(t, "barInvalid", invalid[0].Route.Id)
+
+	m.WithGauges(func(g map[string]float64) {
+		assert.Equal(t, 0.0, g["route.invalid.dataclient.routing_test.unknown_filter"])
+		assert.Equal(t, 1.0, g["route.invalid.dataclient.routing_test.unknown_predicate"])
+	})
+}
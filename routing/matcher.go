@@ -16,6 +16,7 @@ type leafRequestMatcher struct {
 	r         *http.Request
 	path      string
 	exactPath string
+	explain   *explainRecorder
 }
 
 func (m *leafRequestMatcher) Match(value interface{}) (bool, interface{}) {
@@ -24,7 +25,7 @@ func (m *leafRequestMatcher) Match(value interface{}) (bool, interface{}) {
 		return false, nil
 	}
 
-	l := matchLeaves(v.leaves, m.r, m.path, m.exactPath)
+	l := matchIndexedLeaves(v.leaves, v.index, m.r, m.path, m.exactPath, m.explain)
 	return l != nil, l
 }
 
@@ -38,6 +39,7 @@ type leafMatcher struct {
 	pathRxs              []*regexp.Regexp
 	headersExact         map[string]string
 	headersRegexp        map[string][]*regexp.Regexp
+	headerConditions     []*headerCondition
 	predicates           []Predicate
 	route                *Route
 }
@@ -55,6 +57,7 @@ func leafWeight(l *leafMatcher) int {
 	w += len(l.pathRxs)
 	w += len(l.headersExact)
 	w += len(l.headersRegexp)
+	w += len(l.headerConditions)
 	w += len(l.predicates)
 
 	return w
@@ -67,12 +70,14 @@ func (ls leafMatchers) Less(i, j int) bool { return leafWeight(ls[i]) > leafWeig
 
 type pathMatcher struct {
 	leaves leafMatchers
+	index  *headerIndex
 }
 
 // root structure representing the routing tree.
 type matcher struct {
 	paths           *pathmux.Tree
 	rootLeaves      leafMatchers
+	rootIndex       *headerIndex
 	matchingOptions MatchingOptions
 }
 
@@ -237,14 +242,15 @@ func newLeaf(r *Route, rxs map[string]*regexp.Regexp) (*leafMatcher, error) {
 		wildcardParamNames:   extractWildcardParamNames(r),
 		hasFreeWildcardParam: hasFreeWildcardParam(r),
 
-		weight:        r.weight,
-		method:        r.Method,
-		hostRxs:       hostRxs,
-		pathRxs:       pathRxs,
-		headersExact:  canonicalizeHeaders(r.Headers),
-		headersRegexp: canonicalizeHeaderRegexps(allHeaderRxs),
-		predicates:    r.Predicates,
-		route:         r}, nil
+		weight:           r.weight,
+		method:           r.Method,
+		hostRxs:          hostRxs,
+		pathRxs:          pathRxs,
+		headersExact:     canonicalizeHeaders(r.Headers),
+		headersRegexp:    canonicalizeHeaderRegexps(allHeaderRxs),
+		headerConditions: r.headerConditions,
+		predicates:       r.Predicates,
+		route:            r}, nil
 }
 
 func trimTrailingSlash(path string) string {
@@ -263,6 +269,7 @@ func addTreeMatchers(pathTree *pathmux.Tree, matchers map[string]*pathMatcher) [
 
 		// sort leaves during construction time, based on their priority
 		sort.Stable(m.leaves)
+		m.index = newHeaderIndex(m.leaves)
 
 		if err := pathTree.Add(p, m); err != nil {
 			errors = append(errors, &definitionError{Index: -1, Original: err})
@@ -352,11 +359,11 @@ func newMatcher(rs []*Route, o MatchingOptions) (*matcher, []*definitionError) {
 	// sort root leaves during construction time, based on their priority
 	sort.Stable(rootLeaves)
 
-	return &matcher{pathTree, rootLeaves, o}, errors
+	return &matcher{pathTree, rootLeaves, newHeaderIndex(rootLeaves), o}, errors
 }
 
 // matches a path in the path trie structure.
-func matchPathTree(tree *pathmux.Tree, path string, lrm *leafRequestMatcher) (map[string]string, *leafMatcher) {
+func matchPathTree(tree *pathmux.Tree, path string, lrm pathmux.Matcher) (map[string]string, *leafMatcher) {
 	v, params, value := tree.LookupMatcher(path, lrm)
 	if v == nil {
 		return nil, nil
@@ -442,39 +449,57 @@ func matchPredicates(cps []Predicate, req *http.Request) bool {
 	return true
 }
 
-// matches a request to the conditions in a leaf matcher
-func matchLeaf(l *leafMatcher, req *http.Request, path, exactPath string) bool {
+// matches a request to the conditions in a leaf matcher. When
+// explaining, the recorder receives the condition that rejected the
+// request.
+func matchLeaf(l *leafMatcher, req *http.Request, path, exactPath string, x *explainRecorder) bool {
 	if l.exactPath != "" && l.exactPath != path {
+		x.reject(l, RejectedByPath, l.exactPath)
 		return false
 	}
 
 	if l.method != "" && l.method != req.Method {
+		x.reject(l, RejectedByMethod, l.method)
 		return false
 	}
 
 	if !matchRegexps(l.hostRxs, req.Host) {
+		x.rejectRegexps(l, RejectedByHost, l.hostRxs, req.Host)
 		return false
 	}
 
 	if !matchRegexps(l.pathRxs, exactPath) {
+		x.rejectRegexps(l, RejectedByPathRegexp, l.pathRxs, exactPath)
 		return false
 	}
 
 	if !matchHeaders(l.headersExact, l.headersRegexp, req.Header) {
+		x.rejectHeaders(l, req.Header)
 		return false
 	}
 
-	if !matchPredicates(l.predicates, req) {
+	if !matchHeaderConditions(l.headerConditions, req.Header) {
+		x.rejectHeaderConditions(l, req.Header)
 		return false
 	}
 
+	// not using matchPredicates, because the predicates are not
+	// evaluated twice for explaining
+	for i, p := range l.predicates {
+		if !p.Match(req) {
+			x.rejectPredicate(l, i)
+			return false
+		}
+	}
+
+	x.match(l)
 	return true
 }
 
 // matches a request to a set of leaf matchers
-func matchLeaves(leaves leafMatchers, req *http.Request, path, exactPath string) *leafMatcher {
+func matchLeaves(leaves leafMatchers, req *http.Request, path, exactPath string, x *explainRecorder) *leafMatcher {
 	for _, l := range leaves {
-		if matchLeaf(l, req, path, exactPath) {
+		if matchLeaf(l, req, path, exactPath, x) {
 			return l
 		}
 	}
@@ -482,6 +507,16 @@ func matchLeaves(leaves leafMatchers, req *http.Request, path, exactPath string)
 	return nil
 }
 
+// matches a request to a set of leaf matchers, using the header index,
+// when available. When explaining, all the leaves are evaluated.
+func matchIndexedLeaves(leaves leafMatchers, ix *headerIndex, req *http.Request, path, exactPath string, x *explainRecorder) *leafMatcher {
+	if ix == nil || x != nil {
+		return matchLeaves(leaves, req, path, exactPath, x)
+	}
+
+	return ix.match(leaves, req, path, exactPath)
+}
+
 // tries to match a request against the available definitions. If a match is found,
 // returns the associated value, and the wildcard parameters from the path definition,
 // if any.
@@ -503,7 +538,7 @@ func (m *matcher) match(r *http.Request) (*Route, map[string]string) {
 	}
 
 	// if no path match, match root leaves for other conditions
-	l = matchLeaves(m.rootLeaves, r, path, exact)
+	l = matchIndexedLeaves(m.rootLeaves, m.rootIndex, r, path, exact, nil)
 	if l != nil {
 		return l.route, nil
 	}
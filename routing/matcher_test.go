@@ -502,7 +502,7 @@ func TestMatchLeafWrongMethod(t *testing.T) {
 		pathRxs:       []*regexp.Regexp{rxp},
 		headersExact:  map[string]string{"Some-Header": "some-value"},
 		headersRegexp: map[string][]*regexp.Regexp{"Some-Other-Header": {rxhd}}}
-	if matchLeaf(l, req, "/some/path", "/some/path") {
+	if matchLeaf(l, req, "/some/path", "/some/path", nil) {
 		t.Error("failed not to match leaf method")
 	}
 }
@@ -523,7 +523,7 @@ func TestMatchLeafWrongHost(t *testing.T) {
 		pathRxs:       []*regexp.Regexp{rxp},
 		headersExact:  map[string]string{"Some-Header": "some-value"},
 		headersRegexp: map[string][]*regexp.Regexp{"Some-Other-Header": {rxhd}}}
-	if matchLeaf(l, req, "/some/path", "/some/path") {
+	if matchLeaf(l, req, "/some/path", "/some/path", nil) {
 		t.Error("failed not to match leaf host")
 	}
 }
@@ -545,7 +545,7 @@ func TestMatchLeafWrongPath(t *testing.T) {
 		pathRxs:       []*regexp.Regexp{rxp},
 		headersExact:  map[string]string{"Some-Header": "some-value"},
 		headersRegexp: map[string][]*regexp.Regexp{"Some-Other-Header": {rxhd}}}
-	if matchLeaf(l, req, "/some/other/path", "/some/other/path") {
+	if matchLeaf(l, req, "/some/other/path", "/some/other/path", nil) {
 		t.Error("failed not to match leaf path")
 	}
 }
@@ -567,7 +567,7 @@ func TestMatchLeafWrongExactHeader(t *testing.T) {
 		pathRxs:       []*regexp.Regexp{rxp},
 		headersExact:  map[string]string{"Some-Header": "some-value"},
 		headersRegexp: map[string][]*regexp.Regexp{"Some-Other-Header": {rxhd}}}
-	if matchLeaf(l, req, "/some/path", "/some/path") {
+	if matchLeaf(l, req, "/some/path", "/some/path", nil) {
 		t.Error("failed not to match leaf exact header")
 	}
 }
@@ -589,7 +589,7 @@ func TestMatchLeafWrongRegexpHeader(t *testing.T) {
 		pathRxs:       []*regexp.Regexp{rxp},
 		headersExact:  map[string]string{"Some-Header": "some-value"},
 		headersRegexp: map[string][]*regexp.Regexp{"Some-Other-Header": {rxhd}}}
-	if matchLeaf(l, req, "/some/path", "/some/path") {
+	if matchLeaf(l, req, "/some/path", "/some/path", nil) {
 		t.Error("failed not to match leaf regexp header")
 	}
 }
@@ -611,7 +611,7 @@ func TestMatchLeaf(t *testing.T) {
 		pathRxs:       []*regexp.Regexp{rxp},
 		headersExact:  map[string]string{"Some-Header": "some-value"},
 		headersRegexp: map[string][]*regexp.Regexp{"Some-Other-Header": {rxhd}}}
-	if !matchLeaf(l, req, "/some/path", "/some/path") {
+	if !matchLeaf(l, req, "/some/path", "/some/path", nil) {
 		t.Error("failed to match leaf")
 	}
 }
@@ -620,7 +620,7 @@ func TestMatchLeavesFalse(t *testing.T) {
 	l0 := &leafMatcher{method: "PUT"}
 	l1 := &leafMatcher{method: "POST"}
 	req := &http.Request{Method: "GET"}
-	if matchLeaves([]*leafMatcher{l0, l1}, req, "/some/path", "/some/path") != nil {
+	if matchLeaves([]*leafMatcher{l0, l1}, req, "/some/path", "/some/path", nil) != nil {
 		t.Error("failed not to match leaves")
 	}
 }
@@ -629,7 +629,7 @@ func TestMatchLeavesTrue(t *testing.T) {
 	l0 := &leafMatcher{method: "PUT"}
 	l1 := &leafMatcher{method: "POST"}
 	req := &http.Request{URL: &url.URL{Path: "/some/path"}, Method: "PUT"}
-	if matchLeaves([]*leafMatcher{l0, l1}, req, "/some/path", "/some/path") != l0 {
+	if matchLeaves([]*leafMatcher{l0, l1}, req, "/some/path", "/some/path", nil) != l0 {
 		t.Error("failed not to match leaves")
 	}
 }
@@ -1309,3 +1309,63 @@ func BenchmarkConstructionMass(b *testing.B) {
 		}
 	}
 }
+
+func TestHeaderIndex(t *testing.T) {
+	m, err := docToMatcher(`
+		foo: Path("/") && Header("X-Tenant", "foo") -> <shunt>;
+		barBaz: Path("/") && HeaderIn("X-Tenant", "bar", "baz") -> <shunt>;
+		fooPost: Path("/") && Method("POST") && Header("X-Tenant", "foo") && Header("X-Foo", "bar") -> <shunt>;
+		post: Path("/") && Method("POST") && HeaderExists("X-Foo") -> <shunt>;
+		catchAll: Path("/") -> <shunt>;
+		rootFoo: Header("X-Tenant", "foo") -> <shunt>;
+		rootBar: Header("X-Tenant", "bar") -> <shunt>;
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pm, _ := m.paths.Lookup("/")
+	if ix := pm.(*pathMatcher).index; ix == nil || ix.key != "X-Tenant" {
+		t.Fatalf("failed to index the leaves: %+v", ix)
+	}
+
+	if m.rootIndex == nil {
+		t.Fatal("failed to index the root leaves")
+	}
+
+	for _, test := range []struct {
+		method  string
+		path    string
+		tenants []string
+		expect  string
+	}{
+		{"GET", "/", []string{"foo"}, "foo"},
+		{"GET", "/", []string{"baz"}, "barBaz"},
+		{"GET", "/", []string{"qux"}, "catchAll"},
+		{"GET", "/", nil, "catchAll"},
+		{"POST", "/", []string{"foo"}, "fooPost"},
+		{"POST", "/", []string{"bar"}, "post"},
+		{"GET", "/", []string{"qux", "bar"}, "barBaz"},
+		{"GET", "/other", []string{"bar"}, "rootBar"},
+		{"GET", "/other", []string{"qux"}, ""},
+	} {
+		req, err := http.NewRequest(test.method, test.path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Set("X-Foo", "bar")
+		for _, v := range test.tenants {
+			req.Header.Add("X-Tenant", v)
+		}
+
+		var id string
+		if r, _ := m.match(req); r != nil {
+			id = r.Id
+		}
+
+		if id != test.expect {
+			t.Errorf("%s %s %v: expected %q, got %q", test.method, test.path, test.tenants, test.expect, id)
+		}
+	}
+}
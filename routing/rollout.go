@@ -0,0 +1,346 @@
+package routing
+
+import (
+	"math/rand/v2"
+	"sync/atomic"
+	"time"
+
+	"github.com/zalando/skipper/eskip"
+)
+
+const (
+	defaultRolloutDuration    = time.Minute
+	defaultRolloutMinRequests = 100
+	defaultRolloutThreshold   = 0.05
+	rolloutCheckInterval      = time.Second
+)
+
+// RolloutOptions configure the staged rollout of the route table
+// generations. When enabled, a new generation first serves only a
+// fraction of the requests, while its 5xx rate is compared with the rate
+// of the current generation. The new generation is promoted when the
+// rollout duration has passed, or rolled back as soon as its 5xx rate
+// regresses beyond the threshold.
+//
+// Only the lookups done with Routing.Get take part in the rollout, and
+// the result needs to be reported with RouteLookup.Report. The new
+// generation is processed with the RolloutPostProcessors, and when it is
+// promoted, it is created again with the regular post-processing. The
+// routes of a rolled back generation are not rolled out again, until
+// the data clients change them. The promoted, rolled back or replaced
+// generation is closed, when the last request routed with it was
+// reported.
+type RolloutOptions struct {
+
+	// Fraction of the requests routed with the new generation. When
+	// zero, new generations are applied at once.
+	Fraction float64
+
+	// Duration of the rollout before the new generation is promoted.
+	// Defaults to one minute.
+	Duration time.Duration
+
+	// MinRequests is the minimum number of requests routed with the
+	// new generation before it can be rolled back. Defaults to 100.
+	MinRequests int64
+
+	// ErrorRateThreshold is the maximum allowed difference between the
+	// 5xx rate of the new and the current generation. Defaults to 0.05.
+	ErrorRateThreshold float64
+}
+
+type generationStats struct {
+	requests atomic.Int64
+	errors   atomic.Int64
+}
+
+func (s *generationStats) report(statusCode int) {
+	s.requests.Add(1)
+	if statusCode >= 500 {
+		s.errors.Add(1)
+	}
+}
+
+func (s *generationStats) errorRate() (int64, float64) {
+	requests := s.requests.Load()
+	if requests == 0 {
+		return 0, 0
+	}
+
+	return requests, float64(s.errors.Load()) / float64(requests)
+}
+
+// rolloutRetired is set in the in-flight state of a rollout that was
+// promoted, rolled back or replaced.
+const rolloutRetired = 1 << 62
+
+// rollout is a new route table generation serving a fraction of the
+// requests.
+type rollout struct {
+	canary      *routeTable
+	started     time.Time
+	canaryStats generationStats
+	stableStats generationStats
+
+	// the number of the lookups of the canary in flight, and the
+	// rolloutRetired flag
+	inFlight atomic.Int64
+}
+
+type rolloutDecision int
+
+const (
+	rolloutPending rolloutDecision = iota
+	rolloutPromote
+	rolloutRollback
+)
+
+// acquire registers a lookup of the canary. It fails, when the rollout
+// was already retired.
+func (ro *rollout) acquire() bool {
+	if ro.inFlight.Add(1)&rolloutRetired == 0 {
+		return true
+	}
+
+	ro.release()
+	return false
+}
+
+// release ends a lookup of the canary, and closes the canary, when the
+// rollout was retired and it was the last lookup in flight.
+func (ro *rollout) release() {
+	if ro.inFlight.Add(-1) == rolloutRetired {
+		ro.canary.close()
+	}
+}
+
+// retire closes the canary, when no lookups of it are in flight,
+// otherwise the last lookup closes it, so that the requests routed
+// with it can finish using its filters.
+func (ro *rollout) retire() {
+	if ro.inFlight.Or(rolloutRetired) == 0 {
+		ro.canary.close()
+	}
+}
+
+func (o RolloutOptions) enabled() bool {
+	return o.Fraction > 0
+}
+
+func (o RolloutOptions) withDefaults() RolloutOptions {
+	if o.Fraction > 1 {
+		o.Fraction = 1
+	}
+
+	if o.Duration <= 0 {
+		o.Duration = defaultRolloutDuration
+	}
+
+	if o.MinRequests <= 0 {
+		o.MinRequests = defaultRolloutMinRequests
+	}
+
+	if o.ErrorRateThreshold <= 0 {
+		o.ErrorRateThreshold = defaultRolloutThreshold
+	}
+
+	return o
+}
+
+func (o RolloutOptions) decide(ro *rollout, now time.Time) rolloutDecision {
+	canaryRequests, canaryRate := ro.canaryStats.errorRate()
+	_, stableRate := ro.stableStats.errorRate()
+	if canaryRequests >= o.MinRequests && canaryRate-stableRate > o.ErrorRateThreshold {
+		return rolloutRollback
+	}
+
+	if now.Sub(ro.started) >= o.Duration {
+		return rolloutPromote
+	}
+
+	return rolloutPending
+}
+
+// lookup returns the lookup of the canary generation for the configured
+// fraction of the requests, otherwise the lookup of the stable
+// generation.
+func (r *Routing) lookup(stable *routeTable) *RouteLookup {
+	ro := r.rollout.Load()
+	if ro == nil {
+		return &RouteLookup{rt: stable}
+	}
+
+	if rand.Float64() < r.rolloutOptions.Fraction && ro.acquire() {
+		return &RouteLookup{rt: ro.canary, stats: &ro.canaryStats, rollout: ro}
+	}
+
+	return &RouteLookup{rt: stable, stats: &ro.stableStats}
+}
+
+// Report records the status code of the response to a request routed
+// with the lookup, used to evaluate the staged rollout of a new route
+// table generation. It needs to be called once, when the request was
+// served, because the generation under rollout is closed only when the
+// requests routed with it were reported.
+func (rl *RouteLookup) Report(statusCode int) {
+	if rl.stats != nil {
+		rl.stats.report(statusCode)
+	}
+
+	if rl.rollout != nil {
+		rl.rollout.release()
+		rl.rollout = nil
+	}
+}
+
+func (r *Routing) startRollout(rt *routeTable) {
+	if ro := r.rollout.Load(); ro != nil {
+		r.log.Infof("route settings rollout replaced, id: %d", ro.canary.id)
+		ro.retire()
+	}
+
+	r.rollout.Store(&rollout{canary: rt, started: time.Now()})
+	r.log.Infof("route settings rollout started, id: %d, fraction: %g", rt.id, r.rolloutOptions.Fraction)
+	if r.metrics != nil {
+		r.metrics.IncCounter("routes.rollout.started")
+	}
+}
+
+// checkRollout promotes or rolls back the generation under rollout, when
+// the decision can be made. The promoted generation is created again
+// with all the post-processors. The rolled back generation is not
+// rolled out again, until its routes change.
+func (r *Routing) checkRollout(o Options) {
+	ro := r.rollout.Load()
+	if ro == nil {
+		return
+	}
+
+	switch r.rolloutOptions.decide(ro, time.Now()) {
+	case rolloutPromote:
+		r.rollout.Store(nil)
+		r.apply(o, r.builder.promote(ro.canary))
+		ro.retire()
+		if r.metrics != nil {
+			r.metrics.IncCounter("routes.rollout.promoted")
+		}
+
+		r.log.Infof("route settings rollout promoted, id: %d", ro.canary.id)
+	case rolloutRollback:
+		r.rollout.Store(nil)
+		r.builder.recordRollback(ro.canary, r.routeTable.Load().(*routeTable))
+		ro.retire()
+		_, canaryRate := ro.canaryStats.errorRate()
+		_, stableRate := ro.stableStats.errorRate()
+		r.log.Errorf(
+			"route settings rollout rolled back, id: %d, 5xx rate: %g, current 5xx rate: %g",
+			ro.canary.id,
+			canaryRate,
+			stableRate,
+		)
+
+		if r.metrics != nil {
+			r.metrics.IncCounter("routes.rollout.rolled_back")
+		}
+	}
+}
+
+// rolledBackRoute is a route definition of a rolled back generation,
+// that was new, changed or removed compared to the current generation.
+type rolledBackRoute struct {
+
+	// the rolled back definition, empty when the route was removed
+	def string
+
+	// the definition of the current generation, nil when the route
+	// was new
+	current *eskip.Route
+}
+
+// rolledBackRoutes holds the route definitions of the rolled back
+// generations by route id, so that they are not rolled out again, while
+// the data clients keep returning them.
+type rolledBackRoutes map[string]rolledBackRoute
+
+// recordRollback stores the routes of the rolled back generation, that
+// differ from the current generation.
+func (b *tableBuilder) recordRollback(canary, current *routeTable) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	currentDefs := make(map[string]*eskip.Route, len(current.defs))
+	for _, d := range current.defs {
+		currentDefs[d.Id] = d
+	}
+
+	canaryIds := make(map[string]bool, len(canary.defs))
+	for _, d := range canary.defs {
+		canaryIds[d.Id] = true
+
+		def := d.String()
+		c, ok := currentDefs[d.Id]
+		if ok && c.String() == def {
+			continue
+		}
+
+		b.rolledBack[d.Id] = rolledBackRoute{def: def, current: c}
+	}
+
+	for id, c := range currentDefs {
+		if !canaryIds[id] {
+			b.rolledBack[id] = rolledBackRoute{current: c}
+		}
+	}
+}
+
+// skipRolledBack replaces the routes of the rolled back generations
+// with their definitions from the generation that was current during
+// the rollback, until the data clients return a different definition.
+func (b *tableBuilder) skipRolledBack(defs []*eskip.Route) []*eskip.Route {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if len(b.rolledBack) == 0 {
+		return defs
+	}
+
+	result := make([]*eskip.Route, 0, len(defs))
+	seen := make(map[string]bool, len(defs))
+	for _, d := range defs {
+		seen[d.Id] = true
+
+		rb, ok := b.rolledBack[d.Id]
+		if !ok {
+			result = append(result, d)
+			continue
+		}
+
+		if rb.def == "" || d.String() != rb.def {
+			// changed since the rollback
+			delete(b.rolledBack, d.Id)
+			result = append(result, d)
+			continue
+		}
+
+		b.o.Log.Infof("route settings rollout, ignoring the rolled back definition of route %s", d.Id)
+		if rb.current != nil {
+			result = append(result, rb.current)
+		}
+	}
+
+	for id, rb := range b.rolledBack {
+		if seen[id] {
+			continue
+		}
+
+		if rb.def == "" {
+			b.o.Log.Infof("route settings rollout, ignoring the rolled back removal of route %s", id)
+			result = append(result, rb.current)
+		} else {
+			// removed since the rollback
+			delete(b.rolledBack, id)
+		}
+	}
+
+	return result
+}
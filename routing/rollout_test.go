@@ -0,0 +1,335 @@
+package routing_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/filters/builtin"
+	"github.com/zalando/skipper/logging/loggingtest"
+	"github.com/zalando/skipper/metrics/metricstest"
+	"github.com/zalando/skipper/routing"
+	"github.com/zalando/skipper/routing/testdataclient"
+)
+
+type rolloutPostProcessor struct {
+	mu        sync.Mutex
+	processed []string
+	rollouts  []string
+}
+
+func routeIds(r []*routing.Route) string {
+	var ids string
+	for _, ri := range r {
+		ids += ri.Id + ";"
+	}
+
+	return ids
+}
+
+func (pp *rolloutPostProcessor) Do(r []*routing.Route) []*routing.Route {
+	pp.mu.Lock()
+	defer pp.mu.Unlock()
+	pp.processed = append(pp.processed, routeIds(r))
+	return r
+}
+
+func (pp *rolloutPostProcessor) DoRollout(r []*routing.Route) []*routing.Route {
+	pp.mu.Lock()
+	defer pp.mu.Unlock()
+	pp.rollouts = append(pp.rollouts, routeIds(r))
+	return r
+}
+
+func (pp *rolloutPostProcessor) calls() (processed, rollouts []string) {
+	pp.mu.Lock()
+	defer pp.mu.Unlock()
+	return append([]string(nil), pp.processed...), append([]string(nil), pp.rollouts...)
+}
+
+func newTestRoutingWithRollout(t *testing.T, ro routing.RolloutOptions, m *metricstest.MockMetrics, dc routing.DataClient, pp ...routing.PostProcessor) *testRouting {
+	tl := loggingtest.New()
+	rt := routing.New(routing.Options{
+		FilterRegistry: builtin.MakeRegistry(),
+		DataClients:    []routing.DataClient{dc},
+		PollTimeout:    pollTimeout,
+		Log:            tl,
+		Metrics:        m,
+		Rollout:        ro,
+		PostProcessors: pp,
+	})
+
+	tr := &testRouting{tl, rt}
+	require.NoError(t, tr.waitForRouteSetting())
+	return tr
+}
+
+func lookupRouteId(lookup *routing.RouteLookup, path string) string {
+	r, _ := lookup.Do(httptest.NewRequest("GET", path, nil))
+	if r == nil {
+		return ""
+	}
+
+	return r.Id
+}
+
+func TestRolloutPromote(t *testing.T) {
+	dc, err := testdataclient.NewDoc(`foo: Path("/foo") -> <shunt>`)
+	require.NoError(t, err)
+	defer dc.Close()
+
+	m := &metricstest.MockMetrics{}
+	tr := newTestRoutingWithRollout(t, routing.RolloutOptions{Fraction: 1, Duration: 100 * time.Millisecond}, m, dc)
+	defer tr.close()
+
+	tr.log.Reset()
+	require.NoError(t, dc.UpdateDoc(`bar: Path("/bar") -> <shunt>`, nil))
+	require.NoError(t, tr.log.WaitFor("route settings rollout started", 12*pollTimeout))
+
+	// the new generation serves the lookups, but it is not applied yet
+	assert.Equal(t, "bar", lookupRouteId(tr.routing.Get(), "/bar"))
+	r, _ := tr.routing.Route(httptest.NewRequest("GET", "/bar", nil))
+	assert.Nil(t, r)
+
+	require.NoError(t, tr.log.WaitFor("route settings rollout promoted", time.Second))
+	require.NoError(t, tr.waitForRouteSetting())
+
+	r, _ = tr.routing.Route(httptest.NewRequest("GET", "/bar", nil))
+	require.NotNil(t, r)
+	assert.Equal(t, "bar", r.Id)
+
+	m.WithCounters(func(c map[string]int64) {
+		assert.Equal(t, int64(1), c["routes.rollout.started"])
+		assert.Equal(t, int64(1), c["routes.rollout.promoted"])
+	})
+}
+
+func TestRolloutRollback(t *testing.T) {
+	dc, err := testdataclient.NewDoc(`foo: Path("/foo") -> <shunt>`)
+	require.NoError(t, err)
+	defer dc.Close()
+
+	m := &metricstest.MockMetrics{}
+	tr := newTestRoutingWithRollout(t, routing.RolloutOptions{
+		Fraction:    1,
+		Duration:    time.Hour,
+		MinRequests: 10,
+	}, m, dc)
+	defer tr.close()
+
+	tr.log.Reset()
+	require.NoError(t, dc.UpdateDoc(`foo: Path("/foo") -> status(500) -> <shunt>`, nil))
+	require.NoError(t, tr.log.WaitFor("route settings rollout started", 12*pollTimeout))
+
+	for range 10 {
+		lookup := tr.routing.Get()
+		require.Equal(t, "foo", lookupRouteId(lookup, "/foo"))
+		lookup.Report(500)
+	}
+
+	require.NoError(t, tr.log.WaitFor("route settings rollout rolled back", time.Second))
+
+	// the new generation is not used anymore
+	lookup := tr.routing.Get()
+	r, _ := lookup.Do(httptest.NewRequest("GET", "/foo", nil))
+	require.NotNil(t, r)
+	assert.Empty(t, r.Filters)
+
+	m.WithCounters(func(c map[string]int64) {
+		assert.Equal(t, int64(1), c["routes.rollout.rolled_back"])
+		assert.Equal(t, int64(0), c["routes.rollout.promoted"])
+	})
+
+	// an unrelated change doesn't roll out the rolled back route again
+	tr.log.Reset()
+	require.NoError(t, dc.UpdateDoc(`bar: Path("/bar") -> <shunt>`, nil))
+	require.NoError(t, tr.log.WaitFor("route settings rollout started", 12*pollTimeout))
+	require.NoError(t, tr.log.WaitFor("ignoring the rolled back definition of route foo", time.Second))
+
+	lookup = tr.routing.Get()
+	assert.Equal(t, "bar", lookupRouteId(lookup, "/bar"))
+	r, _ = lookup.Do(httptest.NewRequest("GET", "/foo", nil))
+	require.NotNil(t, r)
+	assert.Empty(t, r.Filters)
+}
+
+func TestRolloutPostProcessors(t *testing.T) {
+	dc, err := testdataclient.NewDoc(`foo: Path("/foo") -> <shunt>`)
+	require.NoError(t, err)
+	defer dc.Close()
+
+	pp := &rolloutPostProcessor{}
+	tr := newTestRoutingWithRollout(t, routing.RolloutOptions{Fraction: 1, Duration: 100 * time.Millisecond}, &metricstest.MockMetrics{}, dc, pp)
+	defer tr.close()
+
+	processed, rollouts := pp.calls()
+	assert.Equal(t, []string{"foo;"}, processed)
+	assert.Empty(t, rollouts)
+
+	tr.log.Reset()
+	require.NoError(t, dc.UpdateDoc(`bar: Path("/bar") -> <shunt>`, nil))
+	require.NoError(t, tr.log.WaitFor("route settings rollout started", 12*pollTimeout))
+
+	// the canary is prepared without the regular post-processing
+	processed, rollouts = pp.calls()
+	assert.Equal(t, []string{"foo;"}, processed)
+	assert.Len(t, rollouts, 1)
+	assert.Contains(t, rollouts[0], "bar;")
+
+	require.NoError(t, tr.log.WaitFor("route settings rollout promoted", time.Second))
+	require.NoError(t, tr.waitForRouteSetting())
+
+	// the promoted generation is created again
+	processed, rollouts = pp.calls()
+	require.Len(t, processed, 2)
+	assert.Equal(t, rollouts[0], processed[1])
+}
+
+func TestRolloutDisabled(t *testing.T) {
+	dc, err := testdataclient.NewDoc(`foo: Path("/foo") -> <shunt>`)
+	require.NoError(t, err)
+	defer dc.Close()
+
+	tr := newTestRoutingWithRollout(t, routing.RolloutOptions{}, &metricstest.MockMetrics{}, dc)
+	defer tr.close()
+
+	tr.log.Reset()
+	require.NoError(t, dc.UpdateDoc(`bar: Path("/bar") -> <shunt>`, nil))
+	require.NoError(t, tr.waitForRouteSetting())
+
+	r, _ := tr.routing.Route(httptest.NewRequest("GET", "/bar", nil))
+	require.NotNil(t, r)
+
+	// reporting without a rollout has no effect
+	tr.routing.Get().Report(500)
+}
+
+func TestRolloutExplain(t *testing.T) {
+	dc, err := testdataclient.NewDoc(`foo: Path("/foo") -> <shunt>`)
+	require.NoError(t, err)
+	defer dc.Close()
+
+	tr := newTestRoutingWithRollout(t, routing.RolloutOptions{Fraction: 1, Duration: time.Hour}, &metricstest.MockMetrics{}, dc)
+	defer tr.close()
+
+	tr.log.Reset()
+	require.NoError(t, dc.UpdateDoc(`bar: Path("/bar") -> <shunt>`, nil))
+	require.NoError(t, tr.log.WaitFor("route settings rollout started", 12*pollTimeout))
+
+	// every lookup gets the canary, but the explanation is labeled by table
+	for range 3 {
+		w := httptest.NewRecorder()
+		tr.routing.ServeHTTP(w, httptest.NewRequest("POST", "/routes/explain", strings.NewReader(`{"url": "https://www.example.org/bar"}`)))
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var e routing.Explanation
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &e))
+
+		assert.Equal(t, routing.ExplainStableTable, e.Table)
+		assert.Empty(t, e.RouteId)
+		require.NotNil(t, e.Canary)
+		assert.Equal(t, routing.ExplainCanaryTable, e.Canary.Table)
+		assert.Equal(t, "bar", e.Canary.RouteId)
+	}
+}
+
+type closeRecorderSpec struct {
+	mu     sync.Mutex
+	closed map[string]int
+}
+
+type closeRecorder struct {
+	spec *closeRecorderSpec
+	name string
+}
+
+func (s *closeRecorderSpec) Name() string { return "closeRecorder" }
+
+func (s *closeRecorderSpec) CreateFilter(args []interface{}) (filters.Filter, error) {
+	return &closeRecorder{spec: s, name: args[0].(string)}, nil
+}
+
+func (s *closeRecorderSpec) closedCount(name string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closed[name]
+}
+
+func (f *closeRecorder) Request(filters.FilterContext)  {}
+func (f *closeRecorder) Response(filters.FilterContext) {}
+
+func (f *closeRecorder) Close() error {
+	f.spec.mu.Lock()
+	defer f.spec.mu.Unlock()
+	f.spec.closed[f.name]++
+	return nil
+}
+
+func TestRolloutClosesCanaryAfterInFlightRequests(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		options  routing.RolloutOptions
+		update   string
+		decision string
+	}{{
+		name:     "promoted",
+		options:  routing.RolloutOptions{Fraction: 1, Duration: 100 * time.Millisecond},
+		update:   `foo: Path("/foo") -> closeRecorder("canary") -> <shunt>`,
+		decision: "route settings rollout promoted",
+	}, {
+		name:     "rolled back",
+		options:  routing.RolloutOptions{Fraction: 1, Duration: time.Hour, MinRequests: 1},
+		update:   `foo: Path("/foo") -> closeRecorder("canary") -> status(500) -> <shunt>`,
+		decision: "route settings rollout rolled back",
+	}} {
+		t.Run(tt.name, func(t *testing.T) {
+			dc, err := testdataclient.NewDoc(`foo: Path("/foo") -> <shunt>`)
+			require.NoError(t, err)
+			defer dc.Close()
+
+			spec := &closeRecorderSpec{closed: make(map[string]int)}
+			fr := builtin.MakeRegistry()
+			fr.Register(spec)
+
+			tl := loggingtest.New()
+			defer tl.Close()
+
+			rt := routing.New(routing.Options{
+				FilterRegistry: fr,
+				DataClients:    []routing.DataClient{dc},
+				PollTimeout:    pollTimeout,
+				Log:            tl,
+				Metrics:        &metricstest.MockMetrics{},
+				Rollout:        tt.options,
+			})
+			defer rt.Close()
+
+			require.NoError(t, tl.WaitFor("route settings applied", 12*pollTimeout))
+
+			tl.Reset()
+			require.NoError(t, dc.UpdateDoc(tt.update, nil))
+			require.NoError(t, tl.WaitFor("route settings rollout started", 12*pollTimeout))
+
+			// a request routed with the canary is in flight
+			inFlight := rt.Get()
+			require.Equal(t, "foo", lookupRouteId(inFlight, "/foo"))
+
+			failed := rt.Get()
+			failed.Report(500)
+
+			require.NoError(t, tl.WaitFor(tt.decision, 2*time.Second))
+			assert.Equal(t, 0, spec.closedCount("canary"))
+
+			inFlight.Report(200)
+			assert.Equal(t, 1, spec.closedCount("canary"))
+		})
+	}
+}
@@ -139,6 +139,15 @@ type Options struct {
 	// SignalFirstLoad enables signaling on the first load
 	// of the routing configuration during the startup.
 	SignalFirstLoad bool
+
+	// RouteHistorySize sets how many route table generations are
+	// kept in memory and served on /routes/history and /routes/diff.
+	// When zero, no history is kept.
+	RouteHistorySize int
+
+	// Rollout enables the staged rollout of the new route table
+	// generations. See RolloutOptions.
+	Rollout RolloutOptions
 }
 
 // RouteFilter contains extensions to generic filter
@@ -199,6 +208,10 @@ type Route struct {
 	// path predicate matching a subtree
 	pathSubtree string
 
+	// header predicates compiled into the leaf matcher, other than
+	// Header and HeaderRegexp
+	headerConditions []*headerCondition
+
 	// The backend scheme and host.
 	Scheme, Host string
 
@@ -237,6 +250,19 @@ type PostProcessor interface {
 	Do([]*Route) []*Route
 }
 
+// RolloutPostProcessor is implemented by the post-processors, that keep
+// or change shared state, e.g. the scheduler queues or the endpoint
+// registry. During the staged rollout, the new route table generation is
+// processed with DoRollout, which prepares the routes without changing
+// the shared state, because the generation can be rolled back. When the
+// generation is promoted, it is created again and processed with Do.
+// The post-processors not implementing it are applied with Do in both
+// cases.
+type RolloutPostProcessor interface {
+	PostProcessor
+	DoRollout([]*Route) []*Route
+}
+
 // PreProcessor is an interface for custom pre-processors applying changes
 // to the routes before they were created from eskip.Route representation.
 type PreProcessor interface {
@@ -251,7 +277,12 @@ type Routing struct {
 	firstLoad         chan struct{}
 	firstLoadSignaled bool
 	quit              chan struct{}
+	reprocess         chan struct{}
 	metrics           metrics.Metrics
+	history           *routeHistory
+	rolloutOptions    RolloutOptions
+	rollout           atomic.Pointer[rollout]
+	builder           *tableBuilder
 }
 
 // New initializes a routing instance, and starts listening for route
@@ -274,8 +305,22 @@ func New(o Options) *Routing {
 		o.DataClients = slices.Collect(maps.Keys(uniqueClients))
 	}
 
-	r := &Routing{log: o.Log, firstLoad: make(chan struct{}), quit: make(chan struct{})}
+	r := &Routing{
+		log:       o.Log,
+		firstLoad: make(chan struct{}),
+		quit:      make(chan struct{}),
+		reprocess: make(chan struct{}, 1),
+	}
+
 	r.metrics = o.Metrics
+	if o.RouteHistorySize > 0 {
+		r.history = newRouteHistory(o.RouteHistorySize)
+	}
+
+	if o.Rollout.enabled() {
+		r.rolloutOptions = o.Rollout.withDefaults()
+	}
+
 	if !o.SignalFirstLoad {
 		close(r.firstLoad)
 		r.firstLoadSignaled = true
@@ -291,8 +336,27 @@ func New(o Options) *Routing {
 	return r
 }
 
-// ServeHTTP renders the list of current routes.
+// ServeHTTP renders the list of current routes. On /routes/explain, it
+// explains the route lookup of a synthetic request. On /routes/history
+// and /routes/diff, it renders the recent route table generations and
+// the difference between two of them. On /routes/invalid, it renders
+// the rejected routes with the reason of the rejection.
 func (r *Routing) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	switch req.URL.Path {
+	case explainPath:
+		r.serveExplain(w, req)
+		return
+	case historyPath:
+		r.serveHistory(w, req)
+		return
+	case diffPath:
+		r.serveDiff(w, req)
+		return
+	case invalidPath:
+		r.serveInvalid(w, req)
+		return
+	}
+
 	if req.Method != "GET" && req.Method != "HEAD" {
 		w.WriteHeader(http.StatusMethodNotAllowed)
 		return
@@ -352,32 +416,70 @@ func (r *Routing) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	eskip.Fprint(w, extractPretty(req), routes...)
 }
 
+// apply makes the route table the current generation.
+func (r *Routing) apply(o Options, rt *routeTable) {
+	r.routeTable.Store(rt)
+	if r.history != nil {
+		r.history.add(rt)
+	}
+
+	if !r.firstLoadSignaled {
+		if len(rt.clients) == len(o.DataClients) {
+			close(r.firstLoad)
+			r.firstLoadSignaled = true
+		}
+	}
+	r.log.Infof("route settings applied, id: %d", rt.id)
+	if r.metrics != nil { // existing codebases might not supply metrics instance
+		r.metrics.UpdateGauge("routes.total", float64(len(rt.validRoutes)))
+		r.metrics.UpdateGauge("routes.updated_timestamp", float64(rt.created.Unix()))
+		r.metrics.MeasureSince("routes.update_latency", rt.created)
+	}
+}
+
 func (r *Routing) startReceivingUpdates(o Options) {
 	c := make(chan *routeTable)
-	go receiveRouteMatcher(o, c, r.quit)
+	r.builder = newTableBuilder(&o)
+	go receiveRouteMatcher(o, r.builder, c, r.reprocess, r.quit)
 	go func() {
+		// the rollout is checked only when enabled
+		var check <-chan time.Time
+		if r.rolloutOptions.enabled() {
+			t := time.NewTicker(min(rolloutCheckInterval, r.rolloutOptions.Duration/10))
+			defer t.Stop()
+			check = t.C
+		}
+
 		for {
 			select {
 			case rt := <-c:
-				r.routeTable.Store(rt)
-				if !r.firstLoadSignaled {
-					if len(rt.clients) == len(o.DataClients) {
-						close(r.firstLoad)
-						r.firstLoadSignaled = true
-					}
+				if !rt.rollout {
+					r.apply(o, rt)
+					continue
 				}
-				r.log.Infof("route settings applied, id: %d", rt.id)
-				if r.metrics != nil { // existing codebases might not supply metrics instance
-					r.metrics.UpdateGauge("routes.total", float64(len(rt.validRoutes)))
-					r.metrics.UpdateGauge("routes.updated_timestamp", float64(rt.created.Unix()))
-					r.metrics.MeasureSince("routes.update_latency", rt.created)
+
+				// the initial routes of the data clients are applied
+				// at once
+				current := r.routeTable.Load().(*routeTable)
+				if len(current.clients) < len(o.DataClients) {
+					r.apply(o, r.builder.promote(rt))
+					rt.close()
+					continue
 				}
+
+				r.startRollout(rt)
+			case <-check:
+				r.checkRollout(o)
 			case <-r.quit:
 				var rt *routeTable
 				rt, ok := r.routeTable.Load().(*routeTable)
 				if ok {
 					rt.close()
 				}
+
+				if ro := r.rollout.Load(); ro != nil {
+					ro.canary.close()
+				}
 				return
 			}
 		}
@@ -411,7 +513,9 @@ func (r *Routing) FirstLoad() <-chan struct{} {
 // against is found, the feature is experimental and its exported interface may
 // change.
 type RouteLookup struct {
-	rt *routeTable
+	rt      *routeTable
+	stats   *generationStats
+	rollout *rollout
 }
 
 // Do executes the lookup against the captured routing table. Equivalent to
@@ -422,9 +526,23 @@ func (rl *RouteLookup) Do(req *http.Request) (*Route, map[string]string) {
 
 // Get returns a captured generation of the lookup table. This feature is
 // experimental. See the description of the RouteLookup type.
+//
+// During the staged rollout of a new generation, it returns the new
+// generation for the configured fraction of the calls.
 func (r *Routing) Get() *RouteLookup {
 	rt := r.routeTable.Load().(*routeTable)
-	return &RouteLookup{rt: rt}
+	return r.lookup(rt)
+}
+
+// Reprocess builds a new generation of the routing table from the
+// current route definitions, e.g. after the settings of the pre- or
+// post-processors were changed. It doesn't wait until the new
+// generation is applied.
+func (r *Routing) Reprocess() {
+	select {
+	case r.reprocess <- struct{}{}:
+	default:
+	}
 }
 
 // Close closes routing, routeTable and stops statemachine for receiving routes.
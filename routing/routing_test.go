@@ -6,6 +6,7 @@ import (
 	"io"
 	"net/http"
 	"net/url"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -1032,3 +1033,54 @@ func TestDuplicateDataClients(t *testing.T) {
 		t.Errorf("expected 2 dataclients, got: %v", clients)
 	}
 }
+
+type backendPreProcessor struct {
+	backend atomic.Value
+}
+
+func (p *backendPreProcessor) Do(routes []*eskip.Route) []*eskip.Route {
+	for _, r := range routes {
+		r.Backend = p.backend.Load().(string)
+	}
+
+	return routes
+}
+
+func TestReprocess(t *testing.T) {
+	dc := testdataclient.New([]*eskip.Route{{Id: "route1", Path: "/some-path", Backend: "https://www.example.org"}})
+	defer dc.Close()
+
+	pp := &backendPreProcessor{}
+	pp.backend.Store("https://one.example.org")
+
+	tl := loggingtest.New()
+	rt := routing.New(routing.Options{
+		DataClients:   []routing.DataClient{dc},
+		PreProcessors: []routing.PreProcessor{pp},
+		PollTimeout:   pollTimeout,
+		Log:           tl,
+	})
+	tr := &testRouting{tl, rt}
+	defer tr.close()
+
+	if err := tr.waitForRouteSetting(); err != nil {
+		t.Fatal(err)
+	}
+
+	tr.log.Reset()
+	pp.backend.Store("https://two.example.org")
+	rt.Reprocess()
+
+	if err := tr.waitForRouteSetting(); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := tr.checkGetRequest("https://www.example.com/some-path")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if r.Backend != "https://two.example.org" {
+		t.Errorf("expected the reprocessed backend, got: %s", r.Backend)
+	}
+}
@@ -13,3 +13,6 @@ func (r *Registry) GetFifoForTest(s string) (*FifoQueue, bool) {
 	q, ok := r.fifoQueues[id]
 	return q, ok
 }
+
+var ExportNewFairQueue = newFairQueue
+var ExportCloseFairQueue = (*FairQueue).close
@@ -0,0 +1,367 @@
+package scheduler
+
+import (
+	"context"
+	"net/http"
+	"sync"
+
+	"github.com/zalando/skipper/metrics"
+)
+
+// FairQueue objects implement a queue for handling requests with a
+// maximum allowed concurrency and queue size, where the queued requests
+// are grouped by a key, e.g. the tenant, and served deficit round robin
+// by the weight of the key. A single key can not take all the
+// concurrency slots, while other keys are waiting. Currently, they can
+// be used from the fairFifo filter in the filters/scheduler package
+// only.
+type FairQueue struct {
+	config                   Config
+	weights                  map[string]int
+	feedbackHeader           string
+	metrics                  metrics.Metrics
+	activeRequestsMetricsKey string
+	errorFullMetricsKey      string
+	errorOtherMetricsKey     string
+	errorTimeoutMetricsKey   string
+	queuedRequestsMetricsKey string
+
+	mu      sync.Mutex
+	live    Config
+	active  int
+	queued  int
+	closed  bool
+	tenants map[string]*fairTenant
+	ring    []*fairTenant
+	next    int
+}
+
+type fairTenant struct {
+	key     string
+	weight  int
+	deficit int
+	waiters []*fairWaiter
+}
+
+type fairWaiter struct {
+	ready chan struct{}
+	err   error
+}
+
+// FairFilter is the interface that needs to be implemented by the filters that
+// use a fair queue maintained by the registry.
+type FairFilter interface {
+
+	// SetQueue will be used by the registry to pass in the right queue to
+	// the filter.
+	SetQueue(*FairQueue)
+
+	// GetQueue is currently used only by tests.
+	GetQueue() *FairQueue
+
+	// Config will be called by the registry once during processing the
+	// routing to get the right queue settings from the filter.
+	Config() Config
+
+	// Weights returns the weights of the keys. Keys without weight have
+	// the weight 1.
+	Weights() map[string]int
+}
+
+func newFairQueue(c Config, weights map[string]int) *FairQueue {
+	return &FairQueue{
+		config:  c,
+		live:    c,
+		weights: weights,
+		tenants: make(map[string]*fairTenant),
+	}
+}
+
+func (fq *FairQueue) weight(key string) int {
+	if w, ok := fq.weights[key]; ok && w > 0 {
+		return w
+	}
+	return 1
+}
+
+// Wait blocks until a request with the given key can be processed or
+// needs to be rejected. It returns done() and an error. When it can be
+// processed, calling done indicates that it has finished. It is
+// mandatory to call done() the request was processed. When the
+// request needs to be rejected, an error will be returned and done
+// will be nil.
+func (fq *FairQueue) Wait(ctx context.Context, key string) (func(), error) {
+	return fq.WaitWithPriority(ctx, key, PriorityDefault)
+}
+
+// WaitWithPriority is like Wait, but rejects sheddable requests
+// earlier and critical requests later, when the queue fills up.
+func (fq *FairQueue) WaitWithPriority(ctx context.Context, key string, p Priority) (func(), error) {
+	f, err := fq.wait(ctx, key, p)
+	if err != nil && fq.metrics != nil {
+		switch err {
+		case ErrQueueFull:
+			fq.metrics.IncCounter(fq.errorFullMetricsKey)
+		case ErrQueueTimeout:
+			fq.metrics.IncCounter(fq.errorTimeoutMetricsKey)
+		case ErrClientCanceled:
+			// This case is handled in the proxy with status code 499
+		default:
+			fq.metrics.IncCounter(fq.errorOtherMetricsKey)
+		}
+	}
+	return f, err
+}
+
+func (fq *FairQueue) wait(ctx context.Context, key string, p Priority) (func(), error) {
+	if err := ctx.Err(); err != nil {
+		return nil, contextError(err)
+	}
+
+	fq.mu.Lock()
+	if fq.closed {
+		fq.mu.Unlock()
+		return nil, ErrQueueClosed
+	}
+
+	if fq.active < fq.live.MaxConcurrency && fq.queued == 0 {
+		fq.active++
+		fq.mu.Unlock()
+		return fq.release, nil
+	}
+
+	if int64(fq.queued) >= p.queueLimit(int64(fq.live.MaxQueueSize)) {
+		fq.mu.Unlock()
+		return nil, ErrQueueFull
+	}
+
+	w := &fairWaiter{ready: make(chan struct{})}
+	t, ok := fq.tenants[key]
+	if !ok {
+		t = &fairTenant{key: key, weight: fq.weight(key)}
+		fq.tenants[key] = t
+	}
+	if len(t.waiters) == 0 {
+		fq.ring = append(fq.ring, t)
+	}
+	t.waiters = append(t.waiters, w)
+	fq.queued++
+	timeout := fq.live.Timeout
+	fq.mu.Unlock()
+
+	c, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	select {
+	case <-w.ready:
+		return w.granted(fq.release)
+	case <-c.Done():
+	}
+
+	fq.mu.Lock()
+	defer fq.mu.Unlock()
+
+	select {
+	case <-w.ready:
+		// granted or closed while timing out
+		return w.granted(fq.release)
+	default:
+	}
+
+	fq.remove(t, w)
+	return nil, contextError(c.Err())
+}
+
+// granted returns the release function, or the error, when the queue
+// was closed while waiting.
+func (w *fairWaiter) granted(release func()) (func(), error) {
+	if w.err != nil {
+		return nil, w.err
+	}
+
+	return release, nil
+}
+
+func contextError(err error) error {
+	switch err {
+	case context.DeadlineExceeded:
+		return ErrQueueTimeout
+	case context.Canceled:
+		return ErrClientCanceled
+	default:
+		return err
+	}
+}
+
+// remove drops a waiter that gave up. It has to be called with the lock held.
+func (fq *FairQueue) remove(t *fairTenant, w *fairWaiter) {
+	for i, wi := range t.waiters {
+		if wi == w {
+			t.waiters = append(t.waiters[:i], t.waiters[i+1:]...)
+			fq.queued--
+			break
+		}
+	}
+
+	if len(t.waiters) == 0 {
+		fq.removeFromRing(t)
+	}
+}
+
+func (fq *FairQueue) removeFromRing(t *fairTenant) {
+	for i, ti := range fq.ring {
+		if ti == t {
+			fq.ring = append(fq.ring[:i], fq.ring[i+1:]...)
+			if i < fq.next {
+				fq.next--
+			}
+			break
+		}
+	}
+
+	t.deficit = 0
+	delete(fq.tenants, t.key)
+}
+
+// dequeue selects the next waiter by deficit round robin. Every key
+// can serve as many requests as its weight in one round. It has to be
+// called with the lock held.
+func (fq *FairQueue) dequeue() *fairWaiter {
+	if len(fq.ring) == 0 {
+		return nil
+	}
+
+	if fq.next >= len(fq.ring) {
+		fq.next = 0
+	}
+
+	t := fq.ring[fq.next]
+	if t.deficit <= 0 {
+		t.deficit += t.weight
+	}
+
+	w := t.waiters[0]
+	t.waiters = t.waiters[1:]
+	t.deficit--
+	fq.queued--
+
+	if len(t.waiters) == 0 {
+		fq.removeFromRing(t)
+	} else if t.deficit <= 0 {
+		fq.next++
+	}
+
+	return w
+}
+
+func (fq *FairQueue) release() {
+	fq.mu.Lock()
+	defer fq.mu.Unlock()
+
+	fq.active--
+	fq.dispatch()
+}
+
+// dispatch grants the free concurrency slots to the waiters. It has to
+// be called with the lock held.
+func (fq *FairQueue) dispatch() {
+	for fq.active < fq.live.MaxConcurrency {
+		w := fq.dequeue()
+		if w == nil {
+			return
+		}
+
+		fq.active++
+		close(w.ready)
+	}
+}
+
+// Status returns the current status of a queue.
+func (fq *FairQueue) Status() QueueStatus {
+	fq.mu.Lock()
+	defer fq.mu.Unlock()
+
+	return QueueStatus{
+		ActiveRequests: fq.active,
+		QueuedRequests: fq.queued,
+		Closed:         fq.closed,
+	}
+}
+
+// Config returns the configuration that the queue was created with.
+func (fq *FairQueue) Config() Config {
+	fq.mu.Lock()
+	defer fq.mu.Unlock()
+	return fq.config
+}
+
+// Weights returns the weights of the keys that the queue was created with.
+func (fq *FairQueue) Weights() map[string]int {
+	fq.mu.Lock()
+	defer fq.mu.Unlock()
+	return fq.weights
+}
+
+// LiveConfig returns the settings currently applied to the queue. They
+// differ from Config, when the queue was tuned.
+func (fq *FairQueue) LiveConfig() Config {
+	fq.mu.Lock()
+	defer fq.mu.Unlock()
+	return fq.live
+}
+
+// Tune changes the settings currently applied to the queue, without
+// changing the configuration provided by the route. The next route
+// update with a changed configuration overrides it.
+func (fq *FairQueue) Tune(c Config) {
+	fq.mu.Lock()
+	defer fq.mu.Unlock()
+	fq.live = c
+	fq.dispatch()
+}
+
+// Feedback tunes the MaxConcurrency of the queue from the backend
+// response, when the registry has the feedback mode enabled.
+func (fq *FairQueue) Feedback(rsp *http.Response) {
+	fq.mu.Lock()
+	defer fq.mu.Unlock()
+	if c, ok := feedback(fq.feedbackHeader, rsp, fq.config, fq.live); ok {
+		fq.live = c
+		fq.dispatch()
+	}
+}
+
+// reconfigure applies a changed route configuration. The keys keep
+// their queued requests.
+func (fq *FairQueue) reconfigure(c Config, weights map[string]int) {
+	fq.mu.Lock()
+	defer fq.mu.Unlock()
+	fq.config = c
+	fq.live = c
+	fq.weights = weights
+	for _, t := range fq.tenants {
+		t.weight = fq.weight(t.key)
+	}
+	fq.dispatch()
+}
+
+// close rejects the waiting and the new requests with ErrQueueClosed.
+// The active requests finish normally.
+func (fq *FairQueue) close() {
+	fq.mu.Lock()
+	defer fq.mu.Unlock()
+	fq.closed = true
+	for _, t := range fq.ring {
+		for _, w := range t.waiters {
+			w.err = ErrQueueClosed
+			close(w.ready)
+		}
+
+		t.waiters = nil
+	}
+
+	fq.ring = nil
+	fq.next = 0
+	fq.queued = 0
+	clear(fq.tenants)
+}
@@ -0,0 +1,158 @@
+package scheduler_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zalando/skipper/scheduler"
+)
+
+func waitQueued(t *testing.T, q *scheduler.FairQueue, n int) {
+	t.Helper()
+	require.Eventually(t, func() bool {
+		return q.Status().QueuedRequests == n
+	}, time.Second, time.Millisecond)
+}
+
+func TestFairQueueRoundRobin(t *testing.T) {
+	q := scheduler.ExportNewFairQueue(scheduler.Config{
+		MaxConcurrency: 1,
+		MaxQueueSize:   100,
+		Timeout:        5 * time.Second,
+	}, map[string]int{"quiet": 2})
+
+	done, err := q.Wait(context.Background(), "first")
+	require.NoError(t, err)
+
+	type grant struct {
+		key  string
+		done func()
+	}
+	granted := make(chan grant)
+
+	enqueue := func(key string) {
+		go func() {
+			d, err := q.Wait(context.Background(), key)
+			if err != nil {
+				t.Errorf("Failed to wait: %v", err)
+				return
+			}
+			granted <- grant{key, d}
+		}()
+	}
+
+	queued := 0
+	for _, key := range []string{"noisy", "noisy", "noisy", "noisy", "noisy", "quiet", "quiet"} {
+		enqueue(key)
+		queued++
+		waitQueued(t, q, queued)
+	}
+
+	done()
+
+	var order []string
+	for range queued {
+		g := <-granted
+		order = append(order, g.key)
+		assert.Equal(t, 1, q.Status().ActiveRequests)
+		g.done()
+	}
+
+	assert.Equal(t, []string{"noisy", "quiet", "quiet", "noisy", "noisy", "noisy", "noisy"}, order)
+	assert.Equal(t, scheduler.QueueStatus{}, q.Status())
+}
+
+func TestFairQueueErrors(t *testing.T) {
+	q := scheduler.ExportNewFairQueue(scheduler.Config{
+		MaxConcurrency: 1,
+		MaxQueueSize:   1,
+		Timeout:        10 * time.Millisecond,
+	}, nil)
+
+	done, err := q.Wait(context.Background(), "a")
+	require.NoError(t, err)
+
+	_, err = q.Wait(context.Background(), "a")
+	assert.Equal(t, scheduler.ErrQueueTimeout, err)
+	assert.Equal(t, 0, q.Status().QueuedRequests)
+
+	q.Tune(scheduler.Config{MaxConcurrency: 1, MaxQueueSize: 1, Timeout: time.Second})
+
+	errs := make(chan error)
+	go func() {
+		_, err := q.Wait(context.Background(), "b")
+		errs <- err
+	}()
+	waitQueued(t, q, 1)
+
+	_, err = q.Wait(context.Background(), "c")
+	assert.Equal(t, scheduler.ErrQueueFull, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = q.Wait(ctx, "c")
+	assert.Equal(t, scheduler.ErrClientCanceled, err)
+
+	done()
+	assert.NoError(t, <-errs)
+	assert.Equal(t, scheduler.QueueStatus{ActiveRequests: 1}, q.Status())
+}
+
+func TestFairQueueTuneDispatches(t *testing.T) {
+	q := scheduler.ExportNewFairQueue(scheduler.Config{
+		MaxConcurrency: 1,
+		MaxQueueSize:   10,
+		Timeout:        5 * time.Second,
+	}, nil)
+
+	_, err := q.Wait(context.Background(), "a")
+	require.NoError(t, err)
+
+	errs := make(chan error)
+	go func() {
+		_, err := q.Wait(context.Background(), "b")
+		errs <- err
+	}()
+	waitQueued(t, q, 1)
+
+	q.Tune(scheduler.Config{MaxConcurrency: 2, MaxQueueSize: 10, Timeout: 5 * time.Second})
+
+	assert.NoError(t, <-errs)
+	assert.Equal(t, scheduler.QueueStatus{ActiveRequests: 2}, q.Status())
+	assert.Equal(t, 1, q.Config().MaxConcurrency)
+}
+
+func TestFairQueueClose(t *testing.T) {
+	q := scheduler.ExportNewFairQueue(scheduler.Config{
+		MaxConcurrency: 1,
+		MaxQueueSize:   10,
+		Timeout:        5 * time.Second,
+	}, nil)
+
+	done, err := q.Wait(context.Background(), "a")
+	require.NoError(t, err)
+
+	errs := make(chan error)
+	for _, key := range []string{"a", "b"} {
+		go func() {
+			_, err := q.Wait(context.Background(), key)
+			errs <- err
+		}()
+	}
+	waitQueued(t, q, 2)
+
+	scheduler.ExportCloseFairQueue(q)
+
+	assert.ErrorIs(t, <-errs, scheduler.ErrQueueClosed)
+	assert.ErrorIs(t, <-errs, scheduler.ErrQueueClosed)
+
+	_, err = q.Wait(context.Background(), "c")
+	assert.ErrorIs(t, err, scheduler.ErrQueueClosed)
+
+	done()
+	assert.Equal(t, scheduler.QueueStatus{Closed: true}, q.Status())
+}
@@ -0,0 +1,233 @@
+package scheduler_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zalando/skipper/filters/builtin"
+	"github.com/zalando/skipper/routing"
+	"github.com/zalando/skipper/routing/testdataclient"
+	"github.com/zalando/skipper/scheduler"
+)
+
+func newFeedbackRouting(t *testing.T, o scheduler.Options, doc string) (*scheduler.Registry, *routing.Routing) {
+	t.Helper()
+
+	cli, err := testdataclient.NewDoc(doc)
+	require.NoError(t, err)
+
+	reg := scheduler.RegistryWith(o)
+	t.Cleanup(reg.Close)
+
+	rt := routing.New(routing.Options{
+		SignalFirstLoad: true,
+		FilterRegistry:  builtin.MakeRegistry(),
+		DataClients:     []routing.DataClient{cli},
+		PostProcessors:  []routing.PostProcessor{reg},
+	})
+	t.Cleanup(rt.Close)
+	<-rt.FirstLoad()
+
+	return reg, rt
+}
+
+func TestFeedback(t *testing.T) {
+	_, rt := newFeedbackRouting(t, scheduler.Options{FeedbackHeader: "X-Concurrency"}, `
+		fifo: Path("/fifo") -> fifo(10, 5, "1s") -> <shunt>;
+		lifo: Path("/lifo") -> lifo(10, 5, "1s") -> <shunt>;
+	`)
+
+	type feedbackQueue interface {
+		Config() scheduler.Config
+		LiveConfig() scheduler.Config
+		Feedback(*http.Response)
+	}
+
+	for _, p := range []string{"/fifo", "/lifo"} {
+		t.Run(p, func(t *testing.T) {
+			r, _ := rt.Route(&http.Request{URL: &url.URL{Path: p}})
+			require.NotNil(t, r)
+
+			var q feedbackQueue
+			for _, f := range r.Filters {
+				switch ff := f.Filter.(type) {
+				case scheduler.FIFOFilter:
+					q = ff.GetQueue()
+				case scheduler.LIFOFilter:
+					q = ff.GetQueue()
+				}
+			}
+			require.NotNil(t, q)
+
+			for _, ti := range []struct {
+				value    string
+				expected int
+			}{
+				{"3", 3},
+				{"invalid", 3},
+				{"", 3},
+				{"0", 1},
+				{"100", 10},
+				{"7", 7},
+			} {
+				rsp := &http.Response{Header: make(http.Header)}
+				if ti.value != "" {
+					rsp.Header.Set("X-Concurrency", ti.value)
+				}
+
+				q.Feedback(rsp)
+
+				assert.Equal(t, ti.expected, q.LiveConfig().MaxConcurrency, "header value %q", ti.value)
+				assert.Equal(t, 10, q.Config().MaxConcurrency, "route config should not change")
+				assert.Empty(t, rsp.Header.Get("X-Concurrency"), "feedback header should be removed")
+			}
+		})
+	}
+}
+
+func TestFeedbackLowersConcurrency(t *testing.T) {
+	reg, _ := newFeedbackRouting(t, scheduler.Options{FeedbackHeader: "X-Concurrency"}, `fifo: * -> fifo(2, 5, "1s") -> <shunt>`)
+
+	q, ok := reg.GetFifoForTest("fifo")
+	require.True(t, ok)
+
+	done1, err := q.Wait(context.Background())
+	require.NoError(t, err)
+	done2, err := q.Wait(context.Background())
+	require.NoError(t, err)
+
+	for range 3 {
+		q.Feedback(&http.Response{Header: http.Header{"X-Concurrency": []string{"1"}}})
+	}
+
+	admitted := make(chan func())
+	go func() {
+		done, err := q.Wait(context.Background())
+		assert.NoError(t, err)
+		admitted <- done
+	}()
+
+	done1()
+	select {
+	case <-admitted:
+		t.Fatal("request admitted above the lowered concurrency")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	done2()
+	select {
+	case done := <-admitted:
+		done()
+	case <-time.After(time.Second):
+		t.Fatal("request not admitted after the active requests finished")
+	}
+
+	assert.Equal(t, scheduler.QueueStatus{}, q.Status())
+}
+
+func TestFeedbackDisabled(t *testing.T) {
+	reg, _ := newFeedbackRouting(t, scheduler.Options{}, `fifo: * -> fifo(10, 5, "1s") -> <shunt>`)
+
+	q, ok := reg.GetFifoForTest("fifo")
+	require.True(t, ok)
+
+	rsp := &http.Response{Header: http.Header{"X-Concurrency": []string{"3"}}}
+	q.Feedback(rsp)
+
+	assert.Equal(t, 10, q.LiveConfig().MaxConcurrency)
+	assert.Equal(t, "3", rsp.Header.Get("X-Concurrency"))
+}
+
+func TestRegistryServeHTTP(t *testing.T) {
+	reg, _ := newFeedbackRouting(t, scheduler.Options{EnableLiveConfig: true}, `
+		fifo: Path("/fifo") -> fifo(10, 5, "1s") -> <shunt>;
+		lifo: Path("/lifo") -> lifo(10, 5, "1s") -> <shunt>;
+		group: Path("/group") -> lifoGroup("g", 20, 5, "2s") -> <shunt>;
+	`)
+
+	type settings struct {
+		MaxConcurrency int    `json:"maxConcurrency"`
+		MaxQueueSize   int    `json:"maxQueueSize"`
+		Timeout        string `json:"timeout"`
+	}
+
+	type info struct {
+		Type   string   `json:"type"`
+		Name   string   `json:"name"`
+		Group  bool     `json:"group"`
+		Config settings `json:"config"`
+		Live   settings `json:"live"`
+	}
+
+	list := func() []info {
+		w := httptest.NewRecorder()
+		reg.ServeHTTP(w, httptest.NewRequest("GET", "/queues", nil))
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+
+		var infos []info
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&infos))
+		return infos
+	}
+
+	infos := list()
+	require.Len(t, infos, 3)
+	assert.Equal(t, info{"fifo", "fifo", false, settings{10, 5, "1s"}, settings{10, 5, "1s"}}, infos[0])
+	assert.Equal(t, info{"lifo", "g", true, settings{20, 5, "2s"}, settings{20, 5, "2s"}}, infos[1])
+	assert.Equal(t, info{"lifo", "lifo", false, settings{10, 5, "1s"}, settings{10, 5, "1s"}}, infos[2])
+
+	post := func(body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		reg.ServeHTTP(w, httptest.NewRequest("POST", "/queues", strings.NewReader(body)))
+		return w
+	}
+
+	w := post(`{"type": "fifo", "name": "fifo", "maxConcurrency": 3, "timeout": "500ms"}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	w = post(`{"type": "lifo", "name": "g", "group": true, "maxQueueSize": 50}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	infos = list()
+	assert.Equal(t, settings{3, 5, "500ms"}, infos[0].Live)
+	assert.Equal(t, settings{10, 5, "1s"}, infos[0].Config)
+	assert.Equal(t, settings{20, 50, "2s"}, infos[1].Live)
+
+	q, ok := reg.GetFifoForTest("fifo")
+	require.True(t, ok)
+	assert.Equal(t, 500*time.Millisecond, q.LiveConfig().Timeout)
+
+	w = post(`{"type": "fifo", "name": "fifo", "reset": true}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, settings{10, 5, "1s"}, list()[0].Live)
+
+	assert.Equal(t, http.StatusNotFound, post(`{"type": "fifo", "name": "unknown"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, post(`{"type": "foo", "name": "fifo"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, post(`{"type": "fifo", "name": "fifo", "timeout": "foo"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, post(`{"type": "fifo", "name": "fifo", "maxConcurrency": -1}`).Code)
+	assert.Equal(t, http.StatusBadRequest, post(`not json`).Code)
+
+	w = httptest.NewRecorder()
+	reg.ServeHTTP(w, httptest.NewRequest("DELETE", "/queues", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+}
+
+func TestRegistryServeHTTPLiveConfigDisabled(t *testing.T) {
+	reg, _ := newFeedbackRouting(t, scheduler.Options{}, `fifo: * -> fifo(10, 5, "1s") -> <shunt>`)
+
+	w := httptest.NewRecorder()
+	reg.ServeHTTP(w, httptest.NewRequest("POST", "/queues", strings.NewReader(`{"type": "fifo", "name": "fifo", "maxConcurrency": 3}`)))
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+
+	q, _ := reg.GetFifoForTest("fifo")
+	assert.Equal(t, 10, q.LiveConfig().MaxConcurrency)
+}
@@ -0,0 +1,85 @@
+package scheduler
+
+import (
+	"container/list"
+	"context"
+	"sync"
+)
+
+// limiter is a FIFO semaphore of weight 1, whose limit can be changed
+// while it is held. Lowering the limit doesn't affect the holders, but
+// no waiter is admitted until the number of the holders drops below the
+// new limit.
+type limiter struct {
+	mu      sync.Mutex
+	limit   int64
+	active  int64
+	waiters list.List // of chan struct{}
+}
+
+func newLimiter(limit int64) *limiter {
+	return &limiter{limit: limit}
+}
+
+// acquire blocks until the limiter admits the caller, or the context
+// is done. On error, the limiter is not held.
+func (l *limiter) acquire(ctx context.Context) error {
+	l.mu.Lock()
+	if l.active < l.limit && l.waiters.Len() == 0 {
+		l.active++
+		l.mu.Unlock()
+		return nil
+	}
+
+	ready := make(chan struct{})
+	elem := l.waiters.PushBack(ready)
+	l.mu.Unlock()
+
+	select {
+	case <-ready:
+		return nil
+	case <-ctx.Done():
+		l.mu.Lock()
+		defer l.mu.Unlock()
+
+		select {
+		case <-ready:
+			// admitted after the context was done, giving it back
+			l.active--
+		default:
+			l.waiters.Remove(elem)
+		}
+
+		l.notify()
+		return ctx.Err()
+	}
+}
+
+func (l *limiter) release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.active--
+	l.notify()
+}
+
+func (l *limiter) setLimit(limit int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.limit = limit
+	l.notify()
+}
+
+// notify admits the waiters, in order, while the limit allows. It
+// needs to be called with the lock held.
+func (l *limiter) notify() {
+	for l.active < l.limit {
+		front := l.waiters.Front()
+		if front == nil {
+			return
+		}
+
+		l.waiters.Remove(front)
+		l.active++
+		close(front.Value.(chan struct{}))
+	}
+}
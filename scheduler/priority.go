@@ -0,0 +1,74 @@
+package scheduler
+
+import "fmt"
+
+// PriorityKey is used during routing to pass the priority of the
+// request from the priority filter to the queues and load shedders.
+const PriorityKey = "priority"
+
+// Priority classifies requests for load shedding. Under overload,
+// requests with a lower priority are rejected first. The zero value is
+// PriorityDefault.
+type Priority int
+
+const (
+	// PrioritySheddable requests, e.g. bulk crawlers, are rejected first.
+	PrioritySheddable Priority = iota - 1
+
+	// PriorityDefault is used for requests without a priority.
+	PriorityDefault
+
+	// PriorityCritical requests, e.g. health checks and checkout flows,
+	// are rejected last.
+	PriorityCritical
+)
+
+func (p Priority) String() string {
+	switch p {
+	case PrioritySheddable:
+		return "sheddable"
+	case PriorityDefault:
+		return "default"
+	case PriorityCritical:
+		return "critical"
+	}
+	return "unknown"
+}
+
+// ParsePriority parses the name of a priority class.
+func ParsePriority(s string) (Priority, error) {
+	switch s {
+	case "sheddable":
+		return PrioritySheddable, nil
+	case "default":
+		return PriorityDefault, nil
+	case "critical":
+		return PriorityCritical, nil
+	}
+	return PriorityDefault, fmt.Errorf("invalid priority: %q", s)
+}
+
+// GetPriority returns the priority of the request stored in the state
+// bag, or PriorityDefault when not set.
+func GetPriority(stateBag map[string]interface{}) Priority {
+	p, _ := stateBag[PriorityKey].(Priority)
+	return p
+}
+
+// criticalReserve is the fraction of the queue that only critical
+// requests can use.
+const criticalReserve = 4
+
+// queueLimit returns the queue size available for the priority, within
+// the configured maximum. Sheddable requests can use half of the queue,
+// so that they are rejected first, and the last quarter of the queue is
+// reserved for critical requests, so that they are rejected last.
+func (p Priority) queueLimit(maxQueueSize int64) int64 {
+	switch p {
+	case PrioritySheddable:
+		return maxQueueSize / 2
+	case PriorityCritical:
+		return maxQueueSize
+	}
+	return maxQueueSize - maxQueueSize/criticalReserve
+}
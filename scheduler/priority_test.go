@@ -0,0 +1,69 @@
+package scheduler_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zalando/skipper/scheduler"
+)
+
+func TestParsePriority(t *testing.T) {
+	for _, p := range []scheduler.Priority{
+		scheduler.PrioritySheddable,
+		scheduler.PriorityDefault,
+		scheduler.PriorityCritical,
+	} {
+		parsed, err := scheduler.ParsePriority(p.String())
+		require.NoError(t, err)
+		assert.Equal(t, p, parsed)
+	}
+
+	_, err := scheduler.ParsePriority("urgent")
+	assert.Error(t, err)
+
+	assert.Equal(t, scheduler.PriorityDefault, scheduler.GetPriority(map[string]interface{}{}))
+	assert.Equal(t, scheduler.PriorityCritical, scheduler.GetPriority(map[string]interface{}{
+		scheduler.PriorityKey: scheduler.PriorityCritical,
+	}))
+}
+
+func TestFairQueuePriority(t *testing.T) {
+	for _, tt := range []struct {
+		priority   scheduler.Priority
+		wantQueued int
+	}{
+		{priority: scheduler.PrioritySheddable, wantQueued: 2},
+		{priority: scheduler.PriorityDefault, wantQueued: 3},
+		{priority: scheduler.PriorityCritical, wantQueued: 4},
+	} {
+		t.Run(tt.priority.String(), func(t *testing.T) {
+			q := scheduler.ExportNewFairQueue(scheduler.Config{
+				MaxConcurrency: 1,
+				MaxQueueSize:   4,
+				Timeout:        5 * time.Second,
+			}, nil)
+
+			done, err := q.Wait(context.Background(), "key")
+			require.NoError(t, err)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			for i := range tt.wantQueued {
+				go q.WaitWithPriority(ctx, "key", tt.priority)
+				waitQueued(t, q, i+1)
+			}
+
+			_, err = q.WaitWithPriority(ctx, "key", tt.priority)
+			assert.Equal(t, scheduler.ErrQueueFull, err)
+
+			cancel()
+			waitQueued(t, q, 0)
+			done()
+		})
+	}
+}
@@ -4,8 +4,13 @@ package scheduler
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"maps"
+	"net/http"
+	"sort"
+	"strconv"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -16,7 +21,6 @@ import (
 	"github.com/zalando/skipper/filters"
 	"github.com/zalando/skipper/metrics"
 	"github.com/zalando/skipper/routing"
-	"golang.org/x/sync/semaphore"
 )
 
 // note: Config must stay comparable because it is used to detect changes in route specific LIFO config
@@ -32,6 +36,9 @@ var (
 	ErrQueueFull      = errors.New("queue full")
 	ErrQueueTimeout   = errors.New("queue timeout")
 	ErrClientCanceled = errors.New("client canceled")
+	ErrQueueClosed    = errors.New("queue closed")
+
+	errQueueNotFound = errors.New("queue not found")
 )
 
 // Config can be used to provide configuration of the registry.
@@ -73,6 +80,9 @@ type QueueStatus struct {
 type Queue struct {
 	queue                    *jobqueue.Stack
 	config                   Config
+	feedbackHeader           string
+	mu                       sync.Mutex
+	live                     Config
 	metrics                  metrics.Metrics
 	activeRequestsMetricsKey string
 	errorFullMetricsKey      string
@@ -88,6 +98,7 @@ type Queue struct {
 type FifoQueue struct {
 	queue                    *fifoQueue
 	config                   Config
+	feedbackHeader           string
 	metrics                  metrics.Metrics
 	activeRequestsMetricsKey string
 	errorFullMetricsKey      string
@@ -99,7 +110,7 @@ type FifoQueue struct {
 type fifoQueue struct {
 	mu             sync.RWMutex
 	counter        *atomic.Int64
-	sem            *semaphore.Weighted
+	sem            *limiter
 	timeout        time.Duration
 	maxQueueSize   int64
 	maxConcurrency int64
@@ -142,11 +153,34 @@ func (fq *fifoQueue) reconfigure(c Config) {
 	fq.maxConcurrency = int64(c.MaxConcurrency)
 	fq.maxQueueSize = int64(c.MaxQueueSize)
 	fq.timeout = c.Timeout
-	fq.sem = semaphore.NewWeighted(int64(c.MaxConcurrency))
+	fq.sem = newLimiter(int64(c.MaxConcurrency))
 	fq.counter = new(atomic.Int64)
 }
 
-func (fq *fifoQueue) wait(ctx context.Context) (func(), error) {
+// tune changes the live settings, but in contrast to reconfigure it
+// keeps the requests already in the queue. When the concurrency is
+// lowered, no queued request is admitted until the number of the active
+// requests drops below the new limit.
+func (fq *fifoQueue) tune(c Config) {
+	fq.mu.Lock()
+	defer fq.mu.Unlock()
+	fq.sem.setLimit(int64(c.MaxConcurrency))
+	fq.maxConcurrency = int64(c.MaxConcurrency)
+	fq.maxQueueSize = int64(c.MaxQueueSize)
+	fq.timeout = c.Timeout
+}
+
+func (fq *fifoQueue) liveConfig() Config {
+	fq.mu.RLock()
+	defer fq.mu.RUnlock()
+	return Config{
+		MaxConcurrency: int(fq.maxConcurrency),
+		MaxQueueSize:   int(fq.maxQueueSize),
+		Timeout:        fq.timeout,
+	}
+}
+
+func (fq *fifoQueue) wait(ctx context.Context, p Priority) (func(), error) {
 	fq.mu.RLock()
 	maxConcurrency := fq.maxConcurrency
 	maxQueueSize := fq.maxQueueSize
@@ -172,7 +206,7 @@ func (fq *fifoQueue) wait(ctx context.Context) (func(), error) {
 	// handle queue
 	all := cnt.Add(1)
 	// queue full?
-	if all > maxConcurrency+maxQueueSize {
+	if all > maxConcurrency+p.queueLimit(maxQueueSize) {
 		cnt.Add(-1)
 		return nil, ErrQueueFull
 	}
@@ -182,7 +216,7 @@ func (fq *fifoQueue) wait(ctx context.Context) (func(), error) {
 	defer done()
 
 	// limit concurrency
-	if err := sem.Acquire(c, 1); err != nil {
+	if err := sem.acquire(c); err != nil {
 		cnt.Add(-1)
 		switch err {
 		case context.DeadlineExceeded:
@@ -198,7 +232,7 @@ func (fq *fifoQueue) wait(ctx context.Context) (func(), error) {
 	return func() {
 		// postpone release to Response() filter
 		cnt.Add(-1)
-		sem.Release(1)
+		sem.release()
 	}, nil
 
 }
@@ -219,6 +253,16 @@ type Options struct {
 
 	// Metrics must be provided to the registry in order to collect the FIFO and LIFO metrics.
 	Metrics metrics.Metrics
+
+	// FeedbackHeader enables the backend feedback mode, when set. Backends
+	// can respond with this header set to an integer to lower or raise the
+	// MaxConcurrency of the queue between 1 and the MaxConcurrency
+	// configured by the route. The header is removed from the response.
+	FeedbackHeader string
+
+	// EnableLiveConfig allows changing the live settings of the queues
+	// via the HTTP handler of the registry, without a route update.
+	EnableLiveConfig bool
 }
 
 // Registry maintains a set of LIFO queues. It is used to preserve LIFO queue instances
@@ -240,6 +284,8 @@ type Registry struct {
 	lifoDeleted map[*Queue]time.Time
 	fifoQueues  map[queueId]*FifoQueue
 	fifoDeleted map[*FifoQueue]time.Time
+	fairQueues  map[queueId]*FairQueue
+	fairDeleted map[*FairQueue]time.Time
 }
 
 type queueId struct {
@@ -302,7 +348,13 @@ type GroupedLIFOFilter interface {
 // request needs to be rejected, an error will be returned and done
 // will be nil.
 func (fq *FifoQueue) Wait(ctx context.Context) (func(), error) {
-	f, err := fq.queue.wait(ctx)
+	return fq.WaitWithPriority(ctx, PriorityDefault)
+}
+
+// WaitWithPriority is like Wait, but rejects sheddable requests
+// earlier and critical requests later, when the queue fills up.
+func (fq *FifoQueue) WaitWithPriority(ctx context.Context, p Priority) (func(), error) {
+	f, err := fq.queue.wait(ctx, p)
 	if err != nil && fq.metrics != nil {
 		switch err {
 		case ErrQueueFull:
@@ -335,6 +387,30 @@ func (fq *FifoQueue) Reconfigure(c Config) {
 	fq.queue.reconfigure(c)
 }
 
+// LiveConfig returns the settings currently applied to the queue. They
+// differ from Config, when the queue was tuned.
+func (fq *FifoQueue) LiveConfig() Config {
+	c := fq.queue.liveConfig()
+	c.CloseTimeout = fq.config.CloseTimeout
+	return c
+}
+
+// Tune changes the settings currently applied to the queue, without
+// changing the configuration provided by the route. The next route
+// update with a changed configuration overrides it.
+func (fq *FifoQueue) Tune(c Config) {
+	fq.queue.tune(c)
+}
+
+// Feedback tunes the MaxConcurrency of the queue from the backend
+// response, when the registry has the feedback mode enabled.
+func (fq *FifoQueue) Feedback(rsp *http.Response) {
+	c, ok := feedback(fq.feedbackHeader, rsp, fq.config, fq.LiveConfig())
+	if ok {
+		fq.Tune(c)
+	}
+}
+
 func (fq *FifoQueue) close() {
 	fq.queue.close()
 }
@@ -344,6 +420,23 @@ func (fq *FifoQueue) close() {
 // It is mandatory to call done() the request was processed. When the
 // request needs to be rejected, an error will be returned.
 func (q *Queue) Wait() (done func(), err error) {
+	return q.WaitWithPriority(PriorityDefault)
+}
+
+// WaitWithPriority is like Wait, but rejects sheddable requests
+// earlier, when the queue fills up. The size of the LIFO queue is
+// fixed, so critical requests are treated like default ones.
+func (q *Queue) WaitWithPriority(p Priority) (done func(), err error) {
+	if p == PrioritySheddable {
+		limit := p.queueLimit(int64(q.LiveConfig().MaxQueueSize))
+		if int64(q.Status().QueuedRequests) >= limit {
+			if q.metrics != nil {
+				q.metrics.IncCounter(q.errorFullMetricsKey)
+			}
+			return nil, jobqueue.ErrStackFull
+		}
+	}
+
 	done, err = q.queue.Wait()
 	if q.metrics != nil && err != nil {
 		switch err {
@@ -375,13 +468,68 @@ func (q *Queue) Config() Config {
 }
 
 func (q *Queue) reconfigure() {
+	q.Tune(q.config)
+}
+
+// LiveConfig returns the settings currently applied to the queue. They
+// differ from Config, when the queue was tuned.
+func (q *Queue) LiveConfig() Config {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.live
+}
+
+// Tune changes the settings currently applied to the queue, without
+// changing the configuration provided by the route. The next route
+// update with a changed configuration overrides it.
+func (q *Queue) Tune(c Config) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.live = c
 	q.queue.Reconfigure(jobqueue.Options{
-		MaxConcurrency: q.config.MaxConcurrency,
-		MaxStackSize:   q.config.MaxQueueSize,
-		Timeout:        q.config.Timeout,
+		MaxConcurrency: c.MaxConcurrency,
+		MaxStackSize:   c.MaxQueueSize,
+		Timeout:        c.Timeout,
 	})
 }
 
+// Feedback tunes the MaxConcurrency of the queue from the backend
+// response, when the registry has the feedback mode enabled.
+func (q *Queue) Feedback(rsp *http.Response) {
+	c, ok := feedback(q.feedbackHeader, rsp, q.config, q.LiveConfig())
+	if ok {
+		q.Tune(c)
+	}
+}
+
+// feedback returns the live config adjusted by the feedback header of
+// the response and whether it changed.
+func feedback(header string, rsp *http.Response, config, live Config) (Config, bool) {
+	if header == "" || rsp == nil {
+		return live, false
+	}
+
+	v := rsp.Header.Get(header)
+	if v == "" {
+		return live, false
+	}
+	rsp.Header.Del(header)
+
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		log.Debugf("Invalid scheduler feedback header value %q: %v", v, err)
+		return live, false
+	}
+
+	n = max(1, min(n, config.MaxConcurrency))
+	if n == live.MaxConcurrency {
+		return live, false
+	}
+
+	live.MaxConcurrency = n
+	return live, true
+}
+
 func (q *Queue) Close() {
 	q.queue.Close()
 }
@@ -397,6 +545,8 @@ func RegistryWith(o Options) *Registry {
 		quit:        make(chan struct{}),
 		fifoQueues:  make(map[queueId]*FifoQueue),
 		fifoDeleted: make(map[*FifoQueue]time.Time),
+		fairQueues:  make(map[queueId]*FairQueue),
+		fairDeleted: make(map[*FairQueue]time.Time),
 		lifoQueues:  make(map[queueId]*Queue),
 		lifoDeleted: make(map[*Queue]time.Time),
 	}
@@ -425,10 +575,11 @@ func (r *Registry) getFifoQueue(id queueId, c Config) *FifoQueue {
 
 func (r *Registry) newFifoQueue(name string, c Config) *FifoQueue {
 	q := &FifoQueue{
-		config: c,
+		config:         c,
+		feedbackHeader: r.options.FeedbackHeader,
 		queue: &fifoQueue{
 			counter:        new(atomic.Int64),
-			sem:            semaphore.NewWeighted(int64(c.MaxConcurrency)),
+			sem:            newLimiter(int64(c.MaxConcurrency)),
 			maxConcurrency: int64(c.MaxConcurrency),
 			maxQueueSize:   int64(c.MaxQueueSize),
 			timeout:        c.Timeout,
@@ -452,6 +603,43 @@ func (r *Registry) newFifoQueue(name string, c Config) *FifoQueue {
 	return q
 }
 
+func (r *Registry) getFairQueue(id queueId, c Config, weights map[string]int) *FairQueue {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	fq, ok := r.fairQueues[id]
+	if ok {
+		if fq.Config() != c || !maps.Equal(fq.Weights(), weights) {
+			fq.reconfigure(c, weights)
+		}
+	} else {
+		fq = r.newFairQueue(id.name, c, weights)
+		r.fairQueues[id] = fq
+	}
+	return fq
+}
+
+func (r *Registry) newFairQueue(name string, c Config, weights map[string]int) *FairQueue {
+	q := newFairQueue(c, weights)
+	q.feedbackHeader = r.options.FeedbackHeader
+
+	if r.options.EnableRouteFIFOMetrics {
+		if name == "" {
+			name = "unknown"
+		}
+
+		q.activeRequestsMetricsKey = fmt.Sprintf("fairfifo.%s.active", name)
+		q.queuedRequestsMetricsKey = fmt.Sprintf("fairfifo.%s.queued", name)
+		q.errorFullMetricsKey = fmt.Sprintf("fairfifo.%s.error.full", name)
+		q.errorOtherMetricsKey = fmt.Sprintf("fairfifo.%s.error.other", name)
+		q.errorTimeoutMetricsKey = fmt.Sprintf("fairfifo.%s.error.timeout", name)
+		q.metrics = r.options.Metrics
+		r.measure()
+	}
+
+	return q
+}
+
 func (r *Registry) getQueue(id queueId, c Config) *Queue {
 	r.mu.Lock()
 	defer r.mu.Unlock()
@@ -471,7 +659,9 @@ func (r *Registry) getQueue(id queueId, c Config) *Queue {
 
 func (r *Registry) newQueue(name string, c Config) *Queue {
 	q := &Queue{
-		config: c,
+		config:         c,
+		live:           c,
+		feedbackHeader: r.options.FeedbackHeader,
 		// renaming Stack -> Queue in the jobqueue project will follow
 		queue: jobqueue.With(jobqueue.Options{
 			MaxConcurrency: c.MaxConcurrency,
@@ -518,6 +708,20 @@ func (r *Registry) deleteUnused(inUse map[queueId]struct{}) {
 		}
 	}
 
+	// fair fifo
+	for q, deleted := range r.fairDeleted {
+		if deleted.Before(closeCutoff) {
+			delete(r.fairDeleted, q)
+			q.close()
+		}
+	}
+	for id, q := range r.fairQueues {
+		if _, ok := inUse[id]; !ok {
+			delete(r.fairQueues, id)
+			r.fairDeleted[q] = now
+		}
+	}
+
 	// lifo
 	for q, deleted := range r.lifoDeleted {
 		if deleted.Before(closeCutoff) {
@@ -546,10 +750,13 @@ func (registryPreProcessor) Do(routes []*eskip.Route) []*eskip.Route {
 	for _, r := range routes {
 		lifoCount := 0
 		fifoCount := 0
+		fairCount := 0
 		for _, f := range r.Filters {
 			switch f.Name {
 			case filters.FifoName:
 				fifoCount++
+			case filters.FairFifoName:
+				fairCount++
 			case filters.LifoName:
 				lifoCount++
 			}
@@ -567,6 +774,19 @@ func (registryPreProcessor) Do(routes []*eskip.Route) []*eskip.Route {
 				}
 			}
 		}
+		// remove all but last fairFifo instances
+		if fairCount > 1 {
+			old := r.Filters
+			r.Filters = make([]*eskip.Filter, 0, len(old)-fairCount+1)
+			for _, f := range old {
+				if fairCount > 1 && f.Name == filters.FairFifoName {
+					log.Debugf("Removing non-last %v from %s", f, r.Id)
+					fairCount--
+				} else {
+					r.Filters = append(r.Filters, f)
+				}
+			}
+		}
 		// remove all but last lifo instances
 		if lifoCount > 1 {
 			old := r.Filters
@@ -595,6 +815,14 @@ func (r *Registry) Do(routes []*routing.Route) []*routing.Route {
 	for i, ri := range routes {
 		rr[i] = ri
 		for _, fi := range ri.Filters {
+			if ff, ok := fi.Filter.(FairFilter); ok {
+				id := queueId{ri.Id, false}
+				inUse[id] = struct{}{}
+				fq := r.getFairQueue(id, ff.Config(), ff.Weights())
+				ff.SetQueue(fq)
+				continue
+			}
+
 			if ff, ok := fi.Filter.(FIFOFilter); ok {
 				id := queueId{ri.Id, false}
 				inUse[id] = struct{}{}
@@ -624,38 +852,88 @@ func (r *Registry) Do(routes []*routing.Route) []*routing.Route {
 	}
 
 	for name, group := range groups {
-		var (
-			c           Config
-			foundConfig bool
-		)
+		id := queueId{name, true}
+		inUse[id] = struct{}{}
+
+		q := r.getQueue(id, groupConfig(name, group))
 
 		for _, glf := range group {
-			if !glf.HasConfig() {
-				continue
-			}
+			glf.SetQueue(q)
+		}
+	}
 
-			if foundConfig && glf.Config() != c {
-				log.Warnf("Found mismatching configuration for the LIFO group: %s", name)
-				continue
-			}
+	r.deleteUnused(inUse)
 
-			c = glf.Config()
-			foundConfig = true
-		}
+	return rr
+}
 
-		id := queueId{name, true}
-		inUse[id] = struct{}{}
+// DoRollout implements routing.RolloutPostProcessor. The FIFO filters of
+// the routes under staged rollout use the existing queues without
+// changing their settings, or their own queues, when the route is new.
+// The LIFO filters get their own queues, because they are closed
+// together with the routes under rollout.
+func (r *Registry) DoRollout(routes []*routing.Route) []*routing.Route {
+	r.mu.Lock()
+	defer r.mu.Unlock()
 
-		q := r.getQueue(id, c)
+	groups := make(map[string][]GroupedLIFOFilter)
+	for _, ri := range routes {
+		for _, fi := range ri.Filters {
+			switch f := fi.Filter.(type) {
+			case FairFilter:
+				fq, ok := r.fairQueues[queueId{ri.Id, false}]
+				if !ok {
+					fq = r.newFairQueue(ri.Id, f.Config(), f.Weights())
+				}
 
+				f.SetQueue(fq)
+			case FIFOFilter:
+				fq, ok := r.fifoQueues[queueId{ri.Id, false}]
+				if !ok {
+					fq = r.newFifoQueue(ri.Id, f.Config())
+				}
+
+				f.SetQueue(fq)
+			case GroupedLIFOFilter:
+				groups[f.Group()] = append(groups[f.Group()], f)
+			case LIFOFilter:
+				f.SetQueue(r.newQueue(ri.Id, f.Config()))
+			}
+		}
+	}
+
+	for name, group := range groups {
+		q := r.newQueue(name, groupConfig(name, group))
 		for _, glf := range group {
 			glf.SetQueue(q)
 		}
 	}
 
-	r.deleteUnused(inUse)
+	return routes
+}
 
-	return rr
+// groupConfig returns the queue settings of a LIFO group.
+func groupConfig(name string, group []GroupedLIFOFilter) Config {
+	var (
+		c           Config
+		foundConfig bool
+	)
+
+	for _, glf := range group {
+		if !glf.HasConfig() {
+			continue
+		}
+
+		if foundConfig && glf.Config() != c {
+			log.Warnf("Found mismatching configuration for the LIFO group: %s", name)
+			continue
+		}
+
+		c = glf.Config()
+		foundConfig = true
+	}
+
+	return c
 }
 
 func (r *Registry) measure() {
@@ -688,6 +966,12 @@ func (r *Registry) updateMetrics() {
 		r.options.Metrics.UpdateGauge(q.queuedRequestsMetricsKey, float64(s.QueuedRequests))
 	}
 
+	for _, q := range r.fairQueues {
+		s := q.Status()
+		r.options.Metrics.UpdateGauge(q.activeRequestsMetricsKey, float64(s.ActiveRequests))
+		r.options.Metrics.UpdateGauge(q.queuedRequestsMetricsKey, float64(s.QueuedRequests))
+	}
+
 	for _, q := range r.lifoQueues {
 		s := q.Status()
 		r.options.Metrics.UpdateGauge(q.activeRequestsMetricsKey, float64(s.ActiveRequests))
@@ -711,6 +995,11 @@ func (r *Registry) Close() {
 		q.close()
 	}
 
+	for q := range r.fairDeleted {
+		delete(r.fairDeleted, q)
+		q.close()
+	}
+
 	for q := range r.lifoDeleted {
 		delete(r.lifoDeleted, q)
 		q.Close()
@@ -723,3 +1012,181 @@ func (r *Registry) Close() {
 
 	close(r.quit)
 }
+
+type queueSettings struct {
+	MaxConcurrency int    `json:"maxConcurrency"`
+	MaxQueueSize   int    `json:"maxQueueSize"`
+	Timeout        string `json:"timeout"`
+}
+
+type queueInfo struct {
+	Type           string        `json:"type"`
+	Name           string        `json:"name"`
+	Group          bool          `json:"group,omitempty"`
+	Config         queueSettings `json:"config"`
+	Live           queueSettings `json:"live"`
+	ActiveRequests int           `json:"activeRequests"`
+	QueuedRequests int           `json:"queuedRequests"`
+	Closed         bool          `json:"closed,omitempty"`
+}
+
+// queueUpdate is the request body to change the live settings of a
+// queue. Settings that are not set keep their current value.
+type queueUpdate struct {
+	Type           string `json:"type"`
+	Name           string `json:"name"`
+	Group          bool   `json:"group"`
+	MaxConcurrency int    `json:"maxConcurrency"`
+	MaxQueueSize   int    `json:"maxQueueSize"`
+	Timeout        string `json:"timeout"`
+	Reset          bool   `json:"reset"`
+}
+
+func toQueueSettings(c Config) queueSettings {
+	return queueSettings{
+		MaxConcurrency: c.MaxConcurrency,
+		MaxQueueSize:   c.MaxQueueSize,
+		Timeout:        c.Timeout.String(),
+	}
+}
+
+func newQueueInfo(typ string, id queueId, c, live Config, s QueueStatus) queueInfo {
+	return queueInfo{
+		Type:           typ,
+		Name:           id.name,
+		Group:          id.grouped,
+		Config:         toQueueSettings(c),
+		Live:           toQueueSettings(live),
+		ActiveRequests: s.ActiveRequests,
+		QueuedRequests: s.QueuedRequests,
+		Closed:         s.Closed,
+	}
+}
+
+func (r *Registry) queueInfos() []queueInfo {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	infos := make([]queueInfo, 0, len(r.fifoQueues)+len(r.fairQueues)+len(r.lifoQueues))
+	for id, q := range r.fifoQueues {
+		infos = append(infos, newQueueInfo(filters.FifoName, id, q.Config(), q.LiveConfig(), q.Status()))
+	}
+
+	for id, q := range r.fairQueues {
+		infos = append(infos, newQueueInfo(filters.FairFifoName, id, q.Config(), q.LiveConfig(), q.Status()))
+	}
+
+	for id, q := range r.lifoQueues {
+		infos = append(infos, newQueueInfo(filters.LifoName, id, q.Config(), q.LiveConfig(), q.Status()))
+	}
+
+	sort.Slice(infos, func(i, j int) bool {
+		if infos[i].Type != infos[j].Type {
+			return infos[i].Type < infos[j].Type
+		}
+		return infos[i].Name < infos[j].Name
+	})
+
+	return infos
+}
+
+func (r *Registry) updateQueue(u queueUpdate) (queueInfo, error) {
+	if u.MaxConcurrency < 0 || u.MaxQueueSize < 0 {
+		return queueInfo{}, fmt.Errorf("invalid queue settings")
+	}
+
+	var timeout time.Duration
+	if u.Timeout != "" {
+		d, err := time.ParseDuration(u.Timeout)
+		if err != nil || d < time.Millisecond {
+			return queueInfo{}, fmt.Errorf("invalid timeout: %q", u.Timeout)
+		}
+		timeout = d
+	}
+
+	apply := func(c, live Config) Config {
+		if u.Reset {
+			return c
+		}
+		if u.MaxConcurrency > 0 {
+			live.MaxConcurrency = u.MaxConcurrency
+		}
+		if u.MaxQueueSize > 0 {
+			live.MaxQueueSize = u.MaxQueueSize
+		}
+		if timeout > 0 {
+			live.Timeout = timeout
+		}
+		return live
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	id := queueId{u.Name, u.Group}
+	switch u.Type {
+	case filters.FifoName:
+		if q, ok := r.fifoQueues[id]; ok {
+			q.Tune(apply(q.Config(), q.LiveConfig()))
+			return newQueueInfo(u.Type, id, q.Config(), q.LiveConfig(), q.Status()), nil
+		}
+	case filters.FairFifoName:
+		if q, ok := r.fairQueues[id]; ok {
+			q.Tune(apply(q.Config(), q.LiveConfig()))
+			return newQueueInfo(u.Type, id, q.Config(), q.LiveConfig(), q.Status()), nil
+		}
+	case filters.LifoName:
+		if q, ok := r.lifoQueues[id]; ok {
+			q.Tune(apply(q.Config(), q.LiveConfig()))
+			return newQueueInfo(u.Type, id, q.Config(), q.LiveConfig(), q.Status()), nil
+		}
+	default:
+		return queueInfo{}, fmt.Errorf("invalid queue type: %q", u.Type)
+	}
+
+	return queueInfo{}, errQueueNotFound
+}
+
+// ServeHTTP lists the queues with their configuration, live settings
+// and status as JSON. When EnableLiveConfig is set, the live settings
+// of a queue can be changed with a POST request.
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case "GET", "HEAD":
+		w.Header().Set("Content-Type", "application/json")
+		if req.Method == "HEAD" {
+			return
+		}
+		if err := json.NewEncoder(w).Encode(r.queueInfos()); err != nil {
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		}
+	case "POST":
+		if !r.options.EnableLiveConfig {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		var u queueUpdate
+		if err := json.NewDecoder(req.Body).Decode(&u); err != nil {
+			http.Error(w, "invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		info, err := r.updateQueue(u)
+		if err == errQueueNotFound {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		} else if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		log.Infof("Scheduler queue %s %s tuned to %+v", u.Type, u.Name, info.Live)
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(info); err != nil {
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		}
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
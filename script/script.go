@@ -17,6 +17,7 @@ import (
 	lua "github.com/yuin/gopher-lua"
 	lua_parse "github.com/yuin/gopher-lua/parse"
 	"github.com/zalando/skipper/filters"
+	"github.com/zalando/skipper/net/websocket"
 	"github.com/zalando/skipper/script/base64"
 
 	"slices"
@@ -120,6 +121,8 @@ type script struct {
 	preload     []luaModule
 	hasRequest  bool
 	hasResponse bool
+
+	hasWebSocketMessage bool
 }
 
 func (s *script) getState() (*lua.LState, error) {
@@ -263,8 +266,11 @@ func (s *script) initScript(modules, validSources []string) error {
 	if fn := L.GetGlobal("response"); fn.Type() == lua.LTFunction {
 		s.hasResponse = true
 	}
-	if !s.hasRequest && !s.hasResponse {
-		return errors.New("at least one of `request` and `response` function must be present")
+	if fn := L.GetGlobal("websocket_message"); fn.Type() == lua.LTFunction {
+		s.hasWebSocketMessage = true
+	}
+	if !s.hasRequest && !s.hasResponse && !s.hasWebSocketMessage {
+		return errors.New("at least one of `request`, `response` and `websocket_message` function must be present")
 	}
 
 	// Init state pool
@@ -283,6 +289,11 @@ func (s *script) Request(f filters.FilterContext) {
 	if s.hasRequest {
 		s.runFunc("request", f)
 	}
+
+	if s.hasWebSocketMessage {
+		handlers, _ := f.StateBag()[filters.WebSocketMessageHandlers].([]websocket.MessageHandler)
+		f.StateBag()[filters.WebSocketMessageHandlers] = append(handlers, websocket.MessageHandlerFunc(s.handleWebSocketMessage))
+	}
 }
 
 func (s *script) Response(f filters.FilterContext) {
@@ -299,13 +310,6 @@ func (s *script) runFunc(name string, f filters.FilterContext) {
 	}
 	defer s.putState(L)
 
-	pt := L.CreateTable(len(s.routeParams), len(s.routeParams))
-	for i, p := range s.routeParams {
-		k, v, _ := strings.Cut(p, "=")
-		pt.RawSetString(k, lua.LString(v))
-		pt.RawSetInt(i+1, lua.LString(p))
-	}
-
 	err = L.CallByParam(
 		lua.P{
 			Fn:      L.GetGlobal(name),
@@ -313,13 +317,67 @@ func (s *script) runFunc(name string, f filters.FilterContext) {
 			Protect: true,
 		},
 		s.filterContextAsLuaTable(L, f),
-		pt,
+		s.routeParamsAsLuaTable(L),
 	)
 	if err != nil {
 		log.Errorf("Error calling %s from %s: %v", name, s.source, err)
 	}
 }
 
+// handleWebSocketMessage calls the websocket_message function with the
+// message and the route params. The message is dropped when the function
+// returns false, and its data is replaced when it returns a string.
+func (s *script) handleWebSocketMessage(m *websocket.Message) bool {
+	L, err := s.getState()
+	if err != nil {
+		log.Errorf("Error obtaining lua environment: %v", err)
+		return true
+	}
+	defer s.putState(L)
+
+	msg := L.CreateTable(0, 4)
+	msg.RawSetString("direction", lua.LString(m.Direction.String()))
+	msg.RawSetString("type", lua.LString(m.Opcode.String()))
+	msg.RawSetString("compressed", lua.LBool(m.Compressed))
+	msg.RawSetString("data", lua.LString(m.Data))
+
+	err = L.CallByParam(
+		lua.P{
+			Fn:      L.GetGlobal("websocket_message"),
+			NRet:    1,
+			Protect: true,
+		},
+		msg,
+		s.routeParamsAsLuaTable(L),
+	)
+	if err != nil {
+		log.Errorf("Error calling websocket_message from %s: %v", s.source, err)
+		return true
+	}
+
+	ret := L.Get(-1)
+	L.Pop(1)
+	switch v := ret.(type) {
+	case lua.LBool:
+		return bool(v)
+	case lua.LString:
+		m.Data = []byte(v)
+	}
+
+	return true
+}
+
+func (s *script) routeParamsAsLuaTable(L *lua.LState) *lua.LTable {
+	pt := L.CreateTable(len(s.routeParams), len(s.routeParams))
+	for i, p := range s.routeParams {
+		k, v, _ := strings.Cut(p, "=")
+		pt.RawSetString(k, lua.LString(v))
+		pt.RawSetInt(i+1, lua.LString(p))
+	}
+
+	return pt
+}
+
 func (s *script) filterContextAsLuaTable(L *lua.LState, f filters.FilterContext) *lua.LTable {
 	// this will be passed as parameter to the lua functions
 	// add metatable to dynamically access fields in the context
@@ -14,6 +14,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/zalando/skipper/filters"
 	"github.com/zalando/skipper/filters/filtertest"
+	"github.com/zalando/skipper/net/websocket"
 )
 
 type testContext struct {
@@ -529,6 +530,41 @@ func TestSleep(t *testing.T) {
 	}
 }
 
+func TestWebSocketMessage(t *testing.T) {
+	f, err := newFilter(LuaOptions{}, `
+function websocket_message(msg, params)
+	if msg.data == params.drop then
+		return false
+	end
+	if msg.direction == "backend" then
+		return msg.type .. ": " .. msg.data
+	end
+end`, "drop=secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := &filtertest.Context{FStateBag: map[string]interface{}{}}
+	f.Request(ctx)
+	f.Request(ctx)
+
+	handlers, ok := ctx.FStateBag[filters.WebSocketMessageHandlers].([]websocket.MessageHandler)
+	if !assert.True(t, ok) || !assert.Len(t, handlers, 2) {
+		return
+	}
+
+	m := &websocket.Message{Direction: websocket.FromClient, Opcode: websocket.OpText, Data: []byte("hello")}
+	assert.True(t, handlers[0].HandleMessage(m))
+	assert.Equal(t, "hello", string(m.Data))
+
+	m = &websocket.Message{Direction: websocket.FromBackend, Opcode: websocket.OpText, Data: []byte("hello")}
+	assert.True(t, handlers[0].HandleMessage(m))
+	assert.Equal(t, "text: hello", string(m.Data))
+
+	m = &websocket.Message{Direction: websocket.FromClient, Opcode: websocket.OpBinary, Data: []byte("secret")}
+	assert.False(t, handlers[0].HandleMessage(m))
+}
+
 // testable example have to refer known identifier
 const LoadFileOK = `testdata/load_ok.lua`
 
@@ -561,7 +597,7 @@ func ExampleMissingFunc() {
 	})
 	// Output:
 	// some string
-	// at least one of `request` and `response` function must be present
+	// at least one of `request`, `response` and `websocket_message` function must be present
 }
 
 const MalformedFile = `testdata/not_a_filter.lua`
@@ -572,7 +608,7 @@ func ExampleMalformedFile() {
 	})
 	// Output:
 	// some string
-	// at least one of `request` and `response` function must be present
+	// at least one of `request`, `response` and `websocket_message` function must be present
 }
 
 const SyntaxError = `function request(ctx, params); print(ctx.request.method)`
@@ -0,0 +1,915 @@
+/*
+Package acme implements obtaining and renewing TLS certificates from an
+ACME certificate authority, like Let's Encrypt, for the hosts found in the
+routing table:
+
+https://www.rfc-editor.org/rfc/rfc8555
+
+The Manager is a routing post processor, that collects the hosts from the
+Host predicates of the routes. Only the Host regular expressions matching
+a finite set of hosts are considered, e.g. ^(www[.])?example[.]org$, and
+optionally the hosts can be limited to a list of domains. The issued
+certificates are stored in the configured storage and in the certificate
+registry used to terminate TLS.
+
+The HTTP-01 and the TLS-ALPN-01 challenges are supported. The HTTP-01
+challenges are answered by wrapping the HTTP handler of the plain HTTP
+listener with the HTTPHandler method, and the TLS-ALPN-01 challenges are
+answered by the TLS configuration extended with the ConfigureTLS method,
+on the server configured with the ConfigureServer method.
+The pending challenges are stored in the storage, too, to allow every
+instance to answer them, when the storage is shared.
+
+When running multiple instances, the optional Locker makes sure that
+only one of them orders a certificate, or creates the account key, while
+the others load it from the shared storage.
+*/
+package acme
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	acmeapi "golang.org/x/crypto/acme"
+
+	"github.com/zalando/skipper/metrics"
+	"github.com/zalando/skipper/routing"
+	"github.com/zalando/skipper/secrets/certregistry"
+)
+
+const (
+	// ChallengeHTTP01 is the type of the HTTP-01 challenge.
+	ChallengeHTTP01 = "http-01"
+
+	// ChallengeTLSALPN01 is the type of the TLS-ALPN-01 challenge.
+	ChallengeTLSALPN01 = "tls-alpn-01"
+
+	// LetsEncryptURL is the directory URL of the Let's Encrypt
+	// production environment.
+	LetsEncryptURL = acmeapi.LetsEncryptURL
+
+	defaultRenewBefore   = 30 * 24 * time.Hour
+	defaultCheckInterval = 10 * time.Minute
+	defaultRetryInterval = time.Hour
+	defaultOrderTimeout  = 5 * time.Minute
+
+	accountKey           = "account.key"
+	certificatePrefix    = "certificates/"
+	httpChallengePrefix  = "http-01/"
+	tlsChallengePrefix   = "tls-alpn-01/"
+	orderLockPrefix      = "order."
+	accountLockName      = "account"
+	accountPollInterval  = time.Second
+	httpChallengePath    = "/.well-known/acme-challenge/"
+	pemTypeCertificate   = "CERTIFICATE"
+	pemTypeECPrivateKey  = "EC PRIVATE KEY"
+	metricsOrdersSuccess = "acme.orders.success"
+	metricsOrdersFailure = "acme.orders.failure"
+)
+
+var errUnsupportedChallenges = errors.New("acme: none of the offered challenges is enabled")
+
+// Options to create a Manager.
+type Options struct {
+
+	// DirectoryURL is the directory URL of the ACME server. Defaults
+	// to the Let's Encrypt production environment.
+	DirectoryURL string
+
+	// Email is the optional contact email of the ACME account.
+	Email string
+
+	// Challenges lists the enabled challenge types in the order of
+	// preference. Defaults to http-01 and tls-alpn-01.
+	Challenges []string
+
+	// Domains limits the certificates to the hosts that equal to or
+	// are subdomains of the listed domains. When empty, certificates
+	// are ordered for all the hosts of the routing table.
+	Domains []string
+
+	// Storage keeps the account key, the certificates and the pending
+	// challenges. Required.
+	Storage Storage
+
+	// Locker coordinates the orders between multiple instances.
+	// Optional, when not set, every instance orders the missing
+	// certificates on its own.
+	Locker Locker
+
+	// CertRegistry receives the issued certificates. Required.
+	CertRegistry *certregistry.CertRegistry
+
+	// RenewBefore is the time before the expiry when the
+	// certificates are renewed. Defaults to 30 days.
+	RenewBefore time.Duration
+
+	// CheckInterval is the interval of checking the certificates for
+	// renewal, and loading the certificates ordered by other
+	// instances. Defaults to 10 minutes.
+	CheckInterval time.Duration
+
+	// RetryInterval is the minimum time between failed orders for the
+	// same host. Defaults to 1 hour.
+	RetryInterval time.Duration
+
+	// Client is the HTTP client used to access the ACME server,
+	// e.g. to trust the CA of a local test server. Optional.
+	Client *http.Client
+
+	// Metrics counts the successful and the failed orders, as
+	// acme.orders.success and acme.orders.failure. Optional.
+	Metrics metrics.Metrics
+}
+
+// Manager orders and renews the certificates for the hosts of the
+// routing table.
+type Manager struct {
+	options Options
+
+	// serializes the creation of the account client
+	accountMu sync.Mutex
+
+	mu     sync.Mutex
+	client *acmeapi.Client
+	hosts  map[string]struct{}
+	leafs  map[string]*x509.Certificate
+	failed map[string]time.Time
+
+	// the challenges pending in the current instance
+	challenges sync.Map
+
+	update chan struct{}
+	quit   chan struct{}
+	once   sync.Once
+}
+
+// NewManager creates a Manager, and starts ordering the certificates in
+// the background. Make sure to Close() it.
+func NewManager(o Options) (*Manager, error) {
+	if o.Storage == nil {
+		return nil, errors.New("acme: missing storage")
+	}
+
+	if o.CertRegistry == nil {
+		return nil, errors.New("acme: missing certificate registry")
+	}
+
+	if o.DirectoryURL == "" {
+		o.DirectoryURL = LetsEncryptURL
+	}
+
+	if len(o.Challenges) == 0 {
+		o.Challenges = []string{ChallengeHTTP01, ChallengeTLSALPN01}
+	}
+
+	for _, c := range o.Challenges {
+		if c != ChallengeHTTP01 && c != ChallengeTLSALPN01 {
+			return nil, fmt.Errorf("acme: unsupported challenge type: %s", c)
+		}
+	}
+
+	domains := make([]string, len(o.Domains))
+	for i, d := range o.Domains {
+		domains[i] = strings.ToLower(strings.TrimSuffix(d, "."))
+	}
+
+	o.Domains = domains
+
+	if o.RenewBefore <= 0 {
+		o.RenewBefore = defaultRenewBefore
+	}
+
+	if o.CheckInterval <= 0 {
+		o.CheckInterval = defaultCheckInterval
+	}
+
+	if o.RetryInterval <= 0 {
+		o.RetryInterval = defaultRetryInterval
+	}
+
+	m := &Manager{
+		options: o,
+		hosts:   make(map[string]struct{}),
+		leafs:   make(map[string]*x509.Certificate),
+		failed:  make(map[string]time.Time),
+		update:  make(chan struct{}, 1),
+		quit:    make(chan struct{}),
+	}
+
+	go m.run()
+	return m, nil
+}
+
+func (m *Manager) challengeEnabled(typ string) bool {
+	for _, c := range m.options.Challenges {
+		if c == typ {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Do implements routing.PostProcessor, collecting the hosts of the
+// routes. It doesn't change the routes.
+func (m *Manager) Do(routes []*routing.Route) []*routing.Route {
+	hosts := routeHosts(routes)
+	for h := range hosts {
+		if !allowedHost(m.options.Domains, h) {
+			delete(hosts, h)
+		}
+	}
+
+	m.mu.Lock()
+	changed := len(hosts) != len(m.hosts)
+	for h := range hosts {
+		if _, ok := m.hosts[h]; !ok {
+			changed = true
+			break
+		}
+	}
+
+	m.hosts = hosts
+	m.mu.Unlock()
+
+	if changed {
+		select {
+		case m.update <- struct{}{}:
+		default:
+		}
+	}
+
+	return routes
+}
+
+// DoRollout implements routing.RolloutPostProcessor. The hosts are
+// collected only from the promoted generations.
+func (m *Manager) DoRollout(routes []*routing.Route) []*routing.Route {
+	return routes
+}
+
+// HTTPHandler wraps an HTTP handler, and answers the HTTP-01 challenges
+// pending in the current or in other instances sharing the storage.
+// Other requests, including the requests for unknown challenge tokens,
+// are passed to the wrapped handler.
+func (m *Manager) HTTPHandler(next http.Handler) http.Handler {
+	if !m.challengeEnabled(ChallengeHTTP01) {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" || !strings.HasPrefix(r.URL.Path, httpChallengePath) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		token := strings.TrimPrefix(r.URL.Path, httpChallengePath)
+		rsp, err := m.httpChallenge(r.Context(), token)
+		if errors.Is(err, ErrNotFound) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if err != nil {
+			log.Errorf("ACME: failed to load HTTP-01 challenge: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write(rsp)
+	})
+}
+
+// ConfigureTLS extends a TLS configuration to answer the TLS-ALPN-01
+// challenges pending in the current or in other instances sharing the
+// storage. The challenges are answered with a dedicated configuration,
+// only when the client offers only the acme-tls/1 protocol and a
+// challenge is pending for the SNI, otherwise the handshake fails. It
+// needs to be called after the GetConfigForClient field of the
+// configuration was set, e.g. for the per-host TLS policies, and the
+// server needs to be configured with ConfigureServer.
+func (m *Manager) ConfigureTLS(c *tls.Config) {
+	if !m.challengeEnabled(ChallengeTLSALPN01) {
+		return
+	}
+
+	next := c.GetConfigForClient
+	c.GetConfigForClient = func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+		if len(hello.SupportedProtos) == 1 && hello.SupportedProtos[0] == acmeapi.ALPNProto {
+			cert, err := m.tlsChallenge(hello.Context(), strings.ToLower(hello.ServerName))
+			if err != nil {
+				return nil, err
+			}
+
+			return &tls.Config{
+				MinVersion:   tls.VersionTLS12,
+				Certificates: []tls.Certificate{*cert},
+				NextProtos:   []string{acmeapi.ALPNProto},
+			}, nil
+		}
+
+		if next != nil {
+			return next(hello)
+		}
+
+		return nil, nil
+	}
+}
+
+// ConfigureServer makes the server close the connections of the
+// TLS-ALPN-01 challenges right after the handshake. The HTTP server
+// doesn't serve requests on the connections with an unknown negotiated
+// protocol either, but this way the connections answering the challenges
+// without the host TLS policy are closed explicitly. When the server has
+// no protocols set, it enables HTTP/1 and HTTP/2, that would be disabled
+// otherwise by setting the TLSNextProto field.
+func (m *Manager) ConfigureServer(srv *http.Server) {
+	if !m.challengeEnabled(ChallengeTLSALPN01) {
+		return
+	}
+
+	if srv.Protocols == nil {
+		srv.Protocols = new(http.Protocols)
+		srv.Protocols.SetHTTP1(true)
+		srv.Protocols.SetHTTP2(true)
+	}
+
+	if srv.TLSNextProto == nil {
+		srv.TLSNextProto = make(map[string]func(*http.Server, *tls.Conn, http.Handler))
+	}
+
+	srv.TLSNextProto[acmeapi.ALPNProto] = func(_ *http.Server, c *tls.Conn, _ http.Handler) {
+		c.Close()
+	}
+}
+
+func (m *Manager) httpChallenge(ctx context.Context, token string) ([]byte, error) {
+	if v, ok := m.challenges.Load(httpChallengePrefix + token); ok {
+		return v.([]byte), nil
+	}
+
+	return m.options.Storage.Get(ctx, httpChallengePrefix+token)
+}
+
+func (m *Manager) tlsChallenge(ctx context.Context, host string) (*tls.Certificate, error) {
+	if v, ok := m.challenges.Load(tlsChallengePrefix + host); ok {
+		return v.(*tls.Certificate), nil
+	}
+
+	// the storage is checked only for the hosts that can have a
+	// pending order, to not let any client trigger storage requests
+	if !m.ordering(host) {
+		return nil, fmt.Errorf("acme: TLS-ALPN-01 challenge not found for %s: %w", host, ErrNotFound)
+	}
+
+	b, err := m.options.Storage.Get(ctx, tlsChallengePrefix+host)
+	if err != nil {
+		return nil, fmt.Errorf("acme: TLS-ALPN-01 challenge not found for %s: %w", host, err)
+	}
+
+	return decodeCertificate(b)
+}
+
+// ordering tells whether the certificate of the host can be ordered by
+// the current or by another instance: the host is in the routing table,
+// and it has no certificate yet, or its certificate is due for renewal.
+func (m *Manager) ordering(host string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.hosts[host]; !ok {
+		return false
+	}
+
+	leaf := m.leafs[host]
+	return leaf == nil || m.due(leaf)
+}
+
+func (m *Manager) run() {
+	ticker := time.NewTicker(m.options.CheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-m.update:
+		case <-ticker.C:
+		case <-m.quit:
+			return
+		}
+
+		m.check()
+	}
+}
+
+func (m *Manager) check() {
+	m.mu.Lock()
+	hosts := make([]string, 0, len(m.hosts))
+	for h := range m.hosts {
+		hosts = append(hosts, h)
+	}
+	m.mu.Unlock()
+
+	for _, h := range hosts {
+		select {
+		case <-m.quit:
+			return
+		default:
+		}
+
+		if err := m.ensure(h); err != nil {
+			log.Errorf("ACME: failed to obtain certificate for %s: %v", h, err)
+		}
+	}
+}
+
+func (m *Manager) due(leaf *x509.Certificate) bool {
+	return time.Now().Add(m.options.RenewBefore).After(leaf.NotAfter)
+}
+
+// ensure makes sure that a valid certificate for the host is available
+// in the certificate registry, loading it from the storage or ordering
+// it.
+func (m *Manager) ensure(host string) error {
+	m.mu.Lock()
+	leaf := m.leafs[host]
+	failed := m.failed[host]
+	m.mu.Unlock()
+
+	if leaf != nil && !m.due(leaf) {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), defaultOrderTimeout)
+	defer cancel()
+
+	cert, err := m.load(ctx, host)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return err
+	}
+
+	if cert != nil {
+		if err := m.configure(host, cert); err != nil {
+			return err
+		}
+
+		if !m.due(cert.Leaf) {
+			return nil
+		}
+	}
+
+	if time.Since(failed) < m.options.RetryInterval {
+		return nil
+	}
+
+	if l := m.options.Locker; l != nil {
+		ok, err := l.TryLock(ctx, orderLockPrefix+host, defaultOrderTimeout)
+		if err != nil {
+			return err
+		}
+
+		if !ok {
+			log.Debugf("ACME: certificate for %s is ordered by another instance", host)
+			return nil
+		}
+
+		defer func() {
+			if err := l.Unlock(context.Background(), orderLockPrefix+host); err != nil {
+				log.Errorf("ACME: failed to release lock for %s: %v", host, err)
+			}
+		}()
+
+		// the certificate may have been stored by the previous holder
+		// of the lock
+		if cert, err := m.load(ctx, host); err == nil && !m.due(cert.Leaf) {
+			return m.configure(host, cert)
+		}
+	}
+
+	cert, err = m.order(ctx, host)
+	m.mu.Lock()
+	if err != nil {
+		m.failed[host] = time.Now()
+	} else {
+		delete(m.failed, host)
+	}
+	m.mu.Unlock()
+
+	if err != nil {
+		m.incCounter(metricsOrdersFailure)
+		return err
+	}
+
+	m.incCounter(metricsOrdersSuccess)
+	b, err := encodeCertificate(cert)
+	if err != nil {
+		return err
+	}
+
+	if err := m.options.Storage.Put(ctx, certificatePrefix+host, b); err != nil {
+		return err
+	}
+
+	log.Infof("ACME: obtained certificate for %s, valid until %v", host, cert.Leaf.NotAfter)
+	return m.configure(host, cert)
+}
+
+func (m *Manager) incCounter(key string) {
+	if m.options.Metrics != nil {
+		m.options.Metrics.IncCounter(key)
+	}
+}
+
+func (m *Manager) load(ctx context.Context, host string) (*tls.Certificate, error) {
+	b, err := m.options.Storage.Get(ctx, certificatePrefix+host)
+	if err != nil {
+		return nil, err
+	}
+
+	cert, err := decodeCertificate(b)
+	if err != nil {
+		return nil, fmt.Errorf("invalid stored certificate: %w", err)
+	}
+
+	if time.Now().After(cert.Leaf.NotAfter) {
+		return nil, ErrNotFound
+	}
+
+	return cert, nil
+}
+
+func (m *Manager) configure(host string, cert *tls.Certificate) error {
+	m.mu.Lock()
+	current := m.leafs[host]
+	m.mu.Unlock()
+
+	if current != nil && !cert.Leaf.NotBefore.After(current.NotBefore) {
+		return nil
+	}
+
+	if err := m.options.CertRegistry.ConfigureCertificate(host, cert); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	m.leafs[host] = cert.Leaf
+	m.mu.Unlock()
+	return nil
+}
+
+// acmeClient returns the client with the registered account, loading
+// or creating the account key. When multiple instances create the key
+// at the same time, the last one is stored, while the others keep using
+// their own accounts.
+func (m *Manager) loadAccountKey(ctx context.Context) (crypto.Signer, error) {
+	b, err := m.options.Storage.Get(ctx, accountKey)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := decodeKey(b)
+	if err != nil {
+		return nil, fmt.Errorf("invalid account key: %w", err)
+	}
+
+	return key, nil
+}
+
+func (m *Manager) createAccountKey(ctx context.Context) (crypto.Signer, error) {
+	k, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := encodeKey(k)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := m.options.Storage.Put(ctx, accountKey, b); err != nil {
+		return nil, err
+	}
+
+	return k, nil
+}
+
+// accountSigner loads the account key from the storage, or creates it.
+// With a Locker, only the instance holding the lock creates the key, and
+// the other instances wait until it is stored.
+func (m *Manager) accountSigner(ctx context.Context) (crypto.Signer, error) {
+	for {
+		key, err := m.loadAccountKey(ctx)
+		if !errors.Is(err, ErrNotFound) {
+			return key, err
+		}
+
+		l := m.options.Locker
+		if l == nil {
+			return m.createAccountKey(ctx)
+		}
+
+		ok, err := l.TryLock(ctx, accountLockName, defaultOrderTimeout)
+		if err != nil {
+			return nil, err
+		}
+
+		if ok {
+			defer func() {
+				if err := l.Unlock(context.Background(), accountLockName); err != nil {
+					log.Errorf("ACME: failed to release the account lock: %v", err)
+				}
+			}()
+
+			// the key may have been stored by the previous holder of
+			// the lock
+			if key, err := m.loadAccountKey(ctx); !errors.Is(err, ErrNotFound) {
+				return key, err
+			}
+
+			return m.createAccountKey(ctx)
+		}
+
+		log.Debug("ACME: account key is created by another instance")
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(accountPollInterval):
+		}
+	}
+}
+
+func (m *Manager) acmeClient(ctx context.Context) (*acmeapi.Client, error) {
+	m.accountMu.Lock()
+	defer m.accountMu.Unlock()
+
+	m.mu.Lock()
+	client := m.client
+	m.mu.Unlock()
+	if client != nil {
+		return client, nil
+	}
+
+	key, err := m.accountSigner(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	client = &acmeapi.Client{
+		Key:          key,
+		DirectoryURL: m.options.DirectoryURL,
+		HTTPClient:   m.options.Client,
+		UserAgent:    "skipper",
+	}
+
+	account := &acmeapi.Account{}
+	if m.options.Email != "" {
+		account.Contact = []string{"mailto:" + m.options.Email}
+	}
+
+	if _, err := client.Register(ctx, account, acmeapi.AcceptTOS); err != nil && !errors.Is(err, acmeapi.ErrAccountAlreadyExists) {
+		return nil, fmt.Errorf("failed to register account: %w", err)
+	}
+
+	m.mu.Lock()
+	m.client = client
+	m.mu.Unlock()
+	return client, nil
+}
+
+func (m *Manager) order(ctx context.Context, host string) (*tls.Certificate, error) {
+	client, err := m.acmeClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	o, err := client.AuthorizeOrder(ctx, acmeapi.DomainIDs(host))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, u := range o.AuthzURLs {
+		z, err := client.GetAuthorization(ctx, u)
+		if err != nil {
+			return nil, err
+		}
+
+		if z.Status != acmeapi.StatusPending {
+			continue
+		}
+
+		if err := m.authorize(ctx, client, host, z); err != nil {
+			return nil, err
+		}
+	}
+
+	orderURL := o.URI
+	o, err = client.WaitOrder(ctx, orderURL)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{DNSNames: []string{host}}, key)
+	if err != nil {
+		return nil, err
+	}
+
+	der, _, err := client.CreateOrderCert(ctx, o.FinalizeURL, csr, true)
+	if err != nil {
+		// the response of the finalization may not contain the order
+		// location, e.g. from Pebble, when the certificate is not
+		// issued yet, in which case we wait using the known URL
+		o, werr := client.WaitOrder(ctx, orderURL)
+		if werr != nil || o.Status != acmeapi.StatusValid {
+			return nil, err
+		}
+
+		der, err = client.FetchCert(ctx, o.CertURL, true)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	leaf, err := x509.ParseCertificate(der[0])
+	if err != nil {
+		return nil, err
+	}
+
+	return &tls.Certificate{Certificate: der, PrivateKey: key, Leaf: leaf}, nil
+}
+
+func (m *Manager) authorize(ctx context.Context, client *acmeapi.Client, host string, z *acmeapi.Authorization) error {
+	var chal *acmeapi.Challenge
+	for _, typ := range m.options.Challenges {
+		for _, c := range z.Challenges {
+			if c.Type == typ {
+				chal = c
+				break
+			}
+		}
+
+		if chal != nil {
+			break
+		}
+	}
+
+	if chal == nil {
+		return errUnsupportedChallenges
+	}
+
+	cleanup, err := m.prepareChallenge(ctx, client, host, chal)
+	defer cleanup()
+	if err != nil {
+		return err
+	}
+
+	if _, err := client.Accept(ctx, chal); err != nil {
+		return err
+	}
+
+	_, err = client.WaitAuthorization(ctx, z.URI)
+	return err
+}
+
+// prepareChallenge stores the challenge response in the current
+// instance and in the storage, and returns the function that removes
+// it.
+func (m *Manager) prepareChallenge(ctx context.Context, client *acmeapi.Client, host string, chal *acmeapi.Challenge) (func(), error) {
+	var (
+		key   string
+		value interface{}
+		data  []byte
+	)
+
+	switch chal.Type {
+	case ChallengeHTTP01:
+		rsp, err := client.HTTP01ChallengeResponse(chal.Token)
+		if err != nil {
+			return func() {}, err
+		}
+
+		key, value, data = httpChallengePrefix+chal.Token, []byte(rsp), []byte(rsp)
+	case ChallengeTLSALPN01:
+		cert, err := client.TLSALPN01ChallengeCert(chal.Token, host)
+		if err != nil {
+			return func() {}, err
+		}
+
+		data, err = encodeCertificate(&cert)
+		if err != nil {
+			return func() {}, err
+		}
+
+		key, value = tlsChallengePrefix+host, &cert
+	}
+
+	m.challenges.Store(key, value)
+	cleanup := func() {
+		m.challenges.Delete(key)
+		if err := m.options.Storage.Delete(context.Background(), key); err != nil {
+			log.Errorf("ACME: failed to delete challenge %s: %v", key, err)
+		}
+	}
+
+	return cleanup, m.options.Storage.Put(ctx, key, data)
+}
+
+// Close stops ordering the certificates.
+func (m *Manager) Close() {
+	m.once.Do(func() { close(m.quit) })
+}
+
+func encodeKey(key crypto.Signer) ([]byte, error) {
+	ec, ok := key.(*ecdsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("unsupported key type")
+	}
+
+	b, err := x509.MarshalECPrivateKey(ec)
+	if err != nil {
+		return nil, err
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: pemTypeECPrivateKey, Bytes: b}), nil
+}
+
+func decodeKey(b []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(b)
+	if block == nil || block.Type != pemTypeECPrivateKey {
+		return nil, errors.New("missing private key")
+	}
+
+	return x509.ParseECPrivateKey(block.Bytes)
+}
+
+// encodeCertificate encodes the private key and the certificate chain
+// in PEM format.
+func encodeCertificate(cert *tls.Certificate) ([]byte, error) {
+	signer, ok := cert.PrivateKey.(crypto.Signer)
+	if !ok {
+		return nil, errors.New("unsupported key type")
+	}
+
+	var buf bytes.Buffer
+	b, err := encodeKey(signer)
+	if err != nil {
+		return nil, err
+	}
+
+	buf.Write(b)
+	for _, der := range cert.Certificate {
+		if err := pem.Encode(&buf, &pem.Block{Type: pemTypeCertificate, Bytes: der}); err != nil {
+			return nil, err
+		}
+	}
+
+	return buf.Bytes(), nil
+}
+
+func decodeCertificate(b []byte) (*tls.Certificate, error) {
+	key, err := decodeKey(b)
+	if err != nil {
+		return nil, err
+	}
+
+	cert := &tls.Certificate{PrivateKey: key}
+	for {
+		var block *pem.Block
+		block, b = pem.Decode(b)
+		if block == nil {
+			break
+		}
+
+		if block.Type == pemTypeCertificate {
+			cert.Certificate = append(cert.Certificate, block.Bytes)
+		}
+	}
+
+	if len(cert.Certificate) == 0 {
+		return nil, errors.New("missing certificate")
+	}
+
+	cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return nil, err
+	}
+
+	return cert, nil
+}
@@ -0,0 +1,334 @@
+package acme
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	acmeapi "golang.org/x/crypto/acme"
+
+	"github.com/zalando/skipper/eskip"
+	"github.com/zalando/skipper/routing"
+	"github.com/zalando/skipper/secrets/certregistry"
+)
+
+func selfSigned(t *testing.T, host string, notAfter time.Time) *tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		DNSNames:     []string{host},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     notAfter,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	require.NoError(t, err)
+
+	leaf, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	return &tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}
+}
+
+func newTestManager(t *testing.T, o Options) (*Manager, Storage, *certregistry.CertRegistry) {
+	storage, err := NewFileStorage(t.TempDir())
+	require.NoError(t, err)
+
+	o.Storage = storage
+	o.CertRegistry = certregistry.NewCertRegistry()
+	if o.DirectoryURL == "" {
+		// not expected to be used
+		o.DirectoryURL = "http://127.0.0.1:1/dir"
+	}
+
+	m, err := NewManager(o)
+	require.NoError(t, err)
+	t.Cleanup(m.Close)
+
+	return m, storage, o.CertRegistry
+}
+
+func hostRoute(rx string) *routing.Route {
+	return &routing.Route{Route: eskip.Route{HostRegexps: []string{rx}}}
+}
+
+func TestNewManagerOptions(t *testing.T) {
+	storage, err := NewFileStorage(t.TempDir())
+	require.NoError(t, err)
+
+	cr := certregistry.NewCertRegistry()
+	for _, o := range []Options{
+		{CertRegistry: cr},
+		{Storage: storage},
+		{Storage: storage, CertRegistry: cr, Challenges: []string{"dns-01"}},
+	} {
+		_, err := NewManager(o)
+		assert.Error(t, err)
+	}
+}
+
+func TestHTTPHandler(t *testing.T) {
+	m, storage, _ := newTestManager(t, Options{})
+	require.NoError(t, storage.Put(context.Background(), httpChallengePrefix+"shared-token", []byte("shared-token.thumbprint")))
+	m.challenges.Store(httpChallengePrefix+"local-token", []byte("local-token.thumbprint"))
+
+	h := m.HTTPHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}))
+
+	for _, tt := range []struct {
+		method, path string
+		status       int
+		body         string
+	}{
+		{"GET", "/.well-known/acme-challenge/shared-token", http.StatusOK, "shared-token.thumbprint"},
+		{"GET", "/.well-known/acme-challenge/local-token", http.StatusOK, "local-token.thumbprint"},
+		{"GET", "/.well-known/acme-challenge/unknown", http.StatusTeapot, ""},
+		{"POST", "/.well-known/acme-challenge/shared-token", http.StatusTeapot, ""},
+		{"GET", "/foo", http.StatusTeapot, ""},
+	} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, nil))
+		assert.Equal(t, tt.status, rec.Code, tt.path)
+		assert.Equal(t, tt.body, rec.Body.String(), tt.path)
+	}
+
+	m, _, _ = newTestManager(t, Options{Challenges: []string{ChallengeTLSALPN01}})
+	next := http.NewServeMux()
+	assert.Same(t, next, m.HTTPHandler(next))
+}
+
+func TestConfigureTLS(t *testing.T) {
+	m, storage, _ := newTestManager(t, Options{})
+	challenge := selfSigned(t, "example.org", time.Now().Add(time.Hour))
+	b, err := encodeCertificate(challenge)
+	require.NoError(t, err)
+	require.NoError(t, storage.Put(context.Background(), tlsChallengePrefix+"example.org", b))
+
+	regular := selfSigned(t, "example.org", time.Now().Add(90*24*time.Hour))
+	policy := &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert}
+	c := &tls.Config{
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) { return policy, nil },
+	}
+
+	m.ConfigureTLS(c)
+	challengeHello := func(host string) *tls.ClientHelloInfo {
+		return &tls.ClientHelloInfo{ServerName: host, SupportedProtos: []string{acmeapi.ALPNProto}}
+	}
+
+	// the storage is not checked for the hosts that are not ordered
+	_, err = c.GetConfigForClient(challengeHello("example.org"))
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	m.Do([]*routing.Route{hostRoute(`^example[.]org$`)})
+	cc, err := c.GetConfigForClient(challengeHello("example.org"))
+	require.NoError(t, err)
+	require.Len(t, cc.Certificates, 1)
+	assert.Equal(t, challenge.Certificate, cc.Certificates[0].Certificate)
+	assert.Equal(t, []string{acmeapi.ALPNProto}, cc.NextProtos)
+	assert.Equal(t, tls.NoClientCert, cc.ClientAuth)
+
+	m.mu.Lock()
+	m.leafs["example.org"] = regular.Leaf
+	m.mu.Unlock()
+	_, err = c.GetConfigForClient(challengeHello("example.org"))
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	_, err = c.GetConfigForClient(challengeHello("www.example.org"))
+	assert.Error(t, err)
+
+	cc, err = c.GetConfigForClient(&tls.ClientHelloInfo{ServerName: "example.org", SupportedProtos: []string{"h2", acmeapi.ALPNProto}})
+	require.NoError(t, err)
+	assert.Same(t, policy, cc)
+
+	m, _, _ = newTestManager(t, Options{Challenges: []string{ChallengeHTTP01}})
+	c = &tls.Config{}
+	m.ConfigureTLS(c)
+	assert.Nil(t, c.GetConfigForClient)
+}
+
+func TestConfigureServer(t *testing.T) {
+	m, _, _ := newTestManager(t, Options{})
+	srv := &http.Server{}
+	m.ConfigureServer(srv)
+	assert.Contains(t, srv.TLSNextProto, acmeapi.ALPNProto)
+	assert.True(t, srv.Protocols.HTTP1())
+	assert.True(t, srv.Protocols.HTTP2())
+
+	m, _, _ = newTestManager(t, Options{Challenges: []string{ChallengeHTTP01}})
+	srv = &http.Server{}
+	m.ConfigureServer(srv)
+	assert.Nil(t, srv.TLSNextProto)
+	assert.Nil(t, srv.Protocols)
+}
+
+func TestLoadStoredCertificate(t *testing.T) {
+	m, storage, cr := newTestManager(t, Options{Domains: []string{"Example.org."}})
+
+	stored := selfSigned(t, "www.example.org", time.Now().Add(90*24*time.Hour))
+	b, err := encodeCertificate(stored)
+	require.NoError(t, err)
+	require.NoError(t, storage.Put(context.Background(), certificatePrefix+"www.example.org", b))
+
+	m.Do([]*routing.Route{
+		hostRoute(`^www[.]example[.]org[.]?(:[0-9]+)?$`),
+		hostRoute(`^www[.]example[.]com$`),
+	})
+
+	m.mu.Lock()
+	assert.Equal(t, map[string]struct{}{"www.example.org": {}}, m.hosts)
+	m.mu.Unlock()
+
+	require.Eventually(t, func() bool {
+		cert, _ := cr.GetCertFromHello(&tls.ClientHelloInfo{ServerName: "www.example.org"})
+		return cert != nil
+	}, time.Second, 10*time.Millisecond)
+
+	cert, _ := cr.GetCertFromHello(&tls.ClientHelloInfo{ServerName: "www.example.org"})
+	assert.Equal(t, stored.Certificate, cert.Certificate)
+}
+
+func TestEncodeCertificate(t *testing.T) {
+	cert := selfSigned(t, "example.org", time.Now().Add(time.Hour))
+	b, err := encodeCertificate(cert)
+	require.NoError(t, err)
+
+	decoded, err := decodeCertificate(b)
+	require.NoError(t, err)
+	assert.Equal(t, cert.Certificate, decoded.Certificate)
+	assert.Equal(t, cert.PrivateKey, decoded.PrivateKey)
+	assert.Equal(t, []string{"example.org"}, decoded.Leaf.DNSNames)
+
+	_, err = decodeCertificate([]byte("invalid"))
+	assert.Error(t, err)
+
+	key, err := encodeKey(cert.PrivateKey.(*ecdsa.PrivateKey))
+	require.NoError(t, err)
+
+	_, err = decodeCertificate(key)
+	assert.Error(t, err)
+}
+
+type testLocker struct {
+	mu    sync.Mutex
+	locks map[string]bool
+}
+
+func (l *testLocker) TryLock(_ context.Context, name string, _ time.Duration) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.locks[name] {
+		return false, nil
+	}
+
+	l.locks[name] = true
+	return true, nil
+}
+
+func (l *testLocker) Unlock(_ context.Context, name string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.locks, name)
+	return nil
+}
+
+func TestAccountKeyLocked(t *testing.T) {
+	storage, err := NewFileStorage(t.TempDir())
+	require.NoError(t, err)
+
+	locker := &testLocker{locks: make(map[string]bool)}
+	newManager := func() *Manager {
+		m, err := NewManager(Options{
+			Storage:      storage,
+			CertRegistry: certregistry.NewCertRegistry(),
+			DirectoryURL: "http://127.0.0.1:1/dir",
+			Locker:       locker,
+		})
+		require.NoError(t, err)
+		t.Cleanup(m.Close)
+		return m
+	}
+
+	// another instance is creating the key
+	ok, err := locker.TryLock(context.Background(), accountLockName, time.Minute)
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	keys := make(chan crypto.Signer, 2)
+	for range 2 {
+		m := newManager()
+		go func() {
+			key, err := m.accountSigner(context.Background())
+			assert.NoError(t, err)
+			keys <- key
+		}()
+	}
+
+	time.Sleep(2 * accountPollInterval)
+	_, err = storage.Get(context.Background(), accountKey)
+	require.ErrorIs(t, err, ErrNotFound)
+
+	m := newManager()
+	stored, err := m.createAccountKey(context.Background())
+	require.NoError(t, err)
+	require.NoError(t, locker.Unlock(context.Background(), accountLockName))
+
+	for range 2 {
+		select {
+		case key := <-keys:
+			assert.Equal(t, stored.Public(), key.Public())
+		case <-time.After(5 * accountPollInterval):
+			t.Fatal("failed to load the account key")
+		}
+	}
+
+	assert.Empty(t, locker.locks)
+}
+
+// TestPebble tests ordering the certificates from a real ACME server.
+// Pebble runs with PEBBLE_VA_ALWAYS_VALID, so the challenge responders are
+// not exercised by this test, see TestHTTPHandler and TestConfigureTLS.
+func TestPebble(t *testing.T) {
+	directoryURL := startPebble(t)
+
+	m, storage, cr := newTestManager(t, Options{
+		DirectoryURL: directoryURL,
+		Email:        "admin@example.org",
+		Client: &http.Client{Transport: &http.Transport{
+			// Pebble uses a test certificate
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		}},
+	})
+
+	m.Do([]*routing.Route{hostRoute(`^(www[.])?example[.]org$`)})
+
+	for _, host := range []string{"example.org", "www.example.org"} {
+		require.Eventually(t, func() bool {
+			cert, _ := cr.GetCertFromHello(&tls.ClientHelloInfo{ServerName: host})
+			return cert != nil
+		}, time.Minute, 100*time.Millisecond, host)
+
+		cert, _ := cr.GetCertFromHello(&tls.ClientHelloInfo{ServerName: host})
+		assert.Equal(t, []string{host}, cert.Leaf.DNSNames)
+
+		_, err := storage.Get(context.Background(), certificatePrefix+host)
+		assert.NoError(t, err)
+	}
+
+	_, err := storage.Get(context.Background(), accountKey)
+	assert.NoError(t, err)
+}
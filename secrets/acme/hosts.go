@@ -0,0 +1,156 @@
+package acme
+
+import (
+	"regexp/syntax"
+	"strings"
+
+	"github.com/zalando/skipper/routing"
+)
+
+// maxHostExpansion limits the number of hosts resolved from a single
+// host regular expression.
+const maxHostExpansion = 64
+
+// routeHosts returns the hosts of the routes that can be resolved from
+// the Host predicates. Only the regular expressions matching a finite
+// set of literal hosts, e.g. ^(www[.])?example[.]org$, are resolved,
+// while the optional parts that are not literals, like the port, are
+// ignored, and so are the variants with the trailing dot.
+func routeHosts(routes []*routing.Route) map[string]struct{} {
+	hosts := make(map[string]struct{})
+	for _, r := range routes {
+		for _, rx := range r.HostRegexps {
+			for _, h := range hostsFromRegexp(rx) {
+				hosts[h] = struct{}{}
+			}
+		}
+	}
+
+	return hosts
+}
+
+func hostsFromRegexp(rx string) []string {
+	re, err := syntax.Parse(rx, syntax.Perl)
+	if err != nil {
+		return nil
+	}
+
+	expanded, ok := expand(re.Simplify())
+	if !ok {
+		return nil
+	}
+
+	var hosts []string
+	for _, h := range expanded {
+		h = strings.ToLower(h)
+		if validHost(h) {
+			hosts = append(hosts, h)
+		}
+	}
+
+	return hosts
+}
+
+func expand(re *syntax.Regexp) ([]string, bool) {
+	switch re.Op {
+	case syntax.OpEmptyMatch, syntax.OpBeginLine, syntax.OpEndLine, syntax.OpBeginText, syntax.OpEndText:
+		return []string{""}, true
+	case syntax.OpLiteral:
+		if re.Flags&syntax.FoldCase != 0 {
+			return []string{strings.ToLower(string(re.Rune))}, true
+		}
+
+		return []string{string(re.Rune)}, true
+	case syntax.OpCharClass:
+		// only the single character classes, like [.]
+		if len(re.Rune) == 2 && re.Rune[0] == re.Rune[1] {
+			return []string{string(re.Rune[0])}, true
+		}
+
+		return nil, false
+	case syntax.OpCapture:
+		return expand(re.Sub[0])
+	case syntax.OpQuest:
+		// the optional parts that are not literals, like the port,
+		// are ignored
+		s, ok := expand(re.Sub[0])
+		if !ok {
+			return []string{""}, true
+		}
+
+		return append([]string{""}, s...), true
+	case syntax.OpAlternate:
+		var all []string
+		for _, sub := range re.Sub {
+			s, ok := expand(sub)
+			if !ok {
+				return nil, false
+			}
+
+			all = append(all, s...)
+		}
+
+		return all, len(all) <= maxHostExpansion
+	case syntax.OpConcat:
+		all := []string{""}
+		for _, sub := range re.Sub {
+			s, ok := expand(sub)
+			if !ok {
+				return nil, false
+			}
+
+			var next []string
+			for _, prefix := range all {
+				for _, suffix := range s {
+					next = append(next, prefix+suffix)
+				}
+			}
+
+			if len(next) > maxHostExpansion {
+				return nil, false
+			}
+
+			all = next
+		}
+
+		return all, true
+	default:
+		return nil, false
+	}
+}
+
+func validHost(h string) bool {
+	if len(h) == 0 || len(h) > 253 || !strings.Contains(h, ".") {
+		return false
+	}
+
+	for _, label := range strings.Split(h, ".") {
+		if len(label) == 0 || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+
+		for _, c := range label {
+			if (c < 'a' || c > 'z') && (c < '0' || c > '9') && c != '-' {
+				return false
+			}
+		}
+	}
+
+	// IP addresses are not supported
+	last := h[strings.LastIndexByte(h, '.')+1:]
+	return strings.Trim(last, "0123456789") != ""
+}
+
+func allowedHost(domains []string, h string) bool {
+	if len(domains) == 0 {
+		return true
+	}
+
+	for _, d := range domains {
+		if h == d || strings.HasSuffix(h, "."+d) {
+			return true
+		}
+	}
+
+	return false
+}
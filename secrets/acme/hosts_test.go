@@ -0,0 +1,54 @@
+package acme
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/zalando/skipper/routing"
+)
+
+func TestHostsFromRegexp(t *testing.T) {
+	for _, tt := range []struct {
+		rx    string
+		hosts []string
+	}{
+		{`^example[.]org$`, []string{"example.org"}},
+		{`^example\.org$`, []string{"example.org"}},
+		{`^Example\.ORG$`, []string{"example.org"}},
+		{`example\.org`, []string{"example.org"}},
+		{`^example[.]org[.]?(:[0-9]+)?$`, []string{"example.org"}},
+		{`^(www[.])?example[.]org$`, []string{"example.org", "www.example.org"}},
+		{`^(example[.]org[.]?(:[0-9]+)?|example[.]com[.]?(:[0-9]+)?)$`, []string{"example.org", "example.com"}},
+		{`^(api|www)[.]example[.]org$`, []string{"api.example.org", "www.example.org"}},
+		{`^.*[.]example[.]org$`, nil},
+		{`^example.org$`, nil},
+		{`^[a-z]+[.]example[.]org$`, nil},
+		{`^example$`, nil},
+		{`^192[.]0[.]2[.]1$`, nil},
+		{`^-example[.]org$`, nil},
+		{`(`, nil},
+	} {
+		t.Run(tt.rx, func(t *testing.T) {
+			assert.ElementsMatch(t, tt.hosts, hostsFromRegexp(tt.rx))
+		})
+	}
+}
+
+func TestRouteHosts(t *testing.T) {
+	hosts := routeHosts([]*routing.Route{
+		hostRoute(`^example[.]org$`),
+		hostRoute(`^(www[.])?example[.]org$`),
+		{},
+	})
+
+	assert.Equal(t, map[string]struct{}{"example.org": {}, "www.example.org": {}}, hosts)
+}
+
+func TestAllowedHost(t *testing.T) {
+	assert.True(t, allowedHost(nil, "example.org"))
+	assert.True(t, allowedHost([]string{"example.org"}, "example.org"))
+	assert.True(t, allowedHost([]string{"example.com", "example.org"}, "www.example.org"))
+	assert.False(t, allowedHost([]string{"example.org"}, "wwwexample.org"))
+	assert.False(t, allowedHost([]string{"example.org"}, "example.com"))
+}
@@ -0,0 +1,242 @@
+package acme
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/zalando/skipper/dataclients/kubernetes/incluster"
+	"github.com/zalando/skipper/secrets"
+)
+
+const (
+	defaultKubernetesURL        = "http://localhost:8001"
+	defaultSecretPrefix         = "skipper-acme"
+	secretsNamespaceFmt         = "/api/v1/namespaces/%s/secrets"
+	secretDataKey               = "data"
+	secretKeyAnnotation         = "skipper.io/acme-key"
+	managedByLabel              = "app.kubernetes.io/managed-by"
+	managedByValue              = "skipper-acme"
+	defaultKubernetesAPITimeout = 10 * time.Second
+)
+
+// KubernetesStorageOptions configures the storage in Kubernetes
+// secrets.
+type KubernetesStorageOptions struct {
+
+	// Namespace of the secrets. Required.
+	Namespace string
+
+	// InCluster uses the service account of the pod to access the
+	// Kubernetes API.
+	InCluster bool
+
+	// APIURL is the URL of the Kubernetes API, used when not running
+	// in the cluster. Defaults to http://localhost:8001.
+	APIURL string
+
+	// SecretPrefix is the name prefix of the created secrets. Defaults
+	// to skipper-acme.
+	SecretPrefix string
+
+	// Client is used to access the Kubernetes API. Defaults to a
+	// client with 10 seconds timeout, trusting the cluster CA when
+	// running in the cluster.
+	Client *http.Client
+}
+
+type kubernetesStorage struct {
+	options KubernetesStorageOptions
+	secrets string
+	tokens  *secrets.SecretPaths
+}
+
+type secret struct {
+	APIVersion string            `json:"apiVersion"`
+	Kind       string            `json:"kind"`
+	Metadata   secretMetadata    `json:"metadata"`
+	Type       string            `json:"type"`
+	Data       map[string][]byte `json:"data"`
+}
+
+type secretMetadata struct {
+	Name        string            `json:"name"`
+	Namespace   string            `json:"namespace"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// NewKubernetesStorage creates a storage that keeps the data in
+// Kubernetes secrets, one secret for every key. The service account of
+// Skipper needs permissions to get, create, update and delete the
+// secrets in the namespace.
+func NewKubernetesStorage(o KubernetesStorageOptions) (Storage, error) {
+	if o.Namespace == "" {
+		return nil, errors.New("acme storage: missing Kubernetes namespace")
+	}
+
+	if o.SecretPrefix == "" {
+		o.SecretPrefix = defaultSecretPrefix
+	}
+
+	s := &kubernetesStorage{}
+	if o.InCluster {
+		apiURL, err := incluster.APIURL()
+		if err != nil {
+			return nil, fmt.Errorf("acme storage: %w", err)
+		}
+
+		o.APIURL = apiURL
+		if o.Client == nil {
+			// the storage is used until the process exits
+			client, err := incluster.NewHTTPClient(incluster.RootCAFile, nil)
+			if err != nil {
+				return nil, fmt.Errorf("acme storage: %w", err)
+			}
+
+			client.Timeout = defaultKubernetesAPITimeout
+			o.Client = client
+		}
+
+		s.tokens = secrets.NewSecretPaths(time.Minute)
+		if err := s.tokens.Add(incluster.TokenFile); err != nil {
+			return nil, fmt.Errorf("acme storage: failed to add secret %s: %w", incluster.TokenFile, err)
+		}
+	} else if o.APIURL == "" {
+		o.APIURL = defaultKubernetesURL
+	}
+
+	if o.Client == nil {
+		o.Client = &http.Client{Timeout: defaultKubernetesAPITimeout}
+	}
+
+	s.options = o
+	s.secrets = strings.TrimSuffix(o.APIURL, "/") + fmt.Sprintf(secretsNamespaceFmt, o.Namespace)
+	return s, nil
+}
+
+// secretName maps the storage keys to valid secret names. The key is
+// stored in an annotation of the secret.
+func (s *kubernetesStorage) secretName(key string) string {
+	h := sha256.Sum256([]byte(key))
+	return s.options.SecretPrefix + "-" + hex.EncodeToString(h[:10])
+}
+
+func (s *kubernetesStorage) do(ctx context.Context, method, url string, body interface{}) (*http.Response, error) {
+	var r io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+
+		r = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, r)
+	if err != nil {
+		return nil, err
+	}
+
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	if s.tokens != nil {
+		token, ok := s.tokens.GetSecret(incluster.TokenFile)
+		if !ok {
+			return nil, fmt.Errorf("acme storage: secret not found: %s", incluster.TokenFile)
+		}
+
+		req.Header.Set("Authorization", "Bearer "+string(token))
+	}
+
+	return s.options.Client.Do(req)
+}
+
+func (s *kubernetesStorage) Get(ctx context.Context, key string) ([]byte, error) {
+	rsp, err := s.do(ctx, "GET", s.secrets+"/"+s.secretName(key), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rsp.Body.Close()
+	switch rsp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, ErrNotFound
+	default:
+		return nil, fmt.Errorf("acme storage: failed to get secret for %s: %s", key, rsp.Status)
+	}
+
+	var sec secret
+	if err := json.NewDecoder(rsp.Body).Decode(&sec); err != nil {
+		return nil, err
+	}
+
+	data, ok := sec.Data[secretDataKey]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	return data, nil
+}
+
+func (s *kubernetesStorage) Put(ctx context.Context, key string, data []byte) error {
+	name := s.secretName(key)
+	sec := &secret{
+		APIVersion: "v1",
+		Kind:       "Secret",
+		Metadata: secretMetadata{
+			Name:        name,
+			Namespace:   s.options.Namespace,
+			Labels:      map[string]string{managedByLabel: managedByValue},
+			Annotations: map[string]string{secretKeyAnnotation: key},
+		},
+		Type: "Opaque",
+		Data: map[string][]byte{secretDataKey: data},
+	}
+
+	rsp, err := s.do(ctx, "PUT", s.secrets+"/"+name, sec)
+	if err != nil {
+		return err
+	}
+
+	rsp.Body.Close()
+	if rsp.StatusCode == http.StatusNotFound {
+		rsp, err = s.do(ctx, "POST", s.secrets, sec)
+		if err != nil {
+			return err
+		}
+
+		rsp.Body.Close()
+	}
+
+	if rsp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("acme storage: failed to store secret for %s: %s", key, rsp.Status)
+	}
+
+	return nil
+}
+
+func (s *kubernetesStorage) Delete(ctx context.Context, key string) error {
+	rsp, err := s.do(ctx, "DELETE", s.secrets+"/"+s.secretName(key), nil)
+	if err != nil {
+		return err
+	}
+
+	rsp.Body.Close()
+	if rsp.StatusCode >= http.StatusMultipleChoices && rsp.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("acme storage: failed to delete secret for %s: %s", key, rsp.Status)
+	}
+
+	return nil
+}
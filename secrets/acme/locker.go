@@ -0,0 +1,121 @@
+package acme
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"hash/fnv"
+	"time"
+
+	snet "github.com/zalando/skipper/net"
+	"github.com/zalando/skipper/ratelimit"
+)
+
+const (
+	swarmNodesKey        = "acme.nodes"
+	swarmNodeTTLFactor   = 3
+	redisLockPrefix      = "acme.lock."
+	redisTryLockScript   = `return redis.call("SET", KEYS[1], ARGV[1], "NX", "PX", ARGV[2]) and 1 or 0`
+	redisUnlockScript    = `if redis.call("GET", KEYS[1]) == ARGV[1] then return redis.call("DEL", KEYS[1]) else return 0 end`
+	defaultSwarmInterval = defaultCheckInterval
+)
+
+// Locker coordinates the certificate orders between the Skipper
+// instances, to order every certificate by a single instance. The other
+// instances load the certificate from the shared storage.
+type Locker interface {
+
+	// TryLock acquires the lock with the name for the given duration.
+	// It returns false when another instance holds the lock.
+	TryLock(ctx context.Context, name string, ttl time.Duration) (bool, error)
+
+	// Unlock releases the lock with the name, when it is held by the
+	// current instance.
+	Unlock(ctx context.Context, name string) error
+}
+
+type redisLocker struct {
+	ring   *snet.RedisRingClient
+	owner  string
+	lock   *snet.RedisScript
+	unlock *snet.RedisScript
+}
+
+type swarmLocker struct {
+	swarm    ratelimit.Swarmer
+	self     string
+	interval time.Duration
+	now      func() time.Time
+}
+
+// NewRedisLocker creates a Locker that holds the locks as Redis keys
+// with expiration.
+func NewRedisLocker(ring *snet.RedisRingClient) Locker {
+	owner := make([]byte, 16)
+	_, _ = rand.Read(owner)
+	return &redisLocker{
+		ring:   ring,
+		owner:  hex.EncodeToString(owner),
+		lock:   ring.NewScript(redisTryLockScript),
+		unlock: ring.NewScript(redisUnlockScript),
+	}
+}
+
+func (l *redisLocker) TryLock(ctx context.Context, name string, ttl time.Duration) (bool, error) {
+	r, err := l.ring.RunScript(ctx, l.lock, []string{redisLockPrefix + name}, l.owner, ttl.Milliseconds())
+	if err != nil {
+		return false, err
+	}
+
+	return r.(int64) == 1, nil
+}
+
+func (l *redisLocker) Unlock(ctx context.Context, name string) error {
+	_, err := l.ring.RunScript(ctx, l.unlock, []string{redisLockPrefix + name}, l.owner)
+	return err
+}
+
+// NewSwarmLocker creates a Locker based on the SWIM swarm. The instances
+// share their presence in the swarm, and the lock is acquired only by
+// the instance selected with rendezvous hashing from the live
+// instances. The instances are expected to call TryLock at least once
+// in the given interval. The self argument is the name of the local
+// node in the swarm.
+func NewSwarmLocker(swarm ratelimit.Swarmer, self string, interval time.Duration) Locker {
+	if interval <= 0 {
+		interval = defaultSwarmInterval
+	}
+
+	return &swarmLocker{swarm: swarm, self: self, interval: interval, now: time.Now}
+}
+
+func (l *swarmLocker) TryLock(ctx context.Context, name string, ttl time.Duration) (bool, error) {
+	now := l.now()
+	if err := l.swarm.ShareValue(swarmNodesKey, now.Unix()); err != nil {
+		return false, err
+	}
+
+	var (
+		owner   string
+		maxHash uint64
+	)
+
+	for node, v := range l.swarm.Values(swarmNodesKey) {
+		seen, ok := v.(int64)
+		if !ok || now.Sub(time.Unix(seen, 0)) > swarmNodeTTLFactor*l.interval {
+			continue
+		}
+
+		h := fnv.New64a()
+		h.Write([]byte(node))
+		h.Write([]byte(name))
+		if s := h.Sum64(); owner == "" || s > maxHash || s == maxHash && node < owner {
+			owner, maxHash = node, s
+		}
+	}
+
+	// when the values were not shared yet, every instance orders
+	return owner == "" || owner == l.self, nil
+}
+
+func (*swarmLocker) Unlock(context.Context, string) error { return nil }
@@ -0,0 +1,114 @@
+package acme
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zalando/skipper/net"
+	"github.com/zalando/skipper/net/redistest"
+)
+
+// fakeSwarm shares the values between the nodes in memory.
+type fakeSwarm struct {
+	self   string
+	values map[string]map[string]interface{}
+}
+
+func (s *fakeSwarm) ShareValue(key string, value interface{}) error {
+	if s.values[key] == nil {
+		s.values[key] = make(map[string]interface{})
+	}
+
+	s.values[key][s.self] = value
+	return nil
+}
+
+func (s *fakeSwarm) Values(key string) map[string]interface{} {
+	return s.values[key]
+}
+
+func TestSwarmLocker(t *testing.T) {
+	values := make(map[string]map[string]interface{})
+	now := time.Now()
+	lockers := make(map[string]*swarmLocker)
+	for _, node := range []string{"node-1", "node-2", "node-3"} {
+		l := NewSwarmLocker(&fakeSwarm{self: node, values: values}, node, time.Minute).(*swarmLocker)
+		l.now = func() time.Time { return now }
+		lockers[node] = l
+	}
+
+	// every node shares its presence
+	for _, l := range lockers {
+		_, err := l.TryLock(context.Background(), "order.example.org", time.Minute)
+		require.NoError(t, err)
+	}
+
+	owners := make(map[string]int)
+	for _, host := range []string{"a.example.org", "b.example.org", "c.example.org", "d.example.org", "e.example.org", "f.example.org"} {
+		var locked []string
+		for node, l := range lockers {
+			ok, err := l.TryLock(context.Background(), "order."+host, time.Minute)
+			require.NoError(t, err)
+			if ok {
+				locked = append(locked, node)
+			}
+		}
+
+		require.Len(t, locked, 1, host)
+		owners[locked[0]]++
+	}
+
+	assert.Greater(t, len(owners), 1, "expected the orders to be distributed")
+
+	// the nodes not seen recently are ignored
+	values[swarmNodesKey]["node-2"] = now.Add(-time.Hour).Unix()
+	values[swarmNodesKey]["node-3"] = now.Add(-time.Hour).Unix()
+	ok, err := lockers["node-1"].TryLock(context.Background(), "order.example.org", time.Minute)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.NoError(t, lockers["node-1"].Unlock(context.Background(), "order.example.org"))
+}
+
+func TestRedisLocker(t *testing.T) {
+	redisAddr, done := redistest.NewTestRedis(t)
+	defer done()
+
+	ring := net.NewRedisRingClient(&net.RedisOptions{Addrs: []string{redisAddr}})
+	defer ring.Close()
+
+	l1, l2 := NewRedisLocker(ring), NewRedisLocker(ring)
+	ctx := context.Background()
+
+	ok, err := l1.TryLock(ctx, "order.example.org", time.Minute)
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	ok, err = l2.TryLock(ctx, "order.example.org", time.Minute)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	// only the holder releases the lock
+	require.NoError(t, l2.Unlock(ctx, "order.example.org"))
+	ok, err = l2.TryLock(ctx, "order.example.org", time.Minute)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	require.NoError(t, l1.Unlock(ctx, "order.example.org"))
+	ok, err = l2.TryLock(ctx, "order.example.org", time.Minute)
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	// expired locks can be acquired
+	ok, err = l1.TryLock(ctx, "order.example.com", time.Millisecond)
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	time.Sleep(10 * time.Millisecond)
+	ok, err = l2.TryLock(ctx, "order.example.com", time.Minute)
+	require.NoError(t, err)
+	assert.True(t, ok)
+}
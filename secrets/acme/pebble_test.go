@@ -0,0 +1,54 @@
+package acme
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/wait"
+)
+
+const pebbleImage = "ghcr.io/letsencrypt/pebble:v2.10.1"
+
+// startPebble starts the Pebble test ACME server, and returns its
+// directory URL. The challenges are not validated by the server, because
+// its validation authority can't reach the responders of the test, so
+// the responders are not covered by the tests using Pebble.
+func startPebble(t *testing.T) string {
+	ctx, cancel := context.WithTimeout(context.Background(), 40*time.Second)
+	defer cancel()
+
+	container, err := testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
+		ContainerRequest: testcontainers.ContainerRequest{
+			Image:        pebbleImage,
+			ExposedPorts: []string{"14000/tcp"},
+			Env: map[string]string{
+				"PEBBLE_VA_ALWAYS_VALID": "1",
+				"PEBBLE_VA_NOSLEEP":      "1",
+			},
+			WaitingFor: wait.ForListeningPort("14000/tcp"),
+		},
+		Started: true,
+	})
+	if err != nil {
+		t.Fatalf("Failed to start pebble: %v", err)
+	}
+
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		if err := container.Terminate(ctx); err != nil {
+			t.Errorf("Failed to stop pebble: %v", err)
+		}
+	})
+
+	ip, err := container.ContainerIP(ctx)
+	if err != nil {
+		t.Fatalf("Failed to get pebble container ip: %v", err)
+	}
+
+	return fmt.Sprintf("https://%s:14000/dir", ip)
+}
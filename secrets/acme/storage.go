@@ -0,0 +1,89 @@
+package acme
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ErrNotFound is returned by the storage when the requested key doesn't
+// exist.
+var ErrNotFound = errors.New("acme storage: not found")
+
+// Storage stores the account key, the issued certificates and the
+// pending challenges. When Skipper runs with multiple instances, the
+// storage should be shared between them, to allow any instance to
+// answer the challenges and to load the certificates ordered by the
+// other instances.
+type Storage interface {
+
+	// Get returns the data stored with the key, or ErrNotFound.
+	Get(ctx context.Context, key string) ([]byte, error)
+
+	// Put stores the data with the key, replacing the previous data.
+	Put(ctx context.Context, key string, data []byte) error
+
+	// Delete deletes the data stored with the key. It doesn't fail
+	// when the key doesn't exist.
+	Delete(ctx context.Context, key string) error
+}
+
+type fileStorage struct {
+	dir string
+}
+
+// NewFileStorage creates a storage that keeps the data in the files of a
+// directory, created when it doesn't exist. The private keys are stored
+// unencrypted, readable only by the owner.
+func NewFileStorage(dir string) (Storage, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+
+	return &fileStorage{dir: dir}, nil
+}
+
+func (s *fileStorage) path(key string) string {
+	return filepath.Join(s.dir, strings.ReplaceAll(key, "/", "_"))
+}
+
+func (s *fileStorage) Get(ctx context.Context, key string) ([]byte, error) {
+	b, err := os.ReadFile(s.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+
+	return b, err
+}
+
+func (s *fileStorage) Put(ctx context.Context, key string, data []byte) error {
+	// writing to a temporary file and renaming it, to not expose
+	// partially written data to the other processes
+	f, err := os.CreateTemp(s.dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+
+	defer os.Remove(f.Name())
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), s.path(key))
+}
+
+func (s *fileStorage) Delete(ctx context.Context, key string) error {
+	err := os.Remove(s.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+
+	return err
+}
@@ -0,0 +1,119 @@
+package acme
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testStorage(t *testing.T, s Storage) {
+	ctx := context.Background()
+
+	_, err := s.Get(ctx, "certificates/example.org")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	require.NoError(t, s.Put(ctx, "certificates/example.org", []byte("foo")))
+	b, err := s.Get(ctx, "certificates/example.org")
+	require.NoError(t, err)
+	assert.Equal(t, "foo", string(b))
+
+	require.NoError(t, s.Put(ctx, "certificates/example.org", []byte("bar")))
+	b, err = s.Get(ctx, "certificates/example.org")
+	require.NoError(t, err)
+	assert.Equal(t, "bar", string(b))
+
+	require.NoError(t, s.Delete(ctx, "certificates/example.org"))
+	_, err = s.Get(ctx, "certificates/example.org")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	assert.NoError(t, s.Delete(ctx, "certificates/example.org"))
+}
+
+func TestFileStorage(t *testing.T) {
+	s, err := NewFileStorage(t.TempDir() + "/acme")
+	require.NoError(t, err)
+	testStorage(t, s)
+}
+
+// fakeSecrets implements the secrets resource of the Kubernetes API
+// in a single namespace.
+type fakeSecrets struct {
+	mu      sync.Mutex
+	secrets map[string]*secret
+}
+
+func (f *fakeSecrets) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	const prefix = "/api/v1/namespaces/skipper/secrets"
+	if !strings.HasPrefix(r.URL.Path, prefix) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	name := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, prefix), "/")
+	switch r.Method {
+	case "GET", "DELETE":
+		s, ok := f.secrets[name]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		if r.Method == "DELETE" {
+			delete(f.secrets, name)
+		}
+
+		json.NewEncoder(w).Encode(s)
+	case "PUT", "POST":
+		var s secret
+		if err := json.NewDecoder(r.Body).Decode(&s); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		_, exists := f.secrets[s.Metadata.Name]
+		switch {
+		case r.Method == "PUT" && (!exists || name != s.Metadata.Name):
+			w.WriteHeader(http.StatusNotFound)
+			return
+		case r.Method == "POST" && (exists || name != ""):
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+
+		f.secrets[s.Metadata.Name] = &s
+		json.NewEncoder(w).Encode(s)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func TestKubernetesStorage(t *testing.T) {
+	_, err := NewKubernetesStorage(KubernetesStorageOptions{})
+	assert.Error(t, err)
+
+	api := &fakeSecrets{secrets: make(map[string]*secret)}
+	server := httptest.NewServer(api)
+	defer server.Close()
+
+	s, err := NewKubernetesStorage(KubernetesStorageOptions{Namespace: "skipper", APIURL: server.URL})
+	require.NoError(t, err)
+	testStorage(t, s)
+
+	require.NoError(t, s.Put(context.Background(), "http-01/Token_With-Invalid_Chars", []byte("foo")))
+	require.Len(t, api.secrets, 1)
+	for name, sec := range api.secrets {
+		assert.Regexp(t, `^skipper-acme-[0-9a-f]+$`, name)
+		assert.Equal(t, "http-01/Token_With-Invalid_Chars", sec.Metadata.Annotations[secretKeyAnnotation])
+		assert.Equal(t, "foo", string(sec.Data[secretDataKey]))
+	}
+}
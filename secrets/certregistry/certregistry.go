@@ -14,6 +14,10 @@ import (
 type CertRegistry struct {
 	mu     sync.Mutex
 	lookup map[string]*tls.Certificate
+
+	policySources []string
+	policies      map[string]map[string]*TLSPolicy
+	policyVersion uint64
 }
 
 // NewCertRegistry initializes the certificate registry.
@@ -21,7 +25,8 @@ func NewCertRegistry() *CertRegistry {
 	l := make(map[string]*tls.Certificate)
 
 	return &CertRegistry{
-		lookup: l,
+		lookup:   l,
+		policies: make(map[string]map[string]*TLSPolicy),
 	}
 }
 
@@ -0,0 +1,286 @@
+package certregistry
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/crypto/ocsp"
+)
+
+const (
+	defaultOCSPCheckInterval = time.Minute
+	defaultOCSPRetryInterval = 5 * time.Minute
+	defaultOCSPTimeout       = 10 * time.Second
+
+	// the certificates not served for this duration are dropped from
+	// the background refresh
+	ocspUnusedTimeout = 24 * time.Hour
+	maxOCSPResponse   = 1 << 20
+)
+
+// OCSPOptions configures the OCSP stapling.
+type OCSPOptions struct {
+
+	// Client is used to request the OCSP responses. Defaults to an
+	// HTTP client with 10s timeout.
+	Client *http.Client
+
+	// CheckInterval is the interval of checking whether the OCSP
+	// responses need to be refreshed. Defaults to one minute.
+	CheckInterval time.Duration
+
+	// RetryInterval is the time to wait before requesting the OCSP
+	// response again after a failure. Defaults to five minutes.
+	RetryInterval time.Duration
+}
+
+// OCSPStapler staples the OCSP responses to the certificates served by
+// a TLS listener. The responses are requested from the OCSP server of
+// the certificate issuer when a certificate is served first, and
+// refreshed in the background halfway to their expiry. The
+// certificates without an OCSP server or without the issuer in the
+// chain are served without a staple.
+type OCSPStapler struct {
+	options OCSPOptions
+	mu      sync.Mutex
+	staples map[string]*ocspStaple
+	now     func() time.Time
+	quit    chan struct{}
+	once    sync.Once
+}
+
+type ocspStaple struct {
+	cert       *tls.Certificate
+	stapled    *tls.Certificate
+	nextUpdate time.Time
+	refreshAt  time.Time
+	lastUsed   time.Time
+	pending    bool
+	noServer   bool
+}
+
+// NewOCSPStapler creates an OCSPStapler and starts the background
+// refresh. It needs to be closed when not used anymore.
+func NewOCSPStapler(o OCSPOptions) *OCSPStapler {
+	if o.Client == nil {
+		o.Client = &http.Client{Timeout: defaultOCSPTimeout}
+	}
+
+	if o.CheckInterval <= 0 {
+		o.CheckInterval = defaultOCSPCheckInterval
+	}
+
+	if o.RetryInterval <= 0 {
+		o.RetryInterval = defaultOCSPRetryInterval
+	}
+
+	s := &OCSPStapler{
+		options: o,
+		staples: make(map[string]*ocspStaple),
+		now:     time.Now,
+		quit:    make(chan struct{}),
+	}
+
+	go s.run()
+	return s
+}
+
+// ConfigureTLS wraps the certificate selection of the TLS
+// configuration, including the static certificates, to return the
+// certificates with the stapled OCSP responses.
+func (s *OCSPStapler) ConfigureTLS(c *tls.Config) {
+	next := c.GetCertificate
+	static := c.Certificates
+	c.GetCertificate = func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+		var cert *tls.Certificate
+		if next != nil {
+			var err error
+			if cert, err = next(hello); err != nil {
+				return nil, err
+			}
+		}
+
+		if cert == nil {
+			cert = selectStatic(hello, static)
+		}
+
+		if cert == nil {
+			return nil, nil
+		}
+
+		return s.staple(cert), nil
+	}
+}
+
+func selectStatic(hello *tls.ClientHelloInfo, static []tls.Certificate) *tls.Certificate {
+	if len(static) == 0 {
+		return nil
+	}
+
+	for i := range static {
+		if hello.SupportsCertificate(&static[i]) == nil {
+			return &static[i]
+		}
+	}
+
+	return &static[0]
+}
+
+// Close stops the background refresh.
+func (s *OCSPStapler) Close() {
+	s.once.Do(func() { close(s.quit) })
+}
+
+func (s *OCSPStapler) staple(cert *tls.Certificate) *tls.Certificate {
+	if len(cert.Certificate) < 2 || len(cert.OCSPStaple) > 0 {
+		return cert
+	}
+
+	key := string(cert.Certificate[0])
+	now := s.now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st, ok := s.staples[key]
+	if !ok {
+		st = &ocspStaple{cert: cert, pending: true}
+		s.staples[key] = st
+		go s.refresh(st)
+	}
+
+	st.lastUsed = now
+	if st.stapled != nil && now.Before(st.nextUpdate) {
+		return st.stapled
+	}
+
+	return cert
+}
+
+func (s *OCSPStapler) run() {
+	ticker := time.NewTicker(s.options.CheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.refreshDue()
+		case <-s.quit:
+			return
+		}
+	}
+}
+
+func (s *OCSPStapler) refreshDue() {
+	now := s.now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for key, st := range s.staples {
+		if now.Sub(st.lastUsed) > ocspUnusedTimeout {
+			delete(s.staples, key)
+			continue
+		}
+
+		if !st.pending && !st.noServer && !now.Before(st.refreshAt) {
+			st.pending = true
+			go s.refresh(st)
+		}
+	}
+}
+
+func (s *OCSPStapler) refresh(st *ocspStaple) {
+	resp, raw, err := s.request(st.cert)
+	now := s.now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st.pending = false
+	if err != nil {
+		log.Errorf("Failed to refresh OCSP response: %v", err)
+		st.refreshAt = now.Add(s.options.RetryInterval)
+		return
+	}
+
+	if resp == nil {
+		// checked again only when the certificate is served after
+		// being dropped as unused
+		st.noServer = true
+		return
+	}
+
+	if resp.Status != ocsp.Good {
+		log.Warnf("OCSP status of certificate %v is not good: %d", resp.SerialNumber, resp.Status)
+		st.stapled = nil
+		st.refreshAt = now.Add(s.options.RetryInterval)
+		return
+	}
+
+	stapled := *st.cert
+	stapled.OCSPStaple = raw
+	st.stapled = &stapled
+	st.nextUpdate = resp.NextUpdate
+	if resp.NextUpdate.IsZero() {
+		st.nextUpdate = now.Add(ocspUnusedTimeout)
+	}
+
+	st.refreshAt = resp.ThisUpdate.Add(st.nextUpdate.Sub(resp.ThisUpdate) / 2)
+	if st.refreshAt.Before(now) {
+		st.refreshAt = now.Add(s.options.RetryInterval)
+	}
+}
+
+func (s *OCSPStapler) request(cert *tls.Certificate) (*ocsp.Response, []byte, error) {
+	leaf := cert.Leaf
+	if leaf == nil {
+		var err error
+		if leaf, err = x509.ParseCertificate(cert.Certificate[0]); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if len(leaf.OCSPServer) == 0 {
+		return nil, nil, nil
+	}
+
+	issuer, err := x509.ParseCertificate(cert.Certificate[1])
+	if err != nil {
+		return nil, nil, err
+	}
+
+	req, err := ocsp.CreateRequest(leaf, issuer, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rsp, err := s.options.Client.Post(leaf.OCSPServer[0], "application/ocsp-request", bytes.NewReader(req))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	defer rsp.Body.Close()
+	if rsp.StatusCode != http.StatusOK {
+		return nil, nil, fmt.Errorf("unexpected OCSP response status from %s: %d", leaf.OCSPServer[0], rsp.StatusCode)
+	}
+
+	raw, err := io.ReadAll(io.LimitReader(rsp.Body, maxOCSPResponse))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	resp, err := ocsp.ParseResponseForCert(raw, leaf, issuer)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return resp, raw, nil
+}
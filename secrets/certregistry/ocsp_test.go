@@ -0,0 +1,203 @@
+package certregistry
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ocsp"
+)
+
+type ocspResponder struct {
+	issuer   *x509.Certificate
+	key      crypto.Signer
+	status   atomic.Int64
+	requests atomic.Int64
+	validity time.Duration
+}
+
+func (r *ocspResponder) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.requests.Add(1)
+	b, err := io.ReadAll(req.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	ocspReq, err := ocsp.ParseRequest(b)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	now := time.Now()
+	rsp, err := ocsp.CreateResponse(r.issuer, r.issuer, ocsp.Response{
+		Status:       int(r.status.Load()),
+		SerialNumber: ocspReq.SerialNumber,
+		ThisUpdate:   now.Add(-r.validity / 2),
+		NextUpdate:   now.Add(r.validity / 2),
+	}, r.key)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Write(rsp)
+}
+
+func newOCSPCertificate(t *testing.T, ocspServer string) (*tls.Certificate, *ocspResponder) {
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, caKey.Public(), caKey)
+	require.NoError(t, err)
+
+	issuer, err := x509.ParseCertificate(caDER)
+	require.NoError(t, err)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		DNSNames:     []string{"example.org"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+
+	if ocspServer != "" {
+		template.OCSPServer = []string{ocspServer}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, issuer, key.Public(), caKey)
+	require.NoError(t, err)
+
+	return &tls.Certificate{Certificate: [][]byte{der, caDER}, PrivateKey: key},
+		&ocspResponder{issuer: issuer, key: caKey, validity: time.Hour}
+}
+
+func newTestStapler(t *testing.T) *OCSPStapler {
+	s := NewOCSPStapler(OCSPOptions{CheckInterval: 10 * time.Millisecond, RetryInterval: 10 * time.Millisecond})
+	t.Cleanup(s.Close)
+	return s
+}
+
+func TestOCSPStapling(t *testing.T) {
+	var responder http.Handler
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		responder.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	cert, r := newOCSPCertificate(t, server.URL)
+	responder = r
+
+	s := newTestStapler(t)
+	c := &tls.Config{Certificates: []tls.Certificate{*cert}}
+	s.ConfigureTLS(c)
+
+	hello := &tls.ClientHelloInfo{ServerName: "example.org"}
+	require.Eventually(t, func() bool {
+		stapled, err := c.GetCertificate(hello)
+		require.NoError(t, err)
+		return len(stapled.OCSPStaple) > 0
+	}, time.Second, 10*time.Millisecond)
+
+	stapled, _ := c.GetCertificate(hello)
+	assert.Equal(t, cert.Certificate, stapled.Certificate)
+
+	rsp, err := ocsp.ParseResponse(stapled.OCSPStaple, r.issuer)
+	require.NoError(t, err)
+	assert.Equal(t, ocsp.Good, rsp.Status)
+
+	// the static certificates are not modified
+	assert.Empty(t, c.Certificates[0].OCSPStaple)
+
+	// refreshed halfway to the expiry
+	s.mu.Lock()
+	for _, st := range s.staples {
+		st.refreshAt = time.Now()
+	}
+	s.mu.Unlock()
+
+	requests := r.requests.Load()
+	r.status.Store(ocsp.Revoked)
+	require.Eventually(t, func() bool {
+		stapled, _ := c.GetCertificate(hello)
+		return r.requests.Load() > requests && len(stapled.OCSPStaple) == 0
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestOCSPStaplingWrapsGetCertificate(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+
+	cert, _ := newOCSPCertificate(t, server.URL)
+	s := newTestStapler(t)
+	c := &tls.Config{GetCertificate: func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+		if hello.ServerName == "example.org" {
+			return cert, nil
+		}
+
+		return nil, nil
+	}}
+	s.ConfigureTLS(c)
+
+	got, err := c.GetCertificate(&tls.ClientHelloInfo{ServerName: "example.org"})
+	require.NoError(t, err)
+	assert.Same(t, cert, got)
+
+	got, err = c.GetCertificate(&tls.ClientHelloInfo{ServerName: "other.example.org"})
+	require.NoError(t, err)
+	assert.Nil(t, got)
+
+	// failed requests are retried
+	require.Eventually(t, func() bool {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		st := s.staples[string(cert.Certificate[0])]
+		return st != nil && !st.pending && !st.refreshAt.IsZero()
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestOCSPStaplingUnsupported(t *testing.T) {
+	s := newTestStapler(t)
+
+	cert, _ := newOCSPCertificate(t, "")
+	assert.Same(t, cert, s.staple(cert))
+	require.Eventually(t, func() bool {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return s.staples[string(cert.Certificate[0])].noServer
+	}, time.Second, 10*time.Millisecond)
+
+	selfSigned := &tls.Certificate{Certificate: cert.Certificate[:1], PrivateKey: cert.PrivateKey}
+	assert.Same(t, selfSigned, s.staple(selfSigned))
+
+	s.mu.Lock()
+	assert.Len(t, s.staples, 1)
+	s.mu.Unlock()
+}
@@ -0,0 +1,278 @@
+package certregistry
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v2"
+)
+
+// TLSPolicy overrides the TLS configuration of the listener for the
+// connections of a host, selected by the SNI. The zero value fields
+// keep the configuration of the listener.
+type TLSPolicy struct {
+	MinVersion   uint16
+	CipherSuites []uint16
+	NextProtos   []string
+	ClientAuth   *tls.ClientAuthType
+	ClientCAs    *x509.CertPool
+}
+
+// PolicySpec is the textual representation of a TLSPolicy, as used in
+// the policy file and in the RouteGroup TLS specs.
+type PolicySpec struct {
+	// MinVersion is the minimum TLS version, one of 1.0, 1.1, 1.2 or 1.3.
+	MinVersion string `yaml:"minVersion"`
+
+	// CipherSuites lists the names of the TLS 1.0-1.2 cipher suites,
+	// e.g. TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256.
+	CipherSuites []string `yaml:"cipherSuites"`
+
+	// ALPNProtocols lists the supported application protocols, in the
+	// order of preference, e.g. h2 and http/1.1.
+	ALPNProtocols []string `yaml:"alpnProtocols"`
+
+	// ClientAuth is the client certificate policy, one of none,
+	// optional or require. The optional and required client
+	// certificates are verified with the client CAs of the policy.
+	ClientAuth string `yaml:"clientAuth"`
+}
+
+type policyFile struct {
+	Policies []struct {
+		PolicySpec   `yaml:",inline"`
+		Hosts        []string `yaml:"hosts"`
+		ClientCAFile string   `yaml:"clientCAFile"`
+	} `yaml:"policies"`
+}
+
+type policyConfigs struct {
+	mu      sync.Mutex
+	version uint64
+	configs map[*TLSPolicy]*tls.Config
+}
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+var clientAuthTypes = map[string]tls.ClientAuthType{
+	"none":     tls.NoClientCert,
+	"optional": tls.VerifyClientCertIfGiven,
+	"require":  tls.RequireAndVerifyClientCert,
+}
+
+func cipherSuiteID(name string) (uint16, bool) {
+	for _, s := range tls.CipherSuites() {
+		if s.Name == name {
+			return s.ID, true
+		}
+	}
+
+	for _, s := range tls.InsecureCipherSuites() {
+		if s.Name == name {
+			return s.ID, true
+		}
+	}
+
+	return 0, false
+}
+
+// Policy creates the TLSPolicy from the spec. The clientCAs argument is
+// the optional PEM encoded CA certificates, used to verify the client
+// certificates.
+func (s *PolicySpec) Policy(clientCAs []byte) (*TLSPolicy, error) {
+	p := &TLSPolicy{}
+	if s.MinVersion != "" {
+		v, ok := tlsVersions[s.MinVersion]
+		if !ok {
+			return nil, fmt.Errorf("unsupported TLS version: %s", s.MinVersion)
+		}
+
+		p.MinVersion = v
+	}
+
+	for _, name := range s.CipherSuites {
+		id, ok := cipherSuiteID(name)
+		if !ok {
+			return nil, fmt.Errorf("unsupported cipher suite: %s", name)
+		}
+
+		p.CipherSuites = append(p.CipherSuites, id)
+	}
+
+	for _, proto := range s.ALPNProtocols {
+		if proto == "" || len(proto) > 255 {
+			return nil, fmt.Errorf("invalid ALPN protocol: %q", proto)
+		}
+
+		p.NextProtos = append(p.NextProtos, proto)
+	}
+
+	if s.ClientAuth != "" {
+		ca, ok := clientAuthTypes[strings.ToLower(s.ClientAuth)]
+		if !ok {
+			return nil, fmt.Errorf("unsupported client auth: %s", s.ClientAuth)
+		}
+
+		p.ClientAuth = &ca
+	}
+
+	if len(clientCAs) > 0 {
+		p.ClientCAs = x509.NewCertPool()
+		if !p.ClientCAs.AppendCertsFromPEM(clientCAs) {
+			return nil, fmt.Errorf("invalid client CA certificates")
+		}
+	}
+
+	return p, nil
+}
+
+// LoadPolicies loads the TLS policies of the hosts from a YAML file,
+// in the format:
+//
+//	policies:
+//	- hosts: [api.example.org]
+//	  minVersion: "1.3"
+//	  alpnProtocols: [h2]
+//	  clientAuth: require
+//	  clientCAFile: /etc/skipper/client-ca.pem
+func LoadPolicies(path string) (map[string]*TLSPolicy, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var f policyFile
+	if err := yaml.UnmarshalStrict(b, &f); err != nil {
+		return nil, fmt.Errorf("failed to parse TLS policy file %s: %w", path, err)
+	}
+
+	policies := make(map[string]*TLSPolicy)
+	for i, spec := range f.Policies {
+		if len(spec.Hosts) == 0 {
+			return nil, fmt.Errorf("TLS policy %d has no hosts", i)
+		}
+
+		var ca []byte
+		if spec.ClientCAFile != "" {
+			if ca, err = os.ReadFile(spec.ClientCAFile); err != nil {
+				return nil, err
+			}
+		}
+
+		p, err := spec.Policy(ca)
+		if err != nil {
+			return nil, fmt.Errorf("invalid TLS policy for %v: %w", spec.Hosts, err)
+		}
+
+		for _, h := range spec.Hosts {
+			policies[strings.ToLower(h)] = p
+		}
+	}
+
+	return policies, nil
+}
+
+// SetPolicies replaces the TLS policies of the hosts that were set
+// from the same source, e.g. the policy file or the Kubernetes data
+// client. When multiple sources set a policy for the same host, the
+// policy of the source that was set first takes precedence.
+func (r *CertRegistry) SetPolicies(source string, policies map[string]*TLSPolicy) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.policies[source]; !ok {
+		r.policySources = append(r.policySources, source)
+	}
+
+	r.policies[source] = policies
+	r.policyVersion++
+}
+
+// GetPolicy returns the TLS policy of the host, or nil if the host has
+// no policy.
+func (r *CertRegistry) GetPolicy(host string) *TLSPolicy {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.getPolicy(strings.ToLower(host))
+}
+
+func (r *CertRegistry) getPolicy(host string) *TLSPolicy {
+	for _, source := range r.policySources {
+		if p, ok := r.policies[source][host]; ok {
+			return p
+		}
+	}
+
+	return nil
+}
+
+// GetConfigForClient returns a function to be used as the
+// GetConfigForClient field of the listener TLS configuration. It
+// applies the TLS policy of the host to a copy of the base
+// configuration. The base configuration must list the ALPN protocols
+// of the listener, because the HTTP server sets the default protocols
+// only on its own copy of the configuration.
+func (r *CertRegistry) GetConfigForClient(base *tls.Config) func(*tls.ClientHelloInfo) (*tls.Config, error) {
+	pc := &policyConfigs{configs: make(map[*TLSPolicy]*tls.Config)}
+	return func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+		r.mu.Lock()
+		p := r.getPolicy(strings.ToLower(hello.ServerName))
+		version := r.policyVersion
+		r.mu.Unlock()
+
+		if p == nil {
+			return nil, nil
+		}
+
+		return pc.get(base, p, version), nil
+	}
+}
+
+func (pc *policyConfigs) get(base *tls.Config, p *TLSPolicy, version uint64) *tls.Config {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+
+	// the configurations of the replaced policies are dropped
+	if version != pc.version {
+		pc.version = version
+		clear(pc.configs)
+	}
+
+	if c, ok := pc.configs[p]; ok {
+		return c
+	}
+
+	c := base.Clone()
+	c.GetConfigForClient = nil
+	if p.MinVersion != 0 {
+		c.MinVersion = p.MinVersion
+	}
+
+	if p.CipherSuites != nil {
+		c.CipherSuites = p.CipherSuites
+	}
+
+	if p.NextProtos != nil {
+		c.NextProtos = p.NextProtos
+	}
+
+	if p.ClientAuth != nil {
+		c.ClientAuth = *p.ClientAuth
+	}
+
+	if p.ClientCAs != nil {
+		c.ClientCAs = p.ClientCAs
+	}
+
+	pc.configs[p] = c
+	return c
+}
@@ -0,0 +1,153 @@
+package certregistry
+
+import (
+	"crypto/tls"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPolicySpec(t *testing.T) {
+	p, err := (&PolicySpec{
+		MinVersion:    "1.2",
+		CipherSuites:  []string{"TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256", "TLS_RSA_WITH_AES_128_CBC_SHA"},
+		ALPNProtocols: []string{"h2"},
+		ClientAuth:    "Optional",
+	}).Policy(nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, uint16(tls.VersionTLS12), p.MinVersion)
+	assert.Equal(t, []uint16{tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256, tls.TLS_RSA_WITH_AES_128_CBC_SHA}, p.CipherSuites)
+	assert.Equal(t, []string{"h2"}, p.NextProtos)
+	assert.Equal(t, tls.VerifyClientCertIfGiven, *p.ClientAuth)
+	assert.Nil(t, p.ClientCAs)
+
+	p, err = (&PolicySpec{}).Policy(nil)
+	require.NoError(t, err)
+	assert.Equal(t, &TLSPolicy{}, p)
+
+	for _, spec := range []PolicySpec{
+		{MinVersion: "1.4"},
+		{CipherSuites: []string{"TLS_FOO"}},
+		{ALPNProtocols: []string{""}},
+		{ClientAuth: "sometimes"},
+	} {
+		_, err := spec.Policy(nil)
+		assert.Error(t, err, "%+v", spec)
+	}
+
+	_, err = (&PolicySpec{ClientAuth: "require"}).Policy([]byte("invalid"))
+	assert.Error(t, err)
+}
+
+func TestLoadPolicies(t *testing.T) {
+	dir := t.TempDir()
+	caCert := createDummyCertDetail(t, "ca", []string{"ca.example.org"}, time.Now(), time.Now().Add(time.Hour))
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caCert.Certificate[0]})
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "ca.pem"), ca, 0644))
+
+	file := filepath.Join(dir, "policies.yaml")
+	require.NoError(t, os.WriteFile(file, []byte(`
+policies:
+- hosts: [API.example.org, admin.example.org]
+  minVersion: "1.3"
+  clientAuth: require
+  clientCAFile: `+filepath.Join(dir, "ca.pem")+`
+- hosts: [legacy.example.org]
+  alpnProtocols: [http/1.1]
+`), 0644))
+
+	policies, err := LoadPolicies(file)
+	require.NoError(t, err)
+	require.Len(t, policies, 3)
+	assert.Same(t, policies["api.example.org"], policies["admin.example.org"])
+	assert.Equal(t, uint16(tls.VersionTLS13), policies["api.example.org"].MinVersion)
+	assert.NotNil(t, policies["api.example.org"].ClientCAs)
+	assert.Equal(t, []string{"http/1.1"}, policies["legacy.example.org"].NextProtos)
+
+	for _, content := range []string{
+		"policies:\n- minVersion: \"1.3\"\n",
+		"policies:\n- hosts: [example.org]\n  minVersion: \"2.0\"\n",
+		"policies:\n- hosts: [example.org]\n  unknown: true\n",
+		"policies:\n- hosts: [example.org]\n  clientCAFile: /does/not/exist\n",
+	} {
+		require.NoError(t, os.WriteFile(file, []byte(content), 0644))
+		_, err := LoadPolicies(file)
+		assert.Error(t, err, content)
+	}
+
+	_, err = LoadPolicies(filepath.Join(dir, "missing.yaml"))
+	assert.Error(t, err)
+}
+
+func TestSetPolicies(t *testing.T) {
+	r := NewCertRegistry()
+	first := &TLSPolicy{MinVersion: tls.VersionTLS13}
+	second := &TLSPolicy{MinVersion: tls.VersionTLS12}
+	third := &TLSPolicy{NextProtos: []string{"http/1.1"}}
+
+	r.SetPolicies("file", map[string]*TLSPolicy{"example.org": first})
+	r.SetPolicies("routegroups", map[string]*TLSPolicy{"example.org": second, "www.example.org": third})
+
+	assert.Same(t, first, r.GetPolicy("Example.org"))
+	assert.Same(t, third, r.GetPolicy("www.example.org"))
+	assert.Nil(t, r.GetPolicy("other.example.org"))
+
+	r.SetPolicies("file", nil)
+	assert.Same(t, second, r.GetPolicy("example.org"))
+
+	r.SetPolicies("routegroups", nil)
+	assert.Nil(t, r.GetPolicy("www.example.org"))
+}
+
+func TestGetConfigForClient(t *testing.T) {
+	r := NewCertRegistry()
+	clientAuth := tls.RequireAndVerifyClientCert
+	policy := &TLSPolicy{
+		MinVersion:   tls.VersionTLS13,
+		CipherSuites: []uint16{tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256},
+		NextProtos:   []string{"http/1.1"},
+		ClientAuth:   &clientAuth,
+	}
+	r.SetPolicies("file", map[string]*TLSPolicy{"example.org": policy})
+
+	base := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		NextProtos:     []string{"h2", "http/1.1"},
+		GetCertificate: r.GetCertFromHello,
+	}
+	getConfig := r.GetConfigForClient(base)
+
+	c, err := getConfig(&tls.ClientHelloInfo{ServerName: "other.example.org"})
+	require.NoError(t, err)
+	assert.Nil(t, c)
+
+	c, err = getConfig(&tls.ClientHelloInfo{ServerName: "example.org"})
+	require.NoError(t, err)
+	require.NotNil(t, c)
+	assert.Equal(t, uint16(tls.VersionTLS13), c.MinVersion)
+	assert.Equal(t, policy.CipherSuites, c.CipherSuites)
+	assert.Equal(t, []string{"http/1.1"}, c.NextProtos)
+	assert.Equal(t, tls.RequireAndVerifyClientCert, c.ClientAuth)
+	assert.NotNil(t, c.GetCertificate)
+	assert.Nil(t, c.GetConfigForClient)
+
+	cached, _ := getConfig(&tls.ClientHelloInfo{ServerName: "example.org"})
+	assert.Same(t, c, cached)
+
+	r.SetPolicies("file", map[string]*TLSPolicy{"example.org": {}})
+	c, err = getConfig(&tls.ClientHelloInfo{ServerName: "example.org"})
+	require.NoError(t, err)
+	assert.NotSame(t, cached, c)
+	assert.Equal(t, uint16(tls.VersionTLS12), c.MinVersion)
+	assert.Equal(t, []string{"h2", "http/1.1"}, c.NextProtos)
+	assert.Equal(t, tls.NoClientCert, c.ClientAuth)
+
+	// the base configuration is not modified
+	assert.Equal(t, uint16(tls.VersionTLS12), base.MinVersion)
+}
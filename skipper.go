@@ -3,6 +3,7 @@ package skipper
 import (
 	"context"
 	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -11,9 +12,13 @@ import (
 	"os"
 	"os/signal"
 	"path"
+	"reflect"
 	"regexp"
+	"slices"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
@@ -21,6 +26,7 @@ import (
 
 	ot "github.com/opentracing/opentracing-go"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/quic-go/quic-go/http3"
 	log "github.com/sirupsen/logrus"
 
 	"github.com/zalando/skipper/circuit"
@@ -35,26 +41,38 @@ import (
 	"github.com/zalando/skipper/filters/block"
 	"github.com/zalando/skipper/filters/builtin"
 	"github.com/zalando/skipper/filters/fadein"
+	geofilters "github.com/zalando/skipper/filters/geo"
+	graphqlfilters "github.com/zalando/skipper/filters/graphql"
+	"github.com/zalando/skipper/filters/ipfilter"
 	logfilter "github.com/zalando/skipper/filters/log"
 	"github.com/zalando/skipper/filters/openpolicyagent"
 	"github.com/zalando/skipper/filters/openpolicyagent/opaauthorizerequest"
 	"github.com/zalando/skipper/filters/openpolicyagent/opaserveresponse"
 	ratelimitfilters "github.com/zalando/skipper/filters/ratelimit"
+	schedulerfilters "github.com/zalando/skipper/filters/scheduler"
 	"github.com/zalando/skipper/filters/shedder"
 	teefilters "github.com/zalando/skipper/filters/tee"
+	"github.com/zalando/skipper/graphql"
 	"github.com/zalando/skipper/loadbalancer"
 	"github.com/zalando/skipper/logging"
 	"github.com/zalando/skipper/metrics"
 	skpnet "github.com/zalando/skipper/net"
+	"github.com/zalando/skipper/net/geoip"
+	"github.com/zalando/skipper/net/iplist"
+	"github.com/zalando/skipper/net/proxyprotocol"
+	"github.com/zalando/skipper/net/websocket"
 	pauth "github.com/zalando/skipper/predicates/auth"
 	"github.com/zalando/skipper/predicates/content"
 	"github.com/zalando/skipper/predicates/cookie"
 	"github.com/zalando/skipper/predicates/cron"
 	"github.com/zalando/skipper/predicates/forwarded"
+	"github.com/zalando/skipper/predicates/geo"
+	pgraphql "github.com/zalando/skipper/predicates/graphql"
 	"github.com/zalando/skipper/predicates/host"
 	"github.com/zalando/skipper/predicates/interval"
 	"github.com/zalando/skipper/predicates/methods"
 	"github.com/zalando/skipper/predicates/primitive"
+	pproxyprotocol "github.com/zalando/skipper/predicates/proxyprotocol"
 	"github.com/zalando/skipper/predicates/query"
 	"github.com/zalando/skipper/predicates/source"
 	"github.com/zalando/skipper/predicates/tee"
@@ -66,6 +84,7 @@ import (
 	"github.com/zalando/skipper/scheduler"
 	"github.com/zalando/skipper/script"
 	"github.com/zalando/skipper/secrets"
+	"github.com/zalando/skipper/secrets/acme"
 	"github.com/zalando/skipper/secrets/certregistry"
 	"github.com/zalando/skipper/swarm"
 	"github.com/zalando/skipper/tracing"
@@ -78,6 +97,27 @@ const (
 
 const DefaultPluginDir = "./plugins"
 
+// RuntimeSettings contain the settings that can be changed without
+// restarting skipper.
+type RuntimeSettings struct {
+
+	// DefaultFilters replace the default filters of the routes.
+	DefaultFilters *eskip.DefaultFilters
+
+	// BreakerSettings replace the global and host specific settings of
+	// the circuit breakers, when the breakers are enabled.
+	BreakerSettings []circuit.BreakerSettings
+
+	// RatelimitSettings replace the global settings of the ratelimiters,
+	// when the ratelimiters are enabled.
+	RatelimitSettings []ratelimit.Settings
+
+	// TracingSamplingRate replaces the ratio of the traces started by
+	// skipper that are passed to the tracer. When 0, every trace is
+	// passed to the tracer.
+	TracingSamplingRate float64
+}
+
 // Options to start skipper.
 type Options struct {
 	// WaitForHealthcheckInterval sets the time that skipper waits
@@ -85,6 +125,17 @@ type Options struct {
 	// to 0.
 	WaitForHealthcheckInterval time.Duration
 
+	// ShutdownDrainInterval sets the time that skipper waits, after the
+	// health check interval, for the Server-Sent Events streams and the
+	// upgraded connections to finish, before terminating them. Defaults
+	// to 0.
+	ShutdownDrainInterval time.Duration
+
+	// ShutdownTimeout sets the time, after the health check interval,
+	// after which the connections still open are closed forcefully.
+	// When 0, skipper waits for all the requests to finish.
+	ShutdownTimeout time.Duration
+
 	// StatusChecks is an experimental feature. It defines a
 	// comma separated list of HTTP URLs to do GET requests to,
 	// that have to return 200 before skipper becomes ready
@@ -99,6 +150,18 @@ type Options struct {
 	// Insecure network address skipper should listen on when TLS is enabled
 	InsecureAddress string
 
+	// HTTP3Address is the UDP network address of the HTTP/3 (QUIC)
+	// listener. It is used only when TLS is enabled, and it shares the
+	// certificates of the TLS listener. The TLS listener advertises it
+	// in the Alt-Svc header of its responses.
+	HTTP3Address string
+
+	// ProxyProtocolTrustedNetworks enables the PROXY protocol v1 and v2
+	// on the main listener, accepting the header from the listed IPs
+	// and CIDRs of the load balancers. The connections from these
+	// networks must start with a PROXY protocol header.
+	ProxyProtocolTrustedNetworks []string
+
 	// EnableTCPQueue enables controlling the
 	// concurrently processed requests at the TCP listener.
 	EnableTCPQueue bool
@@ -456,6 +519,28 @@ type Options struct {
 	// instead of full details of the updated/deleted routes.
 	SuppressRouteUpdateLogs bool
 
+	// RouteHistorySize sets how many route table generations are kept
+	// in memory and served on /routes/history and /routes/diff of the
+	// support listener. When zero, no history is kept.
+	RouteHistorySize int
+
+	// RouteRolloutFraction enables the staged rollout of the new route
+	// table generations, routing the given fraction of the requests with
+	// the new generation, before it is promoted or rolled back.
+	RouteRolloutFraction float64
+
+	// RouteRolloutDuration sets how long a new route table generation is
+	// evaluated during the staged rollout.
+	RouteRolloutDuration time.Duration
+
+	// RouteRolloutMinRequests sets the minimum number of requests routed
+	// with a new route table generation, before it can be rolled back.
+	RouteRolloutMinRequests int64
+
+	// RouteRolloutErrorThreshold sets the maximum allowed increase of the
+	// 5xx rate of a new route table generation during the staged rollout.
+	RouteRolloutErrorThreshold float64
+
 	// Dev mode. Currently this flag disables prioritization of the
 	// consumer side over the feeding side during the routing updates to
 	// populate the updated routes faster.
@@ -464,6 +549,14 @@ type Options struct {
 	// Network address for the support endpoints
 	SupportListener string
 
+	// ConfigHandler, when set, is served on the support listener at
+	// /config, e.g. to show the effective configuration.
+	ConfigHandler http.Handler
+
+	// RuntimeSettings, when set, receives the settings that are applied
+	// without restarting, e.g. after the configuration was reloaded.
+	RuntimeSettings <-chan RuntimeSettings
+
 	// Deprecated: Network address for the /metrics endpoint
 	MetricsListener string
 
@@ -663,6 +756,18 @@ type Options struct {
 	// CipherSuites sets the list of cipher suites to use for TLS 1.2
 	CipherSuites []uint16
 
+	// TLSPoliciesFile is a YAML file with the TLS policies of the
+	// hosts, overriding the minimum version, the cipher suites, the
+	// ALPN protocols and the client authentication of the server. See
+	// certregistry.LoadPolicies for the format. The policies of the
+	// file take precedence over the policies of the RouteGroups.
+	TLSPoliciesFile string
+
+	// EnableOCSPStapling enables stapling the OCSP responses to the
+	// server certificates. The responses are refreshed in the
+	// background.
+	EnableOCSPStapling bool
+
 	// Flush interval for upgraded Proxy connections
 	BackendFlushInterval time.Duration
 
@@ -673,6 +778,38 @@ type Options struct {
 	// and the response messages during web socket upgrades.
 	ExperimentalUpgradeAudit bool
 
+	// StreamingIdleTimeout is the idle timeout between the events of the
+	// responses served in streaming mode, i.e. the Server-Sent Events and
+	// the responses of the routes with the streaming() filter. In
+	// streaming mode, it replaces the backend and write timeouts.
+	// Disabled when zero, the streams keep the backend and write timeouts
+	// then.
+	StreamingIdleTimeout time.Duration
+
+	// WebSocketFrameParser enables parsing the frames of all the upgraded
+	// WebSocket connections, to collect message metrics and to enforce
+	// the WebSocket limits. Without it, only the connections of the
+	// routes with WebSocket message handlers, e.g. lua filters, are
+	// parsed.
+	WebSocketFrameParser bool
+
+	// WebSocketMaxMessageSize limits the size of the messages of the
+	// parsed WebSocket connections. Defaults to 1MiB.
+	WebSocketMaxMessageSize int64
+
+	// WebSocketIdleTimeout closes the parsed WebSocket connections
+	// without messages for the given duration.
+	WebSocketIdleTimeout time.Duration
+
+	// WebSocketPingInterval enables pinging the clients of the parsed
+	// WebSocket connections that didn't send any frame for the given
+	// duration.
+	WebSocketPingInterval time.Duration
+
+	// WebSocketPingTimeout closes the parsed WebSocket connections of the
+	// clients not answering the pings. Defaults to WebSocketPingInterval.
+	WebSocketPingTimeout time.Duration
+
 	// MaxLoopbacks defines the maximum number of loops that the proxy can execute when the routing table
 	// contains loop backends (<loopback>).
 	MaxLoopbacks int
@@ -703,6 +840,15 @@ type Options struct {
 	// EnableRouteLIFOMetrics enables metrics for the individual route LIFO queues, if any.
 	EnableRouteLIFOMetrics bool
 
+	// SchedulerFeedbackHeader enables backends to tune the concurrency
+	// of the route FIFO and LIFO queues by responding with this header.
+	SchedulerFeedbackHeader string
+
+	// EnableSchedulerLiveConfig enables changing the live settings of
+	// the route FIFO and LIFO queues via the /queues endpoint of the
+	// support listener.
+	EnableSchedulerLiveConfig bool
+
 	// OpenTracing enables opentracing
 	OpenTracing []string
 
@@ -732,6 +878,12 @@ type Options struct {
 	// tracer instance overrides options provided under OpenTracing property.
 	OpenTracingTracer ot.Tracer
 
+	// OpenTracingSamplingRate sets the ratio of the traces started by skipper, i.e.
+	// without a parent span received from the client, that are passed to the tracer,
+	// between 0 and 1. When 0, every trace is passed to the tracer. The sampling
+	// of the tracer applies, too. It can be changed with the RuntimeSettings.
+	OpenTracingSamplingRate float64
+
 	// PluginDir defines the directory to load plugins from, DEPRECATED, use PluginDirs
 	PluginDir string
 	// PluginDirs defines the directories to load plugins from
@@ -959,6 +1111,42 @@ type Options struct {
 	// KubernetesEnableTLS enables kubernetes to use resources to terminate tls
 	KubernetesEnableTLS bool
 
+	// ACMEDirectoryURL enables obtaining and renewing the TLS
+	// certificates for the hosts of the routing table from an ACME
+	// server, e.g. https://acme-v02.api.letsencrypt.org/directory.
+	// Requires one of the ACME storage options.
+	ACMEDirectoryURL string
+
+	// ACMEEmail is the contact email of the ACME account.
+	ACMEEmail string
+
+	// ACMEChallenges lists the enabled ACME challenge types in the
+	// order of preference, http-01 and tls-alpn-01. Defaults to both.
+	// The http-01 challenges are answered on the insecure listener,
+	// or on the main listener when TLS is not enabled.
+	ACMEChallenges []string
+
+	// ACMEDomains limits the ACME certificates to the hosts that equal
+	// to or are subdomains of the listed domains.
+	ACMEDomains []string
+
+	// ACMEStorageDir is the directory storing the ACME account key,
+	// the certificates and the pending challenges.
+	ACMEStorageDir string
+
+	// ACMEStorageKubernetesNamespace enables storing the ACME account
+	// key, the certificates and the pending challenges in the
+	// Kubernetes secrets of the namespace, shared by the instances.
+	ACMEStorageKubernetesNamespace string
+
+	// ACMERenewBefore is the time before the expiry when the ACME
+	// certificates are renewed. Defaults to 30 days.
+	ACMERenewBefore time.Duration
+
+	// ACMECAFile is the PEM file of the CA certificates trusted when
+	// accessing the ACME server, e.g. a local Pebble test server.
+	ACMECAFile string
+
 	// LuaModules that are allowed to be used.
 	//
 	// Use <module>.<symbol> to selectively enable module symbols,
@@ -971,6 +1159,41 @@ type Options struct {
 	// filters.
 	LuaSources []string
 
+	// GeoIPDatabases contains the paths of the MaxMind DB files used by
+	// the GeoCountry and GeoASN predicates and the geoHeaders filter.
+	// When empty, these are not available.
+	GeoIPDatabases []string
+
+	// GeoIPClientIPSource sets how the client IP of the geoip lookups
+	// is resolved: "Source", "SourceFromLast" or "ClientIP". Defaults to
+	// "Source".
+	GeoIPClientIPSource string
+
+	// GeoIPReloadInterval sets how often the geoip databases are
+	// checked for changes. Defaults to one minute.
+	GeoIPReloadInterval time.Duration
+
+	// IPLists contains the definitions of the named IP lists used by
+	// the ipDenyList and ipAllowList filters, in the format of
+	// <name>=<file path or URL>. When empty, these filters are not
+	// available.
+	IPLists []string
+
+	// IPListsReloadInterval sets how often the IP lists are reloaded.
+	// Defaults to one minute.
+	IPListsReloadInterval time.Duration
+
+	// IPListsClientIPSource sets how the client IP checked against the
+	// IP lists is resolved: "Source", "SourceFromLast" or "ClientIP".
+	// Defaults to "Source".
+	IPListsClientIPSource string
+
+	// BodyPredicatesMaxSize sets the maximum size of the request bodies
+	// inspected by the JSONBodyField, JSONBodyFieldRegexp and FormValue
+	// predicates, and by the GraphQL predicates and filters. Defaults to
+	// 64KiB.
+	BodyPredicatesMaxSize int64
+
 	EnableOpenPolicyAgent                              bool
 	EnableOpenPolicyAgentCustomControlLoop             bool
 	OpenPolicyAgentControlLoopInterval                 time.Duration
@@ -1060,6 +1283,53 @@ func (o *Options) OAuthGrantOptions() *auth.OAuthConfig {
 	return oauthConfig
 }
 
+// defaultFiltersPreProcessor applies the default filters that can be
+// replaced at runtime.
+type defaultFiltersPreProcessor struct {
+	current atomic.Pointer[eskip.DefaultFilters]
+}
+
+func (p *defaultFiltersPreProcessor) Do(routes []*eskip.Route) []*eskip.Route {
+	if df := p.current.Load(); df != nil {
+		return df.Do(routes)
+	}
+
+	return routes
+}
+
+// applyRuntimeSettings applies the received runtime settings, until the
+// settings channel is closed. The routes are processed again when the
+// default filters change.
+func applyRuntimeSettings(
+	settings <-chan RuntimeSettings,
+	defaultFilters *defaultFiltersPreProcessor,
+	rt *routing.Routing,
+	breakers *circuit.Registry,
+	ratelimits *ratelimit.Registry,
+	sampler *tracing.Sampler,
+) {
+	for s := range settings {
+		if !reflect.DeepEqual(defaultFilters.current.Load(), s.DefaultFilters) {
+			defaultFilters.current.Store(s.DefaultFilters)
+			rt.Reprocess()
+		}
+
+		if breakers != nil {
+			breakers.SetSettings(s.BreakerSettings...)
+		}
+
+		if ratelimits != nil {
+			ratelimits.SetSettings(s.RatelimitSettings...)
+		}
+
+		if sampler != nil {
+			sampler.SetRate(s.TracingSamplingRate)
+		}
+
+		log.Info("Runtime settings applied")
+	}
+}
+
 type serverErrorLogWriter struct{}
 
 func (*serverErrorLogWriter) Write(p []byte) (int, error) {
@@ -1278,6 +1548,68 @@ func (o *Options) tlsConfig(cr *certregistry.CertRegistry) (*tls.Config, error)
 	return config, nil
 }
 
+func (o *Options) acmeManager(cr *certregistry.CertRegistry, swarmer ratelimit.Swarmer, ring *skpnet.RedisRingClient, mtr metrics.Metrics) (*acme.Manager, error) {
+	var (
+		storage acme.Storage
+		err     error
+	)
+
+	switch {
+	case o.ACMEStorageKubernetesNamespace != "":
+		storage, err = acme.NewKubernetesStorage(acme.KubernetesStorageOptions{
+			Namespace: o.ACMEStorageKubernetesNamespace,
+			InCluster: o.KubernetesInCluster,
+			APIURL:    o.KubernetesURL,
+		})
+	case o.ACMEStorageDir != "":
+		storage, err = acme.NewFileStorage(o.ACMEStorageDir)
+	default:
+		err = fmt.Errorf("missing ACME storage")
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	var locker acme.Locker
+	if ring != nil {
+		locker = acme.NewRedisLocker(ring)
+	} else if sw, ok := swarmer.(*swarm.Swarm); ok {
+		locker = acme.NewSwarmLocker(sw, sw.Local().Name, 0)
+	}
+
+	var client *http.Client
+	if o.ACMECAFile != "" {
+		ca, err := os.ReadFile(o.ACMECAFile)
+		if err != nil {
+			return nil, err
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("invalid ACME CA file: %s", o.ACMECAFile)
+		}
+
+		client = &http.Client{Transport: &http.Transport{
+			Proxy:           http.ProxyFromEnvironment,
+			TLSClientConfig: &tls.Config{RootCAs: pool},
+		}}
+	}
+
+	return acme.NewManager(acme.Options{
+		DirectoryURL: o.ACMEDirectoryURL,
+		Email:        o.ACMEEmail,
+		Challenges:   o.ACMEChallenges,
+		Domains:      o.ACMEDomains,
+		Storage:      storage,
+		Locker:       locker,
+		CertRegistry: cr,
+		RenewBefore:  o.ACMERenewBefore,
+		Client:       client,
+		Metrics:      mtr,
+	})
+}
+
 func (o *Options) openTracingTracerInstance() (ot.Tracer, error) {
 	if o.OpenTracingTracer != nil {
 		return o.OpenTracingTracer, nil
@@ -1299,7 +1631,7 @@ func (o *Options) openTracingTracerInstance() (ot.Tracer, error) {
 	}
 }
 
-func listen(o *Options, address string, mtr metrics.Metrics) (net.Listener, error) {
+func listen(o *Options, address string, mtr metrics.Metrics, load *queuelistener.Load) (net.Listener, error) {
 
 	if !o.EnableTCPQueue {
 		return net.Listen("tcp", address)
@@ -1350,9 +1682,21 @@ func listen(o *Options, address string, mtr metrics.Metrics) (net.Listener, erro
 		ConnectionBytes:  o.ExpectedBytesPerRequest,
 		QueueTimeout:     qto,
 		Metrics:          mtr,
+		Load:             load,
 	})
 }
 
+func appendMissing(list []string, values ...string) []string {
+	list = slices.Clone(list)
+	for _, v := range values {
+		if !slices.Contains(list, v) {
+			list = append(list, v)
+		}
+	}
+
+	return list
+}
+
 func listenAndServeQuit(
 	proxy http.Handler,
 	o *Options,
@@ -1360,6 +1704,9 @@ func listenAndServeQuit(
 	idleConnsCH chan struct{},
 	mtr metrics.Metrics,
 	cr *certregistry.CertRegistry,
+	listenerLoad *queuelistener.Load,
+	acmeManager *acme.Manager,
+	hooks shutdownHooks,
 ) error {
 	tlsConfig, err := o.tlsConfig(cr)
 	if err != nil {
@@ -1367,6 +1714,32 @@ func listenAndServeQuit(
 	}
 	serveTLS := tlsConfig != nil
 
+	if serveTLS {
+		tlsConfig = tlsConfig.Clone()
+	}
+
+	if serveTLS && o.EnableOCSPStapling {
+		stapler := certregistry.NewOCSPStapler(certregistry.OCSPOptions{})
+		defer stapler.Close()
+		stapler.ConfigureTLS(tlsConfig)
+	}
+
+	if serveTLS && cr != nil && tlsConfig.GetConfigForClient == nil {
+		// the per-host configurations are copies of the server
+		// configuration, that need to list the default protocols
+		tlsConfig.NextProtos = appendMissing(tlsConfig.NextProtos, "h2", "http/1.1")
+		tlsConfig.GetConfigForClient = cr.GetConfigForClient(tlsConfig)
+	}
+
+	if acmeManager != nil {
+		proxy = acmeManager.HTTPHandler(proxy)
+		if serveTLS {
+			// the TLS-ALPN-01 challenges take precedence over
+			// the per-host configurations
+			acmeManager.ConfigureTLS(tlsConfig)
+		}
+	}
+
 	address := o.Address
 	if address == "" {
 		if serveTLS {
@@ -1388,6 +1761,10 @@ func listenAndServeQuit(
 		ErrorLog:          newServerErrorLog(),
 	}
 
+	if serveTLS && acmeManager != nil {
+		acmeManager.ConfigureServer(srv)
+	}
+
 	cm := &skpnet.ConnManager{
 		Keepalive:         o.KeepaliveServer,
 		KeepaliveRequests: o.KeepaliveRequestsServer,
@@ -1397,15 +1774,51 @@ func listenAndServeQuit(
 		cm.Metrics = mtr
 	}
 
+	var h3 *http3.Server
+	if serveTLS && o.HTTP3Address != "" {
+		h3 = &http3.Server{
+			Addr:           o.HTTP3Address,
+			TLSConfig:      tlsConfig,
+			Handler:        srv.Handler,
+			IdleTimeout:    o.IdleTimeoutServer,
+			MaxHeaderBytes: o.MaxHeaderBytes,
+		}
+
+		h3cm := &skpnet.ConnManager{}
+		if o.EnableConnMetricsServer {
+			h3cm.Metrics = mtr
+		}
+
+		h3cm.ConfigureHTTP3(h3)
+		srv.Handler = altSvcHandler(h3, srv.Handler)
+	}
+
+	proxyProtocolTrusted, err := proxyprotocol.ParseTrustedNetworks(o.ProxyProtocolTrustedNetworks)
+	if err != nil {
+		return fmt.Errorf("invalid PROXY protocol trusted networks: %w", err)
+	}
+
+	if len(proxyProtocolTrusted) > 0 {
+		srv.ConnContext = proxyprotocol.ConnContext
+	}
+
 	cm.Configure(srv)
 
 	log.Infof("Listen on %v", address)
 
-	l, err := listen(o, address, mtr)
+	l, err := listen(o, address, mtr, listenerLoad)
 	if err != nil {
 		return err
 	}
 
+	if len(proxyProtocolTrusted) > 0 {
+		l = proxyprotocol.NewListener(l, proxyprotocol.Options{
+			TrustedNetworks:   proxyProtocolTrusted,
+			ReadHeaderTimeout: o.ReadHeaderTimeoutServer,
+			Metrics:           mtr,
+		})
+	}
+
 	// making idleConnsCH and sigs optional parameters is required to be able to tear down a server
 	// from the tests
 	if idleConnsCH == nil {
@@ -1421,22 +1834,26 @@ func listenAndServeQuit(
 
 		<-sigs
 
-		log.Infof("Got shutdown signal, wait %v for health check", o.WaitForHealthcheckInterval)
-		time.Sleep(o.WaitForHealthcheckInterval)
-
-		log.Info("Start shutdown")
-		if err := srv.Shutdown(context.Background()); err != nil {
-			log.Errorf("Failed to graceful shutdown: %v", err)
-		}
+		shutdown(o, srv, h3, cm, hooks)
 		close(idleConnsCH)
 	}()
 
 	if serveTLS {
+		if h3 != nil {
+			log.Infof("HTTP/3 listener on %v", o.HTTP3Address)
+
+			go func() {
+				if err := h3.ListenAndServe(); err != http.ErrServerClosed {
+					log.Errorf("HTTP/3 listener serve failed: %v", err)
+				}
+			}()
+		}
+
 		if o.InsecureAddress != "" {
 			log.Infof("Insecure listener on %v", o.InsecureAddress)
 
 			go func() {
-				l, err := listen(o, o.InsecureAddress, mtr)
+				l, err := listen(o, o.InsecureAddress, mtr, nil)
 				if err != nil {
 					log.Errorf("Failed to start insecure listener on %s: %v", o.InsecureAddress, err)
 				}
@@ -1465,6 +1882,144 @@ func listenAndServeQuit(
 	return nil
 }
 
+// shutdownHooks are called in the phases of the graceful shutdown.
+type shutdownHooks struct {
+
+	// start is called when the shutdown starts, e.g. to fail the
+	// health check.
+	start []func()
+
+	// terminate is called at the end of the drain period, to terminate
+	// the streams and the upgraded connections.
+	terminate []func()
+
+	// upgraded, when set, returns the number of the open upgraded
+	// connections, that are not tracked by the server.
+	upgraded func() int
+}
+
+// shutdown shuts the server down in phases:
+//
+//   - the shutdown hooks fail the health check, and skipper waits for
+//     the load balancer to take the instance out of the pool
+//   - the listeners are closed, and the open connections are drained:
+//     the HTTP/1 responses get Connection: close and the HTTP/2
+//     connections receive GOAWAY
+//   - the streams and the upgraded connections are terminated after the
+//     drain interval
+//   - the connections still open are closed forcefully after the
+//     shutdown timeout
+func shutdown(o *Options, srv *http.Server, h3 *http3.Server, cm *skpnet.ConnManager, hooks shutdownHooks) {
+	log.Infof("Got shutdown signal, wait %v for health check", o.WaitForHealthcheckInterval)
+	for _, f := range hooks.start {
+		f()
+	}
+
+	time.Sleep(o.WaitForHealthcheckInterval)
+
+	log.Infof("Start shutdown, draining connections for %v", o.ShutdownDrainInterval)
+	cm.Drain()
+
+	ctx := context.Background()
+	if o.ShutdownTimeout > 0 {
+		var cancel func()
+		ctx, cancel = context.WithTimeout(ctx, o.ShutdownTimeout)
+		defer cancel()
+	}
+
+	var wg sync.WaitGroup
+	if h3 != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := h3.Shutdown(ctx); err != nil {
+				log.Errorf("Failed to graceful shutdown the HTTP/3 listener: %v", err)
+				h3.Close()
+			}
+		}()
+	}
+
+	shutdownDone := make(chan error, 1)
+	go func() { shutdownDone <- srv.Shutdown(ctx) }()
+
+	drain := time.NewTimer(o.ShutdownDrainInterval)
+	defer drain.Stop()
+
+	progress := time.NewTicker(time.Second)
+	defer progress.Stop()
+
+	terminated := false
+	terminate := func(reason string) {
+		if terminated {
+			return
+		}
+
+		log.Infof("Terminating streams and upgraded connections, %s", reason)
+		for _, f := range hooks.terminate {
+			f()
+		}
+
+		terminated = true
+	}
+
+	timeout := ctx.Done()
+	for shutdownDone != nil || !terminated {
+		select {
+		case <-drain.C:
+			terminate("drain interval elapsed")
+		case <-timeout:
+			timeout = nil
+			terminate("shutdown timeout elapsed")
+		case err := <-shutdownDone:
+			shutdownDone = nil
+			if err != nil {
+				log.Errorf("Failed to graceful shutdown, closing the remaining connections: %v", err)
+				srv.Close()
+				terminate("shutdown timeout elapsed")
+			}
+
+			// the server doesn't track the upgraded connections
+			if hooks.upgraded == nil || hooks.upgraded() == 0 {
+				terminate("no open connections")
+			}
+		case <-progress.C:
+			states := cm.ConnStates()
+			upgraded := 0
+			if hooks.upgraded != nil {
+				upgraded = hooks.upgraded()
+			}
+
+			log.Infof(
+				"Draining connections: %d active, %d idle, %d new, %d upgraded",
+				states[http.StateActive],
+				states[http.StateIdle],
+				states[http.StateNew],
+				upgraded,
+			)
+
+			if shutdownDone == nil && upgraded == 0 {
+				terminate("no open connections")
+			}
+		}
+	}
+
+	wg.Wait()
+	log.Info("Shutdown finished")
+}
+
+// altSvcHandler advertises the HTTP/3 listener on the responses sent
+// over TLS.
+func altSvcHandler(h3 *http3.Server, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.TLS != nil {
+			// fails only until the listener was started
+			_ = h3.SetQUICHeaders(w.Header())
+		}
+
+		h.ServeHTTP(w, r)
+	})
+}
+
 func findKubernetesDataclient(dataClients []routing.DataClient) *kubernetes.Client {
 	var kdc *kubernetes.Client
 	for _, dc := range dataClients {
@@ -1621,10 +2176,19 @@ func run(o Options, sig chan os.Signal, idleConnsCH chan struct{}) error {
 	}
 
 	var cr *certregistry.CertRegistry
-	if o.KubernetesEnableTLS {
+	if o.KubernetesEnableTLS || o.ACMEDirectoryURL != "" || o.TLSPoliciesFile != "" {
 		cr = certregistry.NewCertRegistry()
 	}
 
+	if o.TLSPoliciesFile != "" {
+		policies, err := certregistry.LoadPolicies(o.TLSPoliciesFile)
+		if err != nil {
+			return fmt.Errorf("failed to load TLS policies: %w", err)
+		}
+
+		cr.SetPolicies("file", policies)
+	}
+
 	// create data clients
 	dataClients, err := createDataClients(o, cr)
 	if err != nil {
@@ -1645,6 +2209,12 @@ func run(o Options, sig chan os.Signal, idleConnsCH chan struct{}) error {
 		return err
 	}
 
+	var sampler *tracing.Sampler
+	if (o.OpenTracingSamplingRate > 0 && o.OpenTracingSamplingRate < 1) || o.RuntimeSettings != nil {
+		sampler = tracing.NewSampler(tracer, o.OpenTracingSamplingRate)
+		tracer = sampler
+	}
+
 	// tee filters override with initialized tracer
 	o.CustomFilters = append(o.CustomFilters,
 		// tee()
@@ -1737,8 +2307,26 @@ func run(o Options, sig chan os.Signal, idleConnsCH chan struct{}) error {
 			o.ApiUsageMonitoringRealmsTrackingPattern,
 		),
 		admissionControlFilter,
+		shedder.NewAdaptiveConcurrency(shedder.Options{
+			Tracer: tracer,
+		}),
+		graphqlfilters.NewLimits(graphql.Options{MaxBodySize: o.BodyPredicatesMaxSize}),
+		graphqlfilters.NewPersistedQueries(graphql.Options{MaxBodySize: o.BodyPredicatesMaxSize}),
+		graphqlfilters.NewMetrics(graphql.Options{MaxBodySize: o.BodyPredicatesMaxSize}),
 	)
 
+	var (
+		listenerLoad    *queuelistener.Load
+		priorityOptions schedulerfilters.PriorityOptions
+	)
+
+	if o.EnableTCPQueue {
+		listenerLoad = &queuelistener.Load{}
+		priorityOptions.ListenerLoad = listenerLoad
+	}
+
+	o.CustomFilters = append(o.CustomFilters, schedulerfilters.NewPriorityWithOptions(priorityOptions))
+
 	if o.OIDCSecretsFile != "" {
 		oidcClientId, _ := os.LookupEnv("OIDC_CLIENT_ID")
 		oidcClientSecret, _ := os.LookupEnv("OIDC_CLIENT_SECRET")
@@ -1860,6 +2448,21 @@ func run(o Options, sig chan os.Signal, idleConnsCH chan struct{}) error {
 		}
 	}
 
+	var acmeManager *acme.Manager
+	if o.ACMEDirectoryURL != "" {
+		var ring *skpnet.RedisRingClient
+		if redisOptions != nil {
+			ring = skpnet.NewRedisRingClient(redisOptions)
+			defer ring.Close()
+		}
+
+		acmeManager, err = o.acmeManager(cr, swarmer, ring, mtr)
+		if err != nil {
+			return fmt.Errorf("failed to create ACME manager: %w", err)
+		}
+		defer acmeManager.Close()
+	}
+
 	var ratelimitRegistry *ratelimit.Registry
 	var failClosedRatelimitPostProcessor *ratelimitfilters.FailClosedPostProcessor
 	if o.EnableRatelimiters || len(o.RatelimitSettings) > 0 {
@@ -1974,6 +2577,44 @@ func run(o Options, sig chan os.Signal, idleConnsCH chan struct{}) error {
 	}
 	o.CustomFilters = append(o.CustomFilters, lua)
 
+	if len(o.GeoIPDatabases) > 0 {
+		geoResolver, err := geoip.New(geoip.Options{
+			Databases:      o.GeoIPDatabases,
+			ClientIPSource: o.GeoIPClientIPSource,
+			ReloadInterval: o.GeoIPReloadInterval,
+		})
+		if err != nil {
+			log.Errorf("Failed to load geoip databases: %v.", err)
+			return err
+		}
+		defer geoResolver.Close()
+
+		o.CustomFilters = append(o.CustomFilters, geofilters.NewHeaders(geoResolver))
+		o.CustomPredicates = append(o.CustomPredicates, geo.NewCountry(geoResolver), geo.NewASN(geoResolver))
+	}
+
+	if len(o.IPLists) > 0 {
+		lists, err := iplist.ParseDefinitions(o.IPLists)
+		if err != nil {
+			log.Errorf("Failed to parse ip lists: %v.", err)
+			return err
+		}
+
+		ipLists, err := iplist.NewRegistry(iplist.Options{
+			Lists:          lists,
+			ReloadInterval: o.IPListsReloadInterval,
+			ClientIPSource: o.IPListsClientIPSource,
+			Metrics:        mtr,
+		})
+		if err != nil {
+			log.Errorf("Failed to load ip lists: %v.", err)
+			return err
+		}
+		defer ipLists.Close()
+
+		o.CustomFilters = append(o.CustomFilters, ipfilter.NewDenyList(ipLists), ipfilter.NewAllowList(ipLists))
+	}
+
 	// create routing
 	// create the proxy instance
 	var mo routing.MatchingOptions
@@ -1992,6 +2633,9 @@ func run(o Options, sig chan os.Signal, idleConnsCH chan struct{}) error {
 		updateBuffer = 0
 	}
 
+	// the Shutdown predicates match once the shutdown started
+	shuttingDown := make(chan struct{})
+
 	// include bundled custom predicates
 	o.CustomPredicates = append(o.CustomPredicates,
 		source.New(),
@@ -2007,7 +2651,7 @@ func run(o Options, sig chan os.Signal, idleConnsCH chan struct{}) error {
 		traffic.NewSegment(),
 		primitive.NewTrue(),
 		primitive.NewFalse(),
-		primitive.NewShutdown(),
+		primitive.NewShutdownNotify(shuttingDown),
 		pauth.NewJWTPayloadAllKV(),
 		pauth.NewJWTPayloadAnyKV(),
 		pauth.NewJWTPayloadAllKVRegexp(),
@@ -2019,6 +2663,13 @@ func run(o Options, sig chan os.Signal, idleConnsCH chan struct{}) error {
 		forwarded.NewForwardedProto(),
 		host.NewAny(),
 		content.NewContentLengthBetween(),
+		content.NewJSONBodyField(content.BodyOptions{MaxBodySize: o.BodyPredicatesMaxSize}),
+		content.NewJSONBodyFieldRegexp(content.BodyOptions{MaxBodySize: o.BodyPredicatesMaxSize}),
+		content.NewFormValue(content.BodyOptions{MaxBodySize: o.BodyPredicatesMaxSize}),
+		pgraphql.NewOperationType(graphql.Options{MaxBodySize: o.BodyPredicatesMaxSize}),
+		pgraphql.NewOperationName(graphql.Options{MaxBodySize: o.BodyPredicatesMaxSize}),
+		pproxyprotocol.NewTLV(),
+		pproxyprotocol.NewAWSVPCEndpointID(),
 	)
 
 	// provide default value for wrapper if not defined
@@ -2032,6 +2683,8 @@ func run(o Options, sig chan os.Signal, idleConnsCH chan struct{}) error {
 		Metrics:                mtr,
 		EnableRouteFIFOMetrics: o.EnableRouteFIFOMetrics,
 		EnableRouteLIFOMetrics: o.EnableRouteLIFOMetrics,
+		FeedbackHeader:         o.SchedulerFeedbackHeader,
+		EnableLiveConfig:       o.EnableSchedulerLiveConfig,
 	})
 	defer schedulerRegistry.Close()
 
@@ -2049,13 +2702,20 @@ func run(o Options, sig chan os.Signal, idleConnsCH chan struct{}) error {
 		MaxHealthCheckDropProbability: passiveHealthCheck.MaxDropProbability,
 	})
 	ro := routing.Options{
-		FilterRegistry:  o.filterRegistry(),
-		MatchingOptions: mo,
-		PollTimeout:     o.SourcePollTimeout,
-		DataClients:     dataClients,
-		Predicates:      o.CustomPredicates,
-		UpdateBuffer:    updateBuffer,
-		SuppressLogs:    o.SuppressRouteUpdateLogs,
+		FilterRegistry:   o.filterRegistry(),
+		MatchingOptions:  mo,
+		PollTimeout:      o.SourcePollTimeout,
+		DataClients:      dataClients,
+		Predicates:       o.CustomPredicates,
+		UpdateBuffer:     updateBuffer,
+		SuppressLogs:     o.SuppressRouteUpdateLogs,
+		RouteHistorySize: o.RouteHistorySize,
+		Rollout: routing.RolloutOptions{
+			Fraction:           o.RouteRolloutFraction,
+			Duration:           o.RouteRolloutDuration,
+			MinRequests:        o.RouteRolloutMinRequests,
+			ErrorRateThreshold: o.RouteRolloutErrorThreshold,
+		},
 		PostProcessors: []routing.PostProcessor{
 			loadbalancer.NewAlgorithmProvider(),
 			endpointRegistry,
@@ -2072,8 +2732,10 @@ func run(o Options, sig chan os.Signal, idleConnsCH chan struct{}) error {
 		ro.PostProcessors = append(ro.PostProcessors, failClosedRatelimitPostProcessor)
 	}
 
-	if o.DefaultFilters != nil {
-		ro.PreProcessors = append(ro.PreProcessors, o.DefaultFilters)
+	defaultFilters := &defaultFiltersPreProcessor{}
+	if o.DefaultFilters != nil || o.RuntimeSettings != nil {
+		defaultFilters.current.Store(o.DefaultFilters)
+		ro.PreProcessors = append(ro.PreProcessors, defaultFilters)
 	}
 
 	if o.CloneRoute != nil {
@@ -2098,6 +2760,22 @@ func run(o Options, sig chan os.Signal, idleConnsCH chan struct{}) error {
 		ro.PostProcessors = append(ro.PostProcessors, opaRegistry)
 	}
 
+	if acmeManager != nil {
+		ro.PostProcessors = append(ro.PostProcessors, acmeManager)
+	}
+
+	var webSocketRelay *websocket.Relay
+	if o.ExperimentalUpgrade {
+		webSocketRelay = websocket.NewRelay(websocket.Options{
+			MaxMessageSize: o.WebSocketMaxMessageSize,
+			IdleTimeout:    o.WebSocketIdleTimeout,
+			PingInterval:   o.WebSocketPingInterval,
+			PingTimeout:    o.WebSocketPingTimeout,
+			Metrics:        mtr,
+		})
+		ro.PostProcessors = append(ro.PostProcessors, webSocketRelay)
+	}
+
 	if o.CustomRoutingPreProcessors != nil {
 		ro.PreProcessors = append(ro.PreProcessors, o.CustomRoutingPreProcessors...)
 	}
@@ -2118,8 +2796,11 @@ func run(o Options, sig chan os.Signal, idleConnsCH chan struct{}) error {
 		IdleConnectionsPerHost:     o.IdleConnectionsPerHost,
 		CloseIdleConnsPeriod:       o.CloseIdleConnsPeriod,
 		FlushInterval:              o.BackendFlushInterval,
+		StreamingIdleTimeout:       o.StreamingIdleTimeout,
 		ExperimentalUpgrade:        o.ExperimentalUpgrade,
 		ExperimentalUpgradeAudit:   o.ExperimentalUpgradeAudit,
+		WebSocket:                  webSocketRelay,
+		WebSocketFrameParser:       o.WebSocketFrameParser,
 		MaxLoopbacks:               o.MaxLoopbacks,
 		DefaultHTTPStatus:          o.DefaultHTTPStatus,
 		Timeout:                    o.TimeoutBackend,
@@ -2140,7 +2821,10 @@ func run(o Options, sig chan os.Signal, idleConnsCH chan struct{}) error {
 	}
 
 	if o.EnableBreakers || len(o.BreakerSettings) > 0 {
-		proxyParams.CircuitBreakers = circuit.NewRegistry(o.BreakerSettings...)
+		proxyParams.CircuitBreakers = circuit.NewRegistryWithOptions(circuit.Options{
+			Settings: o.BreakerSettings,
+			Metrics:  mtr,
+		})
 	}
 
 	if o.DebugListener != "" {
@@ -2151,6 +2835,10 @@ func run(o Options, sig chan os.Signal, idleConnsCH chan struct{}) error {
 		go func() { http.ListenAndServe(o.DebugListener, dbg) /* #nosec */ }()
 	}
 
+	if o.RuntimeSettings != nil {
+		go applyRuntimeSettings(o.RuntimeSettings, defaultFilters, routing, proxyParams.CircuitBreakers, ratelimitRegistry, sampler)
+	}
+
 	// init support endpoints
 	supportListener := o.SupportListener
 
@@ -2163,6 +2851,14 @@ func run(o Options, sig chan os.Signal, idleConnsCH chan struct{}) error {
 		mux := http.NewServeMux()
 		mux.Handle("/routes", routing)
 		mux.Handle("/routes/", routing)
+		mux.Handle("/queues", schedulerRegistry)
+		if proxyParams.CircuitBreakers != nil {
+			mux.Handle("/circuit-breakers", proxyParams.CircuitBreakers)
+		}
+
+		if o.ConfigHandler != nil {
+			mux.Handle("/config", o.ConfigHandler)
+		}
 
 		metricsHandler := metrics.NewHandler(mtrOpts, mtr)
 		mux.Handle("/metrics", metricsHandler)
@@ -2217,7 +2913,18 @@ func run(o Options, sig chan os.Signal, idleConnsCH chan struct{}) error {
 	<-routing.FirstLoad()
 	log.Info("Dataclients are updated once, first load complete")
 
-	return listenAndServeQuit(o.CustomHttpHandlerWrap(proxy), &o, sig, idleConnsCH, mtr, cr)
+	hooks := shutdownHooks{
+		start:     []func(){func() { close(shuttingDown) }},
+		terminate: []func(){proxy.CloseStreams, proxy.CloseUpgrades},
+		upgraded:  proxy.UpgradedConns,
+	}
+
+	if webSocketRelay != nil {
+		hooks.terminate = append(hooks.terminate, webSocketRelay.Close)
+		hooks.upgraded = func() int { return proxy.UpgradedConns() + webSocketRelay.Len() }
+	}
+
+	return listenAndServeQuit(o.CustomHttpHandlerWrap(proxy), &o, sig, idleConnsCH, mtr, cr, listenerLoad, acmeManager, hooks)
 }
 
 // Run skipper.
@@ -1,20 +1,34 @@
 package skipper
 
 import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
 	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
 	"fmt"
 	"io"
+	"math/big"
 	"net"
 	"net/http"
 	stdlibhttptest "net/http/httptest"
 	"os"
+	"path/filepath"
 	"syscall"
 	"testing"
 	"time"
 
+	"github.com/quic-go/quic-go/http3"
 	log "github.com/sirupsen/logrus"
 
+	"github.com/zalando/skipper/circuit"
 	"github.com/zalando/skipper/dataclients/routestring"
+	"github.com/zalando/skipper/eskip"
 	"github.com/zalando/skipper/filters"
 	flog "github.com/zalando/skipper/filters/accesslog"
 	"github.com/zalando/skipper/filters/auth"
@@ -22,11 +36,14 @@ import (
 	fscheduler "github.com/zalando/skipper/filters/scheduler"
 	"github.com/zalando/skipper/loadbalancer"
 	"github.com/zalando/skipper/metrics/metricstest"
+	"github.com/zalando/skipper/predicates/source"
 	"github.com/zalando/skipper/proxy"
 	"github.com/zalando/skipper/ratelimit"
 	"github.com/zalando/skipper/routing"
 	"github.com/zalando/skipper/scheduler"
+	"github.com/zalando/skipper/secrets/acme"
 	"github.com/zalando/skipper/secrets/certregistry"
+	"github.com/zalando/skipper/tracing"
 	"github.com/zalando/skipper/tracing/tracingtest"
 
 	"github.com/stretchr/testify/assert"
@@ -39,7 +56,7 @@ const (
 )
 
 func listenAndServe(proxy http.Handler, o *Options) error {
-	return listenAndServeQuit(proxy, o, nil, nil, nil, nil)
+	return listenAndServeQuit(proxy, o, nil, nil, nil, nil, nil, nil, shutdownHooks{})
 }
 
 func testListener() bool {
@@ -385,7 +402,7 @@ func testServerShutdown(t *testing.T, o *Options, scheme string) {
 	sigs := make(chan os.Signal, 1)
 	done := make(chan struct{})
 	go func() {
-		err := listenAndServeQuit(proxy, o, sigs, done, nil, nil)
+		err := listenAndServeQuit(proxy, o, sigs, done, nil, nil, nil, nil, shutdownHooks{})
 		require.NoError(t, err)
 	}()
 
@@ -429,6 +446,564 @@ func testServerShutdown(t *testing.T, o *Options, scheme string) {
 	}
 }
 
+func TestServerShutdownPhases(t *testing.T) {
+	const (
+		healthcheckInterval = 200 * time.Millisecond
+		drainInterval       = 500 * time.Millisecond
+	)
+
+	address, err := findAddress()
+	require.NoError(t, err)
+
+	o := &Options{
+		Address:                    address,
+		WaitForHealthcheckInterval: healthcheckInterval,
+		ShutdownDrainInterval:      drainInterval,
+	}
+
+	// a stream that finishes when it's terminated
+	streamTerminated := make(chan struct{})
+	streaming := make(chan struct{})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		close(streaming)
+		<-streamTerminated
+	})
+
+	var started, terminated time.Time
+	hooks := shutdownHooks{
+		start: []func(){func() { started = time.Now() }},
+		terminate: []func(){func() {
+			terminated = time.Now()
+			close(streamTerminated)
+		}},
+	}
+
+	sigs := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	go func() {
+		err := listenAndServeQuit(handler, o, sigs, done, nil, nil, nil, nil, hooks)
+		require.NoError(t, err)
+	}()
+
+	rsp, err := waitConnGet("http://" + address)
+	require.NoError(t, err)
+	defer rsp.Body.Close()
+	<-streaming
+
+	sigs <- syscall.SIGTERM
+
+	_, err = io.ReadAll(rsp.Body)
+	require.NoError(t, err)
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Shutdown takes too long after the stream was terminated")
+	}
+
+	require.False(t, started.IsZero(), "shutdown not started")
+	assert.GreaterOrEqual(t, terminated.Sub(started), healthcheckInterval+drainInterval)
+}
+
+func TestServerShutdownTimeout(t *testing.T) {
+	address, err := findAddress()
+	require.NoError(t, err)
+
+	o := &Options{
+		Address:               address,
+		ShutdownDrainInterval: time.Hour,
+		ShutdownTimeout:       200 * time.Millisecond,
+	}
+
+	blocking := make(chan struct{})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		close(blocking)
+		<-r.Context().Done()
+	})
+
+	terminated := make(chan struct{})
+	hooks := shutdownHooks{terminate: []func(){func() { close(terminated) }}}
+
+	sigs := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	go func() {
+		err := listenAndServeQuit(handler, o, sigs, done, nil, nil, nil, nil, hooks)
+		require.NoError(t, err)
+	}()
+
+	rsp, err := waitConnGet("http://" + address)
+	require.NoError(t, err)
+	defer rsp.Body.Close()
+	<-blocking
+
+	sigs <- syscall.SIGTERM
+
+	// the connection is closed forcefully
+	_, err = io.ReadAll(rsp.Body)
+	assert.Error(t, err)
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Shutdown takes too long after the shutdown timeout")
+	}
+
+	select {
+	case <-terminated:
+	default:
+		t.Error("Streams not terminated after the shutdown timeout")
+	}
+}
+
+func TestHTTP3Server(t *testing.T) {
+	address, err := findAddress()
+	require.NoError(t, err)
+
+	o := &Options{
+		Address:                 address,
+		HTTP3Address:            "127.0.0.1:0",
+		CertPathTLS:             "fixtures/test.crt",
+		KeyPathTLS:              "fixtures/test.key",
+		EnableConnMetricsServer: true,
+	}
+
+	dc, err := routestring.New(`r0: * -> inlineContent("OK") -> <shunt>`)
+	require.NoError(t, err)
+
+	rt := routing.New(routing.Options{
+		FilterRegistry: builtin.MakeRegistry(),
+		DataClients:    []routing.DataClient{dc},
+	})
+	defer rt.Close()
+
+	proxy := proxy.New(rt, proxy.OptionsNone)
+	defer proxy.Close()
+
+	m := &metricstest.MockMetrics{}
+	sigs := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	go func() {
+		err := listenAndServeQuit(proxy, o, sigs, done, m, nil, nil, nil, shutdownHooks{})
+		require.NoError(t, err)
+	}()
+
+	var altSvc string
+	require.Eventually(t, func() bool {
+		rsp, err := waitConnGet("https://" + address)
+		if err != nil {
+			return false
+		}
+
+		rsp.Body.Close()
+		altSvc = rsp.Header.Get("Alt-Svc")
+		return altSvc != ""
+	}, time.Second, listenDelay)
+
+	var port int
+	_, err = fmt.Sscanf(altSvc, `h3=":%d"`, &port)
+	require.NoError(t, err, altSvc)
+
+	tr := &http3.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}
+	defer tr.Close()
+
+	rsp, err := (&http.Client{Transport: tr}).Get(fmt.Sprintf("https://127.0.0.1:%d", port))
+	require.NoError(t, err)
+	defer rsp.Body.Close()
+
+	body, err := io.ReadAll(rsp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "OK", string(body))
+	assert.Equal(t, 3, rsp.ProtoMajor)
+	assert.Empty(t, rsp.Header.Get("Alt-Svc"))
+
+	m.WithCounters(func(counters map[string]int64) {
+		// the TCP connections are counted, too
+		assert.GreaterOrEqual(t, counters["lb-conn-new"], int64(2))
+	})
+
+	sigs <- syscall.SIGTERM
+	select {
+	case <-done:
+	case <-time.After(1 * time.Second):
+		t.Errorf("Shutdown takes too long")
+	}
+}
+
+func TestACMEHTTPChallenge(t *testing.T) {
+	address, err := findAddress()
+	require.NoError(t, err)
+
+	insecureAddress, err := findAddress()
+	require.NoError(t, err)
+
+	o := &Options{
+		Address:          address,
+		InsecureAddress:  insecureAddress,
+		CertPathTLS:      "fixtures/test.crt",
+		KeyPathTLS:       "fixtures/test.key",
+		ACMEDirectoryURL: "http://127.0.0.1:1/directory",
+		ACMEStorageDir:   t.TempDir(),
+	}
+
+	storage, err := acme.NewFileStorage(o.ACMEStorageDir)
+	require.NoError(t, err)
+	require.NoError(t, storage.Put(context.Background(), "http-01/token", []byte("token.thumbprint")))
+
+	cr := certregistry.NewCertRegistry()
+	am, err := o.acmeManager(cr, nil, nil, nil)
+	require.NoError(t, err)
+	defer am.Close()
+
+	dc, err := routestring.New(`r0: * -> inlineContent("OK") -> <shunt>`)
+	require.NoError(t, err)
+
+	rt := routing.New(routing.Options{
+		FilterRegistry: builtin.MakeRegistry(),
+		DataClients:    []routing.DataClient{dc},
+		PostProcessors: []routing.PostProcessor{am},
+	})
+	defer rt.Close()
+
+	proxy := proxy.New(rt, proxy.OptionsNone)
+	defer proxy.Close()
+
+	sigs := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	go func() {
+		err := listenAndServeQuit(proxy, o, sigs, done, nil, cr, nil, am, shutdownHooks{})
+		require.NoError(t, err)
+	}()
+
+	get := func(u string) (string, error) {
+		rsp, err := waitConnGet(u)
+		if err != nil {
+			return "", err
+		}
+
+		defer rsp.Body.Close()
+		b, err := io.ReadAll(rsp.Body)
+		return string(b), err
+	}
+
+	var body string
+	require.Eventually(t, func() bool {
+		body, err = get("http://" + insecureAddress + "/.well-known/acme-challenge/token")
+		return err == nil
+	}, time.Second, listenDelay)
+
+	assert.Equal(t, "token.thumbprint", body)
+
+	body, err = get("http://" + insecureAddress + "/.well-known/acme-challenge/unknown")
+	require.NoError(t, err)
+	assert.Equal(t, "OK", body)
+
+	body, err = get("https://" + address + "/")
+	require.NoError(t, err)
+	assert.Equal(t, "OK", body)
+
+	sigs <- syscall.SIGTERM
+	<-done
+}
+
+// encodeACMECertificate encodes a self-signed certificate in the format
+// of the ACME storage.
+func encodeACMECertificate(t *testing.T, host string) ([]byte, []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		DNSNames:     []string{host},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	require.NoError(t, err)
+
+	b, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, pem.Encode(&buf, &pem.Block{Type: "EC PRIVATE KEY", Bytes: b}))
+	require.NoError(t, pem.Encode(&buf, &pem.Block{Type: "CERTIFICATE", Bytes: der}))
+	return buf.Bytes(), der
+}
+
+func TestACMETLSChallengeWithClientAuth(t *testing.T) {
+	address, err := findAddress()
+	require.NoError(t, err)
+
+	policies := filepath.Join(t.TempDir(), "policies.yaml")
+	require.NoError(t, os.WriteFile(policies, []byte(`
+policies:
+- hosts: [example.org, www.example.org]
+  clientAuth: require
+  clientCAFile: fixtures/test.crt
+`), 0644))
+
+	o := &Options{
+		Address:          address,
+		CertPathTLS:      "fixtures/test.crt",
+		KeyPathTLS:       "fixtures/test.key",
+		TLSPoliciesFile:  policies,
+		ACMEDirectoryURL: "http://127.0.0.1:1/directory",
+		ACMEChallenges:   []string{acme.ChallengeTLSALPN01},
+		ACMEStorageDir:   t.TempDir(),
+	}
+
+	storage, err := acme.NewFileStorage(o.ACMEStorageDir)
+	require.NoError(t, err)
+
+	challenge, challengeDER := encodeACMECertificate(t, "example.org")
+	require.NoError(t, storage.Put(context.Background(), "tls-alpn-01/example.org", challenge))
+
+	cr := certregistry.NewCertRegistry()
+	p, err := certregistry.LoadPolicies(o.TLSPoliciesFile)
+	require.NoError(t, err)
+	cr.SetPolicies("file", p)
+
+	am, err := o.acmeManager(cr, nil, nil, nil)
+	require.NoError(t, err)
+	defer am.Close()
+
+	dc, err := routestring.New(`r0: Host("^(www[.])?example[.]org$") -> inlineContent("OK") -> <shunt>`)
+	require.NoError(t, err)
+
+	rt := routing.New(routing.Options{
+		FilterRegistry:  builtin.MakeRegistry(),
+		DataClients:     []routing.DataClient{dc},
+		PostProcessors:  []routing.PostProcessor{am},
+		SignalFirstLoad: true,
+	})
+	defer rt.Close()
+	<-rt.FirstLoad()
+
+	proxy := proxy.New(rt, proxy.OptionsNone)
+	defer proxy.Close()
+
+	sigs := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	go func() {
+		err := listenAndServeQuit(proxy, o, sigs, done, nil, cr, nil, am, shutdownHooks{})
+		require.NoError(t, err)
+	}()
+
+	dial := func(serverName string, protos ...string) (*tls.Conn, error) {
+		return tls.Dial("tcp", address, &tls.Config{
+			ServerName:         serverName,
+			NextProtos:         protos,
+			InsecureSkipVerify: true,
+		})
+	}
+
+	// the challenge is answered without the client certificate
+	var conn *tls.Conn
+	require.Eventually(t, func() bool {
+		conn, err = dial("example.org", "acme-tls/1")
+		return err == nil
+	}, time.Second, listenDelay)
+	defer conn.Close()
+
+	state := conn.ConnectionState()
+	assert.Equal(t, "acme-tls/1", state.NegotiatedProtocol)
+	require.NotEmpty(t, state.PeerCertificates)
+	assert.Equal(t, challengeDER, state.PeerCertificates[0].Raw)
+
+	// but the connection cannot be used to send HTTP requests
+	_, err = io.WriteString(conn, "GET / HTTP/1.1\r\nHost: example.org\r\n\r\n")
+	if err == nil {
+		require.NoError(t, conn.SetReadDeadline(time.Now().Add(time.Second)))
+		b, err := io.ReadAll(conn)
+		assert.Empty(t, b)
+		if err != nil {
+			var netErr net.Error
+			assert.False(t, errors.As(err, &netErr) && netErr.Timeout(), "connection was not closed")
+		}
+	}
+
+	// HTTP/2 is still enabled
+	conn1, err := dial("other.example.org", "h2", "http/1.1")
+	require.NoError(t, err)
+	assert.Equal(t, "h2", conn1.ConnectionState().NegotiatedProtocol)
+	conn1.Close()
+
+	// no challenge is pending for the host
+	conn2, err := dial("www.example.org", "acme-tls/1")
+	if err == nil {
+		// the TLS 1.3 handshake errors are received on the first read
+		_, err = conn2.Read(make([]byte, 1))
+		conn2.Close()
+	}
+	assert.Error(t, err)
+
+	// the HTTP requests still require the client certificate
+	conn3, err := dial("example.org", "http/1.1")
+	if err == nil {
+		_, err = io.WriteString(conn3, "GET / HTTP/1.1\r\nHost: example.org\r\n\r\n")
+		if err == nil {
+			_, err = conn3.Read(make([]byte, 1))
+		}
+
+		conn3.Close()
+	}
+	assert.Error(t, err)
+
+	sigs <- syscall.SIGTERM
+	<-done
+}
+
+func TestTLSPolicies(t *testing.T) {
+	address, err := findAddress()
+	require.NoError(t, err)
+
+	policies := filepath.Join(t.TempDir(), "policies.yaml")
+	require.NoError(t, os.WriteFile(policies, []byte(`
+policies:
+- hosts: [strict.example.org]
+  minVersion: "1.3"
+  alpnProtocols: [http/1.1]
+`), 0644))
+
+	o := &Options{
+		Address:            address,
+		CertPathTLS:        "fixtures/test.crt",
+		KeyPathTLS:         "fixtures/test.key",
+		TLSMinVersion:      tls.VersionTLS12,
+		TLSPoliciesFile:    policies,
+		EnableOCSPStapling: true,
+	}
+
+	cr := certregistry.NewCertRegistry()
+	p, err := certregistry.LoadPolicies(o.TLSPoliciesFile)
+	require.NoError(t, err)
+	cr.SetPolicies("file", p)
+
+	rt := routing.New(routing.Options{
+		FilterRegistry: builtin.MakeRegistry(),
+		DataClients:    []routing.DataClient{},
+	})
+	defer rt.Close()
+
+	proxy := proxy.New(rt, proxy.OptionsNone)
+	defer proxy.Close()
+
+	sigs := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	go func() {
+		err := listenAndServeQuit(proxy, o, sigs, done, nil, cr, nil, nil, shutdownHooks{})
+		require.NoError(t, err)
+	}()
+
+	handshake := func(serverName string, maxVersion uint16) (tls.ConnectionState, error) {
+		conn, err := tls.Dial("tcp", address, &tls.Config{
+			ServerName:         serverName,
+			MaxVersion:         maxVersion,
+			NextProtos:         []string{"h2", "http/1.1"},
+			InsecureSkipVerify: true,
+		})
+		if err != nil {
+			return tls.ConnectionState{}, err
+		}
+
+		defer conn.Close()
+		return conn.ConnectionState(), nil
+	}
+
+	var state tls.ConnectionState
+	require.Eventually(t, func() bool {
+		state, err = handshake("www.example.org", tls.VersionTLS12)
+		return err == nil
+	}, time.Second, listenDelay)
+
+	assert.Equal(t, "h2", state.NegotiatedProtocol)
+
+	_, err = handshake("strict.example.org", tls.VersionTLS12)
+	assert.Error(t, err)
+
+	state, err = handshake("strict.example.org", tls.VersionTLS13)
+	require.NoError(t, err)
+	assert.Equal(t, uint16(tls.VersionTLS13), state.Version)
+	assert.Equal(t, "http/1.1", state.NegotiatedProtocol)
+
+	sigs <- syscall.SIGTERM
+	<-done
+}
+
+func TestProxyProtocol(t *testing.T) {
+	address, err := findAddress()
+	require.NoError(t, err)
+
+	o := &Options{
+		Address:                      address,
+		ProxyProtocolTrustedNetworks: []string{"127.0.0.1", "::1"},
+		CustomPredicates:             []routing.PredicateSpec{source.NewClientIP()},
+	}
+
+	dc, err := routestring.New(`
+		proxied: ClientIP("192.0.2.1") -> inlineContent("proxied") -> <shunt>;
+		direct: * -> inlineContent("direct") -> <shunt>;
+	`)
+	require.NoError(t, err)
+
+	rt := routing.New(routing.Options{
+		FilterRegistry: builtin.MakeRegistry(),
+		Predicates:     o.CustomPredicates,
+		DataClients:    []routing.DataClient{dc},
+	})
+	defer rt.Close()
+
+	proxy := proxy.New(rt, proxy.OptionsNone)
+	defer proxy.Close()
+
+	sigs := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	go func() {
+		err := listenAndServeQuit(proxy, o, sigs, done, nil, nil, nil, nil, shutdownHooks{})
+		require.NoError(t, err)
+	}()
+
+	get := func(header string) (string, error) {
+		c, err := net.Dial("tcp", address)
+		if err != nil {
+			return "", err
+		}
+
+		defer c.Close()
+		if _, err := io.WriteString(c, header+"GET / HTTP/1.1\r\nHost: example.org\r\nConnection: close\r\n\r\n"); err != nil {
+			return "", err
+		}
+
+		rsp, err := http.ReadResponse(bufio.NewReader(c), nil)
+		if err != nil {
+			return "", err
+		}
+
+		defer rsp.Body.Close()
+		b, err := io.ReadAll(rsp.Body)
+		return string(b), err
+	}
+
+	var body string
+	require.Eventually(t, func() bool {
+		body, err = get("PROXY TCP4 192.0.2.1 198.51.100.1 12345 443\r\n")
+		return err == nil
+	}, time.Second, listenDelay)
+
+	assert.Equal(t, "proxied", body)
+
+	body, err = get("PROXY TCP4 192.0.2.2 198.51.100.1 12345 443\r\n")
+	require.NoError(t, err)
+	assert.Equal(t, "direct", body)
+
+	sigs <- syscall.SIGTERM
+	<-done
+}
+
 type (
 	customRatelimitSpec   struct{ registry *ratelimit.Registry }
 	customRatelimitFilter struct{}
@@ -607,3 +1182,54 @@ func TestDataClients(t *testing.T) {
 
 	sigs <- syscall.SIGTERM
 }
+
+func TestApplyRuntimeSettings(t *testing.T) {
+	dc, err := routestring.New(`r: * -> status(200) -> <shunt>`)
+	require.NoError(t, err)
+
+	defaultFilters := &defaultFiltersPreProcessor{}
+	rt := routing.New(routing.Options{
+		FilterRegistry: builtin.MakeRegistry(),
+		DataClients:    []routing.DataClient{dc},
+		PreProcessors:  []routing.PreProcessor{defaultFilters},
+		SuppressLogs:   true,
+	})
+	defer rt.Close()
+	<-rt.FirstLoad()
+
+	breakers := circuit.NewRegistry()
+	ratelimits := ratelimit.NewRegistry()
+	defer ratelimits.Close()
+
+	sampler := tracing.NewSampler(tracingtest.NewTracer(), 0)
+
+	settings := make(chan RuntimeSettings)
+	done := make(chan struct{})
+	go func() {
+		applyRuntimeSettings(settings, defaultFilters, rt, breakers, ratelimits, sampler)
+		close(done)
+	}()
+
+	settings <- RuntimeSettings{
+		DefaultFilters:      &eskip.DefaultFilters{Prepend: eskip.MustParseFilters(`setPath("/foo")`)},
+		BreakerSettings:     []circuit.BreakerSettings{{Type: circuit.ConsecutiveFailures, Failures: 3}},
+		RatelimitSettings:   []ratelimit.Settings{{Type: ratelimit.ServiceRatelimit, MaxHits: 1, TimeWindow: time.Minute}},
+		TracingSamplingRate: 0.25,
+	}
+	close(settings)
+	<-done
+
+	req := stdlibhttptest.NewRequest("GET", "/", nil)
+	assert.Eventually(t, func() bool {
+		r, _ := rt.Route(req)
+		return r != nil && len(r.Filters) == 2 && r.Filters[0].Name == "setPath"
+	}, time.Second, 10*time.Millisecond)
+
+	assert.NotNil(t, breakers.Get(circuit.BreakerSettings{Host: "example.org"}))
+
+	ratelimits.Check(req)
+	_, retry := ratelimits.Check(req)
+	assert.NotZero(t, retry)
+
+	assert.Equal(t, 0.25, sampler.Rate())
+}
@@ -1,17 +1,15 @@
 package swarm
 
 import (
-	"crypto/tls"
-	"crypto/x509"
-	"errors"
 	"io"
-	"net"
 	"net/http"
 	"os"
 	"time"
 
 	"github.com/cenkalti/backoff"
 	log "github.com/sirupsen/logrus"
+
+	"github.com/zalando/skipper/dataclients/kubernetes/incluster"
 )
 
 const (
@@ -22,18 +20,8 @@ const (
 	// DefaultLabelSelectorValue is the default label value to select Pods for peer information
 	DefaultLabelSelectorValue = "skipper-ingress"
 
-	defaultKubernetesURL    = "http://localhost:8001"
-	serviceAccountDir       = "/var/run/secrets/kubernetes.io/serviceaccount/"
-	serviceAccountTokenKey  = "token"
-	serviceAccountRootCAKey = "ca.crt"
-	serviceHostEnvVar       = "KUBERNETES_SERVICE_HOST"
-	servicePortEnvVar       = "KUBERNETES_SERVICE_PORT"
-	maxRetries              = 12
-)
-
-var (
-	errAPIServerURLNotFound = errors.New("kubernetes API server URL could not be constructed from env vars")
-	errInvalidCertificate   = errors.New("invalid CA")
+	defaultKubernetesURL = "http://localhost:8001"
+	maxRetries           = 12
 )
 
 // KubernetesOptions are Kubernetes specific swarm options, that are
@@ -94,7 +82,7 @@ func (c *ClientKubernetes) Stop() {
 // find peers. A partial copy of the Kubernetes dataclient.
 func NewClientKubernetes(kubernetesInCluster bool, kubernetesURL string) (*ClientKubernetes, error) {
 	quit := make(chan struct{})
-	httpClient, err := buildHTTPClient(serviceAccountDir+serviceAccountRootCAKey, kubernetesInCluster, quit)
+	httpClient, err := buildHTTPClient(incluster.RootCAFile, kubernetesInCluster, quit)
 	if err != nil {
 		return nil, err
 	}
@@ -104,7 +92,7 @@ func NewClientKubernetes(kubernetesInCluster bool, kubernetesURL string) (*Clien
 		return nil, err
 	}
 
-	token, err := readServiceAccountToken(serviceAccountDir+serviceAccountTokenKey, kubernetesInCluster)
+	token, err := readServiceAccountToken(incluster.TokenFile, kubernetesInCluster)
 	if err != nil {
 		return nil, err
 	}
@@ -123,50 +111,7 @@ func buildHTTPClient(certFilePath string, inCluster bool, quit chan struct{}) (*
 		return http.DefaultClient, nil
 	}
 
-	rootCA, err := os.ReadFile(certFilePath)
-	if err != nil {
-		return nil, err
-	}
-	certPool := x509.NewCertPool()
-	if !certPool.AppendCertsFromPEM(rootCA) {
-		return nil, errInvalidCertificate
-	}
-
-	tlsConfig := &tls.Config{
-		MinVersion: tls.VersionTLS12,
-		RootCAs:    certPool,
-	}
-
-	transport := &http.Transport{
-		DialContext: (&net.Dialer{
-			Timeout:   10 * time.Second,
-			KeepAlive: 30 * time.Second,
-			DualStack: false,
-		}).DialContext,
-		TLSHandshakeTimeout:   10 * time.Second,
-		ResponseHeaderTimeout: 10 * time.Second,
-		ExpectContinueTimeout: 30 * time.Second,
-		MaxIdleConns:          5,
-		MaxIdleConnsPerHost:   5,
-		TLSClientConfig:       tlsConfig,
-	}
-	// regularly force closing idle connections
-	go func() {
-		ticker := time.NewTicker(10 * time.Second)
-		defer ticker.Stop()
-		for {
-			select {
-			case <-ticker.C:
-				transport.CloseIdleConnections()
-			case <-quit:
-				return
-			}
-		}
-	}()
-
-	return &http.Client{
-		Transport: transport,
-	}, nil
+	return incluster.NewHTTPClient(certFilePath, quit)
 }
 
 func buildAPIURL(kubernetesInCluster bool, kubernetesURL string) (string, error) {
@@ -177,12 +122,7 @@ func buildAPIURL(kubernetesInCluster bool, kubernetesURL string) (string, error)
 		return kubernetesURL, nil
 	}
 
-	host, port := os.Getenv(serviceHostEnvVar), os.Getenv(servicePortEnvVar)
-	if host == "" || port == "" {
-		return "", errAPIServerURLNotFound
-	}
-
-	return "https://" + net.JoinHostPort(host, port), nil
+	return incluster.APIURL()
 }
 
 func readServiceAccountToken(tokenFilePath string, inCluster bool) (string, error) {
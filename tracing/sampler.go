@@ -0,0 +1,90 @@
+package tracing
+
+import (
+	"math"
+	"math/rand/v2"
+	"sync/atomic"
+
+	ot "github.com/opentracing/opentracing-go"
+)
+
+var noopSpanContext = ot.NoopTracer{}.StartSpan("").Context()
+
+// Sampler is a tracer that records only a ratio of the traces started by
+// skipper, i.e. the spans without a parent. The spans of the traces that
+// are not sampled are not passed to the wrapped tracer, and their context
+// is not propagated. The spans with a recorded parent, e.g. continuing the
+// traces of the clients, are always passed to the wrapped tracer, that may
+// apply its own sampling. The ratio can be changed at runtime.
+type Sampler struct {
+	tracer ot.Tracer
+	rate   atomic.Uint64
+}
+
+// NewSampler creates a Sampler wrapping a tracer. The rate is the ratio
+// of the recorded traces, between 0 and 1. When 0, every trace is
+// recorded.
+func NewSampler(tracer ot.Tracer, rate float64) *Sampler {
+	s := &Sampler{tracer: tracer}
+	s.SetRate(rate)
+	return s
+}
+
+// SetRate changes the ratio of the recorded traces. When 0, every trace
+// is recorded.
+func (s *Sampler) SetRate(rate float64) {
+	if rate <= 0 || rate > 1 {
+		rate = 1
+	}
+
+	s.rate.Store(math.Float64bits(rate))
+}
+
+// Rate returns the ratio of the recorded traces.
+func (s *Sampler) Rate() float64 {
+	return math.Float64frombits(s.rate.Load())
+}
+
+func (s *Sampler) sampled(opts []ot.StartSpanOption) bool {
+	var so ot.StartSpanOptions
+	for _, o := range opts {
+		o.Apply(&so)
+	}
+
+	if len(so.References) == 0 {
+		rate := s.Rate()
+		return rate >= 1 || rand.Float64() < rate
+	}
+
+	for _, r := range so.References {
+		if r.ReferencedContext == noopSpanContext {
+			return false
+		}
+	}
+
+	return true
+}
+
+// StartSpan implements opentracing.Tracer.
+func (s *Sampler) StartSpan(operationName string, opts ...ot.StartSpanOption) ot.Span {
+	if !s.sampled(opts) {
+		return ot.NoopTracer{}.StartSpan(operationName)
+	}
+
+	return s.tracer.StartSpan(operationName, opts...)
+}
+
+// Inject implements opentracing.Tracer. It doesn't propagate the context
+// of the traces that are not sampled.
+func (s *Sampler) Inject(sc ot.SpanContext, format interface{}, carrier interface{}) error {
+	if sc == noopSpanContext {
+		return nil
+	}
+
+	return s.tracer.Inject(sc, format, carrier)
+}
+
+// Extract implements opentracing.Tracer.
+func (s *Sampler) Extract(format interface{}, carrier interface{}) (ot.SpanContext, error) {
+	return s.tracer.Extract(format, carrier)
+}
@@ -0,0 +1,56 @@
+package tracing_test
+
+import (
+	"math"
+	"net/http"
+	"testing"
+
+	ot "github.com/opentracing/opentracing-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zalando/skipper/tracing"
+	"github.com/zalando/skipper/tracing/tracingtest"
+)
+
+func TestSampler(t *testing.T) {
+	tracer := tracingtest.NewTracer()
+	s := tracing.NewSampler(tracer, 0)
+	assert.Equal(t, 1.0, s.Rate())
+
+	root := s.StartSpan("root")
+	s.StartSpan("child", ot.ChildOf(root.Context())).Finish()
+	root.Finish()
+	assert.Len(t, tracer.FinishedSpans(), 2)
+
+	h := http.Header{}
+	require.NoError(t, s.Inject(root.Context(), ot.HTTPHeaders, ot.HTTPHeadersCarrier(h)))
+	assert.NotEmpty(t, h)
+
+	// practically none of the new traces is sampled
+	s.SetRate(math.SmallestNonzeroFloat64)
+	tracer.Reset()
+
+	root = s.StartSpan("root")
+	s.StartSpan("child", ot.ChildOf(root.Context())).Finish()
+	root.Finish()
+	assert.Empty(t, tracer.FinishedSpans())
+
+	h = http.Header{}
+	require.NoError(t, s.Inject(root.Context(), ot.HTTPHeaders, ot.HTTPHeadersCarrier(h)))
+	assert.Empty(t, h)
+
+	// the traces of the clients are continued
+	client := tracingtest.NewTracer()
+	h = http.Header{}
+	require.NoError(t, client.Inject(client.StartSpan("client").Context(), ot.HTTPHeaders, ot.HTTPHeadersCarrier(h)))
+
+	parent, err := s.Extract(ot.HTTPHeaders, ot.HTTPHeadersCarrier(h))
+	require.NoError(t, err)
+
+	s.StartSpan("ingress", ot.ChildOf(parent)).Finish()
+	assert.Len(t, tracer.FinishedSpans(), 1)
+
+	s.SetRate(2)
+	assert.Equal(t, 1.0, s.Rate())
+}